@@ -0,0 +1,249 @@
+// Command loadtest drives register and login traffic against a running
+// user-service and reports per-endpoint latency percentiles.
+//
+//	go run ./cmd/loadtest -addr http://localhost:8081 -concurrency 20 -duration 30s
+//
+// Each worker registers its own user, then logs in repeatedly. Workers send
+// distinct X-Forwarded-For addresses by default so the per-IP limits don't
+// cap throughput; the service only believes them with the load generator's
+// address in TRUSTED_PROXIES. Pass -spoof-ip=false to measure the limits
+// themselves.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+type options struct {
+	addr        string
+	scenario    string
+	concurrency int
+	duration    time.Duration
+	requests    int64
+	timeout     time.Duration
+	spoofIP     bool
+	password    string
+}
+
+// sample is one completed request
+type sample struct {
+	endpoint string
+	status   int // 0 when the request failed before a response
+	latency  time.Duration
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.addr, "addr", "http://localhost:8081", "base URL of the service")
+	flag.StringVar(&opts.scenario, "scenario", "login", "register: only registrations; login: register once per worker, then log in")
+	flag.IntVar(&opts.concurrency, "concurrency", 10, "number of concurrent workers")
+	flag.DurationVar(&opts.duration, "duration", 30*time.Second, "how long to run")
+	flag.Int64Var(&opts.requests, "requests", 0, "stop after this many requests in total (0 = no limit)")
+	flag.DurationVar(&opts.timeout, "timeout", 10*time.Second, "per-request timeout")
+	flag.BoolVar(&opts.spoofIP, "spoof-ip", true, "send a distinct X-Forwarded-For per worker")
+	flag.StringVar(&opts.password, "password", "Loadtest-pass1", "password for generated users")
+	flag.Parse()
+
+	if opts.scenario != "register" && opts.scenario != "login" {
+		log.Fatalf("unknown scenario %q", opts.scenario)
+	}
+	if opts.concurrency <= 0 {
+		log.Fatal("concurrency must be positive")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.duration)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	start := time.Now()
+	samples := run(ctx, opts)
+	report(os.Stdout, samples, time.Since(start))
+}
+
+// run starts the workers and collects their samples once all have stopped
+func run(ctx context.Context, opts options) []sample {
+	client := &http.Client{
+		Timeout: opts.timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        opts.concurrency,
+			MaxIdleConnsPerHost: opts.concurrency,
+		},
+	}
+
+	// Unique per run so repeated runs don't collide on emails
+	runID := time.Now().UnixNano()
+
+	var (
+		budget  atomic.Int64
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		samples []sample
+	)
+	budget.Store(opts.requests)
+
+	for w := 0; w < opts.concurrency; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			wk := &worker{
+				client: client,
+				opts:   opts,
+				ip:     fmt.Sprintf("10.%d.%d.%d", w>>16&0xff, w>>8&0xff, w&0xff),
+			}
+
+			take := func() bool {
+				if ctx.Err() != nil {
+					return false
+				}
+				return opts.requests == 0 || budget.Add(-1) >= 0
+			}
+
+			for n := 0; take(); n++ {
+				email := fmt.Sprintf("load-%d-%d-%d@example.com", runID, w, n)
+				if opts.scenario == "login" && n > 0 {
+					email = fmt.Sprintf("load-%d-%d-0@example.com", runID, w)
+				}
+
+				if opts.scenario == "register" || n == 0 {
+					wk.post(ctx, "/v1/users/register", map[string]string{
+						"username": fmt.Sprintf("load%d_%d", w, n),
+						"email":    email,
+						"password": opts.password,
+					})
+					continue
+				}
+				wk.post(ctx, "/v1/users/login", map[string]string{
+					"email":    email,
+					"password": opts.password,
+				})
+			}
+
+			mu.Lock()
+			samples = append(samples, wk.samples...)
+			mu.Unlock()
+		}(w)
+	}
+
+	wg.Wait()
+	return samples
+}
+
+type worker struct {
+	client  *http.Client
+	opts    options
+	ip      string
+	samples []sample
+}
+
+func (w *worker) post(ctx context.Context, path string, body interface{}) {
+	raw, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.opts.addr+path, bytes.NewReader(raw))
+	if err != nil {
+		log.Fatalf("build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.opts.spoofIP {
+		req.Header.Set("X-Forwarded-For", w.ip)
+	}
+
+	start := time.Now()
+	resp, err := w.client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		// The run ending mid-request isn't a failure worth reporting
+		if ctx.Err() != nil {
+			return
+		}
+		w.samples = append(w.samples, sample{endpoint: path, latency: latency})
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	w.samples = append(w.samples, sample{endpoint: path, status: resp.StatusCode, latency: latency})
+}
+
+// report prints throughput, status counts and latency percentiles per endpoint
+func report(out io.Writer, samples []sample, elapsed time.Duration) {
+	byEndpoint := make(map[string][]sample)
+	for _, s := range samples {
+		byEndpoint[s.endpoint] = append(byEndpoint[s.endpoint], s)
+	}
+	endpoints := make([]string, 0, len(byEndpoint))
+	for e := range byEndpoint {
+		endpoints = append(endpoints, e)
+	}
+	sort.Strings(endpoints)
+
+	fmt.Fprintf(out, "%d requests in %s\n\n", len(samples), elapsed.Round(time.Millisecond))
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "endpoint\trequests\treq/s\tp50\tp90\tp99\tmax\tstatuses")
+	for _, e := range endpoints {
+		group := byEndpoint[e]
+		latencies := make([]time.Duration, len(group))
+		statuses := make(map[int]int)
+		for i, s := range group {
+			latencies[i] = s.latency
+			statuses[s.status]++
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\n",
+			e,
+			len(group),
+			float64(len(group))/elapsed.Seconds(),
+			percentile(latencies, 50),
+			percentile(latencies, 90),
+			percentile(latencies, 99),
+			latencies[len(latencies)-1].Round(time.Microsecond),
+			formatStatuses(statuses),
+		)
+	}
+	tw.Flush()
+}
+
+// percentile uses the nearest-rank method on sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1].Round(time.Microsecond)
+}
+
+func formatStatuses(statuses map[int]int) string {
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	var buf bytes.Buffer
+	for i, code := range codes {
+		if i > 0 {
+			buf.WriteString(" ")
+		}
+		label := fmt.Sprint(code)
+		if code == 0 {
+			label = "err"
+		}
+		fmt.Fprintf(&buf, "%s=%d", label, statuses[code])
+	}
+	return buf.String()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"user-service/internal/app"
+	"user-service/internal/application"
+	"user-service/internal/config"
+	"user-service/internal/infrastructure/postgres"
+	"user-service/internal/infrastructure/redis"
+)
+
+// backend is what the subcommands act on. Tests build one over the
+// in-memory fakes.
+type backend struct {
+	users *application.UserService
+	repo  application.UserRepository
+	// warnings are printed before the command runs, e.g. a missing Redis
+	warnings []string
+	close    func()
+}
+
+// connect wires the service against the database and Redis named in cfg.
+// Redis is optional, but without it bans, password resets and role changes
+// can't reach tokens and caches the running service already holds.
+func connect(ctx context.Context, cfg *config.Config) (*backend, error) {
+	db, err := postgres.NewConnection(app.DBConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sql.DB: %w", err)
+	}
+
+	opts := []application.Option{
+		application.WithAuditLogger(postgres.NewAuditRepository(db)),
+	}
+	revokers := application.SessionRevokers{
+		postgres.NewRefreshTokenRepository(db),
+		postgres.NewSessionRepository(db),
+	}
+	if cfg.DeletionGracePeriod > 0 {
+		opts = append(opts, application.WithDeletionGracePeriod(cfg.DeletionGracePeriod))
+	}
+
+	var warnings []string
+	var userCache application.UserCache
+	redisClient, err := redis.NewRedisClient(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf(
+			"Redis unavailable (%v); cached users, sessions and the blocklist won't be updated", err))
+		redisClient = nil
+	} else {
+		userCache = redis.NewUserCache(redisClient, cfg.CacheUserTTL)
+		revokers = append(revokers, redis.NewSessionStore(redisClient, cfg.MaxTokenTTL()))
+		opts = append(opts,
+			application.WithEventPublisher(redis.NewEventPublisher(redisClient)),
+			application.WithUserBlocklist(redis.NewUserBlocklist(redisClient, cfg.BlocklistLocalTTL)),
+		)
+	}
+
+	opts = append(opts, application.WithSessionRevoker(revokers))
+
+	repo := postgres.NewUserRepository(db)
+	users := application.NewUserService(repo, postgres.NewTransactionManager(db), userCache, opts...)
+
+	return &backend{
+		users:    users,
+		repo:     repo,
+		warnings: warnings,
+		close: func() {
+			// Post-commit cleanup must finish before the connections go away
+			users.Wait()
+			if redisClient != nil {
+				redisClient.Close()
+			}
+			sqlDB.Close()
+		},
+	}, nil
+}
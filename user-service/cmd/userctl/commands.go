@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"user-service/internal/domain"
+
+	"github.com/spf13/cobra"
+)
+
+// systemActor is recorded as the actor of CLI actions; audit entries use 0
+// for the system itself
+const systemActor = 0
+
+type passwordResult struct {
+	User userView `json:"user"`
+	// Password is only set when userctl generated it
+	Password string `json:"password,omitempty"`
+}
+
+func newCreateAdminCmd(c *cli) *cobra.Command {
+	var email, username, password string
+
+	cmd := &cobra.Command{
+		Use:   "create-admin",
+		Short: "Create an admin account",
+		Long: "Create an admin account. Without --password a random one is generated\n" +
+			"and printed once.",
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().StringVar(&email, "email", "", "email address (required)")
+	cmd.Flags().StringVar(&username, "username", "", "username (required)")
+	cmd.Flags().StringVar(&password, "password", "", "password; generated when empty")
+	cmd.MarkFlagRequired("email")
+	cmd.MarkFlagRequired("username")
+
+	cmd.RunE = c.run(func(ctx context.Context, b *backend, args []string) error {
+		generated := ""
+		if password == "" {
+			var err error
+			if generated, err = generatePassword(); err != nil {
+				return err
+			}
+			password = generated
+		}
+
+		user := &domain.User{Username: username, Email: email}
+		if err := b.users.CreateAdmin(ctx, user, password, systemActor); err != nil {
+			return err
+		}
+
+		text := fmt.Sprintf("Created admin %s (id %d)", user.Email, user.ID)
+		if generated != "" {
+			text += "\nPassword: " + generated
+		}
+		return c.print(passwordResult{User: viewOf(user), Password: generated}, "%s", text)
+	})
+	return cmd
+}
+
+func newResetPasswordCmd(c *cli) *cobra.Command {
+	var password string
+
+	cmd := &cobra.Command{
+		Use:   "reset-password <id|email>",
+		Short: "Set a new password and log the user out everywhere",
+		Long: "Set a new password and log the user out everywhere. Without --password\n" +
+			"a random one is generated and printed once.",
+		Args: cobra.ExactArgs(1),
+	}
+	cmd.Flags().StringVar(&password, "password", "", "new password; generated when empty")
+
+	cmd.RunE = c.run(func(ctx context.Context, b *backend, args []string) error {
+		user, err := findUser(ctx, b, args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := c.confirm("Reset the password of %s (id %d) and end all their sessions?", user.Email, user.ID); err != nil {
+			return err
+		}
+
+		generated := ""
+		if password == "" {
+			if generated, err = generatePassword(); err != nil {
+				return err
+			}
+			password = generated
+		}
+
+		if err := b.users.ResetPassword(ctx, user.ID, password, c.reason, systemActor); err != nil {
+			return err
+		}
+
+		text := fmt.Sprintf("Reset the password of %s (id %d)", user.Email, user.ID)
+		if generated != "" {
+			text += "\nPassword: " + generated
+		}
+		return c.print(passwordResult{User: viewOf(user), Password: generated}, "%s", text)
+	})
+	return cmd
+}
+
+func newSetRoleCmd(c *cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-role <id|email> <user|admin>",
+		Short: "Change an account's role",
+		Args:  cobra.ExactArgs(2),
+	}
+
+	cmd.RunE = c.run(func(ctx context.Context, b *backend, args []string) error {
+		role := domain.Role(strings.ToLower(args[1]))
+		if !role.Valid() {
+			return fmt.Errorf("invalid role %q, want %s or %s", args[1], domain.RoleUser, domain.RoleAdmin)
+		}
+
+		user, err := findUser(ctx, b, args[0])
+		if err != nil {
+			return err
+		}
+		previous := user.Role
+
+		if err := b.users.SetRole(ctx, user.ID, role, c.reason, systemActor); err != nil {
+			return err
+		}
+		user.Role = role
+
+		return c.print(struct {
+			User     userView `json:"user"`
+			Previous string   `json:"previous_role"`
+		}{viewOf(user), string(previous)}, "%s (id %d): role %s -> %s", user.Email, user.ID, previous, role)
+	})
+	return cmd
+}
+
+func newUnbanCmd(c *cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "unban <id|email>",
+		Short: "Lift a ban",
+		Args:  cobra.ExactArgs(1),
+	}
+
+	cmd.RunE = c.run(func(ctx context.Context, b *backend, args []string) error {
+		user, err := findUser(ctx, b, args[0])
+		if err != nil {
+			return err
+		}
+
+		changed := user.IsBanned()
+		if changed {
+			if err := b.users.UnbanUser(ctx, user.ID, c.reason, systemActor); err != nil {
+				return err
+			}
+			user.Status = domain.StatusActive
+		}
+
+		text := fmt.Sprintf("Unbanned %s (id %d)", user.Email, user.ID)
+		if !changed {
+			text = fmt.Sprintf("%s (id %d) is not banned (status %s)", user.Email, user.ID, user.Status)
+		}
+		return c.print(struct {
+			User    userView `json:"user"`
+			Changed bool     `json:"changed"`
+		}{viewOf(user), changed}, "%s", text)
+	})
+	return cmd
+}
+
+type purgeResult struct {
+	Due    []pendingView `json:"due"`
+	Erased int           `json:"erased"`
+	DryRun bool          `json:"dry_run"`
+}
+
+type pendingView struct {
+	UserID      uint      `json:"user_id"`
+	Email       string    `json:"email"`
+	RequestedAt time.Time `json:"requested_at"`
+	EraseAfter  time.Time `json:"erase_after"`
+}
+
+// purgeListPageSize is how many pending deletions purge-deleted reads at once
+const purgeListPageSize = 100
+
+func newPurgeDeletedCmd(c *cli) *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "purge-deleted",
+		Short: "Erase accounts whose deletion grace period has ended",
+		Long: "Erase accounts whose deletion grace period has ended, without waiting for\n" +
+			"the service's erasure job. Personal data is anonymized and cannot be recovered.",
+		Args: cobra.NoArgs,
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list the accounts that would be erased")
+
+	cmd.RunE = c.run(func(ctx context.Context, b *backend, args []string) error {
+		now := time.Now()
+		result := purgeResult{Due: []pendingView{}, DryRun: dryRun}
+		// Oldest requests come first, so the pages stop being due together
+		for page, done := 1, false; !done; page++ {
+			pending, total, err := b.users.ListPendingDeletions(ctx, page, purgeListPageSize)
+			if err != nil {
+				return err
+			}
+			done = int64(page*purgeListPageSize) >= total
+			for _, p := range pending {
+				if p.EraseAfter.After(now) {
+					done = true
+					break
+				}
+				result.Due = append(result.Due, pendingView{
+					UserID:      p.UserID,
+					Email:       p.Email,
+					RequestedAt: p.RequestedAt,
+					EraseAfter:  p.EraseAfter,
+				})
+			}
+		}
+
+		if len(result.Due) == 0 {
+			return c.print(result, "No accounts are due for erasure")
+		}
+		if !c.jsonOutput {
+			c.printDue(result.Due)
+		}
+		if dryRun {
+			return c.print(result, "Dry run: %d accounts would be erased", len(result.Due))
+		}
+
+		if err := c.confirm("Erase %d accounts? This cannot be undone.", len(result.Due)); err != nil {
+			return err
+		}
+
+		// Each pass erases one batch; stop once a pass finds nothing due
+		for {
+			erased, err := b.users.ProcessDueDeletions(ctx)
+			result.Erased += erased
+			if err != nil {
+				return fmt.Errorf("erased %d accounts before failing: %w", result.Erased, err)
+			}
+			if erased == 0 {
+				break
+			}
+		}
+
+		return c.print(result, "Erased %d accounts", result.Erased)
+	})
+	return cmd
+}
+
+func (c *cli) printDue(due []pendingView) {
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEMAIL\tREQUESTED\tERASE AFTER")
+	for _, p := range due {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", p.UserID, p.Email,
+			p.RequestedAt.Format(time.RFC3339), p.EraseAfter.Format(time.RFC3339))
+	}
+	tw.Flush()
+}
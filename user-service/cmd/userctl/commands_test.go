@@ -0,0 +1,263 @@
+// cmd/userctl/commands_test.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type harness struct {
+	repo     *testsupport.UserRepository
+	users    *application.UserService
+	cfg      *config.Config
+	connects int
+}
+
+func newHarness() *harness {
+	repo := testsupport.NewUserRepository()
+	return &harness{
+		repo:  repo,
+		users: application.NewUserService(repo, testsupport.NewTxManager(repo), nil),
+		cfg:   &config.Config{Environment: "development"},
+	}
+}
+
+// run executes userctl with args, feeding stdin to confirmation prompts
+func (h *harness) run(t *testing.T, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	c := &cli{
+		loadConfig: func() *config.Config { return h.cfg },
+		connect: func(ctx context.Context, cfg *config.Config) (*backend, error) {
+			h.connects++
+			return &backend{users: h.users, repo: h.repo, close: h.users.Wait}, nil
+		},
+		in:     bufio.NewReader(strings.NewReader(stdin)),
+		out:    &out,
+		errOut: &errOut,
+	}
+
+	root := newRootCmd(c)
+	root.SetArgs(args)
+	err := root.ExecuteContext(context.Background())
+	return out.String(), err
+}
+
+func passwordMatches(repo *testsupport.UserRepository, id uint, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(repo.PasswordHash(id)), []byte(password)) == nil
+}
+
+func TestCreateAdmin(t *testing.T) {
+	h := newHarness()
+
+	out, err := h.run(t, "", "create-admin", "--email", "Ops@Example.com", "--username", "admin", "--json")
+	if err != nil {
+		t.Fatalf("create-admin failed: %v", err)
+	}
+
+	var result passwordResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out, err)
+	}
+	if result.User.Email != "ops@example.com" || result.User.Role != "admin" || result.Password == "" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	stored, ok := h.repo.User(result.User.ID)
+	if !ok || !stored.IsAdmin() {
+		t.Fatalf("expected an admin to be stored, got %+v", stored)
+	}
+	if !passwordMatches(h.repo, stored.ID, result.Password) {
+		t.Error("printed password does not match the stored hash")
+	}
+
+	// Same email again is rejected by the service
+	if _, err := h.run(t, "", "create-admin", "--email", "ops@example.com", "--username", "ops2"); err == nil {
+		t.Error("expected duplicate email to fail")
+	}
+}
+
+func TestCreateAdmin_RequiresFlags(t *testing.T) {
+	h := newHarness()
+	if _, err := h.run(t, "", "create-admin", "--email", "ops@example.com"); err == nil {
+		t.Fatal("expected missing --username to fail")
+	}
+	if h.connects != 0 {
+		t.Error("expected no connection for invalid usage")
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	h := newHarness()
+	user := h.repo.AddUser("alice@example.com", "secret123")
+
+	// Declining the prompt changes nothing
+	if _, err := h.run(t, "n\n", "reset-password", "alice@example.com", "--password", "N3w-password"); !errors.Is(err, errAborted) {
+		t.Fatalf("expected errAborted, got %v", err)
+	}
+	if !passwordMatches(h.repo, user.ID, "secret123") {
+		t.Fatal("password changed despite declining")
+	}
+
+	if _, err := h.run(t, "yes\n", "reset-password", "alice@example.com", "--password", "N3w-password"); err != nil {
+		t.Fatalf("reset-password failed: %v", err)
+	}
+	if !passwordMatches(h.repo, user.ID, "N3w-password") {
+		t.Error("expected the new password to be stored")
+	}
+
+	// --yes skips the prompt; a generated password is printed
+	out, err := h.run(t, "", "reset-password", "1", "--yes", "--json")
+	if err != nil {
+		t.Fatalf("reset-password by ID failed: %v", err)
+	}
+	var result passwordResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out, err)
+	}
+	if result.Password == "" || !passwordMatches(h.repo, user.ID, result.Password) {
+		t.Errorf("expected the generated password to be stored, got %+v", result)
+	}
+}
+
+func TestResetPassword_UnknownUser(t *testing.T) {
+	h := newHarness()
+	_, err := h.run(t, "", "reset-password", "ghost@example.com", "--yes")
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	h := newHarness()
+	user := h.repo.AddUser("alice@example.com", "secret123")
+
+	out, err := h.run(t, "", "set-role", "alice@example.com", "admin")
+	if err != nil {
+		t.Fatalf("set-role failed: %v", err)
+	}
+	if !strings.Contains(out, "role user -> admin") {
+		t.Errorf("unexpected output %q", out)
+	}
+	stored, _ := h.repo.User(user.ID)
+	if stored.Role != domain.RoleAdmin {
+		t.Errorf("expected role admin, got %q", stored.Role)
+	}
+
+	if _, err := h.run(t, "", "set-role", "alice@example.com", "superuser"); err == nil {
+		t.Error("expected an invalid role to fail")
+	}
+}
+
+func TestUnban(t *testing.T) {
+	h := newHarness()
+	user := h.repo.AddUser("alice@example.com", "secret123")
+	if err := h.users.BanUser(context.Background(), user.ID, "spam", 1); err != nil {
+		t.Fatalf("ban failed: %v", err)
+	}
+
+	out, err := h.run(t, "", "unban", "alice@example.com", "--json")
+	if err != nil {
+		t.Fatalf("unban failed: %v", err)
+	}
+	var result struct {
+		Changed bool `json:"changed"`
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil || !result.Changed {
+		t.Errorf("unexpected output %q (%v)", out, err)
+	}
+	stored, _ := h.repo.User(user.ID)
+	if stored.IsBanned() {
+		t.Error("expected the ban to be lifted")
+	}
+
+	// Unbanning an active user is a no-op
+	out, err = h.run(t, "", "unban", "alice@example.com")
+	if err != nil || !strings.Contains(out, "is not banned") {
+		t.Errorf("expected a no-op, got %q (%v)", out, err)
+	}
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	h := newHarness()
+	ctx := context.Background()
+
+	due := h.repo.AddUser("due@example.com", "secret123")
+	requested := time.Now().Add(-application.DefaultDeletionGracePeriod - time.Hour)
+	due.Status = domain.StatusPendingDeletion
+	due.DeletionRequestedAt = &requested
+	h.repo.Put(due)
+
+	recent := h.repo.AddUser("recent@example.com", "secret123")
+	if err := h.users.DeleteUser(ctx, recent.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	out, err := h.run(t, "", "purge-deleted", "--dry-run")
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if !strings.Contains(out, "due@example.com") || strings.Contains(out, "recent@example.com") {
+		t.Errorf("unexpected dry run output %q", out)
+	}
+	if _, ok := h.repo.User(due.ID); !ok {
+		t.Fatal("dry run erased an account")
+	}
+
+	if _, err := h.run(t, "\n", "purge-deleted"); !errors.Is(err, errAborted) {
+		t.Fatalf("expected errAborted without confirmation, got %v", err)
+	}
+
+	out, err = h.run(t, "", "purge-deleted", "--yes", "--json")
+	if err != nil {
+		t.Fatalf("purge failed: %v", err)
+	}
+	var result purgeResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out, err)
+	}
+	if result.Erased != 1 || len(result.Due) != 1 || result.Due[0].UserID != due.ID {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if erased, ok := h.repo.DeletedUser(due.ID); !ok || erased.Status != domain.StatusErased {
+		t.Error("expected the due account to be erased")
+	}
+	if stored, ok := h.repo.User(recent.ID); !ok || !stored.IsPendingDeletion() {
+		t.Error("expected the account inside its grace period to be kept")
+	}
+}
+
+func TestRefusesProduction(t *testing.T) {
+	h := newHarness()
+	h.cfg.Environment = "production"
+	user := h.repo.AddUser("alice@example.com", "secret123")
+
+	_, err := h.run(t, "", "set-role", "alice@example.com", "admin")
+	if err == nil || !strings.Contains(err.Error(), "--yes-production") {
+		t.Fatalf("expected production to be refused, got %v", err)
+	}
+	if h.connects != 0 {
+		t.Error("expected no connection to a refused production database")
+	}
+
+	if _, err := h.run(t, "", "set-role", "alice@example.com", "admin", "--yes-production"); err != nil {
+		t.Fatalf("expected --yes-production to allow the command, got %v", err)
+	}
+	stored, _ := h.repo.User(user.ID)
+	if !stored.IsAdmin() {
+		t.Error("expected role to change with --yes-production")
+	}
+}
@@ -0,0 +1,41 @@
+// Command userctl runs operational tasks directly against the user database:
+// creating the first admin, resetting passwords, changing roles, lifting
+// bans and purging accounts whose deletion grace period has ended.
+//
+//	go run ./cmd/userctl create-admin --email ops@example.com --username ops
+//	go run ./cmd/userctl reset-password alice@example.com
+//	go run ./cmd/userctl set-role 42 admin --reason "joined support"
+//	go run ./cmd/userctl unban 42 --reason "appeal accepted"
+//	go run ./cmd/userctl purge-deleted --dry-run
+//
+// It reads the same environment as the service. Against a production
+// environment every command refuses to run unless --yes-production is set.
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"user-service/internal/config"
+)
+
+func main() {
+	c := &cli{
+		loadConfig: config.Load,
+		connect:    connect,
+		in:         bufio.NewReader(os.Stdin),
+		out:        os.Stdout,
+		errOut:     os.Stderr,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := newRootCmd(c).ExecuteContext(ctx)
+	stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "userctl:", err)
+		os.Exit(1)
+	}
+}
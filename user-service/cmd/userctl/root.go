@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/normalize"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultReason is recorded in the audit log when --reason isn't given
+const defaultReason = "userctl"
+
+// cli holds the global flags and the seams tests replace
+type cli struct {
+	loadConfig func() *config.Config
+	connect    func(ctx context.Context, cfg *config.Config) (*backend, error)
+
+	in     *bufio.Reader
+	out    io.Writer
+	errOut io.Writer
+
+	jsonOutput    bool
+	assumeYes     bool
+	yesProduction bool
+	reason        string
+}
+
+var errAborted = errors.New("aborted")
+
+func newRootCmd(c *cli) *cobra.Command {
+	root := &cobra.Command{
+		Use:           "userctl",
+		Short:         "Operational tasks against the user database",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	root.SetOut(c.out)
+	root.SetErr(c.errOut)
+
+	flags := root.PersistentFlags()
+	flags.BoolVar(&c.jsonOutput, "json", false, "print results as JSON")
+	flags.BoolVarP(&c.assumeYes, "yes", "y", false, "skip confirmation prompts")
+	flags.BoolVar(&c.yesProduction, "yes-production", false, "allow running against a production environment")
+	flags.StringVar(&c.reason, "reason", defaultReason, "reason recorded in the audit log")
+
+	root.AddCommand(
+		newCreateAdminCmd(c),
+		newResetPasswordCmd(c),
+		newSetRoleCmd(c),
+		newUnbanCmd(c),
+		newPurgeDeletedCmd(c),
+	)
+	return root
+}
+
+// run loads the config, refuses production unless told otherwise, and
+// hands fn a connected backend that is closed once fn returns
+func (c *cli) run(fn func(ctx context.Context, b *backend, args []string) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		cfg := c.loadConfig()
+		if cfg.IsProduction() && !c.yesProduction {
+			return fmt.Errorf("refusing to run against %s (database %s on %s); pass --yes-production to continue",
+				cfg.Environment, cfg.DBName, cfg.DBHost)
+		}
+
+		b, err := c.connect(cmd.Context(), cfg)
+		if err != nil {
+			return err
+		}
+		defer b.close()
+
+		for _, warning := range b.warnings {
+			fmt.Fprintln(c.errOut, "warning:", warning)
+		}
+		return fn(cmd.Context(), b, args)
+	}
+}
+
+// confirm asks before a destructive action; --yes answers for the operator
+func (c *cli) confirm(format string, a ...interface{}) error {
+	if c.assumeYes {
+		return nil
+	}
+	fmt.Fprintf(c.errOut, format+" [y/N]: ", a...)
+	line, _ := c.in.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return nil
+	}
+	return errAborted
+}
+
+// print writes v as JSON with --json, otherwise the formatted text
+func (c *cli) print(v interface{}, format string, a ...interface{}) error {
+	if c.jsonOutput {
+		enc := json.NewEncoder(c.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	_, err := fmt.Fprintf(c.out, format+"\n", a...)
+	return err
+}
+
+// userView is the JSON shape of an account in command output
+type userView struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Role     string `json:"role"`
+	Status   string `json:"status"`
+}
+
+func viewOf(user *domain.User) userView {
+	return userView{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     string(user.Role),
+		Status:   string(user.Status),
+	}
+}
+
+// findUser resolves a numeric ID or an email address
+func findUser(ctx context.Context, b *backend, ref string) (*domain.User, error) {
+	var user *domain.User
+	var err error
+	if id, parseErr := strconv.ParseUint(ref, 10, 64); parseErr == nil {
+		user, err = b.repo.GetByID(ctx, uint(id))
+	} else {
+		user, err = b.repo.GetByEmail(ctx, normalize.Email(ref))
+	}
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil, fmt.Errorf("user %q not found", ref)
+	}
+	return user, err
+}
+
+const passwordAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// generatePassword returns a random password that satisfies the
+// registration policy
+func generatePassword() (string, error) {
+	for {
+		buf := make([]byte, 20)
+		for i := range buf {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(passwordAlphabet))))
+			if err != nil {
+				return "", fmt.Errorf("failed to generate password: %w", err)
+			}
+			buf[i] = passwordAlphabet[n.Int64()]
+		}
+		password := string(buf)
+		if strings.ContainsAny(password, "23456789") && strings.IndexFunc(password, isLetter) >= 0 {
+			return password, nil
+		}
+	}
+}
+
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
@@ -0,0 +1,610 @@
+// Package app wires repositories, services, handlers and middleware into a
+// single http.Handler so main and the end-to-end tests share one setup.
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/config"
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/infrastructure/breach"
+	"user-service/internal/infrastructure/maildomain"
+	"user-service/internal/infrastructure/metrics"
+	"user-service/internal/infrastructure/notify"
+	"user-service/internal/infrastructure/postgres"
+	"user-service/internal/infrastructure/redis"
+	"user-service/internal/infrastructure/storage"
+	usergrpc "user-service/internal/interfaces/grpc"
+	userhttp "user-service/internal/interfaces/http/handlers"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/openapi"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/jobs"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
+)
+
+// Deps are the external resources the application is built on. The schema
+// must already be migrated.
+type Deps struct {
+	DB *gorm.DB
+	// Redis is optional; without it caching, session revocation and
+	// rate limiting fall back to in-process implementations
+	Redis *redis.RedisClient
+	// Registerer and Gatherer back the service metrics and /metrics.
+	// They default to the global Prometheus registry.
+	Registerer prometheus.Registerer
+	Gatherer   prometheus.Gatherer
+	// ResetNotifier delivers password reset tokens, which need Redis. It
+	// defaults to logging them, with the token outside production.
+	ResetNotifier application.PasswordResetNotifier
+	// EmailChangeNotifier delivers email change tokens to the new address.
+	// Like ResetNotifier it needs Redis, and defaults to logging them.
+	EmailChangeNotifier application.EmailChangeNotifier
+	// RequestLogger gets one structured line per HTTP request. It
+	// defaults to JSON on stdout.
+	RequestLogger *slog.Logger
+}
+
+// rateLimiterCleanupJob evicts idle visitors from the in-memory rate limiters
+const rateLimiterCleanupJob = "rate_limiter_cleanup"
+
+// endpointSwitchRefreshJob pulls the kill switches set on other instances
+const endpointSwitchRefreshJob = "endpoint_switch_refresh"
+
+// refreshTokenCleanupJob deletes expired refresh tokens
+const refreshTokenCleanupJob = "refresh_token_cleanup"
+
+// sessionCleanupJob deletes expired sessions
+const sessionCleanupJob = "session_cleanup"
+
+// DBConfig maps the database settings in cfg onto a connection config
+func DBConfig(cfg *config.Config) *postgres.DBConfig {
+	return &postgres.DBConfig{
+		Host:            cfg.DBHost,
+		Port:            cfg.DBPort,
+		User:            cfg.DBUser,
+		Password:        cfg.DBPassword,
+		DBName:          cfg.DBName,
+		SSLMode:         cfg.DBSSLMode,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		ConnMaxLifeTime: cfg.DBConnMaxLifeTime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+		RetryAttempts:   cfg.DBRetryAttempts,
+		RetryDelay:      cfg.DBRetryDelay,
+	}
+}
+
+// Components is a wired application. Handler serves every route with the
+// global middleware chain applied; Close drains the background jobs and
+// workers.
+type Components struct {
+	Handler http.Handler
+	// GRPCServer serves the internal user API; nil when cfg.GRPCPort is
+	// empty. Its lifecycle belongs to the caller.
+	GRPCServer  *grpc.Server
+	UserService *application.UserService
+	JWTManager  *auth.JWTManager
+	// Storage keeps files; nil unless cfg.StorageBackend is set
+	Storage storage.Storage
+
+	jobs           *jobs.Scheduler
+	lastLogin      *application.LastLoginRecorder
+	tokenUsage     *application.LastLoginRecorder
+	rateLimitModes *middleware.RateLimitModes
+	// patterns are the mounted routes
+	patterns []string
+}
+
+// Build creates the services and HTTP stack from cfg and deps and starts
+// the background workers
+func Build(cfg *config.Config, deps Deps) (*Components, error) {
+	if deps.Registerer == nil {
+		deps.Registerer = prometheus.DefaultRegisterer
+	}
+	if deps.Gatherer == nil {
+		deps.Gatherer = prometheus.DefaultGatherer
+	}
+	if deps.ResetNotifier == nil {
+		deps.ResetNotifier = notify.NewLogNotifier(!cfg.IsProduction())
+	}
+	if deps.EmailChangeNotifier == nil {
+		deps.EmailChangeNotifier = notify.NewLogNotifier(!cfg.IsProduction())
+	}
+	if deps.RequestLogger == nil {
+		deps.RequestLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	}
+	db, redisClient := deps.DB, deps.Redis
+
+	// Initialize cache, session revocation and event publishing
+	var userCache application.UserCache
+	var serviceOpts []application.Option
+	var authOpts []middleware.AuthOption
+	var jobLocker jobs.Locker
+	var statsCache application.StatsCache
+	// Revoking a user's sessions also revokes their refresh tokens and
+	// personal access tokens
+	refreshTokens := postgres.NewRefreshTokenRepository(db)
+	sessionRepo := postgres.NewSessionRepository(db)
+	accessTokenRepo := postgres.NewAccessTokenRepository(db)
+	sessionRevokers := application.SessionRevokers{refreshTokens, sessionRepo, accessTokenRepo}
+	var sessionStore *redis.SessionStore
+	if redisClient != nil {
+		redisUserCache := redis.NewUserCache(redisClient, cfg.CacheUserTTL)
+		userCache = redisUserCache
+		statsCache = redis.NewStatsCache(redisClient)
+
+		// Shared with AuthMiddleware so revocations apply to existing tokens
+		sessionStore = redis.NewSessionStore(redisClient, cfg.MaxTokenTTL())
+		blocklist := redis.NewUserBlocklist(redisClient, cfg.BlocklistLocalTTL)
+
+		sessionRevokers = append(sessionRevokers, sessionStore)
+		serviceOpts = append(serviceOpts,
+			application.WithEventPublisher(redis.NewEventPublisher(redisClient)),
+			application.WithUserBlocklist(blocklist),
+			application.WithDeviceStore(redis.NewDeviceStore(redisClient, cfg.KnownDeviceTTL)),
+			// Reads just after a mutation skip replicas that may lag behind it
+			application.WithWriteMarker(redisUserCache),
+			// The auth check reads token versions from Redis, not Postgres
+			application.WithTokenVersionCache(redisUserCache),
+			application.WithPasswordResets(redis.NewPasswordResetStore(redisClient), deps.ResetNotifier),
+			application.WithEmailChanges(redis.NewEmailChangeStore(redisClient), deps.EmailChangeNotifier),
+			// The auth check reads revoked sessions from Redis too
+			application.WithRevokedSessionCache(sessionStore),
+		)
+		authOpts = append(authOpts,
+			middleware.WithRevocationCheck(sessionStore),
+			middleware.WithTokenRevocationCheck(sessionStore),
+			middleware.WithBlocklistCheck(blocklist),
+		)
+
+		// Singleton jobs, like the erasure pass, run on one replica at a time
+		jobLocker = redis.NewDistributedLock(redisClient)
+	}
+	serviceOpts = append(serviceOpts,
+		application.WithSessionRevoker(sessionRevokers),
+		// A session outlives its refresh token, and its longest access token
+		application.WithSessions(sessionRepo, max(cfg.JWTRefreshExpire, cfg.MaxTokenTTL())),
+		application.WithAuditLogger(postgres.NewAuditRepository(db)),
+		application.WithLoginAttemptStore(postgres.NewLoginAttemptRepository(db)),
+		application.WithInviteRepository(postgres.NewInviteRepository(db)),
+		application.WithRecoveryCodeRepository(postgres.NewRecoveryCodeRepository(db)),
+		application.WithBulkJobRepository(postgres.NewBulkJobRepository(db)),
+		application.WithNoticeRepository(postgres.NewNoticeRepository(db)),
+		application.WithTermsVersion(cfg.TermsVersion, cfg.TermsUpdatedAt),
+	)
+	accessTokenUsage := application.NewAccessTokenUsageRecorder(accessTokenRepo, cfg.LastLoginBufferSize)
+	serviceOpts = append(serviceOpts,
+		application.WithAccessTokenRepository(accessTokenRepo),
+		application.WithAccessTokenUsageRecorder(accessTokenUsage),
+	)
+	fileStorage, fileSigner, err := newStorage(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up file storage: %w", err)
+	}
+	if fileStorage != nil {
+		// Bulk job reports are downloaded through signed links
+		serviceOpts = append(serviceOpts, application.WithReportStore(storageReports{store: fileStorage}))
+	}
+	if cfg.RegistrationMode != "" {
+		mode := application.RegistrationMode(cfg.RegistrationMode)
+		if !mode.Valid() {
+			return nil, fmt.Errorf("invalid registration mode %q", cfg.RegistrationMode)
+		}
+		serviceOpts = append(serviceOpts, application.WithRegistrationMode(mode))
+		log.Printf("Registration is %s", mode)
+	}
+	if cfg.LoginHookTimeout > 0 {
+		serviceOpts = append(serviceOpts, application.WithLoginHookTimeout(cfg.LoginHookTimeout))
+	}
+	if cfg.BreachedPasswordsFile != "" {
+		breachHook, err := breach.LoadPasswordHook(cfg.BreachedPasswordsFile, cfg.BreachedPasswordsFPRate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load breached password corpus: %w", err)
+		}
+		serviceOpts = append(serviceOpts, application.WithLoginHooks(breachHook))
+		log.Println("Breached password check enabled")
+	}
+	if disabled := cfg.DisabledSecurityAlerts(); len(disabled) > 0 {
+		var alerts []application.SecurityAlert
+		for _, name := range disabled {
+			alerts = append(alerts, application.SecurityAlert(name))
+		}
+		serviceOpts = append(serviceOpts, application.WithSecurityAlertsDisabled(alerts...))
+	}
+	if cfg.DeletionGracePeriod > 0 {
+		serviceOpts = append(serviceOpts, application.WithDeletionGracePeriod(cfg.DeletionGracePeriod))
+	}
+	serviceOpts = append(serviceOpts, application.WithDeletedAccountHintWindow(cfg.DeletedAccountHintWindow))
+	if cfg.PurgeRetention > 0 {
+		serviceOpts = append(serviceOpts, application.WithPurgeRetention(cfg.PurgeRetention))
+	}
+	if cfg.MailDomainCheck {
+		var checkerOpts []maildomain.Option
+		if redisClient != nil {
+			checkerOpts = append(checkerOpts, maildomain.WithCache(redis.NewMailDomainCache(redisClient)))
+		}
+		serviceOpts = append(serviceOpts, application.WithMailDomainChecker(maildomain.NewChecker(nil, checkerOpts...)))
+		log.Println("Mail domain check enabled")
+	}
+	if cfg.SnapshotSigningSecret != "" {
+		serviceOpts = append(serviceOpts, application.WithSnapshotSecret([]byte(cfg.SnapshotSigningSecret)))
+	}
+	var grpcOpts []grpc.ServerOption
+	if cfg.GRPCPort != "" && (cfg.GRPCTLSCertFile != "" || cfg.GRPCTLSKeyFile != "" || cfg.GRPCClientCAFile != "") {
+		creds, err := usergrpc.ServerCredentials(cfg.GRPCTLSCertFile, cfg.GRPCTLSKeyFile, cfg.GRPCClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load gRPC TLS credentials: %w", err)
+		}
+		grpcOpts = append(grpcOpts, grpc.Creds(creds))
+	}
+
+	modes, err := parseRateLimitModes(cfg.RateLimitModes)
+	if err != nil {
+		return nil, err
+	}
+	rateLimitModes := middleware.NewRateLimitModes(modes)
+	failureModes, err := parseFailureModes(cfg.RateLimitFailureModes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache hits and rate-limit rejections feed /metrics and the admin overview
+	cacheMetrics := metrics.NewCacheMetrics(deps.Registerer)
+	rateLimitMetrics := metrics.NewRateLimitMetrics(deps.Registerer)
+	serviceOpts = append(serviceOpts, application.WithCacheObserver(cacheMetrics))
+
+	// Initialize repositories and services
+	userRepo := postgres.NewUserRepository(db)
+	txManager := postgres.NewTransactionManager(db)
+	lastLoginRecorder := application.NewLastLoginRecorder(userRepo, cfg.LastLoginBufferSize)
+	serviceOpts = append(serviceOpts,
+		application.WithLastLoginRecorder(lastLoginRecorder),
+		// Upgrade old password hashes as users log in
+		application.WithLoginHooks(application.NewRehashHook(userRepo, bcrypt.DefaultCost)),
+	)
+	userService := application.NewUserService(userRepo, txManager, userCache, serviceOpts...)
+	// Routes that opt in take personal access tokens, checked by the
+	// service, which also knows which sessions LogoutAll ended
+	authOpts = append(authOpts,
+		middleware.WithAccessTokens(userService),
+		middleware.WithTokenVersionCheck(userService),
+		middleware.WithSessionCheck(userService),
+	)
+
+	// Background jobs, started once everything is wired
+	scheduler := jobs.New(
+		jobs.WithLocker(jobLocker),
+		jobs.WithObserver(metrics.NewJobMetrics(deps.Registerer)),
+	)
+	scheduler.Register(application.LastLoginJobName, cfg.LastLoginFlushInterval, lastLoginRecorder.Flush)
+	scheduler.Register(application.AccessTokenUsageJobName, cfg.LastLoginFlushInterval, accessTokenUsage.Flush)
+	// Erase accounts whose deletion grace period has ended
+	scheduler.Register(application.ErasureJobName, cfg.ErasureInterval, userService.RunErasurePass,
+		jobs.Singleton(),
+		jobs.WithTimeout(application.ErasureJobTimeout),
+	)
+	scheduler.Register(refreshTokenCleanupJob, time.Hour, func(ctx context.Context) error {
+		deleted, err := refreshTokens.DeleteExpired(ctx, time.Now())
+		if deleted > 0 {
+			log.Printf("Deleted %d expired refresh tokens", deleted)
+		}
+		return err
+	})
+	scheduler.Register(sessionCleanupJob, time.Hour, func(ctx context.Context) error {
+		deleted, err := sessionRepo.DeleteExpired(ctx, time.Now())
+		if deleted > 0 {
+			log.Printf("Deleted %d expired sessions", deleted)
+		}
+		return err
+	})
+	// Work through queued bulk admin actions
+	scheduler.Register(application.BulkJobName, application.BulkJobInterval, userService.RunBulkJobs,
+		jobs.Singleton(),
+		jobs.WithTimeout(application.BulkJobTimeout),
+	)
+
+	// Initialize JWT manager, reporting token and auth outcomes
+	authMetrics := metrics.NewAuthMetrics(deps.Registerer)
+	jwtManager, err := newJWTManager(cfg,
+		auth.WithObserver(authMetrics),
+		auth.WithRefreshTokens(refreshTokens, cfg.JWTRefreshExpire),
+	)
+	if err != nil {
+		return nil, err
+	}
+	authOpts = append(authOpts, middleware.WithAuthObserver(authMetrics))
+
+	// Wrap the service with per-operation metrics
+	serviceMetrics := metrics.NewServiceMetrics(deps.Registerer)
+	instrumentedService := application.NewInstrumentedUserService(userService, serviceMetrics)
+
+	// Dashboard statistics
+	statsRepo := postgres.NewStatsRepository(db)
+	statsService := application.NewUserStatsService(statsRepo, statsCache)
+
+	// Initialize handlers
+	var handlerOpts []userhttp.UserHandlerOption
+	if redisClient != nil {
+		// Double-clicked signups share the first one's response
+		handlerOpts = append(handlerOpts, userhttp.WithRegisterDeduplicator(
+			middleware.NewRedisDeduplicator(redisClient, "register", middleware.DefaultDedupWindow),
+		), userhttp.WithTokenRevoker(sessionStore))
+	}
+	handlerOpts = append(handlerOpts, userhttp.WithRememberMe(cfg.JWTRememberMeExpire))
+	userHandler := userhttp.NewUserHandler(instrumentedService, jwtManager, handlerOpts...)
+	statsHandler := userhttp.NewStatsHandler(statsService)
+	jobsHandler := userhttp.NewJobsHandler(scheduler)
+	overviewSections := append(overviewSources{
+		counts:     statsRepo,
+		stats:      statsService,
+		rateLimits: rateLimitMetrics,
+		cache:      cacheMetrics,
+		db:         db,
+	}.sections(), jobsHandler.OverviewSection())
+	var overviewOpts []userhttp.OverviewOption
+	if redisClient != nil {
+		overviewOpts = append(overviewOpts, userhttp.WithOverviewCache(redisClient, userhttp.DefaultOverviewCacheTTL))
+	}
+	overviewHandler := userhttp.NewOverviewHandler(overviewSections, overviewOpts...)
+
+	// Per-route kill switches for incidents
+	endpointSwitches := middleware.NewEndpointSwitches(redisClient)
+	if redisClient != nil {
+		scheduler.Register(endpointSwitchRefreshJob, middleware.DefaultEndpointSwitchRefresh, endpointSwitches.Refresh)
+	}
+
+	mux, limiters := SetupRoutes(Routes{
+		Users:        userHandler,
+		Stats:        statsHandler,
+		Jobs:         jobsHandler,
+		Overview:     overviewHandler,
+		JWTManager:   jwtManager,
+		AuthOpts:     authOpts,
+		DB:           db,
+		Redis:        redisClient,
+		Gatherer:     deps.Gatherer,
+		RateLimits:   rateLimitMetrics,
+		Modes:        rateLimitModes,
+		FailureModes: failureModes,
+		Switches:     endpointSwitches,
+		Files:        fileStorage,
+		FileSigner:   fileSigner,
+	}, cfg)
+
+	handler, globalLimiter := applyGlobalMiddleware(middleware.EndpointKillSwitch(mux, endpointSwitches)(mux), mux, redisClient, cfg, deps.RequestLogger,
+		middleware.WithRejectionObserver(rateLimitMetrics, "global"),
+		middleware.WithModes(rateLimitModes, "global"),
+		middleware.WithFailureMode(failureMode(failureModes, "global")),
+	)
+	if globalLimiter != nil {
+		limiters = append(limiters, globalLimiter)
+	}
+	// Until older mobile clients parse JSON errors they get plain text;
+	// the counter shows when none are left
+	errorMetrics := metrics.NewErrorFormatMetrics(deps.Registerer)
+	if cfg.ErrorFormatCompat {
+		handler = respond.ErrorCompat(errorMetrics)(handler)
+	}
+	// Outermost, so a panic anywhere in the chain still gets an answer
+	handler = middleware.Recover(metrics.NewPanicMetrics(deps.Registerer))(handler)
+	if len(limiters) > 0 {
+		scheduler.Register(rateLimiterCleanupJob, time.Minute, func(ctx context.Context) error {
+			for _, limiter := range limiters {
+				limiter.EvictIdle()
+			}
+			return nil
+		})
+	}
+
+	// Internal gRPC API, read straight from the cached service
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != "" {
+		grpcServer = usergrpc.NewServer(
+			userService,
+			usergrpc.NewAuthenticator(cfg.InternalAPIKeys),
+			metrics.NewGRPCMetrics(deps.Registerer),
+			grpcOpts...,
+		)
+	}
+
+	scheduler.Start()
+
+	return &Components{
+		Handler:        handler,
+		GRPCServer:     grpcServer,
+		UserService:    userService,
+		JWTManager:     jwtManager,
+		Storage:        fileStorage,
+		jobs:           scheduler,
+		lastLogin:      lastLoginRecorder,
+		tokenUsage:     accessTokenUsage,
+		rateLimitModes: rateLimitModes,
+		patterns:       mux.Patterns(),
+	}, nil
+}
+
+// SetRateLimitModes switches limiter scopes between enforce, warn and off
+// while serving. Scopes left out go back to enforcing.
+func (c *Components) SetRateLimitModes(modes map[string]string) error {
+	parsed, err := parseRateLimitModes(modes)
+	if err != nil {
+		return err
+	}
+	c.rateLimitModes.Set(parsed)
+	return nil
+}
+
+func parseRateLimitModes(modes map[string]string) (map[string]middleware.RateLimitMode, error) {
+	parsed := make(map[string]middleware.RateLimitMode, len(modes))
+	for scope, value := range modes {
+		mode, err := middleware.ParseRateLimitMode(value)
+		if err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_MODES %s: %w", scope, err)
+		}
+		parsed[scope] = mode
+	}
+	return parsed, nil
+}
+
+func parseFailureModes(modes map[string]string) (map[string]middleware.FailureMode, error) {
+	parsed := make(map[string]middleware.FailureMode, len(modes))
+	for scope, value := range modes {
+		mode, err := middleware.ParseFailureMode(value)
+		if err != nil {
+			return nil, fmt.Errorf("RATE_LIMIT_FAILURE_MODES %s: %w", scope, err)
+		}
+		parsed[scope] = mode
+	}
+	return parsed, nil
+}
+
+// failureMode is what scope's Redis limiter does when Redis fails it.
+// Unlisted scopes keep limiting, in memory.
+func failureMode(modes map[string]middleware.FailureMode, scope string) middleware.FailureMode {
+	if mode, ok := modes[scope]; ok {
+		return mode
+	}
+	return middleware.FailoverLocal
+}
+
+// Close stops the background jobs, waiting for runs in progress, then
+// waits for in-flight post-commit steps. Pending last-login and access
+// token usage updates are flushed, so call it before closing the database.
+func (c *Components) Close(ctx context.Context) error {
+	var firstErr error
+	if err := c.jobs.Close(ctx); err != nil {
+		log.Printf("Background jobs still running at shutdown: %v", err)
+		firstErr = err
+	}
+	// After the scheduler, so the final flush doesn't race a scheduled one
+	if err := c.lastLogin.Close(ctx); err != nil {
+		log.Printf("Failed to drain last login updates: %v", err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if err := c.tokenUsage.Close(ctx); err != nil {
+		log.Printf("Failed to drain access token usage updates: %v", err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	c.UserService.Wait()
+	return firstErr
+}
+
+// applyGlobalMiddleware wraps the router with client IP resolution, path
+// normalization, the per-IP rate limit, the body size limit, CORS,
+// tracing, security headers and, when logger is set, request logging.
+// limiter is the in-memory limiter used when Redis isn't, or nil. opts
+// configure whichever limiter is used.
+func applyGlobalMiddleware(mux http.Handler, routes middleware.RouteMatcher, redisClient *redis.RedisClient, cfg *config.Config, logger *slog.Logger, opts ...middleware.RateLimitOption) (handler http.Handler, limiter *middleware.RateLimiter) {
+	handler = mux
+
+	// Apply global rate limiting
+	if redisClient != nil {
+		// Use Redis-based rate limiting for distributed systems
+		globalRateLimiter := middleware.NewRedisRateLimiter(
+			redisClient,
+			int(cfg.RateLimitGlobal),
+			time.Minute,
+			opts...,
+		)
+		handler = middleware.RedisRateLimitMiddleware(globalRateLimiter)(handler)
+		log.Println("Using Redis-based rate limiting")
+	} else {
+		// Fallback to in-memory rate limiting
+		limiter = middleware.NewRateLimiter(
+			cfg.RateLimitGlobal,
+			cfg.RateLimitGlobalBurst,
+			30*time.Minute,
+			opts...,
+		)
+		handler = middleware.RateLimitMiddleware(limiter)(handler)
+		log.Println("Using in-memory rate limiting")
+	}
+
+	// Bodies are capped before anything reads them; routes may set their
+	// own limit
+	handler = middleware.MaxBodyBytes(cfg.MaxBodySize)(handler)
+
+	// A deadline for the handler and the limiter's Redis calls; routes may
+	// set their own
+	if cfg.RequestTimeout > 0 {
+		handler = middleware.Timeout(cfg.RequestTimeout)(handler)
+	}
+
+	// Outside the rate limits, which a preflight mustn't use up
+	handler = middleware.CORS(middleware.CORSConfig{
+		AllowedOrigins: cfg.CORSAllowedOrigins,
+		AllowedMethods: cfg.CORSAllowedMethods,
+		AllowedHeaders: cfg.CORSAllowedHeaders,
+		ExposedHeaders: []string{respond.RequestIDHeader, "ETag", "Retry-After", middleware.IdempotentReplayedHeader,
+			"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           cfg.CORSMaxAge,
+	})(handler)
+	handler = middleware.ClientInfo(handler)
+
+	// Spans cover the rate limits too, and are named by routes
+	handler = middleware.Tracing(routes)(handler)
+
+	// Before anything can answer, so every response is hardened
+	docsPolicy := cfg.DocsContentSecurityPolicy
+	if docsPolicy == "" {
+		docsPolicy = openapi.DocsContentSecurityPolicy(openapi.SpecPath)
+	}
+	handler = middleware.SecureHeaders(
+		middleware.WithReferrerPolicy(cfg.ReferrerPolicy),
+		middleware.WithHSTS(cfg.HSTSMaxAge, cfg.TrustForwardedProto),
+		middleware.WithContentSecurityPolicy(openapi.DocsPath, docsPolicy),
+	)(handler)
+
+	// Liveness probes skip the chain: the Redis limiter would make them
+	// depend on Redis
+	limited := handler
+	handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == livezPath {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		limited.ServeHTTP(w, r)
+	})
+
+	// Canonical paths before the limits, auth and metrics that key on
+	// them. Validate has rejected unknown modes.
+	pathMode, err := middleware.ParsePathNormalization(cfg.PathNormalization)
+	if err != nil {
+		pathMode = middleware.PathRewrite
+	}
+	handler = middleware.NormalizePath(pathMode)(handler)
+
+	if logger != nil {
+		handler = middleware.Logger(logger,
+			middleware.WithLogSkipPaths(cfg.RequestLogSkipPaths...),
+			middleware.WithLogSampleRate(cfg.RequestLogSampleRate),
+		)(handler)
+	}
+
+	// Who the client is, for the log and everything inside. Validate has
+	// rejected malformed proxies; without them nothing is trusted.
+	trustedProxies, _ := middleware.ParseTrustedProxies(cfg.TrustedProxies)
+	handler = middleware.ClientIP(trustedProxies, middleware.WithClientIPHeader(cfg.ClientIPHeader))(handler)
+
+	// Outermost, so every response carries an ID to quote in bug reports
+	handler = middleware.RequestID(handler)
+
+	return handler, limiter
+}
@@ -0,0 +1,212 @@
+// internal/app/credentials_test.go
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/infrastructure/redis"
+	usergrpc "user-service/internal/interfaces/grpc"
+	userv1 "user-service/proto/user/v1"
+
+	"github.com/alicebob/miniredis/v2"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// bcryptPrefix starts every hash the service stores
+var bcryptPrefix = []byte("$2a$")
+
+// TestE2E_PasswordHashNeverLeaves walks every way a user leaves the
+// service - HTTP responses, the internal and admin APIs, gRPC, the Redis
+// cache and published events - and checks none of them carries the hash
+func TestE2E_PasswordHashNeverLeaves(t *testing.T) {
+	if hash, _ := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost); !bytes.HasPrefix(hash, bcryptPrefix) {
+		t.Fatalf("bcrypt hashes don't start with %s: %s", bcryptPrefix, hash)
+	}
+
+	h := newHarness(t, true, func(cfg *config.Config) {
+		cfg.AdminAPIKeys = map[string]string{"ops": "ops-key"}
+		cfg.InternalAPIKeys = map[string]string{"cart": "cart-key"}
+		cfg.SnapshotSigningSecret = "e2e-snapshot-secret-of-32-characters"
+		cfg.GRPCPort = "0"
+	})
+	events := subscribe(t, h.redis, redis.UserEventsChannel)
+
+	var exposed []string
+	check := func(what string, raw []byte) {
+		t.Helper()
+		if bytes.Contains(raw, bcryptPrefix) {
+			t.Errorf("%s carries a password hash: %s", what, raw)
+		}
+		exposed = append(exposed, what)
+	}
+	call := func(req request, status int) response {
+		t.Helper()
+		resp := h.expect(t, req, status)
+		check(req.method+" "+req.path, resp.body)
+		return resp
+	}
+
+	body := map[string]string{"username": "alice", "email": "alice@example.com", "password": testPassword}
+	call(request{method: http.MethodPost, path: "/users/register/validate", body: body}, http.StatusOK)
+	registered := call(request{method: http.MethodPost, path: "/users/register", body: body}, http.StatusCreated).json(t)
+	login := call(request{
+		method: http.MethodPost, path: "/users/login",
+		body: map[string]string{"email": "alice@example.com", "password": testPassword},
+	}, http.StatusOK).json(t)
+	token, _ := login["token"].(string)
+	user, _ := registered["user"].(map[string]interface{})
+	id := user["id"]
+	if token == "" || id == nil {
+		t.Fatalf("unexpected signup %v / %v", registered, login)
+	}
+
+	// Twice, so the second read comes from the cache
+	call(request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusOK)
+	call(request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusOK)
+	call(request{method: http.MethodPut, path: "/users/update", token: token, body: map[string]string{"first_name": "Alice"}}, http.StatusOK)
+	h.signup(t, "bob")
+	call(request{method: http.MethodGet, path: "/users", token: h.promote(t, "bob")}, http.StatusOK)
+	call(request{method: http.MethodGet, path: "/users/me/preferences", token: token}, http.StatusOK)
+	call(request{method: http.MethodGet, path: "/admin/users", apiKey: "ops-key"}, http.StatusOK)
+	call(request{method: http.MethodGet, path: fmt.Sprintf("/admin/users/%v/snapshot", id), apiKey: "ops-key"}, http.StatusOK)
+	call(request{method: http.MethodGet, path: "/internal/users/by-email?email=alice@example.com", apiKey: "cart-key"}, http.StatusOK)
+	newPassword := "N3w-" + testPassword
+	call(request{
+		method: http.MethodPut, path: "/users/me/password", token: token,
+		body: map[string]string{"current_password": testPassword, "new_password": newPassword},
+	}, http.StatusOK)
+	h.app.components.UserService.Wait()
+	// Revocation has second precision and covers tokens issued in the same second
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	login = call(request{
+		method: http.MethodPost, path: "/users/login",
+		body: map[string]string{"email": "alice@example.com", "password": newPassword},
+	}, http.StatusOK).json(t)
+	token, _ = login["token"].(string)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	h.app.ServeGRPC(ln)
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	client := userv1.NewUserServiceClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), usergrpc.APIKeyHeader, "cart-key")
+	userID := uint64(id.(float64))
+	for name, rpc := range map[string]func() (proto.Message, error){
+		"GetUser": func() (proto.Message, error) {
+			return client.GetUser(ctx, &userv1.GetUserRequest{Id: userID})
+		},
+		"BatchGetUsers": func() (proto.Message, error) {
+			return client.BatchGetUsers(ctx, &userv1.BatchGetUsersRequest{Ids: []uint64{userID}})
+		},
+		"SearchUsers": func() (proto.Message, error) {
+			return client.SearchUsers(ctx, &userv1.SearchUsersRequest{Query: "ali"})
+		},
+	} {
+		msg, err := rpc()
+		if err != nil {
+			t.Fatalf("gRPC %s: %v", name, err)
+		}
+		raw, _ := proto.Marshal(msg)
+		check("gRPC "+name, raw)
+	}
+
+	// Deleting the account publishes an event carrying the user
+	call(request{method: http.MethodDelete, path: "/users/delete", token: token, body: map[string]string{"password": newPassword}}, http.StatusAccepted)
+	h.app.components.UserService.Wait()
+	for _, key := range h.redis.Keys() {
+		check("Redis key "+key, []byte(redisValue(t, h.redis, key)))
+	}
+	published := events()
+	if len(published) == 0 {
+		t.Error("expected events published")
+	}
+	for i, event := range published {
+		check(fmt.Sprintf("event %d", i), []byte(event))
+	}
+	t.Logf("checked %d representations", len(exposed))
+}
+
+// subscribe collects what is published on channel until the returned
+// function is called
+func subscribe(t *testing.T, mr *miniredis.Miniredis, channel string) func() []string {
+	t.Helper()
+	sub := mr.NewSubscriber()
+	sub.Subscribe(channel)
+
+	var mu sync.Mutex
+	var messages []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range sub.Messages() {
+			mu.Lock()
+			messages = append(messages, msg.Message)
+			mu.Unlock()
+		}
+	}()
+	stop := func() []string {
+		sub.Unsubscribe(channel)
+		sub.Close()
+		<-done
+		mu.Lock()
+		defer mu.Unlock()
+		return messages
+	}
+	t.Cleanup(func() {
+		select {
+		case <-done:
+		default:
+			stop()
+		}
+	})
+	return stop
+}
+
+// redisValue renders whatever is stored at key
+func redisValue(t *testing.T, mr *miniredis.Miniredis, key string) string {
+	t.Helper()
+	var value interface{}
+	var err error
+	switch kind := mr.Type(key); kind {
+	case "string":
+		value, err = mr.Get(key)
+	case "list":
+		value, err = mr.List(key)
+	case "set":
+		value, err = mr.Members(key)
+	case "zset":
+		value, err = mr.SortedSet(key)
+	case "hash":
+		fields := make(map[string]string)
+		var names []string
+		names, err = mr.HKeys(key)
+		for _, name := range names {
+			fields[name] = mr.HGet(key, name)
+		}
+		value = fields
+	default:
+		t.Fatalf("Redis key %s has type %s, which the test can't read", key, kind)
+	}
+	if err != nil {
+		t.Fatalf("read Redis key %s: %v", key, err)
+	}
+	return fmt.Sprint(value)
+}
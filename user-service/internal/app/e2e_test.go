@@ -0,0 +1,2021 @@
+// internal/app/e2e_test.go
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/infrastructure/postgres"
+	"user-service/internal/infrastructure/redis"
+	"user-service/internal/infrastructure/storage"
+	"user-service/internal/interfaces/http/openapi"
+	"user-service/internal/testsupport"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const testPassword = "Sup3r-secret!"
+
+// harness serves a full App over a real listener against an in-memory
+// SQLite database and optionally miniredis
+type harness struct {
+	app    *App
+	db     *gorm.DB
+	url    string
+	client *http.Client
+	cfg    *config.Config
+	// redis is nil unless the harness was built with Redis
+	redis *miniredis.Miniredis
+	// resets gets the password reset tokens the app sends
+	resets *resetInbox
+	// signups counts registered users so each gets its own client IP
+	signups int
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		JWTSecret:              "e2e-secret",
+		JWTExpire:              time.Hour,
+		JWTRefreshExpire:       24 * time.Hour,
+		JWTRememberMeExpire:    7 * 24 * time.Hour,
+		CacheUserTTL:           time.Minute,
+		BlocklistLocalTTL:      time.Second,
+		LastLoginBufferSize:    16,
+		LastLoginFlushInterval: 10 * time.Millisecond,
+		DeletionGracePeriod:    24 * time.Hour,
+		ErasureInterval:        time.Hour,
+		RateLimitGlobal:        100,
+		RateLimitGlobalBurst:   200,
+		MaxBodySize:            config.DefaultMaxBodySize,
+		RequestTimeout:         10 * time.Second,
+		// The harness is the proxy naming each request's client
+		TrustedProxies: []string{"127.0.0.1", "::1"},
+	}
+}
+
+// newHarness builds the app; tweaks adjust the test config before wiring
+func newHarness(t *testing.T, withRedis bool, tweaks ...func(*config.Config)) *harness {
+	t.Helper()
+
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sql db: %v", err)
+	}
+	// SQLite serializes writers anyway; one connection avoids lock errors
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := postgres.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	var redisClient *redis.RedisClient
+	var mr *miniredis.Miniredis
+	if withRedis {
+		mr = miniredis.RunT(t)
+		redisClient, err = redis.NewRedisClient(mr.Addr(), "", 0)
+		if err != nil {
+			t.Fatalf("redis: %v", err)
+		}
+		t.Cleanup(func() { redisClient.Close() })
+	}
+
+	cfg := testConfig()
+	for _, tweak := range tweaks {
+		tweak(cfg)
+	}
+	registry := prometheus.NewRegistry()
+	resets := &resetInbox{tokens: make(map[string]string)}
+	app, err := NewApp(cfg, WithDeps(Deps{
+		DB:                  db,
+		Redis:               redisClient,
+		Registerer:          registry,
+		Gatherer:            registry,
+		ResetNotifier:       resets,
+		EmailChangeNotifier: resets,
+		RequestLogger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}))
+	if err != nil {
+		t.Fatalf("new app: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- app.Serve(ctx, ln)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{}}
+	t.Cleanup(func() {
+		cancel()
+		if err := <-served; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+		client.CloseIdleConnections()
+	})
+
+	return &harness{app: app, db: db, url: "http://" + ln.Addr().String(), client: client, cfg: cfg, redis: mr, resets: resets}
+}
+
+// resetInbox keeps the last password reset or email change token sent to
+// each email
+type resetInbox struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func (i *resetInbox) SendPasswordReset(ctx context.Context, user *domain.User, token string, expiresAt time.Time) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.tokens[user.Email] = token
+	return nil
+}
+
+func (i *resetInbox) SendEmailChange(ctx context.Context, user *domain.User, newEmail, token string, expiresAt time.Time) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.tokens[newEmail] = token
+	return nil
+}
+
+func (i *resetInbox) token(email string) string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.tokens[email]
+}
+
+type request struct {
+	method string
+	path   string
+	token  string
+	// client sets X-Forwarded-For so per-IP limits can be kept apart
+	client string
+	// apiKey authenticates admin and internal routes
+	apiKey string
+	accept string
+	// origin makes it a cross-origin request; with an OPTIONS method, a
+	// preflight
+	origin string
+	body   interface{}
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (r response) json(t *testing.T) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := json.Unmarshal(r.body, &out); err != nil {
+		t.Fatalf("decode %q: %v", r.body, err)
+	}
+	return out
+}
+
+// errorOf is the error object of a JSON error response
+func errorOf(body map[string]interface{}) map[string]interface{} {
+	detail, _ := body["error"].(map[string]interface{})
+	return detail
+}
+
+// errorDetails is the details of a JSON error response
+func errorDetails(body map[string]interface{}) map[string]interface{} {
+	details, _ := errorOf(body)["details"].(map[string]interface{})
+	return details
+}
+
+func (h *harness) do(t *testing.T, req request) response {
+	t.Helper()
+
+	var body io.Reader
+	if req.body != nil {
+		raw, err := json.Marshal(req.body)
+		if err != nil {
+			t.Fatalf("encode body: %v", err)
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	httpReq, err := http.NewRequest(req.method, h.url+req.path, body)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if req.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+req.token)
+	}
+	if req.client != "" {
+		httpReq.Header.Set("X-Forwarded-For", req.client)
+	}
+	if req.apiKey != "" {
+		httpReq.Header.Set("X-API-Key", req.apiKey)
+	}
+	if req.accept != "" {
+		httpReq.Header.Set("Accept", req.accept)
+	}
+	if req.origin != "" {
+		httpReq.Header.Set("Origin", req.origin)
+		if req.method == http.MethodOptions {
+			httpReq.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			httpReq.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+		}
+	}
+
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		t.Fatalf("%s %s: %v", req.method, req.path, err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	return response{status: resp.StatusCode, header: resp.Header, body: raw}
+}
+
+func (h *harness) expect(t *testing.T, req request, status int) response {
+	t.Helper()
+	resp := h.do(t, req)
+	if resp.status != status {
+		t.Fatalf("%s %s: expected %d, got %d: %s", req.method, req.path, status, resp.status, resp.body)
+	}
+	return resp
+}
+
+// signup registers and logs in a user from its own client IP and returns
+// the token
+func (h *harness) signup(t *testing.T, name string) string {
+	t.Helper()
+	h.signups++
+	client := fmt.Sprintf("10.0.0.%d", h.signups)
+	h.expect(t, request{
+		method: http.MethodPost, path: "/users/register", client: client,
+		body: map[string]string{"username": name, "email": name + "@example.com", "password": testPassword},
+	}, http.StatusCreated)
+	return h.login(t, name, client)
+}
+
+// login signs name in from client and returns the token
+func (h *harness) login(t *testing.T, name, client string) string {
+	t.Helper()
+	resp := h.expect(t, request{
+		method: http.MethodPost, path: "/users/login", client: client,
+		body: map[string]string{"email": name + "@example.com", "password": testPassword},
+	}, http.StatusOK)
+
+	token, _ := resp.json(t)["token"].(string)
+	if token == "" {
+		t.Fatalf("login returned no token: %s", resp.body)
+	}
+	return token
+}
+
+// promote makes name an admin straight in the database and returns a token
+// from a new login, which carries the role
+func (h *harness) promote(t *testing.T, name string) string {
+	t.Helper()
+	ctx := context.Background()
+	repo := postgres.NewUserRepository(h.db)
+	user, err := repo.GetByEmail(ctx, name+"@example.com")
+	if err != nil {
+		t.Fatalf("find %s: %v", name, err)
+	}
+	if err := repo.UpdateFields(ctx, user.ID, map[string]interface{}{"role": string(domain.RoleAdmin)}); err != nil {
+		t.Fatalf("promote %s: %v", name, err)
+	}
+	h.signups++
+	return h.login(t, name, fmt.Sprintf("10.0.0.%d", h.signups))
+}
+
+var backends = []struct {
+	name      string
+	withRedis bool
+}{
+	{"memory", false},
+	{"redis", true},
+}
+
+func TestE2E_UserLifecycle(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			h := newHarness(t, backend.withRedis)
+
+			alice := h.signup(t, "alice")
+			h.signup(t, "bob")
+
+			me := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK).json(t)
+			if _, leaked := me["Password"]; leaked || me["Email"] != "alice@example.com" {
+				t.Errorf("unexpected /users/me body %v", me)
+			}
+
+			updated := h.expect(t, request{
+				method: http.MethodPut, path: "/users/update", token: alice,
+				body: map[string]string{"first_name": "Alice"},
+			}, http.StatusOK).json(t)
+			if user, _ := updated["user"].(map[string]interface{}); user["FirstName"] != "Alice" || user["TokenVersion"] != nil {
+				t.Errorf("unexpected update body %v", updated)
+			}
+
+			me = h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK).json(t)
+			if me["FirstName"] != "Alice" {
+				t.Errorf("update not visible on re-read: %v", me)
+			}
+
+			// Listing every user is for admins
+			denied := h.expect(t, request{method: http.MethodGet, path: "/users", token: alice}, http.StatusForbidden).json(t)
+			if errorOf(denied)["code"] != "insufficient_role" {
+				t.Errorf("unexpected 403 body %v", denied)
+			}
+			alice = h.promote(t, "alice")
+			list := h.expect(t, request{method: http.MethodGet, path: "/users?page=1&page_size=1", token: alice}, http.StatusOK).json(t)
+			if list["total"] != float64(2) || list["total_pages"] != float64(2) {
+				t.Errorf("unexpected list body %v", list)
+			}
+			if users, _ := list["items"].([]interface{}); len(users) != 1 {
+				t.Errorf("expected one user per page, got %v", list["items"])
+			}
+
+			h.expect(t, request{method: http.MethodDelete, path: "/users/delete", token: alice, body: map[string]string{"password": testPassword}}, http.StatusAccepted)
+		})
+	}
+}
+
+func TestE2E_DeleteRevokesSessions(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			h := newHarness(t, backend.withRedis)
+			token := h.signup(t, "alice")
+			deleteAccount := func(body interface{}, status int) map[string]interface{} {
+				t.Helper()
+				return h.expect(t, request{method: http.MethodDelete, path: "/users/delete", token: token, body: body}, status).json(t)
+			}
+
+			// A token alone isn't enough
+			if wrong := deleteAccount(map[string]string{"password": "not-" + testPassword}, http.StatusForbidden); errorOf(wrong)["code"] != "invalid_password" {
+				t.Errorf("expected invalid_password, got %v", wrong)
+			}
+			h.expect(t, request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusOK)
+
+			deleteAccount(map[string]string{"password": testPassword}, http.StatusAccepted)
+			h.app.components.UserService.Wait()
+			// Refused through the token version, without Redis too
+			h.expect(t, request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusUnauthorized)
+		})
+	}
+}
+
+func TestE2E_AuthFailures(t *testing.T) {
+	h := newHarness(t, false)
+	h.signup(t, "alice")
+
+	forged, err := auth.NewJWTManager("other-secret", time.Hour).GenerateToken(1)
+	if err != nil {
+		t.Fatalf("forge token: %v", err)
+	}
+	expired, err := auth.NewJWTManager(h.cfg.JWTSecret, -time.Minute).GenerateToken(1)
+	if err != nil {
+		t.Fatalf("expired token: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		req    request
+		status int
+	}{
+		{"missing header", request{method: http.MethodGet, path: "/users/me"}, http.StatusUnauthorized},
+		{"garbage token", request{method: http.MethodGet, path: "/users/me", token: "not-a-jwt"}, http.StatusUnauthorized},
+		{"wrong secret", request{method: http.MethodGet, path: "/users/me", token: forged}, http.StatusUnauthorized},
+		{"expired", request{method: http.MethodGet, path: "/users", token: expired}, http.StatusUnauthorized},
+		{"update without token", request{method: http.MethodPut, path: "/users/update", body: map[string]string{}}, http.StatusUnauthorized},
+		{"wrong password", request{
+			method: http.MethodPost, path: "/users/login", client: "10.0.1.1",
+			body: map[string]string{"email": "alice@example.com", "password": "nope"},
+		}, http.StatusUnauthorized},
+		{"unknown email", request{
+			method: http.MethodPost, path: "/users/login", client: "10.0.1.2",
+			body: map[string]string{"email": "nobody@example.com", "password": testPassword},
+		}, http.StatusUnauthorized},
+		{"admin routes not mounted", request{method: http.MethodGet, path: "/admin/deletions"}, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h.expect(t, tt.req, tt.status)
+		})
+	}
+}
+
+func TestE2E_ValidationErrors(t *testing.T) {
+	h := newHarness(t, true)
+	h.signup(t, "alice")
+
+	tests := []struct {
+		name   string
+		body   interface{}
+		status int
+		field  string
+	}{
+		{"bad email", map[string]string{"username": "bob", "email": "not-an-email", "password": testPassword}, http.StatusBadRequest, "email"},
+		{"short password", map[string]string{"username": "bob", "email": "bob@example.com", "password": "x"}, http.StatusBadRequest, "password"},
+		{"missing username", map[string]string{"email": "bob@example.com", "password": testPassword}, http.StatusBadRequest, "username"},
+		{"duplicate email", map[string]string{"username": "alice2", "email": "alice@example.com", "password": testPassword}, http.StatusConflict, ""},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := h.expect(t, request{
+				method: http.MethodPost, path: "/users/register",
+				client: fmt.Sprintf("10.0.2.%d", i), body: tt.body,
+			}, tt.status)
+			if tt.field == "" {
+				return
+			}
+			fields, _ := errorOf(resp.json(t))["fields"].(map[string]interface{})
+			if _, ok := fields[tt.field]; !ok {
+				t.Errorf("expected error for field %q, got %s", tt.field, resp.body)
+			}
+		})
+	}
+}
+
+func TestE2E_BodySizeLimit(t *testing.T) {
+	h := newHarness(t, false, func(cfg *config.Config) { cfg.MaxBodySize = 1024 })
+	h.signup(t, "alice")
+
+	resp := h.expect(t, request{
+		method: http.MethodPost, path: "/v1/users/register", client: "10.0.9.1",
+		body: map[string]string{"username": strings.Repeat("b", 2048), "email": "bob@example.com", "password": testPassword},
+	}, http.StatusRequestEntityTooLarge)
+	if message, _ := errorOf(resp.json(t))["message"].(string); !strings.Contains(message, "1024 bytes") {
+		t.Errorf("expected the limit in the message, got %s", resp.body)
+	}
+}
+
+func TestE2E_RateLimits(t *testing.T) {
+	t.Run("memory register", func(t *testing.T) {
+		h := newHarness(t, false)
+		body := map[string]string{"username": "alice", "email": "alice@example.com", "password": testPassword}
+
+		h.expect(t, request{method: http.MethodPost, path: "/users/register", client: "10.0.3.1", body: body}, http.StatusCreated)
+		// Validation shares the registration budget
+		resp := h.expect(t, request{method: http.MethodPost, path: "/users/register/validate", client: "10.0.3.1", body: body}, http.StatusTooManyRequests)
+		if errorOf(resp.json(t))["code"] != "rate_limit_exceeded" {
+			t.Errorf("unexpected 429 body %s", resp.body)
+		}
+
+		// Another client still has its own budget and reaches validation
+		h.expect(t, request{method: http.MethodPost, path: "/users/register/validate", client: "10.0.3.2", body: body}, http.StatusBadRequest)
+	})
+
+	t.Run("redis login", func(t *testing.T) {
+		h := newHarness(t, true)
+		h.signup(t, "alice")
+
+		login := request{
+			method: http.MethodPost, path: "/users/login", client: "10.0.4.1",
+			body: map[string]string{"email": "alice@example.com", "password": "wrong"},
+		}
+		for i := 0; i < 10; i++ {
+			h.expect(t, login, http.StatusUnauthorized)
+		}
+		h.expect(t, login, http.StatusTooManyRequests)
+
+		// The endpoint limit is separate from the global per-IP budget
+		h.expect(t, request{method: http.MethodGet, path: "/health", client: "10.0.4.1"}, http.StatusOK)
+	})
+
+	t.Run("redis global", func(t *testing.T) {
+		limited := newHarness(t, true, func(cfg *config.Config) { cfg.RateLimitGlobal = 3 })
+		for i := 0; i < 3; i++ {
+			limited.expect(t, request{method: http.MethodGet, path: "/health", client: "10.0.5.1"}, http.StatusOK)
+		}
+		limited.expect(t, request{method: http.MethodGet, path: "/health", client: "10.0.5.1"}, http.StatusTooManyRequests)
+	})
+
+	t.Run("redis down", func(t *testing.T) {
+		h := newHarness(t, true, func(cfg *config.Config) {
+			cfg.RateLimitGlobal = 2
+			cfg.RateLimitFailureModes = map[string]string{"login": "closed"}
+		})
+		h.signup(t, "alice")
+		h.redis.SetError("ERR connection lost")
+
+		// Login refuses rather than go unlimited...
+		resp := h.expect(t, request{
+			method: http.MethodPost, path: "/users/login", client: "10.0.6.1",
+			body: map[string]string{"email": "alice@example.com", "password": "wrong"},
+		}, http.StatusServiceUnavailable)
+		if errorOf(resp.json(t))["code"] != "rate_limit_unavailable" {
+			t.Errorf("unexpected 503 body %s", resp.body)
+		}
+
+		// ...while the global limit carries on in memory
+		spec := request{method: http.MethodGet, path: openapi.SpecPath, client: "10.0.6.2"}
+		h.expect(t, spec, http.StatusOK)
+		h.expect(t, spec, http.StatusOK)
+		h.expect(t, spec, http.StatusTooManyRequests)
+	})
+}
+
+func TestE2E_CORSPreflight(t *testing.T) {
+	h := newHarness(t, false, func(cfg *config.Config) {
+		cfg.CORSAllowedOrigins = []string{"https://shop.example.com", "https://*.preview.example.com"}
+		cfg.CORSAllowedMethods = []string{"GET", "POST", "PATCH"}
+		cfg.CORSAllowedHeaders = []string{"Authorization", "Content-Type"}
+		cfg.CORSAllowCredentials = true
+		cfg.CORSMaxAge = 10 * time.Minute
+		cfg.RateLimitLogin = 0.001
+		cfg.RateLimitLoginBurst = 1
+	})
+
+	// Preflights skip auth and the login limit, which would refuse them
+	for i := 0; i < 3; i++ {
+		resp := h.expect(t, request{method: http.MethodOptions, path: "/users/login", origin: "https://pr-42.preview.example.com"}, http.StatusNoContent)
+		if resp.header.Get("Access-Control-Allow-Origin") != "https://pr-42.preview.example.com" ||
+			resp.header.Get("Access-Control-Allow-Credentials") != "true" ||
+			resp.header.Get("Access-Control-Allow-Methods") != "GET, POST, PATCH" ||
+			resp.header.Get("Access-Control-Max-Age") != "600" {
+			t.Fatalf("expected the preview origin allowed, got %v", resp.header)
+		}
+	}
+	h.expect(t, request{method: http.MethodOptions, path: "/users/me", origin: "https://shop.example.com"}, http.StatusNoContent)
+
+	denied := h.expect(t, request{method: http.MethodOptions, path: "/users/me", origin: "https://evil.example.net"}, http.StatusNoContent)
+	for header := range denied.header {
+		if strings.HasPrefix(header, "Access-Control-") {
+			t.Errorf("expected no CORS headers for another origin, got %s", header)
+		}
+	}
+
+	// The actual request carries the headers scripts may read
+	resp := h.expect(t, request{method: http.MethodGet, path: "/users/me", origin: "https://shop.example.com"}, http.StatusUnauthorized)
+	if resp.header.Get("Access-Control-Allow-Origin") != "https://shop.example.com" ||
+		!strings.Contains(resp.header.Get("Access-Control-Expose-Headers"), "X-Request-ID") {
+		t.Errorf("expected CORS headers on the response, got %v", resp.header)
+	}
+}
+
+func TestE2E_InviteOnlyRegistration(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			h := newHarness(t, backend.withRedis, func(cfg *config.Config) {
+				cfg.RegistrationMode = "invite"
+				cfg.AdminAPIKeys = map[string]string{"ops": "ops-key"}
+			})
+			signup := func(name, client, code string) response {
+				return h.do(t, request{
+					method: http.MethodPost, path: "/users/register", client: client,
+					body: map[string]string{
+						"username": name, "email": name + "@example.com", "password": testPassword, "invite_code": code,
+					},
+				})
+			}
+
+			const maxUses = 3
+			minted := h.expect(t, request{
+				method: http.MethodPost, path: "/admin/invites", apiKey: "ops-key",
+				body: map[string]int{"max_uses": maxUses},
+			}, http.StatusCreated).json(t)
+			invite, _ := minted["invite"].(map[string]interface{})
+			code, _ := invite["code"].(string)
+			if code == "" || invite["created_by"] != "ops" {
+				t.Fatalf("unexpected invite %v", minted)
+			}
+
+			if resp := signup("nobody", "10.0.6.1", ""); resp.status != http.StatusForbidden || errorOf(resp.json(t))["code"] != "invite_required" {
+				t.Errorf("expected invite_required, got %d %s", resp.status, resp.body)
+			}
+
+			// Every signup races for the same code; only maxUses may win
+			const racers = 12
+			statuses := make([]int, racers)
+			var wg sync.WaitGroup
+			for i := 0; i < racers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					resp := signup(fmt.Sprintf("racer%d", i), fmt.Sprintf("10.0.7.%d", i+1), code)
+					statuses[i] = resp.status
+					if resp.status == http.StatusForbidden && errorOf(resp.json(t))["code"] != "invite_exhausted" {
+						t.Errorf("racer %d: expected invite_exhausted, got %s", i, resp.body)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			created := 0
+			for i, status := range statuses {
+				switch status {
+				case http.StatusCreated:
+					created++
+				case http.StatusForbidden:
+				default:
+					t.Errorf("racer %d: unexpected status %d", i, status)
+				}
+			}
+			if created != maxUses {
+				t.Errorf("expected exactly %d signups, got %d", maxUses, created)
+			}
+
+			other := h.expect(t, request{
+				method: http.MethodPost, path: "/admin/invites", apiKey: "ops-key",
+				body: map[string]int{"max_uses": 5},
+			}, http.StatusCreated).json(t)
+			otherCode, _ := other["invite"].(map[string]interface{})["code"].(string)
+			h.expect(t, request{
+				method: http.MethodPost, path: "/admin/invites/revoke", apiKey: "ops-key",
+				body: map[string]string{"code": otherCode, "reason": "posted publicly"},
+			}, http.StatusOK)
+			if resp := signup("late", "10.0.6.2", otherCode); resp.status != http.StatusForbidden || errorOf(resp.json(t))["code"] != "invite_invalid" {
+				t.Errorf("expected invite_invalid for a revoked code, got %d %s", resp.status, resp.body)
+			}
+			h.expect(t, request{
+				method: http.MethodPost, path: "/admin/invites/revoke", apiKey: "ops-key",
+				body: map[string]string{"code": "NOPE-NOPE-NOPE", "reason": "typo"},
+			}, http.StatusNotFound)
+		})
+	}
+}
+
+func TestE2E_ClosedRegistration(t *testing.T) {
+	h := newHarness(t, false, func(cfg *config.Config) { cfg.RegistrationMode = "closed" })
+
+	resp := h.expect(t, request{
+		method: http.MethodPost, path: "/users/register", client: "10.0.8.1",
+		body: map[string]string{"username": "alice", "email": "alice@example.com", "password": testPassword},
+	}, http.StatusForbidden)
+	if body := resp.json(t); errorOf(body)["code"] != "registration_closed" || errorOf(body)["message"] == "" {
+		t.Errorf("unexpected body %s", resp.body)
+	}
+}
+
+func TestE2E_AdminJobs(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			h := newHarness(t, backend.withRedis, func(cfg *config.Config) {
+				cfg.AdminAPIKeys = map[string]string{"ops": "ops-key"}
+			})
+
+			listing := h.expect(t, request{method: http.MethodGet, path: "/admin/jobs", apiKey: "ops-key"}, http.StatusOK).json(t)
+			list, _ := listing["jobs"].([]interface{})
+			var names []string
+			for _, item := range list {
+				job, _ := item.(map[string]interface{})
+				name, _ := job["name"].(string)
+				names = append(names, name)
+			}
+			// Redis limiters keep no per-process state to clean up, and
+			// without Redis there are no shared kill switches to pull
+			want := []string{"access_token_usage_flush", "bulk_actions", "last_login_flush", "rate_limiter_cleanup", "refresh_token_cleanup", "session_cleanup", "user_erasure"}
+			if backend.withRedis {
+				want = []string{"access_token_usage_flush", "bulk_actions", "endpoint_switch_refresh", "last_login_flush", "refresh_token_cleanup", "session_cleanup", "user_erasure"}
+			}
+			if !slices.Equal(names, want) {
+				t.Errorf("listed %v, want %v", names, want)
+			}
+
+			run := h.expect(t, request{method: http.MethodPost, path: "/admin/jobs/user_erasure/run", apiKey: "ops-key"}, http.StatusOK).json(t)
+			job, _ := run["job"].(map[string]interface{})
+			if job["name"] != "user_erasure" || job["last_error"] != "" || job["last_run"] == nil || job["runs"] != float64(1) {
+				t.Errorf("unexpected run result %v", run)
+			}
+
+			h.expect(t, request{method: http.MethodPost, path: "/admin/jobs/nope/run", apiKey: "ops-key"}, http.StatusNotFound)
+			h.expect(t, request{method: http.MethodGet, path: "/admin/jobs/user_erasure/run", apiKey: "ops-key"}, http.StatusMethodNotAllowed)
+			h.expect(t, request{method: http.MethodPost, path: "/admin/jobs/user_erasure/run"}, http.StatusUnauthorized)
+		})
+	}
+}
+
+func TestE2E_TimestampsAreUTC(t *testing.T) {
+	if !testsupport.InLocalZone(t) {
+		return
+	}
+	h := newHarness(t, false, func(cfg *config.Config) {
+		cfg.AdminAPIKeys = map[string]string{"ops": "ops-key"}
+	})
+	rfc3339UTCMillis := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z$`)
+
+	before := time.Now()
+	alice := h.signup(t, "alice")
+
+	// last_login arrives with the next flush
+	var me map[string]interface{}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		me = h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK).json(t)
+		if me["LastLogin"] != nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	for _, field := range []string{"CreatedAt", "UpdatedAt", "LastLogin"} {
+		value, _ := me[field].(string)
+		if !rfc3339UTCMillis.MatchString(value) {
+			t.Errorf("%s = %q, want RFC 3339 UTC with milliseconds", field, value)
+			continue
+		}
+		// A local time stored or sent as if it were UTC is seven hours off
+		at, _ := time.Parse(time.RFC3339, value)
+		if at.Before(before.Add(-time.Second)) || at.After(time.Now().Add(time.Second)) {
+			t.Errorf("%s = %s, expected around %s", field, value, before.UTC().Format(time.RFC3339))
+		}
+	}
+
+	token := h.expect(t, request{method: http.MethodGet, path: "/users/me/token", token: alice}, http.StatusOK).json(t)
+	if exp, _ := token["exp"].(string); !rfc3339UTCMillis.MatchString(exp) {
+		t.Errorf("exp = %q, want RFC 3339 UTC with milliseconds", exp)
+	}
+
+	// Inputs with an offset are normalized too
+	minted := h.expect(t, request{
+		method: http.MethodPost, path: "/admin/invites", apiKey: "ops-key",
+		body: map[string]string{"expires_at": "2099-03-01T19:30:00+07:00"},
+	}, http.StatusCreated).json(t)
+	invite, _ := minted["invite"].(map[string]interface{})
+	if invite["expires_at"] != "2099-03-01T12:30:00.000Z" {
+		t.Errorf("expires_at = %v, want 2099-03-01T12:30:00.000Z", invite["expires_at"])
+	}
+}
+
+func TestE2E_AccountRecovery(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			h := newHarness(t, backend.withRedis)
+			alice := h.signup(t, "alice")
+
+			me := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK).json(t)
+			if me["RecoveryCodesRemaining"] != float64(0) {
+				t.Errorf("expected no recovery codes yet, got %v", me["RecoveryCodesRemaining"])
+			}
+			h.expect(t, request{
+				method: http.MethodPost, path: "/users/me/recovery-codes", token: alice,
+				body: map[string]string{"password": "wrong"},
+			}, http.StatusBadRequest)
+			generated := h.expect(t, request{
+				method: http.MethodPost, path: "/users/me/recovery-codes", token: alice,
+				body: map[string]string{"password": testPassword},
+			}, http.StatusCreated).json(t)
+			codes, _ := generated["codes"].([]interface{})
+			if len(codes) != 10 {
+				t.Fatalf("expected 10 codes, got %v", generated)
+			}
+			code, _ := codes[0].(string)
+
+			h.expect(t, request{
+				method: http.MethodPost, path: "/users/recover", client: "10.0.6.1",
+				body: map[string]string{"email": "nobody@example.com", "code": code},
+			}, http.StatusUnauthorized)
+			recovered := h.expect(t, request{
+				method: http.MethodPost, path: "/users/recover", client: "10.0.6.1",
+				body: map[string]string{"email": "alice@example.com", "code": code},
+			}, http.StatusOK).json(t)
+			session, _ := recovered["token"].(string)
+			if session == "" || recovered["recovery_codes_remaining"] != float64(9) {
+				t.Fatalf("unexpected recovery body %v", recovered)
+			}
+			// Each code works once
+			h.expect(t, request{
+				method: http.MethodPost, path: "/users/recover", client: "10.0.6.2",
+				body: map[string]string{"email": "alice@example.com", "code": code},
+			}, http.StatusUnauthorized)
+			if !backend.withRedis {
+				// Guesses against one account are limited whatever the address
+				h.expect(t, request{
+					method: http.MethodPost, path: "/users/recover", client: "10.0.6.3",
+					body: map[string]string{"email": "alice@example.com", "code": code},
+				}, http.StatusTooManyRequests)
+			}
+
+			// The recovery session can change the email and password, nothing
+			// else, and the email only through a link sent to the new address
+			denied := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: session}, http.StatusForbidden).json(t)
+			if errorOf(denied)["code"] != "recovery_session" {
+				t.Errorf("unexpected 403 body %v", denied)
+			}
+			for _, body := range []map[string]string{{"first_name": "Mallory"}, {"email": "mallory@example.com"}} {
+				h.expect(t, request{method: http.MethodPut, path: "/users/update", token: session, body: body}, http.StatusForbidden)
+			}
+			if backend.withRedis {
+				h.expect(t, request{
+					method: http.MethodPost, path: "/users/me/email", token: session,
+					body: map[string]string{"email": "alice.recovered@example.com"},
+				}, http.StatusAccepted)
+			}
+			h.expect(t, request{
+				method: http.MethodPut, path: "/users/me/password", token: session,
+				body: map[string]string{"new_password": "An0ther-secret"},
+			}, http.StatusOK)
+			h.app.components.UserService.Wait()
+			// Revocation has second precision and covers tokens issued in
+			// the same second
+			time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+
+			login := h.expect(t, request{
+				method: http.MethodPost, path: "/users/login", client: "10.0.6.4",
+				body: map[string]string{"email": "alice@example.com", "password": "An0ther-secret"},
+			}, http.StatusOK).json(t)
+			token, _ := login["token"].(string)
+			me = h.expect(t, request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusOK).json(t)
+			if me["RecoveryCodesRemaining"] != float64(9) {
+				t.Errorf("expected 9 codes left, got %v", me["RecoveryCodesRemaining"])
+			}
+			if backend.withRedis {
+				// Changing the password signed out every session
+				h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestE2E_HeadRequests(t *testing.T) {
+	h := newHarness(t, false)
+	alice := h.signup(t, "alice")
+
+	// last_login arrives with the next flush and changes the body
+	var get response
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		get = h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK)
+		if get.json(t)["LastLogin"] != nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	head := h.expect(t, request{method: http.MethodHead, path: "/users/me", token: alice}, http.StatusOK)
+	if len(head.body) != 0 {
+		t.Errorf("expected no body for HEAD, got %q", head.body)
+	}
+	for _, name := range []string{"ETag", "Content-Length", "Content-Type"} {
+		if got, want := head.header.Get(name), get.header.Get(name); got != want || want == "" {
+			t.Errorf("HEAD %s = %q, GET %s = %q", name, got, name, want)
+		}
+	}
+
+	// Other GET endpoints answer HEAD too, and anyone signed in can check
+	// an account exists; only admins can GET it
+	h.expect(t, request{method: http.MethodHead, path: "/users/me/token", token: alice}, http.StatusOK)
+	id := fmt.Sprint(get.json(t)["ID"])
+	h.expect(t, request{method: http.MethodHead, path: "/users/" + id, token: alice}, http.StatusOK)
+	h.expect(t, request{method: http.MethodHead, path: "/users/999", token: alice}, http.StatusNotFound)
+	h.expect(t, request{method: http.MethodHead, path: "/users/abc", token: alice}, http.StatusBadRequest)
+	h.expect(t, request{method: http.MethodGet, path: "/users/" + id, token: alice}, http.StatusForbidden)
+	h.expect(t, request{method: http.MethodPost, path: "/users/" + id, token: alice}, http.StatusMethodNotAllowed)
+	h.expect(t, request{method: http.MethodHead, path: "/users/" + id}, http.StatusUnauthorized)
+
+	h.signup(t, "bob")
+	admin := h.promote(t, "bob")
+	account := h.expect(t, request{method: http.MethodGet, path: "/users/" + id, token: admin}, http.StatusOK).json(t)
+	if account["Email"] != "alice@example.com" || account["Password"] != nil {
+		t.Errorf("unexpected account %v", account)
+	}
+	h.expect(t, request{method: http.MethodGet, path: "/users/999", token: admin}, http.StatusNotFound)
+}
+
+func TestE2E_AdminOverview(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			h := newHarness(t, backend.withRedis, func(cfg *config.Config) {
+				cfg.AdminAPIKeys = map[string]string{"ops": "ops-key"}
+			})
+			alice := h.signup(t, "alice")
+			h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK)
+
+			// Wrong passwords from one client until its login limiter steps in
+			limited := false
+			for i := 0; i < 20 && !limited; i++ {
+				resp := h.do(t, request{
+					method: http.MethodPost, path: "/users/login", client: "10.0.9.1",
+					body: map[string]string{"email": "alice@example.com", "password": "wrong"},
+				})
+				limited = resp.status == http.StatusTooManyRequests
+			}
+			if !limited {
+				t.Fatal("expected the login limiter to reject a request")
+			}
+
+			overview := h.expect(t, request{method: http.MethodGet, path: "/admin/overview", apiKey: "ops-key"}, http.StatusOK).json(t)
+
+			users, _ := overview["users"].(map[string]interface{})
+			byStatus, _ := users["by_status"].(map[string]interface{})
+			if users["total"] != float64(1) || byStatus["active"] != float64(1) {
+				t.Errorf("unexpected user counts %v", overview["users"])
+			}
+			rateLimit, _ := overview["rate_limit"].(map[string]interface{})
+			if rejected, _ := rateLimit["rejections_last_hour"].(float64); rejected < 1 {
+				t.Errorf("expected the rejection to be counted, got %v", overview["rate_limit"])
+			}
+			// Only the Redis backend has a user cache to hit or miss
+			cache, _ := overview["cache"].(map[string]interface{})
+			if _, measured := cache["hit_ratio"].(float64); measured != backend.withRedis {
+				t.Errorf("unexpected cache section %v", overview["cache"])
+			}
+			pool, _ := overview["db_pool"].(map[string]interface{})
+			if pool["max_open"] != float64(1) || pool["saturation"] == nil {
+				t.Errorf("unexpected pool section %v", overview["db_pool"])
+			}
+			if jobs, _ := overview["jobs"].([]interface{}); len(jobs) == 0 {
+				t.Errorf("expected job statuses, got %v", overview["jobs"])
+			}
+			// SQLite has no date_trunc, so today's counts degrade on their own
+			if overview["today"] != "unavailable" {
+				t.Errorf("expected today to be unavailable on SQLite, got %v", overview["today"])
+			}
+
+			h.expect(t, request{method: http.MethodGet, path: "/admin/overview"}, http.StatusUnauthorized)
+			h.expect(t, request{method: http.MethodPost, path: "/admin/overview", apiKey: "ops-key"}, http.StatusMethodNotAllowed)
+		})
+	}
+}
+
+func TestE2E_RateLimitWarnMode(t *testing.T) {
+	h := newHarness(t, false, func(cfg *config.Config) {
+		cfg.RateLimitModes = map[string]string{"login": "warn"}
+	})
+	h.signup(t, "alice")
+
+	login := request{
+		method: http.MethodPost, path: "/users/login", client: "10.0.9.1",
+		body: map[string]string{"email": "alice@example.com", "password": "wrong"},
+	}
+	warned := false
+	for i := 0; i < 5; i++ {
+		resp := h.expect(t, login, http.StatusUnauthorized)
+		warned = warned || resp.header.Get("X-RateLimit-Warning") != ""
+	}
+	if !warned {
+		t.Fatal("expected requests over the login limit to carry a warning")
+	}
+
+	// Enforced once the mode is reloaded, without a restart
+	if err := h.app.SetRateLimitModes(map[string]string{"login": "enforce"}); err != nil {
+		t.Fatalf("set modes: %v", err)
+	}
+	h.expect(t, login, http.StatusTooManyRequests)
+
+	if err := h.app.SetRateLimitModes(map[string]string{"login": "loud"}); err == nil {
+		t.Error("expected an invalid mode to be refused")
+	}
+}
+
+func TestE2E_DoubleSubmittedRegistration(t *testing.T) {
+	h := newHarness(t, true)
+
+	signup := request{
+		method: http.MethodPost, path: "/users/register", client: "10.0.7.1",
+		body: map[string]string{"username": "alice", "email": "alice@example.com", "password": testPassword},
+	}
+	responses := make([]response, 2)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = h.do(t, signup)
+		}(i)
+	}
+	wg.Wait()
+
+	// Both clicks see the one account created, neither a conflict
+	for _, resp := range responses {
+		if resp.status != http.StatusCreated || string(resp.body) != string(responses[0].body) {
+			t.Errorf("expected both to get the same 201, got %d %s", resp.status, resp.body)
+		}
+	}
+}
+
+func TestE2E_UserSnapshots(t *testing.T) {
+	h := newHarness(t, false, func(cfg *config.Config) {
+		cfg.AdminAPIKeys = map[string]string{"ops": "ops-key"}
+		cfg.SnapshotSigningSecret = "e2e-snapshot-secret-of-32-characters"
+	})
+	alice := h.signup(t, "alice")
+	me := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK).json(t)
+	path := fmt.Sprintf("/admin/users/%v/snapshot", me["ID"])
+
+	resp := h.expect(t, request{method: http.MethodGet, path: path, apiKey: "ops-key"}, http.StatusOK)
+	var bundle struct {
+		Snapshot  json.RawMessage `json:"snapshot"`
+		Signature string          `json:"signature"`
+	}
+	if err := json.Unmarshal(resp.body, &bundle); err != nil {
+		t.Fatalf("decode bundle: %v", err)
+	}
+	if bytes.Contains(bundle.Snapshot, []byte("password")) || bundle.Signature == "" {
+		t.Fatalf("unexpected bundle %s", resp.body)
+	}
+
+	importSnapshot := func(snapshot json.RawMessage, overwrite bool) request {
+		return request{
+			method: http.MethodPost, path: "/admin/users/import-snapshot", apiKey: "ops-key",
+			body: map[string]interface{}{"snapshot": snapshot, "signature": bundle.Signature, "overwrite": overwrite},
+		}
+	}
+
+	// Unchanged since the export, so there's nothing to apply
+	result := h.expect(t, importSnapshot(bundle.Snapshot, false), http.StatusOK).json(t)
+	if result["created"] != false || result["message"] != "Nothing to import" {
+		t.Errorf("unexpected import result %v", result)
+	}
+
+	h.expect(t, request{
+		method: http.MethodPut, path: "/users/update", token: alice,
+		body: map[string]string{"first_name": "Ally"},
+	}, http.StatusOK)
+	conflict := h.expect(t, importSnapshot(bundle.Snapshot, false), http.StatusConflict).json(t)
+	conflicts, _ := errorDetails(conflict)["conflicts"].([]interface{})
+	if len(conflicts) != 1 {
+		t.Fatalf("expected one conflict, got %v", conflict)
+	}
+	if c, _ := conflicts[0].(map[string]interface{}); c["field"] != "first_name" || c["current"] != "Ally" || c["overwritable"] != true {
+		t.Errorf("unexpected conflict %v", c)
+	}
+	h.expect(t, importSnapshot(bundle.Snapshot, true), http.StatusOK)
+	if after := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK).json(t); after["FirstName"] != me["FirstName"] {
+		t.Errorf("expected the exported first name back, got %v", after["FirstName"])
+	}
+
+	forged := bytes.Replace(bundle.Snapshot, []byte(`"role":"user"`), []byte(`"role":"admin"`), 1)
+	if bytes.Equal(forged, bundle.Snapshot) {
+		t.Fatalf("snapshot has no role to forge: %s", bundle.Snapshot)
+	}
+	invalid := h.expect(t, importSnapshot(forged, true), http.StatusBadRequest).json(t)
+	if errorOf(invalid)["code"] != "invalid_signature" {
+		t.Errorf("unexpected forgery response %v", invalid)
+	}
+
+	h.expect(t, request{method: http.MethodGet, path: path}, http.StatusUnauthorized)
+	h.expect(t, request{method: http.MethodGet, path: "/admin/users/999/snapshot", apiKey: "ops-key"}, http.StatusNotFound)
+}
+
+func TestE2E_ErrorFormatCompat(t *testing.T) {
+	for _, compat := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compat=%v", compat), func(t *testing.T) {
+			h := newHarness(t, false, func(cfg *config.Config) {
+				cfg.ErrorFormatCompat = compat
+			})
+
+			for _, accept := range []string{"", "application/json"} {
+				resp := h.expect(t, request{method: http.MethodGet, path: "/users/me", accept: accept}, http.StatusUnauthorized)
+				legacy := compat && accept == ""
+				if contentType := resp.header.Get("Content-Type"); strings.HasPrefix(contentType, "text/plain") != legacy {
+					t.Errorf("Accept %q: unexpected Content-Type %q", accept, contentType)
+				}
+				if !legacy && errorOf(resp.json(t))["message"] != "missing authorization header" {
+					t.Errorf("Accept %q: unexpected body %s", accept, resp.body)
+				}
+			}
+
+			metrics := string(h.expect(t, request{method: http.MethodGet, path: "/metrics"}, http.StatusOK).body)
+			counted := strings.Contains(metrics, `user_service_http_legacy_error_responses_total{status="401"} 1`)
+			if counted != compat {
+				t.Errorf("expected the legacy response counted only with compat on, got counted=%v", counted)
+			}
+		})
+	}
+}
+
+func TestE2E_RestoreUser(t *testing.T) {
+	h := newHarness(t, true, func(cfg *config.Config) {
+		cfg.AdminAPIKeys = map[string]string{"ops": "ops-key"}
+	})
+	alice := h.signup(t, "alice")
+	me := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK).json(t)
+	path := fmt.Sprintf("/admin/users/%v/restore", me["ID"])
+	restore := func(status int) map[string]interface{} {
+		t.Helper()
+		return h.expect(t, request{
+			method: http.MethodPost, path: path, apiKey: "ops-key",
+			body: map[string]string{"reason": "deleted by mistake"},
+		}, status).json(t)
+	}
+	login := func(status int) {
+		t.Helper()
+		h.expect(t, request{
+			method: http.MethodPost, path: "/users/login", client: "10.0.13.1",
+			body: map[string]string{"email": "alice@example.com", "password": testPassword},
+		}, status)
+	}
+
+	if live := restore(http.StatusConflict); errorOf(live)["code"] != "user_not_deleted" {
+		t.Errorf("expected a live account refused, got %v", live)
+	}
+	if err := postgres.NewUserRepository(h.db).SoftDelete(context.Background(), uint(me["ID"].(float64))); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	login(http.StatusUnauthorized)
+
+	// Support can see the deleted account before bringing it back
+	shown := h.expect(t, request{method: http.MethodGet, path: path, apiKey: "ops-key"}, http.StatusOK).json(t)
+	if shown["Email"] != "alice@example.com" || shown["DeletedAt"] == nil {
+		t.Errorf("expected the deleted account shown, got %v", shown)
+	}
+	h.expect(t, request{method: http.MethodPost, path: path, apiKey: "ops-key", body: map[string]string{}}, http.StatusBadRequest)
+	restore(http.StatusOK)
+	h.app.components.UserService.Wait()
+	login(http.StatusOK)
+	restore(http.StatusConflict)
+	h.expect(t, request{method: http.MethodGet, path: "/admin/users/999/restore", apiKey: "ops-key"}, http.StatusNotFound)
+}
+
+func TestE2E_PurgeUser(t *testing.T) {
+	h := newHarness(t, true, func(cfg *config.Config) {
+		cfg.AdminAPIKeys = map[string]string{"ops": "ops-key"}
+		cfg.PurgeRetention = 48 * time.Hour
+	})
+	alice := h.signup(t, "alice")
+	me := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK).json(t)
+	id := uint(me["ID"].(float64))
+	purge := func(confirm string, status int) map[string]interface{} {
+		t.Helper()
+		return h.expect(t, request{
+			method: http.MethodDelete, path: fmt.Sprintf("/admin/users/%d?confirm=%s", id, confirm), apiKey: "ops-key",
+		}, status).json(t)
+	}
+
+	if live := purge("alice@example.com", http.StatusConflict); errorOf(live)["code"] != "user_not_deleted" {
+		t.Errorf("expected a live account refused, got %v", live)
+	}
+	deletedAt := time.Now().Add(-24 * time.Hour).UTC()
+	if err := h.db.Exec("UPDATE users SET deleted_at = ? WHERE id = ?", deletedAt, id).Error; err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	tooEarly := purge("alice@example.com", http.StatusConflict)
+	purgeAfter, _ := time.Parse(time.RFC3339, fmt.Sprint(errorDetails(tooEarly)["purge_after"]))
+	if errorOf(tooEarly)["code"] != "retention_period" || !purgeAfter.Equal(deletedAt.Add(48*time.Hour).Truncate(time.Millisecond)) {
+		t.Errorf("expected the earliest purge date, got %v", tooEarly)
+	}
+
+	h.db.Exec("UPDATE users SET deleted_at = ? WHERE id = ?", time.Now().Add(-49*time.Hour), id)
+	purge("", http.StatusBadRequest)
+	purge("bob@example.com", http.StatusBadRequest)
+	purge("alice@example.com", http.StatusOK)
+	h.app.components.UserService.Wait()
+	h.expect(t, request{method: http.MethodGet, path: fmt.Sprintf("/admin/users/%d/restore", id), apiKey: "ops-key"}, http.StatusNotFound)
+	h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusUnauthorized)
+	purge("alice@example.com", http.StatusNotFound)
+}
+
+func TestE2E_ForcedPasswordReset(t *testing.T) {
+	h := newHarness(t, true, func(cfg *config.Config) {
+		cfg.AdminAPIKeys = map[string]string{"ops": "ops-key"}
+	})
+	alice := h.signup(t, "alice")
+	h.signup(t, "bob")
+	me := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK).json(t)
+
+	h.expect(t, request{
+		method: http.MethodPost, path: fmt.Sprintf("/admin/users/%v/force-password-reset", me["ID"]), apiKey: "ops-key",
+		body: map[string]string{},
+	}, http.StatusBadRequest)
+	bulk := h.expect(t, request{
+		method: http.MethodPost, path: "/admin/users/force-password-reset", apiKey: "ops-key",
+		body: map[string]interface{}{"user_ids": []interface{}{me["ID"], 999}, "reason": "credential stuffing"},
+	}, http.StatusOK).json(t)
+	if reset, _ := bulk["reset"].([]interface{}); len(reset) != 1 || reset[0] != me["ID"] {
+		t.Fatalf("unexpected bulk result %v", bulk)
+	}
+	if notFound, _ := bulk["not_found"].([]interface{}); len(notFound) != 1 || notFound[0] != float64(999) {
+		t.Errorf("unexpected bulk result %v", bulk)
+	}
+	h.app.components.UserService.Wait()
+	h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusUnauthorized)
+	// Revocation has second precision and covers tokens issued in the same second
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+
+	login := h.expect(t, request{
+		method: http.MethodPost, path: "/users/login", client: "10.0.7.1",
+		body: map[string]string{"email": "alice@example.com", "password": testPassword},
+	}, http.StatusOK).json(t)
+	session, _ := login["token"].(string)
+	if login["password_reset_required"] != true || session == "" {
+		t.Fatalf("expected a password reset session, got %v", login)
+	}
+	denied := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: session}, http.StatusForbidden).json(t)
+	if errorOf(denied)["code"] != "password_reset_required" {
+		t.Errorf("unexpected 403 body %v", denied)
+	}
+	h.expect(t, request{
+		method: http.MethodPut, path: "/users/update", token: session,
+		body: map[string]string{"first_name": "Mallory"},
+	}, http.StatusForbidden)
+	h.expect(t, request{
+		method: http.MethodPut, path: "/users/me/password", token: session,
+		body: map[string]string{"new_password": testPassword},
+	}, http.StatusBadRequest)
+	h.expect(t, request{
+		method: http.MethodPut, path: "/users/me/password", token: session,
+		body: map[string]string{"new_password": "An0ther-secret"},
+	}, http.StatusOK)
+	h.app.components.UserService.Wait()
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+
+	// The flag is gone, so the next login is an ordinary one
+	login = h.expect(t, request{
+		method: http.MethodPost, path: "/users/login", client: "10.0.7.2",
+		body: map[string]string{"email": "alice@example.com", "password": "An0ther-secret"},
+	}, http.StatusOK).json(t)
+	token, _ := login["token"].(string)
+	if login["password_reset_required"] != nil {
+		t.Errorf("expected an ordinary login, got %v", login)
+	}
+	h.expect(t, request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusOK)
+}
+
+func TestE2E_EndpointKillSwitch(t *testing.T) {
+	h := newHarness(t, false, func(cfg *config.Config) {
+		cfg.AdminAPIKeys = map[string]string{"ops": "ops-key"}
+	})
+	disable := func(body map[string]interface{}, status int) map[string]interface{} {
+		return h.expect(t, request{
+			method: http.MethodPost, path: "/admin/endpoints/disable", apiKey: "ops-key", body: body,
+		}, status).json(t)
+	}
+
+	// Only the router's own spelling of a pattern is accepted
+	for _, pattern := range []string{"/users/register/", "//users/register", "/nowhere"} {
+		resp := disable(map[string]interface{}{"pattern": pattern}, http.StatusBadRequest)
+		if fields, _ := errorOf(resp)["fields"].(map[string]interface{}); fields["pattern"] == nil {
+			t.Errorf("%s: expected a pattern error, got %v", pattern, resp)
+		}
+	}
+	disable(map[string]interface{}{"pattern": "/admin/endpoints/disable"}, http.StatusBadRequest)
+
+	disabled := disable(map[string]interface{}{
+		"pattern": "/users/register", "message": "Signups are paused during maintenance.", "ttl_seconds": 600,
+	}, http.StatusOK)
+	if disabled["pattern"] != "/users/register" {
+		t.Errorf("unexpected disable response %v", disabled)
+	}
+
+	signup := request{
+		method: http.MethodPost, path: "/users/register", client: "10.0.9.1",
+		body: map[string]string{"username": "alice", "email": "alice@example.com", "password": "Secret123!"},
+	}
+	resp := h.expect(t, signup, http.StatusServiceUnavailable)
+	if body := resp.json(t); errorOf(body)["code"] != "endpoint_disabled" || errorOf(body)["message"] != "Signups are paused during maintenance." {
+		t.Errorf("unexpected body %v", body)
+	}
+	if resp.header.Get("Retry-After") == "" {
+		t.Error("expected Retry-After for a timed disable")
+	}
+	signup.path = "//users/register"
+	h.expect(t, signup, http.StatusServiceUnavailable)
+	// The versioned route is the same endpoint
+	signup.path = "/v1/users/register"
+	h.expect(t, signup, http.StatusServiceUnavailable)
+
+	listed := h.expect(t, request{method: http.MethodGet, path: "/admin/endpoints/disable", apiKey: "ops-key"}, http.StatusOK).json(t)
+	if entries, _ := listed["disabled"].(map[string]interface{}); entries["/users/register"] == nil {
+		t.Errorf("expected the switch listed, got %v", listed)
+	}
+
+	h.expect(t, request{method: http.MethodDelete, path: "/admin/endpoints/disable?pattern=/users/register", apiKey: "ops-key"}, http.StatusNoContent)
+	signup.path = "/users/register"
+	h.expect(t, signup, http.StatusCreated)
+}
+
+func TestE2E_AccessTokens(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			h := newHarness(t, backend.withRedis)
+			session := h.signup(t, "alice")
+
+			created := h.expect(t, request{
+				method: http.MethodPost, path: "/users/me/tokens", token: session,
+				body: map[string]interface{}{"name": "backup script", "scopes": []string{"user:read"}},
+			}, http.StatusCreated).json(t)
+			pat, _ := created["token"].(string)
+			if !strings.HasPrefix(pat, "pat_") {
+				t.Fatalf("expected a pat_ token, got %v", created)
+			}
+			id := created["details"].(map[string]interface{})["id"].(float64)
+
+			// Read-only: the profile is readable, not editable
+			me := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: pat}, http.StatusOK).json(t)
+			if me["Email"] != "alice@example.com" {
+				t.Errorf("expected alice's profile, got %v", me)
+			}
+			denied := h.expect(t, request{
+				method: http.MethodPatch, path: "/users/me", token: pat,
+				body: map[string]string{"username": "mallory"},
+			}, http.StatusForbidden).json(t)
+			if errorOf(denied)["code"] != "insufficient_scope" {
+				t.Errorf("expected insufficient_scope, got %v", denied)
+			}
+			info := h.expect(t, request{method: http.MethodGet, path: "/users/me/token", token: pat}, http.StatusOK).json(t)
+			if info["jti"] != fmt.Sprintf("pat_%d", int(id)) {
+				t.Errorf("expected the token described, got %v", info)
+			}
+
+			writer := h.expect(t, request{
+				method: http.MethodPost, path: "/users/me/tokens", token: session,
+				body: map[string]interface{}{"name": "profile sync", "scopes": []string{"user:write"}},
+			}, http.StatusCreated).json(t)["token"].(string)
+			h.expect(t, request{
+				method: http.MethodPatch, path: "/users/me", token: writer,
+				body: map[string]string{"first_name": "Alice"},
+			}, http.StatusOK)
+
+			// A token can't manage tokens or other credentials, and that
+			// includes the email, which PUT /users/update changes
+			for _, req := range []request{
+				{method: http.MethodPut, path: "/users/update", token: writer, body: map[string]string{"email": "mallory@example.com"}},
+				{method: http.MethodGet, path: "/users/me/tokens", token: pat},
+				{method: http.MethodPost, path: "/users/me/recovery-codes", token: pat, body: map[string]string{"password": testPassword}},
+			} {
+				if body := h.expect(t, req, http.StatusForbidden).json(t); errorOf(body)["code"] != "access_token_not_allowed" {
+					t.Errorf("%s: expected access_token_not_allowed, got %v", req.path, body)
+				}
+			}
+
+			// Last use shows up once the batch is flushed
+			if err := h.app.components.tokenUsage.Flush(context.Background()); err != nil {
+				t.Fatalf("flush: %v", err)
+			}
+			listed := h.expect(t, request{method: http.MethodGet, path: "/users/me/tokens", token: session}, http.StatusOK).json(t)
+			tokens := listed["tokens"].([]interface{})
+			// Newest first: the read token is the older one
+			if len(tokens) != 2 || tokens[1].(map[string]interface{})["last_used_at"] == nil {
+				t.Fatalf("expected two used tokens, got %v", listed)
+			}
+			if _, leaked := tokens[1].(map[string]interface{})["token"]; leaked {
+				t.Error("the secret must not be listed")
+			}
+
+			h.expect(t, request{method: http.MethodDelete, path: fmt.Sprintf("/users/me/tokens/%d", int(id)), token: session}, http.StatusNoContent)
+			h.expect(t, request{method: http.MethodGet, path: "/users/me", token: pat}, http.StatusUnauthorized)
+			h.expect(t, request{method: http.MethodDelete, path: fmt.Sprintf("/users/me/tokens/%d", int(id)), token: session}, http.StatusNotFound)
+
+			// Logging out everywhere revokes the tokens too
+			h.expect(t, request{method: http.MethodGet, path: "/users/me", token: writer}, http.StatusOK)
+			h.expect(t, request{method: http.MethodPost, path: "/users/logout-all", token: session}, http.StatusNoContent)
+			h.app.components.UserService.Wait()
+			h.expect(t, request{method: http.MethodGet, path: "/users/me", token: writer}, http.StatusUnauthorized)
+		})
+	}
+}
+
+// withLocalStorage stores files under dir, downloadable through /files
+func withLocalStorage(dir string) func(*config.Config) {
+	return func(cfg *config.Config) {
+		cfg.StorageBackend = "local"
+		cfg.StorageLocalPath = dir
+		cfg.StorageURLSecret = "e2e-storage-secret-of-32-characters"
+	}
+}
+
+func TestE2E_FileDownloads(t *testing.T) {
+	h := newHarness(t, false, withLocalStorage(t.TempDir()))
+	store := h.app.components.Storage
+	ctx := context.Background()
+
+	if _, err := store.Put(ctx, "exports/1.json", strings.NewReader(`{"id":1}`), storage.PutOptions{ContentType: "application/json"}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	link, err := store.SignedURL(ctx, "exports/1.json", time.Minute)
+	if err != nil {
+		t.Fatalf("signed url: %v", err)
+	}
+
+	resp := h.expect(t, request{method: http.MethodGet, path: link}, http.StatusOK)
+	if string(resp.body) != `{"id":1}` || resp.header.Get("Content-Type") != "application/json" ||
+		resp.header.Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("unexpected download %q %v", resp.body, resp.header)
+	}
+
+	// The link is the credential: without it, or pointed elsewhere, nothing
+	h.expect(t, request{method: http.MethodGet, path: "/files/exports/1.json"}, http.StatusForbidden)
+	other := strings.Replace(link, "exports/1.json", "exports/2.json", 1)
+	h.expect(t, request{method: http.MethodGet, path: other}, http.StatusForbidden)
+
+	if err := store.Delete(ctx, "exports/1.json"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	h.expect(t, request{method: http.MethodGet, path: link}, http.StatusNotFound)
+}
+
+func TestE2E_BulkJobs(t *testing.T) {
+	h := newHarness(t, false, withLocalStorage(t.TempDir()), func(cfg *config.Config) {
+		cfg.AdminAPIKeys = map[string]string{"ops": "ops-key"}
+	})
+	alice := h.signup(t, "alice")
+	me := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK).json(t)
+
+	invalid := h.expect(t, request{
+		method: http.MethodPost, path: "/admin/users/bulk", apiKey: "ops-key",
+		body: map[string]interface{}{"action": "tag", "user_ids": []interface{}{me["ID"]}, "reason": "fraud"},
+	}, http.StatusBadRequest).json(t)
+	if fields, _ := errorOf(invalid)["fields"].(map[string]interface{}); fields["action"] == nil {
+		t.Errorf("expected the action refused, got %v", invalid)
+	}
+
+	resp := h.expect(t, request{
+		method: http.MethodPost, path: "/admin/users/bulk", apiKey: "ops-key",
+		body: map[string]interface{}{"action": "suspend", "user_ids": []interface{}{me["ID"], 999}, "reason": "fraud"},
+	}, http.StatusAccepted)
+	queued, _ := resp.json(t)["job"].(map[string]interface{})
+	location := resp.header.Get("Location")
+	if queued["status"] != "queued" || queued["total"] != float64(2) || location != fmt.Sprintf("/admin/jobs/%v", queued["id"]) {
+		t.Fatalf("unexpected queued job %v at %q", queued, location)
+	}
+
+	h.expect(t, request{method: http.MethodPost, path: "/admin/jobs/bulk_actions/run", apiKey: "ops-key"}, http.StatusOK)
+	h.app.components.UserService.Wait()
+
+	status := h.expect(t, request{method: http.MethodGet, path: location, apiKey: "ops-key"}, http.StatusOK).json(t)
+	job, _ := status["job"].(map[string]interface{})
+	failures, _ := job["failures"].([]interface{})
+	if job["status"] != "done" || job["succeeded"] != float64(1) || job["failed"] != float64(1) || len(failures) != 1 {
+		t.Fatalf("unexpected finished job %v", job)
+	}
+	// The suspension signs alice out, and she is listed as banned
+	h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusUnauthorized)
+	banned := h.expect(t, request{method: http.MethodGet, path: "/admin/users?status=banned", apiKey: "ops-key"}, http.StatusOK).json(t)
+	if items, _ := banned["items"].([]interface{}); len(items) != 1 || items[0].(map[string]interface{})["ID"] != me["ID"] {
+		t.Errorf("expected alice suspended, got %v", banned["items"])
+	}
+
+	link, _ := job["report_url"].(string)
+	report := h.expect(t, request{method: http.MethodGet, path: link}, http.StatusOK)
+	want := fmt.Sprintf("user_id,outcome,error\n%v,ok,\n999,failed,user not found\n", me["ID"])
+	if string(report.body) != want || report.header.Get("Content-Type") != "text/csv" {
+		t.Errorf("unexpected report %q %v", report.body, report.header)
+	}
+
+	h.expect(t, request{method: http.MethodGet, path: "/admin/jobs/12345", apiKey: "ops-key"}, http.StatusNotFound)
+	h.expect(t, request{method: http.MethodGet, path: location}, http.StatusUnauthorized)
+}
+
+func TestE2E_RefreshTokens(t *testing.T) {
+	h := newHarness(t, false)
+	access := h.signup(t, "alice")
+	logins := 0
+	login := func() map[string]interface{} {
+		t.Helper()
+		logins++
+		return h.expect(t, request{
+			method: http.MethodPost, path: "/users/login", client: fmt.Sprintf("10.0.9.%d", logins),
+			body: map[string]string{"email": "alice@example.com", "password": testPassword},
+		}, http.StatusOK).json(t)
+	}
+	refresh := func(token string, status int) map[string]interface{} {
+		t.Helper()
+		return h.expect(t, request{
+			method: http.MethodPost, path: "/users/refresh",
+			body: map[string]string{"refresh_token": token},
+		}, status).json(t)
+	}
+
+	first := login()
+	original, _ := first["refresh_token"].(string)
+	if !strings.HasPrefix(original, "rt_") || first["expires_in"] != float64(3600) || first["refresh_expires_in"] != float64(86400) {
+		t.Fatalf("expected a token pair from login, got %v", first)
+	}
+
+	rotated := refresh(original, http.StatusOK)
+	next, _ := rotated["refresh_token"].(string)
+	token, _ := rotated["token"].(string)
+	if next == "" || next == original || token == "" {
+		t.Fatalf("expected a new pair, got %v", rotated)
+	}
+	h.expect(t, request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusOK)
+
+	// Presenting the exchanged token again revokes the whole login
+	if reused := refresh(original, http.StatusUnauthorized); errorOf(reused)["code"] != "refresh_token_reused" {
+		t.Errorf("expected the reuse called out, got %v", reused)
+	}
+	refresh(next, http.StatusUnauthorized)
+
+	// A client can sign one login out with its refresh token
+	second, _ := login()["refresh_token"].(string)
+	h.expect(t, request{
+		method: http.MethodPost, path: "/users/refresh/revoke",
+		body: map[string]string{"refresh_token": second},
+	}, http.StatusNoContent)
+	refresh(second, http.StatusUnauthorized)
+
+	// Changing the password signs out every login
+	third, _ := login()["refresh_token"].(string)
+	h.expect(t, request{
+		method: http.MethodPut, path: "/users/me/password", token: access,
+		body: map[string]string{"current_password": testPassword, "new_password": "An0ther-secret"},
+	}, http.StatusOK)
+	h.app.components.UserService.Wait()
+	refresh(third, http.StatusUnauthorized)
+
+	refresh("rt_unknown", http.StatusUnauthorized)
+	refresh("", http.StatusBadRequest)
+	resp := h.expect(t, request{method: http.MethodGet, path: "/users/refresh/revoke"}, http.StatusMethodNotAllowed)
+	if allow := resp.header.Get("Allow"); allow != http.MethodPost {
+		t.Errorf("expected Allow: POST, got %q", allow)
+	}
+}
+
+func TestE2E_RememberMe(t *testing.T) {
+	h := newHarness(t, false)
+	h.signup(t, "alice")
+	cfg := testConfig()
+	verifier := auth.NewJWTManager(cfg.JWTSecret, cfg.JWTExpire)
+
+	tests := []struct {
+		name       string
+		rememberMe bool
+		ttl        time.Duration
+	}{
+		{"default", false, cfg.JWTExpire},
+		{"remember me", true, cfg.JWTRememberMeExpire},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now().Truncate(time.Second)
+			session := h.expect(t, request{
+				method: http.MethodPost, path: "/users/login", client: fmt.Sprintf("10.0.10.%d", i+1),
+				body: map[string]interface{}{"email": "alice@example.com", "password": testPassword, "remember_me": tt.rememberMe},
+			}, http.StatusOK).json(t)
+
+			if session["expires_in"] != float64(tt.ttl/time.Second) {
+				t.Errorf("expected expires_in %v, got %v", tt.ttl.Seconds(), session["expires_in"])
+			}
+			raw, _ := session["expires_at"].(string)
+			expiresAt, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				t.Fatalf("expected expires_at, got %v", session)
+			}
+			if expiresAt.Before(before.Add(tt.ttl)) || expiresAt.After(time.Now().Add(tt.ttl)) {
+				t.Errorf("expected expires_at %s from now, got %s", tt.ttl, expiresAt)
+			}
+
+			token, _ := session["token"].(string)
+			claims, err := verifier.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if !claims.ExpiresAt.Time.Equal(expiresAt) {
+				t.Errorf("expected the token to expire at %s, got %s", expiresAt, claims.ExpiresAt.Time)
+			}
+			h.expect(t, request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusOK)
+		})
+	}
+}
+
+func TestE2E_ForgotPassword(t *testing.T) {
+	h := newHarness(t, true)
+	old := h.signup(t, "alice")
+	forgot := func(email string, status int) map[string]interface{} {
+		t.Helper()
+		return h.expect(t, request{
+			method: http.MethodPost, path: "/users/forgot-password",
+			body: map[string]string{"email": email},
+		}, status).json(t)
+	}
+
+	// Unknown emails get the same answer
+	unknown := forgot("nobody@example.com", http.StatusAccepted)
+	known := forgot("alice@example.com", http.StatusAccepted)
+	if unknown["message"] != known["message"] {
+		t.Errorf("expected the same answer for any email, got %v and %v", unknown, known)
+	}
+	h.app.components.UserService.Wait()
+	token := h.resets.token("alice@example.com")
+	if token == "" {
+		t.Fatal("expected a reset token sent")
+	}
+
+	reset := func(token, password string, status int) map[string]interface{} {
+		t.Helper()
+		return h.expect(t, request{
+			method: http.MethodPost, path: "/users/reset-password",
+			body: map[string]string{"token": token, "new_password": password},
+		}, status).json(t)
+	}
+	if weak := reset(token, "short", http.StatusBadRequest); errorOf(weak)["fields"] == nil {
+		t.Errorf("expected the password policy enforced, got %v", weak)
+	}
+	newPassword := "N3w-" + testPassword
+	reset(token, newPassword, http.StatusOK)
+	if used := reset(token, "An0ther-secret", http.StatusBadRequest); errorOf(used)["code"] != "invalid_reset_token" {
+		t.Errorf("expected a used token refused, got %v", used)
+	}
+	h.app.components.UserService.Wait()
+
+	// Signed out everywhere; only the new password works
+	h.expect(t, request{method: http.MethodGet, path: "/users/me", token: old}, http.StatusUnauthorized)
+	h.expect(t, request{
+		method: http.MethodPost, path: "/users/login",
+		body: map[string]string{"email": "alice@example.com", "password": testPassword},
+	}, http.StatusUnauthorized)
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	h.expect(t, request{
+		method: http.MethodPost, path: "/users/login", client: "10.0.11.1",
+		body: map[string]string{"email": "alice@example.com", "password": newPassword},
+	}, http.StatusOK)
+
+	// Three reset emails an hour per address, however it is written
+	forgot("alice@example.com", http.StatusAccepted)
+	forgot("alice@example.com", http.StatusAccepted)
+	forgot("Alice@Example.com", http.StatusTooManyRequests)
+	forgot("bob@example.com", http.StatusAccepted)
+}
+
+func TestE2E_ChangeEmail(t *testing.T) {
+	h := newHarness(t, true)
+	token := h.signup(t, "alice")
+	change := func(email, password string, status int) map[string]interface{} {
+		t.Helper()
+		return h.expect(t, request{
+			method: http.MethodPost, path: "/users/me/email", token: token,
+			body: map[string]string{"email": email, "password": password},
+		}, status).json(t)
+	}
+	confirm := func(token string, status int) map[string]interface{} {
+		t.Helper()
+		return h.expect(t, request{
+			method: http.MethodGet, path: "/users/confirm-email?token=" + token,
+		}, status).json(t)
+	}
+	me := func() string {
+		t.Helper()
+		user := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusOK).json(t)
+		email, _ := user["Email"].(string)
+		return email
+	}
+
+	change("alice.new@example.com", "wrong-password", http.StatusBadRequest)
+	change("alice.first@example.com", testPassword, http.StatusAccepted)
+	change("alice.new@example.com", testPassword, http.StatusAccepted)
+	if email := me(); email != "alice@example.com" {
+		t.Fatalf("expected the email kept until confirmed, got %s", email)
+	}
+
+	// Asking again voids the first link
+	if replaced := confirm(h.resets.token("alice.first@example.com"), http.StatusBadRequest); errorOf(replaced)["code"] != "invalid_email_change_token" {
+		t.Errorf("expected the replaced token refused, got %v", replaced)
+	}
+	if confirmed := confirm(h.resets.token("alice.new@example.com"), http.StatusOK); confirmed["user"] != nil {
+		t.Errorf("expected the unauthenticated link to leave the account out, got %v", confirmed)
+	}
+	confirm(h.resets.token("alice.new@example.com"), http.StatusBadRequest)
+	h.app.components.UserService.Wait()
+	if email := me(); email != "alice.new@example.com" {
+		t.Errorf("expected the new email, got %s", email)
+	}
+	h.expect(t, request{
+		method: http.MethodPost, path: "/users/login",
+		body: map[string]string{"email": "alice.new@example.com", "password": testPassword},
+	}, http.StatusOK)
+
+	// Someone signs up with the address while the change is pending
+	change("taken@example.com", testPassword, http.StatusAccepted)
+	h.signup(t, "taken")
+	confirm(h.resets.token("taken@example.com"), http.StatusConflict)
+	if email := me(); email != "alice.new@example.com" {
+		t.Errorf("expected the email unchanged, got %s", email)
+	}
+	change("taken@example.com", testPassword, http.StatusConflict)
+}
+
+func TestE2E_Logout(t *testing.T) {
+	h := newHarness(t, true)
+	h.signup(t, "alice")
+	login := func() (string, string) {
+		t.Helper()
+		pair := h.expect(t, request{
+			method: http.MethodPost, path: "/users/login",
+			body: map[string]string{"email": "alice@example.com", "password": testPassword},
+		}, http.StatusOK).json(t)
+		token, _ := pair["token"].(string)
+		refreshToken, _ := pair["refresh_token"].(string)
+		return token, refreshToken
+	}
+
+	token, refreshToken := login()
+	other, _ := login()
+	h.expect(t, request{
+		method: http.MethodPost, path: "/users/logout", token: token,
+		body: map[string]string{"refresh_token": refreshToken},
+	}, http.StatusNoContent)
+
+	// The token and its refresh token are done; the other login isn't
+	h.expect(t, request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusUnauthorized)
+	h.expect(t, request{method: http.MethodPost, path: "/users/logout", token: token}, http.StatusUnauthorized)
+	h.expect(t, request{
+		method: http.MethodPost, path: "/users/refresh",
+		body: map[string]string{"refresh_token": refreshToken},
+	}, http.StatusUnauthorized)
+	h.expect(t, request{method: http.MethodGet, path: "/users/me", token: other}, http.StatusOK)
+
+	// The body is optional
+	h.expect(t, request{method: http.MethodPost, path: "/users/logout", token: other}, http.StatusNoContent)
+	h.expect(t, request{method: http.MethodGet, path: "/users/me", token: other}, http.StatusUnauthorized)
+	h.expect(t, request{method: http.MethodPost, path: "/users/logout"}, http.StatusUnauthorized)
+}
+
+func TestE2E_LogoutAll(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			h := newHarness(t, backend.withRedis)
+			first := h.signup(t, "alice")
+			login := h.expect(t, request{
+				method: http.MethodPost, path: "/users/login",
+				body: map[string]string{"email": "alice@example.com", "password": testPassword},
+			}, http.StatusOK).json(t)
+			second, _ := login["token"].(string)
+			refreshToken, _ := login["refresh_token"].(string)
+			user, _ := login["user"].(map[string]interface{})
+
+			h.expect(t, request{method: http.MethodPost, path: "/users/logout-all", token: first}, http.StatusNoContent)
+			h.app.components.UserService.Wait()
+
+			// Every device is signed out, without Redis too
+			for _, token := range []string{first, second} {
+				h.expect(t, request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusUnauthorized)
+			}
+			h.expect(t, request{
+				method: http.MethodPost, path: "/users/refresh",
+				body: map[string]string{"refresh_token": refreshToken},
+			}, http.StatusUnauthorized)
+
+			if h.redis != nil {
+				key := fmt.Sprintf("user:token_version:%v", user["id"])
+				if version, err := h.redis.Get(key); err != nil || version != "1" {
+					t.Errorf("expected version 1 cached under %s, got %q (%v)", key, version, err)
+				}
+				// Revocation has second precision and covers tokens issued in the same second
+				time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+			}
+
+			// A new login works, and saving the profile doesn't bring the
+			// old version back
+			token := h.login(t, "alice", "10.0.5.1")
+			h.expect(t, request{
+				method: http.MethodPut, path: "/users/update", token: token,
+				body: map[string]string{"first_name": "Alice"},
+			}, http.StatusOK)
+			h.app.components.UserService.Wait()
+			h.expect(t, request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusOK)
+			h.expect(t, request{method: http.MethodGet, path: "/users/me", token: second}, http.StatusUnauthorized)
+		})
+	}
+}
+
+func TestE2E_ChangePassword(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			h := newHarness(t, backend.withRedis)
+			first := h.signup(t, "alice")
+			second := h.login(t, "alice", "10.0.12.1")
+			// Cached under both keys
+			me := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: first}, http.StatusOK).json(t)
+			change := func(current, next string, status int) map[string]interface{} {
+				t.Helper()
+				return h.expect(t, request{
+					method: http.MethodPut, path: "/users/me/password", token: first,
+					body: map[string]string{"current_password": current, "new_password": next},
+				}, status).json(t)
+			}
+
+			if same := change(testPassword, testPassword, http.StatusBadRequest); errorOf(same)["fields"] == nil {
+				t.Errorf("expected the current password refused as the new one, got %v", same)
+			}
+			change(testPassword, "N3w-"+testPassword, http.StatusOK)
+			h.app.components.UserService.Wait()
+
+			// Every session is signed out, without Redis too
+			for _, token := range []string{first, second} {
+				h.expect(t, request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusUnauthorized)
+			}
+			if h.redis != nil {
+				for _, key := range []string{fmt.Sprintf("user:id:%v", me["id"]), "user:email:alice@example.com"} {
+					if h.redis.Exists(key) {
+						t.Errorf("expected %s cleared", key)
+					}
+				}
+				// Revocation has second precision and covers tokens issued in the same second
+				time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+			}
+			h.expect(t, request{
+				method: http.MethodPost, path: "/users/login", client: "10.0.12.2",
+				body: map[string]string{"email": "alice@example.com", "password": "N3w-" + testPassword},
+			}, http.StatusOK)
+		})
+	}
+}
+
+func TestE2E_Sessions(t *testing.T) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			h := newHarness(t, backend.withRedis)
+			h.signup(t, "alice")
+			bob := h.signup(t, "bob")
+			login := func(client string) map[string]interface{} {
+				t.Helper()
+				return h.expect(t, request{
+					method: http.MethodPost, path: "/users/login", client: client,
+					body: map[string]string{"email": "alice@example.com", "password": testPassword},
+				}, http.StatusOK).json(t)
+			}
+			laptop, phone := login("10.0.13.1"), login("10.0.13.2")
+			laptopToken, _ := laptop["token"].(string)
+			phoneToken, _ := phone["token"].(string)
+			sessions := func() map[string]map[string]interface{} {
+				t.Helper()
+				body := h.expect(t, request{method: http.MethodGet, path: "/users/me/sessions", token: laptopToken}, http.StatusOK).json(t)
+				byIP := make(map[string]map[string]interface{})
+				list, _ := body["sessions"].([]interface{})
+				for _, item := range list {
+					session, _ := item.(map[string]interface{})
+					ip, _ := session["ip"].(string)
+					byIP[ip] = session
+				}
+				return byIP
+			}
+
+			listed := sessions()
+			mine, other := listed["10.0.13.1"], listed["10.0.13.2"]
+			if mine == nil || other == nil {
+				t.Fatalf("expected both logins listed, got %v", listed)
+			}
+			if mine["current"] != true || other["current"] != false || other["user_agent"] == "" || other["last_used"] == nil {
+				t.Errorf("unexpected session details: %v and %v", mine, other)
+			}
+			phoneSession, _ := other["id"].(string)
+
+			// Someone else's session is as good as unknown
+			h.expect(t, request{method: http.MethodDelete, path: "/users/me/sessions/" + phoneSession, token: bob}, http.StatusNotFound)
+			h.expect(t, request{method: http.MethodDelete, path: "/users/me/sessions/" + phoneSession, token: laptopToken}, http.StatusOK)
+			h.app.components.UserService.Wait()
+			h.expect(t, request{method: http.MethodGet, path: "/users/me", token: phoneToken}, http.StatusUnauthorized)
+			ended := h.expect(t, request{
+				method: http.MethodPost, path: "/users/refresh",
+				body: map[string]interface{}{"refresh_token": phone["refresh_token"]},
+			}, http.StatusUnauthorized).json(t)
+			if errorOf(ended)["code"] != "session_ended" {
+				t.Errorf("expected the revoked session's refresh refused, got %v", ended)
+			}
+			if _, listed := sessions()["10.0.13.2"]; listed {
+				t.Error("expected the revoked session gone from the list")
+			}
+			h.expect(t, request{method: http.MethodDelete, path: "/users/me/sessions/" + phoneSession, token: laptopToken}, http.StatusNotFound)
+			h.expect(t, request{method: http.MethodGet, path: "/users/me", token: bob}, http.StatusOK)
+
+			// Refreshing keeps the session, and its ID
+			refreshed := h.expect(t, request{
+				method: http.MethodPost, path: "/users/refresh",
+				body: map[string]interface{}{"refresh_token": laptop["refresh_token"]},
+			}, http.StatusOK).json(t)
+			laptopToken, _ = refreshed["token"].(string)
+			laptopSession, _ := sessions()["10.0.13.1"]["id"].(string)
+			if laptopSession != mine["id"] {
+				t.Errorf("expected the refresh to stay in session %v, got %q", mine["id"], laptopSession)
+			}
+
+			// Revoking the current session signs it out
+			h.expect(t, request{method: http.MethodDelete, path: "/users/me/sessions/" + laptopSession, token: laptopToken}, http.StatusOK)
+			h.app.components.UserService.Wait()
+			h.expect(t, request{method: http.MethodGet, path: "/users/me", token: laptopToken}, http.StatusUnauthorized)
+		})
+	}
+}
+
+func TestE2E_Preferences(t *testing.T) {
+	h := newHarness(t, true)
+	token := h.signup(t, "alice")
+	// Cache the account before changing it
+	h.expect(t, request{method: http.MethodGet, path: "/users/me/preferences", token: token}, http.StatusOK)
+
+	h.expect(t, request{
+		method: http.MethodPatch, path: "/users/me/preferences", token: token,
+		body: map[string]interface{}{"theme": "dark", "accessibility": map[string]bool{"reduced_motion": true}},
+	}, http.StatusOK)
+	h.expect(t, request{
+		method: http.MethodPatch, path: "/users/me/preferences", token: token,
+		body: map[string]interface{}{"locale": "fr", "marketing": true, "accessibility": map[string]bool{"high_contrast": true}},
+	}, http.StatusOK)
+	h.app.components.UserService.Wait()
+
+	prefs := h.expect(t, request{method: http.MethodGet, path: "/users/me/preferences", token: token}, http.StatusOK).json(t)
+	accessibility, _ := prefs["accessibility"].(map[string]interface{})
+	if prefs["theme"] != "dark" || prefs["locale"] != "fr" || prefs["marketing"] != true ||
+		accessibility["reduced_motion"] != true || accessibility["high_contrast"] != true {
+		t.Errorf("expected both patches merged, got %v", prefs)
+	}
+
+	rejected := h.expect(t, request{
+		method: http.MethodPatch, path: "/users/me/preferences", token: token,
+		body: map[string]string{"font": "serif"},
+	}, http.StatusBadRequest).json(t)
+	if message, _ := errorOf(rejected)["message"].(string); !strings.Contains(message, "locale") {
+		t.Errorf("expected the accepted keys listed, got %v", rejected)
+	}
+}
+
+func TestE2E_PatchCurrentUser(t *testing.T) {
+	h := newHarness(t, true)
+	token := h.signup(t, "alice")
+	h.expect(t, request{
+		method: http.MethodPut, path: "/users/update", token: token,
+		body: map[string]string{"first_name": "Alice", "last_name": "Smith"},
+	}, http.StatusOK)
+
+	// PUT can't clear a field; PATCH with null can
+	patched := h.expect(t, request{
+		method: http.MethodPatch, path: "/users/me", token: token,
+		body: map[string]interface{}{"last_name": nil},
+	}, http.StatusOK).json(t)
+	if changed, _ := patched["changed"].([]interface{}); len(changed) != 1 || changed[0] != "last_name" {
+		t.Errorf("expected last_name changed, got %v", patched)
+	}
+	me := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusOK).json(t)
+	if me["LastName"] != "" || me["FirstName"] != "Alice" {
+		t.Errorf("expected last name cleared, got %v", me)
+	}
+
+	rejected := h.expect(t, request{
+		method: http.MethodPatch, path: "/users/me", token: token,
+		body: map[string]string{"email": "new@example.com"},
+	}, http.StatusBadRequest).json(t)
+	if fields, _ := errorOf(rejected)["fields"].(map[string]interface{}); fields["email"] == nil {
+		t.Errorf("expected an email field error, got %v", rejected)
+	}
+	h.expect(t, request{method: http.MethodPatch, path: "/users/me", body: map[string]string{}}, http.StatusUnauthorized)
+}
+
+func TestE2E_Notices(t *testing.T) {
+	h := newHarness(t, false, func(cfg *config.Config) {
+		cfg.AdminAPIKeys = map[string]string{"ops": "ops-key"}
+		cfg.TermsVersion = "2024-06"
+	})
+	alice := h.signup(t, "alice")
+	codes := func() []string {
+		t.Helper()
+		me := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK).json(t)
+		notices, _ := me["Notices"].([]interface{})
+		var codes []string
+		for _, n := range notices {
+			notice, _ := n.(map[string]interface{})
+			codes = append(codes, fmt.Sprint(notice["code"]))
+		}
+		return codes
+	}
+	if got := codes(); !slices.Equal(got, []string{"verify_email", "terms_updated"}) {
+		t.Fatalf("unexpected notices %v", got)
+	}
+
+	me := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: alice}, http.StatusOK).json(t)
+	noticesPath := fmt.Sprintf("/admin/users/%v/notices", me["ID"])
+	h.expect(t, request{
+		method: http.MethodPost, path: noticesPath, apiKey: "ops-key",
+		body: map[string]interface{}{"code": "billing_issue", "severity": "critical", "message": "Update your card"},
+	}, http.StatusCreated)
+	h.expect(t, request{
+		method: http.MethodPost, path: noticesPath, apiKey: "ops-key",
+		body: map[string]interface{}{"code": "verify_email", "message": "Spoofed"},
+	}, http.StatusBadRequest)
+
+	h.expect(t, request{method: http.MethodPost, path: "/users/me/notices/terms_updated/dismiss", token: alice}, http.StatusNoContent)
+	h.expect(t, request{method: http.MethodPost, path: "/users/me/notices/billing_issue/dismiss", token: alice}, http.StatusConflict)
+	h.expect(t, request{method: http.MethodPost, path: "/users/me/notices/nope/dismiss", token: alice}, http.StatusNotFound)
+	if got := codes(); !slices.Equal(got, []string{"billing_issue", "verify_email"}) {
+		t.Fatalf("unexpected notices after dismissal %v", got)
+	}
+
+	h.expect(t, request{method: http.MethodDelete, path: noticesPath + "/billing_issue", apiKey: "ops-key"}, http.StatusNoContent)
+	h.expect(t, request{method: http.MethodDelete, path: noticesPath + "/billing_issue", apiKey: "ops-key"}, http.StatusNotFound)
+	if got := codes(); !slices.Equal(got, []string{"verify_email"}) {
+		t.Errorf("unexpected notices after removal %v", got)
+	}
+}
@@ -0,0 +1,170 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"user-service/internal/infrastructure/redis"
+	"user-service/internal/interfaces/http/respond"
+
+	"gorm.io/gorm"
+)
+
+const (
+	livezPath = "/livez"
+
+	// healthCheckTimeout bounds each dependency ping
+	healthCheckTimeout = 2 * time.Second
+)
+
+// Dependency states reported by /health
+const (
+	ServiceUp            = "up"
+	ServiceDown          = "down"
+	ServiceNotConfigured = "not configured"
+	// ServiceSkipped is a startup check that couldn't run because one it
+	// depends on failed
+	ServiceSkipped = "skipped"
+)
+
+// Overall states of /health and SelfCheck
+const (
+	StatusHealthy   = "healthy"
+	StatusUnhealthy = "unhealthy"
+)
+
+// HealthResponse is the body of /health
+type HealthResponse struct {
+	Status    string                   `json:"status"` // StatusHealthy or StatusUnhealthy
+	Timestamp time.Time                `json:"timestamp"`
+	Services  map[string]ServiceHealth `json:"services"`
+}
+
+// ServiceHealth is the most recent check of one dependency
+type ServiceHealth struct {
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+}
+
+// LivenessResponse is the body of /livez
+type LivenessResponse struct {
+	Status string `json:"status"`
+}
+
+// livez only proves the server is serving, so container and load balancer
+// probes don't touch Postgres or Redis
+func livez(w http.ResponseWriter, r *http.Request) {
+	respond.JSON(w, http.StatusOK, LivenessResponse{Status: "ok"})
+}
+
+// cachedCheck runs check at most once per ttl. Probes arriving while a
+// check is running wait for it and share its result, so a burst of probes
+// costs one ping.
+type cachedCheck struct {
+	check func(ctx context.Context) error
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu   sync.Mutex
+	last *ServiceHealth
+}
+
+func newCachedCheck(ttl time.Duration, check func(ctx context.Context) error) *cachedCheck {
+	return &cachedCheck{check: check, ttl: ttl, now: time.Now}
+}
+
+func (c *cachedCheck) result(ctx context.Context) ServiceHealth {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.last != nil && c.now().Sub(*c.last.CheckedAt) < c.ttl {
+		return *c.last
+	}
+
+	// The result is shared, so one probe disconnecting mustn't fail it
+	checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), healthCheckTimeout)
+	err := c.check(checkCtx)
+	cancel()
+
+	checkedAt := c.now().UTC()
+	health := ServiceHealth{Status: ServiceUp, CheckedAt: &checkedAt}
+	if err != nil {
+		health.Status, health.Error = ServiceDown, err.Error()
+	}
+	c.last = &health
+	return health
+}
+
+// pingDatabase is the database check behind both /health and SelfCheck
+func pingDatabase(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// optionalServices don't decide the overall status: the service degrades
+// gracefully without them
+var optionalServices = map[string]bool{
+	"redis": true,
+}
+
+// overallStatus is healthy when every required check is up. /health and
+// SelfCheck both use it so the two can't disagree.
+func overallStatus(services map[string]ServiceHealth) string {
+	for name, health := range services {
+		if health.Status != ServiceUp && !optionalServices[name] {
+			return StatusUnhealthy
+		}
+	}
+	return StatusHealthy
+}
+
+// healthChecker serves /health. Only the database decides the overall
+// status; the service degrades gracefully without Redis.
+type healthChecker struct {
+	database *cachedCheck
+	// redis is nil when Redis isn't configured
+	redis *cachedCheck
+	now   func() time.Time
+}
+
+func newHealthChecker(db *gorm.DB, redisClient *redis.RedisClient, cacheTTL time.Duration) *healthChecker {
+	h := &healthChecker{
+		database: newCachedCheck(cacheTTL, pingDatabase(db)),
+		now:      time.Now,
+	}
+	if redisClient != nil {
+		h.redis = newCachedCheck(cacheTTL, redisClient.Ping)
+	}
+	return h
+}
+
+func (h *healthChecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	database := h.database.result(r.Context())
+	redisHealth := ServiceHealth{Status: ServiceNotConfigured}
+	if h.redis != nil {
+		redisHealth = h.redis.result(r.Context())
+	}
+
+	resp := HealthResponse{
+		Timestamp: h.now().UTC(),
+		Services: map[string]ServiceHealth{
+			"database": database,
+			"redis":    redisHealth,
+		},
+	}
+	resp.Status = overallStatus(resp.Services)
+	statusCode := http.StatusOK
+	if resp.Status != StatusHealthy {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	respond.JSON(w, statusCode, resp)
+}
@@ -0,0 +1,190 @@
+// internal/app/health_test.go
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"user-service/internal/infrastructure/redis"
+	"user-service/internal/testsupport"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// countingCheck returns a check that counts its calls and fails with err
+func countingCheck(calls *atomic.Int32, err *error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		calls.Add(1)
+		return *err
+	}
+}
+
+func TestCachedCheck_ReusesResultWithinWindow(t *testing.T) {
+	clock := testsupport.NewClock()
+	var calls atomic.Int32
+	var checkErr error
+	check := newCachedCheck(5*time.Second, countingCheck(&calls, &checkErr))
+	check.now = clock.Now
+	ctx := context.Background()
+
+	first := check.result(ctx)
+	if first.Status != ServiceUp || first.CheckedAt == nil || !first.CheckedAt.Equal(clock.Now()) {
+		t.Fatalf("unexpected first result %+v", first)
+	}
+
+	clock.Advance(4 * time.Second)
+	checkErr = errors.New("connection refused")
+	if got := check.result(ctx); got.Status != ServiceUp || calls.Load() != 1 {
+		t.Fatalf("expected the cached result inside the window, got %+v after %d calls", got, calls.Load())
+	}
+
+	clock.Advance(time.Second)
+	got := check.result(ctx)
+	if got.Status != ServiceDown || got.Error != "connection refused" || calls.Load() != 2 {
+		t.Fatalf("expected a fresh failing check once the window passed, got %+v after %d calls", got, calls.Load())
+	}
+
+	// Failures are cached too, so a down database isn't hammered
+	clock.Advance(time.Second)
+	if check.result(ctx); calls.Load() != 2 {
+		t.Errorf("expected the failure to be cached, got %d calls", calls.Load())
+	}
+}
+
+func TestCachedCheck_ZeroTTLChecksEveryTime(t *testing.T) {
+	var calls atomic.Int32
+	var checkErr error
+	check := newCachedCheck(0, countingCheck(&calls, &checkErr))
+
+	for i := 0; i < 3; i++ {
+		check.result(context.Background())
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 checks, got %d", calls.Load())
+	}
+}
+
+func TestCachedCheck_ConcurrentProbesShareOnePing(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	check := newCachedCheck(time.Minute, func(ctx context.Context) error {
+		calls.Add(1)
+		<-release
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := check.result(context.Background()); got.Status != ServiceUp {
+				t.Errorf("unexpected result %+v", got)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("expected one ping for concurrent probes, got %d", calls.Load())
+	}
+}
+
+func TestCachedCheck_IgnoresProbeCancellation(t *testing.T) {
+	check := newCachedCheck(time.Minute, func(ctx context.Context) error {
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if got := check.result(ctx); got.Status != ServiceUp {
+		t.Errorf("a disconnected probe must not poison the shared result, got %+v", got)
+	}
+}
+
+func TestHealthChecker_Status(t *testing.T) {
+	dbErr := errors.New("database is down")
+	cases := []struct {
+		name       string
+		dbErr      error
+		redis      bool
+		redisErr   error
+		wantCode   int
+		wantStatus string
+		wantRedis  string
+	}{
+		{"all up", nil, true, nil, http.StatusOK, "healthy", ServiceUp},
+		{"no redis", nil, false, nil, http.StatusOK, "healthy", ServiceNotConfigured},
+		{"redis down degrades only", nil, true, errors.New("refused"), http.StatusOK, "healthy", ServiceDown},
+		{"database down", dbErr, true, nil, http.StatusServiceUnavailable, "unhealthy", ServiceUp},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbCheckErr, redisCheckErr := tc.dbErr, tc.redisErr
+			var calls atomic.Int32
+			h := &healthChecker{
+				database: newCachedCheck(time.Minute, countingCheck(&calls, &dbCheckErr)),
+				now:      time.Now,
+			}
+			if tc.redis {
+				h.redis = newCachedCheck(time.Minute, countingCheck(&calls, &redisCheckErr))
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			if rec.Code != tc.wantCode {
+				t.Fatalf("expected %d, got %d", tc.wantCode, rec.Code)
+			}
+			var body HealthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode %q: %v", rec.Body, err)
+			}
+			if body.Status != tc.wantStatus || body.Services["redis"].Status != tc.wantRedis {
+				t.Errorf("unexpected body %+v", body)
+			}
+			if tc.dbErr != nil && body.Services["database"].Error != tc.dbErr.Error() {
+				t.Errorf("expected the database error to be reported, got %+v", body.Services["database"])
+			}
+		})
+	}
+}
+
+func TestLivez_SkipsRedisBackedMiddleware(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient, err := redis.NewRedisClient(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("redis: %v", err)
+	}
+	defer redisClient.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(livezPath, livez)
+	handler, _ := applyGlobalMiddleware(mux, mux, redisClient, testConfig(), nil)
+
+	before := mr.CommandCount()
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, livezPath, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		var body LivenessResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Status != "ok" {
+			t.Errorf("unexpected body %q (%v)", rec.Body, err)
+		}
+	}
+	if n := mr.CommandCount() - before; n != 0 {
+		t.Errorf("expected liveness probes not to touch Redis, got %d commands", n)
+	}
+}
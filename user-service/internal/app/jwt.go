@@ -0,0 +1,64 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/infrastructure/auth"
+)
+
+// newJWTManager builds the token manager for cfg.JWTAlgorithm, reading the
+// key files the asymmetric algorithms sign with
+func newJWTManager(cfg *config.Config, opts ...auth.JWTOption) (*auth.JWTManager, error) {
+	opts = append(opts, auth.WithMaxExpiry(cfg.MaxTokenTTL()))
+	if cfg.JWTIssuer != "" {
+		opts = append(opts, auth.WithIssuer(cfg.JWTIssuer))
+	}
+	if len(cfg.JWTAudience) > 0 {
+		opts = append(opts, auth.WithAudience(cfg.JWTAudience...))
+	}
+	switch cfg.JWTAlgorithm {
+	case "", auth.AlgorithmHS256:
+		if len(cfg.JWTSecrets) > 0 {
+			return auth.NewJWTManagerHMAC(hmacKeys(cfg), cfg.JWTExpire, opts...)
+		}
+		return auth.NewJWTManager(cfg.JWTSecret, cfg.JWTExpire, opts...), nil
+	case auth.AlgorithmRS256, auth.AlgorithmEdDSA:
+	default:
+		return nil, fmt.Errorf("unknown JWT algorithm %q", cfg.JWTAlgorithm)
+	}
+
+	private, err := os.ReadFile(cfg.JWTPrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT private key: %w", err)
+	}
+	var public []byte
+	if cfg.JWTPublicKeyPath != "" {
+		if public, err = os.ReadFile(cfg.JWTPublicKeyPath); err != nil {
+			return nil, fmt.Errorf("failed to read JWT public key: %w", err)
+		}
+	}
+	if cfg.JWTAlgorithm == auth.AlgorithmRS256 {
+		return auth.NewJWTManagerRS256(private, public, cfg.JWTExpire, opts...)
+	}
+	return auth.NewJWTManagerEdDSA(private, public, cfg.JWTExpire, opts...)
+}
+
+// hmacKeys retires every key but the first JWTSecretGracePeriod after the
+// rotation, counting from now when its time wasn't given
+func hmacKeys(cfg *config.Config) []auth.HMACKey {
+	rotatedAt := cfg.JWTSecretsRotatedAt
+	if rotatedAt.IsZero() {
+		rotatedAt = time.Now()
+	}
+	keys := make([]auth.HMACKey, len(cfg.JWTSecrets))
+	for i, key := range cfg.JWTSecrets {
+		keys[i] = auth.HMACKey{ID: key.ID, Secret: key.Secret}
+		if i > 0 {
+			keys[i].RetiresAt = rotatedAt.Add(cfg.JWTSecretGracePeriod)
+		}
+	}
+	return keys
+}
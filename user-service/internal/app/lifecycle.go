@@ -0,0 +1,311 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/infrastructure/postgres"
+	"user-service/internal/infrastructure/redis"
+
+	"gorm.io/gorm"
+)
+
+// Server timeouts
+const (
+	readTimeout  = 15 * time.Second
+	writeTimeout = 15 * time.Second
+	idleTimeout  = 60 * time.Second
+
+	// DefaultShutdownTimeout bounds how long Run waits for in-flight
+	// requests and background workers once its context is cancelled
+	DefaultShutdownTimeout = 10 * time.Second
+)
+
+// App owns the process lifecycle: the connections it opened, the wired
+// components, the HTTP and gRPC servers and anything else registered with
+// OnShutdown.
+type App struct {
+	cfg        *config.Config
+	deps       Deps
+	components *Components
+	server     *http.Server
+
+	shutdownTimeout time.Duration
+
+	mu    sync.Mutex
+	hooks []shutdownHook
+
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// AppOption configures NewApp
+type AppOption func(*App)
+
+// WithDeps uses already opened resources instead of connecting from the
+// config. The caller keeps ownership: Shutdown doesn't close them, and the
+// schema must already be migrated.
+func WithDeps(deps Deps) AppOption {
+	return func(a *App) {
+		a.deps = deps
+	}
+}
+
+// WithShutdownTimeout overrides DefaultShutdownTimeout
+func WithShutdownTimeout(d time.Duration) AppOption {
+	return func(a *App) {
+		a.shutdownTimeout = d
+	}
+}
+
+// NewApp connects to Postgres and, when reachable, Redis, migrates the
+// schema and builds the HTTP server. Background workers start right away;
+// call Run to serve, or Shutdown to release everything.
+func NewApp(cfg *config.Config, opts ...AppOption) (*App, error) {
+	a := &App{
+		cfg:             cfg,
+		shutdownTimeout: DefaultShutdownTimeout,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if a.deps.DB == nil {
+		if err := a.connect(); err != nil {
+			a.runHooks(context.Background())
+			return nil, err
+		}
+	}
+
+	components, err := Build(cfg, a.deps)
+	if err != nil {
+		a.runHooks(context.Background())
+		return nil, err
+	}
+	a.components = components
+	a.OnShutdown("background workers", components.Close)
+	if components.GRPCServer != nil {
+		// Registered last so in-flight calls finish before the workers drain
+		a.OnShutdown("gRPC server", a.stopGRPC)
+	}
+
+	a.server = &http.Server{
+		Addr:         ":" + cfg.Port,
+		Handler:      components.Handler,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  idleTimeout,
+	}
+
+	return a, nil
+}
+
+// connect opens the connections NewApp owns and registers their closing
+func (a *App) connect() error {
+	db, err := openDatabase(a.cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get sql.DB: %w", err)
+	}
+	a.OnShutdown("database", func(ctx context.Context) error {
+		return sqlDB.Close()
+	})
+
+	// Redis is optional - graceful degradation
+	redisClient, err := openRedis(a.cfg)
+	if err != nil {
+		log.Printf("WARNING: Failed to connect to Redis: %v", err)
+		log.Printf("Continuing without Redis - using in-memory cache and rate limiting")
+		redisClient = nil
+	} else {
+		a.OnShutdown("redis", func(ctx context.Context) error {
+			return redisClient.Close()
+		})
+		log.Println("Redis connected successfully")
+	}
+
+	if err := postgres.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	log.Print("Database migrated successfully")
+
+	a.deps.DB, a.deps.Redis = db, redisClient
+	return nil
+}
+
+// openDatabase and openRedis connect the way NewApp does; SelfCheck uses
+// them too so it tests the same path
+func openDatabase(cfg *config.Config) (*gorm.DB, error) {
+	return postgres.NewConnection(DBConfig(cfg))
+}
+
+func openRedis(cfg *config.Config) (*redis.RedisClient, error) {
+	return redis.NewRedisClient(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+}
+
+// SetRateLimitModes switches limiter scopes between enforce, warn and off
+// without a restart
+func (a *App) SetRateLimitModes(modes map[string]string) error {
+	return a.components.SetRateLimitModes(modes)
+}
+
+// OnShutdown registers fn to run during Shutdown, after the HTTP server
+// has stopped. Hooks run in reverse registration order, so anything
+// registered after NewApp runs before the background workers drain and
+// the connections close.
+func (a *App) OnShutdown(name string, fn func(ctx context.Context) error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.hooks = append(a.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// Run serves HTTP and, when enabled, gRPC on the configured ports until
+// ctx is cancelled or the HTTP server fails, then shuts down
+func (a *App) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", a.server.Addr)
+	if err != nil {
+		a.shutdownWithTimeout()
+		return fmt.Errorf("failed to listen on %s: %w", a.server.Addr, err)
+	}
+	if a.components.GRPCServer != nil {
+		grpcAddr := ":" + a.cfg.GRPCPort
+		grpcLn, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			ln.Close()
+			a.shutdownWithTimeout()
+			return fmt.Errorf("failed to listen on %s: %w", grpcAddr, err)
+		}
+		a.ServeGRPC(grpcLn)
+	}
+	return a.Serve(ctx, ln)
+}
+
+// ServeGRPC serves the gRPC API on ln in the background until Shutdown.
+// It does nothing when gRPC is disabled.
+func (a *App) ServeGRPC(ln net.Listener) {
+	server := a.components.GRPCServer
+	if server == nil {
+		return
+	}
+	log.Printf("gRPC server starting on %s", ln.Addr())
+	go func() {
+		if err := server.Serve(ln); err != nil {
+			log.Printf("gRPC server failed: %v", err)
+		}
+	}()
+}
+
+// stopGRPC lets in-flight calls finish, cutting them off if ctx ends first
+func (a *App) stopGRPC(ctx context.Context) error {
+	server := a.components.GRPCServer
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		server.Stop()
+		<-stopped
+		return fmt.Errorf("gRPC server forced to stop: %w", ctx.Err())
+	}
+}
+
+// Serve is Run on an existing listener
+func (a *App) Serve(ctx context.Context, ln net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- a.server.Serve(ln)
+	}()
+
+	log.Printf("Server starting on %s", ln.Addr())
+	log.Printf("Environment: %s", a.cfg.Environment)
+	log.Printf("Features enabled:")
+	log.Printf("  - Database: PostgreSQL")
+	log.Printf("  - Cache: %v", a.deps.Redis != nil)
+	log.Printf("  - Rate Limiting: %v (Redis: %v)", true, a.deps.Redis != nil)
+
+	select {
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			// Shutdown was called directly; wait for it and report its result
+			return a.Shutdown(context.Background())
+		}
+		// The server stopped on its own, so shut the rest down too
+		a.shutdownWithTimeout()
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down server...")
+	if err := a.shutdownWithTimeout(); err != nil {
+		return err
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	log.Println("Server exited")
+	return nil
+}
+
+func (a *App) shutdownWithTimeout() error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+	return a.Shutdown(ctx)
+}
+
+// Shutdown stops accepting requests, waits for in-flight ones, then runs
+// the shutdown hooks: background workers drain before the connections
+// close. Only the first call does anything; later calls return its result.
+func (a *App) Shutdown(ctx context.Context) error {
+	a.shutdownOnce.Do(func() {
+		if a.server != nil {
+			if err := a.server.Shutdown(ctx); err != nil {
+				log.Printf("Server forced to shutdown: %v", err)
+				a.shutdownErr = err
+			}
+		}
+		if err := a.runHooks(ctx); err != nil && a.shutdownErr == nil {
+			a.shutdownErr = err
+		}
+	})
+	return a.shutdownErr
+}
+
+// runHooks runs every shutdown hook, newest first, and returns the first
+// error
+func (a *App) runHooks(ctx context.Context) error {
+	a.mu.Lock()
+	hooks := a.hooks
+	a.hooks = nil
+	a.mu.Unlock()
+
+	var firstErr error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].fn(ctx); err != nil {
+			log.Printf("Shutdown of %s failed: %v", hooks[i].name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,129 @@
+// internal/app/lifecycle_test.go
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	usergrpc "user-service/internal/interfaces/grpc"
+	userv1 "user-service/proto/user/v1"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Every App started by this package's tests must release its goroutines.
+// gorm's prepared statement cache starts a goroutine that never exits, by
+// design; SelfCheck's tests open a real Postgres connection and hit it.
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m,
+		goleak.IgnoreTopFunction("gorm.io/gorm/internal/lru.NewLRU[...].func1"),
+	)
+}
+
+func TestApp_ShutdownStopsServingAndRunsHooks(t *testing.T) {
+	h := newHarness(t, false)
+	h.expect(t, request{method: http.MethodGet, path: "/health"}, http.StatusOK)
+	h.expect(t, request{method: http.MethodGet, path: "/livez"}, http.StatusOK)
+
+	// Hooks registered after NewApp run first, ahead of the workers
+	var order []string
+	h.app.OnShutdown("first", func(ctx context.Context) error {
+		order = append(order, "first")
+		return nil
+	})
+	h.app.OnShutdown("second", func(ctx context.Context) error {
+		order = append(order, "second")
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.app.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if strings.Join(order, ",") != "second,first" {
+		t.Errorf("expected hooks in reverse order, got %v", order)
+	}
+
+	// Later calls, including the harness's, don't rerun anything
+	if err := h.app.Shutdown(ctx); err != nil {
+		t.Errorf("second shutdown: %v", err)
+	}
+	if len(order) != 2 {
+		t.Errorf("expected hooks to run once, got %v", order)
+	}
+
+	h.client.CloseIdleConnections()
+	if _, err := h.client.Get(h.url + "/health"); err == nil {
+		t.Error("expected the server to stop accepting connections")
+	}
+}
+
+func TestApp_RunListensOnConfiguredPort(t *testing.T) {
+	busy, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer busy.Close()
+	_, port, _ := net.SplitHostPort(busy.Addr().String())
+
+	h := newHarness(t, false)
+	cfg := *h.cfg
+	cfg.Port = port
+	deps := h.app.deps
+	deps.Registerer = prometheus.NewRegistry()
+	app, err := NewApp(&cfg, WithDeps(deps), WithShutdownTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("new app: %v", err)
+	}
+
+	err = app.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), ":"+port) {
+		t.Fatalf("expected a listen error for port %s, got %v", port, err)
+	}
+}
+
+func TestApp_ServesGRPCUntilShutdown(t *testing.T) {
+	h := newHarness(t, true, func(cfg *config.Config) {
+		cfg.GRPCPort = "0"
+		cfg.InternalAPIKeys = map[string]string{"cart": "cart-key"}
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	h.app.ServeGRPC(ln)
+
+	conn, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	client := userv1.NewUserServiceClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), usergrpc.APIKeyHeader, "cart-key")
+
+	// The call gets through auth to the (empty) database
+	if _, err := client.GetUser(ctx, &userv1.GetUserRequest{Id: 1}); status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.app.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	if _, err := client.GetUser(ctx, &userv1.GetUserRequest{Id: 1}); status.Code(err) != codes.Unavailable {
+		t.Errorf("expected the gRPC server to be stopped, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/infrastructure/metrics"
+	userhttp "user-service/internal/interfaces/http/handlers"
+
+	"gorm.io/gorm"
+)
+
+// overviewSources are what the admin overview reads besides the jobs
+type overviewSources struct {
+	counts     application.UserCountRepository
+	stats      application.ActivityStats
+	rateLimits *metrics.RateLimitMetrics
+	cache      *metrics.CacheMetrics
+	db         *gorm.DB
+}
+
+// sections lists the overview in display order. Rate-limit rejections and
+// the cache hit ratio are this instance's own figures.
+func (o overviewSources) sections() []userhttp.OverviewSection {
+	return []userhttp.OverviewSection{
+		{Name: "users", Load: o.users},
+		{Name: "today", Load: o.today},
+		{Name: "rate_limit", Load: o.rateLimit},
+		{Name: "cache", Load: o.cacheRatio},
+		{Name: "db_pool", Load: o.dbPool},
+	}
+}
+
+func (o overviewSources) users(ctx context.Context) (interface{}, error) {
+	counts, err := o.counts.CountUsersByStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	byStatus := make(map[string]int64, len(counts))
+	for status, count := range counts {
+		byStatus[string(status)] = count
+		total += count
+	}
+	return map[string]interface{}{
+		"total":     total,
+		"by_status": byStatus,
+	}, nil
+}
+
+// today counts since midnight UTC
+func (o overviewSources) today(ctx context.Context) (interface{}, error) {
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	report, err := o.stats.Activity(ctx, string(application.GranularityDay), midnight, now)
+	if err != nil {
+		return nil, err
+	}
+	var registrations, logins int64
+	for _, bucket := range report.Buckets {
+		registrations += bucket.Signups
+		logins += bucket.Logins
+	}
+	return map[string]interface{}{
+		"registrations": registrations,
+		"logins":        logins,
+	}, nil
+}
+
+func (o overviewSources) rateLimit(ctx context.Context) (interface{}, error) {
+	return map[string]interface{}{
+		"rejections_last_hour": o.rateLimits.RejectionsLastHour(),
+	}, nil
+}
+
+func (o overviewSources) cacheRatio(ctx context.Context) (interface{}, error) {
+	ratio, ok := o.cache.HitRatio()
+	if !ok {
+		// No lookups yet, nothing to divide
+		return map[string]interface{}{"hit_ratio": nil}, nil
+	}
+	return map[string]interface{}{"hit_ratio": ratio}, nil
+}
+
+func (o overviewSources) dbPool(ctx context.Context) (interface{}, error) {
+	if o.db == nil {
+		return nil, errors.New("no database")
+	}
+	sqlDB, err := o.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	stats := sqlDB.Stats()
+	pool := map[string]interface{}{
+		"open":             stats.OpenConnections,
+		"in_use":           stats.InUse,
+		"idle":             stats.Idle,
+		"max_open":         stats.MaxOpenConnections,
+		"wait_count":       stats.WaitCount,
+		"wait_duration_ms": stats.WaitDuration.Milliseconds(),
+	}
+	// Saturation is only defined when the pool has a cap
+	if stats.MaxOpenConnections > 0 {
+		pool["saturation"] = float64(stats.InUse) / float64(stats.MaxOpenConnections)
+	}
+	return pool, nil
+}
@@ -0,0 +1,282 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/domain"
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/infrastructure/redis"
+	"user-service/internal/infrastructure/storage"
+	userhttp "user-service/internal/interfaces/http/handlers"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/openapi"
+	"user-service/internal/interfaces/http/router"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// Routes are the handlers and dependencies SetupRoutes mounts
+type Routes struct {
+	Users      *userhttp.UserHandler
+	Stats      *userhttp.StatsHandler
+	Jobs       *userhttp.JobsHandler
+	Overview   *userhttp.OverviewHandler
+	JWTManager *auth.JWTManager
+	// AuthOpts carry the revocation checks shared with the service
+	AuthOpts []middleware.AuthOption
+	DB       *gorm.DB
+	Redis    *redis.RedisClient
+	Gatherer prometheus.Gatherer
+	// RateLimits is told about every request a route limiter rejects
+	RateLimits middleware.RateLimitObserver
+	// Modes switches route limiters between enforce, warn and off
+	Modes *middleware.RateLimitModes
+	// FailureModes says what route limiters do when Redis fails them
+	FailureModes map[string]middleware.FailureMode
+	// Switches are the per-route kill switches managed by the admin API
+	Switches *middleware.EndpointSwitches
+	// Files are served at /files when FileSigner is set, to holders of a
+	// link it signed
+	Files      storage.Storage
+	FileSigner *auth.URLSigner
+}
+
+// SetupRoutes mounts every endpoint with its route-specific auth and rate
+// limits. The global middleware chain is applied by Build. limiters are the
+// in-memory rate limiters, whose idle visitors the owner evicts.
+func SetupRoutes(routes Routes, cfg *config.Config) (mux *router.Router, limiters []*middleware.RateLimiter) {
+	handler, statsHandler := routes.Users, routes.Stats
+	jwtManager, authOpts := routes.JWTManager, routes.AuthOpts
+	db, redisClient := routes.DB, routes.Redis
+
+	mux = router.New()
+
+	// limitedBy names a limiter scope for its mode, failure mode and
+	// rejection reports
+	limitedBy := func(scope string) []middleware.RateLimitOption {
+		return []middleware.RateLimitOption{
+			middleware.WithRejectionObserver(routes.RateLimits, scope),
+			middleware.WithModes(routes.Modes, scope),
+			middleware.WithFailureMode(failureMode(routes.FailureModes, scope)),
+		}
+	}
+	newLimiter := func(scope string, requestsPerSecond float64, burst int) *middleware.RateLimiter {
+		limiter := middleware.NewRateLimiter(requestsPerSecond, burst, 30*time.Minute, limitedBy(scope)...)
+		limiters = append(limiters, limiter)
+		return limiter
+	}
+
+	// Liveness for container and load balancer probes; touches nothing
+	root := mux.Group()
+	root.HandleFunc("GET "+livezPath, livez)
+
+	// Health check - includes Redis status, cached for a few seconds
+	root.Handle("GET /health", newHealthChecker(db, redisClient, cfg.HealthCacheTTL))
+
+	// Prometheus metrics
+	root.Handle("GET /metrics", promhttp.HandlerFor(routes.Gatherer, promhttp.HandlerOpts{}))
+
+	// The API description and a Swagger UI to browse it
+	root.HandleFunc("GET "+openapi.SpecPath, openapi.SpecHandler(openapi.Spec()))
+	root.HandleFunc("GET "+openapi.DocsPath, openapi.DocsHandler(openapi.SpecPath))
+
+	// Auth with the revocation checks configured by main
+	authenticate := middleware.AuthMiddleware(jwtManager, authOpts...)
+	// Reading and editing the profile also take personal access tokens,
+	// within their scopes. Managing the account's credentials, the email
+	// included, never does.
+	authenticateOrToken := middleware.AuthMiddleware(jwtManager,
+		append(authOpts[:len(authOpts):len(authOpts)], middleware.AllowAccessTokens())...)
+	// The email change also takes the restricted sessions opened with a
+	// recovery code
+	authenticateEmailChange := middleware.AuthMiddleware(jwtManager,
+		append(authOpts[:len(authOpts):len(authOpts)], middleware.AllowRecoverySessions())...)
+	// The password change also takes the session Login opens for an
+	// account that must reset its password, and so does logging out of it
+	authenticatePasswordChange := middleware.AuthMiddleware(jwtManager,
+		append(authOpts[:len(authOpts):len(authOpts)], middleware.AllowRecoverySessions(), middleware.AllowPasswordResetSessions())...)
+
+	// The user API is versioned; the rest isn't
+	public := mux.Versioned()
+	authed := mux.Versioned(authenticate)
+	authedOrToken := mux.Versioned(authenticateOrToken)
+
+	// Public routes with specific rate limits
+	// Register and its dry-run share one limiter so validation can't be used
+	// to enumerate emails at a higher rate than registration itself
+	// Recovery is limited like login, but per account so guessing codes
+	// can't be spread across addresses
+	// Reset emails are limited per address so no one can flood an inbox,
+	// and reset tokens like login attempts. Email change links are
+	// limited like reset tokens.
+	// Profile edits, PUT /users/update and PATCH /users/me, are limited
+	// per user, and so are the password change, email change and deletion
+	var registerLimit, loginLimit, recoverLimit, forgotLimit, resetLimit, confirmEmailLimit, updateLimit router.Middleware
+	var passwordLimit, changeEmailLimit, deleteLimit router.Middleware
+	if redisClient != nil {
+		// Redis-based rate limiting
+		// Register: 5 requests per minute, and login 10. Both count in a
+		// sliding window, so guessing can't double up across a boundary.
+		registerLimit = middleware.CustomRedisRateLimitMiddleware(redisClient, "register", 5, time.Minute,
+			append(limitedBy("register"), middleware.WithAlgorithm(middleware.SlidingWindow))...)
+		loginLimit = middleware.CustomRedisRateLimitMiddleware(redisClient, "login", 10, time.Minute,
+			append(limitedBy("login"), middleware.WithAlgorithm(middleware.SlidingWindow))...)
+		recoverLimit = middleware.CustomRedisKeyedRateLimitMiddleware(redisClient, "recover", 10, time.Minute, middleware.EmailKey, limitedBy("recover")...)
+		forgotLimit = middleware.CustomRedisKeyedRateLimitMiddleware(redisClient, "forgot_password", 3, time.Hour, middleware.EmailKey, limitedBy("forgot_password")...)
+		resetLimit = middleware.CustomRedisRateLimitMiddleware(redisClient, "reset_password", 10, time.Minute, limitedBy("reset_password")...)
+		confirmEmailLimit = middleware.CustomRedisRateLimitMiddleware(redisClient, "confirm_email", 10, time.Minute, limitedBy("confirm_email")...)
+		updateLimit = middleware.RedisUserRateLimitMiddleware(redisClient, mux, 10, time.Minute, limitedBy("update")...)
+		passwordLimit = middleware.RedisUserRateLimitMiddleware(redisClient, mux, 5, time.Minute, limitedBy("password")...)
+		changeEmailLimit = middleware.RedisUserRateLimitMiddleware(redisClient, mux, 5, time.Hour, limitedBy("change_email")...)
+		deleteLimit = middleware.RedisUserRateLimitMiddleware(redisClient, mux, 5, time.Minute, limitedBy("delete")...)
+	} else {
+		// In-memory rate limiting fallback
+		registerLimit = middleware.CustomRateLimitMiddleware(newLimiter("register", 0.083, 1))
+		loginLimit = middleware.CustomRateLimitMiddleware(newLimiter("login", 0.167, 2))
+		recoverLimit = middleware.KeyedRateLimitMiddleware(newLimiter("recover", 0.167, 2), middleware.EmailKey)
+		forgotLimit = middleware.KeyedRateLimitMiddleware(newLimiter("forgot_password", 0.00083, 3), middleware.EmailKey)
+		resetLimit = middleware.CustomRateLimitMiddleware(newLimiter("reset_password", 0.167, 2))
+		confirmEmailLimit = middleware.CustomRateLimitMiddleware(newLimiter("confirm_email", 0.167, 2))
+		updateLimit = middleware.UserRateLimitMiddleware(newLimiter("update", 2, 5))
+		passwordLimit = middleware.UserRateLimitMiddleware(newLimiter("password", 1, 2))
+		changeEmailLimit = middleware.UserRateLimitMiddleware(newLimiter("change_email", 0.0014, 5))
+		deleteLimit = middleware.UserRateLimitMiddleware(newLimiter("delete", 1, 2))
+	}
+
+	// Exact retries of a signup replay its first response. Replays sit in
+	// front of the limiter so a client retrying a timeout isn't throttled
+	// for it. Without Redis, Register's own replay of recent signups is the
+	// only protection.
+	register := public
+	if redisClient != nil {
+		register = register.With(middleware.RedisIdempotencyMiddleware(redisClient, "register", 24*time.Hour))
+	}
+	register.With(registerLimit).HandleFunc("POST /users/register", handler.Register)
+	public.With(registerLimit).HandleFunc("POST /users/register/validate", handler.ValidateRegistration)
+	public.With(loginLimit).HandleFunc("POST /users/login", handler.Login)
+	public.With(recoverLimit).HandleFunc("POST /users/recover", handler.Recover)
+	public.With(forgotLimit).HandleFunc("POST /users/forgot-password", handler.ForgotPassword)
+	public.With(resetLimit).HandleFunc("POST /users/reset-password", handler.ResetForgottenPassword)
+	public.With(confirmEmailLimit).HandleFunc("GET /users/confirm-email", handler.ConfirmEmailChange)
+	// The refresh token is the credential for both
+	public.HandleFunc("POST /users/refresh", handler.Refresh)
+	public.HandleFunc("POST /users/refresh/revoke", handler.RevokeRefreshToken)
+	root.HandleFunc("GET /auth/.well-known/jwks.json", handler.JWKS)
+
+	// Internal routes for other services, only mounted when keys are configured.
+	// Strictly limited and audited since this is an enumeration oracle.
+	if len(cfg.InternalAPIKeys) > 0 {
+		var internalLimit router.Middleware
+		if redisClient != nil {
+			internalLimit = middleware.CustomRedisRateLimitMiddleware(redisClient, "internal", 60, time.Minute, limitedBy("internal")...)
+		} else {
+			internalLimit = middleware.CustomRateLimitMiddleware(newLimiter("internal", 1, 10))
+		}
+
+		// Signed requests can't be replayed or altered in transit, which
+		// a static key alone doesn't prevent
+		internal := mux.Group(middleware.APIKeyAuth(cfg.InternalAPIKeys))
+		if cfg.InternalRequestSigning {
+			var nonces middleware.NonceStore = middleware.NewMemoryNonceStore()
+			if redisClient != nil {
+				nonces = middleware.NewRedisNonceStore(redisClient)
+			}
+			internal = internal.With(middleware.RequireRequestSignature(cfg.InternalAPIKeys, nonces))
+		}
+		if cfg.InternalRequestTimeout > 0 {
+			internal = internal.With(middleware.Timeout(cfg.InternalRequestTimeout))
+		}
+
+		internal.With(internalLimit).HandleFunc("GET /internal/users/by-email", handler.LookupByEmail)
+	}
+
+	// Admin routes for user listings, the deletion workflow, dashboards and
+	// background jobs, only mounted when keys are configured
+	if len(cfg.AdminAPIKeys) > 0 {
+		admin := mux.Group(middleware.APIKeyAuth(cfg.AdminAPIKeys))
+
+		admin.HandleFunc("GET /admin/users", handler.AdminListUsers)
+		admin.HandleFunc("GET /admin/deletions", handler.ListPendingDeletions)
+		admin.HandleFunc("POST /admin/deletions/cancel", handler.CancelDeletion)
+		admin.HandleFunc("POST /admin/deletions/expedite", handler.ExpediteDeletion)
+		admin.HandleFunc("GET /admin/stats/activity", statsHandler.Activity)
+		admin.HandleFunc("POST /admin/invites", handler.CreateInvite)
+		admin.HandleFunc("POST /admin/invites/revoke", handler.RevokeInvite)
+		admin.HandleFunc("GET /admin/jobs", routes.Jobs.List)
+		admin.HandleFunc("POST /admin/jobs/{name}/run", routes.Jobs.Run)
+		admin.HandleFunc("GET /admin/jobs/{id}", handler.GetBulkJob)
+		admin.HandleFunc("GET /admin/overview", routes.Overview.Overview)
+		admin.HandleFunc("POST /admin/users/{id}/force-password-reset", handler.ForcePasswordReset)
+		admin.HandleFunc("GET /admin/users/{id}/restore", handler.GetUserForRestore)
+		admin.HandleFunc("POST /admin/users/{id}/restore", handler.RestoreUser)
+		admin.HandleFunc("DELETE /admin/users/{id}", handler.PurgeUser)
+		admin.HandleFunc("POST /admin/users/force-password-reset", handler.BulkForcePasswordReset)
+		admin.HandleFunc("POST /admin/users/bulk", handler.StartBulkJob)
+		admin.HandleFunc("POST /admin/users/{id}/notices", handler.AddNotice)
+		admin.HandleFunc("DELETE /admin/users/{id}/notices/{code}", handler.RemoveNotice)
+		if routes.Switches != nil {
+			endpoints := userhttp.NewEndpointsHandler(routes.Switches, func(path string) (string, bool) {
+				return middleware.RoutePattern(mux, path)
+			})
+			admin.HandleFunc("GET "+userhttp.EndpointSwitchPath, endpoints.List)
+			admin.HandleFunc("POST "+userhttp.EndpointSwitchPath, endpoints.Disable)
+			admin.HandleFunc("DELETE "+userhttp.EndpointSwitchPath, endpoints.Enable)
+		}
+
+		// Snapshots move accounts between environments; without a signing
+		// secret they could be forged, so they need one of their own
+		if cfg.SnapshotSigningSecret != "" {
+			admin.HandleFunc("GET /admin/users/{id}/snapshot", handler.ExportSnapshot)
+			admin.HandleFunc("POST /admin/users/import-snapshot", handler.ImportSnapshot)
+		}
+	}
+
+	// Downloads of locally stored files; the signed link is the credential
+	if routes.Files != nil && routes.FileSigner != nil {
+		files := userhttp.NewFilesHandler(routes.Files)
+		mux.Group(middleware.RequireSignedURL(routes.FileSigner)).HandleFunc("GET "+userhttp.FilesPrefix+"/{key...}", files.Download)
+	}
+
+	// Protected routes with authentication
+	authedOrToken.HandleFunc("GET /users/me", handler.GetCurrentUser)
+	authedOrToken.With(updateLimit).HandleFunc("PATCH /users/me", handler.PatchCurrentUser)
+	authedOrToken.HandleFunc("GET /users/me/token", handler.GetCurrentToken)
+	authedOrToken.HandleFunc("GET /users/me/preferences", handler.GetPreferences)
+	authedOrToken.HandleFunc("PATCH /users/me/preferences", handler.UpdatePreferences)
+	// The account by ID for admins, and an existence check for anyone
+	// signed in. GET also serves HEAD, and a pattern of HEAD's own would
+	// conflict with the literal GET /users/... routes, so it's told apart here.
+	getUserByID := middleware.RequireRole(domain.RoleAdmin)(http.HandlerFunc(handler.GetUserByID))
+	authedOrToken.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			handler.UserExists(w, r)
+			return
+		}
+		getUserByID.ServeHTTP(w, r)
+	})
+	authed.HandleFunc("POST /users/me/recovery-codes", handler.GenerateRecoveryCodes)
+	authed.HandleFunc("GET /users/me/tokens", handler.ListAccessTokens)
+	authed.HandleFunc("POST /users/me/tokens", handler.CreateAccessToken)
+	authed.HandleFunc("DELETE /users/me/tokens/{id}", handler.RevokeAccessToken)
+	authed.HandleFunc("GET /users/me/sessions", handler.ListSessions)
+	authed.HandleFunc("DELETE /users/me/sessions/{id}", handler.RevokeSession)
+	authed.HandleFunc("POST /users/me/notices/{code}/dismiss", handler.DismissNotice)
+
+	// Protected routes with auth + user-based rate limiting
+	authed.With(updateLimit).HandleFunc("PUT /users/update", handler.UpdateUser)
+	passwordChange := mux.Versioned(authenticatePasswordChange)
+	passwordChange.With(passwordLimit).HandleFunc("PUT /users/me/password", handler.ChangePassword)
+	passwordChange.HandleFunc("POST /users/logout", handler.Logout)
+	passwordChange.HandleFunc("POST /users/logout-all", handler.LogoutAll)
+	mux.Versioned(authenticateEmailChange, changeEmailLimit).HandleFunc("POST /users/me/email", handler.RequestEmailChange)
+	authed.With(deleteLimit).HandleFunc("DELETE /users/delete", handler.DeleteUser)
+
+	// List users - admins only, without extra rate limiting
+	authedOrToken.With(middleware.RequireRole(domain.RoleAdmin)).HandleFunc("GET /users", handler.ListUsers)
+
+	return mux, limiters
+}
@@ -0,0 +1,225 @@
+// internal/app/routes_test.go
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/interfaces/http/openapi"
+)
+
+var wildcard = regexp.MustCompile(`\{[^}]+\}`)
+
+// withEveryRoute mounts the routes that only exist when configured
+func withEveryRoute(t *testing.T, mode string) func(*config.Config) {
+	dir := t.TempDir()
+	return func(cfg *config.Config) {
+		cfg.AdminAPIKeys = map[string]string{"ops": "ops-key"}
+		cfg.InternalAPIKeys = map[string]string{"checkout": "checkout-key"}
+		cfg.SnapshotSigningSecret = "e2e-snapshot-secret-of-32-characters"
+		withLocalStorage(dir)(cfg)
+		cfg.PathNormalization = mode
+	}
+}
+
+// pathVariants are the spellings of path that clients and proxies send
+func pathVariants(path string) []string {
+	variants := []string{path + "/", "/" + path, path + "//"}
+	if i := strings.LastIndex(path, "/"); i > 0 {
+		variants = append(variants,
+			path[:i]+"/"+path[i:],
+			path[:i]+"/."+path[i:],
+		)
+	}
+	return variants
+}
+
+// route splits a mounted pattern into its method and a concrete path
+func route(pattern string) (method, path string) {
+	method, path, _ = strings.Cut(pattern, " ")
+	return method, wildcard.ReplaceAllString(path, "1")
+}
+
+// serveRaw sends a request whose path reaches the handler exactly as given
+func serveRaw(h *harness, method, path string, n int) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/", nil)
+	req.URL.Path = path
+	// Every request from its own IP, so route limits don't interfere
+	req.RemoteAddr = fmt.Sprintf("10.1.%d.%d:4000", n/250, n%250+1)
+	rec := httptest.NewRecorder()
+	h.app.components.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRoutes_PathVariantsReachTheirRoute(t *testing.T) {
+	h := newHarness(t, false, withEveryRoute(t, "rewrite"))
+	patterns := h.app.components.patterns
+	if len(patterns) < 30 {
+		t.Fatalf("expected every route mounted, got %v", patterns)
+	}
+
+	n := 0
+	for _, pattern := range patterns {
+		method, path := route(pattern)
+		n++
+		want := serveRaw(h, method, path, n).Code
+		if want == http.StatusNotFound || want == http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: canonical path not routed, got %d", method, path, want)
+			continue
+		}
+		for _, variant := range pathVariants(path) {
+			n++
+			if got := serveRaw(h, method, variant, n).Code; got != want {
+				t.Errorf("%s: expected %d like %s, got %d", variant, want, path, got)
+			}
+		}
+	}
+}
+
+func TestRoutes_PathVariantsRedirect(t *testing.T) {
+	h := newHarness(t, false, withEveryRoute(t, "redirect"))
+
+	n := 0
+	for _, pattern := range h.app.components.patterns {
+		_, path := route(pattern)
+		for _, variant := range pathVariants(path) {
+			n++
+			rec := serveRaw(h, http.MethodPost, variant, n)
+			if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != path {
+				t.Errorf("%s: expected 308 to %s, got %d to %q", variant, path, rec.Code, rec.Header().Get("Location"))
+			}
+		}
+	}
+
+	// Preflights can't follow redirects, so they are rewritten instead
+	n++
+	if rec := serveRaw(h, http.MethodOptions, "/users/login/", n); rec.Code == http.StatusPermanentRedirect {
+		t.Error("expected a preflight not to be redirected")
+	}
+}
+
+func TestRoutes_PathVariantsKeepTheBody(t *testing.T) {
+	for _, mode := range []string{"rewrite", "redirect"} {
+		t.Run(mode, func(t *testing.T) {
+			h := newHarness(t, false, withEveryRoute(t, mode))
+			// The client follows the 308 with the body
+			h.expect(t, request{
+				method: http.MethodPost, path: "/users/register/", client: "10.2.0.1",
+				body: map[string]string{"username": "alice", "email": "alice@example.com", "password": testPassword},
+			}, http.StatusCreated)
+			h.expect(t, request{
+				method: http.MethodPost, path: "//users//login", client: "10.2.0.1",
+				body: map[string]string{"email": "alice@example.com", "password": testPassword},
+			}, http.StatusOK)
+		})
+	}
+}
+
+func TestRoutes_Versioned(t *testing.T) {
+	h := newHarness(t, false, withEveryRoute(t, "rewrite"))
+
+	resp := h.expect(t, request{
+		method: http.MethodPost, path: "/v1/users/register", client: "10.3.0.1",
+		body: map[string]string{"username": "alice", "email": "alice@example.com", "password": testPassword},
+	}, http.StatusCreated)
+	if resp.header.Get("Deprecation") != "" {
+		t.Errorf("expected the versioned route current, got %v", resp.header)
+	}
+	token := h.login(t, "alice", "10.3.0.1")
+
+	me := h.expect(t, request{method: http.MethodGet, path: "/v1/users/me", token: token}, http.StatusOK)
+	legacy := h.expect(t, request{method: http.MethodGet, path: "/users/me", token: token}, http.StatusOK)
+	if string(me.body) != string(legacy.body) {
+		t.Errorf("expected the alias to serve the same account, got %s and %s", me.body, legacy.body)
+	}
+	if legacy.header.Get("Deprecation") != "true" || legacy.header.Get("Link") != `</v1/users/me>; rel="successor-version"` {
+		t.Errorf("expected the alias deprecated, got %v", legacy.header)
+	}
+
+	// Only the user API is versioned
+	h.expect(t, request{method: http.MethodGet, path: "/v1/admin/users", apiKey: "ops-key"}, http.StatusNotFound)
+	h.expect(t, request{method: http.MethodGet, path: "/v1/health"}, http.StatusNotFound)
+}
+
+// TestRoutes_Documented fails when a route is mounted without being in
+// the OpenAPI document, as openapi.Routes has to be kept up by hand
+func TestRoutes_Documented(t *testing.T) {
+	h := newHarness(t, false, withEveryRoute(t, "rewrite"))
+
+	resp := h.expect(t, request{method: http.MethodGet, path: openapi.SpecPath}, http.StatusOK)
+	var doc openapi.Document
+	if err := json.Unmarshal(resp.body, &doc); err != nil {
+		t.Fatalf("expected the document as JSON: %v", err)
+	}
+
+	for _, pattern := range h.app.components.patterns {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			t.Errorf("%s is mounted without a method", pattern)
+			continue
+		}
+		item, ok := doc.Paths[openapi.Path(path)]
+		if !ok {
+			t.Errorf("%s is mounted but not documented", pattern)
+			continue
+		}
+		if item[strings.ToLower(method)] == nil {
+			t.Errorf("%s is mounted but its method isn't documented", pattern)
+		}
+	}
+
+	docs := h.expect(t, request{method: http.MethodGet, path: openapi.DocsPath}, http.StatusOK)
+	if !strings.Contains(string(docs.body), openapi.SpecPath) {
+		t.Errorf("expected the docs page to load the document, got %s", docs.body)
+	}
+}
+
+func TestRoutes_SecureHeaders(t *testing.T) {
+	serve := func(handler http.Handler, path, proto string) http.Header {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if proto != "" {
+			req.Header.Set("X-Forwarded-Proto", proto)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Header()
+	}
+
+	// testConfig leaves HSTS off and doesn't trust the proxy
+	plain := newHarness(t, false).app.components.Handler
+	headers := serve(plain, "/users/me", "https")
+	if headers.Get("X-Content-Type-Options") != "nosniff" || headers.Get("X-Frame-Options") != "DENY" {
+		t.Errorf("expected the hardening headers on every response, got %v", headers)
+	}
+	if hsts := headers.Get("Strict-Transport-Security"); hsts != "" {
+		t.Errorf("expected no HSTS without config, got %q", hsts)
+	}
+	if csp := headers.Get("Content-Security-Policy"); csp != "" {
+		t.Errorf("expected no CSP on the API, got %q", csp)
+	}
+	if csp := serve(plain, openapi.DocsPath, "").Get("Content-Security-Policy"); csp != openapi.DocsContentSecurityPolicy(openapi.SpecPath) {
+		t.Errorf("expected the built-in policy on the docs, got %q", csp)
+	}
+
+	proxied := newHarness(t, false, func(cfg *config.Config) {
+		cfg.HSTSMaxAge = time.Hour
+		cfg.TrustForwardedProto = true
+		cfg.DocsContentSecurityPolicy = "default-src 'self'"
+	}).app.components.Handler
+	if hsts := serve(proxied, "/users/me", "https").Get("Strict-Transport-Security"); hsts != "max-age=3600; includeSubDomains" {
+		t.Errorf("expected HSTS behind a TLS proxy, got %q", hsts)
+	}
+	if hsts := serve(proxied, "/users/me", "").Get("Strict-Transport-Security"); hsts != "" {
+		t.Errorf("expected no HSTS over plain HTTP, got %q", hsts)
+	}
+	if csp := serve(proxied, openapi.DocsPath, "").Get("Content-Security-Policy"); csp != "default-src 'self'" {
+		t.Errorf("expected the configured docs policy, got %q", csp)
+	}
+}
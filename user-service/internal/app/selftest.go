@@ -0,0 +1,162 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/infrastructure/postgres"
+	"user-service/internal/infrastructure/redis"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// CheckReport is the result of SelfCheck. Checks use the /health states
+// and the overall status follows the same rule, so only a Redis failure
+// leaves it healthy.
+type CheckReport struct {
+	Status string                   `json:"status"` // StatusHealthy or StatusUnhealthy
+	Checks map[string]ServiceHealth `json:"checks"`
+}
+
+// selfCheck holds the connectors SelfCheck uses; tests swap them
+type selfCheck struct {
+	openDatabase func(cfg *config.Config) (*gorm.DB, error)
+	openRedis    func(cfg *config.Config) (*redis.RedisClient, error)
+	now          func() time.Time
+}
+
+// SelfCheck runs the startup wiring NewApp would - config validation, the
+// database connection and migrations, Redis, the JWT key and Build -
+// without serving anything, and reports each step. Migrations run in a
+// transaction that is rolled back, so the check changes nothing.
+func SelfCheck(ctx context.Context, cfg *config.Config) *CheckReport {
+	c := &selfCheck{
+		openDatabase: openDatabase,
+		openRedis:    openRedis,
+		now:          time.Now,
+	}
+	return c.run(ctx, cfg)
+}
+
+func (c *selfCheck) run(ctx context.Context, cfg *config.Config) *CheckReport {
+	report := &CheckReport{Checks: make(map[string]ServiceHealth)}
+	record := func(name string, err error) bool {
+		checkedAt := c.now().UTC()
+		health := ServiceHealth{Status: ServiceUp, CheckedAt: &checkedAt}
+		if err != nil {
+			health.Status, health.Error = ServiceDown, err.Error()
+		}
+		report.Checks[name] = health
+		return err == nil
+	}
+	skip := func(names ...string) {
+		for _, name := range names {
+			report.Checks[name] = ServiceHealth{Status: ServiceSkipped}
+		}
+	}
+	defer func() {
+		report.Status = overallStatus(report.Checks)
+	}()
+
+	record("jwt", checkJWT(cfg))
+
+	// Everything else connects using the config
+	if !record("config", cfg.Validate()) {
+		skip("database", "migrations", "redis", "wiring")
+		return report
+	}
+
+	var redisClient *redis.RedisClient
+	if cfg.RedisAddr == "" {
+		report.Checks["redis"] = ServiceHealth{Status: ServiceNotConfigured}
+	} else {
+		client, err := c.openRedis(cfg)
+		if err == nil {
+			defer client.Close()
+			err = client.Ping(ctx)
+		}
+		if record("redis", err) {
+			redisClient = client
+		}
+	}
+
+	db, err := c.openDatabase(cfg)
+	if err == nil {
+		defer closeDatabase(db)
+		// Keep the migration's SQL out of the report on stdout
+		db = db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+		err = pingDatabase(db)(ctx)
+	}
+	if !record("database", err) {
+		skip("migrations", "wiring")
+		return report
+	}
+	if !record("migrations", postgres.CheckMigrations(ctx, db)) {
+		skip("wiring")
+		return report
+	}
+	record("wiring", checkWiring(ctx, cfg, Deps{DB: db, Redis: redisClient}))
+
+	return report
+}
+
+// checkJWT loads the signing key as Build does and proves it can issue a
+// token that verifies
+func checkJWT(cfg *config.Config) error {
+	if cfg.JWTAlgorithm == "" || cfg.JWTAlgorithm == auth.AlgorithmHS256 {
+		secrets := []config.JWTKey{{Secret: cfg.JWTSecret}}
+		if len(cfg.JWTSecrets) > 0 {
+			secrets = cfg.JWTSecrets
+		}
+		for _, key := range secrets {
+			if key.Secret == "" {
+				return errors.New("JWT_SECRET is empty")
+			}
+			if cfg.IsProduction() && key.Secret == config.DefaultJWTSecret {
+				return errors.New("JWT_SECRET is the development default")
+			}
+		}
+	}
+
+	manager, err := newJWTManager(cfg)
+	if err != nil {
+		return err
+	}
+	token, err := manager.GenerateToken(0)
+	if err != nil {
+		return fmt.Errorf("failed to sign a token: %w", err)
+	}
+	if _, err := manager.ValidateToken(token); err != nil {
+		return fmt.Errorf("signed token does not verify: %w", err)
+	}
+	return nil
+}
+
+// checkWiring builds the full application and tears it down again. Its
+// metrics go to a throwaway registry.
+func checkWiring(ctx context.Context, cfg *config.Config, deps Deps) error {
+	registry := prometheus.NewRegistry()
+	deps.Registerer, deps.Gatherer = registry, registry
+
+	components, err := Build(cfg, deps)
+	if err != nil {
+		return err
+	}
+	if err := components.Close(ctx); err != nil {
+		log.Printf("Failed to stop the self-check's workers: %v", err)
+	}
+	return nil
+}
+
+func closeDatabase(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
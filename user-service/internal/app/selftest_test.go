@@ -0,0 +1,171 @@
+// internal/app/selftest_test.go
+package app
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/infrastructure/redis"
+	"user-service/internal/testsupport"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// checkConfig is a config SelfCheck accepts, pointing at nothing
+func checkConfig() *config.Config {
+	cfg := testConfig()
+	cfg.Port = "8081"
+	cfg.DBHost, cfg.DBName = "127.0.0.1", "user_service"
+	cfg.DBRetryAttempts = 1
+	return cfg
+}
+
+// closedPort returns a local port nothing listens on
+func closedPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+// newSelfCheck connects to a fresh, unmigrated SQLite file and to
+// miniredis unless cfg points Redis elsewhere
+func newSelfCheck(t *testing.T) (*selfCheck, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "check.db")
+	mr := miniredis.RunT(t)
+	clock := testsupport.NewClock()
+
+	return &selfCheck{
+		openDatabase: func(cfg *config.Config) (*gorm.DB, error) {
+			return gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		},
+		openRedis: func(cfg *config.Config) (*redis.RedisClient, error) {
+			if cfg.RedisAddr == "miniredis" {
+				return redis.NewRedisClient(mr.Addr(), "", 0)
+			}
+			return openRedis(cfg)
+		},
+		now: clock.Now,
+	}, path
+}
+
+func TestSelfCheck_Healthy(t *testing.T) {
+	check, path := newSelfCheck(t)
+	cfg := checkConfig()
+	cfg.RedisAddr = "miniredis"
+
+	report := check.run(context.Background(), cfg)
+	if report.Status != StatusHealthy {
+		t.Fatalf("expected healthy, got %+v", report.Checks)
+	}
+	for _, name := range []string{"config", "jwt", "database", "migrations", "redis", "wiring"} {
+		if got := report.Checks[name]; got.Status != ServiceUp || got.CheckedAt == nil {
+			t.Errorf("%s: expected up, got %+v", name, got)
+		}
+	}
+
+	// The migration dry run leaves the schema as it found it
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer closeDatabase(db)
+	if db.Migrator().HasTable("users") {
+		t.Error("expected the check to roll its migrations back")
+	}
+}
+
+func TestSelfCheck_BrokenConfigurations(t *testing.T) {
+	tests := []struct {
+		name string
+		// real uses the production connectors instead of SQLite
+		real        bool
+		tweak       func(cfg *config.Config, t *testing.T)
+		wantHealthy bool
+		want        map[string]string
+	}{
+		{
+			name: "invalid config",
+			tweak: func(cfg *config.Config, t *testing.T) {
+				cfg.ErasureInterval = 0
+			},
+			want: map[string]string{
+				"config": ServiceDown, "jwt": ServiceUp,
+				"database": ServiceSkipped, "migrations": ServiceSkipped, "redis": ServiceSkipped, "wiring": ServiceSkipped,
+			},
+		},
+		{
+			name: "default JWT secret in production",
+			tweak: func(cfg *config.Config, t *testing.T) {
+				cfg.Environment, cfg.JWTSecret = "production", config.DefaultJWTSecret
+			},
+			want: map[string]string{"jwt": ServiceDown, "database": ServiceUp, "wiring": ServiceUp},
+		},
+		{
+			name: "database unreachable",
+			real: true,
+			tweak: func(cfg *config.Config, t *testing.T) {
+				cfg.DBPort = closedPort(t)
+			},
+			want: map[string]string{
+				"config": ServiceUp, "database": ServiceDown,
+				"migrations": ServiceSkipped, "wiring": ServiceSkipped, "redis": ServiceNotConfigured,
+			},
+		},
+		{
+			name: "redis unreachable only degrades",
+			tweak: func(cfg *config.Config, t *testing.T) {
+				cfg.RedisAddr = "127.0.0.1:" + strconv.Itoa(closedPort(t))
+			},
+			wantHealthy: true,
+			want:        map[string]string{"redis": ServiceDown, "database": ServiceUp, "wiring": ServiceUp},
+		},
+		{
+			name: "wiring fails",
+			tweak: func(cfg *config.Config, t *testing.T) {
+				cfg.BreachedPasswordsFile = filepath.Join(t.TempDir(), "missing.txt")
+			},
+			want: map[string]string{"migrations": ServiceUp, "wiring": ServiceDown},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check, _ := newSelfCheck(t)
+			if tt.real {
+				check.openDatabase = openDatabase
+			}
+			cfg := checkConfig()
+			tt.tweak(cfg, t)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			report := check.run(ctx, cfg)
+
+			if healthy := report.Status == StatusHealthy; healthy != tt.wantHealthy {
+				t.Errorf("status %s, checks %+v", report.Status, report.Checks)
+			}
+			for name, want := range tt.want {
+				got := report.Checks[name]
+				if got.Status != want {
+					t.Errorf("%s: expected %s, got %+v", name, want, got)
+				}
+				if (got.Status == ServiceDown) != (got.Error != "") {
+					t.Errorf("%s: a failed check must say why, and only then: %+v", name, got)
+				}
+			}
+		})
+	}
+}
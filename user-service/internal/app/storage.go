@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/infrastructure/storage"
+	userhttp "user-service/internal/interfaces/http/handlers"
+)
+
+// newStorage builds the file storage cfg selects, or nil when none is.
+// signer is set when downloads go through this service's /files route,
+// which only local storage needs; S3 links are presigned by S3 itself.
+func newStorage(cfg *config.Config) (store storage.Storage, signer *auth.URLSigner, err error) {
+	switch cfg.StorageBackend {
+	case "":
+		return nil, nil, nil
+	case "local":
+		var opts []storage.LocalOption
+		if cfg.StorageURLSecret != "" {
+			signer = auth.NewURLSigner([]byte(cfg.StorageURLSecret))
+			opts = append(opts, storage.WithLocalSignedURLs(signer, userhttp.FilesPrefix))
+		}
+		local, err := storage.NewLocal(cfg.StorageLocalPath, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Storing files in %s", cfg.StorageLocalPath)
+		return local, signer, nil
+	case "s3":
+		s3, err := storage.NewS3(storage.S3Config{
+			Endpoint:  cfg.StorageS3Endpoint,
+			Region:    cfg.StorageS3Region,
+			Bucket:    cfg.StorageS3Bucket,
+			AccessKey: cfg.StorageS3AccessKey,
+			SecretKey: cfg.StorageS3SecretKey,
+			PathStyle: cfg.StorageS3PathStyle,
+			SSE:       cfg.StorageS3SSE,
+			KMSKeyID:  cfg.StorageS3KMSKeyID,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Storing files in bucket %s", cfg.StorageS3Bucket)
+		return s3, nil, nil
+	}
+	return nil, nil, fmt.Errorf("invalid storage backend %q", cfg.StorageBackend)
+}
+
+// storageReports keeps bulk job reports in file storage
+type storageReports struct {
+	store storage.Storage
+}
+
+func (s storageReports) SaveReport(ctx context.Context, key string, report io.Reader) error {
+	_, err := s.store.Put(ctx, key, report, storage.PutOptions{ContentType: "text/csv"})
+	return err
+}
+
+func (s storageReports) ReportURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	link, err := s.store.SignedURL(ctx, key, ttl)
+	if errors.Is(err, storage.ErrSignedURLsUnsupported) {
+		// Local storage without a URL secret; the report is only on disk
+		return "", nil
+	}
+	return link, err
+}
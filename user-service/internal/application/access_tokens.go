@@ -0,0 +1,319 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+// ErrAccessTokensNotConfigured is returned by the access token methods
+// when the service was built without an AccessTokenRepository
+var ErrAccessTokensNotConfigured = errors.New("access tokens not configured")
+
+const (
+	// MaxAccessTokens is how many tokens one user may hold at a time
+	MaxAccessTokens = 20
+	// MaxAccessTokenNameLength bounds the label a user gives a token
+	MaxAccessTokenNameLength = 100
+
+	// AccessTokenUsageJobName is the last-used flush's name in the job
+	// scheduler
+	AccessTokenUsageJobName = "access_token_usage_flush"
+
+	// accessTokenSecretBytes is the random part of a token, before encoding
+	accessTokenSecretBytes = 32
+)
+
+// AccessTokenRepository persists personal access tokens by the hash of
+// their secret
+type AccessTokenRepository interface {
+	// Create stores token under hash, filling in its ID
+	Create(ctx context.Context, token *domain.AccessToken, hash string) error
+	// ListByUser returns userID's tokens, newest first
+	ListByUser(ctx context.Context, userID uint) ([]*domain.AccessToken, error)
+	// GetByHash fails with domain.ErrAccessTokenNotFound for an unknown hash
+	GetByHash(ctx context.Context, hash string) (*domain.AccessToken, error)
+	// Delete removes userID's token id, failing with
+	// domain.ErrAccessTokenNotFound when userID has no such token
+	Delete(ctx context.Context, userID, id uint) error
+	// DeleteByUser removes every token userID holds
+	DeleteByUser(ctx context.Context, userID uint) error
+	// UpdateLastUsed writes a batch of last-used timestamps keyed by token ID
+	UpdateLastUsed(ctx context.Context, used map[uint]time.Time) error
+	WithTx(tx *gorm.DB) AccessTokenRepository
+}
+
+// WithAccessTokenRepository stores personal access tokens, which the token
+// endpoints and token authentication need
+func WithAccessTokenRepository(repo AccessTokenRepository) Option {
+	return func(s *UserService) {
+		s.accessTokens = repo
+	}
+}
+
+// WithAccessTokenUsageRecorder makes VerifyAccessToken record last-used
+// times asynchronously, through a recorder from NewAccessTokenUsageRecorder
+func WithAccessTokenUsageRecorder(recorder *LastLoginRecorder) Option {
+	return func(s *UserService) {
+		s.accessTokenUsage = recorder
+	}
+}
+
+// accessTokenUsageStore lets a LastLoginRecorder batch last-used times,
+// keyed by token rather than user ID
+type accessTokenUsageStore struct {
+	repo AccessTokenRepository
+}
+
+func (s accessTokenUsageStore) UpdateLastLogins(ctx context.Context, used map[uint]time.Time) error {
+	return s.repo.UpdateLastUsed(ctx, used)
+}
+
+// NewAccessTokenUsageRecorder batches access token last-used updates the
+// way last_login is batched, holding at most bufferSize tokens between
+// flushes. Flush it periodically under AccessTokenUsageJobName.
+func NewAccessTokenUsageRecorder(repo AccessTokenRepository, bufferSize int) *LastLoginRecorder {
+	return NewLastLoginRecorder(accessTokenUsageStore{repo: repo}, bufferSize)
+}
+
+// hashAccessToken hashes a token for storage and lookup. The secret is 256
+// random bits, so a fast unsalted hash is enough.
+func hashAccessToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAccessTokenSecret returns "pat_" and 32 random bytes in URL-safe base64
+func newAccessTokenSecret() (string, error) {
+	b := make([]byte, accessTokenSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return domain.AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// wellFormedAccessToken rejects strings that can't be a token without a
+// database lookup
+func wellFormedAccessToken(secret string) bool {
+	want := len(domain.AccessTokenPrefix) + base64.RawURLEncoding.EncodedLen(accessTokenSecretBytes)
+	return len(secret) == want && strings.HasPrefix(secret, domain.AccessTokenPrefix)
+}
+
+// validateAccessToken checks a token about to be created, defaulting its
+// scopes to read-only
+func validateAccessToken(token *domain.AccessToken, now time.Time) error {
+	verr := &ValidationError{Fields: make(map[string]string)}
+
+	token.Name = strings.TrimSpace(token.Name)
+	switch {
+	case token.Name == "":
+		verr.Fields["name"] = "name is required"
+	case len(token.Name) > MaxAccessTokenNameLength:
+		verr.Fields["name"] = fmt.Sprintf("name must be at most %d characters", MaxAccessTokenNameLength)
+	}
+
+	if len(token.Scopes) == 0 {
+		token.Scopes = []string{domain.ScopeUserRead}
+	}
+	seen := make(map[string]bool, len(token.Scopes))
+	scopes := token.Scopes[:0:0]
+	for _, scope := range token.Scopes {
+		if !isAccessTokenScope(scope) {
+			verr.Fields["scopes"] = fmt.Sprintf("unknown scope %q, want one of %s", scope, strings.Join(domain.AccessTokenScopes, ", "))
+			break
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	token.Scopes = scopes
+
+	if token.ExpiresAt != nil && !token.ExpiresAt.After(now) {
+		verr.Fields["expires_at"] = "expires_at must be in the future"
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func isAccessTokenScope(scope string) bool {
+	for _, s := range domain.AccessTokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAccessToken mints a personal access token for token.UserID with
+// token's name, scopes (read-only when empty) and optional expiry, filling
+// in the rest of token. The secret is only returned here; just its hash is
+// kept.
+func (s *UserService) CreateAccessToken(ctx context.Context, token *domain.AccessToken) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if s.accessTokens == nil {
+		return "", ErrAccessTokensNotConfigured
+	}
+
+	now := s.now().UTC()
+	if err := validateAccessToken(token, now); err != nil {
+		return "", err
+	}
+	secret, err := newAccessTokenSecret()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate access token: %w", err)
+	}
+	token.LastUsedAt, token.CreatedAt = nil, now
+
+	metadata := map[string]interface{}{
+		"name":   token.Name,
+		"scopes": token.Scopes,
+	}
+	if token.ExpiresAt != nil {
+		metadata["expires_at"] = token.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	err = s.WithTransaction(writeCtx, func(ctx context.Context, tx *TxService) error {
+		existing, err := tx.ListAccessTokens(ctx, token.UserID)
+		if err != nil {
+			return err
+		}
+		if len(existing) >= MaxAccessTokens {
+			return &ValidationError{Fields: map[string]string{
+				"name": fmt.Sprintf("at most %d access tokens, revoke one first", MaxAccessTokens),
+			}}
+		}
+		if err := tx.CreateAccessToken(ctx, token, hashAccessToken(secret)); err != nil {
+			return err
+		}
+		metadata["token_id"] = token.ID
+		return tx.Audit(ctx, &AuditEntry{
+			Action:    AuditAccessTokenCreated,
+			ActorID:   token.UserID,
+			TargetID:  token.UserID,
+			Metadata:  metadata,
+			CreatedAt: now,
+		})
+	})
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create access token: %w", err)
+	}
+	return secret, nil
+}
+
+// ListAccessTokens returns the user's tokens, newest first, expired ones
+// included
+func (s *UserService) ListAccessTokens(ctx context.Context, userID uint) ([]*domain.AccessToken, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.accessTokens == nil {
+		return nil, ErrAccessTokensNotConfigured
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	defer cancel()
+	return s.accessTokens.ListByUser(readCtx, userID)
+}
+
+// RevokeAccessToken deletes the user's token id, which stops working at
+// once. Another user's token is domain.ErrAccessTokenNotFound.
+func (s *UserService) RevokeAccessToken(ctx context.Context, userID, id uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.accessTokens == nil {
+		return ErrAccessTokensNotConfigured
+	}
+
+	now := s.now().UTC()
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	err := s.WithTransaction(writeCtx, func(ctx context.Context, tx *TxService) error {
+		if err := tx.DeleteAccessToken(ctx, userID, id); err != nil {
+			return err
+		}
+		return tx.Audit(ctx, &AuditEntry{
+			Action:    AuditAccessTokenRevoked,
+			ActorID:   userID,
+			TargetID:  userID,
+			Metadata:  map[string]interface{}{"token_id": id},
+			CreatedAt: now,
+		})
+	})
+	if errors.Is(err, domain.ErrAccessTokenNotFound) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+	return nil
+}
+
+// VerifyAccessToken resolves a presented token to its record, failing with
+// ErrInvalidAccessToken when it is unknown, revoked or expired, or its
+// owner can't use the account: banned, deleted, erased or due to reset
+// their password. Use is recorded through the usage recorder, if
+// configured.
+func (s *UserService) VerifyAccessToken(ctx context.Context, secret string) (*domain.AccessToken, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.accessTokens == nil {
+		return nil, ErrAccessTokensNotConfigured
+	}
+	if !wellFormedAccessToken(secret) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	token, err := s.accessTokens.GetByHash(readCtx, hashAccessToken(secret))
+	cancel()
+	if errors.Is(err, domain.ErrAccessTokenNotFound) {
+		return nil, ErrInvalidAccessToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up access token: %w", err)
+	}
+
+	now := s.now().UTC()
+	if token.Expired(now) {
+		return nil, ErrInvalidAccessToken
+	}
+	// Revoking the owner's sessions deletes their tokens, but that runs
+	// after the commit and may fail, and a ban revokes nothing
+	owner, err := s.GetUser(ctx, token.UserID)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil, ErrInvalidAccessToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load access token owner: %w", err)
+	}
+	if owner.Status != domain.StatusActive || owner.MustResetPassword {
+		return nil, ErrInvalidAccessToken
+	}
+	if s.accessTokenUsage != nil {
+		s.accessTokenUsage.Record(token.ID, now)
+	}
+	return token, nil
+}
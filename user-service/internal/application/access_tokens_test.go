@@ -0,0 +1,195 @@
+// internal/application/access_tokens_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+type accessTokenFixture struct {
+	repo   *testsupport.UserRepository
+	tokens *testsupport.AccessTokenRepository
+	usage  *application.LastLoginRecorder
+	audit  *fakeAuditLogger
+	clock  *testsupport.Clock
+	svc    *application.UserService
+	user   *domain.User
+}
+
+func newAccessTokenFixture() *accessTokenFixture {
+	repo := testsupport.NewUserRepository()
+	f := &accessTokenFixture{
+		repo:   repo,
+		tokens: testsupport.NewAccessTokenRepository(),
+		audit:  &fakeAuditLogger{},
+		clock:  testsupport.NewClock(),
+	}
+	f.usage = application.NewAccessTokenUsageRecorder(f.tokens, 0)
+	f.user = repo.AddUser("alice@example.com", "secret123")
+	f.svc = application.NewUserService(repo, testsupport.NewTxManager(repo, f.tokens), nil,
+		application.WithAccessTokenRepository(f.tokens),
+		application.WithAccessTokenUsageRecorder(f.usage),
+		application.WithAuditLogger(f.audit),
+		application.WithClock(f.clock.Now),
+		// As in the app, revoking sessions revokes access tokens
+		application.WithSessionRevoker(f.tokens),
+	)
+	return f
+}
+
+func TestAccessTokens_Lifecycle(t *testing.T) {
+	f := newAccessTokenFixture()
+	ctx := context.Background()
+
+	token := &domain.AccessToken{UserID: f.user.ID, Name: " ci deploys "}
+	secret, err := f.svc.CreateAccessToken(ctx, token)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.HasPrefix(secret, domain.AccessTokenPrefix) || token.ID == 0 || token.Name != "ci deploys" {
+		t.Fatalf("unexpected token %q %+v", secret, token)
+	}
+	if len(token.Scopes) != 1 || token.Scopes[0] != domain.ScopeUserRead {
+		t.Errorf("expected read-only by default, got %v", token.Scopes)
+	}
+	if hash, _ := f.tokens.Hash(token.ID); hash == "" || strings.Contains(secret, hash) || strings.Contains(hash, secret) {
+		t.Errorf("expected only a hash stored, got %q", hash)
+	}
+
+	verified, err := f.svc.VerifyAccessToken(ctx, secret)
+	if err != nil || verified.ID != token.ID || verified.UserID != f.user.ID {
+		t.Fatalf("expected the token verified, got %+v %v", verified, err)
+	}
+	if _, err := f.svc.VerifyAccessToken(ctx, secret[:len(secret)-1]+"x"); !errors.Is(err, application.ErrInvalidAccessToken) {
+		t.Errorf("expected a wrong secret refused, got %v", err)
+	}
+
+	// Last use is batched, not written per request
+	listed, _ := f.svc.ListAccessTokens(ctx, f.user.ID)
+	if len(listed) != 1 || listed[0].LastUsedAt != nil {
+		t.Fatalf("expected last use pending, got %+v", listed)
+	}
+	if err := f.usage.Flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	listed, _ = f.svc.ListAccessTokens(ctx, f.user.ID)
+	if listed[0].LastUsedAt == nil || !listed[0].LastUsedAt.Equal(f.clock.Now().UTC()) {
+		t.Errorf("expected last use recorded, got %v", listed[0].LastUsedAt)
+	}
+
+	// Only the owner can revoke
+	if err := f.svc.RevokeAccessToken(ctx, f.user.ID+1, token.ID); !errors.Is(err, domain.ErrAccessTokenNotFound) {
+		t.Errorf("expected another user's revoke to find nothing, got %v", err)
+	}
+	if err := f.svc.RevokeAccessToken(ctx, f.user.ID, token.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, err := f.svc.VerifyAccessToken(ctx, secret); !errors.Is(err, application.ErrInvalidAccessToken) {
+		t.Errorf("expected a revoked token refused, got %v", err)
+	}
+
+	var actions []string
+	for _, entry := range f.audit.entries {
+		actions = append(actions, entry.Action)
+	}
+	if strings.Join(actions, ",") != application.AuditAccessTokenCreated+","+application.AuditAccessTokenRevoked {
+		t.Errorf("unexpected audit trail %v", actions)
+	}
+}
+
+func TestAccessTokens_ExpiryAndValidation(t *testing.T) {
+	f := newAccessTokenFixture()
+	ctx := context.Background()
+
+	past := f.clock.Now().Add(-time.Minute)
+	_, err := f.svc.CreateAccessToken(ctx, &domain.AccessToken{
+		UserID: f.user.ID, Name: "bad", Scopes: []string{"admin"}, ExpiresAt: &past,
+	})
+	var verr *application.ValidationError
+	if !errors.As(err, &verr) || verr.Fields["scopes"] == "" || verr.Fields["expires_at"] == "" {
+		t.Fatalf("expected scopes and expires_at rejected, got %v", err)
+	}
+
+	expiresAt := f.clock.Now().Add(time.Hour)
+	secret, err := f.svc.CreateAccessToken(ctx, &domain.AccessToken{
+		UserID: f.user.ID, Name: "temp", Scopes: []string{domain.ScopeUserWrite}, ExpiresAt: &expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := f.svc.VerifyAccessToken(ctx, secret); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	f.clock.Advance(time.Hour)
+	if _, err := f.svc.VerifyAccessToken(ctx, secret); !errors.Is(err, application.ErrInvalidAccessToken) {
+		t.Errorf("expected an expired token refused, got %v", err)
+	}
+
+	for i := 1; i < application.MaxAccessTokens; i++ {
+		if _, err := f.svc.CreateAccessToken(ctx, &domain.AccessToken{UserID: f.user.ID, Name: "bulk"}); err != nil {
+			t.Fatalf("create %d: %v", i, err)
+		}
+	}
+	if _, err := f.svc.CreateAccessToken(ctx, &domain.AccessToken{UserID: f.user.ID, Name: "one too many"}); !errors.As(err, &verr) {
+		t.Errorf("expected the limit enforced, got %v", err)
+	}
+}
+
+func TestAccessTokens_OwnerMustBeUsable(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(user *domain.User)
+	}{
+		{"banned", func(user *domain.User) { user.Status = domain.StatusBanned }},
+		{"pending deletion", func(user *domain.User) { user.Status = domain.StatusPendingDeletion }},
+		{"erased", func(user *domain.User) { user.Status = domain.StatusErased }},
+		{"must reset password", func(user *domain.User) { user.MustResetPassword = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAccessTokenFixture()
+			ctx := context.Background()
+			secret, err := f.svc.CreateAccessToken(ctx, &domain.AccessToken{UserID: f.user.ID, Name: "script"})
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if _, err := f.svc.VerifyAccessToken(ctx, secret); err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+
+			user, _ := f.repo.User(f.user.ID)
+			tt.apply(user)
+			f.repo.Put(user)
+			if _, err := f.svc.VerifyAccessToken(ctx, secret); !errors.Is(err, application.ErrInvalidAccessToken) {
+				t.Errorf("expected the token refused, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAccessTokens_RevokedWithSessions(t *testing.T) {
+	f := newAccessTokenFixture()
+	ctx := context.Background()
+
+	secret, err := f.svc.CreateAccessToken(ctx, &domain.AccessToken{UserID: f.user.ID, Name: "script"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := f.svc.LogoutAll(ctx, f.user.ID); err != nil {
+		t.Fatalf("logout all: %v", err)
+	}
+	f.svc.Wait()
+	if _, err := f.svc.VerifyAccessToken(ctx, secret); !errors.Is(err, application.ErrInvalidAccessToken) {
+		t.Errorf("expected logging out everywhere to revoke the token, got %v", err)
+	}
+	if listed, _ := f.tokens.ListByUser(ctx, f.user.ID); len(listed) != 0 {
+		t.Errorf("expected the tokens deleted, got %d", len(listed))
+	}
+}
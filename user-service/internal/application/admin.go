@@ -0,0 +1,179 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"user-service/internal/domain"
+	"user-service/internal/normalize"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// CreateAdmin registers an operator account. Unlike Register it accepts
+// reserved usernames, since those only exist to stop customers posing as
+// staff.
+func (s *UserService) CreateAdmin(ctx context.Context, user *domain.User, password string, actorID uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	user.Role = domain.RoleAdmin
+	if err := s.validateRegistration(ctx, user, password, true); err != nil {
+		return err
+	}
+
+	return s.createUser(ctx, user, password, &AuditEntry{
+		Action:    AuditAdminCreated,
+		ActorID:   actorID,
+		CreatedAt: s.now().UTC(),
+	}, "")
+}
+
+// ResetPassword sets a new password on the user's behalf and logs them out
+// everywhere, raising the token version with the password. The password
+// must pass the registration policy. The user is sent a security alert.
+func (s *UserService) ResetPassword(ctx context.Context, id uint, password, reason string, actorID uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	password = normalize.Password(password)
+	if msg := checkPasswordPolicy(password, user.Username, user.Email); msg != "" {
+		return &ValidationError{Fields: map[string]string{"password": msg}}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	updated, err := s.updateAuditedSigningOut(ctx, id, map[string]interface{}{
+		"password": string(hashedPassword),
+	}, &AuditEntry{
+		Action:    AuditPasswordReset,
+		ActorID:   actorID,
+		TargetID:  id,
+		Reason:    reason,
+		CreatedAt: s.now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset password: %w", err)
+	}
+	user.TokenVersion = updated.TokenVersion
+
+	s.cacheTokenVersion(ctx, user)
+	s.invalidateUser(ctx, user)
+
+	if s.sessions != nil {
+		s.afterCommit(ctx, "revoke sessions", func(ctx context.Context) error {
+			return s.sessions.RevokeUserSessions(ctx, id)
+		})
+	}
+
+	// The request came from support tooling, so its client isn't quoted
+	s.sendSecurityAlert(ctx, AlertPasswordChanged, user, user.Email, ClientInfo{}, alertDetails{})
+
+	return nil
+}
+
+// SetRole changes what the account may do. Tokens carry the role they
+// were issued with, so the token version goes up with the role and the
+// user's sessions are revoked. Setting the role it already has is a no-op
+// and isn't audited.
+func (s *UserService) SetRole(ctx context.Context, id uint, role domain.Role, reason string, actorID uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if user.Role == role {
+		return nil
+	}
+
+	updated, err := s.updateAuditedSigningOut(ctx, id, map[string]interface{}{
+		"role": string(role),
+	}, &AuditEntry{
+		Action:   AuditRoleChanged,
+		ActorID:  actorID,
+		TargetID: id,
+		Reason:   reason,
+		Metadata: map[string]interface{}{
+			"from": string(user.Role),
+			"to":   string(role),
+		},
+		CreatedAt: s.now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set role: %w", err)
+	}
+	user.TokenVersion = updated.TokenVersion
+
+	s.cacheTokenVersion(ctx, user)
+	s.invalidateUser(ctx, user)
+
+	if s.sessions != nil {
+		s.afterCommit(ctx, "revoke sessions", func(ctx context.Context) error {
+			return s.sessions.RevokeUserSessions(ctx, id)
+		})
+	}
+	return nil
+}
+
+// updateAudited writes fields and entry in one transaction
+func (s *UserService) updateAudited(ctx context.Context, id uint, fields map[string]interface{}, entry *AuditEntry) error {
+	txCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+
+	return s.WithTransaction(txCtx, func(txCtx context.Context, tx *TxService) error {
+		if err := tx.UpdateFields(txCtx, id, fields); err != nil {
+			return err
+		}
+		return tx.Audit(txCtx, entry)
+	})
+}
+
+// updateAuditedSigningOut is updateAudited that also raises the user's
+// token version, so every token issued so far is refused from the commit
+// on, whether or not the sessions are revoked afterwards. It returns the
+// user with their new version.
+func (s *UserService) updateAuditedSigningOut(ctx context.Context, id uint, fields map[string]interface{}, entry *AuditEntry) (*domain.User, error) {
+	txCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+
+	var user *domain.User
+	err := s.WithTransaction(txCtx, func(txCtx context.Context, tx *TxService) error {
+		var err error
+		if user, err = nextTokenVersion(txCtx, tx, id); err != nil {
+			return err
+		}
+		writes := make(map[string]interface{}, len(fields)+1)
+		for name, value := range fields {
+			writes[name] = value
+		}
+		writes["token_version"] = user.TokenVersion
+		if err := tx.UpdateFields(txCtx, id, writes); err != nil {
+			return err
+		}
+		return tx.Audit(txCtx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
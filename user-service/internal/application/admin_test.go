@@ -0,0 +1,144 @@
+// internal/application/admin_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+func TestCreateAdmin(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	audit := &fakeAuditLogger{}
+	clock := testsupport.NewClock()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithAuditLogger(audit),
+		application.WithClock(clock.Now),
+	)
+	ctx := context.Background()
+
+	// Reserved usernames are fine for staff accounts
+	admin := &domain.User{Username: "admin", Email: " Ops@Example.com"}
+	if err := svc.CreateAdmin(ctx, admin, "Sup3r-secret", 0); err != nil {
+		t.Fatalf("create admin failed: %v", err)
+	}
+
+	stored, ok := repo.User(admin.ID)
+	if !ok {
+		t.Fatal("expected admin to be stored")
+	}
+	if !stored.IsAdmin() || stored.Email != "ops@example.com" {
+		t.Errorf("unexpected stored admin: %+v", stored)
+	}
+	if _, err := svc.Login(ctx, "ops@example.com", "Sup3r-secret"); err != nil {
+		t.Errorf("expected admin to log in, got %v", err)
+	}
+	if len(audit.entries) != 1 || audit.entries[0].Action != application.AuditAdminCreated ||
+		audit.entries[0].TargetID != admin.ID || !audit.entries[0].CreatedAt.Equal(clock.Now()) {
+		t.Errorf("unexpected audit entries: %+v", audit.entries)
+	}
+
+	// Customers still can't take the name
+	_, err := svc.Register(ctx, &domain.User{Username: "admin", Email: "x@example.com"}, "Sup3r-secret", "")
+	var verr *application.ValidationError
+	if !errors.As(err, &verr) || verr.Fields["username"] == "" {
+		t.Errorf("expected reserved username error for Register, got %v", err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	audit := &fakeAuditLogger{}
+	revoker := &fakeRevoker{}
+	clock := testsupport.NewClock()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithAuditLogger(audit),
+		application.WithSessionRevoker(revoker),
+		application.WithClock(clock.Now),
+	)
+	ctx := context.Background()
+
+	err := svc.ResetPassword(ctx, user.ID, "password", "locked out", 0)
+	var verr *application.ValidationError
+	if !errors.As(err, &verr) || verr.Fields["password"] == "" {
+		t.Fatalf("expected password policy error, got %v", err)
+	}
+
+	if err := svc.ResetPassword(ctx, user.ID, "N3w-password", "locked out", 0); err != nil {
+		t.Fatalf("reset failed: %v", err)
+	}
+	svc.Wait()
+
+	if _, err := svc.Login(ctx, "alice@example.com", "secret123"); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Errorf("expected the old password to stop working, got %v", err)
+	}
+	if _, err := svc.Login(ctx, "alice@example.com", "N3w-password"); err != nil {
+		t.Errorf("expected the new password to work, got %v", err)
+	}
+	if len(revoker.revoked) != 1 || revoker.revoked[0] != user.ID {
+		t.Errorf("expected sessions revoked for user %d, got %v", user.ID, revoker.revoked)
+	}
+	if stored, _ := repo.User(user.ID); stored.TokenVersion != user.TokenVersion+1 {
+		t.Errorf("expected a new token version, got %d", stored.TokenVersion)
+	}
+	if len(audit.entries) != 1 || audit.entries[0].Action != application.AuditPasswordReset ||
+		audit.entries[0].Reason != "locked out" || !audit.entries[0].CreatedAt.Equal(clock.Now()) {
+		t.Errorf("unexpected audit entries: %+v", audit.entries)
+	}
+
+	if err := svc.ResetPassword(ctx, 999, "N3w-password", "", 0); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	audit := &fakeAuditLogger{}
+	revoker := &fakeRevoker{}
+	clock := testsupport.NewClock()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithAuditLogger(audit),
+		application.WithSessionRevoker(revoker),
+		application.WithClock(clock.Now),
+	)
+	ctx := context.Background()
+
+	if err := svc.SetRole(ctx, user.ID, "superuser", "", 0); !errors.Is(err, application.ErrInvalidRole) {
+		t.Fatalf("expected ErrInvalidRole, got %v", err)
+	}
+
+	if err := svc.SetRole(ctx, user.ID, domain.RoleAdmin, "on-call", 0); err != nil {
+		t.Fatalf("set role failed: %v", err)
+	}
+	// Tokens carrying the old role are outdated as the role changes, even
+	// if revoking the sessions fails
+	stored, _ := repo.User(user.ID)
+	if stored.Role != domain.RoleAdmin || stored.TokenVersion != user.TokenVersion+1 {
+		t.Errorf("expected role admin and a new token version, got %q, %d", stored.Role, stored.TokenVersion)
+	}
+	if len(audit.entries) != 1 || audit.entries[0].Action != application.AuditRoleChanged ||
+		audit.entries[0].Metadata["from"] != "user" || audit.entries[0].Metadata["to"] != "admin" ||
+		!audit.entries[0].CreatedAt.Equal(clock.Now()) {
+		t.Errorf("unexpected audit entries: %+v", audit.entries)
+	}
+	// Tokens carry the old role until they are revoked
+	svc.Wait()
+	if len(revoker.revoked) != 1 || revoker.revoked[0] != user.ID {
+		t.Errorf("expected sessions revoked for user %d, got %v", user.ID, revoker.revoked)
+	}
+
+	// Unchanged role is not audited again
+	if err := svc.SetRole(ctx, user.ID, domain.RoleAdmin, "on-call", 0); err != nil {
+		t.Fatalf("repeat set role failed: %v", err)
+	}
+	svc.Wait()
+	if len(audit.entries) != 1 || len(revoker.revoked) != 1 {
+		t.Errorf("expected no new audit entry or revocation, got %d and %v", len(audit.entries), revoker.revoked)
+	}
+}
@@ -0,0 +1,67 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	AuditUserBanned   = "user.banned"
+	AuditUserUnbanned = "user.unbanned"
+	AuditEmailLookup  = "internal.email_lookup"
+
+	AuditDeletionRequested = "user.deletion_requested"
+	AuditDeletionCancelled = "user.deletion_cancelled"
+	AuditUserErased        = "user.erased"
+	AuditUserRestored      = "user.restored"
+	AuditUserPurged        = "user.purged"
+
+	AuditAdminCreated  = "user.admin_created"
+	AuditPasswordReset = "user.password_reset"
+	AuditRoleChanged   = "user.role_changed"
+	AuditEmailChanged  = "user.email_changed"
+	// AuditPasswordChanged is the user changing their own password;
+	// AuditPasswordReset is support doing it for them
+	AuditPasswordChanged = "user.password_changed"
+	// AuditPasswordResetForced is support requiring the user to choose a
+	// new password at their next login
+	AuditPasswordResetForced = "user.password_reset_forced"
+
+	AuditRecoveryCodesGenerated = "user.recovery_codes_generated"
+	AuditRecoveryCodeUsed       = "user.recovery_code_used"
+
+	AuditAccessTokenCreated = "user.access_token_created"
+	AuditAccessTokenRevoked = "user.access_token_revoked"
+
+	AuditNotificationPrefsChanged = "user.notification_preferences_changed"
+	AuditPreferencesChanged       = "user.preferences_changed"
+
+	AuditNoticeAdded   = "user.notice_added"
+	AuditNoticeRemoved = "user.notice_removed"
+
+	AuditSnapshotExported = "user.snapshot_exported"
+	AuditSnapshotImported = "user.snapshot_imported"
+
+	// Invite entries have no target account; the code is in the metadata
+	AuditInviteCreated = "invite.created"
+	AuditInviteRevoked = "invite.revoked"
+)
+
+// AuditEntry records who did what to which account and why
+type AuditEntry struct {
+	Action    string
+	ActorID   uint // 0 means the system itself
+	TargetID  uint
+	Reason    string
+	Metadata  map[string]interface{}
+	CreatedAt time.Time
+}
+
+// AuditLogger persists audit entries, optionally inside a transaction
+type AuditLogger interface {
+	Record(ctx context.Context, entry *AuditEntry) error
+	WithTx(tx *gorm.DB) AuditLogger
+}
@@ -0,0 +1,280 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/logging"
+)
+
+// ErrBulkJobsNotConfigured is returned by the bulk job methods when the
+// service was built without a BulkJobRepository
+var ErrBulkJobsNotConfigured = errors.New("bulk jobs not configured")
+
+const (
+	// MaxBulkJobUsers caps how many users one bulk job may cover
+	MaxBulkJobUsers = 10000
+	// BulkJobBatchSize is how many users are acted on between progress saves
+	BulkJobBatchSize = 100
+
+	// BulkJobName is the bulk job runner's name in the job scheduler
+	BulkJobName = "bulk_actions"
+	// BulkJobInterval is how often the runner looks for queued jobs
+	BulkJobInterval = 5 * time.Second
+	// BulkJobTimeout bounds one runner pass, and the lease that keeps other
+	// instances from running one at the same time. A job that doesn't
+	// finish in one pass is picked up again by the next.
+	BulkJobTimeout = time.Minute
+
+	// BulkReportURLTTL is how long a report download link works
+	BulkReportURLTTL = 15 * time.Minute
+)
+
+// BulkJobRepository persists bulk jobs and their progress
+type BulkJobRepository interface {
+	// Create stores job, filling in its ID
+	Create(ctx context.Context, job *domain.BulkJob) error
+	// Get fails with domain.ErrBulkJobNotFound for an unknown ID
+	Get(ctx context.Context, id uint) (*domain.BulkJob, error)
+	// NextUnfinished returns the oldest job that isn't done, or nil
+	NextUnfinished(ctx context.Context) (*domain.BulkJob, error)
+	// SaveProgress writes the job's status, progress, failures, report
+	// and timestamps
+	SaveProgress(ctx context.Context, job *domain.BulkJob) error
+}
+
+// ReportStore keeps the reports of finished bulk jobs
+type ReportStore interface {
+	SaveReport(ctx context.Context, key string, report io.Reader) error
+	// ReportURL returns a link that downloads the report until ttl passes
+	ReportURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// WithBulkJobRepository stores bulk jobs, which StartBulkJob needs
+func WithBulkJobRepository(repo BulkJobRepository) Option {
+	return func(s *UserService) {
+		s.bulkJobs = repo
+	}
+}
+
+// WithReportStore keeps a per-user report of each finished bulk job.
+// Without it only the failures recorded on the job are available.
+func WithReportStore(store ReportStore) Option {
+	return func(s *UserService) {
+		s.reports = store
+	}
+}
+
+// StartBulkJob queues job to be run in the background by RunBulkJobs.
+// Duplicate IDs are dropped; the ID, status and creation time are filled
+// in on job.
+func (s *UserService) StartBulkJob(ctx context.Context, job *domain.BulkJob) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.bulkJobs == nil {
+		return ErrBulkJobsNotConfigured
+	}
+
+	job.Reason = strings.TrimSpace(job.Reason)
+	job.UserIDs = uniqueIDs(job.UserIDs)
+
+	verr := &ValidationError{Fields: make(map[string]string)}
+	if !job.Action.Valid() {
+		actions := make([]string, len(domain.BulkActions))
+		for i, action := range domain.BulkActions {
+			actions[i] = string(action)
+		}
+		verr.Fields["action"] = "action must be one of " + strings.Join(actions, ", ")
+	}
+	switch {
+	case len(job.UserIDs) == 0:
+		verr.Fields["user_ids"] = "user_ids is required"
+	case len(job.UserIDs) > MaxBulkJobUsers:
+		verr.Fields["user_ids"] = fmt.Sprintf("at most %d users per job", MaxBulkJobUsers)
+	}
+	if job.Reason == "" {
+		verr.Fields["reason"] = "reason is required"
+	}
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+
+	job.Status = domain.BulkJobQueued
+	job.Processed = 0
+	job.Failures = nil
+	job.CreatedAt = s.now().UTC()
+
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	if err := s.bulkJobs.Create(writeCtx, job); err != nil {
+		return fmt.Errorf("failed to queue bulk job: %w", err)
+	}
+	return nil
+}
+
+// GetBulkJob returns the job with its progress so far
+func (s *UserService) GetBulkJob(ctx context.Context, id uint) (*domain.BulkJob, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.bulkJobs == nil {
+		return nil, ErrBulkJobsNotConfigured
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	defer cancel()
+	return s.bulkJobs.Get(readCtx, id)
+}
+
+// BulkJobReportURL returns a download link for the finished job's report,
+// or "" when it has none
+func (s *UserService) BulkJobReportURL(ctx context.Context, job *domain.BulkJob) (string, error) {
+	if job.ReportKey == "" || s.reports == nil {
+		return "", nil
+	}
+	return s.reports.ReportURL(ctx, job.ReportKey, BulkReportURLTTL)
+}
+
+// RunBulkJobs works through the queued bulk jobs, oldest first, until none
+// are left or ctx ends. Progress is saved after every batch, so a pass cut
+// short resumes where it stopped. It is run on a schedule as a singleton
+// job.
+func (s *UserService) RunBulkJobs(ctx context.Context) error {
+	if s.bulkJobs == nil {
+		return nil
+	}
+
+	for ctx.Err() == nil {
+		readCtx, cancel := stepContext(ctx, pointReadTimeout)
+		job, err := s.bulkJobs.NextUnfinished(readCtx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to find queued bulk jobs: %w", err)
+		}
+		if job == nil {
+			return nil
+		}
+		if err := s.runBulkJob(ctx, job); err != nil {
+			return fmt.Errorf("bulk job %d: %w", job.ID, err)
+		}
+	}
+	// Out of time; the next pass carries on
+	return nil
+}
+
+func (s *UserService) runBulkJob(ctx context.Context, job *domain.BulkJob) error {
+	if job.Status == domain.BulkJobQueued {
+		now := s.now().UTC()
+		job.Status, job.StartedAt = domain.BulkJobRunning, &now
+		if err := s.saveBulkProgress(ctx, job); err != nil {
+			return err
+		}
+	}
+
+	reason := fmt.Sprintf("%s (bulk job %d)", job.Reason, job.ID)
+	for job.Processed < job.Total() {
+		end := min(job.Processed+BulkJobBatchSize, job.Total())
+		for _, id := range job.UserIDs[job.Processed:end] {
+			if ctx.Err() != nil {
+				break
+			}
+			err := s.applyBulkAction(ctx, job.Action, id, reason)
+			if err != nil && ctx.Err() != nil {
+				// Cut short rather than failed; retried next pass
+				break
+			}
+			if err != nil {
+				job.Failures = append(job.Failures, domain.BulkFailure{UserID: id, Error: bulkErrorMessage(err)})
+			}
+			job.Processed++
+		}
+		if err := s.saveBulkProgress(ctx, job); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+
+	if s.reports != nil {
+		job.ReportKey = fmt.Sprintf("bulk-jobs/%d.csv", job.ID)
+		if err := s.reports.SaveReport(ctx, job.ReportKey, bulkReport(job)); err != nil {
+			// The failures are still on the job; the report isn't worth
+			// redoing the whole job over
+			logging.Printf(ctx, "Failed to store the report of bulk job %d: %v", job.ID, err)
+			job.ReportKey = ""
+		}
+	}
+	now := s.now().UTC()
+	job.Status, job.FinishedAt = domain.BulkJobDone, &now
+	if err := s.saveBulkProgress(ctx, job); err != nil {
+		return err
+	}
+	logging.Printf(ctx, "Bulk job %d (%s) finished: %d succeeded, %d failed",
+		job.ID, job.Action, job.Succeeded(), len(job.Failures))
+	return nil
+}
+
+// saveBulkProgress writes job even when ctx has just ended, so the work
+// done before a pass ran out of time isn't repeated
+func (s *UserService) saveBulkProgress(ctx context.Context, job *domain.BulkJob) error {
+	writeCtx, cancel := bestEffortContext(ctx, writeTimeout)
+	defer cancel()
+	if err := s.bulkJobs.SaveProgress(writeCtx, job); err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+	return nil
+}
+
+// applyBulkAction acts on one user the way the single-user admin
+// endpoints do, with the same audit entry and cache invalidation
+func (s *UserService) applyBulkAction(ctx context.Context, action domain.BulkAction, id uint, reason string) error {
+	switch action {
+	case domain.BulkSuspend:
+		return s.BanUser(ctx, id, reason, 0)
+	case domain.BulkUnsuspend:
+		return s.UnbanUser(ctx, id, reason, 0)
+	case domain.BulkForcePasswordReset:
+		return s.forcePasswordReset(ctx, id, reason, 0)
+	}
+	return fmt.Errorf("unknown bulk action %q", action)
+}
+
+// bulkErrorMessage is what a failure is recorded as. Unexpected errors are
+// kept whole, since the report is only for operators.
+func bulkErrorMessage(err error) string {
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return "user not found"
+	}
+	return err.Error()
+}
+
+// bulkReport lists every user of a finished job with its outcome
+func bulkReport(job *domain.BulkJob) io.Reader {
+	failures := make(map[uint]string, len(job.Failures))
+	for _, failure := range job.Failures {
+		failures[failure.UserID] = failure.Error
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"user_id", "outcome", "error"})
+	for _, id := range job.UserIDs {
+		outcome, reason := "ok", ""
+		if msg, failed := failures[id]; failed {
+			outcome, reason = "failed", msg
+		}
+		w.Write([]string{strconv.FormatUint(uint64(id), 10), outcome, reason})
+	}
+	w.Flush()
+	return &buf
+}
@@ -0,0 +1,163 @@
+// internal/application/bulk_jobs_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+func TestBulkJobs_RunsInBatchesAndCollectsFailures(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	jobs := testsupport.NewBulkJobRepository()
+	reports := testsupport.NewReportStore()
+	audit := &fakeAuditLogger{}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithBulkJobRepository(jobs),
+		application.WithReportStore(reports),
+		application.WithAuditLogger(audit),
+	)
+	alice := repo.AddUser("alice@example.com", "secret123")
+	bob := repo.AddUser("bob@example.com", "secret123")
+	ctx := context.Background()
+
+	// Spans three batches; everyone but alice and bob is missing
+	ids := []uint{alice.ID, alice.ID}
+	for id := uint(1000); len(ids) < 2*application.BulkJobBatchSize+50; id++ {
+		ids = append(ids, id)
+	}
+	ids = append(ids, bob.ID)
+	job := &domain.BulkJob{Action: domain.BulkSuspend, Reason: "fraud ring", UserIDs: ids}
+	if err := svc.StartBulkJob(ctx, job); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if job.ID == 0 || job.Status != domain.BulkJobQueued || job.Total() != len(ids)-1 {
+		t.Fatalf("expected the job queued without the repeat, got %+v", job)
+	}
+
+	if err := svc.RunBulkJobs(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	svc.Wait()
+
+	got, err := svc.GetBulkJob(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Status != domain.BulkJobDone || got.Processed != got.Total() || got.Succeeded() != 2 ||
+		got.StartedAt == nil || got.FinishedAt == nil {
+		t.Fatalf("expected the job finished despite the failures, got %+v", got)
+	}
+	if len(got.Failures) != got.Total()-2 || got.Failures[0].Error != "user not found" {
+		t.Errorf("expected each missing user recorded, got %v", got.Failures[:1])
+	}
+	for _, user := range []*domain.User{alice, bob} {
+		if stored, _ := repo.User(user.ID); stored.Status != domain.StatusBanned {
+			t.Errorf("expected user %d suspended, got %s", user.ID, stored.Status)
+		}
+	}
+	want := []string{application.AuditUserBanned, application.AuditUserBanned}
+	if actions := auditActions(audit); !equalStrings(actions, want) {
+		t.Errorf("audited %v, want %v", actions, want)
+	}
+	if audit.entries[0].Reason != "fraud ring (bulk job 1)" {
+		t.Errorf("expected the job named in the audit reason, got %q", audit.entries[0].Reason)
+	}
+
+	report, ok := reports.Report(got.ReportKey)
+	if !ok {
+		t.Fatalf("expected a report stored under %q", got.ReportKey)
+	}
+	lines := strings.Split(strings.TrimSpace(report), "\n")
+	if len(lines) != got.Total()+1 || lines[1] != "1,ok," || lines[2] != "1000,failed,user not found" {
+		t.Errorf("unexpected report starting %q", lines[:3])
+	}
+	link, err := svc.BulkJobReportURL(ctx, got)
+	if err != nil || link != "https://reports.test/"+got.ReportKey {
+		t.Errorf("expected a report link, got %q (%v)", link, err)
+	}
+
+	if _, err := svc.GetBulkJob(ctx, 99); !errors.Is(err, domain.ErrBulkJobNotFound) {
+		t.Errorf("expected ErrBulkJobNotFound, got %v", err)
+	}
+}
+
+func TestBulkJobs_ResumesWhereAPassStopped(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	jobs := testsupport.NewBulkJobRepository()
+	audit := &fakeAuditLogger{}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithBulkJobRepository(jobs),
+		application.WithAuditLogger(audit),
+	)
+	var ids []uint
+	for i := 0; i < 40; i++ {
+		ids = append(ids, repo.AddUser(strings.Repeat("u", i+1)+"@example.com", "secret123").ID)
+	}
+	job := &domain.BulkJob{Action: domain.BulkForcePasswordReset, Reason: "leak", UserIDs: ids}
+	if err := svc.StartBulkJob(context.Background(), job); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	// A pass that runs out of time saves what it got through
+	repo.ReadDelay = 5 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := svc.RunBulkJobs(ctx); err != nil {
+		t.Fatalf("first pass: %v", err)
+	}
+	partial, _ := svc.GetBulkJob(context.Background(), job.ID)
+	if partial.Status != domain.BulkJobRunning || partial.Processed == 0 || partial.Processed >= len(ids) || len(partial.Failures) != 0 {
+		t.Fatalf("expected the job part done without failures, got %d/%d %s %v",
+			partial.Processed, len(ids), partial.Status, partial.Failures)
+	}
+
+	repo.ReadDelay = 0
+	if err := svc.RunBulkJobs(context.Background()); err != nil {
+		t.Fatalf("second pass: %v", err)
+	}
+	svc.Wait()
+	done, _ := svc.GetBulkJob(context.Background(), job.ID)
+	if done.Status != domain.BulkJobDone || done.Succeeded() != len(ids) || done.ReportKey != "" {
+		t.Fatalf("expected the job finished without a report store, got %+v", done)
+	}
+	// Nobody was acted on twice
+	if actions := auditActions(audit); len(actions) != len(ids) {
+		t.Errorf("expected %d audit entries, got %d", len(ids), len(actions))
+	}
+}
+
+func TestBulkJobs_Validation(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	ctx := context.Background()
+
+	unconfigured := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+	if err := unconfigured.StartBulkJob(ctx, &domain.BulkJob{}); !errors.Is(err, application.ErrBulkJobsNotConfigured) {
+		t.Errorf("expected ErrBulkJobsNotConfigured, got %v", err)
+	}
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithBulkJobRepository(testsupport.NewBulkJobRepository()),
+	)
+	tooMany := make([]uint, application.MaxBulkJobUsers+1)
+	for i := range tooMany {
+		tooMany[i] = uint(i + 1)
+	}
+	cases := map[string]*domain.BulkJob{
+		"action":   {Action: "tag", Reason: "r", UserIDs: []uint{1}},
+		"user_ids": {Action: domain.BulkSuspend, Reason: "r", UserIDs: tooMany},
+		"reason":   {Action: domain.BulkUnsuspend, Reason: "  ", UserIDs: []uint{1}},
+	}
+	for field, job := range cases {
+		var verr *application.ValidationError
+		if err := svc.StartBulkJob(ctx, job); !errors.As(err, &verr) || verr.Fields[field] == "" {
+			t.Errorf("%s: expected a field error, got %v", field, err)
+		}
+	}
+}
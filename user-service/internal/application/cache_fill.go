@@ -0,0 +1,43 @@
+package application
+
+import (
+	"context"
+
+	"user-service/internal/domain"
+)
+
+// Write paths that warm the user cache, as reported to
+// CacheObserver.ObserveCacheWarm
+const (
+	CacheWarmRegister = "register"
+	CacheWarmLogin    = "login"
+)
+
+// cacheUser stores user under its ID, and under its email when byEmail.
+// An account written recently is only kept briefly, since the copy may
+// predate the write on a lagging replica. It runs after the client may
+// have gone, and failures are ignored: the next read fills the entry.
+func (s *UserService) cacheUser(ctx context.Context, user *domain.User, recent, byEmail bool) {
+	cacheCtx, cancel := bestEffortContext(ctx, cacheOpTimeout)
+	defer cancel()
+	if recent {
+		_ = s.cache.SetWithTTL(cacheCtx, user, RecentWriteCacheTTL)
+	} else {
+		_ = s.cache.Set(cacheCtx, user)
+	}
+	if byEmail {
+		_ = s.cache.SetByEmail(cacheCtx, user.Email, user)
+	}
+}
+
+// warmCache caches a user the write path just loaded or created, so the
+// client's next request doesn't miss
+func (s *UserService) warmCache(ctx context.Context, user *domain.User, source string) {
+	if s.cache == nil {
+		return
+	}
+	s.cacheUser(ctx, user, s.writtenRecently(ctx, user.ID), true)
+	if s.cacheObserver != nil {
+		s.cacheObserver.ObserveCacheWarm(source)
+	}
+}
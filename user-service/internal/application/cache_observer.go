@@ -0,0 +1,23 @@
+package application
+
+// CacheObserver is told how each batch of cache lookups went. Erased users
+// found in the cache count as misses, since the read falls through.
+type CacheObserver interface {
+	ObserveCacheReads(hits, misses int)
+	// ObserveCacheWarm counts entries written by a write path rather than
+	// a read miss; source is one of the CacheWarm constants
+	ObserveCacheWarm(source string)
+}
+
+// WithCacheObserver reports user cache hits and misses to observer
+func WithCacheObserver(observer CacheObserver) Option {
+	return func(s *UserService) {
+		s.cacheObserver = observer
+	}
+}
+
+func (s *UserService) observeCacheReads(hits, misses int) {
+	if s.cacheObserver != nil {
+		s.cacheObserver.ObserveCacheReads(hits, misses)
+	}
+}
@@ -0,0 +1,55 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"user-service/internal/logging"
+)
+
+const (
+	cleanupAttempts     = 3
+	cleanupBaseBackoff  = 200 * time.Millisecond
+	cleanupStepDeadline = 5 * time.Second
+)
+
+// afterCommit runs a best-effort step once the main write has committed.
+// The first attempt runs inline on a context that survives client
+// disconnects; on failure the step is retried in the background with
+// exponential backoff instead of failing the request.
+func (s *UserService) afterCommit(ctx context.Context, step string, fn func(ctx context.Context) error) {
+	ctx = context.WithoutCancel(ctx)
+
+	attemptCtx, cancel := context.WithTimeout(ctx, cleanupStepDeadline)
+	err := fn(attemptCtx)
+	cancel()
+	if err == nil {
+		return
+	}
+	logging.Printf(ctx, "Post-commit step %q failed (attempt 1/%d): %v", step, cleanupAttempts, err)
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+
+		backoff := cleanupBaseBackoff
+		for attempt := 2; attempt <= cleanupAttempts; attempt++ {
+			time.Sleep(backoff)
+			backoff *= 2
+
+			attemptCtx, cancel := context.WithTimeout(ctx, cleanupStepDeadline)
+			err := fn(attemptCtx)
+			cancel()
+			if err == nil {
+				return
+			}
+			logging.Printf(ctx, "Post-commit step %q failed (attempt %d/%d): %v", step, attempt, cleanupAttempts, err)
+		}
+	}()
+}
+
+// Wait blocks until background cleanup retries have finished. Call it during
+// shutdown so retries are not cut off mid-flight.
+func (s *UserService) Wait() {
+	s.background.Wait()
+}
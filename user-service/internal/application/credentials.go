@@ -0,0 +1,32 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"user-service/internal/domain"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// credentials loads the password hash of user id. Nothing else in the
+// service sees it: users are cached, returned and published without it.
+func (s *UserService) credentials(ctx context.Context, id uint) (*domain.Credentials, error) {
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	defer cancel()
+	credentials, err := s.repo.GetCredentials(readCtx, id)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credentials: %w", err)
+	}
+	return credentials, nil
+}
+
+// passwordMatches reports whether the normalized password is the one
+// credentials were set with
+func passwordMatches(credentials *domain.Credentials, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(credentials.PasswordHash), []byte(password)) == nil
+}
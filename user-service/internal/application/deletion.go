@@ -0,0 +1,447 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/normalize"
+)
+
+// Event types for the deletion workflow. EventUserDeleted is only published
+// once the account has actually been erased.
+const (
+	EventUserDeletionRequested = "user.deletion_requested"
+	EventUserDeletionCancelled = "user.deletion_cancelled"
+)
+
+// DefaultDeletionGracePeriod is how long a user can change their mind
+// before their personal data is erased
+const DefaultDeletionGracePeriod = 30 * 24 * time.Hour
+
+const (
+	// erasureBatchSize bounds how many accounts one job pass erases
+	erasureBatchSize = 100
+)
+
+// errStatusChanged means another request moved the account to a different
+// state between our read and our guarded write
+var errStatusChanged = errors.New("account status changed concurrently")
+
+// PendingDeletion is an account waiting out its grace period
+type PendingDeletion struct {
+	UserID      uint
+	Email       string
+	RequestedAt time.Time
+	EraseAfter  time.Time
+}
+
+// WithClock replaces time.Now, letting tests fast-forward the grace period
+func WithClock(now func() time.Time) Option {
+	return func(s *UserService) {
+		s.now = now
+	}
+}
+
+// WithDeletionGracePeriod sets how long a deletion request can be cancelled
+func WithDeletionGracePeriod(d time.Duration) Option {
+	return func(s *UserService) {
+		s.deletionGracePeriod = d
+	}
+}
+
+// DeleteUser starts the erasure workflow. The account is marked
+// pending_deletion and logged out everywhere, but nothing is erased until
+// the grace period ends; logging in again before then cancels the request.
+func (s *UserService) DeleteUser(ctx context.Context, id uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if err != nil {
+		return err
+	}
+	return s.requestDeletion(ctx, user)
+}
+
+// DeleteUserWithPassword is DeleteUser for the account holder, who must
+// confirm with their current password so a stolen token alone can't
+// delete the account. A wrong one is ErrInvalidCredentials.
+func (s *UserService) DeleteUserWithPassword(ctx context.Context, id uint, password string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if err != nil {
+		return err
+	}
+	credentials, err := s.credentials(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !passwordMatches(credentials, normalize.Password(password)) {
+		return ErrInvalidCredentials
+	}
+	return s.requestDeletion(ctx, user)
+}
+
+// requestDeletion moves user to pending_deletion, raising their token
+// version in the same transaction so every token issued so far stops
+// working at once, with or without Redis
+func (s *UserService) requestDeletion(ctx context.Context, user *domain.User) error {
+	id := user.ID
+	switch {
+	case user.IsPendingDeletion():
+		// Repeating the request must not restart the grace period
+		return nil
+	case user.IsBanned():
+		// Cancelling on login would otherwise lift the ban
+		return ErrUserBanned
+	}
+
+	requestedAt := s.now().UTC()
+	err := s.transition(ctx, id, domain.StatusActive, map[string]interface{}{
+		"status":                string(domain.StatusPendingDeletion),
+		"deletion_requested_at": requestedAt,
+	}, &AuditEntry{
+		Action:    AuditDeletionRequested,
+		ActorID:   id,
+		TargetID:  id,
+		Reason:    "requested by user",
+		CreatedAt: requestedAt,
+	}, func(txCtx context.Context, tx *TxService) error {
+		current, err := nextTokenVersion(txCtx, tx, id)
+		if err != nil {
+			return err
+		}
+		user.TokenVersion = current.TokenVersion
+		return tx.UpdateFields(txCtx, id, map[string]interface{}{
+			"token_version": user.TokenVersion,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to request deletion: %w", err)
+	}
+
+	s.cacheTokenVersion(ctx, user)
+	s.invalidateUser(ctx, user)
+	s.updateBlocklist(ctx, id)
+
+	if s.sessions != nil {
+		s.afterCommit(ctx, "revoke sessions", func(ctx context.Context) error {
+			return s.sessions.RevokeUserSessions(ctx, id)
+		})
+	}
+
+	s.publishAfterCommit(ctx, Event{
+		Type:       EventUserDeletionRequested,
+		UserID:     id,
+		OccurredAt: requestedAt,
+		Data: map[string]interface{}{
+			"erase_after": requestedAt.Add(s.deletionGracePeriod),
+		},
+	})
+
+	return nil
+}
+
+// ListPendingDeletions returns one page of the accounts waiting out their
+// grace period, oldest request first, and how many are waiting in all
+func (s *UserService) ListPendingDeletions(ctx context.Context, page, pageSize int) ([]*PendingDeletion, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	users, total, err := s.repo.PagePendingDeletion(readCtx, (page-1)*pageSize, pageSize)
+	cancel()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pending := make([]*PendingDeletion, 0, len(users))
+	for _, u := range users {
+		if u.DeletionRequestedAt == nil {
+			continue
+		}
+		pending = append(pending, &PendingDeletion{
+			UserID:      u.ID,
+			Email:       u.Email,
+			RequestedAt: *u.DeletionRequestedAt,
+			EraseAfter:  u.DeletionRequestedAt.Add(s.deletionGracePeriod),
+		})
+	}
+	return pending, total, nil
+}
+
+// CancelDeletion withdraws a pending deletion request on the user's behalf
+func (s *UserService) CancelDeletion(ctx context.Context, id uint, actorID uint, reason string) error {
+	user, err := s.pendingDeletion(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.cancelDeletion(ctx, user, actorID, reason)
+}
+
+// ExpediteDeletion erases a pending account now instead of waiting for the
+// grace period to end
+func (s *UserService) ExpediteDeletion(ctx context.Context, id uint, actorID uint, reason string) error {
+	user, err := s.pendingDeletion(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.eraseUser(ctx, user, actorID, reason, true)
+}
+
+// ProcessDueDeletions erases one batch of accounts whose grace period has
+// ended and returns how many were erased. Failures are collected so one
+// bad row doesn't hold up the rest of the batch.
+func (s *UserService) ProcessDueDeletions(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	cutoff := s.now().Add(-s.deletionGracePeriod)
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	users, err := s.repo.ListPendingDeletion(readCtx, cutoff, erasureBatchSize)
+	cancel()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due deletions: %w", err)
+	}
+
+	var errs []error
+	erased := 0
+	for _, user := range users {
+		if err := ctx.Err(); err != nil {
+			return erased, err
+		}
+
+		err := s.eraseUser(ctx, user, 0, "grace period elapsed", false)
+		if errors.Is(err, ErrDeletionNotPending) {
+			// Cancelled by a login since we listed it
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", user.ID, err))
+			continue
+		}
+		erased++
+	}
+
+	return erased, errors.Join(errs...)
+}
+
+func (s *UserService) pendingDeletion(ctx context.Context, id uint) (*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.IsPendingDeletion() {
+		return nil, ErrDeletionNotPending
+	}
+	return user, nil
+}
+
+func (s *UserService) cancelDeletion(ctx context.Context, user *domain.User, actorID uint, reason string) error {
+	cancelledAt := s.now().UTC()
+	err := s.transition(ctx, user.ID, domain.StatusPendingDeletion, map[string]interface{}{
+		"status":                string(domain.StatusActive),
+		"deletion_requested_at": nil,
+	}, &AuditEntry{
+		Action:    AuditDeletionCancelled,
+		ActorID:   actorID,
+		TargetID:  user.ID,
+		Reason:    reason,
+		CreatedAt: cancelledAt,
+	}, nil)
+	if errors.Is(err, errStatusChanged) {
+		return ErrDeletionNotPending
+	}
+	if err != nil {
+		return err
+	}
+
+	s.invalidateUser(ctx, user)
+	s.updateBlocklist(ctx, user.ID)
+	s.publishAfterCommit(ctx, Event{
+		Type:       EventUserDeletionCancelled,
+		UserID:     user.ID,
+		OccurredAt: cancelledAt,
+	})
+
+	return nil
+}
+
+// eraseUser anonymizes every personal field and soft-deletes the row in one
+// transaction. The row itself is kept so audit logs still resolve.
+func (s *UserService) eraseUser(ctx context.Context, user *domain.User, actorID uint, reason string, expedited bool) error {
+	erasedAt := s.now().UTC()
+
+	metadata := map[string]interface{}{"expedited": expedited}
+	if user.DeletionRequestedAt != nil {
+		metadata["requested_at"] = user.DeletionRequestedAt.UTC()
+	}
+
+	err := s.transition(ctx, user.ID, domain.StatusPendingDeletion, map[string]interface{}{
+		"status":            string(domain.StatusErased),
+		"username":          fmt.Sprintf("deleted-%d", user.ID),
+		"email":             fmt.Sprintf("deleted-%d@erased.invalid", user.ID),
+		"password":          "",
+		"first_name":        "",
+		"last_name":         "",
+		"email_verified_at": nil,
+		"last_login":        nil,
+		"preferences":       domain.Preferences{},
+		// Notification choices go back to their defaults
+		"mute_security_alerts":   false,
+		"notify_marketing":       false,
+		"notify_product_updates": false,
+	}, &AuditEntry{
+		Action:    AuditUserErased,
+		ActorID:   actorID,
+		TargetID:  user.ID,
+		Reason:    reason,
+		Metadata:  metadata,
+		CreatedAt: erasedAt,
+	}, func(ctx context.Context, tx *TxService) error {
+		if err := tx.DeleteAccessTokens(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := tx.DeleteRecoveryCodes(ctx, user.ID); err != nil {
+			return err
+		}
+		return tx.SoftDelete(ctx, user.ID)
+	})
+	if errors.Is(err, errStatusChanged) {
+		return ErrDeletionNotPending
+	}
+	if err != nil {
+		return fmt.Errorf("failed to erase user: %w", err)
+	}
+
+	s.invalidateUser(ctx, user)
+
+	if s.sessions != nil {
+		s.afterCommit(ctx, "revoke sessions", func(ctx context.Context) error {
+			return s.sessions.RevokeUserSessions(ctx, user.ID)
+		})
+	}
+	// Session rows and known devices live outside the transaction. They
+	// record where the user logged in from, so they go too.
+	if s.sessionRepo != nil {
+		s.afterCommit(ctx, "anonymize sessions", func(ctx context.Context) error {
+			return s.sessionRepo.Anonymize(ctx, user.ID)
+		})
+	}
+	if s.devices != nil {
+		s.afterCommit(ctx, "forget devices", func(ctx context.Context) error {
+			return s.devices.ForgetDevices(ctx, user.ID)
+		})
+	}
+
+	// Consumers key their own copies by email, so it goes out one last time
+	s.publishAfterCommit(ctx, Event{
+		Type:       EventUserDeleted,
+		UserID:     user.ID,
+		OccurredAt: erasedAt,
+		Data:       map[string]interface{}{"email": user.Email},
+	})
+
+	return nil
+}
+
+// transition moves an account out of status from, together with its audit
+// entry, in one transaction. then runs inside the same transaction when set.
+func (s *UserService) transition(
+	ctx context.Context,
+	id uint,
+	from domain.UserStatus,
+	fields map[string]interface{},
+	entry *AuditEntry,
+	then func(ctx context.Context, tx *TxService) error,
+) error {
+	txCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+
+	return s.WithTransaction(txCtx, func(txCtx context.Context, tx *TxService) error {
+		updated, err := tx.UpdateFieldsIfStatus(txCtx, id, from, fields)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return errStatusChanged
+		}
+
+		if then != nil {
+			if err := then(txCtx, tx); err != nil {
+				return err
+			}
+		}
+
+		return tx.Audit(txCtx, entry)
+	})
+}
+
+// invalidateUser drops both cache entries for the user after a commit
+func (s *UserService) invalidateUser(ctx context.Context, user *domain.User) {
+	s.markWritten(ctx, user.ID)
+	if s.cache == nil {
+		return
+	}
+	s.afterCommit(ctx, "invalidate cache", func(ctx context.Context) error {
+		if err := s.cache.Delete(ctx, user.ID); err != nil {
+			return err
+		}
+		return s.cache.DeleteByEmail(ctx, user.Email)
+	})
+}
+
+// updateBlocklist brings the user's entry in the inactive set in line with
+// the status that committed. Membership is read back from the primary rather
+// than taken from the caller, so lifting one restriction (an unban, a
+// cancelled deletion) never unblocks a user another one still applies to.
+// Erased users are never removed: their entry keeps any token that outlives
+// the erasure from being accepted.
+func (s *UserService) updateBlocklist(ctx context.Context, id uint) {
+	if s.blocklist == nil {
+		return
+	}
+	s.afterCommit(ctx, "update blocklist", func(ctx context.Context) error {
+		user, err := s.repo.GetByID(WithPrimaryRead(ctx), id)
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return s.blocklist.Block(ctx, id)
+		}
+		if err != nil {
+			return err
+		}
+		if user.Status != domain.StatusActive {
+			return s.blocklist.Block(ctx, id)
+		}
+		return s.blocklist.Unblock(ctx, id)
+	})
+}
+
+func (s *UserService) publishAfterCommit(ctx context.Context, event Event) {
+	if s.events == nil {
+		return
+	}
+	s.afterCommit(ctx, "publish "+event.Type+" event", func(ctx context.Context) error {
+		return s.events.Publish(ctx, event)
+	})
+}
@@ -0,0 +1,449 @@
+// internal/application/deletion_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+func auditActions(audit *fakeAuditLogger) []string {
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	actions := make([]string, len(audit.entries))
+	for i, e := range audit.entries {
+		actions[i] = e.Action
+	}
+	return actions
+}
+
+func eventTypes(publisher *fakePublisher) []string {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+	types := make([]string, len(publisher.events))
+	for i, e := range publisher.events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDeleteUser_MarksPendingAndLogsOut(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	_ = cache.Set(context.Background(), user)
+	revoker := &fakeRevoker{}
+	publisher := &fakePublisher{}
+	audit := &fakeAuditLogger{}
+	clock := testsupport.NewClock()
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache,
+		application.WithSessionRevoker(revoker),
+		application.WithEventPublisher(publisher),
+		application.WithAuditLogger(audit),
+		application.WithClock(clock.Now),
+	)
+
+	if err := svc.DeleteUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	svc.Wait()
+
+	stored, err := repo.GetByID(context.Background(), user.ID)
+	if err != nil {
+		t.Fatal("nothing may be erased before the grace period ends")
+	}
+	if !stored.IsPendingDeletion() {
+		t.Errorf("expected status %s, got %s", domain.StatusPendingDeletion, stored.Status)
+	}
+	if stored.DeletionRequestedAt == nil || !stored.DeletionRequestedAt.Equal(clock.Now()) {
+		t.Errorf("expected deletion_requested_at %v, got %v", clock.Now(), stored.DeletionRequestedAt)
+	}
+	if _, err := cache.Get(context.Background(), user.ID); err == nil {
+		t.Error("expected cache entry to be invalidated")
+	}
+	if len(revoker.revoked) != 1 || revoker.revoked[0] != user.ID {
+		t.Errorf("expected sessions revoked for user %d, got %v", user.ID, revoker.revoked)
+	}
+	if got := auditActions(audit); !equalStrings(got, []string{application.AuditDeletionRequested}) {
+		t.Errorf("unexpected audit trail %v", got)
+	}
+	if got := eventTypes(publisher); !equalStrings(got, []string{application.EventUserDeletionRequested}) {
+		t.Errorf("unexpected events %v", got)
+	}
+}
+
+func TestDeleteUser_BlocksTokensUntilCancelled(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	blocklist := newFakeBlocklist()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithUserBlocklist(blocklist),
+	)
+
+	if err := svc.DeleteUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	svc.Wait()
+	if !blocklist.blocked[user.ID] {
+		t.Fatal("expected a deleted account to be on the blocklist")
+	}
+
+	if _, err := svc.Login(context.Background(), "alice@example.com", "secret123"); err != nil {
+		t.Fatalf("login during grace period must succeed: %v", err)
+	}
+	svc.Wait()
+	if blocklist.blocked[user.ID] {
+		t.Error("expected cancelling the deletion to lift the block")
+	}
+}
+
+func TestGetUser_HidesErasedRows(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache)
+
+	// An erased row that an unscoped read still returns
+	if err := repo.UpdateFields(context.Background(), user.ID, map[string]interface{}{
+		"status": string(domain.StatusErased),
+	}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	if _, err := svc.GetUser(context.Background(), user.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+	if _, err := cache.Get(context.Background(), user.ID); err == nil {
+		t.Error("an erased user must not be written back to the cache")
+	}
+}
+
+func TestDeleteUser_RepeatKeepsGracePeriod(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	clock := testsupport.NewClock()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithClock(clock.Now))
+
+	if err := svc.DeleteUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	requestedAt := clock.Now()
+
+	clock.Advance(10 * 24 * time.Hour)
+	if err := svc.DeleteUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("repeated delete failed: %v", err)
+	}
+
+	stored, _ := repo.GetByID(context.Background(), user.ID)
+	if !stored.DeletionRequestedAt.Equal(requestedAt) {
+		t.Errorf("grace period restarted: %v", stored.DeletionRequestedAt)
+	}
+}
+
+func TestDeleteUser_RefusesBannedUser(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	if err := svc.BanUser(context.Background(), user.ID, "spam", 0); err != nil {
+		t.Fatalf("ban failed: %v", err)
+	}
+	if err := svc.DeleteUser(context.Background(), user.ID); !errors.Is(err, application.ErrUserBanned) {
+		t.Fatalf("expected ErrUserBanned, got %v", err)
+	}
+}
+
+func TestDeleteUser_RetriesFailedCleanupInBackground(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	cache.FailDeletes = 1
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache)
+
+	if err := svc.DeleteUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("cache failure must not fail the request: %v", err)
+	}
+	svc.Wait()
+
+	if len(cache.DeletedIDs()) != 1 {
+		t.Errorf("expected cache invalidation to succeed on retry, got %v", cache.DeletedIDs())
+	}
+}
+
+func TestDeleteUser_NotFound(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	if err := svc.DeleteUser(context.Background(), 99); err == nil {
+		t.Fatal("expected error for unknown user")
+	}
+}
+
+func TestLogin_CancelsPendingDeletion(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	publisher := &fakePublisher{}
+	audit := &fakeAuditLogger{}
+	clock := testsupport.NewClock()
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithEventPublisher(publisher),
+		application.WithAuditLogger(audit),
+		application.WithClock(clock.Now),
+	)
+
+	if err := svc.DeleteUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	clock.Advance(29 * 24 * time.Hour)
+	got, err := svc.Login(context.Background(), "alice@example.com", "secret123")
+	if err != nil {
+		t.Fatalf("login during grace period must succeed: %v", err)
+	}
+	if got.IsPendingDeletion() {
+		t.Error("returned user still pending deletion")
+	}
+
+	stored, _ := repo.GetByID(context.Background(), user.ID)
+	if stored.Status != domain.StatusActive || stored.DeletionRequestedAt != nil {
+		t.Errorf("expected request cancelled, got status %s requested_at %v", stored.Status, stored.DeletionRequestedAt)
+	}
+
+	// Nothing is due any more, even once the original grace period ends
+	clock.Advance(7 * 24 * time.Hour)
+	if erased, err := svc.ProcessDueDeletions(context.Background()); err != nil || erased != 0 {
+		t.Fatalf("expected nothing erased, got %d (%v)", erased, err)
+	}
+	svc.Wait()
+
+	wantAudit := []string{application.AuditDeletionRequested, application.AuditDeletionCancelled}
+	if got := auditActions(audit); !equalStrings(got, wantAudit) {
+		t.Errorf("expected audit trail %v, got %v", wantAudit, got)
+	}
+	wantEvents := []string{application.EventUserDeletionRequested, application.EventUserDeletionCancelled}
+	if got := eventTypes(publisher); !equalStrings(got, wantEvents) {
+		t.Errorf("expected events %v, got %v", wantEvents, got)
+	}
+}
+
+func TestProcessDueDeletions_ErasesAfterGracePeriod(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	other := repo.AddUser("bob@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	publisher := &fakePublisher{}
+	audit := &fakeAuditLogger{}
+	clock := testsupport.NewClock()
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache,
+		application.WithEventPublisher(publisher),
+		application.WithAuditLogger(audit),
+		application.WithClock(clock.Now),
+	)
+
+	if err := svc.DeleteUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	clock.Advance(application.DefaultDeletionGracePeriod - time.Minute)
+	if erased, err := svc.ProcessDueDeletions(context.Background()); err != nil || erased != 0 {
+		t.Fatalf("expected nothing erased inside the grace period, got %d (%v)", erased, err)
+	}
+
+	clock.Advance(2 * time.Minute)
+	erased, err := svc.ProcessDueDeletions(context.Background())
+	if err != nil || erased != 1 {
+		t.Fatalf("expected one erasure, got %d (%v)", erased, err)
+	}
+	svc.Wait()
+
+	if _, err := repo.GetByID(context.Background(), user.ID); err == nil {
+		t.Error("expected erased user to be soft-deleted")
+	}
+	row, ok := repo.DeletedUser(user.ID)
+	if !ok {
+		t.Fatal("expected the anonymized row to be kept")
+	}
+	if row.Email == "alice@example.com" || repo.PasswordHash(user.ID) != "" || row.Username == "user" {
+		t.Errorf("personal data survived erasure: %+v", row)
+	}
+	if row.Status != domain.StatusErased {
+		t.Errorf("expected status %s, got %s", domain.StatusErased, row.Status)
+	}
+	if _, err := repo.GetByID(context.Background(), other.ID); err != nil {
+		t.Error("unrelated user must be untouched")
+	}
+	if len(cache.DeletedEmails()) == 0 || cache.DeletedEmails()[len(cache.DeletedEmails())-1] != "alice@example.com" {
+		t.Errorf("expected the original email to be evicted, got %v", cache.DeletedEmails())
+	}
+
+	wantAudit := []string{application.AuditDeletionRequested, application.AuditUserErased}
+	if got := auditActions(audit); !equalStrings(got, wantAudit) {
+		t.Errorf("expected audit trail %v, got %v", wantAudit, got)
+	}
+	if expedited := audit.entries[1].Metadata["expedited"]; expedited != false {
+		t.Errorf("expected expedited=false, got %v", expedited)
+	}
+	wantEvents := []string{application.EventUserDeletionRequested, application.EventUserDeleted}
+	if got := eventTypes(publisher); !equalStrings(got, wantEvents) {
+		t.Errorf("expected events %v, got %v", wantEvents, got)
+	}
+}
+
+func TestExpediteDeletion_ErasesImmediately(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	audit := &fakeAuditLogger{}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithAuditLogger(audit))
+
+	if err := svc.ExpediteDeletion(context.Background(), user.ID, 7, "support ticket"); !errors.Is(err, application.ErrDeletionNotPending) {
+		t.Fatalf("expected ErrDeletionNotPending without a request, got %v", err)
+	}
+
+	if err := svc.DeleteUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if err := svc.ExpediteDeletion(context.Background(), user.ID, 7, "support ticket"); err != nil {
+		t.Fatalf("expedite failed: %v", err)
+	}
+
+	if _, ok := repo.DeletedUser(user.ID); !ok {
+		t.Fatal("expected user to be erased")
+	}
+	last := audit.entries[len(audit.entries)-1]
+	if last.Action != application.AuditUserErased || last.ActorID != 7 || last.Metadata["expedited"] != true {
+		t.Errorf("unexpected audit entry %+v", last)
+	}
+}
+
+func TestExpediteDeletion_ErasesEverythingTiedToTheUser(t *testing.T) {
+	ctx := context.Background()
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	user.Preferences = domain.Preferences{"locale": "fr-FR"}
+	user.Notifications = domain.NotificationPreferences{SecurityAlertsMuted: true, Marketing: true, ProductUpdates: true}
+	repo.Put(user)
+
+	tokens := testsupport.NewAccessTokenRepository()
+	if err := tokens.Create(ctx, &domain.AccessToken{UserID: user.ID, Name: "ci"}, "hash"); err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	codes := testsupport.NewRecoveryCodeRepository()
+	if err := codes.Replace(ctx, user.ID, []string{"a", "b"}, time.Now()); err != nil {
+		t.Fatalf("store codes: %v", err)
+	}
+	devices := testsupport.NewDeviceStore()
+	if _, err := devices.RememberDevice(ctx, user.ID, "laptop"); err != nil {
+		t.Fatalf("remember device: %v", err)
+	}
+	sessions := &fakeSessionRepo{sessions: map[string]*domain.Session{
+		"s1": {ID: "s1", UserID: user.ID, UserAgent: "Firefox", IP: "203.0.113.7", ExpiresAt: time.Now().Add(time.Hour)},
+	}}
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo, tokens, codes), nil,
+		application.WithAccessTokenRepository(tokens),
+		application.WithRecoveryCodeRepository(codes),
+		application.WithDeviceStore(devices),
+		application.WithSessions(sessions, time.Hour),
+	)
+
+	if err := svc.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if err := svc.ExpediteDeletion(ctx, user.ID, 7, "support ticket"); err != nil {
+		t.Fatalf("expedite failed: %v", err)
+	}
+
+	erased, ok := repo.DeletedUser(user.ID)
+	if !ok {
+		t.Fatal("expected user to be erased")
+	}
+	if len(erased.Preferences) != 0 {
+		t.Errorf("expected preferences to be cleared, got %v", erased.Preferences)
+	}
+	if erased.Notifications != (domain.NotificationPreferences{}) {
+		t.Errorf("expected notification settings to be reset, got %+v", erased.Notifications)
+	}
+	if left, _ := tokens.ListByUser(ctx, user.ID); len(left) != 0 {
+		t.Errorf("expected access tokens to be deleted, %d left", len(left))
+	}
+	if hashes := codes.Hashes(user.ID); len(hashes) != 0 {
+		t.Errorf("expected recovery codes to be deleted, %d left", len(hashes))
+	}
+	if n := devices.Devices(user.ID); n != 0 {
+		t.Errorf("expected known devices to be forgotten, %d left", n)
+	}
+	if s := sessions.sessions["s1"]; s.UserAgent != "" || s.IP != "" {
+		t.Errorf("expected session to be anonymized, got user agent %q and IP %q", s.UserAgent, s.IP)
+	}
+}
+
+func TestCancelDeletion_ByAdmin(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	clock := testsupport.NewClock()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithClock(clock.Now))
+
+	if err := svc.DeleteUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	pending, total, err := svc.ListPendingDeletions(context.Background(), 1, 10)
+	if err != nil || len(pending) != 1 || total != 1 {
+		t.Fatalf("expected one pending deletion, got %v of %d (%v)", pending, total, err)
+	}
+	if want := clock.Now().Add(application.DefaultDeletionGracePeriod); !pending[0].EraseAfter.Equal(want) {
+		t.Errorf("expected erase_after %v, got %v", want, pending[0].EraseAfter)
+	}
+
+	if err := svc.CancelDeletion(context.Background(), user.ID, 7, "user called support"); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+	if err := svc.CancelDeletion(context.Background(), user.ID, 7, "again"); !errors.Is(err, application.ErrDeletionNotPending) {
+		t.Fatalf("expected ErrDeletionNotPending, got %v", err)
+	}
+
+	clock.Advance(2 * application.DefaultDeletionGracePeriod)
+	if erased, _ := svc.ProcessDueDeletions(context.Background()); erased != 0 {
+		t.Errorf("cancelled request was erased")
+	}
+}
+
+func TestRunErasurePass_ErasesDueAccounts(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	clock := testsupport.NewClock()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithClock(clock.Now))
+
+	if err := svc.DeleteUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	clock.Advance(application.DefaultDeletionGracePeriod + time.Hour)
+
+	if err := svc.RunErasurePass(context.Background()); err != nil {
+		t.Fatalf("erasure pass: %v", err)
+	}
+	if _, err := repo.GetByID(context.Background(), user.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected the account to be erased, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/normalize"
+)
+
+// ErrEmailChangesNotConfigured is returned by the email change methods
+// when the service was built without an EmailChangeStore
+var ErrEmailChangesNotConfigured = errors.New("email changes not configured")
+
+// ErrInvalidEmailChangeToken covers unknown, used, replaced and expired
+// confirmation tokens alike
+var ErrInvalidEmailChangeToken = errors.New("invalid or expired email change token")
+
+// EmailChangeTTL is how long a token from RequestEmailChange stays usable
+const EmailChangeTTL = 24 * time.Hour
+
+// EmailChangeStore keeps each user's pending email change: the new
+// address and the hash of the token that confirms it. A user has at most
+// one; asking again replaces it.
+type EmailChangeStore interface {
+	// SaveEmailChange makes hash userID's token for moving to email, for ttl
+	SaveEmailChange(ctx context.Context, userID uint, hash, email string, ttl time.Duration) error
+	// ConsumeEmailChange deletes userID's pending change if its token hash
+	// is hash, returning the new address and whether it did. The check and
+	// the delete are one atomic step, so a token can't be used twice.
+	ConsumeEmailChange(ctx context.Context, userID uint, hash string) (email string, ok bool, err error)
+}
+
+// EmailChangeNotifier gets a confirmation token to the address the user
+// wants to move to, typically as a link in an email
+type EmailChangeNotifier interface {
+	SendEmailChange(ctx context.Context, user *domain.User, newEmail, token string, expiresAt time.Time) error
+}
+
+// WithEmailChanges enables RequestEmailChange and ConfirmEmailChange,
+// keeping pending changes in store and delivering tokens with notifier
+func WithEmailChanges(store EmailChangeStore, notifier EmailChangeNotifier) Option {
+	return func(s *UserService) {
+		s.emailChanges = store
+		s.emailChangeNotifier = notifier
+	}
+}
+
+// EmailChange is a user asking to move their account to a new address
+type EmailChange struct {
+	Email    string
+	Password string
+	// Recovered skips the Password check. Only set it for a session opened
+	// by Recover, where the user has proven ownership with a code instead.
+	Recovered bool
+}
+
+// RequestEmailChange starts moving the user to change.Email once they
+// confirm with their current password, sending the new address a token
+// that ConfirmEmailChange takes for EmailChangeTTL. The email only changes
+// then. A wrong password is ErrInvalidCredentials; an address that is
+// already registered is a ValidationError wrapping domain.ErrDuplicateUser.
+// Requesting again voids the previous token.
+func (s *UserService) RequestEmailChange(ctx context.Context, id uint, change EmailChange) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.emailChanges == nil || s.emailChangeNotifier == nil {
+		return ErrEmailChangesNotConfigured
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if !change.Recovered {
+		credentials, err := s.credentials(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !passwordMatches(credentials, normalize.Password(change.Password)) {
+			return ErrInvalidCredentials
+		}
+	}
+
+	email := normalize.Email(change.Email)
+	if email == user.Email {
+		return &ValidationError{Fields: map[string]string{"email": "This is already your email"}}
+	}
+	readCtx, cancel = stepContext(ctx, pointReadTimeout)
+	taken, err := s.repo.ExistsEmail(readCtx, email)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if taken {
+		return duplicateFieldsError([]string{"email"})
+	}
+
+	token, secret, err := newResetToken(id)
+	if err != nil {
+		return fmt.Errorf("failed to generate email change token: %w", err)
+	}
+	expiresAt := s.now().Add(EmailChangeTTL)
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	err = s.emailChanges.SaveEmailChange(writeCtx, id, hashResetToken(secret), email, EmailChangeTTL)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to store email change: %w", err)
+	}
+
+	sendCtx, cancel := stepContext(ctx, mailSendTimeout)
+	defer cancel()
+	if err := s.emailChangeNotifier.SendEmailChange(sendCtx, user, email, token, expiresAt); err != nil {
+		return fmt.Errorf("failed to send email change token: %w", err)
+	}
+	return nil
+}
+
+// ConfirmEmailChange moves the account token was issued for to the address
+// it was sent to, using the token up. The address counts as verified, since
+// following the token proves it. Should someone else have registered the
+// address in the meantime, it fails with a ValidationError wrapping
+// domain.ErrDuplicateUser. Any problem with the token, or an account that
+// can no longer log in, is ErrInvalidEmailChangeToken.
+func (s *UserService) ConfirmEmailChange(ctx context.Context, token string) (*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.emailChanges == nil {
+		return nil, ErrEmailChangesNotConfigured
+	}
+
+	id, secret, ok := parseResetToken(strings.TrimSpace(token))
+	if !ok {
+		return nil, ErrInvalidEmailChangeToken
+	}
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil, ErrInvalidEmailChangeToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	if user.IsBanned() || isErased(user) {
+		return nil, ErrInvalidEmailChangeToken
+	}
+
+	consumeCtx, cancel := stepContext(ctx, writeTimeout)
+	email, used, err := s.emailChanges.ConsumeEmailChange(consumeCtx, id, hashResetToken(secret))
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to use email change token: %w", err)
+	}
+	if !used {
+		return nil, ErrInvalidEmailChangeToken
+	}
+
+	// The write claims the address under lock, so a signup that took it
+	// while the change was pending is caught here
+	user, _, err = s.updateProfile(ctx, id, map[string]string{"email": email}, true)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
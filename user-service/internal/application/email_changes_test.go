@@ -0,0 +1,159 @@
+// internal/application/email_changes_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+type pendingEmailChange struct {
+	hash, email string
+}
+
+// fakeEmailChangeStore is an in-memory application.EmailChangeStore that
+// ignores TTLs
+type fakeEmailChangeStore struct {
+	mu      sync.Mutex
+	pending map[uint]pendingEmailChange
+}
+
+func (s *fakeEmailChangeStore) SaveEmailChange(ctx context.Context, userID uint, hash, email string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[userID] = pendingEmailChange{hash: hash, email: email}
+	return nil
+}
+
+func (s *fakeEmailChangeStore) ConsumeEmailChange(ctx context.Context, userID uint, hash string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending, ok := s.pending[userID]
+	if !ok || pending.hash != hash {
+		return "", false, nil
+	}
+	delete(s.pending, userID)
+	return pending.email, true, nil
+}
+
+// fakeEmailChangeNotifier records the tokens it is handed by new address
+type fakeEmailChangeNotifier struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func (n *fakeEmailChangeNotifier) SendEmailChange(ctx context.Context, user *domain.User, newEmail, token string, expiresAt time.Time) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.tokens[newEmail] = token
+	return nil
+}
+
+func TestEmailChange(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	repo.AddUser("bob@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	notifier := &fakeEmailChangeNotifier{tokens: make(map[string]string)}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache,
+		application.WithEmailChanges(&fakeEmailChangeStore{pending: make(map[uint]pendingEmailChange)}, notifier),
+	)
+	ctx := context.Background()
+
+	if err := svc.RequestEmailChange(ctx, alice.ID, application.EmailChange{Email: "alice.new@example.com", Password: "wrong"}); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if err := svc.RequestEmailChange(ctx, alice.ID, application.EmailChange{Email: "Bob@Example.com", Password: "secret123"}); !errors.Is(err, domain.ErrDuplicateUser) {
+		t.Errorf("expected a taken address refused, got %v", err)
+	}
+	var verr *application.ValidationError
+	if err := svc.RequestEmailChange(ctx, alice.ID, application.EmailChange{Email: "alice@example.com", Password: "secret123"}); !errors.As(err, &verr) {
+		t.Errorf("expected the current address refused, got %v", err)
+	}
+	if len(notifier.tokens) != 0 {
+		t.Fatalf("expected nothing sent, got %v", notifier.tokens)
+	}
+
+	// A second request voids the first token
+	if err := svc.RequestEmailChange(ctx, alice.ID, application.EmailChange{Email: "alice.first@example.com", Password: "secret123"}); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if err := svc.RequestEmailChange(ctx, alice.ID, application.EmailChange{Email: " Alice.New@Example.com", Password: "secret123"}); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if stored, _ := repo.User(alice.ID); stored.Email != "alice@example.com" {
+		t.Fatalf("expected the email kept until confirmed, got %s", stored.Email)
+	}
+	if _, err := svc.ConfirmEmailChange(ctx, notifier.tokens["alice.first@example.com"]); !errors.Is(err, application.ErrInvalidEmailChangeToken) {
+		t.Errorf("expected the replaced token refused, got %v", err)
+	}
+
+	token := notifier.tokens["alice.new@example.com"]
+	user, err := svc.ConfirmEmailChange(ctx, token)
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	svc.Wait()
+	if user.Email != "alice.new@example.com" || !user.IsEmailVerified() {
+		t.Errorf("expected the new address, verified, got %s (%v)", user.Email, user.EmailVerifiedAt)
+	}
+	deleted := cache.DeletedEmails()
+	sort.Strings(deleted)
+	if len(deleted) != 2 || deleted[0] != "alice.new@example.com" || deleted[1] != "alice@example.com" {
+		t.Errorf("expected both addresses evicted from the cache, got %v", deleted)
+	}
+	if _, err := svc.ConfirmEmailChange(ctx, token); !errors.Is(err, application.ErrInvalidEmailChangeToken) {
+		t.Errorf("expected a token to work once, got %v", err)
+	}
+	for _, bad := range []string{"", "nonsense", "99.abc"} {
+		if _, err := svc.ConfirmEmailChange(ctx, bad); !errors.Is(err, application.ErrInvalidEmailChangeToken) {
+			t.Errorf("expected %q refused, got %v", bad, err)
+		}
+	}
+
+	// A recovery session has proven ownership with a code, not the
+	// password, but the new address still has to be confirmed
+	if err := svc.RequestEmailChange(ctx, alice.ID, application.EmailChange{Email: "alice.recovered@example.com", Recovered: true}); err != nil {
+		t.Fatalf("recovered request: %v", err)
+	}
+	if stored, _ := repo.User(alice.ID); stored.Email != "alice.new@example.com" || notifier.tokens["alice.recovered@example.com"] == "" {
+		t.Errorf("expected a token sent and the email kept, got %s", stored.Email)
+	}
+
+	unconfigured := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+	if err := unconfigured.RequestEmailChange(ctx, alice.ID, application.EmailChange{Email: "x@example.com", Password: "secret123"}); !errors.Is(err, application.ErrEmailChangesNotConfigured) {
+		t.Errorf("expected ErrEmailChangesNotConfigured, got %v", err)
+	}
+}
+
+func TestEmailChange_AddressTakenWhilePending(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	notifier := &fakeEmailChangeNotifier{tokens: make(map[string]string)}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithEmailChanges(&fakeEmailChangeStore{pending: make(map[uint]pendingEmailChange)}, notifier),
+	)
+	ctx := context.Background()
+
+	if err := svc.RequestEmailChange(ctx, alice.ID, application.EmailChange{Email: "wanted@example.com", Password: "secret123"}); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	// Someone else registers the address before alice follows the link
+	repo.AddUser("wanted@example.com", "secret123")
+
+	_, err := svc.ConfirmEmailChange(ctx, notifier.tokens["wanted@example.com"])
+	var verr *application.ValidationError
+	if !errors.As(err, &verr) || !errors.Is(err, domain.ErrDuplicateUser) || verr.Fields["email"] == "" {
+		t.Fatalf("expected the taken address refused, got %v", err)
+	}
+	if stored, _ := repo.User(alice.ID); stored.Email != "alice@example.com" {
+		t.Errorf("expected the email unchanged, got %s", stored.Email)
+	}
+}
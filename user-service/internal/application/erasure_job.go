@@ -0,0 +1,30 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// ErasureJobName is the erasure pass's name in the job scheduler
+	ErasureJobName = "user_erasure"
+	// ErasureJobTimeout bounds one pass, and the lease that keeps other
+	// instances from running one at the same time
+	ErasureJobTimeout = 5 * time.Minute
+)
+
+// RunErasurePass erases accounts whose deletion grace period has ended.
+// It is run on a schedule as a singleton job, so with several replicas
+// only one runs a pass at a time.
+func (s *UserService) RunErasurePass(ctx context.Context) error {
+	erased, err := s.ProcessDueDeletions(ctx)
+	if err != nil {
+		return fmt.Errorf("erasure pass failed after erasing %d users: %w", erased, err)
+	}
+	if erased > 0 {
+		log.Printf("Erased %d users past their deletion grace period", erased)
+	}
+	return nil
+}
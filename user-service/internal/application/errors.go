@@ -0,0 +1,68 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidCredentials     = errors.New("invalid credentials")
+	ErrUserBanned             = errors.New("user is banned")
+	ErrEmailAlreadyRegistered = errors.New("email already registered")
+	// ErrEmailBelongsToDeletedAccount is ErrEmailAlreadyRegistered where the
+	// holder is a recently deleted account whose owner proved the address,
+	// so they can be pointed at getting it back instead of at login
+	ErrEmailBelongsToDeletedAccount = fmt.Errorf("%w: belongs to a deleted account", ErrEmailAlreadyRegistered)
+	ErrDeletionNotPending           = errors.New("no pending deletion request")
+	// ErrUserNotActive is banning an account that is already banned or
+	// on its way to erasure
+	ErrUserNotActive = errors.New("user is not active")
+	// ErrUserNotBanned is unbanning an account that isn't banned
+	ErrUserNotBanned = errors.New("user is not banned")
+	// ErrUserNotDeleted is restoring or purging an account that isn't
+	// soft-deleted
+	ErrUserNotDeleted = errors.New("user is not deleted")
+	// ErrUserErased is restoring a soft-deleted account whose personal
+	// data was erased with it, which nothing can bring back
+	ErrUserErased = errors.New("user was erased")
+	// ErrPurgeNotConfirmed is a purge whose confirmation isn't the
+	// account's email
+	ErrPurgeNotConfirmed  = errors.New("purge not confirmed")
+	ErrLoginDenied        = errors.New("login denied")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrRegistrationClosed = errors.New("registration is closed")
+	ErrInviteRequired     = errors.New("invite code required")
+	// ErrInvalidRecoveryCode doesn't say whether the email or the code was
+	// wrong, so recovery can't be used to find accounts
+	ErrInvalidRecoveryCode = errors.New("invalid email or recovery code")
+	// ErrInvalidAccessToken covers unknown, revoked and expired tokens alike
+	ErrInvalidAccessToken = errors.New("invalid access token")
+)
+
+// ValidationError carries per-field problems found by the service. Err is
+// set when one of the fields maps to a more specific sentinel, so callers
+// can still use errors.Is (e.g. ErrEmailAlreadyRegistered -> 409).
+type ValidationError struct {
+	Fields map[string]string
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+": "+e.Fields[k])
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,46 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Event types published by the user service
+const (
+	EventUserDeleted  = "user.deleted"
+	EventUserRestored = "user.restored"
+	EventUserPurged   = "user.purged"
+)
+
+// Event is a domain event emitted after a state change has been committed
+type Event struct {
+	Type       string                 `json:"type"`
+	UserID     uint                   `json:"user_id"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	Data       map[string]interface{} `json:"data,omitempty"`
+}
+
+// EventPublisher delivers events to other services
+type EventPublisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// SessionRevoker invalidates every token issued to a user so far
+type SessionRevoker interface {
+	RevokeUserSessions(ctx context.Context, userID uint) error
+}
+
+// SessionRevokers revokes through each of its revokers, for sessions kept
+// in more than one place. Every revoker is tried even if one fails.
+type SessionRevokers []SessionRevoker
+
+func (r SessionRevokers) RevokeUserSessions(ctx context.Context, userID uint) error {
+	var errs []error
+	for _, revoker := range r {
+		if err := revoker.RevokeUserSessions(ctx, userID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
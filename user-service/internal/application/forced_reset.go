@@ -0,0 +1,103 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"user-service/internal/domain"
+)
+
+// PasswordResetSessionTTL is how long the session Login opens for an
+// account that must reset its password stays usable
+const PasswordResetSessionTTL = 15 * time.Minute
+
+// MaxForcedResetBatch caps how many accounts one ForcePasswordReset call
+// may flag
+const MaxForcedResetBatch = 500
+
+// ForcedResets reports what ForcePasswordReset did with each ID
+type ForcedResets struct {
+	Reset    []uint
+	NotFound []uint
+}
+
+// ForcePasswordReset flags the accounts so their next login can only
+// choose a new password, signs them out everywhere and tells them why by
+// email. IDs of missing or erased accounts are reported, not fatal; any
+// other failure stops the batch, returning what was done so far.
+func (s *UserService) ForcePasswordReset(ctx context.Context, ids []uint, reason string, actorID uint) (*ForcedResets, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) > MaxForcedResetBatch {
+		return nil, &ValidationError{Fields: map[string]string{
+			"user_ids": fmt.Sprintf("at most %d users per request", MaxForcedResetBatch),
+		}}
+	}
+
+	result := &ForcedResets{Reset: []uint{}, NotFound: []uint{}}
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		err := s.forcePasswordReset(ctx, id, reason, actorID)
+		switch {
+		case errors.Is(err, domain.ErrUserNotFound):
+			result.NotFound = append(result.NotFound, id)
+		case err != nil:
+			return result, fmt.Errorf("failed to force password reset for user %d: %w", id, err)
+		default:
+			result.Reset = append(result.Reset, id)
+		}
+	}
+	return result, nil
+}
+
+func (s *UserService) forcePasswordReset(ctx context.Context, id uint, reason string, actorID uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if user.Status == domain.StatusErased {
+		return domain.ErrUserNotFound
+	}
+
+	// The account may be in someone else's hands, so its tokens stop
+	// working with the commit rather than once revocation gets to them
+	updated, err := s.updateAuditedSigningOut(ctx, id, map[string]interface{}{
+		"must_reset_password": true,
+	}, &AuditEntry{
+		Action:    AuditPasswordResetForced,
+		ActorID:   actorID,
+		TargetID:  id,
+		Reason:    reason,
+		CreatedAt: s.now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	user.TokenVersion = updated.TokenVersion
+
+	s.cacheTokenVersion(ctx, user)
+	s.invalidateUser(ctx, user)
+
+	if s.sessions != nil {
+		s.afterCommit(ctx, "revoke sessions", func(ctx context.Context) error {
+			return s.sessions.RevokeUserSessions(ctx, id)
+		})
+	}
+
+	s.sendSecurityAlert(ctx, AlertPasswordResetRequired, user, user.Email, ClientInfo{}, alertDetails{})
+	return nil
+}
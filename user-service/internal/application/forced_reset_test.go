@@ -0,0 +1,106 @@
+// internal/application/forced_reset_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/testsupport"
+)
+
+func TestForcePasswordReset_FlagLifecycle(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	mailer := testsupport.NewMailer()
+	audit := &fakeAuditLogger{}
+	revoker := &fakeRevoker{}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithMailer(mailer),
+		application.WithAuditLogger(audit),
+		application.WithSessionRevoker(revoker),
+	)
+	user := repo.AddUser("alice@example.com", "secret123")
+	user.TokenVersion = 3
+	repo.Put(user)
+	ctx := context.Background()
+
+	result, err := svc.ForcePasswordReset(ctx, []uint{user.ID}, "credential stuffing", 0)
+	if err != nil {
+		t.Fatalf("force: %v", err)
+	}
+	svc.Wait()
+	if len(result.Reset) != 1 || len(result.NotFound) != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if stored, _ := repo.User(user.ID); !stored.MustResetPassword || stored.TokenVersion != user.TokenVersion+1 {
+		t.Fatalf("expected the account flagged and its tokens outdated, got %+v", stored)
+	}
+	if len(revoker.revoked) != 1 || revoker.revoked[0] != user.ID {
+		t.Errorf("expected sessions revoked, got %v", revoker.revoked)
+	}
+	if sent := mailer.Sent(); len(sent) != 1 || sent[0].To != "alice@example.com" || sent[0].Subject != "Please choose a new password" {
+		t.Errorf("expected the user told by email, got %+v", sent)
+	}
+
+	// The password still works; the flag is what the caller acts on
+	loggedIn, err := svc.Login(ctx, "alice@example.com", "secret123")
+	if err != nil || !loggedIn.MustResetPassword {
+		t.Fatalf("expected a flagged login, got %+v (%v)", loggedIn, err)
+	}
+
+	// A forced-reset session needn't repeat the password, but can't keep it
+	err = svc.ChangePassword(ctx, user.ID, application.PasswordChange{New: "secret123", Forced: true})
+	var verr *application.ValidationError
+	if !errors.As(err, &verr) || verr.Fields["new_password"] == "" {
+		t.Fatalf("expected the old password refused, got %v", err)
+	}
+	if err := svc.ChangePassword(ctx, user.ID, application.PasswordChange{New: "N3w-password", Forced: true}); err != nil {
+		t.Fatalf("change: %v", err)
+	}
+	svc.Wait()
+	if stored, _ := repo.User(user.ID); stored.MustResetPassword {
+		t.Error("expected the flag cleared by the change")
+	}
+
+	// With the flag gone, Forced no longer stands in for the password
+	err = svc.ChangePassword(ctx, user.ID, application.PasswordChange{New: "Th1rd-password", Forced: true})
+	if !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Errorf("expected the current password required again, got %v", err)
+	}
+
+	want := []string{application.AuditPasswordResetForced, application.AuditPasswordChanged}
+	if got := auditActions(audit); !equalStrings(got, want) {
+		t.Errorf("audited %v, want %v", got, want)
+	}
+}
+
+func TestForcePasswordReset_Bulk(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+	alice := repo.AddUser("alice@example.com", "secret123")
+	bob := repo.AddUser("bob@example.com", "secret123")
+	ctx := context.Background()
+
+	result, err := svc.ForcePasswordReset(ctx, []uint{alice.ID, 999, bob.ID, alice.ID}, "credential stuffing", 0)
+	if err != nil {
+		t.Fatalf("force: %v", err)
+	}
+	if len(result.Reset) != 2 || result.Reset[0] != alice.ID || result.Reset[1] != bob.ID {
+		t.Errorf("expected alice and bob reset once each, got %v", result.Reset)
+	}
+	if len(result.NotFound) != 1 || result.NotFound[0] != 999 {
+		t.Errorf("expected 999 not found, got %v", result.NotFound)
+	}
+	for _, id := range []uint{alice.ID, bob.ID} {
+		if stored, _ := repo.User(id); !stored.MustResetPassword {
+			t.Errorf("expected user %d flagged", id)
+		}
+	}
+
+	tooMany := make([]uint, application.MaxForcedResetBatch+1)
+	var verr *application.ValidationError
+	if _, err := svc.ForcePasswordReset(ctx, tooMany, "credential stuffing", 0); !errors.As(err, &verr) {
+		t.Errorf("expected a batch over the cap refused, got %v", err)
+	}
+}
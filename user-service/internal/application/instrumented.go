@@ -0,0 +1,450 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-service/internal/domain"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Outcome labels recorded for every service operation
+const (
+	OutcomeSuccess            = "success"
+	OutcomeNotFound           = "not_found"
+	OutcomeConflict           = "conflict"
+	OutcomeInvalidCredentials = "invalid_credentials"
+	OutcomeInvalidInput       = "invalid_input"
+	OutcomeForbidden          = "forbidden"
+	OutcomeInternal           = "internal"
+)
+
+// OperationObserver receives the duration and outcome of each operation
+type OperationObserver interface {
+	ObserveOperation(operation, outcome string, duration time.Duration)
+}
+
+var _ UserServiceInterface = (*InstrumentedUserService)(nil)
+
+// InstrumentedUserService decorates a UserServiceInterface with per-operation
+// duration and outcome metrics so the core service stays free of metrics code
+type InstrumentedUserService struct {
+	next     UserServiceInterface
+	observer OperationObserver
+}
+
+func NewInstrumentedUserService(next UserServiceInterface, observer OperationObserver) *InstrumentedUserService {
+	return &InstrumentedUserService{
+		next:     next,
+		observer: observer,
+	}
+}
+
+// ClassifyError maps an error returned by the service to an outcome label
+func ClassifyError(err error) string {
+	var verr *ValidationError
+
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, domain.ErrInviteNotFound),
+		errors.Is(err, domain.ErrAccessTokenNotFound), errors.Is(err, domain.ErrBulkJobNotFound),
+		errors.Is(err, domain.ErrNoticeNotFound):
+		return OutcomeNotFound
+	case errors.Is(err, ErrEmailAlreadyRegistered), errors.Is(err, domain.ErrDuplicateUser),
+		errors.Is(err, ErrDeletionNotPending), errors.Is(err, domain.ErrNoticeNotDismissible):
+		return OutcomeConflict
+	case errors.Is(err, ErrInvalidCredentials), errors.Is(err, ErrInvalidRecoveryCode),
+		errors.Is(err, ErrInvalidAccessToken):
+		return OutcomeInvalidCredentials
+	case errors.Is(err, ErrUserBanned), errors.Is(err, ErrLoginDenied),
+		errors.Is(err, ErrRegistrationClosed), errors.Is(err, ErrInviteRequired),
+		errors.Is(err, domain.ErrInviteRevoked),
+		errors.Is(err, domain.ErrInviteExpired), errors.Is(err, domain.ErrInviteExhausted):
+		return OutcomeForbidden
+	case errors.As(err, &verr):
+		return OutcomeInvalidInput
+	default:
+		return OutcomeInternal
+	}
+}
+
+// tracer starts the spans for service operations; it's a no-op until
+// tracing is set up
+var tracer = otel.Tracer("user-service/internal/application")
+
+// operation is one call through InstrumentedUserService
+type operation struct {
+	observer OperationObserver
+	name     string
+	start    time.Time
+	span     trace.Span
+}
+
+// begin starts timing operation and a span for it, a child of any span in
+// ctx, so the cache and database calls it makes show up beneath it
+func (s *InstrumentedUserService) begin(ctx context.Context, name string) (context.Context, *operation) {
+	ctx, span := tracer.Start(ctx, "UserService."+name)
+	return ctx, &operation{observer: s.observer, name: name, start: time.Now(), span: span}
+}
+
+// end records the operation's outcome. Only internal errors mark the span
+// failed; a wrong password or a missing user is the service working.
+func (op *operation) end(err error) {
+	outcome := ClassifyError(err)
+	op.observer.ObserveOperation(op.name, outcome, time.Since(op.start))
+	op.span.SetAttributes(attribute.String("outcome", outcome))
+	if outcome == OutcomeInternal {
+		op.span.RecordError(err)
+		op.span.SetStatus(codes.Error, err.Error())
+	}
+	op.span.End()
+}
+
+func (s *InstrumentedUserService) Register(ctx context.Context, user *domain.User, password, inviteCode string) (bool, error) {
+	ctx, op := s.begin(ctx, "register")
+	replayed, err := s.next.Register(ctx, user, password, inviteCode)
+	op.end(err)
+	return replayed, err
+}
+
+func (s *InstrumentedUserService) Login(ctx context.Context, email, password string) (*domain.User, error) {
+	ctx, op := s.begin(ctx, "login")
+	user, err := s.next.Login(ctx, email, password)
+	op.end(err)
+	return user, err
+}
+
+func (s *InstrumentedUserService) GetUser(ctx context.Context, id uint) (*domain.User, error) {
+	ctx, op := s.begin(ctx, "get_user")
+	user, err := s.next.GetUser(ctx, id)
+	op.end(err)
+	return user, err
+}
+
+func (s *InstrumentedUserService) UserExists(ctx context.Context, id uint) (bool, error) {
+	ctx, op := s.begin(ctx, "user_exists")
+	exists, err := s.next.UserExists(ctx, id)
+	op.end(err)
+	return exists, err
+}
+
+func (s *InstrumentedUserService) UpdateUser(ctx context.Context, user *domain.User) ([]string, error) {
+	ctx, op := s.begin(ctx, "update_user")
+	changed, err := s.next.UpdateUser(ctx, user)
+	op.end(err)
+	return changed, err
+}
+
+func (s *InstrumentedUserService) DeleteUserWithPassword(ctx context.Context, id uint, password string) error {
+	ctx, op := s.begin(ctx, "delete_user_with_password")
+	err := s.next.DeleteUserWithPassword(ctx, id, password)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) DeleteUser(ctx context.Context, id uint) error {
+	ctx, op := s.begin(ctx, "delete_user")
+	err := s.next.DeleteUser(ctx, id)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) UpdateProfile(ctx context.Context, id uint, fields map[string]interface{}) (*domain.User, []string, error) {
+	ctx, op := s.begin(ctx, "update_profile")
+	user, changed, err := s.next.UpdateProfile(ctx, id, fields)
+	op.end(err)
+	return user, changed, err
+}
+
+func (s *InstrumentedUserService) ListUsers(ctx context.Context, page, pageSize int, opts ListUsersOptions) ([]*domain.User, int64, error) {
+	ctx, op := s.begin(ctx, "list_users")
+	users, total, err := s.next.ListUsers(ctx, page, pageSize, opts)
+	op.end(err)
+	return users, total, err
+}
+
+func (s *InstrumentedUserService) ValidateRegistration(ctx context.Context, user *domain.User, password string) error {
+	ctx, op := s.begin(ctx, "validate_registration")
+	err := s.next.ValidateRegistration(ctx, user, password)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) LookupByEmail(ctx context.Context, email, caller string) (*EmailLookup, error) {
+	ctx, op := s.begin(ctx, "lookup_by_email")
+	result, err := s.next.LookupByEmail(ctx, email, caller)
+	op.end(err)
+	return result, err
+}
+
+func (s *InstrumentedUserService) ListPendingDeletions(ctx context.Context, page, pageSize int) ([]*PendingDeletion, int64, error) {
+	ctx, op := s.begin(ctx, "list_pending_deletions")
+	pending, total, err := s.next.ListPendingDeletions(ctx, page, pageSize)
+	op.end(err)
+	return pending, total, err
+}
+
+func (s *InstrumentedUserService) CancelDeletion(ctx context.Context, id uint, actorID uint, reason string) error {
+	ctx, op := s.begin(ctx, "cancel_deletion")
+	err := s.next.CancelDeletion(ctx, id, actorID, reason)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) ExpediteDeletion(ctx context.Context, id uint, actorID uint, reason string) error {
+	ctx, op := s.begin(ctx, "expedite_deletion")
+	err := s.next.ExpediteDeletion(ctx, id, actorID, reason)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) GetUserUnscoped(ctx context.Context, id uint) (*domain.User, error) {
+	ctx, op := s.begin(ctx, "get_user_unscoped")
+	user, err := s.next.GetUserUnscoped(ctx, id)
+	op.end(err)
+	return user, err
+}
+
+func (s *InstrumentedUserService) RestoreUser(ctx context.Context, id uint, reason string, actorID uint) (*domain.User, error) {
+	ctx, op := s.begin(ctx, "restore_user")
+	user, err := s.next.RestoreUser(ctx, id, reason, actorID)
+	op.end(err)
+	return user, err
+}
+
+func (s *InstrumentedUserService) PurgeUser(ctx context.Context, id uint, confirmEmail, reason string, actorID uint) error {
+	ctx, op := s.begin(ctx, "purge_user")
+	err := s.next.PurgeUser(ctx, id, confirmEmail, reason, actorID)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) UpdateNotificationPreferences(ctx context.Context, id uint, update NotificationPreferencesUpdate) (*domain.NotificationPreferences, error) {
+	ctx, op := s.begin(ctx, "update_notification_preferences")
+	prefs, err := s.next.UpdateNotificationPreferences(ctx, id, update)
+	op.end(err)
+	return prefs, err
+}
+
+func (s *InstrumentedUserService) UpdatePreferences(ctx context.Context, id uint, patch map[string]interface{}) (domain.Preferences, error) {
+	ctx, op := s.begin(ctx, "update_preferences")
+	prefs, err := s.next.UpdatePreferences(ctx, id, patch)
+	op.end(err)
+	return prefs, err
+}
+
+func (s *InstrumentedUserService) CreateInvite(ctx context.Context, invite *domain.Invite) error {
+	ctx, op := s.begin(ctx, "create_invite")
+	err := s.next.CreateInvite(ctx, invite)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) RevokeInvite(ctx context.Context, code, reason string) error {
+	ctx, op := s.begin(ctx, "revoke_invite")
+	err := s.next.RevokeInvite(ctx, code, reason)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) GenerateRecoveryCodes(ctx context.Context, id uint, password string) ([]string, error) {
+	ctx, op := s.begin(ctx, "generate_recovery_codes")
+	codes, err := s.next.GenerateRecoveryCodes(ctx, id, password)
+	op.end(err)
+	return codes, err
+}
+
+func (s *InstrumentedUserService) Recover(ctx context.Context, email, code string) (*domain.User, error) {
+	ctx, op := s.begin(ctx, "recover")
+	user, err := s.next.Recover(ctx, email, code)
+	op.end(err)
+	return user, err
+}
+
+func (s *InstrumentedUserService) RecoveryCodesRemaining(ctx context.Context, id uint) (int, error) {
+	ctx, op := s.begin(ctx, "recovery_codes_remaining")
+	remaining, err := s.next.RecoveryCodesRemaining(ctx, id)
+	op.end(err)
+	return remaining, err
+}
+
+func (s *InstrumentedUserService) ForgotPassword(ctx context.Context, email string) error {
+	ctx, op := s.begin(ctx, "forgot_password")
+	err := s.next.ForgotPassword(ctx, email)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) ResetForgottenPassword(ctx context.Context, token, password string) error {
+	ctx, op := s.begin(ctx, "reset_forgotten_password")
+	err := s.next.ResetForgottenPassword(ctx, token, password)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) RequestEmailChange(ctx context.Context, id uint, change EmailChange) error {
+	ctx, op := s.begin(ctx, "request_email_change")
+	err := s.next.RequestEmailChange(ctx, id, change)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) ConfirmEmailChange(ctx context.Context, token string) (*domain.User, error) {
+	ctx, op := s.begin(ctx, "confirm_email_change")
+	user, err := s.next.ConfirmEmailChange(ctx, token)
+	op.end(err)
+	return user, err
+}
+
+func (s *InstrumentedUserService) CreateAccessToken(ctx context.Context, token *domain.AccessToken) (string, error) {
+	ctx, op := s.begin(ctx, "create_access_token")
+	secret, err := s.next.CreateAccessToken(ctx, token)
+	op.end(err)
+	return secret, err
+}
+
+func (s *InstrumentedUserService) ListAccessTokens(ctx context.Context, userID uint) ([]*domain.AccessToken, error) {
+	ctx, op := s.begin(ctx, "list_access_tokens")
+	tokens, err := s.next.ListAccessTokens(ctx, userID)
+	op.end(err)
+	return tokens, err
+}
+
+func (s *InstrumentedUserService) RevokeAccessToken(ctx context.Context, userID, id uint) error {
+	ctx, op := s.begin(ctx, "revoke_access_token")
+	err := s.next.RevokeAccessToken(ctx, userID, id)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) ChangePassword(ctx context.Context, id uint, change PasswordChange) error {
+	ctx, op := s.begin(ctx, "change_password")
+	err := s.next.ChangePassword(ctx, id, change)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) ForcePasswordReset(ctx context.Context, ids []uint, reason string, actorID uint) (*ForcedResets, error) {
+	ctx, op := s.begin(ctx, "force_password_reset")
+	result, err := s.next.ForcePasswordReset(ctx, ids, reason, actorID)
+	op.end(err)
+	return result, err
+}
+
+func (s *InstrumentedUserService) StartBulkJob(ctx context.Context, job *domain.BulkJob) error {
+	ctx, op := s.begin(ctx, "start_bulk_job")
+	err := s.next.StartBulkJob(ctx, job)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) GetBulkJob(ctx context.Context, id uint) (*domain.BulkJob, error) {
+	ctx, op := s.begin(ctx, "get_bulk_job")
+	job, err := s.next.GetBulkJob(ctx, id)
+	op.end(err)
+	return job, err
+}
+
+func (s *InstrumentedUserService) BulkJobReportURL(ctx context.Context, job *domain.BulkJob) (string, error) {
+	ctx, op := s.begin(ctx, "bulk_job_report_url")
+	link, err := s.next.BulkJobReportURL(ctx, job)
+	op.end(err)
+	return link, err
+}
+
+func (s *InstrumentedUserService) TokenVersion(ctx context.Context, userID uint) (uint, error) {
+	ctx, op := s.begin(ctx, "token_version")
+	version, err := s.next.TokenVersion(ctx, userID)
+	op.end(err)
+	return version, err
+}
+
+func (s *InstrumentedUserService) LogoutAll(ctx context.Context, id uint) error {
+	ctx, op := s.begin(ctx, "logout_all")
+	err := s.next.LogoutAll(ctx, id)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) StartSession(ctx context.Context, userID uint) (*domain.Session, error) {
+	ctx, op := s.begin(ctx, "start_session")
+	session, err := s.next.StartSession(ctx, userID)
+	op.end(err)
+	return session, err
+}
+
+func (s *InstrumentedUserService) ListSessions(ctx context.Context, userID uint) ([]*domain.Session, error) {
+	ctx, op := s.begin(ctx, "list_sessions")
+	sessions, err := s.next.ListSessions(ctx, userID)
+	op.end(err)
+	return sessions, err
+}
+
+func (s *InstrumentedUserService) RevokeSession(ctx context.Context, userID uint, id string) error {
+	ctx, op := s.begin(ctx, "revoke_session")
+	err := s.next.RevokeSession(ctx, userID, id)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) UseSession(ctx context.Context, userID uint, id string) error {
+	ctx, op := s.begin(ctx, "use_session")
+	err := s.next.UseSession(ctx, userID, id)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) SessionActive(ctx context.Context, id string) (bool, error) {
+	ctx, op := s.begin(ctx, "session_active")
+	active, err := s.next.SessionActive(ctx, id)
+	op.end(err)
+	return active, err
+}
+
+func (s *InstrumentedUserService) Notices(ctx context.Context, user *domain.User) ([]domain.Notice, error) {
+	ctx, op := s.begin(ctx, "notices")
+	notices, err := s.next.Notices(ctx, user)
+	op.end(err)
+	return notices, err
+}
+
+func (s *InstrumentedUserService) DismissNotice(ctx context.Context, userID uint, code string) error {
+	ctx, op := s.begin(ctx, "dismiss_notice")
+	err := s.next.DismissNotice(ctx, userID, code)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) AddNotice(ctx context.Context, notice *domain.UserNotice) error {
+	ctx, op := s.begin(ctx, "add_notice")
+	err := s.next.AddNotice(ctx, notice)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) RemoveNotice(ctx context.Context, userID uint, code, removedBy string) error {
+	ctx, op := s.begin(ctx, "remove_notice")
+	err := s.next.RemoveNotice(ctx, userID, code, removedBy)
+	op.end(err)
+	return err
+}
+
+func (s *InstrumentedUserService) ExportSnapshot(ctx context.Context, id uint, reason string) (*SignedSnapshot, error) {
+	ctx, op := s.begin(ctx, "export_snapshot")
+	bundle, err := s.next.ExportSnapshot(ctx, id, reason)
+	op.end(err)
+	return bundle, err
+}
+
+func (s *InstrumentedUserService) ImportSnapshot(ctx context.Context, bundle *SignedSnapshot, overwrite bool, reason string) (*SnapshotImport, error) {
+	ctx, op := s.begin(ctx, "import_snapshot")
+	result, err := s.next.ImportSnapshot(ctx, bundle, overwrite, reason)
+	op.end(err)
+	return result, err
+}
@@ -0,0 +1,121 @@
+// internal/application/instrumented_test.go
+package application_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type recordedOp struct {
+	operation string
+	outcome   string
+}
+
+type fakeObserver struct {
+	ops []recordedOp
+}
+
+func (o *fakeObserver) ObserveOperation(operation, outcome string, duration time.Duration) {
+	o.ops = append(o.ops, recordedOp{operation, outcome})
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, application.OutcomeSuccess},
+		{domain.ErrUserNotFound, application.OutcomeNotFound},
+		{fmt.Errorf("wrapped: %w", domain.ErrUserNotFound), application.OutcomeNotFound},
+		{domain.ErrDuplicateUser, application.OutcomeConflict},
+		{&application.ValidationError{Fields: map[string]string{"email": "taken"}, Err: application.ErrEmailAlreadyRegistered}, application.OutcomeConflict},
+		{&application.ValidationError{Fields: map[string]string{"password": "weak"}}, application.OutcomeInvalidInput},
+		{application.ErrInvalidCredentials, application.OutcomeInvalidCredentials},
+		{application.ErrUserBanned, application.OutcomeForbidden},
+		{fmt.Errorf("connection reset"), application.OutcomeInternal},
+	}
+
+	for _, tt := range tests {
+		if got := application.ClassifyError(tt.err); got != tt.want {
+			t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestInstrumentedUserService(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	repo.AddUser("alice@example.com", "secret123")
+	observer := &fakeObserver{}
+	svc := application.NewInstrumentedUserService(application.NewUserService(repo, testsupport.NewTxManager(repo), nil), observer)
+	ctx := context.Background()
+
+	svc.Login(ctx, "alice@example.com", "secret123")
+	svc.Login(ctx, "alice@example.com", "wrong")
+	svc.GetUser(ctx, 404)
+
+	want := []recordedOp{
+		{"login", application.OutcomeSuccess},
+		{"login", application.OutcomeInvalidCredentials},
+		{"get_user", application.OutcomeNotFound},
+	}
+	if len(observer.ops) != len(want) {
+		t.Fatalf("expected %d observations, got %+v", len(want), observer.ops)
+	}
+	for i, op := range want {
+		if observer.ops[i] != op {
+			t.Errorf("observation %d = %+v, want %+v", i, observer.ops[i], op)
+		}
+	}
+}
+
+var (
+	spanExporter    *tracetest.InMemoryExporter
+	installExporter sync.Once
+)
+
+func TestInstrumentedUserService_Spans(t *testing.T) {
+	// otel's global tracers only ever delegate to the first provider set
+	installExporter.Do(func() {
+		spanExporter = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter)))
+	})
+	spanExporter.Reset()
+
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	svc := application.NewInstrumentedUserService(application.NewUserService(repo, testsupport.NewTxManager(repo), nil), &fakeObserver{})
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "GET /users/{id}")
+	svc.GetUser(ctx, alice.ID)
+	svc.GetUser(ctx, 404)
+	parent.End()
+
+	spans := spanExporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected two operation spans and the parent, got %d", len(spans))
+	}
+	for _, span := range spans[:2] {
+		if span.Name != "UserService.get_user" {
+			t.Errorf("expected the operation's span, got %q", span.Name)
+		}
+		if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("expected %q beneath the request's span", span.Name)
+		}
+		// Not found is an answer, not a failure
+		if span.Status.Code == codes.Error {
+			t.Errorf("expected %q not marked failed", span.Name)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvitesNotConfigured is returned by the invite methods when the
+// service was built without an InviteRepository
+var ErrInvitesNotConfigured = errors.New("invites not configured")
+
+// RegistrationMode decides who may Register
+type RegistrationMode string
+
+const (
+	RegistrationOpen RegistrationMode = "open"
+	// RegistrationInvite requires a valid invite code, which each signup
+	// uses up one redemption of
+	RegistrationInvite RegistrationMode = "invite"
+	RegistrationClosed RegistrationMode = "closed"
+)
+
+// Valid reports whether m is a known mode
+func (m RegistrationMode) Valid() bool {
+	switch m {
+	case RegistrationOpen, RegistrationInvite, RegistrationClosed:
+		return true
+	}
+	return false
+}
+
+// DefaultInviteMaxUses is how many signups a minted code allows when the
+// caller doesn't say
+const DefaultInviteMaxUses = 1
+
+// codeAlphabet leaves out characters that are easily misread (0/O, 1/I/L).
+// Invite and recovery codes are both typed in by hand.
+const codeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// InviteRepository persists invite codes
+type InviteRepository interface {
+	Create(ctx context.Context, invite *domain.Invite) error
+	// Revoke marks the code revoked at at. Revoking a revoked code is a
+	// no-op; an unknown one is domain.ErrInviteNotFound.
+	Revoke(ctx context.Context, code string, at time.Time) error
+	// Consume uses up one redemption of the code if it is usable at now.
+	// The check and the increment are one atomic step, so concurrent
+	// signups can't exceed MaxUses. Otherwise it returns why, as one of
+	// the domain invite errors.
+	Consume(ctx context.Context, code string, now time.Time) error
+	WithTx(tx *gorm.DB) InviteRepository
+}
+
+// WithRegistrationMode sets who may Register; the default is open
+func WithRegistrationMode(mode RegistrationMode) Option {
+	return func(s *UserService) {
+		s.registrationMode = mode
+	}
+}
+
+// WithInviteRepository stores invite codes, which invite-only registration
+// needs
+func WithInviteRepository(repo InviteRepository) Option {
+	return func(s *UserService) {
+		s.invites = repo
+	}
+}
+
+// normalizeInviteCode makes codes case- and whitespace-insensitive, since
+// people type them in by hand
+func normalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// newInviteCode returns a random code like "K7QM-X2PD-9RTW"
+func newInviteCode() (string, error) {
+	return newCode(3, 4)
+}
+
+// newCode returns groups random groups of size characters from
+// codeAlphabet, joined by dashes
+func newCode(groups, size int) (string, error) {
+	var code strings.Builder
+	for i := 0; i < groups*size; i++ {
+		if i > 0 && i%size == 0 {
+			code.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(codeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code.WriteByte(codeAlphabet[n.Int64()])
+	}
+	return code.String(), nil
+}
+
+// CreateInvite mints a code allowing invite.MaxUses signups, or
+// DefaultInviteMaxUses when unset, until invite.ExpiresAt. The code and
+// timestamps are filled in on invite.
+func (s *UserService) CreateInvite(ctx context.Context, invite *domain.Invite) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.invites == nil {
+		return ErrInvitesNotConfigured
+	}
+
+	if invite.MaxUses == 0 {
+		invite.MaxUses = DefaultInviteMaxUses
+	}
+	verr := &ValidationError{Fields: make(map[string]string)}
+	if invite.MaxUses < 0 {
+		verr.Fields["max_uses"] = "max_uses must be positive"
+	}
+	now := s.now().UTC()
+	if invite.ExpiresAt != nil && !invite.ExpiresAt.After(now) {
+		verr.Fields["expires_at"] = "expires_at must be in the future"
+	}
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+
+	code, err := newInviteCode()
+	if err != nil {
+		return fmt.Errorf("failed to generate invite code: %w", err)
+	}
+	invite.Code, invite.Uses, invite.RevokedAt, invite.CreatedAt = code, 0, nil, now
+
+	metadata := map[string]interface{}{
+		"code":       invite.Code,
+		"max_uses":   invite.MaxUses,
+		"created_by": invite.CreatedBy,
+	}
+	if invite.ExpiresAt != nil {
+		metadata["expires_at"] = invite.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	err = s.WithTransaction(writeCtx, func(ctx context.Context, tx *TxService) error {
+		if err := tx.CreateInvite(ctx, invite); err != nil {
+			return err
+		}
+		return tx.Audit(ctx, &AuditEntry{
+			Action:    AuditInviteCreated,
+			Metadata:  metadata,
+			CreatedAt: now,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create invite: %w", err)
+	}
+	return nil
+}
+
+// RevokeInvite stops code from being redeemed. Accounts it already let in
+// are unaffected.
+func (s *UserService) RevokeInvite(ctx context.Context, code, reason string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.invites == nil {
+		return ErrInvitesNotConfigured
+	}
+
+	code = normalizeInviteCode(code)
+	now := s.now().UTC()
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	err := s.WithTransaction(writeCtx, func(ctx context.Context, tx *TxService) error {
+		if err := tx.RevokeInvite(ctx, code, now); err != nil {
+			return err
+		}
+		return tx.Audit(ctx, &AuditEntry{
+			Action:    AuditInviteRevoked,
+			Reason:    reason,
+			Metadata:  map[string]interface{}{"code": code},
+			CreatedAt: now,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke invite: %w", err)
+	}
+	return nil
+}
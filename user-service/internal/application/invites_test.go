@@ -0,0 +1,173 @@
+// internal/application/invites_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+type inviteFixture struct {
+	repo    *testsupport.UserRepository
+	invites *testsupport.InviteRepository
+	tx      *testsupport.TxManager
+	clock   *testsupport.Clock
+	audit   *fakeAuditLogger
+	svc     *application.UserService
+}
+
+func newInviteFixture(mode application.RegistrationMode) *inviteFixture {
+	f := &inviteFixture{
+		repo:    testsupport.NewUserRepository(),
+		invites: testsupport.NewInviteRepository(),
+		clock:   testsupport.NewClock(),
+		audit:   &fakeAuditLogger{},
+	}
+	f.tx = testsupport.NewTxManager(f.repo, f.invites)
+	f.svc = application.NewUserService(f.repo, f.tx, nil,
+		application.WithRegistrationMode(mode),
+		application.WithInviteRepository(f.invites),
+		application.WithClock(f.clock.Now),
+		application.WithAuditLogger(f.audit),
+	)
+	return f
+}
+
+// mint creates an invite and returns its code
+func (f *inviteFixture) mint(t *testing.T, invite domain.Invite) string {
+	t.Helper()
+	if err := f.svc.CreateInvite(context.Background(), &invite); err != nil {
+		t.Fatalf("create invite: %v", err)
+	}
+	return invite.Code
+}
+
+func (f *inviteFixture) register(name, code string) error {
+	_, err := f.svc.Register(context.Background(), &domain.User{
+		Username: name, Email: name + "@example.com",
+	}, "secret123", code)
+	return err
+}
+
+func TestRegister_InviteOnly(t *testing.T) {
+	f := newInviteFixture(application.RegistrationInvite)
+	code := f.mint(t, domain.Invite{MaxUses: 2, CreatedBy: "ops"})
+
+	if err := f.register("alice", ""); !errors.Is(err, application.ErrInviteRequired) {
+		t.Errorf("expected ErrInviteRequired, got %v", err)
+	}
+	if err := f.register("alice", "NOPE-NOPE-NOPE"); !errors.Is(err, domain.ErrInviteNotFound) {
+		t.Errorf("expected ErrInviteNotFound, got %v", err)
+	}
+
+	// Codes are typed by hand, so case and padding don't matter
+	if err := f.register("alice", " "+code+" "); err != nil {
+		t.Fatalf("register with invite: %v", err)
+	}
+	if err := f.register("bob", code); err != nil {
+		t.Fatalf("register with invite: %v", err)
+	}
+	if err := f.register("carol", code); !errors.Is(err, domain.ErrInviteExhausted) {
+		t.Errorf("expected ErrInviteExhausted, got %v", err)
+	}
+	if invite, _ := f.invites.Invite(code); invite.Uses != 2 {
+		t.Errorf("expected 2 uses, got %d", invite.Uses)
+	}
+	if _, err := f.repo.GetByEmail(context.Background(), "carol@example.com"); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected no account without a usable invite, got %v", err)
+	}
+}
+
+func TestRegister_RefusedInvitesDontUseUpACode(t *testing.T) {
+	f := newInviteFixture(application.RegistrationInvite)
+	f.repo.AddUser("taken@example.com", "secret123")
+	code := f.mint(t, domain.Invite{MaxUses: 1})
+
+	// A signup that fails validation never reaches the invite
+	_, err := f.svc.Register(context.Background(), &domain.User{
+		Username: "taken", Email: "taken@example.com",
+	}, "secret123", code)
+	var verr *application.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	if invite, _ := f.invites.Invite(code); invite.Uses != 0 {
+		t.Errorf("expected the failed signup to leave the code unused, got %d uses", invite.Uses)
+	}
+	if err := f.register("alice", code); err != nil {
+		t.Errorf("expected the code to still work, got %v", err)
+	}
+}
+
+func TestRegister_ExpiredAndRevokedInvites(t *testing.T) {
+	f := newInviteFixture(application.RegistrationInvite)
+	expiresAt := f.clock.Now().Add(time.Hour)
+	expiring := f.mint(t, domain.Invite{MaxUses: 10, ExpiresAt: &expiresAt})
+	revoked := f.mint(t, domain.Invite{MaxUses: 10})
+
+	if err := f.svc.RevokeInvite(context.Background(), revoked, "leaked on a forum"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if err := f.register("alice", revoked); !errors.Is(err, domain.ErrInviteRevoked) {
+		t.Errorf("expected ErrInviteRevoked, got %v", err)
+	}
+
+	f.clock.Advance(time.Hour)
+	if err := f.register("bob", expiring); !errors.Is(err, domain.ErrInviteExpired) {
+		t.Errorf("expected ErrInviteExpired, got %v", err)
+	}
+
+	if err := f.svc.RevokeInvite(context.Background(), "NOPE-NOPE-NOPE", "typo"); !errors.Is(err, domain.ErrInviteNotFound) {
+		t.Errorf("expected ErrInviteNotFound, got %v", err)
+	}
+	want := []string{application.AuditInviteCreated, application.AuditInviteCreated, application.AuditInviteRevoked}
+	if got := auditActions(f.audit); !equalStrings(got, want) {
+		t.Errorf("audited %v, want %v", got, want)
+	}
+}
+
+func TestRegister_Modes(t *testing.T) {
+	closed := newInviteFixture(application.RegistrationClosed)
+	if err := closed.register("alice", ""); !errors.Is(err, application.ErrRegistrationClosed) {
+		t.Errorf("expected ErrRegistrationClosed, got %v", err)
+	}
+
+	// Open registration ignores codes, leaving them for invite mode
+	open := newInviteFixture(application.RegistrationOpen)
+	code := open.mint(t, domain.Invite{})
+	if err := open.register("alice", code); err != nil {
+		t.Fatalf("open register: %v", err)
+	}
+	if invite, _ := open.invites.Invite(code); invite.Uses != 0 {
+		t.Errorf("expected open registration not to use the code, got %d uses", invite.Uses)
+	}
+}
+
+func TestCreateInvite_Validates(t *testing.T) {
+	f := newInviteFixture(application.RegistrationInvite)
+	past := f.clock.Now().Add(-time.Minute)
+
+	err := f.svc.CreateInvite(context.Background(), &domain.Invite{MaxUses: -1, ExpiresAt: &past})
+	var verr *application.ValidationError
+	if !errors.As(err, &verr) || verr.Fields["max_uses"] == "" || verr.Fields["expires_at"] == "" {
+		t.Fatalf("expected max_uses and expires_at errors, got %v", err)
+	}
+
+	invite := &domain.Invite{}
+	if err := f.svc.CreateInvite(context.Background(), invite); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if invite.MaxUses != application.DefaultInviteMaxUses || len(invite.Code) != len("XXXX-XXXX-XXXX") {
+		t.Errorf("unexpected invite %+v", invite)
+	}
+
+	bare := application.NewUserService(f.repo, f.tx, nil, application.WithRegistrationMode(application.RegistrationInvite))
+	if err := bare.CreateInvite(context.Background(), &domain.Invite{}); !errors.Is(err, application.ErrInvitesNotConfigured) {
+		t.Errorf("expected ErrInvitesNotConfigured, got %v", err)
+	}
+}
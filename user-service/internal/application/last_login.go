@@ -0,0 +1,91 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// LastLoginJobName is the last-login flush's name in the job scheduler
+const LastLoginJobName = "last_login_flush"
+
+// LastLoginStore persists a batch of last-login timestamps keyed by user ID
+type LastLoginStore interface {
+	UpdateLastLogins(ctx context.Context, logins map[uint]time.Time) error
+}
+
+// LastLoginRecorder takes last-login writes off the login path. Logins are
+// coalesced per user in memory and written in batches by Flush, which the
+// job scheduler runs periodically.
+type LastLoginRecorder struct {
+	store      LastLoginStore
+	bufferSize int
+
+	mu      sync.Mutex
+	pending map[uint]time.Time
+	closed  bool
+}
+
+// NewLastLoginRecorder creates a recorder holding at most bufferSize users'
+// updates between flushes
+func NewLastLoginRecorder(store LastLoginStore, bufferSize int) *LastLoginRecorder {
+	if bufferSize <= 0 {
+		bufferSize = 1024
+	}
+
+	return &LastLoginRecorder{
+		store:      store,
+		bufferSize: bufferSize,
+		pending:    make(map[uint]time.Time),
+	}
+}
+
+// Record queues a last-login update. It never blocks: when the buffer is
+// full the update is dropped and logged, since last_login is best-effort.
+func (r *LastLoginRecorder) Record(userID uint, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		log.Printf("Last login recorder closed, dropping update for user %d", userID)
+		return
+	}
+	// Coalesce: only the most recent login per user matters
+	prev, ok := r.pending[userID]
+	if !ok && len(r.pending) >= r.bufferSize {
+		log.Printf("Last login buffer full, dropping update for user %d", userID)
+		return
+	}
+	if !ok || at.After(prev) {
+		r.pending[userID] = at
+	}
+}
+
+// Flush writes every pending update in one batch. A batch that fails to
+// write is dropped rather than retried.
+func (r *LastLoginRecorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[uint]time.Time)
+	r.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	if err := r.store.UpdateLastLogins(ctx, pending); err != nil {
+		return fmt.Errorf("failed to flush %d last login updates: %w", len(pending), err)
+	}
+	return nil
+}
+
+// Close stops accepting updates and flushes everything still pending.
+// Close the job scheduler first so a scheduled flush isn't still running.
+func (r *LastLoginRecorder) Close(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	return r.Flush(ctx)
+}
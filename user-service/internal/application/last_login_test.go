@@ -0,0 +1,153 @@
+// internal/application/last_login_test.go
+package application_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/testsupport"
+)
+
+func TestLastLoginRecorder_PersistsOnFlush(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+
+	recorder := application.NewLastLoginRecorder(repo, 16)
+	defer recorder.Close(context.Background())
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithLastLoginRecorder(recorder))
+
+	if _, err := svc.Login(context.Background(), "alice@example.com", "secret123"); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if _, ok := repo.LastLogin(user.ID); ok {
+		t.Fatal("expected the write to wait for a flush")
+	}
+
+	if err := recorder.Flush(context.Background()); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if _, ok := repo.LastLogin(user.ID); !ok {
+		t.Fatal("last_login was never persisted")
+	}
+	if repo.Calls("UpdateLastLogins") != 1 {
+		t.Errorf("expected one batch write, got %d", repo.Calls("UpdateLastLogins"))
+	}
+
+	// Nothing pending, so nothing to write
+	if err := recorder.Flush(context.Background()); err != nil || repo.Calls("UpdateLastLogins") != 1 {
+		t.Errorf("expected an empty flush to skip the write, got %v", err)
+	}
+}
+
+func TestLogin_RecordsLastLoginInUTC(t *testing.T) {
+	if !testsupport.InLocalZone(t) {
+		return
+	}
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	recorder := application.NewLastLoginRecorder(repo, 16)
+	// The default clock is time.Now, which is in the local zone
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithLastLoginRecorder(recorder))
+
+	loggedIn, err := svc.Login(context.Background(), "alice@example.com", "secret123")
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if loggedIn.LastLogin == nil || loggedIn.LastLogin.Location() != time.UTC {
+		t.Errorf("expected last login in UTC, got %v", loggedIn.LastLogin)
+	}
+
+	if err := recorder.Close(context.Background()); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if stored, ok := repo.LastLogin(user.ID); !ok || stored.Location() != time.UTC {
+		t.Errorf("expected the stored last login in UTC, got %v", stored)
+	}
+}
+
+func TestLastLoginRecorder_CoalescesAndDrainsOnClose(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+
+	recorder := application.NewLastLoginRecorder(repo, 16)
+
+	first := time.Now()
+	latest := first.Add(time.Minute)
+	recorder.Record(user.ID, first)
+	recorder.Record(user.ID, latest)
+	recorder.Record(user.ID, first)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := recorder.Close(ctx); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	got, ok := repo.LastLogin(user.ID)
+	if !ok {
+		t.Fatal("expected last login to be flushed on close")
+	}
+	if !got.Equal(latest) {
+		t.Errorf("expected latest timestamp %v, got %v", latest, got)
+	}
+
+	// Records after close are dropped without blocking
+	recorder.Record(user.ID, time.Now())
+}
+
+func TestLastLoginRecorder_DropsOnOverflow(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	recorder := application.NewLastLoginRecorder(repo, 1)
+	defer recorder.Close(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			recorder.Record(uint(i), time.Now())
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Record blocked on a full buffer")
+	}
+}
+
+// BenchmarkLogin compares the synchronous last_login write against the
+// recorder. The mock repository simulates a 1ms write round-trip.
+func BenchmarkLogin(b *testing.B) {
+	b.Run("sync", func(b *testing.B) {
+		repo := testsupport.NewUserRepository()
+		repo.AddUser("alice@example.com", "secret123")
+		repo.WriteDelay = time.Millisecond
+		svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := svc.Login(context.Background(), "alice@example.com", "secret123"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("async", func(b *testing.B) {
+		repo := testsupport.NewUserRepository()
+		repo.AddUser("alice@example.com", "secret123")
+		repo.WriteDelay = time.Millisecond
+		recorder := application.NewLastLoginRecorder(repo, 4096)
+		defer recorder.Close(context.Background())
+		svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithLastLoginRecorder(recorder))
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := svc.Login(context.Background(), "alice@example.com", "secret123"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
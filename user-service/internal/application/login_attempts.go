@@ -0,0 +1,53 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"user-service/internal/logging"
+)
+
+// LoginAttempt is one credential check, kept for activity statistics.
+// UserID is 0 when the email didn't match an account.
+type LoginAttempt struct {
+	UserID    uint
+	Success   bool
+	CreatedAt time.Time
+}
+
+// LoginAttemptStore persists login attempts
+type LoginAttemptStore interface {
+	RecordLoginAttempt(ctx context.Context, attempt *LoginAttempt) error
+}
+
+// WithLoginAttemptStore records every login attempt for statistics
+func WithLoginAttemptStore(store LoginAttemptStore) Option {
+	return func(s *UserService) {
+		s.loginAttempts = store
+	}
+}
+
+// recordLoginAttempt writes the attempt in the background so it never adds
+// latency to login; Wait covers it during shutdown
+func (s *UserService) recordLoginAttempt(ctx context.Context, userID uint, success bool) {
+	if s.loginAttempts == nil {
+		return
+	}
+
+	attempt := &LoginAttempt{
+		UserID:    userID,
+		Success:   success,
+		CreatedAt: s.now().UTC(),
+	}
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+
+		writeCtx, cancel := bestEffortContext(ctx, pointReadTimeout)
+		defer cancel()
+		if err := s.loginAttempts.RecordLoginAttempt(writeCtx, attempt); err != nil {
+			logging.Printf(ctx, "Failed to record login attempt: %v", err)
+		}
+	}()
+}
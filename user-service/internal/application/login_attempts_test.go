@@ -0,0 +1,54 @@
+// internal/application/login_attempts_test.go
+package application_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/testsupport"
+)
+
+type fakeLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts []application.LoginAttempt
+}
+
+func (s *fakeLoginAttemptStore) RecordLoginAttempt(ctx context.Context, attempt *application.LoginAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts = append(s.attempts, *attempt)
+	return nil
+}
+
+func TestLogin_RecordsAttempts(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	store := &fakeLoginAttemptStore{}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithLoginAttemptStore(store))
+
+	_, _ = svc.Login(context.Background(), "alice@example.com", "secret123")
+	_, _ = svc.Login(context.Background(), "alice@example.com", "wrong-password")
+	_, _ = svc.Login(context.Background(), "nobody@example.com", "secret123")
+	svc.Wait()
+
+	want := map[application.LoginAttempt]bool{
+		{UserID: user.ID, Success: true}:  true,
+		{UserID: user.ID, Success: false}: true,
+		{UserID: 0, Success: false}:       true,
+	}
+	if len(store.attempts) != len(want) {
+		t.Fatalf("expected %d attempts, got %+v", len(want), store.attempts)
+	}
+	for _, a := range store.attempts {
+		if a.CreatedAt.IsZero() {
+			t.Error("attempt recorded without a timestamp")
+		}
+		key := application.LoginAttempt{UserID: a.UserID, Success: a.Success}
+		if !want[key] {
+			t.Errorf("unexpected attempt %+v", a)
+		}
+		delete(want, key)
+	}
+}
@@ -0,0 +1,61 @@
+// internal/application/login_bench_test.go
+package application_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/testsupport"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BenchmarkBcryptCompare measures password verification at the costs we
+// have stored hashes for; the rehash hook moves users to DefaultCost
+func BenchmarkBcryptCompare(b *testing.B) {
+	for _, cost := range []int{bcrypt.MinCost, bcrypt.DefaultCost, 12} {
+		b.Run(fmt.Sprintf("cost=%d", cost), func(b *testing.B) {
+			hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), cost)
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = bcrypt.CompareHashAndPassword(hash, []byte("secret123"))
+			}
+		})
+	}
+}
+
+// BenchmarkLoginOutcomes measures the service login path against the in-memory
+// repository, so the numbers are bcrypt plus service overhead
+func BenchmarkLoginOutcomes(b *testing.B) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "placeholder")
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.DefaultCost)
+	_ = repo.UpdateFields(context.Background(), user.ID, map[string]interface{}{"password": string(hash)})
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+	defer svc.Wait()
+	ctx := context.Background()
+
+	cases := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{"success", "alice@example.com", "secret123"},
+		{"wrong_password", "alice@example.com", "wrong"},
+		{"unknown_email", "nobody@example.com", "secret123"},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = svc.Login(ctx, tc.email, tc.password)
+			}
+		})
+	}
+}
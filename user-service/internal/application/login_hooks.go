@@ -0,0 +1,190 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/logging"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultLoginHookTimeout bounds each hook call so a slow check can't
+// stall login
+const DefaultLoginHookTimeout = 200 * time.Millisecond
+
+// LoginRequest is what hooks see of a login. Password is the plaintext the
+// client sent; hooks must never log or persist it.
+type LoginRequest struct {
+	Email    string
+	Password string
+	// User is nil when the email didn't match an account
+	User *domain.User
+	// Credentials are loaded for the password check, so they are nil
+	// before it
+	Credentials *domain.Credentials
+}
+
+// LoginHook plugs extra checks into Login without growing it. Hooks run in
+// the order they were configured. Returning an error made with DenyLogin
+// rejects the login; any other error or a timeout is logged and ignored.
+type LoginHook interface {
+	// BeforeCredentialCheck runs once the account is loaded, before bcrypt
+	BeforeCredentialCheck(ctx context.Context, req *LoginRequest) error
+	// AfterSuccess runs after the password matched; it can still deny
+	AfterSuccess(ctx context.Context, req *LoginRequest) error
+	// AfterFailure runs for every rejected login with the reason
+	AfterFailure(ctx context.Context, req *LoginRequest, cause error)
+}
+
+// NopLoginHook can be embedded by hooks that only need some of the methods
+type NopLoginHook struct{}
+
+func (NopLoginHook) BeforeCredentialCheck(ctx context.Context, req *LoginRequest) error { return nil }
+func (NopLoginHook) AfterSuccess(ctx context.Context, req *LoginRequest) error          { return nil }
+func (NopLoginHook) AfterFailure(ctx context.Context, req *LoginRequest, cause error)   {}
+
+// LoginDeniedError is returned by Login when a hook vetoed it
+type LoginDeniedError struct {
+	Reason string
+}
+
+func (e *LoginDeniedError) Error() string {
+	return "login denied: " + e.Reason
+}
+
+func (e *LoginDeniedError) Unwrap() error {
+	return ErrLoginDenied
+}
+
+// DenyLogin is what a hook returns to reject a login
+func DenyLogin(reason string) error {
+	return &LoginDeniedError{Reason: reason}
+}
+
+// WithLoginHooks appends hooks to the login chain, ahead of the built-in
+// last-login hook
+func WithLoginHooks(hooks ...LoginHook) Option {
+	return func(s *UserService) {
+		s.loginHooks = append(s.loginHooks, hooks...)
+	}
+}
+
+// WithLoginHookTimeout overrides DefaultLoginHookTimeout
+func WithLoginHookTimeout(d time.Duration) Option {
+	return func(s *UserService) {
+		s.loginHookTimeout = d
+	}
+}
+
+// runLoginHooks calls phase on every hook in order and stops at the first
+// denial
+func (s *UserService) runLoginHooks(ctx context.Context, phase string, call func(ctx context.Context, hook LoginHook) error) error {
+	for _, hook := range s.loginHooks {
+		err := s.callLoginHook(ctx, hook, call)
+
+		var denied *LoginDeniedError
+		switch {
+		case err == nil:
+		case errors.As(err, &denied):
+			return err
+		case errors.Is(err, context.Canceled) && ctx.Err() != nil:
+			// The caller went away; no point running the rest
+			return ctx.Err()
+		default:
+			logging.Printf(ctx, "Login hook %T failed during %s, ignoring: %v", hook, phase, err)
+		}
+	}
+	return nil
+}
+
+// callLoginHook runs one hook under the per-hook timeout. The hook runs in
+// its own goroutine so one that ignores ctx still can't hold login up.
+func (s *UserService) callLoginHook(ctx context.Context, hook LoginHook, call func(ctx context.Context, hook LoginHook) error) error {
+	hookCtx, cancel := context.WithTimeout(ctx, s.loginHookTimeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		done <- call(hookCtx, hook)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-hookCtx.Done():
+		return hookCtx.Err()
+	}
+}
+
+// afterLoginFailure notifies hooks; failures never change the outcome
+func (s *UserService) afterLoginFailure(ctx context.Context, req *LoginRequest, cause error) {
+	_ = s.runLoginHooks(ctx, "AfterFailure", func(ctx context.Context, hook LoginHook) error {
+		hook.AfterFailure(ctx, req, cause)
+		return nil
+	})
+}
+
+// lastLoginHook is the built-in hook that records last_login, through the
+// recorder when one is wired or with a direct best-effort write otherwise
+type lastLoginHook struct {
+	NopLoginHook
+	recorder *LastLoginRecorder
+	repo     UserRepository
+}
+
+func (h *lastLoginHook) AfterSuccess(ctx context.Context, req *LoginRequest) error {
+	at := *req.User.LastLogin
+	if h.recorder != nil {
+		h.recorder.Record(req.User.ID, at)
+		return nil
+	}
+
+	// Not bound by the hook timeout; the write finishes in the background
+	writeCtx, cancel := bestEffortContext(ctx, pointReadTimeout)
+	defer cancel()
+	if err := h.repo.UpdateFields(writeCtx, req.User.ID, map[string]interface{}{
+		"last_login": at,
+	}); err != nil {
+		logging.Printf(ctx, "Failed to update last login: %v", err)
+	}
+	return nil
+}
+
+// RehashHook upgrades password hashes made with a lower bcrypt cost than
+// the current one, using the plaintext we only have during login
+type RehashHook struct {
+	NopLoginHook
+	repo UserRepository
+	cost int
+}
+
+func NewRehashHook(repo UserRepository, cost int) *RehashHook {
+	return &RehashHook{repo: repo, cost: cost}
+}
+
+func (h *RehashHook) AfterSuccess(ctx context.Context, req *LoginRequest) error {
+	current, err := bcrypt.Cost([]byte(req.Credentials.PasswordHash))
+	if err != nil || current >= h.cost {
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), h.cost)
+	if err != nil {
+		return fmt.Errorf("rehash password: %w", err)
+	}
+
+	writeCtx, cancel := bestEffortContext(ctx, writeTimeout)
+	defer cancel()
+	return h.repo.UpdateFields(writeCtx, req.User.ID, map[string]interface{}{
+		"password": string(hash),
+	})
+}
@@ -0,0 +1,204 @@
+// internal/application/login_hooks_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// recordingHook logs every call under its name and can be scripted
+type recordingHook struct {
+	name   string
+	log    *callLog
+	before error
+	after  error
+	delay  time.Duration
+}
+
+type callLog struct {
+	mu     sync.Mutex
+	calls  []string
+	causes []error
+}
+
+func (l *callLog) add(call string, cause error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, call)
+	if cause != nil {
+		l.causes = append(l.causes, cause)
+	}
+}
+
+func (l *callLog) snapshot() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.calls...)
+}
+
+func (h *recordingHook) BeforeCredentialCheck(ctx context.Context, req *application.LoginRequest) error {
+	h.log.add(h.name+".before", nil)
+	return h.before
+}
+
+func (h *recordingHook) AfterSuccess(ctx context.Context, req *application.LoginRequest) error {
+	if h.delay > 0 {
+		time.Sleep(h.delay)
+	}
+	h.log.add(h.name+".success", nil)
+	return h.after
+}
+
+func (h *recordingHook) AfterFailure(ctx context.Context, req *application.LoginRequest, cause error) {
+	h.log.add(h.name+".failure", cause)
+}
+
+func TestLoginHooks_RunInOrder(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	repo.AddUser("alice@example.com", "secret123")
+	calls := &callLog{}
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithLoginHooks(
+		&recordingHook{name: "first", log: calls},
+		&recordingHook{name: "second", log: calls},
+	))
+
+	user, err := svc.Login(context.Background(), "alice@example.com", "secret123")
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if user.LastLogin == nil {
+		t.Error("built-in last login hook did not run")
+	}
+
+	want := []string{"first.before", "second.before", "first.success", "second.success"}
+	if got := calls.snapshot(); !equalStrings(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestLoginHooks_DenyBeforeCredentialCheck(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	repo.AddUser("alice@example.com", "secret123")
+	calls := &callLog{}
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithLoginHooks(
+		&recordingHook{name: "travel", log: calls, before: application.DenyLogin("impossible travel")},
+		&recordingHook{name: "never", log: calls},
+	))
+
+	_, err := svc.Login(context.Background(), "alice@example.com", "secret123")
+	if !errors.Is(err, application.ErrLoginDenied) {
+		t.Fatalf("expected ErrLoginDenied, got %v", err)
+	}
+	var denied *application.LoginDeniedError
+	if !errors.As(err, &denied) || denied.Reason != "impossible travel" {
+		t.Errorf("expected the hook's reason, got %v", err)
+	}
+
+	want := []string{"travel.before", "travel.failure", "never.failure"}
+	if got := calls.snapshot(); !equalStrings(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if !errors.Is(calls.causes[0], application.ErrLoginDenied) {
+		t.Errorf("AfterFailure got cause %v", calls.causes[0])
+	}
+}
+
+func TestLoginHooks_DenyAfterSuccess(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	requested := time.Now().UTC()
+	alice.Status, alice.DeletionRequestedAt = domain.StatusPendingDeletion, &requested
+	repo.Put(alice)
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithLoginHooks(
+		&recordingHook{name: "breach", log: &callLog{}, after: application.DenyLogin("breached password")},
+	))
+
+	if _, err := svc.Login(context.Background(), "alice@example.com", "secret123"); !errors.Is(err, application.ErrLoginDenied) {
+		t.Fatalf("expected ErrLoginDenied, got %v", err)
+	}
+	svc.Wait()
+	// A denied login has none of a successful one's side effects
+	if stored, _ := repo.User(alice.ID); stored.LastLogin != nil || !stored.IsPendingDeletion() {
+		t.Errorf("expected no last login and the deletion still pending, got %v / %s", stored.LastLogin, stored.Status)
+	}
+	if application.ClassifyError(application.ErrLoginDenied) != application.OutcomeForbidden {
+		t.Error("denied logins should be classified as forbidden")
+	}
+}
+
+func TestLoginHooks_FailuresSeeCause(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	repo.AddUser("alice@example.com", "secret123")
+	calls := &callLog{}
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithLoginHooks(
+		&recordingHook{name: "fraud", log: calls},
+	))
+
+	_, _ = svc.Login(context.Background(), "alice@example.com", "wrong")
+	_, _ = svc.Login(context.Background(), "nobody@example.com", "secret123")
+
+	want := []string{"fraud.before", "fraud.failure", "fraud.failure"}
+	if got := calls.snapshot(); !equalStrings(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	for _, cause := range calls.causes {
+		if !errors.Is(cause, application.ErrInvalidCredentials) {
+			t.Errorf("expected ErrInvalidCredentials cause, got %v", cause)
+		}
+	}
+}
+
+func TestLoginHooks_SlowOrBrokenHooksFailOpen(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	repo.AddUser("alice@example.com", "secret123")
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithLoginHookTimeout(20*time.Millisecond),
+		application.WithLoginHooks(
+			&recordingHook{name: "slow", log: &callLog{}, delay: time.Second},
+			&recordingHook{name: "broken", log: &callLog{}, after: errors.New("fraud service unavailable")},
+		),
+	)
+
+	start := time.Now()
+	if _, err := svc.Login(context.Background(), "alice@example.com", "secret123"); err != nil {
+		t.Fatalf("slow or failing hooks must not break login: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("login waited %v for a slow hook", elapsed)
+	}
+}
+
+func TestRehashHook_UpgradesWeakHashes(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123") // bcrypt.MinCost
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithLoginHookTimeout(time.Second),
+		application.WithLoginHooks(application.NewRehashHook(repo, bcrypt.MinCost+1)),
+	)
+
+	if _, err := svc.Login(context.Background(), "alice@example.com", "secret123"); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	if cost, _ := bcrypt.Cost([]byte(repo.PasswordHash(user.ID))); cost != bcrypt.MinCost+1 {
+		t.Fatalf("expected hash upgraded to cost %d, got %d", bcrypt.MinCost+1, cost)
+	}
+	if _, err := svc.Login(context.Background(), "alice@example.com", "secret123"); err != nil {
+		t.Fatalf("login with the rehashed password failed: %v", err)
+	}
+}
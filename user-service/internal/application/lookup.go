@@ -0,0 +1,89 @@
+package application
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/normalize"
+)
+
+// EmailLookup is the minimal answer other services get about an email
+type EmailLookup struct {
+	Exists        bool
+	UserID        uint
+	EmailVerified bool
+}
+
+// LookupByEmail tells internal callers whether an account exists for the
+// email. It is an enumeration oracle by design, so every call is audited
+// with the caller's identity and a hash of the email (never the email).
+func (s *UserService) LookupByEmail(ctx context.Context, email, caller string) (*EmailLookup, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	email = normalize.Email(email)
+
+	user, err := s.findByEmail(ctx, email)
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return nil, err
+	}
+
+	result := &EmailLookup{}
+	if user != nil {
+		result.Exists = true
+		result.UserID = user.ID
+		result.EmailVerified = user.IsEmailVerified()
+	}
+
+	if s.audit != nil {
+		sum := sha256.Sum256([]byte(email))
+		entry := &AuditEntry{
+			Action:   AuditEmailLookup,
+			TargetID: result.UserID,
+			Metadata: map[string]interface{}{
+				"caller":     caller,
+				"email_hash": hex.EncodeToString(sum[:]),
+				"exists":     result.Exists,
+			},
+			CreatedAt: time.Now().UTC(),
+		}
+		s.afterCommit(ctx, "audit email lookup", func(ctx context.Context) error {
+			return s.audit.Record(ctx, entry)
+		})
+	}
+
+	return result, nil
+}
+
+// findByEmail consults the email cache before the database
+func (s *UserService) findByEmail(ctx context.Context, email string) (*domain.User, error) {
+	if s.cache != nil {
+		cacheCtx, cancel := stepContext(ctx, cacheOpTimeout)
+		user, err := s.cache.GetByEmail(cacheCtx, email)
+		cancel()
+		if err == nil {
+			s.observeCacheReads(1, 0)
+			return user, nil
+		}
+		s.observeCacheReads(0, 1)
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByEmail(readCtx, email)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cache != nil {
+		cacheCtx, cancel := bestEffortContext(ctx, cacheOpTimeout)
+		_ = s.cache.SetByEmail(cacheCtx, email, user)
+		cancel()
+	}
+
+	return user, nil
+}
@@ -0,0 +1,61 @@
+// internal/application/lookup_test.go
+package application_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/testsupport"
+)
+
+func TestLookupByEmail(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	audit := &fakeAuditLogger{}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithAuditLogger(audit))
+	ctx := context.Background()
+
+	result, err := svc.LookupByEmail(ctx, " ALICE@example.com ", "checkout")
+	if err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+	if !result.Exists || result.UserID != user.ID || result.EmailVerified {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	result, err = svc.LookupByEmail(ctx, "ghost@example.com", "checkout")
+	if err != nil {
+		t.Fatalf("lookup of unknown email should not error: %v", err)
+	}
+	if result.Exists || result.UserID != 0 {
+		t.Errorf("expected no account, got %+v", result)
+	}
+
+	if len(audit.entries) != 2 {
+		t.Fatalf("expected every lookup to be audited, got %d entries", len(audit.entries))
+	}
+	for _, e := range audit.entries {
+		if e.Action != application.AuditEmailLookup || e.Metadata["caller"] != "checkout" {
+			t.Errorf("unexpected audit entry: %+v", e)
+		}
+		if _, leaked := e.Metadata["email"]; leaked {
+			t.Error("audit entry must not contain the raw email")
+		}
+	}
+}
+
+func TestLookupByEmail_VerifiedFlag(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	repo.Put(user)
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	result, _ := svc.LookupByEmail(context.Background(), "alice@example.com", "checkout")
+	if !result.EmailVerified {
+		t.Error("expected email_verified to be true")
+	}
+}
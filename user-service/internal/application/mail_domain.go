@@ -0,0 +1,46 @@
+package application
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"user-service/internal/logging"
+)
+
+// mailDomainCheckTimeout bounds the DNS lookup a signup waits on; a slow
+// resolver lets the signup through rather than holding it up
+const mailDomainCheckTimeout = 500 * time.Millisecond
+
+// MailDomainChecker reports whether an email domain can receive mail. An
+// error means no definitive answer, and the address is accepted.
+type MailDomainChecker interface {
+	CanReceiveMail(ctx context.Context, domain string) (bool, error)
+}
+
+// WithMailDomainChecker rejects signups whose email domain can't receive
+// mail, which would bounce every verification email
+func WithMailDomainChecker(checker MailDomainChecker) Option {
+	return func(s *UserService) {
+		s.mailDomains = checker
+	}
+}
+
+// emailDomainRejected reports whether email's domain definitively can't
+// receive mail. Lookup failures and timeouts let the address through.
+func (s *UserService) emailDomainRejected(ctx context.Context, email string) bool {
+	at := strings.LastIndexByte(email, '@')
+	if s.mailDomains == nil || at < 0 || at == len(email)-1 {
+		return false
+	}
+	domain := email[at+1:]
+
+	checkCtx, cancel := stepContext(ctx, mailDomainCheckTimeout)
+	defer cancel()
+	canReceive, err := s.mailDomains.CanReceiveMail(checkCtx, domain)
+	if err != nil {
+		logging.Printf(ctx, "Mail domain check for %s failed, accepting: %v", domain, err)
+		return false
+	}
+	return !canReceive
+}
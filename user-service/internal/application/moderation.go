@@ -0,0 +1,140 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"user-service/internal/domain"
+)
+
+// Event types for moderation actions
+const (
+	EventUserBanned   = "user.banned"
+	EventUserUnbanned = "user.unbanned"
+)
+
+// UserBlocklist is the shared set of inactive (banned or deleted) user IDs
+// whose tokens must be rejected regardless of expiry
+type UserBlocklist interface {
+	Block(ctx context.Context, userID uint) error
+	Unblock(ctx context.Context, userID uint) error
+}
+
+// WithAuditLogger records moderation and other sensitive actions
+func WithAuditLogger(audit AuditLogger) Option {
+	return func(s *UserService) {
+		s.audit = audit
+	}
+}
+
+// WithUserBlocklist lets bans and deletions take effect on already issued
+// tokens
+func WithUserBlocklist(blocklist UserBlocklist) Option {
+	return func(s *UserService) {
+		s.blocklist = blocklist
+	}
+}
+
+// BanUser locks an active account out immediately: login is refused, and
+// the ban raises the token version, so every token issued so far stops
+// working with the commit. Any other status fails with ErrUserNotActive.
+func (s *UserService) BanUser(ctx context.Context, id uint, reason string, actorID uint) error {
+	return s.setBanned(ctx, id, true, reason, actorID)
+}
+
+// UnbanUser restores access to a banned account, failing with
+// ErrUserNotBanned for any other
+func (s *UserService) UnbanUser(ctx context.Context, id uint, reason string, actorID uint) error {
+	return s.setBanned(ctx, id, false, reason, actorID)
+}
+
+func (s *UserService) setBanned(ctx context.Context, id uint, banned bool, reason string, actorID uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	from, to, wrongStatus := domain.StatusBanned, domain.StatusActive, ErrUserNotBanned
+	action, eventType := AuditUserUnbanned, EventUserUnbanned
+	if banned {
+		from, to, wrongStatus = domain.StatusActive, domain.StatusBanned, ErrUserNotActive
+		action, eventType = AuditUserBanned, EventUserBanned
+	}
+	if user.Status != from {
+		return wrongStatus
+	}
+
+	changedAt := s.now().UTC()
+	// signedOut is the banned user with their raised token version
+	var signedOut *domain.User
+	err = s.transition(ctx, id, from, map[string]interface{}{
+		"status": string(to),
+	}, &AuditEntry{
+		Action:    action,
+		ActorID:   actorID,
+		TargetID:  id,
+		Reason:    reason,
+		CreatedAt: changedAt,
+	}, func(ctx context.Context, tx *TxService) error {
+		if !banned {
+			return nil
+		}
+		var err error
+		if signedOut, err = nextTokenVersion(ctx, tx, id); err != nil {
+			return err
+		}
+		return tx.UpdateFields(ctx, id, map[string]interface{}{
+			"token_version": signedOut.TokenVersion,
+		})
+	})
+	if errors.Is(err, errStatusChanged) {
+		return wrongStatus
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update ban status: %w", err)
+	}
+
+	if signedOut != nil {
+		s.cacheTokenVersion(ctx, signedOut)
+		if s.sessions != nil {
+			s.afterCommit(ctx, "revoke sessions", func(ctx context.Context) error {
+				return s.sessions.RevokeUserSessions(ctx, id)
+			})
+		}
+	}
+	s.updateBlocklist(ctx, id)
+
+	s.markWritten(ctx, id)
+	if s.cache != nil {
+		s.afterCommit(ctx, "invalidate cache", func(ctx context.Context) error {
+			if err := s.cache.Delete(ctx, id); err != nil {
+				return err
+			}
+			return s.cache.DeleteByEmail(ctx, user.Email)
+		})
+	}
+
+	if s.events != nil {
+		event := Event{
+			Type:       eventType,
+			UserID:     id,
+			OccurredAt: changedAt,
+			Data: map[string]interface{}{
+				"reason":   reason,
+				"actor_id": actorID,
+			},
+		}
+		s.afterCommit(ctx, "publish moderation event", func(ctx context.Context) error {
+			return s.events.Publish(ctx, event)
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+// internal/application/moderation_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+
+	"gorm.io/gorm"
+)
+
+func TestBanUnbanUser(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	audit := &fakeAuditLogger{}
+	blocklist := newFakeBlocklist()
+	publisher := &fakePublisher{}
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithAuditLogger(audit),
+		application.WithUserBlocklist(blocklist),
+		application.WithEventPublisher(publisher),
+	)
+	ctx := context.Background()
+
+	if err := svc.BanUser(ctx, user.ID, "spam", 99); err != nil {
+		t.Fatalf("ban failed: %v", err)
+	}
+
+	stored, _ := repo.GetByID(ctx, user.ID)
+	if stored.Status != domain.StatusBanned {
+		t.Errorf("expected status banned, got %q", stored.Status)
+	}
+	if !blocklist.blocked[user.ID] {
+		t.Error("expected user to be on the blocklist")
+	}
+	if len(audit.entries) != 1 || audit.entries[0].Action != application.AuditUserBanned ||
+		audit.entries[0].ActorID != 99 || audit.entries[0].Reason != "spam" {
+		t.Errorf("unexpected audit entries: %+v", audit.entries)
+	}
+
+	// Correct password on a banned account yields the specific error
+	if _, err := svc.Login(ctx, "alice@example.com", "secret123"); !errors.Is(err, application.ErrUserBanned) {
+		t.Fatalf("expected ErrUserBanned, got %v", err)
+	}
+	// Wrong password never reveals the ban
+	if _, err := svc.Login(ctx, "alice@example.com", "wrong"); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+
+	if err := svc.UnbanUser(ctx, user.ID, "appeal accepted", 99); err != nil {
+		t.Fatalf("unban failed: %v", err)
+	}
+	if blocklist.blocked[user.ID] {
+		t.Error("expected blocklist entry to be cleared")
+	}
+	if _, err := svc.Login(ctx, "alice@example.com", "secret123"); err != nil {
+		t.Fatalf("expected login to work after unban, got %v", err)
+	}
+
+	if len(publisher.events) != 2 || publisher.events[0].Type != application.EventUserBanned ||
+		publisher.events[1].Type != application.EventUserUnbanned {
+		t.Errorf("unexpected events: %+v", publisher.events)
+	}
+}
+
+func TestBanUser_SignsOutWithTheServiceClock(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	audit := &fakeAuditLogger{}
+	publisher := &fakePublisher{}
+	revoker := &fakeRevoker{}
+	clock := testsupport.NewClock()
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithAuditLogger(audit),
+		application.WithEventPublisher(publisher),
+		application.WithSessionRevoker(revoker),
+		application.WithClock(clock.Now),
+	)
+
+	if err := svc.BanUser(context.Background(), user.ID, "spam", 99); err != nil {
+		t.Fatalf("ban failed: %v", err)
+	}
+
+	stored, _ := repo.User(user.ID)
+	if stored.TokenVersion != user.TokenVersion+1 {
+		t.Errorf("expected token version %d, got %d", user.TokenVersion+1, stored.TokenVersion)
+	}
+	if len(revoker.revoked) != 1 || revoker.revoked[0] != user.ID {
+		t.Errorf("expected the user's sessions revoked, got %v", revoker.revoked)
+	}
+	if len(audit.entries) != 1 || !audit.entries[0].CreatedAt.Equal(clock.Now()) {
+		t.Errorf("expected the audit entry stamped %v, got %+v", clock.Now(), audit.entries)
+	}
+	if len(publisher.events) != 1 || !publisher.events[0].OccurredAt.Equal(clock.Now()) {
+		t.Errorf("expected the event stamped %v, got %+v", clock.Now(), publisher.events)
+	}
+}
+
+func TestBanUnbanUser_OnlyFromTheExpectedStatus(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+	ctx := context.Background()
+
+	if err := svc.UnbanUser(ctx, user.ID, "appeal", 99); !errors.Is(err, application.ErrUserNotBanned) {
+		t.Fatalf("expected ErrUserNotBanned for an active user, got %v", err)
+	}
+
+	if err := svc.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if err := svc.BanUser(ctx, user.ID, "spam", 99); !errors.Is(err, application.ErrUserNotActive) {
+		t.Fatalf("expected ErrUserNotActive for a pending deletion, got %v", err)
+	}
+	if err := svc.UnbanUser(ctx, user.ID, "appeal", 99); !errors.Is(err, application.ErrUserNotBanned) {
+		t.Fatalf("expected ErrUserNotBanned for a pending deletion, got %v", err)
+	}
+
+	stored, _ := repo.User(user.ID)
+	if stored.Status != domain.StatusPendingDeletion || stored.DeletionRequestedAt == nil {
+		t.Errorf("expected the deletion left pending, got %q requested at %v", stored.Status, stored.DeletionRequestedAt)
+	}
+}
+
+// bannedAfterWriteRepo lets a ban commit straight after the unban it wraps,
+// before the unban's after-commit work has run.
+type bannedAfterWriteRepo struct {
+	*testsupport.UserRepository
+}
+
+func (r *bannedAfterWriteRepo) WithTx(tx *gorm.DB) application.UserRepository {
+	return r
+}
+
+func (r *bannedAfterWriteRepo) UpdateFieldsIfStatus(ctx context.Context, id uint, status domain.UserStatus, fields map[string]interface{}) (bool, error) {
+	updated, err := r.UserRepository.UpdateFieldsIfStatus(ctx, id, status, fields)
+	if !updated || err != nil {
+		return updated, err
+	}
+	user, _ := r.User(id)
+	user.Status = domain.StatusBanned
+	r.Put(user)
+	return true, nil
+}
+
+func TestUnbanUser_KeepsBlockedWhenStatusIsNotActive(t *testing.T) {
+	inner := testsupport.NewUserRepository()
+	user := inner.AddUser("alice@example.com", "secret123")
+	user.Status = domain.StatusBanned
+	inner.Put(user)
+	repo := &bannedAfterWriteRepo{UserRepository: inner}
+	blocklist := newFakeBlocklist()
+	blocklist.blocked[user.ID] = true
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithUserBlocklist(blocklist),
+	)
+
+	if err := svc.UnbanUser(context.Background(), user.ID, "appeal accepted", 99); err != nil {
+		t.Fatalf("unban failed: %v", err)
+	}
+	if !blocklist.blocked[user.ID] {
+		t.Error("expected a user banned again before the unban settled to stay blocked")
+	}
+}
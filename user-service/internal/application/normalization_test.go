@@ -0,0 +1,94 @@
+// internal/application/normalization_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/normalize"
+	"user-service/internal/testsupport"
+)
+
+// padding is the whitespace clients have been seen to send around fields
+var padding = []string{"", " ", "  ", "\t", "\n", "\r\n", "\u00a0", "\u3000"}
+
+// variant returns s with random ASCII letters re-cased and random padding
+func variant(rng *rand.Rand, s string) string {
+	var b strings.Builder
+	b.WriteString(padding[rng.Intn(len(padding))])
+	for _, r := range s {
+		if rng.Intn(2) == 0 {
+			b.WriteString(strings.ToUpper(string(r)))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	b.WriteString(padding[rng.Intn(len(padding))])
+	return b.String()
+}
+
+func TestLogin_RegisteredEmailLogsInWithAnyCaseOrPadding(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+
+	registered := &domain.User{Username: "alice", Email: " Alice.Smith@Example.com "}
+	if _, err := svc.Register(ctx, registered, " secret123\t", ""); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		email := variant(rng, "alice.smith@example.com")
+		password := padding[rng.Intn(len(padding))] + "secret123" + padding[rng.Intn(len(padding))]
+		user, err := svc.Login(ctx, email, password)
+		if err != nil {
+			t.Fatalf("login with %q / %q: %v", email, password, err)
+		}
+		if user.ID != registered.ID {
+			t.Fatalf("login with %q found user %d, want %d", email, user.ID, registered.ID)
+		}
+	}
+
+	// Case still matters inside the password
+	if _, err := svc.Login(ctx, "alice.smith@example.com", "SECRET123"); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Errorf("expected the password to stay case-sensitive, got %v", err)
+	}
+}
+
+// FuzzValidateRegistration checks the service's own guard: whatever the
+// caller passes, validation never accepts a user whose username or email
+// is empty once normalized
+func FuzzValidateRegistration(f *testing.F) {
+	f.Add("alice", "alice@example.com", "secret123")
+	f.Add("   ", "alice@example.com", "secret123")
+	f.Add("alice", " \t ", "secret123")
+	f.Add("Admin", "ADMIN@example.com", " admin1 ")
+	f.Add("bob", "taken@example.com", "secret123")
+
+	repo := testsupport.NewUserRepository()
+	repo.AddUser("taken@example.com", "secret123")
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	f.Fuzz(func(t *testing.T, username, email, password string) {
+		user := &domain.User{Username: username, Email: email}
+		err := svc.ValidateRegistration(context.Background(), user, password)
+
+		var verr *application.ValidationError
+		if err != nil && !errors.As(err, &verr) {
+			t.Fatalf("unexpected error type %T: %v", err, err)
+		}
+		if err == nil && (normalize.Username(username) == "" || normalize.Email(email) == "" || normalize.Password(password) == "") {
+			t.Fatalf("accepted %q / %q / %q with an empty field", username, email, password)
+		}
+		if !reflect.DeepEqual(*user, domain.User{Username: username, Email: email}) {
+			t.Fatal("ValidateRegistration must not modify its argument")
+		}
+	})
+}
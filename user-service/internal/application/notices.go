@@ -0,0 +1,251 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+)
+
+// ErrNoticesNotConfigured is returned by the notice methods that need
+// storage when the service was built without a NoticeRepository
+var ErrNoticesNotConfigured = errors.New("notices not configured")
+
+// MaxNoticeMessageLength bounds the text of an authored notice
+const MaxNoticeMessageLength = 500
+
+// noticeCodePattern is what an authored notice's code may look like; it
+// ends up in the dismiss URL
+var noticeCodePattern = regexp.MustCompile(`^[a-z0-9_]{1,50}$`)
+
+// NoticeRepository persists admin-authored notices and the notices users
+// dismissed
+type NoticeRepository interface {
+	// ListByUser returns userID's authored notices, expired ones included
+	ListByUser(ctx context.Context, userID uint) ([]*domain.UserNotice, error)
+	// Put stores notice, filling in its ID and replacing any notice
+	// notice.UserID already has under the same code
+	Put(ctx context.Context, notice *domain.UserNotice) error
+	// Delete removes userID's notice code, failing with
+	// domain.ErrNoticeNotFound when there is none
+	Delete(ctx context.Context, userID uint, code string) error
+	// Dismissals maps the code of each notice userID dismissed to the
+	// version dismissed
+	Dismissals(ctx context.Context, userID uint) (map[string]string, error)
+	// Dismiss records userID dismissing version of notice code, replacing
+	// an earlier dismissal of the code
+	Dismiss(ctx context.Context, userID uint, code, version string, at time.Time) error
+}
+
+// WithNoticeRepository stores admin-authored notices and dismissals.
+// Without it users only see notices derived from their account, and can't
+// dismiss them.
+func WithNoticeRepository(repo NoticeRepository) Option {
+	return func(s *UserService) {
+		s.notices = repo
+	}
+}
+
+// WithTermsVersion shows the terms_updated notice for version to accounts
+// created before updatedAt, until they dismiss it. A zero updatedAt shows
+// it to every account.
+func WithTermsVersion(version string, updatedAt time.Time) Option {
+	return func(s *UserService) {
+		s.termsVersion = version
+		s.termsUpdatedAt = updatedAt
+	}
+}
+
+// Notices returns the notices user should see now, most severe first
+func (s *UserService) Notices(ctx context.Context, user *domain.User) ([]domain.Notice, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	state := domain.NoticeState{
+		TermsVersion:        s.termsVersion,
+		TermsUpdatedAt:      s.termsUpdatedAt,
+		DeletionGracePeriod: s.deletionGracePeriod,
+	}
+	if s.notices != nil {
+		readCtx, cancel := stepContext(ctx, pointReadTimeout)
+		defer cancel()
+		authored, err := s.notices.ListByUser(readCtx, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list notices: %w", err)
+		}
+		dismissed, err := s.notices.Dismissals(readCtx, user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list dismissed notices: %w", err)
+		}
+		state.Authored, state.Dismissed = authored, dismissed
+	}
+	return domain.ActiveNotices(user, state, s.now()), nil
+}
+
+// DismissNotice hides the user's active notice code until a new version
+// of it comes along. Notices about something the user must act on can't
+// be dismissed.
+func (s *UserService) DismissNotice(ctx context.Context, userID uint, code string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.notices == nil {
+		return ErrNoticesNotConfigured
+	}
+
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	notices, err := s.Notices(ctx, user)
+	if err != nil {
+		return err
+	}
+	for _, notice := range notices {
+		if notice.Code != code {
+			continue
+		}
+		if !notice.Dismissible {
+			return domain.ErrNoticeNotDismissible
+		}
+		writeCtx, cancel := stepContext(ctx, writeTimeout)
+		defer cancel()
+		if err := s.notices.Dismiss(writeCtx, userID, code, notice.Version, s.now().UTC()); err != nil {
+			return fmt.Errorf("failed to dismiss notice: %w", err)
+		}
+		return nil
+	}
+	return domain.ErrNoticeNotFound
+}
+
+// AddNotice shows notice to notice.UserID, replacing their notice with the
+// same code. Severity defaults to info; CreatedBy names the admin client.
+func (s *UserService) AddNotice(ctx context.Context, notice *domain.UserNotice) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.notices == nil {
+		return ErrNoticesNotConfigured
+	}
+
+	now := s.now().UTC()
+	if err := validateNotice(notice, now); err != nil {
+		return err
+	}
+	if _, err := s.GetUser(ctx, notice.UserID); err != nil {
+		return err
+	}
+	notice.CreatedAt = now
+
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	if err := s.notices.Put(writeCtx, notice); err != nil {
+		return fmt.Errorf("failed to add notice: %w", err)
+	}
+
+	if s.audit != nil {
+		entry := &AuditEntry{
+			Action:   AuditNoticeAdded,
+			TargetID: notice.UserID,
+			Metadata: map[string]interface{}{
+				"code":       notice.Code,
+				"severity":   string(notice.Severity),
+				"created_by": notice.CreatedBy,
+			},
+			CreatedAt: now,
+		}
+		s.afterCommit(ctx, "audit notice", func(ctx context.Context) error {
+			return s.audit.Record(ctx, entry)
+		})
+	}
+	return nil
+}
+
+// RemoveNotice takes down the user's authored notice code on behalf of
+// the admin client removedBy
+func (s *UserService) RemoveNotice(ctx context.Context, userID uint, code, removedBy string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.notices == nil {
+		return ErrNoticesNotConfigured
+	}
+
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	err := s.notices.Delete(writeCtx, userID, code)
+	cancel()
+	if errors.Is(err, domain.ErrNoticeNotFound) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove notice: %w", err)
+	}
+
+	if s.audit != nil {
+		entry := &AuditEntry{
+			Action:    AuditNoticeRemoved,
+			TargetID:  userID,
+			Metadata:  map[string]interface{}{"code": code, "removed_by": removedBy},
+			CreatedAt: s.now().UTC(),
+		}
+		s.afterCommit(ctx, "audit notice", func(ctx context.Context) error {
+			return s.audit.Record(ctx, entry)
+		})
+	}
+	return nil
+}
+
+// validateNotice checks an authored notice about to be stored, defaulting
+// its severity to info
+func validateNotice(notice *domain.UserNotice, now time.Time) error {
+	verr := &ValidationError{Fields: make(map[string]string)}
+
+	switch {
+	case !noticeCodePattern.MatchString(notice.Code):
+		verr.Fields["code"] = "code must be 1 to 50 lowercase letters, digits or underscores"
+	case isDerivedNoticeCode(notice.Code):
+		verr.Fields["code"] = fmt.Sprintf("code %q is reserved", notice.Code)
+	}
+
+	if notice.Severity == "" {
+		notice.Severity = domain.NoticeInfo
+	}
+	if !notice.Severity.Valid() {
+		names := make([]string, len(domain.NoticeSeverities))
+		for i, severity := range domain.NoticeSeverities {
+			names[i] = string(severity)
+		}
+		verr.Fields["severity"] = "severity must be one of " + strings.Join(names, ", ")
+	}
+
+	notice.Message = strings.TrimSpace(notice.Message)
+	switch {
+	case notice.Message == "":
+		verr.Fields["message"] = "message is required"
+	case len(notice.Message) > MaxNoticeMessageLength:
+		verr.Fields["message"] = fmt.Sprintf("message must be at most %d characters", MaxNoticeMessageLength)
+	}
+
+	if notice.ExpiresAt != nil && !notice.ExpiresAt.After(now) {
+		verr.Fields["expires_at"] = "expires_at must be in the future"
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func isDerivedNoticeCode(code string) bool {
+	for _, derived := range domain.DerivedNoticeCodes {
+		if code == derived {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+// internal/application/notices_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+func noticeCodes(notices []domain.Notice) []string {
+	codes := make([]string, len(notices))
+	for i, notice := range notices {
+		codes[i] = notice.Code
+	}
+	return codes
+}
+
+func TestNotices_DismissAndReissue(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	notices := testsupport.NewNoticeRepository()
+	audit := &fakeAuditLogger{}
+	clock := testsupport.NewClock()
+	user := repo.AddUser("alice@example.com", "secret123")
+	user.MustResetPassword = true
+	repo.Put(user)
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithNoticeRepository(notices),
+		application.WithTermsVersion("2024-05", time.Time{}),
+		application.WithAuditLogger(audit),
+		application.WithClock(clock.Now),
+	)
+	ctx := context.Background()
+
+	survey := &domain.UserNotice{UserID: user.ID, Code: "survey", Message: " Tell us how we did ", Dismissible: true, CreatedBy: "support-console"}
+	if err := svc.AddNotice(ctx, survey); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if survey.Severity != domain.NoticeInfo || survey.Message != "Tell us how we did" {
+		t.Errorf("expected the notice defaulted and trimmed, got %+v", survey)
+	}
+
+	current := func() []string {
+		t.Helper()
+		user, _ := svc.GetUser(ctx, user.ID)
+		active, err := svc.Notices(ctx, user)
+		if err != nil {
+			t.Fatalf("notices: %v", err)
+		}
+		return noticeCodes(active)
+	}
+	if got := current(); len(got) != 4 {
+		t.Fatalf("expected reset, verify, survey and terms notices, got %v", got)
+	}
+
+	for _, code := range []string{"survey", domain.NoticeTermsUpdated, domain.NoticeVerifyEmail} {
+		if err := svc.DismissNotice(ctx, user.ID, code); err != nil {
+			t.Fatalf("dismiss %s: %v", code, err)
+		}
+	}
+	if err := svc.DismissNotice(ctx, user.ID, domain.NoticePasswordReset); !errors.Is(err, domain.ErrNoticeNotDismissible) {
+		t.Errorf("expected ErrNoticeNotDismissible, got %v", err)
+	}
+	if err := svc.DismissNotice(ctx, user.ID, "survey"); !errors.Is(err, domain.ErrNoticeNotFound) {
+		t.Errorf("expected a dismissed notice gone, got %v", err)
+	}
+	if got := current(); len(got) != 1 || got[0] != domain.NoticePasswordReset {
+		t.Fatalf("expected only the reset notice left, got %v", got)
+	}
+
+	// Reissuing the survey brings it back
+	if err := svc.AddNotice(ctx, &domain.UserNotice{UserID: user.ID, Code: "survey", Message: "Last chance", Dismissible: true}); err != nil {
+		t.Fatalf("reissue: %v", err)
+	}
+	if got := current(); len(got) != 2 || got[1] != "survey" {
+		t.Errorf("expected the reissued survey shown, got %v", got)
+	}
+
+	if err := svc.RemoveNotice(ctx, user.ID, "survey", "support-console"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if err := svc.RemoveNotice(ctx, user.ID, "survey", "support-console"); !errors.Is(err, domain.ErrNoticeNotFound) {
+		t.Errorf("expected ErrNoticeNotFound, got %v", err)
+	}
+	if len(audit.entries) != 3 || audit.entries[0].Action != application.AuditNoticeAdded || audit.entries[2].Action != application.AuditNoticeRemoved {
+		t.Errorf("expected the admin changes audited, got %+v", audit.entries)
+	}
+}
+
+func TestAddNotice_Validation(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	clock := testsupport.NewClock()
+	user := repo.AddUser("alice@example.com", "secret123")
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithNoticeRepository(testsupport.NewNoticeRepository()),
+		application.WithClock(clock.Now),
+	)
+	past := clock.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name      string
+		notice    domain.UserNotice
+		wantField string
+	}{
+		{"bad code", domain.UserNotice{Code: "Billing Issue", Message: "x"}, "code"},
+		{"reserved code", domain.UserNotice{Code: domain.NoticeVerifyEmail, Message: "x"}, "code"},
+		{"unknown severity", domain.UserNotice{Code: "billing", Severity: "loud", Message: "x"}, "severity"},
+		{"no message", domain.UserNotice{Code: "billing", Message: "  "}, "message"},
+		{"already expired", domain.UserNotice{Code: "billing", Message: "x", ExpiresAt: &past}, "expires_at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notice := tt.notice
+			notice.UserID = user.ID
+			var verr *application.ValidationError
+			if err := svc.AddNotice(context.Background(), &notice); !errors.As(err, &verr) || verr.Fields[tt.wantField] == "" {
+				t.Errorf("expected a %s error, got %v", tt.wantField, err)
+			}
+		})
+	}
+
+	notice := domain.UserNotice{UserID: 404, Code: "billing", Message: "x"}
+	if err := svc.AddNotice(context.Background(), &notice); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestNotices_WithoutRepository(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	active, err := svc.Notices(context.Background(), user)
+	if err != nil || len(active) != 1 || active[0].Code != domain.NoticeVerifyEmail {
+		t.Fatalf("expected the derived notices, got %v (%v)", active, err)
+	}
+	if err := svc.DismissNotice(context.Background(), user.ID, domain.NoticeVerifyEmail); !errors.Is(err, application.ErrNoticesNotConfigured) {
+		t.Errorf("expected ErrNoticesNotConfigured, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"user-service/internal/domain"
+)
+
+// ErrMailerNotConfigured is returned by SendNotification when the service
+// was built without a Mailer
+var ErrMailerNotConfigured = errors.New("mailer not configured")
+
+// Mailer delivers email. It sends whatever it is handed; deciding what a
+// user should receive is SendNotification's job.
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Message is one email to a user
+type Message struct {
+	To       string
+	Category domain.NotificationCategory
+	Subject  string
+	Body     string
+}
+
+// WithMailer sets where SendNotification delivers mail
+func WithMailer(mailer Mailer) Option {
+	return func(s *UserService) {
+		s.mailer = mailer
+	}
+}
+
+// NotificationPreferencesUpdate changes the preferences that are set and
+// leaves the rest alone
+type NotificationPreferencesUpdate struct {
+	SecurityAlerts *bool
+	Marketing      *bool
+	ProductUpdates *bool
+}
+
+// UpdateNotificationPreferences applies update to the user's preferences
+// and returns the result. Every change is audited; an update that changes
+// nothing writes nothing.
+func (s *UserService) UpdateNotificationPreferences(ctx context.Context, id uint, update NotificationPreferencesUpdate) (*domain.NotificationPreferences, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	before := user.Notifications
+	after := before
+	if update.SecurityAlerts != nil {
+		after.SecurityAlertsMuted = !*update.SecurityAlerts
+	}
+	if update.Marketing != nil {
+		after.Marketing = *update.Marketing
+	}
+	if update.ProductUpdates != nil {
+		after.ProductUpdates = *update.ProductUpdates
+	}
+	if after == before {
+		return &after, nil
+	}
+
+	// The audit entry records the new value of each changed preference
+	fields := make(map[string]interface{})
+	changes := make(map[string]interface{})
+	if after.SecurityAlertsMuted != before.SecurityAlertsMuted {
+		fields["mute_security_alerts"] = after.SecurityAlertsMuted
+		changes["security_alerts"] = !after.SecurityAlertsMuted
+	}
+	if after.Marketing != before.Marketing {
+		fields["notify_marketing"] = after.Marketing
+		changes["marketing"] = after.Marketing
+	}
+	if after.ProductUpdates != before.ProductUpdates {
+		fields["notify_product_updates"] = after.ProductUpdates
+		changes["product_updates"] = after.ProductUpdates
+	}
+
+	err = s.updateAudited(ctx, id, fields, &AuditEntry{
+		Action:    AuditNotificationPrefsChanged,
+		ActorID:   id,
+		TargetID:  id,
+		Metadata:  changes,
+		CreatedAt: s.now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update notification preferences: %w", err)
+	}
+
+	s.invalidateUser(ctx, user)
+	return &after, nil
+}
+
+// SendNotification hands msg to the Mailer, addressed to the user, unless
+// their preferences exclude its category. It reports whether the message
+// was sent. Transactional and security-critical mail is always sent.
+func (s *UserService) SendNotification(ctx context.Context, userID uint, msg Message) (bool, error) {
+	if s.mailer == nil {
+		return false, ErrMailerNotConfigured
+	}
+
+	// Erased users come back as not found, so they get nothing
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	msg.To = user.Email
+	return s.deliver(ctx, user.Notifications, &msg)
+}
+
+// deliver sends msg unless prefs exclude its category
+func (s *UserService) deliver(ctx context.Context, prefs domain.NotificationPreferences, msg *Message) (bool, error) {
+	if !prefs.Allows(msg.Category) {
+		return false, nil
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		return false, fmt.Errorf("failed to send %s notification: %w", msg.Category, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,155 @@
+// internal/application/notifications_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+var allCategories = []domain.NotificationCategory{
+	domain.NotificationTransactional,
+	domain.NotificationSecurityCritical,
+	domain.NotificationSecurityAlert,
+	domain.NotificationMarketing,
+	domain.NotificationProductUpdate,
+}
+
+func TestSendNotification_DeliversExactlyWhatPreferencesAllow(t *testing.T) {
+	for combo := 0; combo < 8; combo++ {
+		securityAlerts, marketing, productUpdates := combo&1 != 0, combo&2 != 0, combo&4 != 0
+		name := fmt.Sprintf("alerts=%v,marketing=%v,updates=%v", securityAlerts, marketing, productUpdates)
+
+		t.Run(name, func(t *testing.T) {
+			repo := testsupport.NewUserRepository()
+			user := repo.AddUser("alice@example.com", "secret123")
+			mailer := testsupport.NewMailer()
+			svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+				application.WithMailer(mailer),
+			)
+			ctx := context.Background()
+
+			_, err := svc.UpdateNotificationPreferences(ctx, user.ID, application.NotificationPreferencesUpdate{
+				SecurityAlerts: &securityAlerts,
+				Marketing:      &marketing,
+				ProductUpdates: &productUpdates,
+			})
+			if err != nil {
+				t.Fatalf("update preferences: %v", err)
+			}
+
+			for _, category := range allCategories {
+				if _, err := svc.SendNotification(ctx, user.ID, application.Message{Category: category, Subject: string(category)}); err != nil {
+					t.Fatalf("send %s: %v", category, err)
+				}
+			}
+
+			// Transactional and security-critical mail can't be turned off
+			want := []string{"transactional", "security_critical"}
+			if securityAlerts {
+				want = append(want, "security_alert")
+			}
+			if marketing {
+				want = append(want, "marketing")
+			}
+			if productUpdates {
+				want = append(want, "product_update")
+			}
+			var got []string
+			for _, msg := range mailer.Sent() {
+				if msg.To != "alice@example.com" {
+					t.Errorf("%s went to %q", msg.Category, msg.To)
+				}
+				got = append(got, msg.Subject)
+			}
+			if !equalStrings(got, want) {
+				t.Errorf("delivered %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSendNotification_RefusesWhatItCantDeliver(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	mailer := testsupport.NewMailer()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithMailer(mailer))
+	ctx := context.Background()
+
+	// New accounts get security alerts but no promotional mail
+	if sent, _ := svc.SendNotification(ctx, user.ID, application.Message{Category: domain.NotificationSecurityAlert}); !sent {
+		t.Error("expected security alerts on by default")
+	}
+	if sent, _ := svc.SendNotification(ctx, user.ID, application.Message{Category: domain.NotificationMarketing}); sent {
+		t.Error("expected marketing off by default")
+	}
+	if sent, _ := svc.SendNotification(ctx, user.ID, application.Message{Category: "newsletter"}); sent {
+		t.Error("expected an unclassified message to be dropped")
+	}
+
+	mailer.Err = errors.New("smtp unavailable")
+	if sent, err := svc.SendNotification(ctx, user.ID, application.Message{Category: domain.NotificationTransactional}); sent || err == nil {
+		t.Errorf("expected the mailer's error, got sent=%v err=%v", sent, err)
+	}
+
+	if _, err := svc.SendNotification(ctx, 999, application.Message{Category: domain.NotificationTransactional}); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+
+	bare := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+	if _, err := bare.SendNotification(ctx, user.ID, application.Message{Category: domain.NotificationTransactional}); !errors.Is(err, application.ErrMailerNotConfigured) {
+		t.Errorf("expected ErrMailerNotConfigured, got %v", err)
+	}
+}
+
+func TestUpdateNotificationPreferences_AuditsChanges(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	audit := &fakeAuditLogger{}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithAuditLogger(audit))
+	ctx := context.Background()
+
+	on, off := true, false
+	prefs, err := svc.UpdateNotificationPreferences(ctx, user.ID, application.NotificationPreferencesUpdate{
+		SecurityAlerts: &off,
+		Marketing:      &on,
+		// Already off, so not a change
+		ProductUpdates: &off,
+	})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	want := domain.NotificationPreferences{SecurityAlertsMuted: true, Marketing: true}
+	if *prefs != want {
+		t.Errorf("returned %+v, want %+v", *prefs, want)
+	}
+	if stored, _ := repo.User(user.ID); stored.Notifications != want {
+		t.Errorf("stored %+v, want %+v", stored.Notifications, want)
+	}
+
+	if len(audit.entries) != 1 {
+		t.Fatalf("expected one audit entry, got %+v", audit.entries)
+	}
+	entry := audit.entries[0]
+	if entry.Action != application.AuditNotificationPrefsChanged || entry.ActorID != user.ID || entry.TargetID != user.ID {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	wantChanges := map[string]interface{}{"security_alerts": false, "marketing": true}
+	if !reflect.DeepEqual(entry.Metadata, wantChanges) {
+		t.Errorf("metadata %v, want %v", entry.Metadata, wantChanges)
+	}
+
+	// Nothing changes, so nothing is written or audited
+	if _, err := svc.UpdateNotificationPreferences(ctx, user.ID, application.NotificationPreferencesUpdate{Marketing: &on}); err != nil {
+		t.Fatalf("no-op update: %v", err)
+	}
+	if len(audit.entries) != 1 || repo.Calls("UpdateFields") != 1 {
+		t.Errorf("expected the no-op update to write nothing, got %d entries and %d writes", len(audit.entries), repo.Calls("UpdateFields"))
+	}
+}
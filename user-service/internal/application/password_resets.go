@@ -0,0 +1,234 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/logging"
+	"user-service/internal/normalize"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPasswordResetsNotConfigured is returned by the password reset methods
+// when the service was built without a PasswordResetStore
+var ErrPasswordResetsNotConfigured = errors.New("password resets not configured")
+
+// ErrInvalidResetToken covers unknown, used, replaced and expired reset
+// tokens alike
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordResetTTL is how long a token from ForgotPassword stays usable
+const PasswordResetTTL = 15 * time.Minute
+
+// resetSecretBytes is the randomness in a reset token
+const resetSecretBytes = 32
+
+// PasswordResetStore keeps the hash of each user's outstanding reset
+// token. A user has at most one; asking again replaces it.
+type PasswordResetStore interface {
+	// SavePasswordReset makes hash userID's reset token for ttl
+	SavePasswordReset(ctx context.Context, userID uint, hash string, ttl time.Duration) error
+	// ConsumePasswordReset deletes userID's reset token if its hash is
+	// hash, reporting whether it did. The check and the delete are one
+	// atomic step, so a token can't be used twice.
+	ConsumePasswordReset(ctx context.Context, userID uint, hash string) (bool, error)
+}
+
+// PasswordResetNotifier gets a reset token to the user who asked for it,
+// typically as a link in an email
+type PasswordResetNotifier interface {
+	SendPasswordReset(ctx context.Context, user *domain.User, token string, expiresAt time.Time) error
+}
+
+// WithPasswordResets enables ForgotPassword and ResetForgottenPassword,
+// keeping tokens in store and delivering them with notifier
+func WithPasswordResets(store PasswordResetStore, notifier PasswordResetNotifier) Option {
+	return func(s *UserService) {
+		s.passwordResets = store
+		s.resetNotifier = notifier
+	}
+}
+
+// newResetToken returns a token of the form "<user ID>.<secret>", and its
+// secret. The ID lets ResetForgottenPassword find the stored hash.
+func newResetToken(userID uint) (token, secret string, err error) {
+	b := make([]byte, resetSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret = base64.RawURLEncoding.EncodeToString(b)
+	return strconv.FormatUint(uint64(userID), 10) + "." + secret, secret, nil
+}
+
+// parseResetToken splits a token from newResetToken, rejecting anything
+// that can't be one
+func parseResetToken(token string) (uint, string, bool) {
+	id, secret, ok := strings.Cut(token, ".")
+	if !ok || len(secret) != base64.RawURLEncoding.EncodedLen(resetSecretBytes) {
+		return 0, "", false
+	}
+	userID, err := strconv.ParseUint(id, 10, 0)
+	if err != nil || userID == 0 {
+		return 0, "", false
+	}
+	return uint(userID), secret, true
+}
+
+// hashResetToken hashes a token's secret for storage. It is 256 random
+// bits, so a fast unsalted hash is enough.
+func hashResetToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// ForgotPassword sends the account registered to email a token that
+// ResetForgottenPassword takes for PasswordResetTTL. It returns nil whether
+// or not the account exists, and does the work for one that does in the
+// background, so neither the answer nor its timing gives the account away.
+// Banned and erased accounts get nothing.
+func (s *UserService) ForgotPassword(ctx context.Context, email string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.passwordResets == nil || s.resetNotifier == nil {
+		return ErrPasswordResetsNotConfigured
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByEmail(readCtx, normalize.Email(email))
+	cancel()
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load user: %w", err)
+	}
+	if user.IsBanned() || isErased(user) {
+		return nil
+	}
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+
+		token, secret, err := newResetToken(user.ID)
+		if err != nil {
+			logging.Printf(ctx, "Failed to generate reset token for user %d: %v", user.ID, err)
+			return
+		}
+		expiresAt := s.now().Add(PasswordResetTTL)
+
+		saveCtx, cancel := bestEffortContext(ctx, writeTimeout)
+		err = s.passwordResets.SavePasswordReset(saveCtx, user.ID, hashResetToken(secret), PasswordResetTTL)
+		cancel()
+		if err != nil {
+			logging.Printf(ctx, "Failed to store reset token for user %d: %v", user.ID, err)
+			return
+		}
+
+		sendCtx, cancel := bestEffortContext(ctx, mailSendTimeout)
+		defer cancel()
+		if err := s.resetNotifier.SendPasswordReset(sendCtx, user, token, expiresAt); err != nil {
+			logging.Printf(ctx, "Failed to send reset token to user %d: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// ResetForgottenPassword sets password on the account token was issued
+// for, using the token up, and logs the user out everywhere. The password
+// must pass the registration policy; a rejected one leaves the token
+// usable. Any problem with the token, or an account that can no longer log
+// in, is ErrInvalidResetToken.
+func (s *UserService) ResetForgottenPassword(ctx context.Context, token, password string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.passwordResets == nil {
+		return ErrPasswordResetsNotConfigured
+	}
+
+	id, secret, ok := parseResetToken(strings.TrimSpace(token))
+	if !ok {
+		return ErrInvalidResetToken
+	}
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load user: %w", err)
+	}
+	if user.IsBanned() || isErased(user) {
+		return ErrInvalidResetToken
+	}
+
+	password = normalize.Password(password)
+	if msg := checkPasswordPolicy(password, user.Username, user.Email); msg != "" {
+		return &ValidationError{Fields: map[string]string{"new_password": msg}}
+	}
+	if user.MustResetPassword {
+		credentials, err := s.credentials(ctx, id)
+		if err != nil {
+			return err
+		}
+		// The old password is presumed leaked
+		if passwordMatches(credentials, password) {
+			return &ValidationError{Fields: map[string]string{"new_password": "Choose a different password from the one you had"}}
+		}
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	consumeCtx, cancel := stepContext(ctx, writeTimeout)
+	used, err := s.passwordResets.ConsumePasswordReset(consumeCtx, id, hashResetToken(secret))
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to use reset token: %w", err)
+	}
+	if !used {
+		return ErrInvalidResetToken
+	}
+
+	// Proving the mailbox is as good as the old password, so a forced
+	// reset is done too
+	fields := map[string]interface{}{"password": string(hashedPassword)}
+	if user.MustResetPassword {
+		fields["must_reset_password"] = false
+	}
+	err = s.updateAudited(ctx, id, fields, &AuditEntry{
+		Action:    AuditPasswordChanged,
+		ActorID:   id,
+		TargetID:  id,
+		Reason:    "reset by email",
+		CreatedAt: s.now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	s.invalidateUser(ctx, user)
+
+	if s.sessions != nil {
+		s.afterCommit(ctx, "revoke sessions", func(ctx context.Context) error {
+			return s.sessions.RevokeUserSessions(ctx, id)
+		})
+	}
+
+	s.sendSecurityAlert(ctx, AlertPasswordChanged, user, user.Email, ClientInfoFrom(ctx), alertDetails{})
+	return nil
+}
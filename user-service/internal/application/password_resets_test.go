@@ -0,0 +1,119 @@
+// internal/application/password_resets_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+// fakeResetStore is an in-memory application.PasswordResetStore that
+// ignores TTLs
+type fakeResetStore struct {
+	mu     sync.Mutex
+	hashes map[uint]string
+}
+
+func (s *fakeResetStore) SavePasswordReset(ctx context.Context, userID uint, hash string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hashes[userID] = hash
+	return nil
+}
+
+func (s *fakeResetStore) ConsumePasswordReset(ctx context.Context, userID uint, hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hashes[userID] != hash {
+		return false, nil
+	}
+	delete(s.hashes, userID)
+	return true, nil
+}
+
+// fakeResetNotifier records the tokens it is handed by email
+type fakeResetNotifier struct {
+	mu     sync.Mutex
+	tokens map[string][]string
+}
+
+func (n *fakeResetNotifier) SendPasswordReset(ctx context.Context, user *domain.User, token string, expiresAt time.Time) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.tokens[user.Email] = append(n.tokens[user.Email], token)
+	return nil
+}
+
+func TestPasswordReset(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	banned := repo.AddUser("mallory@example.com", "secret123")
+	ctx := context.Background()
+	repo.UpdateFields(ctx, banned.ID, map[string]interface{}{"status": string(domain.StatusBanned)})
+	store := &fakeResetStore{hashes: make(map[uint]string)}
+	notifier := &fakeResetNotifier{tokens: make(map[string][]string)}
+	revoker := &fakeRevoker{}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithPasswordResets(store, notifier),
+		application.WithSessionRevoker(revoker),
+	)
+
+	// Unknown and banned accounts get the same answer and no email
+	for _, email := range []string{"nobody@example.com", "mallory@example.com", " Alice@Example.com"} {
+		if err := svc.ForgotPassword(ctx, email); err != nil {
+			t.Fatalf("forgot %q: %v", email, err)
+		}
+	}
+	svc.Wait()
+	if len(notifier.tokens) != 1 || len(notifier.tokens["alice@example.com"]) != 1 {
+		t.Fatalf("expected one token sent to alice, got %v", notifier.tokens)
+	}
+	first := notifier.tokens["alice@example.com"][0]
+	if strings.Contains(store.hashes[user.ID], strings.SplitN(first, ".", 2)[1]) {
+		t.Error("tokens must only be stored hashed")
+	}
+
+	// Asking again replaces the first token
+	if err := svc.ForgotPassword(ctx, "alice@example.com"); err != nil {
+		t.Fatalf("forgot: %v", err)
+	}
+	svc.Wait()
+	token := notifier.tokens["alice@example.com"][1]
+	if err := svc.ResetForgottenPassword(ctx, first, "N3w-secret!"); !errors.Is(err, application.ErrInvalidResetToken) {
+		t.Errorf("expected the replaced token refused, got %v", err)
+	}
+
+	// A password the policy rejects leaves the token usable
+	var verr *application.ValidationError
+	if err := svc.ResetForgottenPassword(ctx, token, "short"); !errors.As(err, &verr) || verr.Fields["new_password"] == "" {
+		t.Fatalf("expected the password policy enforced, got %v", err)
+	}
+	if err := svc.ResetForgottenPassword(ctx, token, "N3w-secret!"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	svc.Wait()
+	if _, err := svc.Login(ctx, "alice@example.com", "N3w-secret!"); err != nil {
+		t.Errorf("expected the new password to work, got %v", err)
+	}
+	if len(revoker.revoked) != 1 {
+		t.Errorf("expected sessions revoked, got %v", revoker.revoked)
+	}
+
+	for _, bad := range []string{token, "", "1.short", "abc", "999." + strings.SplitN(token, ".", 2)[1]} {
+		if err := svc.ResetForgottenPassword(ctx, bad, "An0ther-secret"); !errors.Is(err, application.ErrInvalidResetToken) {
+			t.Errorf("expected %q refused, got %v", bad, err)
+		}
+	}
+
+	unconfigured := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+	if err := unconfigured.ForgotPassword(ctx, "alice@example.com"); !errors.Is(err, application.ErrPasswordResetsNotConfigured) {
+		t.Errorf("expected ErrPasswordResetsNotConfigured, got %v", err)
+	}
+}
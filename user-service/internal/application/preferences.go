@@ -0,0 +1,48 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"user-service/internal/domain"
+)
+
+// UpdatePreferences merges patch into the user's display preferences and
+// returns the result. patch is a JSON merge patch checked against
+// domain.PreferenceSchema; a key or value it refuses comes back as a
+// *domain.PreferenceError and nothing is written. The audit entry records
+// the patch as sent.
+func (s *UserService) UpdatePreferences(ctx context.Context, id uint, patch map[string]interface{}) (domain.Preferences, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := user.Preferences.Merge(patch)
+	if err != nil {
+		return nil, err
+	}
+	if merged.Equal(user.Preferences) {
+		return merged, nil
+	}
+
+	err = s.updateAudited(ctx, id, map[string]interface{}{"preferences": merged}, &AuditEntry{
+		Action:    AuditPreferencesChanged,
+		ActorID:   id,
+		TargetID:  id,
+		Metadata:  patch,
+		CreatedAt: s.now().UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update preferences: %w", err)
+	}
+
+	s.invalidateUser(ctx, user)
+	return merged, nil
+}
@@ -0,0 +1,72 @@
+// internal/application/preferences_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+func TestUpdatePreferences_MergesAuditsAndInvalidates(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	_ = cache.Set(context.Background(), user)
+	audit := &fakeAuditLogger{}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache, application.WithAuditLogger(audit))
+	ctx := context.Background()
+
+	if _, err := svc.UpdatePreferences(ctx, user.ID, map[string]interface{}{
+		"theme": "dark", "accessibility": map[string]interface{}{"reduced_motion": true},
+	}); err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	prefs, err := svc.UpdatePreferences(ctx, user.ID, map[string]interface{}{
+		"locale": "de", "accessibility": map[string]interface{}{"high_contrast": true},
+	})
+	if err != nil {
+		t.Fatalf("second update: %v", err)
+	}
+	svc.Wait()
+
+	want := domain.Preferences{
+		"theme":         "dark",
+		"locale":        "de",
+		"accessibility": map[string]interface{}{"reduced_motion": true, "high_contrast": true},
+	}
+	if !reflect.DeepEqual(prefs, want) {
+		t.Errorf("returned %v, want %v", prefs, want)
+	}
+	if stored, _ := repo.User(user.ID); !reflect.DeepEqual(stored.Preferences, want) {
+		t.Errorf("stored %v, want %v", stored.Preferences, want)
+	}
+	if _, cached := cache.Cached(user.ID); cached {
+		t.Error("expected the cached user invalidated")
+	}
+	if len(audit.entries) != 2 || audit.entries[1].Action != application.AuditPreferencesChanged || audit.entries[1].Metadata["locale"] != "de" {
+		t.Errorf("expected each change audited with its patch, got %+v", audit.entries)
+	}
+
+	// Nothing changes, so nothing is written or audited
+	writes := repo.Calls("UpdateFields")
+	if _, err := svc.UpdatePreferences(ctx, user.ID, map[string]interface{}{"theme": "dark"}); err != nil {
+		t.Fatalf("no-op update: %v", err)
+	}
+	if len(audit.entries) != 2 || repo.Calls("UpdateFields") != writes {
+		t.Errorf("expected the no-op update to write nothing, got %d entries and %d writes", len(audit.entries), repo.Calls("UpdateFields"))
+	}
+
+	// A refused patch writes nothing either
+	var prefErr *domain.PreferenceError
+	if _, err := svc.UpdatePreferences(ctx, user.ID, map[string]interface{}{"theme": "sepia", "locale": "fr"}); !errors.As(err, &prefErr) {
+		t.Fatalf("expected a PreferenceError, got %v", err)
+	}
+	if stored, _ := repo.User(user.ID); stored.Preferences["locale"] != "de" {
+		t.Errorf("expected the refused patch not applied, got %v", stored.Preferences)
+	}
+}
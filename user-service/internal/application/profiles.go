@@ -0,0 +1,117 @@
+package application
+
+import (
+	"context"
+	"strings"
+
+	"user-service/internal/domain"
+)
+
+// Batch and search limits for internal profile lookups
+const (
+	MaxBatchGetUsers      = 100
+	DefaultSearchPageSize = 20
+	MaxSearchPageSize     = 100
+)
+
+// GetUsers returns the users with the given IDs, keyed by ID. Unknown IDs
+// are simply absent. Cached users come from one batched cache read; only
+// the misses go to the database, and those are cached on the way out.
+func (s *UserService) GetUsers(ctx context.Context, ids []uint) (map[uint]*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ids = uniqueIDs(ids)
+	if len(ids) > MaxBatchGetUsers {
+		return nil, &ValidationError{Fields: map[string]string{
+			"ids": "At most 100 users can be fetched at once",
+		}}
+	}
+
+	users := make(map[uint]*domain.User, len(ids))
+	if len(ids) == 0 {
+		return users, nil
+	}
+
+	if s.cache != nil {
+		cacheCtx, cancel := stepContext(ctx, cacheOpTimeout)
+		cached, err := s.cache.GetMany(cacheCtx, ids)
+		cancel()
+		// On error every ID is treated as a miss
+		if err == nil {
+			for id, user := range cached {
+				users[id] = user
+			}
+		}
+		s.observeCacheReads(len(users), len(ids)-len(users))
+	}
+
+	misses := make([]uint, 0, len(ids)-len(users))
+	for _, id := range ids {
+		if _, ok := users[id]; !ok {
+			misses = append(misses, id)
+		}
+	}
+	if len(misses) == 0 {
+		return users, nil
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	found, err := s.repo.GetByIDs(readCtx, misses)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	// Update cache even if the client disconnected after the read
+	var cacheCtx context.Context
+	if s.cache != nil && len(found) > 0 {
+		var cancel context.CancelFunc
+		cacheCtx, cancel = bestEffortContext(ctx, cacheOpTimeout)
+		defer cancel()
+	}
+	for _, user := range found {
+		users[user.ID] = user
+		if cacheCtx != nil {
+			_ = s.cache.Set(cacheCtx, user)
+		}
+	}
+
+	return users, nil
+}
+
+// SearchUsers returns up to limit users whose username starts with prefix,
+// case-insensitively, ordered by ID. Pass the last ID of the previous page
+// as afterID to continue; erased accounts never match.
+func (s *UserService) SearchUsers(ctx context.Context, prefix string, afterID uint, limit int) ([]*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return nil, &ValidationError{Fields: map[string]string{"query": "Query is required"}}
+	}
+	if limit <= 0 {
+		limit = DefaultSearchPageSize
+	}
+	if limit > MaxSearchPageSize {
+		limit = MaxSearchPageSize
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	defer cancel()
+	return s.repo.SearchByUsername(readCtx, prefix, afterID, limit, nil)
+}
+
+// uniqueIDs drops duplicates, keeping the first occurrence's position
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	out := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
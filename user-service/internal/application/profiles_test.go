@@ -0,0 +1,100 @@
+// internal/application/profiles_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+func TestGetUsers_ReadsMissesThroughToTheDatabase(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	cache := testsupport.NewUserCache()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	bob := repo.AddUser("bob@example.com", "secret123")
+	cache.Set(context.Background(), alice)
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache)
+
+	users, err := svc.GetUsers(context.Background(), []uint{alice.ID, bob.ID, 999, alice.ID})
+	if err != nil {
+		t.Fatalf("get users: %v", err)
+	}
+	if len(users) != 2 || users[alice.ID] == nil || users[bob.ID] == nil {
+		t.Fatalf("expected alice and bob, got %v", users)
+	}
+	if n := repo.Calls("GetByIDs"); n != 1 {
+		t.Errorf("expected one batched database read, got %d", n)
+	}
+	if _, ok := cache.Cached(bob.ID); !ok {
+		t.Error("expected the database hit to be cached")
+	}
+
+	// Everything is cached now, so the database isn't consulted again
+	if _, err := svc.GetUsers(context.Background(), []uint{alice.ID, bob.ID}); err != nil {
+		t.Fatalf("get users: %v", err)
+	}
+	if n := repo.Calls("GetByIDs"); n != 1 {
+		t.Errorf("expected the second batch to be served from cache, got %d reads", n)
+	}
+}
+
+func TestGetUsers_RejectsOversizedBatches(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	ids := make([]uint, application.MaxBatchGetUsers+1)
+	for i := range ids {
+		ids[i] = uint(i + 1)
+	}
+	var verr *application.ValidationError
+	if _, err := svc.GetUsers(context.Background(), ids); !errors.As(err, &verr) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+}
+
+func TestSearchUsers_PagesByPrefix(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	for i := 0; i < 5; i++ {
+		u := repo.AddUser(fmt.Sprintf("user%d@example.com", i), "secret123")
+		u.Username = fmt.Sprintf("Alice%d", i)
+		repo.Put(u)
+	}
+	other := repo.AddUser("bob@example.com", "secret123")
+	other.Username = "bob"
+	repo.Put(other)
+	erased := repo.AddUser("erased@example.com", "secret123")
+	erased.Username, erased.Status = "alice-erased", domain.StatusErased
+	repo.Put(erased)
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+	ctx := context.Background()
+
+	first, err := svc.SearchUsers(ctx, " alice", 0, 3)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(first) != 3 {
+		t.Fatalf("expected a full first page, got %d users", len(first))
+	}
+	rest, err := svc.SearchUsers(ctx, "alice", first[len(first)-1].ID, 3)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(rest) != 2 {
+		t.Fatalf("expected the remaining 2 users, got %d", len(rest))
+	}
+	for _, u := range append(first, rest...) {
+		if u.ID == other.ID || u.ID == erased.ID {
+			t.Errorf("unexpected match %q", u.Username)
+		}
+	}
+
+	var verr *application.ValidationError
+	if _, err := svc.SearchUsers(ctx, "  ", 0, 10); !errors.As(err, &verr) {
+		t.Errorf("expected an empty query to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/normalize"
+)
+
+// DefaultPurgeRetention is how long a soft-deleted account is kept before
+// it may be purged
+const DefaultPurgeRetention = 30 * 24 * time.Hour
+
+// PurgeTooEarlyError is a purge of an account that hasn't been deleted for
+// the retention period yet
+type PurgeTooEarlyError struct {
+	// PurgeAfter is the earliest the account can be purged
+	PurgeAfter time.Time
+}
+
+func (e *PurgeTooEarlyError) Error() string {
+	return fmt.Sprintf("user can't be purged before %s", e.PurgeAfter.Format(time.RFC3339))
+}
+
+// WithPurgeRetention sets how long an account must stay soft-deleted
+// before PurgeUser removes it
+func WithPurgeRetention(d time.Duration) Option {
+	return func(s *UserService) {
+		s.purgeRetention = d
+	}
+}
+
+// PurgeUser removes a soft-deleted account's row for good. confirmEmail
+// must be the account's email, or it fails with ErrPurgeNotConfirmed; an
+// account that isn't deleted is ErrUserNotDeleted, and one deleted less
+// than the retention period ago a *PurgeTooEarlyError. Its cache entries go
+// with it, and any session it still has is revoked.
+func (s *UserService) PurgeUser(ctx context.Context, id uint, confirmEmail, reason string, actorID uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var purged *domain.User
+	txCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	err := s.WithTransaction(txCtx, func(txCtx context.Context, tx *TxService) error {
+		user, err := tx.GetUserUnscoped(txCtx, id)
+		if err != nil {
+			return err
+		}
+		if normalize.Email(confirmEmail) != user.Email {
+			return ErrPurgeNotConfirmed
+		}
+		if !user.IsDeleted() {
+			return ErrUserNotDeleted
+		}
+		now := s.now().UTC()
+		if purgeAfter := user.DeletedAt.Time.Add(s.purgeRetention); now.Before(purgeAfter) {
+			return &PurgeTooEarlyError{PurgeAfter: purgeAfter.UTC()}
+		}
+
+		if err := tx.HardDelete(txCtx, id); err != nil {
+			return err
+		}
+		purged = user
+		return tx.Audit(txCtx, &AuditEntry{
+			Action:    AuditUserPurged,
+			ActorID:   actorID,
+			TargetID:  id,
+			Reason:    reason,
+			Metadata:  map[string]interface{}{"deleted_at": user.DeletedAt.Time.UTC()},
+			CreatedAt: now,
+		})
+	})
+	var tooEarly *PurgeTooEarlyError
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, ErrPurgeNotConfirmed),
+		errors.Is(err, ErrUserNotDeleted), errors.As(err, &tooEarly):
+		return err
+	case err != nil:
+		return fmt.Errorf("failed to purge user: %w", err)
+	}
+
+	s.invalidateUser(ctx, purged)
+	s.updateBlocklist(ctx, id)
+	if s.sessions != nil {
+		s.afterCommit(ctx, "revoke sessions", func(ctx context.Context) error {
+			return s.sessions.RevokeUserSessions(ctx, id)
+		})
+	}
+	s.publishAfterCommit(ctx, Event{
+		Type:       EventUserPurged,
+		UserID:     id,
+		OccurredAt: s.now().UTC(),
+	})
+
+	return nil
+}
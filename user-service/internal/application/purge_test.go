@@ -0,0 +1,78 @@
+// internal/application/purge_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+
+	"gorm.io/gorm"
+)
+
+func TestPurgeUser(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	live := repo.AddUser("bob@example.com", "secret123")
+	deletedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	gone := &domain.User{ID: 7, Username: "deleted-7", Email: "deleted-7@erased.invalid", Status: domain.StatusErased,
+		DeletedAt: gorm.DeletedAt{Time: deletedAt, Valid: true}}
+	repo.Put(gone)
+
+	now := deletedAt.Add(10 * 24 * time.Hour)
+	cache := testsupport.NewUserCache()
+	audit := &fakeAuditLogger{}
+	blocklist := newFakeBlocklist()
+	revoker := &fakeRevoker{}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache,
+		application.WithClock(func() time.Time { return now }),
+		application.WithPurgeRetention(14*24*time.Hour),
+		application.WithAuditLogger(audit),
+		application.WithUserBlocklist(blocklist),
+		application.WithSessionRevoker(revoker),
+	)
+	ctx := context.Background()
+
+	if err := svc.PurgeUser(ctx, live.ID, "bob@example.com", "cleanup", 99); !errors.Is(err, application.ErrUserNotDeleted) {
+		t.Errorf("expected a live account refused, got %v", err)
+	}
+	if err := svc.PurgeUser(ctx, gone.ID, "bob@example.com", "cleanup", 99); !errors.Is(err, application.ErrPurgeNotConfirmed) {
+		t.Errorf("expected another account's email refused, got %v", err)
+	}
+	if err := svc.PurgeUser(ctx, 999, "x@example.com", "cleanup", 99); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+
+	// Still inside the retention period
+	var tooEarly *application.PurgeTooEarlyError
+	if err := svc.PurgeUser(ctx, gone.ID, gone.Email, "cleanup", 99); !errors.As(err, &tooEarly) {
+		t.Fatalf("expected PurgeTooEarlyError, got %v", err)
+	}
+	if want := deletedAt.Add(14 * 24 * time.Hour); !tooEarly.PurgeAfter.Equal(want) {
+		t.Errorf("expected purge after %s, got %s", want, tooEarly.PurgeAfter)
+	}
+	if _, ok := repo.DeletedUser(gone.ID); !ok {
+		t.Fatal("expected the row kept")
+	}
+
+	now = deletedAt.Add(15 * 24 * time.Hour)
+	if err := svc.PurgeUser(ctx, gone.ID, " Deleted-7@Erased.invalid", "cleanup", 99); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	svc.Wait()
+	if _, err := repo.GetByIDUnscoped(ctx, gone.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected the row gone, got %v", err)
+	}
+	if emails := cache.DeletedEmails(); len(emails) != 1 || emails[0] != gone.Email {
+		t.Errorf("expected the cache entries dropped, got %v", emails)
+	}
+	if !blocklist.blocked[gone.ID] || len(revoker.revoked) != 1 || revoker.revoked[0] != gone.ID {
+		t.Errorf("expected the account's sessions ended, got blocked=%v revoked=%v", blocklist.blocked, revoker.revoked)
+	}
+	if len(audit.entries) != 1 || audit.entries[0].Action != application.AuditUserPurged || audit.entries[0].TargetID != gone.ID {
+		t.Errorf("unexpected audit entries: %+v", audit.entries)
+	}
+}
@@ -0,0 +1,67 @@
+package application
+
+import (
+	"context"
+	"time"
+)
+
+// RecentWriteWindow is how long after a mutation reads of the user skip the
+// replicas. It should cover the worst replication lag we expect.
+const RecentWriteWindow = 10 * time.Second
+
+// RecentWriteCacheTTL is how long a user read inside RecentWriteWindow stays
+// cached, so a stale row that slips in anyway doesn't live for the full TTL
+const RecentWriteCacheTTL = 5 * time.Second
+
+// WriteMarker remembers, across instances, which users were mutated within
+// RecentWriteWindow
+type WriteMarker interface {
+	MarkWritten(ctx context.Context, userID uint) error
+	WrittenRecently(ctx context.Context, userID uint) (bool, error)
+}
+
+// WithWriteMarker makes GetUser read recently mutated users from the
+// primary. Without it a read racing an invalidation on another instance can
+// re-cache a lagging replica's row for the full cache TTL.
+func WithWriteMarker(marker WriteMarker) Option {
+	return func(s *UserService) {
+		s.writeMarker = marker
+	}
+}
+
+type primaryReadKey struct{}
+
+// WithPrimaryRead asks the repository to serve reads made with ctx from the
+// primary rather than a replica
+func WithPrimaryRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadKey{}, true)
+}
+
+// PrimaryReadRequested reports whether WithPrimaryRead applies to ctx
+func PrimaryReadRequested(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryReadKey{}).(bool)
+	return primary
+}
+
+// markWritten records the mutation before the cache entries are dropped, so
+// a reader that misses the cache afterwards also sees the marker
+func (s *UserService) markWritten(ctx context.Context, id uint) {
+	if s.writeMarker == nil {
+		return
+	}
+	markCtx, cancel := bestEffortContext(ctx, cacheOpTimeout)
+	defer cancel()
+	_ = s.writeMarker.MarkWritten(markCtx, id)
+}
+
+// writtenRecently fails open: if the marker can't be read the replica is
+// used as it was before markers existed
+func (s *UserService) writtenRecently(ctx context.Context, id uint) bool {
+	if s.writeMarker == nil {
+		return false
+	}
+	markCtx, cancel := stepContext(ctx, cacheOpTimeout)
+	defer cancel()
+	recent, err := s.writeMarker.WrittenRecently(markCtx, id)
+	return err == nil && recent
+}
@@ -0,0 +1,128 @@
+// internal/application/recent_writes_test.go
+package application_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+// laggingReplica serves GetByID from a frozen copy of the rows until
+// CatchUp, except for reads pinned to the primary
+type laggingReplica struct {
+	*testsupport.UserRepository
+
+	mu           sync.Mutex
+	stale        map[uint]*domain.User
+	primaryReads int
+}
+
+func newLaggingReplica(primary *testsupport.UserRepository) *laggingReplica {
+	return &laggingReplica{UserRepository: primary, stale: make(map[uint]*domain.User)}
+}
+
+// Freeze snapshots the user as the replica will keep serving it
+func (r *laggingReplica) Freeze(id uint) {
+	user, _ := r.User(id)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stale[id] = user
+}
+
+func (r *laggingReplica) CatchUp() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stale = make(map[uint]*domain.User)
+}
+
+func (r *laggingReplica) GetByID(ctx context.Context, id uint) (*domain.User, error) {
+	r.mu.Lock()
+	if application.PrimaryReadRequested(ctx) {
+		r.primaryReads++
+	} else if user, ok := r.stale[id]; ok {
+		r.mu.Unlock()
+		cp := *user
+		return &cp, nil
+	}
+	r.mu.Unlock()
+	return r.UserRepository.GetByID(ctx, id)
+}
+
+func TestGetUser_ReadsOwnWritesPastLaggingReplica(t *testing.T) {
+	primary := testsupport.NewUserRepository()
+	replica := newLaggingReplica(primary)
+	cache := testsupport.NewUserCache()
+	svc := application.NewUserService(replica, testsupport.NewTxManager(primary), cache,
+		application.WithWriteMarker(cache),
+	)
+	user := primary.AddUser("alice@example.com", "secret123")
+	ctx := context.Background()
+
+	// The replica hasn't seen the rename when the next read arrives
+	replica.Freeze(user.ID)
+	user.FirstName = "Alicia"
+	if _, err := svc.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	got, err := svc.GetUser(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.FirstName != "Alicia" {
+		t.Fatalf("expected the write read back, got first name %q", got.FirstName)
+	}
+	if replica.primaryReads != 1 {
+		t.Errorf("expected one read pinned to the primary, got %d", replica.primaryReads)
+	}
+	if cached, ok := cache.Cached(user.ID); !ok || cached.FirstName != "Alicia" {
+		t.Errorf("expected the fresh row cached, got %+v", cached)
+	}
+	if ttl := cache.TTL(user.ID); ttl != application.RecentWriteCacheTTL {
+		t.Errorf("expected the short TTL, got %v", ttl)
+	}
+
+	// Once the window has passed, reads go back to the replica and the
+	// usual TTL
+	replica.CatchUp()
+	cache.ExpireWrites()
+	if err := cache.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := svc.GetUser(ctx, user.ID); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if replica.primaryReads != 1 {
+		t.Errorf("expected the replica used again, got %d primary reads", replica.primaryReads)
+	}
+	if ttl := cache.TTL(user.ID); ttl != 0 {
+		t.Errorf("expected the default TTL, got %v", ttl)
+	}
+}
+
+func TestGetUser_WithoutMarkerCachesStaleReplica(t *testing.T) {
+	primary := testsupport.NewUserRepository()
+	replica := newLaggingReplica(primary)
+	cache := testsupport.NewUserCache()
+	svc := application.NewUserService(replica, testsupport.NewTxManager(primary), cache)
+	user := primary.AddUser("alice@example.com", "secret123")
+	ctx := context.Background()
+
+	replica.Freeze(user.ID)
+	user.FirstName = "Alicia"
+	if _, err := svc.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	// The failure mode the marker exists for
+	if got, _ := svc.GetUser(ctx, user.ID); got.FirstName == "Alicia" {
+		t.Fatal("expected the lagging replica to be read")
+	}
+	if cached, _ := cache.Cached(user.ID); cached.FirstName == "Alicia" {
+		t.Error("expected the stale row cached")
+	}
+}
@@ -0,0 +1,302 @@
+package application
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/normalize"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ErrRecoveryCodesNotConfigured is returned by the recovery code methods
+// when the service was built without a RecoveryCodeRepository
+var ErrRecoveryCodesNotConfigured = errors.New("recovery codes not configured")
+
+// RecoveryCodeCount is how many codes each generation issues
+const RecoveryCodeCount = 10
+
+// RecoverySessionTTL is how long the session opened by a recovery code
+// lasts. It is only good for changing the account's email and password.
+const RecoverySessionTTL = 15 * time.Minute
+
+// RecoveryCodeRepository persists hashes of single-use recovery codes
+type RecoveryCodeRepository interface {
+	// Replace discards every code userID has, used or not, and stores
+	// hashes in their place
+	Replace(ctx context.Context, userID uint, hashes []string, at time.Time) error
+	// Consume marks userID's unused code with hash used at at, reporting
+	// false when there is none. The check and the update are one atomic
+	// step, so a code can't be used twice by concurrent requests.
+	Consume(ctx context.Context, userID uint, hash string, at time.Time) (bool, error)
+	// CountRemaining counts userID's unused codes
+	CountRemaining(ctx context.Context, userID uint) (int, error)
+	// DeleteByUser removes every code userID has, used or not
+	DeleteByUser(ctx context.Context, userID uint) error
+	WithTx(tx *gorm.DB) RecoveryCodeRepository
+}
+
+// WithRecoveryCodeRepository stores recovery codes, which account
+// recovery needs
+func WithRecoveryCodeRepository(repo RecoveryCodeRepository) Option {
+	return func(s *UserService) {
+		s.recoveryCodes = repo
+	}
+}
+
+// PasswordChange is a user changing their own password
+type PasswordChange struct {
+	Current string
+	New     string
+	// Recovered skips the Current check. Only set it for a session opened
+	// by Recover, where the user has proven ownership with a code instead.
+	Recovered bool
+	// Forced skips the Current check for an account that must reset its
+	// password, whose session Login opened after checking it. It is
+	// ignored once the account no longer needs a reset.
+	Forced bool
+}
+
+// normalizeRecoveryCode drops case, whitespace and dashes, since people
+// copy codes out of wherever they kept them
+func normalizeRecoveryCode(code string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '-', ' ', '\t', '\n', '\r':
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+}
+
+// hashRecoveryCode hashes a code for storage. Codes are random and
+// single-use, and guesses are rate limited, so a fast hash is enough; the
+// user ID keeps equal codes on different accounts from sharing a hash.
+func hashRecoveryCode(userID uint, code string) string {
+	sum := sha256.Sum256([]byte(strconv.FormatUint(uint64(userID), 10) + ":" + normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateRecoveryCodes issues RecoveryCodeCount fresh codes, like
+// "K7QMX-2PD9R", replacing every code the user had. password must be the
+// account's current one, so a stolen session can't mint its own way back
+// in. The codes are only returned here; just their hashes are kept.
+func (s *UserService) GenerateRecoveryCodes(ctx context.Context, id uint, password string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.recoveryCodes == nil {
+		return nil, ErrRecoveryCodesNotConfigured
+	}
+
+	credentials, err := s.credentials(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !passwordMatches(credentials, normalize.Password(password)) {
+		return nil, ErrInvalidCredentials
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		code, err := newCode(2, 5)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		codes[i], hashes[i] = code, hashRecoveryCode(id, code)
+	}
+
+	now := s.now().UTC()
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	err = s.WithTransaction(writeCtx, func(ctx context.Context, tx *TxService) error {
+		if err := tx.ReplaceRecoveryCodes(ctx, id, hashes, now); err != nil {
+			return err
+		}
+		return tx.Audit(ctx, &AuditEntry{
+			Action:    AuditRecoveryCodesGenerated,
+			ActorID:   id,
+			TargetID:  id,
+			Metadata:  map[string]interface{}{"count": RecoveryCodeCount},
+			CreatedAt: now,
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// Recover uses up one of the account's recovery codes and returns the
+// account, for which the caller opens a session limited to changing the
+// email and password. An unknown email, a wrong or used code and an
+// inactive account all fail with ErrInvalidRecoveryCode.
+func (s *UserService) Recover(ctx context.Context, email, code string) (*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.recoveryCodes == nil {
+		return nil, ErrRecoveryCodesNotConfigured
+	}
+	if normalizeRecoveryCode(code) == "" {
+		return nil, ErrInvalidRecoveryCode
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByEmail(readCtx, normalize.Email(email))
+	cancel()
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil, ErrInvalidRecoveryCode
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	// A banned account can't recover its way back in; say nothing about why
+	if user.IsBanned() || isErased(user) {
+		return nil, ErrInvalidRecoveryCode
+	}
+
+	now := s.now().UTC()
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	err = s.WithTransaction(writeCtx, func(ctx context.Context, tx *TxService) error {
+		used, err := tx.ConsumeRecoveryCode(ctx, user.ID, hashRecoveryCode(user.ID, code), now)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidRecoveryCode
+		}
+		return tx.Audit(ctx, &AuditEntry{
+			Action:    AuditRecoveryCodeUsed,
+			ActorID:   user.ID,
+			TargetID:  user.ID,
+			CreatedAt: now,
+		})
+	})
+	if errors.Is(err, ErrInvalidRecoveryCode) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return user, nil
+}
+
+// RecoveryCodesRemaining counts the user's unused recovery codes
+func (s *UserService) RecoveryCodesRemaining(ctx context.Context, id uint) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if s.recoveryCodes == nil {
+		return 0, ErrRecoveryCodesNotConfigured
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	defer cancel()
+	return s.recoveryCodes.CountRemaining(readCtx, id)
+}
+
+// ChangePassword sets a new password the user chose and logs them out
+// everywhere, this session included: refresh tokens are revoked and the
+// token version goes up. The new password must pass the registration
+// policy and differ from the current one. The user is sent a security
+// alert.
+func (s *UserService) ChangePassword(ctx context.Context, id uint, change PasswordChange) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if err != nil {
+		return err
+	}
+	credentials, err := s.credentials(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	forced := change.Forced && user.MustResetPassword
+	if !change.Recovered && !forced {
+		if !passwordMatches(credentials, normalize.Password(change.Current)) {
+			return ErrInvalidCredentials
+		}
+	}
+	password := normalize.Password(change.New)
+	if msg := checkPasswordPolicy(password, user.Username, user.Email); msg != "" {
+		return &ValidationError{Fields: map[string]string{"new_password": msg}}
+	}
+	if passwordMatches(credentials, password) {
+		msg := "New password must be different from the current one"
+		// The old password is presumed leaked
+		if user.MustResetPassword {
+			msg = "Choose a different password from the one you had"
+		}
+		return &ValidationError{Fields: map[string]string{"new_password": msg}}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	reason := "changed by user"
+	switch {
+	case change.Recovered:
+		reason = "changed with a recovery code"
+	case forced:
+		reason = "changed after a forced reset"
+	}
+	fields := map[string]interface{}{"password": string(hashedPassword)}
+	if user.MustResetPassword {
+		fields["must_reset_password"] = false
+	}
+	txCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	err = s.WithTransaction(txCtx, func(txCtx context.Context, tx *TxService) error {
+		current, err := nextTokenVersion(txCtx, tx, id)
+		if err != nil {
+			return err
+		}
+		user.TokenVersion = current.TokenVersion
+		fields["token_version"] = user.TokenVersion
+		// Only the changed columns, never a Save of the whole record
+		if err := tx.UpdateFields(txCtx, id, fields); err != nil {
+			return err
+		}
+		return tx.Audit(txCtx, &AuditEntry{
+			Action:    AuditPasswordChanged,
+			ActorID:   id,
+			TargetID:  id,
+			Reason:    reason,
+			CreatedAt: s.now().UTC(),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	s.cacheTokenVersion(ctx, user)
+	s.invalidateUser(ctx, user)
+
+	if s.sessions != nil {
+		s.afterCommit(ctx, "revoke sessions", func(ctx context.Context) error {
+			return s.sessions.RevokeUserSessions(ctx, id)
+		})
+	}
+
+	s.sendSecurityAlert(ctx, AlertPasswordChanged, user, user.Email, ClientInfoFrom(ctx), alertDetails{})
+
+	return nil
+}
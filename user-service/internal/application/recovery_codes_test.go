@@ -0,0 +1,153 @@
+// internal/application/recovery_codes_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+type recoveryFixture struct {
+	repo    *testsupport.UserRepository
+	codes   *testsupport.RecoveryCodeRepository
+	audit   *fakeAuditLogger
+	revoker *fakeRevoker
+	svc     *application.UserService
+	user    *domain.User
+}
+
+func newRecoveryFixture() *recoveryFixture {
+	f := &recoveryFixture{
+		repo:    testsupport.NewUserRepository(),
+		codes:   testsupport.NewRecoveryCodeRepository(),
+		audit:   &fakeAuditLogger{},
+		revoker: &fakeRevoker{},
+	}
+	f.user = f.repo.AddUser("alice@example.com", "secret123")
+	f.svc = application.NewUserService(f.repo, testsupport.NewTxManager(f.repo, f.codes), nil,
+		application.WithRecoveryCodeRepository(f.codes),
+		application.WithAuditLogger(f.audit),
+		application.WithSessionRevoker(f.revoker),
+	)
+	return f
+}
+
+func TestRecoveryCodes_SingleUse(t *testing.T) {
+	f := newRecoveryFixture()
+	ctx := context.Background()
+
+	if _, err := f.svc.GenerateRecoveryCodes(ctx, f.user.ID, "wrong"); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Fatalf("expected the password to be required, got %v", err)
+	}
+	codes, err := f.svc.GenerateRecoveryCodes(ctx, f.user.ID, "secret123")
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(codes) != application.RecoveryCodeCount || len(codes[0]) != len("XXXXX-XXXXX") {
+		t.Fatalf("unexpected codes %v", codes)
+	}
+	for _, hash := range f.codes.Hashes(f.user.ID) {
+		for _, code := range codes {
+			if strings.Contains(hash, code) {
+				t.Fatal("codes must only be stored hashed")
+			}
+		}
+	}
+
+	// Codes are typed by hand, so case and dashes don't matter
+	typed := strings.ToLower(strings.ReplaceAll(codes[0], "-", ""))
+	user, err := f.svc.Recover(ctx, " Alice@Example.com", typed)
+	if err != nil || user.ID != f.user.ID {
+		t.Fatalf("expected recovery to succeed, got %v", err)
+	}
+	if _, err := f.svc.Recover(ctx, "alice@example.com", codes[0]); !errors.Is(err, application.ErrInvalidRecoveryCode) {
+		t.Errorf("expected a used code to be refused, got %v", err)
+	}
+	if remaining, err := f.svc.RecoveryCodesRemaining(ctx, f.user.ID); err != nil || remaining != application.RecoveryCodeCount-1 {
+		t.Errorf("expected %d codes left, got %d (%v)", application.RecoveryCodeCount-1, remaining, err)
+	}
+
+	// Regenerating invalidates every earlier code
+	if _, err := f.svc.GenerateRecoveryCodes(ctx, f.user.ID, "secret123"); err != nil {
+		t.Fatalf("regenerate: %v", err)
+	}
+	if _, err := f.svc.Recover(ctx, "alice@example.com", codes[1]); !errors.Is(err, application.ErrInvalidRecoveryCode) {
+		t.Errorf("expected an old code to be refused, got %v", err)
+	}
+
+	want := []string{
+		application.AuditRecoveryCodesGenerated,
+		application.AuditRecoveryCodeUsed,
+		application.AuditRecoveryCodesGenerated,
+	}
+	if got := auditActions(f.audit); !equalStrings(got, want) {
+		t.Errorf("audited %v, want %v", got, want)
+	}
+}
+
+func TestRecover_RefusesWithoutSayingWhy(t *testing.T) {
+	f := newRecoveryFixture()
+	ctx := context.Background()
+	codes, err := f.svc.GenerateRecoveryCodes(ctx, f.user.ID, "secret123")
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	if _, err := f.svc.Recover(ctx, "nobody@example.com", codes[0]); !errors.Is(err, application.ErrInvalidRecoveryCode) {
+		t.Errorf("expected ErrInvalidRecoveryCode for an unknown email, got %v", err)
+	}
+
+	f.user.Status = domain.StatusBanned
+	f.repo.Put(f.user)
+	if _, err := f.svc.Recover(ctx, "alice@example.com", codes[0]); !errors.Is(err, application.ErrInvalidRecoveryCode) {
+		t.Errorf("expected ErrInvalidRecoveryCode for a banned account, got %v", err)
+	}
+	// Refusing the banned account didn't use the code up
+	if remaining, _ := f.svc.RecoveryCodesRemaining(ctx, f.user.ID); remaining != application.RecoveryCodeCount {
+		t.Errorf("expected every code left, got %d", remaining)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	f := newRecoveryFixture()
+	ctx := context.Background()
+
+	err := f.svc.ChangePassword(ctx, f.user.ID, application.PasswordChange{Current: "wrong", New: "N3w-password"})
+	if !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Fatalf("expected the current password to be checked, got %v", err)
+	}
+	err = f.svc.ChangePassword(ctx, f.user.ID, application.PasswordChange{Current: "secret123", New: "password"})
+	var verr *application.ValidationError
+	if !errors.As(err, &verr) || verr.Fields["new_password"] == "" {
+		t.Fatalf("expected a password policy error, got %v", err)
+	}
+	err = f.svc.ChangePassword(ctx, f.user.ID, application.PasswordChange{Current: "secret123", New: "secret123"})
+	if !errors.As(err, &verr) || verr.Fields["new_password"] == "" {
+		t.Fatalf("expected the current password refused as the new one, got %v", err)
+	}
+
+	// A recovery session proved ownership with a code instead
+	if err := f.svc.ChangePassword(ctx, f.user.ID, application.PasswordChange{New: "N3w-password", Recovered: true}); err != nil {
+		t.Fatalf("change: %v", err)
+	}
+	f.svc.Wait()
+
+	if _, err := f.svc.Login(ctx, "alice@example.com", "N3w-password"); err != nil {
+		t.Errorf("expected the new password to work, got %v", err)
+	}
+	if len(f.revoker.revoked) != 1 || f.revoker.revoked[0] != f.user.ID {
+		t.Errorf("expected sessions revoked for user %d, got %v", f.user.ID, f.revoker.revoked)
+	}
+	// Access tokens issued before the change carry the old version
+	if stored, _ := f.repo.User(f.user.ID); stored.TokenVersion != 1 {
+		t.Errorf("expected the token version raised to 1, got %d", stored.TokenVersion)
+	}
+	if len(f.audit.entries) != 1 || f.audit.entries[0].Action != application.AuditPasswordChanged {
+		t.Errorf("unexpected audit entries: %+v", f.audit.entries)
+	}
+}
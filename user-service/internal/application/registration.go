@@ -0,0 +1,208 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"user-service/internal/domain"
+	"user-service/internal/normalize"
+)
+
+// reservedUsernames cannot be registered by customers
+var reservedUsernames = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"root":          true,
+	"system":        true,
+	"support":       true,
+	"security":      true,
+	"api":           true,
+	"me":            true,
+	"null":          true,
+	"undefined":     true,
+}
+
+// DefaultRegisterReplayWindow is how long after signing up a conflicting
+// retry with the same username still gets the account back
+const DefaultRegisterReplayWindow = 30 * time.Second
+
+// WithRegisterReplayWindow sets how recent an account must be for Register
+// to answer a conflicting signup with it; zero always reports the conflict
+func WithRegisterReplayWindow(d time.Duration) Option {
+	return func(s *UserService) {
+		s.registerReplayWindow = d
+	}
+}
+
+// DefaultDeletedAccountHintWindow is how long after an account is deleted
+// a signup reusing its email is told so, matching the deletion grace period
+const DefaultDeletedAccountHintWindow = 30 * 24 * time.Hour
+
+// WithDeletedAccountHintWindow sets how recently an account must have been
+// deleted for a signup reusing its email to get
+// ErrEmailBelongsToDeletedAccount; zero always reports a plain conflict
+func WithDeletedAccountHintWindow(d time.Duration) Option {
+	return func(s *UserService) {
+		s.deletedAccountHintWindow = d
+	}
+}
+
+// ValidateRegistration runs every check Register performs without writing
+// anything, for inline signup form feedback.
+func (s *UserService) ValidateRegistration(ctx context.Context, user *domain.User, password string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	candidate := *user
+	return s.validateRegistration(ctx, &candidate, password, false)
+}
+
+// validateRegistration normalizes the user in place and runs the
+// reserved-username, password-policy and email-existence checks. Field
+// problems come back as a *ValidationError; lookup failures as plain errors.
+// allowReserved skips the reserved-username check for staff accounts.
+func (s *UserService) validateRegistration(ctx context.Context, user *domain.User, password string, allowReserved bool) error {
+	user.Email = normalize.Email(user.Email)
+	user.Username = normalize.Username(user.Username)
+	password = normalize.Password(password)
+
+	verr := &ValidationError{Fields: make(map[string]string)}
+
+	// Callers other than the HTTP handler (e.g. the admin CLI) may not have
+	// validated the request
+	if user.Username == "" {
+		verr.Fields["username"] = "Username is required"
+	}
+	if user.Email == "" {
+		verr.Fields["email"] = "Email is required"
+	}
+
+	if !allowReserved && reservedUsernames[strings.ToLower(user.Username)] {
+		verr.Fields["username"] = "Username is reserved"
+	}
+
+	if msg := checkPasswordPolicy(password, user.Username, user.Email); msg != "" {
+		verr.Fields["password"] = msg
+	}
+
+	// Unscoped, since a soft-deleted account keeps its email under the
+	// unique index and would otherwise only fail at insert
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	holder, err := s.repo.GetByEmailUnscoped(readCtx, user.Email)
+	cancel()
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+	case err != nil:
+		return fmt.Errorf("failed to check email: %w", err)
+	case s.recentlyDeleted(holder):
+		verr.Fields["email"] = "Email belongs to a recently deleted account"
+		verr.Err = ErrEmailBelongsToDeletedAccount
+	default:
+		verr.Fields["email"] = "Email already registered"
+		verr.Err = ErrEmailAlreadyRegistered
+	}
+	// Only worth a DNS lookup for an address that could otherwise sign up
+	if verr.Fields["email"] == "" && s.emailDomainRejected(ctx, user.Email) {
+		verr.Fields["email"] = "domain cannot receive mail"
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// recentlyDeleted reports whether a signup conflicting with holder may be
+// told the account was deleted: it was soft-deleted or is waiting out its
+// grace period, within the hint window, and its email was verified, so
+// the account really was the address owner's
+func (s *UserService) recentlyDeleted(holder *domain.User) bool {
+	if s.deletedAccountHintWindow <= 0 || !holder.IsEmailVerified() {
+		return false
+	}
+	var deletedAt time.Time
+	switch {
+	case holder.IsDeleted():
+		deletedAt = holder.DeletedAt.Time
+	case holder.IsPendingDeletion() && holder.DeletionRequestedAt != nil:
+		deletedAt = *holder.DeletionRequestedAt
+	default:
+		return false
+	}
+	return s.now().Sub(deletedAt) <= s.deletedAccountHintWindow
+}
+
+// isRegistrationConflict reports whether err is Register failing only
+// because the email is taken, either at validation or at insert
+func isRegistrationConflict(err error) bool {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		// A retry of a signup that went through passes every other check
+		return errors.Is(verr.Err, ErrEmailAlreadyRegistered) && len(verr.Fields) == 1
+	}
+	return errors.Is(err, domain.ErrDuplicateUser)
+}
+
+// recentRegistration returns the account a conflicting signup for user is
+// taken to be a retry of: the one holding its email, created under the same
+// username within the replay window and with password. Without the
+// password anyone could confirm a fresh signup and learn its ID. Otherwise,
+// or if a lookup fails, it returns nil and the conflict stands.
+func (s *UserService) recentRegistration(ctx context.Context, user *domain.User, password string) *domain.User {
+	if s.registerReplayWindow <= 0 {
+		return nil
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	existing, err := s.repo.GetByEmail(readCtx, user.Email)
+	cancel()
+	if err != nil || existing.Status != domain.StatusActive {
+		return nil
+	}
+	if !strings.EqualFold(existing.Username, user.Username) {
+		return nil
+	}
+	if s.now().Sub(existing.CreatedAt) > s.registerReplayWindow {
+		return nil
+	}
+	credentials, err := s.credentials(ctx, existing.ID)
+	if err != nil || !passwordMatches(credentials, normalize.Password(password)) {
+		return nil
+	}
+	return existing
+}
+
+// checkPasswordPolicy returns a user-facing message when the password is too
+// weak, or "" when it is acceptable
+func checkPasswordPolicy(password, username, email string) string {
+	if password == "" {
+		return "Password is required"
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return "Password must contain at least one letter and one digit"
+	}
+
+	lower := strings.ToLower(password)
+	if username != "" && lower == strings.ToLower(username) {
+		return "Password must not match the username"
+	}
+	if local, _, ok := strings.Cut(email, "@"); ok && lower == local {
+		return "Password must not match the email address"
+	}
+
+	return ""
+}
@@ -0,0 +1,286 @@
+// internal/application/registration_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+
+	"gorm.io/gorm"
+)
+
+func TestValidateRegistration(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	repo.AddUser("taken@example.com", "secret123")
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	tests := []struct {
+		name       string
+		user       domain.User
+		password   string
+		wantFields []string
+	}{
+		{"valid", domain.User{Username: "alice", Email: "alice@example.com"}, "secret123", nil},
+		{"reserved username", domain.User{Username: " Admin ", Email: "a@example.com"}, "secret123", []string{"username"}},
+		{"password without digit", domain.User{Username: "alice", Email: "a@example.com"}, "secretpw", []string{"password"}},
+		{"password matches email", domain.User{Username: "alice", Email: "bob12@example.com"}, "bob12", []string{"password"}},
+		{"email taken after normalization", domain.User{Username: "alice", Email: " TAKEN@example.com"}, "secret123", []string{"email"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := tt.user
+			err := svc.ValidateRegistration(context.Background(), &user, tt.password)
+
+			if tt.wantFields == nil {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			var verr *application.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+			for _, f := range tt.wantFields {
+				if verr.Fields[f] == "" {
+					t.Errorf("expected error on %q, got %v", f, verr.Fields)
+				}
+			}
+		})
+	}
+
+	// The dry run never writes
+	users, _, _ := repo.List(context.Background(), domain.UserFilter{}, 0, 10, domain.DefaultUserSort)
+	if len(users) != 1 {
+		t.Errorf("expected no new users, got %d", len(users))
+	}
+}
+
+func TestRegister_SharesValidation(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	repo.AddUser("taken@example.com", "secret123")
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	_, err := svc.Register(context.Background(), &domain.User{
+		Username: "alice", Email: "Taken@Example.com",
+	}, "secret123", "")
+	if !errors.Is(err, application.ErrEmailAlreadyRegistered) {
+		t.Fatalf("expected ErrEmailAlreadyRegistered, got %v", err)
+	}
+
+	user := &domain.User{Username: "root", Email: "new@example.com"}
+	var verr *application.ValidationError
+	if _, err := svc.Register(context.Background(), user, "secret123", ""); !errors.As(err, &verr) {
+		t.Fatalf("expected reserved username to be rejected, got %v", err)
+	}
+}
+
+func TestRegister_ReplaysARetriedSignup(t *testing.T) {
+	tests := []struct {
+		name         string
+		user         domain.User
+		password     string
+		age          time.Duration
+		window       time.Duration
+		wantReplayed bool
+	}{
+		{
+			name:         "retry inside the window",
+			user:         domain.User{Username: "alice", Email: "Alice@Example.com"},
+			password:     "secret123",
+			age:          10 * time.Second,
+			window:       application.DefaultRegisterReplayWindow,
+			wantReplayed: true,
+		},
+		{
+			name:     "someone else's password",
+			user:     domain.User{Username: "alice", Email: "alice@example.com"},
+			password: "guess1234",
+			age:      10 * time.Second,
+			window:   application.DefaultRegisterReplayWindow,
+		},
+		{
+			name:   "someone else's username",
+			user:   domain.User{Username: "mallory", Email: "alice@example.com"},
+			age:    10 * time.Second,
+			window: application.DefaultRegisterReplayWindow,
+		},
+		{
+			name:   "account older than the window",
+			user:   domain.User{Username: "alice", Email: "alice@example.com"},
+			age:    time.Minute,
+			window: application.DefaultRegisterReplayWindow,
+		},
+		{
+			name:   "replays disabled",
+			user:   domain.User{Username: "alice", Email: "alice@example.com"},
+			age:    time.Second,
+			window: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := testsupport.NewClock()
+			repo := testsupport.NewUserRepository()
+			existing := repo.AddUser("alice@example.com", "secret123")
+			existing.Username, existing.CreatedAt = "alice", clock.Now()
+			repo.Put(existing)
+			clock.Advance(tt.age)
+			svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+				application.WithClock(clock.Now),
+				application.WithRegisterReplayWindow(tt.window),
+			)
+
+			user, password := tt.user, tt.password
+			if password == "" {
+				password = "secret123"
+			}
+			replayed, err := svc.Register(context.Background(), &user, password, "")
+			if replayed != tt.wantReplayed {
+				t.Fatalf("replayed = %v, want %v (err %v)", replayed, tt.wantReplayed, err)
+			}
+			if !tt.wantReplayed {
+				if !errors.Is(err, application.ErrEmailAlreadyRegistered) {
+					t.Errorf("expected the conflict to stand, got %v", err)
+				}
+				return
+			}
+			if err != nil || user.ID != existing.ID {
+				t.Errorf("expected the existing account, got ID %d, err %v", user.ID, err)
+			}
+			// Only AddUser's
+			if got := repo.Calls("Create"); got != 1 {
+				t.Errorf("expected no insert, got %d", got)
+			}
+		})
+	}
+}
+
+func TestRegister_DoesNotReplayAnInvalidRetry(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	repo.Put(&domain.User{
+		ID: 7, Username: "alice", Email: "alice@example.com",
+		Status: domain.StatusActive, CreatedAt: time.Now(),
+	})
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	// The original signup couldn't have passed with this password
+	user := &domain.User{Username: "alice", Email: "alice@example.com"}
+	replayed, err := svc.Register(context.Background(), user, "aaaaaaaa", "")
+	var verr *application.ValidationError
+	if replayed || !errors.As(err, &verr) || verr.Fields["password"] == "" {
+		t.Fatalf("expected the validation errors, got replayed %v, err %v", replayed, err)
+	}
+}
+
+func TestRegister_EmailOfADeletedAccount(t *testing.T) {
+	tests := []struct {
+		name        string
+		deleted     bool
+		pending     bool
+		verified    bool
+		age         time.Duration
+		window      time.Duration
+		wantDeleted bool
+	}{
+		{name: "recently soft deleted", deleted: true, verified: true, age: time.Hour, window: application.DefaultDeletedAccountHintWindow, wantDeleted: true},
+		{name: "waiting out the grace period", pending: true, verified: true, age: time.Hour, window: application.DefaultDeletedAccountHintWindow, wantDeleted: true},
+		{name: "unverified email", deleted: true, age: time.Hour, window: application.DefaultDeletedAccountHintWindow},
+		{name: "deleted long ago", deleted: true, verified: true, age: 31 * 24 * time.Hour, window: application.DefaultDeletedAccountHintWindow},
+		{name: "hint disabled", deleted: true, verified: true, age: time.Hour},
+		{name: "live account", verified: true, age: time.Hour, window: application.DefaultDeletedAccountHintWindow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := testsupport.NewClock()
+			now := clock.Now()
+			holder := &domain.User{ID: 7, Username: "alice", Email: "alice@example.com", Status: domain.StatusActive}
+			if tt.verified {
+				holder.EmailVerifiedAt = &now
+			}
+			if tt.deleted {
+				holder.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+			}
+			if tt.pending {
+				holder.Status = domain.StatusPendingDeletion
+				holder.DeletionRequestedAt = &now
+			}
+			repo := testsupport.NewUserRepository()
+			repo.Put(holder)
+			clock.Advance(tt.age)
+			svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+				application.WithClock(clock.Now),
+				application.WithDeletedAccountHintWindow(tt.window),
+			)
+
+			_, err := svc.Register(context.Background(), &domain.User{
+				Username: "alice2", Email: "alice@example.com",
+			}, "secret123", "")
+			if !errors.Is(err, application.ErrEmailAlreadyRegistered) {
+				t.Fatalf("expected a conflict, got %v", err)
+			}
+			if got := errors.Is(err, application.ErrEmailBelongsToDeletedAccount); got != tt.wantDeleted {
+				t.Errorf("deleted account reported = %v, want %v (%v)", got, tt.wantDeleted, err)
+			}
+			if got := repo.Calls("Create"); got != 0 {
+				t.Errorf("expected no insert, got %d", got)
+			}
+		})
+	}
+}
+
+// fakeMailDomains answers CanReceiveMail from a fixed table
+type fakeMailDomains struct {
+	answers map[string]bool
+	err     error
+	checked []string
+}
+
+func (f *fakeMailDomains) CanReceiveMail(ctx context.Context, domain string) (bool, error) {
+	f.checked = append(f.checked, domain)
+	if f.err != nil {
+		return false, f.err
+	}
+	return f.answers[domain], nil
+}
+
+func TestRegister_MailDomainCheck(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	repo.AddUser("taken@nomail.test", "secret123")
+	domains := &fakeMailDomains{answers: map[string]bool{"example.com": true}}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithMailDomainChecker(domains),
+	)
+	ctx := context.Background()
+
+	_, err := svc.Register(ctx, &domain.User{Username: "bob", Email: "bob@NoMail.test"}, "secret123", "")
+	var verr *application.ValidationError
+	if !errors.As(err, &verr) || verr.Fields["email"] != "domain cannot receive mail" {
+		t.Fatalf("expected the domain rejected, got %v", err)
+	}
+	if _, err := svc.Register(ctx, &domain.User{Username: "carol", Email: "carol@example.com"}, "secret123", ""); err != nil {
+		t.Fatalf("expected a deliverable domain accepted, got %v", err)
+	}
+
+	// A registered email is a conflict without a lookup
+	domains.checked = nil
+	if _, err := svc.Register(ctx, &domain.User{Username: "dave", Email: "taken@nomail.test"}, "secret123", ""); !errors.Is(err, application.ErrEmailAlreadyRegistered) {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	if len(domains.checked) != 0 {
+		t.Errorf("expected no lookup, got %v", domains.checked)
+	}
+
+	// No definitive answer lets the signup through
+	domains.err = context.DeadlineExceeded
+	if _, err := svc.Register(ctx, &domain.User{Username: "erin", Email: "erin@nomail.test"}, "secret123", ""); err != nil {
+		t.Fatalf("expected a lookup failure to pass, got %v", err)
+	}
+}
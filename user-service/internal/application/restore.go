@@ -0,0 +1,85 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"user-service/internal/domain"
+)
+
+// GetUserUnscoped reads the user from the database whether or not they
+// are soft-deleted, for support to look at before restoring them. The
+// cache is skipped, since it never holds deleted accounts.
+func (s *UserService) GetUserUnscoped(ctx context.Context, id uint) (*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	defer cancel()
+	return s.repo.GetByIDUnscoped(readCtx, id)
+}
+
+// RestoreUser brings back a soft-deleted account as it was when deleted,
+// ErrUserNotDeleted if it isn't. Accounts that went through erasure were
+// soft-deleted with their personal data already gone, and are refused with
+// ErrUserErased. Every cached read of the user and their email is dropped,
+// so lookups that found nothing while the account was deleted find it now.
+func (s *UserService) RestoreUser(ctx context.Context, id uint, reason string, actorID uint) (*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var restored *domain.User
+	txCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	err := s.WithTransaction(txCtx, func(txCtx context.Context, tx *TxService) error {
+		user, err := tx.GetUserUnscoped(txCtx, id)
+		if err != nil {
+			return err
+		}
+		if !user.IsDeleted() {
+			return ErrUserNotDeleted
+		}
+		if user.Status == domain.StatusErased {
+			return ErrUserErased
+		}
+
+		// A concurrent restore got there first
+		if err := tx.Restore(txCtx, id); errors.Is(err, domain.ErrUserNotFound) {
+			return ErrUserNotDeleted
+		} else if err != nil {
+			return err
+		}
+		if restored, err = tx.GetUser(txCtx, id); err != nil {
+			return err
+		}
+
+		return tx.Audit(txCtx, &AuditEntry{
+			Action:    AuditUserRestored,
+			ActorID:   actorID,
+			TargetID:  id,
+			Reason:    reason,
+			Metadata:  map[string]interface{}{"deleted_at": user.DeletedAt.Time.UTC()},
+			CreatedAt: s.now().UTC(),
+		})
+	})
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound), errors.Is(err, ErrUserNotDeleted), errors.Is(err, ErrUserErased):
+		return nil, err
+	case err != nil:
+		return nil, fmt.Errorf("failed to restore user: %w", err)
+	}
+
+	s.invalidateUser(ctx, restored)
+	s.updateBlocklist(ctx, id)
+	s.publishAfterCommit(ctx, Event{
+		Type:       EventUserRestored,
+		UserID:     id,
+		OccurredAt: s.now().UTC(),
+		Data:       map[string]interface{}{"email": restored.Email},
+	})
+
+	return restored, nil
+}
@@ -0,0 +1,86 @@
+// internal/application/restore_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+func TestRestoreUser(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	audit := &fakeAuditLogger{}
+	blocklist := newFakeBlocklist()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache,
+		application.WithAuditLogger(audit),
+		application.WithUserBlocklist(blocklist),
+	)
+	ctx := context.Background()
+
+	if _, err := svc.RestoreUser(ctx, alice.ID, "mistake", 99); !errors.Is(err, application.ErrUserNotDeleted) {
+		t.Errorf("expected ErrUserNotDeleted for a live account, got %v", err)
+	}
+	if _, err := svc.RestoreUser(ctx, 999, "mistake", 99); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+
+	repo.SoftDelete(ctx, alice.ID)
+	blocklist.Block(ctx, alice.ID)
+	if _, err := svc.Login(ctx, "alice@example.com", "secret123"); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Fatalf("expected a deleted account unable to log in, got %v", err)
+	}
+	if _, err := svc.GetUser(ctx, alice.ID); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("expected the deleted account hidden, got %v", err)
+	}
+	shown, err := svc.GetUserUnscoped(ctx, alice.ID)
+	if err != nil || !shown.IsDeleted() {
+		t.Fatalf("expected the deleted account shown unscoped, got %+v (%v)", shown, err)
+	}
+
+	restored, err := svc.RestoreUser(ctx, alice.ID, "mistake", 99)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	svc.Wait()
+	if restored.IsDeleted() || restored.Email != "alice@example.com" {
+		t.Errorf("expected the account back as it was, got %+v", restored)
+	}
+	if _, err := svc.Login(ctx, "alice@example.com", "secret123"); err != nil {
+		t.Errorf("expected login to work after restore, got %v", err)
+	}
+	if blocklist.blocked[alice.ID] {
+		t.Error("expected the blocklist entry cleared")
+	}
+	if emails := cache.DeletedEmails(); len(emails) != 1 || emails[0] != "alice@example.com" {
+		t.Errorf("expected the email lookup evicted, got %v", emails)
+	}
+	if len(audit.entries) != 1 || audit.entries[0].Action != application.AuditUserRestored ||
+		audit.entries[0].ActorID != 99 || audit.entries[0].Reason != "mistake" {
+		t.Errorf("unexpected audit entries: %+v", audit.entries)
+	}
+	if _, err := svc.RestoreUser(ctx, alice.ID, "again", 99); !errors.Is(err, application.ErrUserNotDeleted) {
+		t.Errorf("expected a second restore refused, got %v", err)
+	}
+}
+
+func TestRestoreUser_RefusesErased(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	ctx := context.Background()
+	repo.UpdateFields(ctx, user.ID, map[string]interface{}{"status": string(domain.StatusErased)})
+	repo.SoftDelete(ctx, user.ID)
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	if _, err := svc.RestoreUser(ctx, user.ID, "mistake", 99); !errors.Is(err, application.ErrUserErased) {
+		t.Fatalf("expected ErrUserErased, got %v", err)
+	}
+	if deleted, ok := repo.DeletedUser(user.ID); !ok || !deleted.IsDeleted() {
+		t.Error("expected the erased row left deleted")
+	}
+}
@@ -0,0 +1,237 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"text/template"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/logging"
+)
+
+// SecurityAlert is a kind of email sent when something sensitive happens
+// to an account
+type SecurityAlert string
+
+const (
+	AlertNewDevice       SecurityAlert = "new_device"
+	AlertPasswordChanged SecurityAlert = "password_changed"
+	AlertEmailChanged    SecurityAlert = "email_changed"
+	// AlertPasswordResetRequired tells the user support has signed them
+	// out and they must choose a new password
+	AlertPasswordResetRequired SecurityAlert = "password_reset_required"
+)
+
+// mailSendTimeout bounds one background delivery
+const mailSendTimeout = 10 * time.Second
+
+// ClientInfo describes where a request came from. The HTTP layer stores it
+// in the context; alerts quote it and new-device detection fingerprints it.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+type clientInfoKey struct{}
+
+// WithClientInfo returns a context carrying info
+func WithClientInfo(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// ClientInfoFrom returns the ClientInfo stored by WithClientInfo, or the
+// zero value
+func ClientInfoFrom(ctx context.Context) ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info
+}
+
+// DeviceStore remembers which devices each user has logged in from
+type DeviceStore interface {
+	// RememberDevice records the fingerprint for the user and reports
+	// whether it was already known
+	RememberDevice(ctx context.Context, userID uint, fingerprint string) (known bool, err error)
+	// ForgetDevices drops every device remembered for the user
+	ForgetDevices(ctx context.Context, userID uint) error
+}
+
+// WithDeviceStore enables new-device alerts on login
+func WithDeviceStore(store DeviceStore) Option {
+	return func(s *UserService) {
+		s.devices = store
+	}
+}
+
+// WithSecurityAlertsDisabled turns off the given alerts. Devices are still
+// remembered while new-device alerts are off, so turning them back on
+// doesn't flag every known device.
+func WithSecurityAlertsDisabled(alerts ...SecurityAlert) Option {
+	return func(s *UserService) {
+		for _, alert := range alerts {
+			s.disabledAlerts[alert] = true
+		}
+	}
+}
+
+// deviceFingerprint identifies a device by its user agent and coarse
+// network (/24 for IPv4, /48 for IPv6), so a DHCP lease change on the
+// same network isn't a new device
+func deviceFingerprint(info ClientInfo) string {
+	network := info.IP
+	if ip := net.ParseIP(info.IP); ip != nil {
+		if v4 := ip.To4(); v4 != nil {
+			network = v4.Mask(net.CIDRMask(24, 32)).String()
+		} else {
+			network = ip.Mask(net.CIDRMask(48, 128)).String()
+		}
+	}
+	sum := sha256.Sum256([]byte(info.UserAgent + "\x00" + network))
+	return hex.EncodeToString(sum[:16])
+}
+
+// alertTemplate is the email sent for one SecurityAlert
+type alertTemplate struct {
+	category domain.NotificationCategory
+	subject  string
+	body     *template.Template
+}
+
+// alertDetails is what the alert templates can show
+type alertDetails struct {
+	Username string
+	Time     string
+	IP       string
+	Device   string
+	NewEmail string
+}
+
+var alertTemplates = map[SecurityAlert]alertTemplate{
+	AlertNewDevice: {
+		// Users may mute this one; the others are always sent
+		category: domain.NotificationSecurityAlert,
+		subject:  "New sign-in to your account",
+		body: template.Must(template.New("new_device").Parse(`Hi {{.Username}},
+
+Your account was just signed in to from a device we haven't seen before.
+
+Time: {{.Time}}
+IP address: {{.IP}}
+Device: {{.Device}}
+
+If this was you, there's nothing to do. If not, change your password now.
+`)),
+	},
+	AlertPasswordChanged: {
+		category: domain.NotificationSecurityCritical,
+		subject:  "Your password was changed",
+		body: template.Must(template.New("password_changed").Parse(`Hi {{.Username}},
+
+The password for your account was changed.
+
+Time: {{.Time}}
+{{- with .IP}}
+IP address: {{.}}{{end}}
+{{- with .Device}}
+Device: {{.}}{{end}}
+
+You have been signed out everywhere. If you didn't ask for this, contact support.
+`)),
+	},
+	AlertEmailChanged: {
+		category: domain.NotificationSecurityCritical,
+		subject:  "Your email address was changed",
+		body: template.Must(template.New("email_changed").Parse(`Hi {{.Username}},
+
+The email address on your account was changed to {{.NewEmail}}. This is the last email we will send to this address.
+
+Time: {{.Time}}
+{{- with .IP}}
+IP address: {{.}}{{end}}
+{{- with .Device}}
+Device: {{.}}{{end}}
+
+If you didn't make this change, contact support.
+`)),
+	},
+	AlertPasswordResetRequired: {
+		category: domain.NotificationSecurityCritical,
+		subject:  "Please choose a new password",
+		body: template.Must(template.New("password_reset_required").Parse(`Hi {{.Username}},
+
+To protect your account, we have signed you out everywhere. Your password may have been exposed outside our service.
+
+The next time you log in with your current password, you will be asked to choose a new one. Please don't reuse a password from another site.
+
+Time: {{.Time}}
+`)),
+	},
+}
+
+// sendSecurityAlert renders the alert for user and mails it to the given
+// address in the background, after the change it reports has committed.
+// It is a no-op without a Mailer or when the alert is disabled.
+func (s *UserService) sendSecurityAlert(ctx context.Context, alert SecurityAlert, user *domain.User, to string, client ClientInfo, details alertDetails) {
+	if s.mailer == nil || s.disabledAlerts[alert] {
+		return
+	}
+
+	tmpl := alertTemplates[alert]
+	details.Username = user.Username
+	details.Time = s.now().UTC().Format(time.RFC1123)
+	details.IP = client.IP
+	details.Device = client.UserAgent
+	if alert == AlertNewDevice && details.Device == "" {
+		details.Device = "Unknown device"
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.body.Execute(&body, details); err != nil {
+		logging.Printf(ctx, "Failed to render %s alert for user %d: %v", alert, user.ID, err)
+		return
+	}
+	msg := &Message{To: to, Category: tmpl.category, Subject: tmpl.subject, Body: body.String()}
+	prefs := user.Notifications
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+
+		sendCtx, cancel := bestEffortContext(ctx, mailSendTimeout)
+		defer cancel()
+		if _, err := s.deliver(sendCtx, prefs, msg); err != nil {
+			logging.Printf(ctx, "Failed to send %s alert to user %d: %v", alert, user.ID, err)
+		}
+	}()
+}
+
+// checkNewDevice remembers the device the user just logged in from and
+// alerts them if it is one the store hadn't seen. A user's first login
+// only seeds the store.
+func (s *UserService) checkNewDevice(ctx context.Context, user *domain.User, firstLogin bool) {
+	client := ClientInfoFrom(ctx)
+	if s.devices == nil || (client.IP == "" && client.UserAgent == "") {
+		return
+	}
+	fingerprint := deviceFingerprint(client)
+	alerted := *user
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+
+		storeCtx, cancel := bestEffortContext(ctx, pointReadTimeout)
+		known, err := s.devices.RememberDevice(storeCtx, alerted.ID, fingerprint)
+		cancel()
+		if err != nil {
+			logging.Printf(ctx, "Failed to check device for user %d: %v", alerted.ID, err)
+			return
+		}
+		if !known && !firstLogin {
+			s.sendSecurityAlert(ctx, AlertNewDevice, &alerted, alerted.Email, client, alertDetails{})
+		}
+	}()
+}
@@ -0,0 +1,210 @@
+// internal/application/security_alerts_test.go
+package application_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+// alertFixture is a user who has logged in before, on a service that
+// mails security alerts
+type alertFixture struct {
+	repo    *testsupport.UserRepository
+	user    *domain.User
+	mailer  *testsupport.Mailer
+	devices *testsupport.DeviceStore
+	clock   *testsupport.Clock
+	svc     *application.UserService
+}
+
+func newAlertFixture(t *testing.T, opts ...application.Option) *alertFixture {
+	t.Helper()
+	f := &alertFixture{
+		repo:    testsupport.NewUserRepository(),
+		mailer:  testsupport.NewMailer(),
+		devices: testsupport.NewDeviceStore(),
+		clock:   testsupport.NewClock(),
+	}
+	f.user = f.repo.AddUser("alice@example.com", "secret123")
+	lastLogin := f.clock.Now().Add(-24 * time.Hour)
+	f.user.LastLogin = &lastLogin
+	f.repo.Put(f.user)
+
+	opts = append([]application.Option{
+		application.WithMailer(f.mailer),
+		application.WithDeviceStore(f.devices),
+		application.WithClock(f.clock.Now),
+	}, opts...)
+	f.svc = application.NewUserService(f.repo, testsupport.NewTxManager(f.repo), nil, opts...)
+	return f
+}
+
+// loginFrom logs alice in from client and waits for the alerts it sends
+func (f *alertFixture) loginFrom(t *testing.T, client application.ClientInfo) {
+	t.Helper()
+	ctx := application.WithClientInfo(context.Background(), client)
+	if _, err := f.svc.Login(ctx, "alice@example.com", "secret123"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	f.svc.Wait()
+}
+
+const (
+	firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0"
+	iphone  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) Mobile/15E148"
+)
+
+func TestLogin_AlertsOnFirstSeenDevice(t *testing.T) {
+	f := newAlertFixture(t)
+
+	f.loginFrom(t, application.ClientInfo{IP: "203.0.113.7", UserAgent: firefox})
+	sent := f.mailer.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("expected an alert for the first-seen device, got %d", len(sent))
+	}
+	alert := sent[0]
+	if alert.To != "alice@example.com" || alert.Category != domain.NotificationSecurityAlert {
+		t.Errorf("unexpected alert %+v", alert)
+	}
+	for _, detail := range []string{"203.0.113.7", firefox, f.clock.Now().UTC().Format(time.RFC1123)} {
+		if !strings.Contains(alert.Body, detail) {
+			t.Errorf("expected the alert to mention %q:\n%s", detail, alert.Body)
+		}
+	}
+
+	// The same device again, then from a new address on the same network
+	f.loginFrom(t, application.ClientInfo{IP: "203.0.113.7", UserAgent: firefox})
+	f.loginFrom(t, application.ClientInfo{IP: "203.0.113.99", UserAgent: firefox})
+	if got := len(f.mailer.Sent()); got != 1 {
+		t.Fatalf("expected no alert for a repeat login, got %d alerts", got)
+	}
+
+	f.loginFrom(t, application.ClientInfo{IP: "203.0.113.7", UserAgent: iphone})
+	f.loginFrom(t, application.ClientInfo{IP: "198.51.100.7", UserAgent: firefox})
+	if got := len(f.mailer.Sent()); got != 3 {
+		t.Errorf("expected an alert for each new device, got %d alerts", got)
+	}
+}
+
+func TestLogin_NewDeviceAlertOnlyWhenWanted(t *testing.T) {
+	laptop := application.ClientInfo{IP: "203.0.113.7", UserAgent: firefox}
+
+	t.Run("first login seeds the store", func(t *testing.T) {
+		f := newAlertFixture(t)
+		f.user.LastLogin = nil
+		f.repo.Put(f.user)
+
+		f.loginFrom(t, laptop)
+		if len(f.mailer.Sent()) != 0 || f.devices.Devices(f.user.ID) != 1 {
+			t.Errorf("expected a silently remembered device, got %d alerts", len(f.mailer.Sent()))
+		}
+	})
+
+	t.Run("muted by the user", func(t *testing.T) {
+		f := newAlertFixture(t)
+		off := false
+		if _, err := f.svc.UpdateNotificationPreferences(context.Background(), f.user.ID, application.NotificationPreferencesUpdate{SecurityAlerts: &off}); err != nil {
+			t.Fatalf("mute: %v", err)
+		}
+
+		f.loginFrom(t, laptop)
+		if len(f.mailer.Sent()) != 0 {
+			t.Errorf("expected muted alerts to stay quiet, got %+v", f.mailer.Sent())
+		}
+	})
+
+	t.Run("switched off", func(t *testing.T) {
+		f := newAlertFixture(t, application.WithSecurityAlertsDisabled(application.AlertNewDevice))
+
+		f.loginFrom(t, laptop)
+		if len(f.mailer.Sent()) != 0 || f.devices.Devices(f.user.ID) != 1 {
+			t.Errorf("expected the device remembered without an alert, got %d alerts", len(f.mailer.Sent()))
+		}
+	})
+
+	t.Run("no client details", func(t *testing.T) {
+		f := newAlertFixture(t)
+		if _, err := f.svc.Login(context.Background(), "alice@example.com", "secret123"); err != nil {
+			t.Fatalf("login: %v", err)
+		}
+		f.svc.Wait()
+		if len(f.mailer.Sent()) != 0 || f.devices.Devices(f.user.ID) != 0 {
+			t.Error("expected nothing to fingerprint without client details")
+		}
+	})
+}
+
+func TestAccountChanges_SendSecurityAlerts(t *testing.T) {
+	client := application.ClientInfo{IP: "203.0.113.7", UserAgent: firefox}
+
+	t.Run("password changed", func(t *testing.T) {
+		f := newAlertFixture(t)
+		// Alerts that can't be muted ignore the preference
+		off := false
+		f.svc.UpdateNotificationPreferences(context.Background(), f.user.ID, application.NotificationPreferencesUpdate{SecurityAlerts: &off})
+
+		if err := f.svc.ResetPassword(context.Background(), f.user.ID, "N3w-password", "locked out", 0); err != nil {
+			t.Fatalf("reset: %v", err)
+		}
+		f.svc.Wait()
+
+		sent := f.mailer.Sent()
+		if len(sent) != 1 || sent[0].To != "alice@example.com" || sent[0].Category != domain.NotificationSecurityCritical {
+			t.Fatalf("expected one critical alert to alice, got %+v", sent)
+		}
+		if !strings.Contains(sent[0].Subject, "password") {
+			t.Errorf("unexpected subject %q", sent[0].Subject)
+		}
+	})
+
+	t.Run("email changed", func(t *testing.T) {
+		f := newAlertFixture(t)
+		user, _ := f.repo.User(f.user.ID)
+		user.Email = "alice@new.example.com"
+
+		ctx := application.WithClientInfo(context.Background(), client)
+		if _, err := f.svc.UpdateUser(ctx, user); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		f.svc.Wait()
+
+		sent := f.mailer.Sent()
+		if len(sent) != 1 || sent[0].To != "alice@example.com" {
+			t.Fatalf("expected one alert to the old address, got %+v", sent)
+		}
+		for _, detail := range []string{"alice@new.example.com", "203.0.113.7", firefox} {
+			if !strings.Contains(sent[0].Body, detail) {
+				t.Errorf("expected the alert to mention %q:\n%s", detail, sent[0].Body)
+			}
+		}
+
+		// A profile edit that keeps the email sends nothing
+		user.FirstName = "Alice"
+		if _, err := f.svc.UpdateUser(ctx, user); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		f.svc.Wait()
+		if got := len(f.mailer.Sent()); got != 1 {
+			t.Errorf("expected no alert for other changes, got %d", got)
+		}
+	})
+
+	t.Run("switched off", func(t *testing.T) {
+		f := newAlertFixture(t, application.WithSecurityAlertsDisabled(application.AlertPasswordChanged, application.AlertEmailChanged))
+		f.svc.ResetPassword(context.Background(), f.user.ID, "N3w-password", "locked out", 0)
+		user, _ := f.repo.User(f.user.ID)
+		user.Email = "alice@new.example.com"
+		f.svc.UpdateUser(context.Background(), user)
+		f.svc.Wait()
+
+		if got := len(f.mailer.Sent()); got != 0 {
+			t.Errorf("expected disabled alerts to stay quiet, got %d", got)
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/logging"
+)
+
+// ErrSessionsNotConfigured is returned by the session methods when the
+// service was built without a SessionRepository
+var ErrSessionsNotConfigured = errors.New("sessions not configured")
+
+// SessionRepository stores one row per login. It is a SessionRevoker too,
+// so logging out everywhere ends every recorded session.
+type SessionRepository interface {
+	Create(ctx context.Context, session *domain.Session) error
+	// Get returns the session whatever its state, or ErrSessionNotFound
+	Get(ctx context.Context, id string) (*domain.Session, error)
+	// ListActive returns the user's sessions that are neither revoked nor
+	// expired at now, most recently used first
+	ListActive(ctx context.Context, userID uint, now time.Time) ([]*domain.Session, error)
+	// Touch records a use of the session at at, extending it to expiresAt
+	Touch(ctx context.Context, id string, at, expiresAt time.Time) error
+	// Revoke ends the user's active session id, failing with
+	// ErrSessionNotFound if they have no such session
+	Revoke(ctx context.Context, userID uint, id string, at time.Time) error
+	// Anonymize blanks the user agent and IP of every session userID has,
+	// keeping the rows so revoked sessions stay revoked
+	Anonymize(ctx context.Context, userID uint) error
+	SessionRevoker
+}
+
+// RevokedSessionCache remembers revoked session IDs where the auth check
+// can read them without a database query
+type RevokedSessionCache interface {
+	MarkSessionRevoked(ctx context.Context, id string) error
+	SessionRevoked(ctx context.Context, id string) (bool, error)
+}
+
+// WithSessions records a session for every login in repo, each lasting ttl
+// past its last use, and enables the session methods
+func WithSessions(repo SessionRepository, ttl time.Duration) Option {
+	return func(s *UserService) {
+		s.sessionRepo = repo
+		s.sessionTTL = ttl
+	}
+}
+
+// WithRevokedSessionCache makes SessionActive read from cache alone, which
+// RevokeSession fills. Without it every check reads the session.
+func WithRevokedSessionCache(cache RevokedSessionCache) Option {
+	return func(s *UserService) {
+		s.revokedSessions = cache
+	}
+}
+
+// StartSession records a new session for userID, from the client in ctx,
+// and returns it. Its ID goes into the login's tokens.
+func (s *UserService) StartSession(ctx context.Context, userID uint) (*domain.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.sessionRepo == nil {
+		return nil, ErrSessionsNotConfigured
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	client := ClientInfoFrom(ctx)
+	now := s.now().UTC()
+	session := &domain.Session{
+		ID:         id,
+		UserID:     userID,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.sessionTTL),
+	}
+
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	if err := s.sessionRepo.Create(writeCtx, session); err != nil {
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+	return session, nil
+}
+
+// ListSessions returns the user's active sessions, most recently used
+// first
+func (s *UserService) ListSessions(ctx context.Context, userID uint) ([]*domain.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.sessionRepo == nil {
+		return nil, ErrSessionsNotConfigured
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	defer cancel()
+	sessions, err := s.sessionRepo.ListActive(readCtx, userID, s.now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs the user's session id out: its access tokens are
+// refused from now on and its refresh tokens can't be exchanged. A session
+// that isn't the user's, or has already ended, is ErrSessionNotFound.
+func (s *UserService) RevokeSession(ctx context.Context, userID uint, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.sessionRepo == nil {
+		return ErrSessionsNotConfigured
+	}
+
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	err := s.sessionRepo.Revoke(writeCtx, userID, id, s.now().UTC())
+	cancel()
+	if err != nil {
+		if errors.Is(err, domain.ErrSessionNotFound) {
+			return err
+		}
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	if s.revokedSessions != nil {
+		s.afterCommit(ctx, "mark session revoked", func(ctx context.Context) error {
+			return s.revokedSessions.MarkSessionRevoked(ctx, id)
+		})
+	}
+	return nil
+}
+
+// UseSession records a refresh of session id by userID, failing with
+// ErrSessionNotFound if the session was revoked, has expired or belongs to
+// someone else. Refresh tokens from before sessions were recorded have no
+// session, and pass.
+func (s *UserService) UseSession(ctx context.Context, userID uint, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.sessionRepo == nil {
+		return nil
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	session, err := s.sessionRepo.Get(readCtx, id)
+	cancel()
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load session: %w", err)
+	}
+	now := s.now().UTC()
+	if session.UserID != userID || !session.Active(now) {
+		return domain.ErrSessionNotFound
+	}
+
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	if err := s.sessionRepo.Touch(writeCtx, id, now, now.Add(s.sessionTTL)); err != nil {
+		return fmt.Errorf("failed to record session use: %w", err)
+	}
+	return nil
+}
+
+// SessionActive reports whether tokens of session id are still accepted.
+// With a RevokedSessionCache only the cache is read, falling back to the
+// database when it fails. Sessions that were never recorded count as
+// active: their tokens expire on their own.
+func (s *UserService) SessionActive(ctx context.Context, id string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if s.sessionRepo == nil {
+		return true, nil
+	}
+
+	if s.revokedSessions != nil {
+		cacheCtx, cancel := stepContext(ctx, cacheOpTimeout)
+		revoked, err := s.revokedSessions.SessionRevoked(cacheCtx, id)
+		cancel()
+		if err == nil {
+			return !revoked, nil
+		}
+		logging.Printf(ctx, "Revoked session cache failed, reading session %s: %v", id, err)
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	defer cancel()
+	session, err := s.sessionRepo.Get(readCtx, id)
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load session: %w", err)
+	}
+	return session.RevokedAt == nil, nil
+}
+
+// newSessionID returns 16 random bytes in hex, the length of a refresh
+// token family
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,188 @@
+// internal/application/sessions_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+// fakeSessionRepo is an in-memory application.SessionRepository
+type fakeSessionRepo struct {
+	mu       sync.Mutex
+	sessions map[string]*domain.Session
+	reads    int
+}
+
+func (r *fakeSessionRepo) Create(ctx context.Context, session *domain.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *session
+	r.sessions[session.ID] = &stored
+	return nil
+}
+
+func (r *fakeSessionRepo) Get(ctx context.Context, id string) (*domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reads++
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, domain.ErrSessionNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *fakeSessionRepo) ListActive(ctx context.Context, userID uint, now time.Time) ([]*domain.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sessions []*domain.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.Active(now) {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *fakeSessionRepo) Touch(ctx context.Context, id string, at, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if session, ok := r.sessions[id]; ok {
+		session.LastUsedAt, session.ExpiresAt = at, expiresAt
+	}
+	return nil
+}
+
+func (r *fakeSessionRepo) Revoke(ctx context.Context, userID uint, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok || session.UserID != userID || !session.Active(at) {
+		return domain.ErrSessionNotFound
+	}
+	session.RevokedAt = &at
+	return nil
+}
+
+func (r *fakeSessionRepo) RevokeUserSessions(ctx context.Context, userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeSessionRepo) Anonymize(ctx context.Context, userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, session := range r.sessions {
+		if session.UserID == userID {
+			session.UserAgent, session.IP = "", ""
+		}
+	}
+	return nil
+}
+
+// fakeRevokedSessions is an in-memory application.RevokedSessionCache
+type fakeRevokedSessions struct {
+	mu      sync.Mutex
+	revoked map[string]bool
+}
+
+func (c *fakeRevokedSessions) MarkSessionRevoked(ctx context.Context, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.revoked[id] = true
+	return nil
+}
+
+func (c *fakeRevokedSessions) SessionRevoked(ctx context.Context, id string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.revoked[id], nil
+}
+
+func TestSessions(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	bob := repo.AddUser("bob@example.com", "secret123")
+	sessions := &fakeSessionRepo{sessions: make(map[string]*domain.Session)}
+	cache := &fakeRevokedSessions{revoked: make(map[string]bool)}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil,
+		application.WithSessions(sessions, time.Hour),
+		application.WithRevokedSessionCache(cache),
+		application.WithSessionRevoker(sessions),
+	)
+	ctx := application.WithClientInfo(context.Background(), application.ClientInfo{IP: "203.0.113.7", UserAgent: "curl/8.0"})
+
+	laptop, err := svc.StartSession(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	if laptop.IP != "203.0.113.7" || laptop.UserAgent != "curl/8.0" || len(laptop.ID) != 32 {
+		t.Errorf("expected the client recorded, got %+v", laptop)
+	}
+	phone, _ := svc.StartSession(ctx, alice.ID)
+	if listed, err := svc.ListSessions(ctx, alice.ID); err != nil || len(listed) != 2 {
+		t.Fatalf("expected two sessions, got %d (%v)", len(listed), err)
+	}
+
+	// Refreshing someone else's session, or an unknown one
+	if err := svc.UseSession(ctx, bob.ID, phone.ID); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Errorf("expected a foreign session refused, got %v", err)
+	}
+	if err := svc.UseSession(ctx, alice.ID, "legacy-family"); err != nil {
+		t.Errorf("expected a session from before sessions were recorded accepted, got %v", err)
+	}
+
+	if err := svc.RevokeSession(ctx, bob.ID, phone.ID); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Errorf("expected bob unable to revoke alice's session, got %v", err)
+	}
+	if err := svc.RevokeSession(ctx, alice.ID, phone.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	svc.Wait()
+	reads := sessions.reads
+	if active, err := svc.SessionActive(ctx, phone.ID); err != nil || active {
+		t.Errorf("expected the revoked session inactive, got %v (%v)", active, err)
+	}
+	if active, _ := svc.SessionActive(ctx, laptop.ID); !active {
+		t.Error("expected the other session still active")
+	}
+	if sessions.reads != reads {
+		t.Error("expected the check answered from the cache")
+	}
+	if err := svc.UseSession(ctx, alice.ID, phone.ID); !errors.Is(err, domain.ErrSessionNotFound) {
+		t.Errorf("expected the revoked session's refresh refused, got %v", err)
+	}
+
+	// Logging out everywhere ends the rest
+	if err := svc.LogoutAll(ctx, alice.ID); err != nil {
+		t.Fatalf("logout all: %v", err)
+	}
+	svc.Wait()
+	if listed, _ := svc.ListSessions(ctx, alice.ID); len(listed) != 0 {
+		t.Errorf("expected no sessions left, got %d", len(listed))
+	}
+
+	unconfigured := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+	if _, err := unconfigured.StartSession(ctx, alice.ID); !errors.Is(err, application.ErrSessionsNotConfigured) {
+		t.Errorf("expected ErrSessionsNotConfigured, got %v", err)
+	}
+	if active, err := unconfigured.SessionActive(ctx, phone.ID); err != nil || !active {
+		t.Errorf("expected every session active without a repository, got %v (%v)", active, err)
+	}
+}
@@ -0,0 +1,445 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/normalize"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SnapshotVersion is the UserSnapshot layout ExportSnapshot writes and
+// ImportSnapshot accepts
+const SnapshotVersion = 1
+
+var (
+	// ErrSnapshotsNotConfigured is returned by the snapshot methods when
+	// the service was built without a signing secret
+	ErrSnapshotsNotConfigured = errors.New("snapshots not configured")
+	// ErrInvalidSnapshotSignature means the bundle wasn't signed with this
+	// service's secret, or was changed after it was
+	ErrInvalidSnapshotSignature = errors.New("invalid snapshot signature")
+)
+
+// UserSnapshot is an account's portable data: its profile and preferences.
+// Credentials, sessions and recovery codes are never included.
+type UserSnapshot struct {
+	Version         int                       `json:"version"`
+	ExportedAt      time.Time                 `json:"exported_at"`
+	SourceID        uint                      `json:"source_id"`
+	Username        string                    `json:"username"`
+	Email           string                    `json:"email"`
+	FirstName       string                    `json:"first_name"`
+	LastName        string                    `json:"last_name"`
+	Status          domain.UserStatus         `json:"status"`
+	Role            domain.Role               `json:"role"`
+	EmailVerifiedAt *time.Time                `json:"email_verified_at,omitempty"`
+	Notifications   SnapshotNotificationPrefs `json:"notifications"`
+	CreatedAt       time.Time                 `json:"created_at"`
+}
+
+// SnapshotNotificationPrefs are the optional mail categories in a snapshot
+type SnapshotNotificationPrefs struct {
+	SecurityAlerts bool `json:"security_alerts"`
+	Marketing      bool `json:"marketing"`
+	ProductUpdates bool `json:"product_updates"`
+}
+
+func snapshotPrefs(p domain.NotificationPreferences) SnapshotNotificationPrefs {
+	return SnapshotNotificationPrefs{
+		SecurityAlerts: !p.SecurityAlertsMuted,
+		Marketing:      p.Marketing,
+		ProductUpdates: p.ProductUpdates,
+	}
+}
+
+func (p SnapshotNotificationPrefs) domain() domain.NotificationPreferences {
+	return domain.NotificationPreferences{
+		SecurityAlertsMuted: !p.SecurityAlerts,
+		Marketing:           p.Marketing,
+		ProductUpdates:      p.ProductUpdates,
+	}
+}
+
+// SignedSnapshot is a UserSnapshot as exported. Signature is the hex
+// HMAC-SHA256 of the compacted snapshot JSON, so whitespace may change in
+// transit but nothing else.
+type SignedSnapshot struct {
+	Snapshot  json.RawMessage `json:"snapshot"`
+	Signature string          `json:"signature"`
+}
+
+// SnapshotConflict is a field whose current value an import would replace
+type SnapshotConflict struct {
+	Field    string
+	Current  interface{}
+	Snapshot interface{}
+	// Forceable is false when overwriting can't resolve it, e.g. a
+	// username another account holds
+	Forceable bool
+}
+
+// SnapshotImport reports what ImportSnapshot did. When Conflicts is
+// non-empty nothing was written.
+type SnapshotImport struct {
+	UserID    uint
+	Created   bool
+	Applied   []string
+	Conflicts []SnapshotConflict
+}
+
+// WithSnapshotSecret enables ExportSnapshot and ImportSnapshot, signing
+// bundles with secret. It should be used for nothing else, so rotating it
+// only invalidates snapshots.
+func WithSnapshotSecret(secret []byte) Option {
+	return func(s *UserService) {
+		s.snapshotSecret = secret
+	}
+}
+
+// snapshotMAC signs the compacted form of raw
+func (s *UserService) snapshotMAC(raw []byte) ([]byte, error) {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, s.snapshotSecret)
+	mac.Write(compact.Bytes())
+	return mac.Sum(nil), nil
+}
+
+// ExportSnapshot returns the user's signed snapshot. Erased accounts have
+// nothing left to export and are reported as not found.
+func (s *UserService) ExportSnapshot(ctx context.Context, id uint, reason string) (*SignedSnapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(s.snapshotSecret) == 0 {
+		return nil, ErrSnapshotsNotConfigured
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if user.Status == domain.StatusErased {
+		return nil, domain.ErrUserNotFound
+	}
+
+	snapshot := UserSnapshot{
+		Version:       SnapshotVersion,
+		ExportedAt:    s.now().UTC(),
+		SourceID:      user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Status:        user.Status,
+		Role:          user.Role,
+		Notifications: snapshotPrefs(user.Notifications),
+		CreatedAt:     user.CreatedAt.UTC(),
+	}
+	if user.EmailVerifiedAt != nil {
+		verifiedAt := user.EmailVerifiedAt.UTC()
+		snapshot.EmailVerifiedAt = &verifiedAt
+	}
+
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	signature, err := s.snapshotMAC(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign snapshot: %w", err)
+	}
+
+	// Exports carry personal data out of the service, so none is handed
+	// over unaudited
+	if s.audit != nil {
+		auditCtx, cancel := stepContext(ctx, writeTimeout)
+		err := s.audit.Record(auditCtx, &AuditEntry{
+			Action:    AuditSnapshotExported,
+			TargetID:  id,
+			Reason:    reason,
+			CreatedAt: s.now().UTC(),
+		})
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to audit export: %w", err)
+		}
+	}
+
+	return &SignedSnapshot{Snapshot: raw, Signature: hex.EncodeToString(signature)}, nil
+}
+
+// ImportSnapshot verifies bundle and applies it to the account with the
+// same email, creating one if there is none. A created account gets an
+// unusable password, so its owner must reset it before logging in.
+//
+// Fields of an existing account that are empty take the snapshot's value.
+// Fields that differ are reported as conflicts and nothing is written,
+// unless overwrite is set; a username held by another account is a
+// conflict even then.
+func (s *UserService) ImportSnapshot(ctx context.Context, bundle *SignedSnapshot, overwrite bool, reason string) (*SnapshotImport, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(s.snapshotSecret) == 0 {
+		return nil, ErrSnapshotsNotConfigured
+	}
+
+	expected, err := s.snapshotMAC(bundle.Snapshot)
+	if err != nil {
+		return nil, ErrInvalidSnapshotSignature
+	}
+	given, err := hex.DecodeString(bundle.Signature)
+	if err != nil || !hmac.Equal(given, expected) {
+		return nil, ErrInvalidSnapshotSignature
+	}
+
+	var snapshot UserSnapshot
+	if err := json.Unmarshal(bundle.Snapshot, &snapshot); err != nil {
+		return nil, &ValidationError{Fields: map[string]string{"snapshot": "snapshot is malformed"}}
+	}
+	if err := validateSnapshot(&snapshot); err != nil {
+		return nil, err
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	existing, err := s.repo.GetByEmail(readCtx, snapshot.Email)
+	cancel()
+	if err != nil && !errors.Is(err, domain.ErrUserNotFound) {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	entry := &AuditEntry{
+		Action: AuditSnapshotImported,
+		Reason: reason,
+		Metadata: map[string]interface{}{
+			"source_id":   snapshot.SourceID,
+			"exported_at": snapshot.ExportedAt.UTC().Format(time.RFC3339),
+		},
+		CreatedAt: s.now().UTC(),
+	}
+
+	if existing == nil {
+		return s.importNewUser(ctx, &snapshot, entry)
+	}
+	return s.importExistingUser(ctx, existing.ID, &snapshot, overwrite, entry)
+}
+
+// validateSnapshot normalizes snapshot and checks what a signature can't:
+// a bundle from an older layout, or an account state that can't be moved
+func validateSnapshot(snapshot *UserSnapshot) error {
+	snapshot.Email = normalize.Email(snapshot.Email)
+	snapshot.Username = normalize.Username(snapshot.Username)
+	snapshot.FirstName = normalize.Name(snapshot.FirstName)
+	snapshot.LastName = normalize.Name(snapshot.LastName)
+
+	verr := &ValidationError{Fields: make(map[string]string)}
+	if snapshot.Version != SnapshotVersion {
+		verr.Fields["version"] = fmt.Sprintf("snapshot version %d is not supported", snapshot.Version)
+	}
+	if snapshot.Username == "" {
+		verr.Fields["username"] = "Username is required"
+	}
+	if snapshot.Email == "" {
+		verr.Fields["email"] = "Email is required"
+	}
+	if snapshot.Status != domain.StatusActive && snapshot.Status != domain.StatusBanned {
+		verr.Fields["status"] = "Only active and banned accounts can be imported"
+	}
+	if !snapshot.Role.Valid() {
+		verr.Fields["role"] = "Role is invalid"
+	}
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func (s *UserService) importNewUser(ctx context.Context, snapshot *UserSnapshot, entry *AuditEntry) (*SnapshotImport, error) {
+	// Nobody knows this password; the owner resets it to get in
+	unusable := make([]byte, 32)
+	if _, err := rand.Read(unusable); err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(hex.EncodeToString(unusable)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &domain.User{
+		Username:        snapshot.Username,
+		Email:           snapshot.Email,
+		FirstName:       snapshot.FirstName,
+		LastName:        snapshot.LastName,
+		Status:          snapshot.Status,
+		Role:            snapshot.Role,
+		EmailVerifiedAt: snapshot.EmailVerifiedAt,
+		Notifications:   snapshot.Notifications.domain(),
+	}
+	result := &SnapshotImport{Created: true}
+
+	txCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	err = s.WithTransaction(txCtx, func(txCtx context.Context, tx *TxService) error {
+		taken, err := tx.LockConflicts(txCtx, 0, user.Username, user.Email)
+		if err != nil {
+			return err
+		}
+		for _, field := range taken {
+			// The email was free when it was looked up; whoever claimed it
+			// since is reported like a username clash
+			current := user.Username
+			if field == "email" {
+				current = user.Email
+			}
+			result.Conflicts = append(result.Conflicts, SnapshotConflict{
+				Field: field, Current: current, Snapshot: current,
+			})
+		}
+		if len(result.Conflicts) > 0 {
+			return nil
+		}
+
+		if err := tx.CreateUser(txCtx, user, string(hashedPassword)); err != nil {
+			return err
+		}
+		entry.TargetID = user.ID
+		entry.Metadata["created"] = true
+		return tx.Audit(txCtx, entry)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import snapshot: %w", err)
+	}
+	if len(result.Conflicts) > 0 {
+		result.Created = false
+		return result, nil
+	}
+
+	result.UserID = user.ID
+	result.Applied = []string{"username", "email", "first_name", "last_name", "status", "role", "email_verified", "notifications"}
+	if user.IsBanned() {
+		s.updateBlocklist(ctx, user.ID)
+	}
+	return result, nil
+}
+
+func (s *UserService) importExistingUser(ctx context.Context, id uint, snapshot *UserSnapshot, overwrite bool, entry *AuditEntry) (*SnapshotImport, error) {
+	result := &SnapshotImport{UserID: id}
+	var previous, updated domain.User
+
+	txCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	err := s.WithTransaction(txCtx, func(txCtx context.Context, tx *TxService) error {
+		current, err := tx.GetUser(txCtx, id)
+		if err != nil {
+			return err
+		}
+		previous = *current
+
+		var username string
+		if !strings.EqualFold(current.Username, snapshot.Username) {
+			username = snapshot.Username
+		}
+		taken, err := tx.LockConflicts(txCtx, id, username, "")
+		if err != nil {
+			return err
+		}
+
+		applied, conflicts := mergeSnapshot(current, snapshot, len(taken) > 0)
+		for _, conflict := range conflicts {
+			if !conflict.Forceable || !overwrite {
+				result.Conflicts = conflicts
+				return nil
+			}
+		}
+		for _, conflict := range conflicts {
+			applied = append(applied, conflict.Field)
+		}
+		if len(applied) == 0 {
+			return nil
+		}
+		result.Applied = applied
+
+		if err := tx.UpdateUser(txCtx, current); err != nil {
+			return err
+		}
+		updated = *current
+		entry.TargetID = id
+		entry.Metadata["fields"] = applied
+		entry.Metadata["overwrite"] = overwrite
+		return tx.Audit(txCtx, entry)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to import snapshot: %w", err)
+	}
+	if len(result.Applied) == 0 {
+		return result, nil
+	}
+
+	s.invalidateUser(ctx, &previous)
+	if previous.Status != updated.Status {
+		s.updateBlocklist(ctx, id)
+	}
+	return result, nil
+}
+
+// mergeSnapshot copies snapshot onto user. Empty fields of user are filled
+// and returned as applied; differing fields are returned as conflicts and
+// are also copied, for the caller to save only if it overwrites.
+// usernameTaken means the snapshot's username belongs to another account.
+// An account waiting to be erased keeps its status; cancelling deletion is
+// CancelDeletion's job.
+func mergeSnapshot(user *domain.User, snapshot *UserSnapshot, usernameTaken bool) (applied []string, conflicts []SnapshotConflict) {
+	statusMovable := user.Status == domain.StatusActive || user.Status == domain.StatusBanned
+	merge := func(field string, current, incoming interface{}, empty bool, set func()) {
+		if current == incoming {
+			return
+		}
+		set()
+		if empty {
+			applied = append(applied, field)
+			return
+		}
+		conflicts = append(conflicts, SnapshotConflict{
+			Field:     field,
+			Current:   current,
+			Snapshot:  incoming,
+			Forceable: field != "status" || statusMovable,
+		})
+	}
+
+	if usernameTaken {
+		conflicts = append(conflicts, SnapshotConflict{
+			Field: "username", Current: user.Username, Snapshot: snapshot.Username,
+		})
+	} else {
+		merge("username", user.Username, snapshot.Username, user.Username == "", func() { user.Username = snapshot.Username })
+	}
+	merge("first_name", user.FirstName, snapshot.FirstName, user.FirstName == "", func() { user.FirstName = snapshot.FirstName })
+	merge("last_name", user.LastName, snapshot.LastName, user.LastName == "", func() { user.LastName = snapshot.LastName })
+	merge("status", user.Status, snapshot.Status, false, func() { user.Status = snapshot.Status })
+	merge("role", user.Role, snapshot.Role, false, func() { user.Role = snapshot.Role })
+	merge("email_verified", user.IsEmailVerified(), snapshot.EmailVerifiedAt != nil, !user.IsEmailVerified(), func() {
+		user.EmailVerifiedAt = snapshot.EmailVerifiedAt
+	})
+	merge("notifications", snapshotPrefs(user.Notifications), snapshot.Notifications, false, func() {
+		user.Notifications = snapshot.Notifications.domain()
+	})
+	return applied, conflicts
+}
@@ -0,0 +1,235 @@
+// internal/application/snapshots_test.go
+package application_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+const testSnapshotSecret = "snapshot-secret-of-at-least-32-chars"
+
+type snapshotEnv struct {
+	repo  *testsupport.UserRepository
+	audit *fakeAuditLogger
+	svc   *application.UserService
+}
+
+func newSnapshotEnv(secret string) *snapshotEnv {
+	env := &snapshotEnv{repo: testsupport.NewUserRepository(), audit: &fakeAuditLogger{}}
+	env.svc = application.NewUserService(env.repo, testsupport.NewTxManager(env.repo), nil,
+		application.WithAuditLogger(env.audit),
+		application.WithSnapshotSecret([]byte(secret)),
+	)
+	return env
+}
+
+// seedSnapshotUser adds a verified account with non-default preferences
+func seedSnapshotUser(env *snapshotEnv) *domain.User {
+	user := env.repo.AddUser("alice@example.com", "secret123")
+	verifiedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	user.Username = "alice"
+	user.FirstName = "Alice"
+	user.LastName = "Liddell"
+	user.Role = domain.RoleUser
+	user.EmailVerifiedAt = &verifiedAt
+	user.Notifications = domain.NotificationPreferences{SecurityAlertsMuted: true, Marketing: true}
+	env.repo.Put(user)
+	return user
+}
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := newSnapshotEnv(testSnapshotSecret)
+	seeded := seedSnapshotUser(source)
+
+	bundle, err := source.svc.ExportSnapshot(ctx, seeded.ID, "exported via test")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if strings.Contains(string(bundle.Snapshot), "password") || strings.Contains(string(bundle.Snapshot), "$2a$") {
+		t.Fatalf("snapshot must not carry the password: %s", bundle.Snapshot)
+	}
+
+	// The bundle travels as JSON, possibly re-indented on the way
+	encoded, _ := json.MarshalIndent(bundle, "", "    ")
+	var received application.SignedSnapshot
+	if err := json.Unmarshal(encoded, &received); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	target := newSnapshotEnv(testSnapshotSecret)
+	result, err := target.svc.ImportSnapshot(ctx, &received, false, "imported via test")
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if !result.Created || len(result.Conflicts) > 0 {
+		t.Fatalf("expected the account to be created, got %+v", result)
+	}
+
+	imported, ok := target.repo.User(result.UserID)
+	if !ok {
+		t.Fatal("imported account not stored")
+	}
+	if imported.Username != "alice" || imported.Email != "alice@example.com" ||
+		imported.FirstName != "Alice" || imported.LastName != "Liddell" ||
+		imported.Role != domain.RoleUser || imported.Status != domain.StatusActive {
+		t.Errorf("profile not carried over: %+v", imported)
+	}
+	if imported.EmailVerifiedAt == nil || !imported.EmailVerifiedAt.Equal(*seeded.EmailVerifiedAt) {
+		t.Errorf("expected verification at %v, got %v", seeded.EmailVerifiedAt, imported.EmailVerifiedAt)
+	}
+	if imported.Notifications != seeded.Notifications {
+		t.Errorf("expected preferences %+v, got %+v", seeded.Notifications, imported.Notifications)
+	}
+	// Nobody knows the new account's password, including the old one
+	if _, err := target.svc.Login(ctx, "alice@example.com", "secret123"); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Errorf("expected the source password not to work, got %v", err)
+	}
+
+	// Importing the same snapshot again finds nothing to change
+	again, err := target.svc.ImportSnapshot(ctx, &received, false, "imported via test")
+	if err != nil {
+		t.Fatalf("reimport: %v", err)
+	}
+	if again.Created || again.UserID != result.UserID || len(again.Applied) != 0 || len(again.Conflicts) != 0 {
+		t.Errorf("expected a no-op reimport, got %+v", again)
+	}
+
+	if got := auditActions(source.audit); !equalStrings(got, []string{application.AuditSnapshotExported}) {
+		t.Errorf("source audited %v", got)
+	}
+	if got := auditActions(target.audit); !equalStrings(got, []string{application.AuditSnapshotImported}) {
+		t.Errorf("target audited %v", got)
+	}
+}
+
+func TestImportSnapshot_RejectsForgeries(t *testing.T) {
+	ctx := context.Background()
+	source := newSnapshotEnv(testSnapshotSecret)
+	seeded := seedSnapshotUser(source)
+	bundle, err := source.svc.ExportSnapshot(ctx, seeded.ID, "exported via test")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	tampered := *bundle
+	tampered.Snapshot = json.RawMessage(strings.Replace(string(bundle.Snapshot), `"role":"user"`, `"role":"admin"`, 1))
+	unsigned := *bundle
+	unsigned.Signature = "not-hex"
+
+	tests := []struct {
+		name   string
+		secret string
+		bundle *application.SignedSnapshot
+	}{
+		{"edited after export", testSnapshotSecret, &tampered},
+		{"malformed signature", testSnapshotSecret, &unsigned},
+		{"signed with another secret", "some-other-secret-of-32-characters!", bundle},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := newSnapshotEnv(tt.secret)
+			if _, err := target.svc.ImportSnapshot(ctx, tt.bundle, true, "imported via test"); !errors.Is(err, application.ErrInvalidSnapshotSignature) {
+				t.Fatalf("expected ErrInvalidSnapshotSignature, got %v", err)
+			}
+			if _, ok := target.repo.User(1); ok {
+				t.Error("a forged snapshot must not create an account")
+			}
+		})
+	}
+
+	unconfigured := application.NewUserService(source.repo, testsupport.NewTxManager(source.repo), nil)
+	if _, err := unconfigured.ExportSnapshot(ctx, seeded.ID, "exported via test"); !errors.Is(err, application.ErrSnapshotsNotConfigured) {
+		t.Errorf("expected ErrSnapshotsNotConfigured without a secret, got %v", err)
+	}
+}
+
+func TestImportSnapshot_ReportsConflicts(t *testing.T) {
+	ctx := context.Background()
+	source := newSnapshotEnv(testSnapshotSecret)
+	seeded := seedSnapshotUser(source)
+	bundle, err := source.svc.ExportSnapshot(ctx, seeded.ID, "exported via test")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	// The target already knows alice: unverified, no last name, and under
+	// another first name
+	target := newSnapshotEnv(testSnapshotSecret)
+	existing := target.repo.AddUser("alice@example.com", "secret123")
+	existing.Username = "alice"
+	existing.FirstName = "Ally"
+	existing.Role = domain.RoleUser
+	target.repo.Put(existing)
+
+	result, err := target.svc.ImportSnapshot(ctx, bundle, false, "imported via test")
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	var fields []string
+	for _, c := range result.Conflicts {
+		fields = append(fields, c.Field)
+		if !c.Forceable {
+			t.Errorf("expected %s to be overwritable", c.Field)
+		}
+	}
+	if !equalStrings(fields, []string{"first_name", "notifications"}) {
+		t.Fatalf("expected first_name and notifications to conflict, got %+v", result.Conflicts)
+	}
+	if stored, _ := target.repo.User(existing.ID); stored.FirstName != "Ally" || stored.LastName != "" {
+		t.Fatalf("a conflicting import must write nothing, got %+v", stored)
+	}
+
+	result, err = target.svc.ImportSnapshot(ctx, bundle, true, "imported via test")
+	if err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+	if want := []string{"last_name", "email_verified", "first_name", "notifications"}; !equalStrings(result.Applied, want) {
+		t.Errorf("applied %v, want %v", result.Applied, want)
+	}
+	stored, _ := target.repo.User(existing.ID)
+	if stored.FirstName != "Alice" || stored.LastName != "Liddell" || !stored.IsEmailVerified() {
+		t.Errorf("expected the snapshot applied, got %+v", stored)
+	}
+	// The account's own password is kept
+	if _, err := target.svc.Login(ctx, "alice@example.com", "secret123"); err != nil {
+		t.Errorf("expected the existing password to survive, got %v", err)
+	}
+}
+
+func TestImportSnapshot_TakenUsernameCantBeForced(t *testing.T) {
+	ctx := context.Background()
+	source := newSnapshotEnv(testSnapshotSecret)
+	seeded := seedSnapshotUser(source)
+	bundle, err := source.svc.ExportSnapshot(ctx, seeded.ID, "exported via test")
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	target := newSnapshotEnv(testSnapshotSecret)
+	other := target.repo.AddUser("someone@example.com", "secret123")
+	other.Username = "Alice"
+	target.repo.Put(other)
+
+	result, err := target.svc.ImportSnapshot(ctx, bundle, true, "imported via test")
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if result.Created || len(result.Conflicts) != 1 || result.Conflicts[0].Field != "username" || result.Conflicts[0].Forceable {
+		t.Fatalf("expected an unforceable username conflict, got %+v", result)
+	}
+	if _, err := target.repo.GetByEmail(ctx, "alice@example.com"); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected no account created, got %v", err)
+	}
+	if len(target.audit.entries) != 0 {
+		t.Errorf("expected nothing audited, got %+v", target.audit.entries)
+	}
+}
@@ -0,0 +1,238 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/logging"
+)
+
+// Granularity is the width of one activity bucket
+type Granularity string
+
+const (
+	GranularityDay  Granularity = "day"
+	GranularityWeek Granularity = "week"
+)
+
+const (
+	// Caps keep a single request from scanning years of rows
+	maxDailyStatsRange  = 92 * 24 * time.Hour
+	maxWeeklyStatsRange = 53 * 7 * 24 * time.Hour
+	defaultStatsRange   = 30 * 24 * time.Hour
+
+	// Windows that are entirely in the past never change
+	closedWindowCacheTTL = time.Hour
+	openWindowCacheTTL   = time.Minute
+)
+
+// BucketCount is one row of a date_trunc grouped count
+type BucketCount struct {
+	Start time.Time
+	Count int64
+}
+
+// ActivityStatsRepository counts activity per bucket over [from, to).
+// Buckets without activity may be omitted.
+type ActivityStatsRepository interface {
+	CountSignups(ctx context.Context, granularity Granularity, from, to time.Time) ([]BucketCount, error)
+	CountLogins(ctx context.Context, granularity Granularity, from, to time.Time) ([]BucketCount, error)
+	CountDeletions(ctx context.Context, granularity Granularity, from, to time.Time) ([]BucketCount, error)
+}
+
+// UserCountRepository counts accounts by status, erased ones included
+type UserCountRepository interface {
+	CountUsersByStatus(ctx context.Context) (map[domain.UserStatus]int64, error)
+}
+
+// StatsCache stores computed activity windows
+type StatsCache interface {
+	GetActivity(ctx context.Context, key string) (*ActivityReport, error)
+	SetActivity(ctx context.Context, key string, report *ActivityReport, ttl time.Duration) error
+}
+
+// ActivityBucket holds the counts for one day or week starting at Start
+type ActivityBucket struct {
+	Start     time.Time `json:"start"`
+	Signups   int64     `json:"signups"`
+	Logins    int64     `json:"logins"`
+	Deletions int64     `json:"deletions"`
+}
+
+// ActivityReport covers [From, To) with one bucket per period, including
+// empty ones
+type ActivityReport struct {
+	Granularity Granularity      `json:"granularity"`
+	From        time.Time        `json:"from"`
+	To          time.Time        `json:"to"`
+	Buckets     []ActivityBucket `json:"buckets"`
+}
+
+// ActivityStats is the contract the admin stats handler depends on
+type ActivityStats interface {
+	Activity(ctx context.Context, granularity string, from, to time.Time) (*ActivityReport, error)
+}
+
+var _ ActivityStats = (*UserStatsService)(nil)
+
+// UserStatsService aggregates signups, successful logins and deletion
+// requests for dashboards
+type UserStatsService struct {
+	repo  ActivityStatsRepository
+	cache StatsCache
+	now   func() time.Time
+}
+
+func NewUserStatsService(repo ActivityStatsRepository, cache StatsCache) *UserStatsService {
+	return &UserStatsService{
+		repo:  repo,
+		cache: cache,
+		now:   time.Now,
+	}
+}
+
+// Activity returns per-bucket counts between from and to (exclusive), both
+// in UTC. A zero to means now and a zero from means 30 days before to.
+// from is rounded down to the start of its bucket.
+func (s *UserStatsService) Activity(ctx context.Context, granularity string, from, to time.Time) (*ActivityReport, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	unit, from, to, err := s.normalizeWindow(granularity, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("stats:activity:%s:%d:%d", unit, from.Unix(), to.Unix())
+	if s.cache != nil {
+		cacheCtx, cancel := stepContext(ctx, cacheOpTimeout)
+		report, err := s.cache.GetActivity(cacheCtx, key)
+		cancel()
+		if err == nil && report != nil {
+			return report, nil
+		}
+	}
+
+	report, err := s.compute(ctx, unit, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cache != nil {
+		ttl := closedWindowCacheTTL
+		if to.After(s.now()) {
+			ttl = openWindowCacheTTL
+		}
+		cacheCtx, cancel := bestEffortContext(ctx, cacheOpTimeout)
+		if err := s.cache.SetActivity(cacheCtx, key, report, ttl); err != nil {
+			logging.Printf(ctx, "Failed to cache activity stats: %v", err)
+		}
+		cancel()
+	}
+
+	return report, nil
+}
+
+func (s *UserStatsService) normalizeWindow(granularity string, from, to time.Time) (Granularity, time.Time, time.Time, error) {
+	fields := make(map[string]string)
+
+	unit := Granularity(granularity)
+	if unit == "" {
+		unit = GranularityDay
+	}
+
+	maxRange := maxDailyStatsRange
+	switch unit {
+	case GranularityDay:
+	case GranularityWeek:
+		maxRange = maxWeeklyStatsRange
+	default:
+		fields["granularity"] = "must be day or week"
+	}
+
+	if to.IsZero() {
+		to = s.now()
+	}
+	to = to.UTC()
+	if from.IsZero() {
+		from = to.Add(-defaultStatsRange)
+	}
+	from = truncateToBucket(from.UTC(), unit)
+
+	switch {
+	case !from.Before(to):
+		fields["from"] = "must be before to"
+	case to.Sub(from) > maxRange:
+		fields["to"] = fmt.Sprintf("range must not exceed %d days for %s buckets", int(maxRange.Hours()/24), unit)
+	}
+
+	if len(fields) > 0 {
+		return "", time.Time{}, time.Time{}, &ValidationError{Fields: fields}
+	}
+	return unit, from, to, nil
+}
+
+func (s *UserStatsService) compute(ctx context.Context, unit Granularity, from, to time.Time) (*ActivityReport, error) {
+	queryCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+
+	signups, err := s.repo.CountSignups(queryCtx, unit, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count signups: %w", err)
+	}
+	logins, err := s.repo.CountLogins(queryCtx, unit, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count logins: %w", err)
+	}
+	deletions, err := s.repo.CountDeletions(queryCtx, unit, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count deletions: %w", err)
+	}
+
+	// Gap filling: every bucket in the window is present, even when empty
+	var buckets []ActivityBucket
+	index := make(map[int64]int)
+	for start := from; start.Before(to); start = nextBucket(start, unit) {
+		index[start.Unix()] = len(buckets)
+		buckets = append(buckets, ActivityBucket{Start: start})
+	}
+
+	fill := func(counts []BucketCount, set func(b *ActivityBucket, n int64)) {
+		for _, c := range counts {
+			if i, ok := index[truncateToBucket(c.Start.UTC(), unit).Unix()]; ok {
+				set(&buckets[i], c.Count)
+			}
+		}
+	}
+	fill(signups, func(b *ActivityBucket, n int64) { b.Signups += n })
+	fill(logins, func(b *ActivityBucket, n int64) { b.Logins += n })
+	fill(deletions, func(b *ActivityBucket, n int64) { b.Deletions += n })
+
+	return &ActivityReport{
+		Granularity: unit,
+		From:        from,
+		To:          to,
+		Buckets:     buckets,
+	}, nil
+}
+
+// truncateToBucket matches Postgres date_trunc: days start at midnight UTC
+// and weeks on Monday
+func truncateToBucket(t time.Time, unit Granularity) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if unit != GranularityWeek {
+		return day
+	}
+	offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+	return day.AddDate(0, 0, -offset)
+}
+
+func nextBucket(t time.Time, unit Granularity) time.Time {
+	if unit == GranularityWeek {
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 0, 1)
+}
@@ -0,0 +1,212 @@
+// internal/application/stats_test.go
+package application
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStatsRepo returns one row per seeded event; the service is expected
+// to truncate and sum them like date_trunc + count(*) would
+type fakeStatsRepo struct {
+	mu                         sync.Mutex
+	signups, logins, deletions []time.Time
+	calls                      int
+}
+
+func (r *fakeStatsRepo) rows(events []time.Time, from, to time.Time) []BucketCount {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	var rows []BucketCount
+	for _, at := range events {
+		if !at.Before(from) && at.Before(to) {
+			rows = append(rows, BucketCount{Start: at, Count: 1})
+		}
+	}
+	return rows
+}
+
+func (r *fakeStatsRepo) CountSignups(ctx context.Context, g Granularity, from, to time.Time) ([]BucketCount, error) {
+	return r.rows(r.signups, from, to), nil
+}
+
+func (r *fakeStatsRepo) CountLogins(ctx context.Context, g Granularity, from, to time.Time) ([]BucketCount, error) {
+	return r.rows(r.logins, from, to), nil
+}
+
+func (r *fakeStatsRepo) CountDeletions(ctx context.Context, g Granularity, from, to time.Time) ([]BucketCount, error) {
+	return r.rows(r.deletions, from, to), nil
+}
+
+type fakeStatsCache struct {
+	mu      sync.Mutex
+	reports map[string]*ActivityReport
+	ttls    map[string]time.Duration
+}
+
+func newFakeStatsCache() *fakeStatsCache {
+	return &fakeStatsCache{
+		reports: make(map[string]*ActivityReport),
+		ttls:    make(map[string]time.Duration),
+	}
+}
+
+func (c *fakeStatsCache) GetActivity(ctx context.Context, key string) (*ActivityReport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, ok := c.reports[key]; ok {
+		return r, nil
+	}
+	return nil, errors.New("cache miss")
+}
+
+func (c *fakeStatsCache) SetActivity(ctx context.Context, key string, report *ActivityReport, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reports[key] = report
+	c.ttls[key] = ttl
+	return nil
+}
+
+func jan(day, hour int) time.Time {
+	return time.Date(2024, time.January, day, hour, 0, 0, 0, time.UTC)
+}
+
+// seededMonth spreads activity over January 2024 (Jan 1 is a Monday)
+func seededMonth() *fakeStatsRepo {
+	return &fakeStatsRepo{
+		signups:   []time.Time{jan(1, 9), jan(1, 23), jan(3, 0), jan(15, 12), jan(31, 23)},
+		logins:    []time.Time{jan(1, 10), jan(2, 8), jan(2, 9), jan(2, 22), jan(20, 5), jan(31, 0)},
+		deletions: []time.Time{jan(8, 0), jan(28, 18)},
+	}
+}
+
+func TestActivity_DailyFillsGaps(t *testing.T) {
+	svc := NewUserStatsService(seededMonth(), nil)
+
+	report, err := svc.Activity(context.Background(), "day", jan(1, 0), time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("activity: %v", err)
+	}
+
+	if len(report.Buckets) != 31 {
+		t.Fatalf("expected 31 daily buckets, got %d", len(report.Buckets))
+	}
+	for i, b := range report.Buckets {
+		if want := jan(i+1, 0); !b.Start.Equal(want) {
+			t.Fatalf("bucket %d starts at %v, want %v", i, b.Start, want)
+		}
+	}
+
+	checks := map[int]ActivityBucket{
+		1:  {Signups: 2, Logins: 1},
+		2:  {Logins: 3},
+		3:  {Signups: 1},
+		8:  {Deletions: 1},
+		10: {},
+		31: {Signups: 1, Logins: 1},
+	}
+	for day, want := range checks {
+		got := report.Buckets[day-1]
+		if got.Signups != want.Signups || got.Logins != want.Logins || got.Deletions != want.Deletions {
+			t.Errorf("Jan %d: got %+v, want %+v", day, got, want)
+		}
+	}
+}
+
+func TestActivity_WeeklyStartsOnMonday(t *testing.T) {
+	svc := NewUserStatsService(seededMonth(), nil)
+
+	// A Wednesday start is rounded down to Monday Jan 1
+	report, err := svc.Activity(context.Background(), "week", jan(3, 0), time.Date(2024, time.February, 5, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("activity: %v", err)
+	}
+
+	if !report.From.Equal(jan(1, 0)) {
+		t.Errorf("expected window to start Monday Jan 1, got %v", report.From)
+	}
+	if len(report.Buckets) != 5 {
+		t.Fatalf("expected 5 weekly buckets, got %d", len(report.Buckets))
+	}
+
+	want := []ActivityBucket{
+		{Signups: 3, Logins: 4},
+		{Deletions: 1},
+		{Signups: 1, Logins: 1},
+		{Deletions: 1},
+		{Signups: 1, Logins: 1},
+	}
+	for i, w := range want {
+		got := report.Buckets[i]
+		if got.Signups != w.Signups || got.Logins != w.Logins || got.Deletions != w.Deletions {
+			t.Errorf("week %d: got %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestActivity_ValidatesWindow(t *testing.T) {
+	svc := NewUserStatsService(seededMonth(), nil)
+
+	tests := []struct {
+		name        string
+		granularity string
+		from, to    time.Time
+		field       string
+	}{
+		{"unknown granularity", "hour", jan(1, 0), jan(2, 0), "granularity"},
+		{"inverted range", "day", jan(10, 0), jan(2, 0), "from"},
+		{"daily range too long", "day", jan(1, 0), jan(1, 0).AddDate(0, 6, 0), "to"},
+		{"weekly range too long", "week", jan(1, 0), jan(1, 0).AddDate(2, 0, 0), "to"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Activity(context.Background(), tt.granularity, tt.from, tt.to)
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+			if _, ok := verr.Fields[tt.field]; !ok {
+				t.Errorf("expected error on %s, got %v", tt.field, verr.Fields)
+			}
+		})
+	}
+}
+
+func TestActivity_CachesComputedWindows(t *testing.T) {
+	repo := seededMonth()
+	cache := newFakeStatsCache()
+	svc := NewUserStatsService(repo, cache)
+	svc.now = func() time.Time { return jan(20, 12) }
+
+	closedTo := jan(15, 0)
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Activity(context.Background(), "day", jan(1, 0), closedTo); err != nil {
+			t.Fatalf("activity: %v", err)
+		}
+	}
+	if repo.calls != 3 {
+		t.Errorf("expected the second request to be served from cache, repo called %d times", repo.calls)
+	}
+
+	// A window reaching into the future is still changing
+	if _, err := svc.Activity(context.Background(), "day", jan(10, 0), jan(25, 0)); err != nil {
+		t.Fatalf("activity: %v", err)
+	}
+
+	for key, ttl := range cache.ttls {
+		report := cache.reports[key]
+		want := closedWindowCacheTTL
+		if report.To.After(svc.now()) {
+			want = openWindowCacheTTL
+		}
+		if ttl != want {
+			t.Errorf("window ending %v cached for %v, want %v", report.To, ttl, want)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package application
+
+import (
+	"context"
+	"time"
+)
+
+// Per-step budgets carved out of the caller's deadline, so one slow
+// dependency can't consume the whole request budget
+const (
+	cacheOpTimeout   = 100 * time.Millisecond
+	pointReadTimeout = 2 * time.Second
+	writeTimeout     = 5 * time.Second
+)
+
+// stepContext derives a sub-deadline for one step. The parent's deadline
+// still wins when it is earlier.
+func stepContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, timeout)
+}
+
+// bestEffortContext is for steps that must run even if the client has gone
+// away after the main write committed (cache population, invalidation).
+// It keeps the parent's values but not its cancellation or deadline.
+func bestEffortContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), timeout)
+}
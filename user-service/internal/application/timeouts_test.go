@@ -0,0 +1,149 @@
+// internal/application/timeouts_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+// publicOperations exercises every public service method with the given ctx
+func publicOperations(svc *application.UserService, userID uint) map[string]func(ctx context.Context) error {
+	return map[string]func(ctx context.Context) error{
+		"Register": func(ctx context.Context) error {
+			_, err := svc.Register(ctx, &domain.User{Username: "bob", Email: "bob@example.com"}, "secret123", "")
+			return err
+		},
+		"ValidateRegistration": func(ctx context.Context) error {
+			return svc.ValidateRegistration(ctx, &domain.User{Username: "bob", Email: "bob@example.com"}, "secret123")
+		},
+		"Login": func(ctx context.Context) error {
+			_, err := svc.Login(ctx, "alice@example.com", "secret123")
+			return err
+		},
+		"GetUser": func(ctx context.Context) error {
+			_, err := svc.GetUser(ctx, userID)
+			return err
+		},
+		"UpdateUser": func(ctx context.Context) error {
+			_, err := svc.UpdateUser(ctx, &domain.User{ID: userID, Username: "renamed"})
+			return err
+		},
+		"DeleteUser": func(ctx context.Context) error {
+			return svc.DeleteUser(ctx, userID)
+		},
+		"ListUsers": func(ctx context.Context) error {
+			_, _, err := svc.ListUsers(ctx, 1, 10, application.ListUsersOptions{})
+			return err
+		},
+		"LookupByEmail": func(ctx context.Context) error {
+			_, err := svc.LookupByEmail(ctx, "alice@example.com", "test")
+			return err
+		},
+		"BanUser": func(ctx context.Context) error {
+			return svc.BanUser(ctx, userID, "test", 0)
+		},
+	}
+}
+
+func TestPublicMethods_PreCancelledContext(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), testsupport.NewUserCache())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for name, op := range publicOperations(svc, user.ID) {
+		t.Run(name, func(t *testing.T) {
+			if err := op(ctx); !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+		})
+	}
+
+	if _, err := repo.GetByID(context.Background(), user.ID); err != nil {
+		t.Error("no write should have happened with a cancelled context")
+	}
+}
+
+func TestPublicMethods_ShortDeadline(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	repo.ReadDelay = 200 * time.Millisecond
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	for name, op := range publicOperations(svc, user.ID) {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			err := op(ctx)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+				t.Errorf("operation ignored the deadline, took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestGetUser_SlowCacheDoesNotConsumeBudget(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	cache.GetDelay = time.Second
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache)
+
+	start := time.Now()
+	got, err := svc.GetUser(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("expected DB fallback after cache timeout, got %v", err)
+	}
+	if got.ID != user.ID {
+		t.Errorf("unexpected user %d", got.ID)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("cache lookup was not bounded, took %v", elapsed)
+	}
+}
+
+func TestGetUser_CachePopulatedAfterClientDisconnect(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+
+	// The client disconnects right after the DB read finished
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc := application.NewUserService(&cancelAfterReadRepo{UserRepository: repo, cancel: cancel}, testsupport.NewTxManager(repo), cache)
+
+	if _, err := svc.GetUser(ctx, user.ID); err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Fatal("expected the request context to be cancelled")
+	}
+	if _, err := cache.Get(context.Background(), user.ID); err != nil {
+		t.Error("cache population must survive a client disconnect")
+	}
+}
+
+// cancelAfterReadRepo cancels the request context once GetByID returns
+type cancelAfterReadRepo struct {
+	*testsupport.UserRepository
+	cancel context.CancelFunc
+}
+
+func (r *cancelAfterReadRepo) GetByID(ctx context.Context, id uint) (*domain.User, error) {
+	user, err := r.UserRepository.GetByID(ctx, id)
+	r.cancel()
+	return user, err
+}
@@ -0,0 +1,113 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"user-service/internal/domain"
+)
+
+// TokenVersionCache keeps each user's token version where the auth check
+// can read it without a database query
+type TokenVersionCache interface {
+	GetTokenVersion(ctx context.Context, userID uint) (uint, error)
+	SetTokenVersion(ctx context.Context, userID uint, version uint) error
+}
+
+// WithTokenVersionCache makes TokenVersion read from cache, filling it
+// from the database on a miss. Without it every check reads the user.
+func WithTokenVersionCache(cache TokenVersionCache) Option {
+	return func(s *UserService) {
+		s.tokenVersions = cache
+	}
+}
+
+// TokenVersion returns the version the user's tokens must carry to be
+// accepted. Tokens are stamped with it when issued, and LogoutAll raises
+// it.
+func (s *UserService) TokenVersion(ctx context.Context, userID uint) (uint, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if s.tokenVersions != nil {
+		cacheCtx, cancel := stepContext(ctx, cacheOpTimeout)
+		version, err := s.tokenVersions.GetTokenVersion(cacheCtx, userID)
+		cancel()
+		if err == nil {
+			return version, nil
+		}
+		// If error, continue to the user
+	}
+
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	if s.tokenVersions != nil {
+		cacheCtx, cancel := bestEffortContext(ctx, cacheOpTimeout)
+		_ = s.tokenVersions.SetTokenVersion(cacheCtx, userID, user.TokenVersion)
+		cancel()
+	}
+	return user.TokenVersion, nil
+}
+
+// LogoutAll signs the user out of every device: the token version goes
+// up, so every token issued so far is refused, and refresh tokens are
+// revoked so they can't mint new ones.
+func (s *UserService) LogoutAll(ctx context.Context, id uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var user *domain.User
+	txCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+	err := s.WithTransaction(txCtx, func(txCtx context.Context, tx *TxService) error {
+		var err error
+		if user, err = nextTokenVersion(txCtx, tx, id); err != nil {
+			return err
+		}
+		return tx.UpdateFields(txCtx, id, map[string]interface{}{
+			"token_version": user.TokenVersion,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to log out everywhere: %w", err)
+	}
+
+	s.cacheTokenVersion(ctx, user)
+	s.invalidateUser(ctx, user)
+	if s.sessions != nil {
+		s.afterCommit(ctx, "revoke sessions", func(ctx context.Context) error {
+			return s.sessions.RevokeUserSessions(ctx, id)
+		})
+	}
+	return nil
+}
+
+// nextTokenVersion reads the user inside tx and returns them with their
+// token version raised, for the caller to write. Reading from the primary
+// keeps a lagging replica from handing back a version some live token
+// already carries.
+func nextTokenVersion(ctx context.Context, tx *TxService, id uint) (*domain.User, error) {
+	user, err := tx.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	user.TokenVersion++
+	return user, nil
+}
+
+// cacheTokenVersion stores the user's new token version once it has
+// committed. It is set rather than dropped, so a replica read can't put
+// the old one back.
+func (s *UserService) cacheTokenVersion(ctx context.Context, user *domain.User) {
+	if s.tokenVersions == nil {
+		return
+	}
+	id, version := user.ID, user.TokenVersion
+	s.afterCommit(ctx, "set token version", func(ctx context.Context) error {
+		return s.tokenVersions.SetTokenVersion(ctx, id, version)
+	})
+}
@@ -0,0 +1,91 @@
+// internal/application/token_versions_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+// fakeTokenVersions is an in-memory application.TokenVersionCache
+type fakeTokenVersions struct {
+	mu       sync.Mutex
+	versions map[uint]uint
+}
+
+func (c *fakeTokenVersions) GetTokenVersion(ctx context.Context, userID uint) (uint, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	version, ok := c.versions[userID]
+	if !ok {
+		return 0, testsupport.ErrCacheMiss
+	}
+	return version, nil
+}
+
+func (c *fakeTokenVersions) SetTokenVersion(ctx context.Context, userID uint, version uint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.versions[userID] = version
+	return nil
+}
+
+func TestLogoutAll(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	versions := &fakeTokenVersions{versions: make(map[uint]uint)}
+	cache := testsupport.NewUserCache()
+	revoker := &fakeRevoker{}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache,
+		application.WithTokenVersionCache(versions),
+		application.WithSessionRevoker(revoker),
+	)
+	ctx := context.Background()
+
+	// A miss is filled from the user
+	if version, err := svc.TokenVersion(ctx, user.ID); err != nil || version != 0 {
+		t.Fatalf("expected version 0, got %d (%v)", version, err)
+	}
+	if _, cached := versions.versions[user.ID]; !cached {
+		t.Error("expected the version cached after a miss")
+	}
+
+	for want := uint(1); want <= 2; want++ {
+		if err := svc.LogoutAll(ctx, user.ID); err != nil {
+			t.Fatalf("logout all: %v", err)
+		}
+		svc.Wait()
+		if stored, _ := repo.User(user.ID); stored.TokenVersion != want {
+			t.Errorf("expected version %d stored, got %d", want, stored.TokenVersion)
+		}
+		// Read from the cache, which was set rather than cleared
+		reads := repo.Calls("GetByID")
+		if version, err := svc.TokenVersion(ctx, user.ID); err != nil || version != want {
+			t.Errorf("expected version %d, got %d (%v)", want, version, err)
+		}
+		if repo.Calls("GetByID") != reads {
+			t.Error("expected the version read from the cache")
+		}
+	}
+	if len(revoker.revoked) != 2 || len(cache.DeletedIDs()) != 2 {
+		t.Errorf("expected sessions revoked and the user uncached each time, got %v and %v", revoker.revoked, cache.DeletedIDs())
+	}
+
+	// Saving the profile from a stale copy keeps the version
+	user.FirstName = "Alice"
+	if _, err := svc.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if stored, _ := repo.User(user.ID); stored.TokenVersion != 2 {
+		t.Errorf("expected the update to keep version 2, got %d", stored.TokenVersion)
+	}
+
+	if err := svc.LogoutAll(ctx, 404); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
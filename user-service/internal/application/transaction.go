@@ -0,0 +1,161 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+// TxService is the set of writes a multi-step flow can make inside
+// WithTransaction, every one of them in the same transaction. It is a
+// distinct type that only holds the transaction's repositories, so code
+// written against it cannot reach the non-transactional ones by mistake.
+type TxService struct {
+	users   UserRepository
+	audit   AuditLogger
+	invites InviteRepository
+
+	recoveryCodes RecoveryCodeRepository
+	accessTokens  AccessTokenRepository
+}
+
+// WithTransaction runs fn in one transaction from the TransactionManager.
+// If fn returns an error or panics, none of its writes are kept. Post-commit
+// work such as cache invalidation belongs after WithTransaction returns.
+func (s *UserService) WithTransaction(ctx context.Context, fn func(ctx context.Context, tx *TxService) error) error {
+	return s.txManager.ExecuteInTx(ctx, func(db *gorm.DB) error {
+		tx := &TxService{users: s.repo.WithTx(db)}
+		if s.audit != nil {
+			tx.audit = s.audit.WithTx(db)
+		}
+		if s.invites != nil {
+			tx.invites = s.invites.WithTx(db)
+		}
+		if s.recoveryCodes != nil {
+			tx.recoveryCodes = s.recoveryCodes.WithTx(db)
+		}
+		if s.accessTokens != nil {
+			tx.accessTokens = s.accessTokens.WithTx(db)
+		}
+		return fn(ctx, tx)
+	})
+}
+
+func (t *TxService) GetUser(ctx context.Context, id uint) (*domain.User, error) {
+	return t.users.GetByID(ctx, id)
+}
+
+// GetUserUnscoped is GetUser including soft-deleted accounts
+func (t *TxService) GetUserUnscoped(ctx context.Context, id uint) (*domain.User, error) {
+	return t.users.GetByIDUnscoped(ctx, id)
+}
+
+func (t *TxService) CreateUser(ctx context.Context, user *domain.User, passwordHash string) error {
+	return t.users.Create(ctx, user, passwordHash)
+}
+
+func (t *TxService) UpdateUser(ctx context.Context, user *domain.User) error {
+	return t.users.Update(ctx, user)
+}
+
+func (t *TxService) UpdateFields(ctx context.Context, id uint, fields map[string]interface{}) error {
+	return t.users.UpdateFields(ctx, id, fields)
+}
+
+// UpdateFieldsIfStatus applies fields only while the user is in status,
+// reporting whether it did
+func (t *TxService) UpdateFieldsIfStatus(ctx context.Context, id uint, status domain.UserStatus, fields map[string]interface{}) (bool, error) {
+	return t.users.UpdateFieldsIfStatus(ctx, id, status, fields)
+}
+
+func (t *TxService) SoftDelete(ctx context.Context, id uint) error {
+	return t.users.SoftDelete(ctx, id)
+}
+
+// HardDelete removes the row for good
+func (t *TxService) HardDelete(ctx context.Context, id uint) error {
+	return t.users.HardDelete(ctx, id)
+}
+
+// Restore undoes SoftDelete
+func (t *TxService) Restore(ctx context.Context, id uint) error {
+	return t.users.Restore(ctx, id)
+}
+
+// LockConflicts reports which of username and email another account uses,
+// holding both values until the transaction ends
+func (t *TxService) LockConflicts(ctx context.Context, id uint, username, email string) ([]string, error) {
+	return t.users.LockConflicts(ctx, id, username, email)
+}
+
+// CreateInvite, RevokeInvite and ConsumeInvite need an InviteRepository;
+// the service checks for one before using them
+func (t *TxService) CreateInvite(ctx context.Context, invite *domain.Invite) error {
+	return t.invites.Create(ctx, invite)
+}
+
+func (t *TxService) RevokeInvite(ctx context.Context, code string, at time.Time) error {
+	return t.invites.Revoke(ctx, code, at)
+}
+
+// ConsumeInvite uses up one redemption of code, failing with the reason
+// it can't be used
+func (t *TxService) ConsumeInvite(ctx context.Context, code string, now time.Time) error {
+	return t.invites.Consume(ctx, code, now)
+}
+
+// ReplaceRecoveryCodes and ConsumeRecoveryCode need a
+// RecoveryCodeRepository; the service checks for one before using them
+func (t *TxService) ReplaceRecoveryCodes(ctx context.Context, userID uint, hashes []string, at time.Time) error {
+	return t.recoveryCodes.Replace(ctx, userID, hashes, at)
+}
+
+// ConsumeRecoveryCode uses up the code with hash, reporting false when the
+// user has no such unused code
+func (t *TxService) ConsumeRecoveryCode(ctx context.Context, userID uint, hash string, at time.Time) (bool, error) {
+	return t.recoveryCodes.Consume(ctx, userID, hash, at)
+}
+
+// DeleteRecoveryCodes removes every code the user has. Without a
+// RecoveryCodeRepository there are none, and it does nothing.
+func (t *TxService) DeleteRecoveryCodes(ctx context.Context, userID uint) error {
+	if t.recoveryCodes == nil {
+		return nil
+	}
+	return t.recoveryCodes.DeleteByUser(ctx, userID)
+}
+
+// ListAccessTokens, CreateAccessToken and DeleteAccessToken need an
+// AccessTokenRepository; the service checks for one before using them
+func (t *TxService) ListAccessTokens(ctx context.Context, userID uint) ([]*domain.AccessToken, error) {
+	return t.accessTokens.ListByUser(ctx, userID)
+}
+
+func (t *TxService) CreateAccessToken(ctx context.Context, token *domain.AccessToken, hash string) error {
+	return t.accessTokens.Create(ctx, token, hash)
+}
+
+func (t *TxService) DeleteAccessToken(ctx context.Context, userID, id uint) error {
+	return t.accessTokens.Delete(ctx, userID, id)
+}
+
+// DeleteAccessTokens removes every token the user holds. Without an
+// AccessTokenRepository there are none, and it does nothing.
+func (t *TxService) DeleteAccessTokens(ctx context.Context, userID uint) error {
+	if t.accessTokens == nil {
+		return nil
+	}
+	return t.accessTokens.DeleteByUser(ctx, userID)
+}
+
+// Audit records entry with the other writes; without an audit logger it
+// does nothing
+func (t *TxService) Audit(ctx context.Context, entry *AuditEntry) error {
+	if t.audit == nil {
+		return nil
+	}
+	return t.audit.Record(ctx, entry)
+}
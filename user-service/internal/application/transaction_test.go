@@ -0,0 +1,84 @@
+// internal/application/transaction_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+)
+
+func TestWithTransaction_FailedSecondWriteRollsBackTheFirst(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	repo.AddUser("bob@example.com", "secret123")
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	err := svc.WithTransaction(context.Background(), func(ctx context.Context, tx *application.TxService) error {
+		if err := tx.UpdateFields(ctx, alice.ID, map[string]interface{}{"first_name": "Alice"}); err != nil {
+			return err
+		}
+		user, err := tx.GetUser(ctx, alice.ID)
+		if err != nil {
+			return err
+		}
+		if user.FirstName != "Alice" {
+			t.Error("the first write should be visible inside the transaction")
+		}
+		// The unique email fails the second write
+		user.Email = "bob@example.com"
+		return tx.UpdateUser(ctx, user)
+	})
+	if !errors.Is(err, domain.ErrDuplicateUser) {
+		t.Fatalf("expected the second write's error, got %v", err)
+	}
+
+	stored, _ := repo.GetByID(context.Background(), alice.ID)
+	if stored.FirstName != "" || stored.Email != "alice@example.com" {
+		t.Errorf("expected nothing persisted, got %q / %q", stored.FirstName, stored.Email)
+	}
+}
+
+func TestUpdateUser_EmailChangeIsAuditedAtomically(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	audit := &fakeAuditLogger{err: errors.New("audit table unavailable")}
+	cache := testsupport.NewUserCache()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache,
+		application.WithAuditLogger(audit),
+	)
+
+	changed := *alice
+	changed.Email = "alice2@example.com"
+	changed.FirstName = "Alice"
+	if _, err := svc.UpdateUser(context.Background(), &changed); err == nil {
+		t.Fatal("expected the failed audit write to fail the update")
+	}
+	stored, _ := repo.GetByID(context.Background(), alice.ID)
+	if stored.Email != "alice@example.com" || stored.FirstName != "" {
+		t.Errorf("expected the update rolled back with its audit entry, got %q / %q", stored.Email, stored.FirstName)
+	}
+	if len(cache.DeletedEmails()) != 0 {
+		t.Error("nothing committed, so nothing should have been invalidated")
+	}
+
+	audit.err = nil
+	if _, err := svc.UpdateUser(context.Background(), &changed); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got := auditActions(audit); !equalStrings(got, []string{application.AuditEmailChanged}) {
+		t.Errorf("expected one email change entry, got %v", got)
+	}
+
+	// Changes that keep the email aren't audited
+	changed.LastName = "Smith"
+	if _, err := svc.UpdateUser(context.Background(), &changed); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if got := auditActions(audit); len(got) != 1 {
+		t.Errorf("expected no new audit entry, got %v", got)
+	}
+}
@@ -2,10 +2,13 @@ package application
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 	"user-service/internal/domain"
+	"user-service/internal/normalize"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -13,7 +16,11 @@ import (
 
 type UserCache interface {
 	Set(ctx context.Context, user *domain.User) error
+	// SetWithTTL caches user for ttl instead of the configured TTL
+	SetWithTTL(ctx context.Context, user *domain.User, ttl time.Duration) error
 	Get(ctx context.Context, userID uint) (*domain.User, error)
+	// GetMany returns the cached users among userIDs, keyed by ID
+	GetMany(ctx context.Context, userIDs []uint) (map[uint]*domain.User, error)
 	Delete(ctx context.Context, userID uint) error
 	SetByEmail(ctx context.Context, email string, user *domain.User) error
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
@@ -21,14 +28,49 @@ type UserCache interface {
 }
 
 type UserRepository interface {
-	Create(ctx context.Context, user *domain.User) error
+	// Create inserts user with passwordHash, the bcrypt hash of its password
+	Create(ctx context.Context, user *domain.User, passwordHash string) error
 	GetByEmail(ctx context.Context, email string) (*domain.User, error)
+	// GetByEmailUnscoped is GetByEmail including soft-deleted accounts
+	GetByEmailUnscoped(ctx context.Context, email string) (*domain.User, error)
 	GetByID(ctx context.Context, id uint) (*domain.User, error)
+	// GetByIDUnscoped is GetByID including soft-deleted accounts
+	GetByIDUnscoped(ctx context.Context, id uint) (*domain.User, error)
+	// GetCredentials is the only way to read a password hash. It must read
+	// the primary, so a password that was just changed is the one checked.
+	GetCredentials(ctx context.Context, id uint) (*domain.Credentials, error)
+	// GetByIDs returns the users that exist among ids, in no particular order
+	GetByIDs(ctx context.Context, ids []uint) ([]*domain.User, error)
 	Update(ctx context.Context, user *domain.User) error
 	UpdateFields(ctx context.Context, id uint, fields map[string]interface{}) error
+	UpdateFieldsIfStatus(ctx context.Context, id uint, status domain.UserStatus, fields map[string]interface{}) (bool, error)
 	SoftDelete(ctx context.Context, id uint) error
+	// HardDelete removes the row for good, soft-deleted or not
+	HardDelete(ctx context.Context, id uint) error
+	// Restore undoes SoftDelete, failing with ErrUserNotFound unless the
+	// user is soft-deleted
+	Restore(ctx context.Context, id uint) error
 	ExistsEmail(ctx context.Context, email string) (bool, error)
-	List(ctx context.Context, offset, limit int) ([]*domain.User, int64, error)
+	// Exists reports whether the user is there and not erased
+	Exists(ctx context.Context, id uint) (bool, error)
+	// List pages through the users filter keeps, in sort order, refusing a
+	// sort field it doesn't know. The total counts every user it keeps.
+	List(ctx context.Context, filter domain.UserFilter, offset, limit int, sort domain.UserSort) ([]*domain.User, int64, error)
+	// ListWithDeleted is List with soft-deleted users included
+	ListWithDeleted(ctx context.Context, filter domain.UserFilter, offset, limit int, sort domain.UserSort) ([]*domain.User, int64, error)
+	ListPendingDeletion(ctx context.Context, requestedBefore time.Time, limit int) ([]*domain.User, error)
+	// PagePendingDeletion pages through every account awaiting erasure,
+	// oldest request first. The total counts all of them.
+	PagePendingDeletion(ctx context.Context, offset, limit int) ([]*domain.User, int64, error)
+	// SearchByUsername pages through non-erased users whose username starts
+	// with prefix, case-insensitively, in ID order after afterID. A
+	// non-empty statuses limits matches to those states.
+	SearchByUsername(ctx context.Context, prefix string, afterID uint, limit int, statuses []domain.UserStatus) ([]*domain.User, error)
+	// LockConflicts reports which of username (compared case-insensitively)
+	// and email another account already uses, and holds both values until
+	// the transaction ends so a concurrent claim waits for this one. Empty
+	// values are skipped. Only meaningful inside a transaction.
+	LockConflicts(ctx context.Context, id uint, username, email string) ([]string, error)
 	WithTx(tx *gorm.DB) UserRepository
 }
 
@@ -36,37 +78,251 @@ type TransactionManager interface {
 	ExecuteInTx(ctx context.Context, fn func(tx *gorm.DB) error) error
 }
 
+// UserServiceInterface is the contract the HTTP layer depends on, so handlers
+// can be exercised against a mock instead of a real repository and cache.
+type UserServiceInterface interface {
+	// Register creates user. replayed reports that the signup conflicted
+	// with an account it had itself just created, which user then holds.
+	// inviteCode is only read while registration is invite-only.
+	Register(ctx context.Context, user *domain.User, password, inviteCode string) (replayed bool, err error)
+	Login(ctx context.Context, email, password string) (*domain.User, error)
+	GetUser(ctx context.Context, id uint) (*domain.User, error)
+	UserExists(ctx context.Context, id uint) (bool, error)
+	// UpdateUser saves user's profile and returns the fields that changed
+	UpdateUser(ctx context.Context, user *domain.User) (changed []string, err error)
+	// UpdateProfile sets only the profile fields given, by JSON name
+	UpdateProfile(ctx context.Context, id uint, fields map[string]interface{}) (*domain.User, []string, error)
+	DeleteUser(ctx context.Context, id uint) error
+	DeleteUserWithPassword(ctx context.Context, id uint, password string) error
+	ListUsers(ctx context.Context, page, pageSize int, opts ListUsersOptions) ([]*domain.User, int64, error)
+	ValidateRegistration(ctx context.Context, user *domain.User, password string) error
+	LookupByEmail(ctx context.Context, email, caller string) (*EmailLookup, error)
+	ListPendingDeletions(ctx context.Context, page, pageSize int) ([]*PendingDeletion, int64, error)
+	CancelDeletion(ctx context.Context, id uint, actorID uint, reason string) error
+	ExpediteDeletion(ctx context.Context, id uint, actorID uint, reason string) error
+	GetUserUnscoped(ctx context.Context, id uint) (*domain.User, error)
+	RestoreUser(ctx context.Context, id uint, reason string, actorID uint) (*domain.User, error)
+	PurgeUser(ctx context.Context, id uint, confirmEmail, reason string, actorID uint) error
+	UpdateNotificationPreferences(ctx context.Context, id uint, update NotificationPreferencesUpdate) (*domain.NotificationPreferences, error)
+	UpdatePreferences(ctx context.Context, id uint, patch map[string]interface{}) (domain.Preferences, error)
+	CreateInvite(ctx context.Context, invite *domain.Invite) error
+	RevokeInvite(ctx context.Context, code, reason string) error
+	GenerateRecoveryCodes(ctx context.Context, id uint, password string) ([]string, error)
+	Recover(ctx context.Context, email, code string) (*domain.User, error)
+	RecoveryCodesRemaining(ctx context.Context, id uint) (int, error)
+	ForgotPassword(ctx context.Context, email string) error
+	ResetForgottenPassword(ctx context.Context, token, password string) error
+	RequestEmailChange(ctx context.Context, id uint, change EmailChange) error
+	ConfirmEmailChange(ctx context.Context, token string) (*domain.User, error)
+	// CreateAccessToken returns the token's secret, which isn't kept
+	CreateAccessToken(ctx context.Context, token *domain.AccessToken) (string, error)
+	ListAccessTokens(ctx context.Context, userID uint) ([]*domain.AccessToken, error)
+	RevokeAccessToken(ctx context.Context, userID, id uint) error
+	ChangePassword(ctx context.Context, id uint, change PasswordChange) error
+	ForcePasswordReset(ctx context.Context, ids []uint, reason string, actorID uint) (*ForcedResets, error)
+	StartBulkJob(ctx context.Context, job *domain.BulkJob) error
+	GetBulkJob(ctx context.Context, id uint) (*domain.BulkJob, error)
+	BulkJobReportURL(ctx context.Context, job *domain.BulkJob) (string, error)
+	Notices(ctx context.Context, user *domain.User) ([]domain.Notice, error)
+	DismissNotice(ctx context.Context, userID uint, code string) error
+	AddNotice(ctx context.Context, notice *domain.UserNotice) error
+	RemoveNotice(ctx context.Context, userID uint, code, removedBy string) error
+	ExportSnapshot(ctx context.Context, id uint, reason string) (*SignedSnapshot, error)
+	ImportSnapshot(ctx context.Context, bundle *SignedSnapshot, overwrite bool, reason string) (*SnapshotImport, error)
+	// TokenVersion is the version new tokens for the user are stamped with
+	TokenVersion(ctx context.Context, userID uint) (uint, error)
+	LogoutAll(ctx context.Context, id uint) error
+	StartSession(ctx context.Context, userID uint) (*domain.Session, error)
+	ListSessions(ctx context.Context, userID uint) ([]*domain.Session, error)
+	RevokeSession(ctx context.Context, userID uint, id string) error
+	UseSession(ctx context.Context, userID uint, id string) error
+	SessionActive(ctx context.Context, id string) (bool, error)
+}
+
+var _ UserServiceInterface = (*UserService)(nil)
+
 type UserService struct {
-	repo      UserRepository
-	txManager TransactionManager
-	cache     UserCache
+	repo          UserRepository
+	txManager     TransactionManager
+	cache         UserCache
+	cacheObserver CacheObserver
+	writeMarker   WriteMarker
+	tokenVersions TokenVersionCache
+	lastLogin     *LastLoginRecorder
+	events        EventPublisher
+	sessions      SessionRevoker
+	audit         AuditLogger
+	blocklist     UserBlocklist
+	mailer        Mailer
+	devices       DeviceStore
+	invites       InviteRepository
+
+	recoveryCodes RecoveryCodeRepository
+
+	passwordResets PasswordResetStore
+	resetNotifier  PasswordResetNotifier
+
+	emailChanges        EmailChangeStore
+	emailChangeNotifier EmailChangeNotifier
+
+	sessionRepo     SessionRepository
+	sessionTTL      time.Duration
+	revokedSessions RevokedSessionCache
+
+	accessTokens     AccessTokenRepository
+	accessTokenUsage *LastLoginRecorder
+
+	bulkJobs BulkJobRepository
+	reports  ReportStore
+
+	notices NoticeRepository
+	// termsVersion and termsUpdatedAt drive the terms_updated notice
+	termsVersion   string
+	termsUpdatedAt time.Time
+
+	registrationMode RegistrationMode
+
+	// disabledAlerts are the security alerts switched off by config
+	disabledAlerts map[SecurityAlert]bool
+
+	loginAttempts LoginAttemptStore
+
+	// loginHooks run in order around the credential check
+	loginHooks       []LoginHook
+	loginHookTimeout time.Duration
+
+	// now and deletionGracePeriod drive the erasure workflow; now is
+	// swappable so tests can fast-forward the grace period
+	now                 func() time.Time
+	deletionGracePeriod time.Duration
+	purgeRetention      time.Duration
+
+	// registerReplayWindow is how recent an account must be for a
+	// conflicting signup to be answered as a retry of it
+	registerReplayWindow time.Duration
+
+	// deletedAccountHintWindow is how recently an account must have been
+	// deleted for a signup reusing its email to be told so
+	deletedAccountHintWindow time.Duration
+
+	// mailDomains rejects signups from domains that can't receive mail;
+	// nil skips the check
+	mailDomains MailDomainChecker
+
+	// snapshotSecret signs exported snapshots; without it they're disabled
+	snapshotSecret []byte
+
+	// background tracks post-commit cleanup retries still in flight
+	background sync.WaitGroup
+}
+
+// Option configures optional UserService dependencies
+type Option func(*UserService)
+
+// WithLastLoginRecorder makes Login record last_login asynchronously
+func WithLastLoginRecorder(recorder *LastLoginRecorder) Option {
+	return func(s *UserService) {
+		s.lastLogin = recorder
+	}
+}
+
+// WithEventPublisher publishes domain events after successful mutations
+func WithEventPublisher(publisher EventPublisher) Option {
+	return func(s *UserService) {
+		s.events = publisher
+	}
 }
 
-func NewUserService(repo UserRepository, txManager TransactionManager, cache UserCache) *UserService {
-	return &UserService{
+// WithSessionRevoker revokes outstanding tokens when an account goes away
+func WithSessionRevoker(revoker SessionRevoker) Option {
+	return func(s *UserService) {
+		s.sessions = revoker
+	}
+}
+
+func NewUserService(repo UserRepository, txManager TransactionManager, cache UserCache, opts ...Option) *UserService {
+	s := &UserService{
 		repo:      repo,
 		txManager: txManager,
 		cache:     cache,
+
+		registrationMode:     RegistrationOpen,
+		disabledAlerts:       make(map[SecurityAlert]bool),
+		now:                  time.Now,
+		deletionGracePeriod:  DefaultDeletionGracePeriod,
+		purgeRetention:       DefaultPurgeRetention,
+		registerReplayWindow: DefaultRegisterReplayWindow,
+
+		deletedAccountHintWindow: DefaultDeletedAccountHintWindow,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	// last_login is always recorded last, once no configured hook has
+	// denied the login
+	s.loginHooks = append(s.loginHooks, &lastLoginHook{
+		recorder: s.lastLogin,
+		repo:     s.repo,
+	})
+	if s.loginHookTimeout <= 0 {
+		s.loginHookTimeout = DefaultLoginHookTimeout
+	}
+
+	return s
 }
 
-func (s *UserService) Register(ctx context.Context, user *domain.User) error {
-	// Trim and validate
-	user.Email = strings.ToLower(strings.TrimSpace(user.Email))
-	user.Username = strings.TrimSpace(user.Username)
-	password := strings.TrimSpace(user.Password)
+func (s *UserService) Register(ctx context.Context, user *domain.User, password, inviteCode string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 
-	if password == "" {
-		return fmt.Errorf("password is required")
+	switch s.registrationMode {
+	case RegistrationClosed:
+		return false, ErrRegistrationClosed
+	case RegistrationInvite:
+		inviteCode = normalizeInviteCode(inviteCode)
+		if inviteCode == "" {
+			return false, ErrInviteRequired
+		}
+		if s.invites == nil {
+			return false, ErrInvitesNotConfigured
+		}
+	default:
+		inviteCode = ""
 	}
 
-	// Check if email exists
-	exists, err := s.repo.ExistsEmail(ctx, user.Email)
-	if err != nil {
-		return fmt.Errorf("failed to check email: %w", err)
+	// Normalize and validate; shared with the dry-run endpoint
+	err := s.validateRegistration(ctx, user, password, false)
+	if err == nil {
+		err = s.createUser(ctx, user, password, nil, inviteCode)
 	}
-	if exists {
-		return fmt.Errorf("email already registered")
+	if err == nil {
+		// The client's next request is usually GET /users/me
+		s.warmCache(ctx, user, CacheWarmRegister)
+	}
+
+	// A client that timed out and retried would otherwise be told its own
+	// account is a conflict
+	if isRegistrationConflict(err) {
+		if existing := s.recentRegistration(ctx, user, password); existing != nil {
+			*user = *existing
+			return true, nil
+		}
+	}
+	return false, err
+}
+
+// createUser hashes the already validated password and inserts the user,
+// together with entry when set. A non-empty inviteCode is redeemed in the
+// same transaction, so a failed insert doesn't use it up.
+func (s *UserService) createUser(ctx context.Context, user *domain.User, password string, entry *AuditEntry, inviteCode string) error {
+	password = normalize.Password(password)
+
+	// Don't burn a bcrypt round on a request that's already gone
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
 	// Hash password
@@ -74,20 +330,26 @@ func (s *UserService) Register(ctx context.Context, user *domain.User) error {
 	if err != nil {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
-	user.Password = string(hashedPassword)
 
 	// Use transaction for complex operations
-	err = s.txManager.ExecuteInTx(ctx, func(tx *gorm.DB) error {
-		// Create user
-		userRepo := s.repo.WithTx(tx)
-		if err := userRepo.Create(ctx, user); err != nil {
+	txCtx, cancel := stepContext(ctx, writeTimeout)
+	defer cancel()
+
+	err = s.WithTransaction(txCtx, func(txCtx context.Context, tx *TxService) error {
+		if inviteCode != "" {
+			if err := tx.ConsumeInvite(txCtx, inviteCode, s.now().UTC()); err != nil {
+				return err
+			}
+		}
+		if err := tx.CreateUser(txCtx, user, string(hashedPassword)); err != nil {
 			return err
 		}
 
-		// Could add more operations here (e.g., create profile, send event, etc.)
-		// Example: create default settings, audit log, etc.
-
-		return nil
+		if entry == nil {
+			return nil
+		}
+		entry.TargetID = user.ID
+		return tx.Audit(txCtx, entry)
 	})
 
 	if err != nil {
@@ -98,75 +360,464 @@ func (s *UserService) Register(ctx context.Context, user *domain.User) error {
 }
 
 func (s *UserService) Login(ctx context.Context, email, password string) (*domain.User, error) {
-	email = strings.ToLower(strings.TrimSpace(email))
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	email, password = normalize.Email(email), normalize.Password(password)
+	req := &LoginRequest{Email: email, Password: password}
 
-	user, err := s.repo.GetByEmail(ctx, email)
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	user, err := s.repo.GetByEmail(readCtx, email)
+	cancel()
 	if err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		if errors.Is(err, domain.ErrUserNotFound) {
+			s.recordLoginAttempt(ctx, 0, false)
+			s.afterLoginFailure(ctx, req, ErrInvalidCredentials)
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	req.User = user
+
+	if err := s.runLoginHooks(ctx, "BeforeCredentialCheck", func(ctx context.Context, hook LoginHook) error {
+		return hook.BeforeCredentialCheck(ctx, req)
+	}); err != nil {
+		return nil, s.loginFailed(ctx, req, err)
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	credentials, err := s.credentials(ctx, user.ID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid credentials")
+		return nil, err
+	}
+	req.Credentials = credentials
+
+	if !passwordMatches(credentials, password) {
+		return nil, s.loginFailed(ctx, req, ErrInvalidCredentials)
 	}
 
-	// Update last login time
-	now := time.Now()
-	if err := s.repo.UpdateFields(ctx, user.ID, map[string]interface{}{
-		"last_login": now,
+	// Only reveal the ban to someone who proved they own the account
+	if user.IsBanned() {
+		return nil, s.loginFailed(ctx, req, ErrUserBanned)
+	}
+
+	firstLogin := user.LastLogin == nil
+	now := s.now().UTC()
+	user.LastLogin = &now
+
+	// Hooks such as the breached-password check, then last_login, which
+	// only a login no hook denied gets
+	if err := s.runLoginHooks(ctx, "AfterSuccess", func(ctx context.Context, hook LoginHook) error {
+		return hook.AfterSuccess(ctx, req)
 	}); err != nil {
-		fmt.Printf("Failed to update last login: %v\n", err)
+		return nil, s.loginFailed(ctx, req, err)
+	}
+
+	// Logging in during the grace period means the user changed their mind
+	if user.IsPendingDeletion() {
+		if err := s.cancelDeletion(ctx, user, user.ID, "user logged in during grace period"); err != nil {
+			return nil, fmt.Errorf("failed to cancel deletion request: %w", err)
+		}
+		user.Status = domain.StatusActive
+		user.DeletionRequestedAt = nil
 	}
 
-	nowCopy := now
-	user.LastLogin = &nowCopy
+	s.recordLoginAttempt(ctx, user.ID, true)
+	s.checkNewDevice(ctx, user, firstLogin)
+	s.warmCache(ctx, user, CacheWarmLogin)
 	return user, nil
 }
 
+// loginFailed records a rejected login and notifies hooks, returning cause
+func (s *UserService) loginFailed(ctx context.Context, req *LoginRequest, cause error) error {
+	if errors.Is(cause, context.Canceled) || errors.Is(cause, context.DeadlineExceeded) {
+		return cause
+	}
+	s.recordLoginAttempt(ctx, req.User.ID, false)
+	s.afterLoginFailure(ctx, req, cause)
+	return cause
+}
+
 func (s *UserService) GetUser(ctx context.Context, id uint) (*domain.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Try cache first
 	if s.cache != nil {
-		user, err := s.cache.Get(ctx, id)
-		if err == nil {
+		cacheCtx, cancel := stepContext(ctx, cacheOpTimeout)
+		user, err := s.cache.Get(cacheCtx, id)
+		cancel()
+		if err == nil && !isErased(user) {
+			s.observeCacheReads(1, 0)
 			return user, nil
 		}
+		s.observeCacheReads(0, 1)
 		// If error, continue to database
 	}
 
+	// A replica may not have caught up with a recent write yet
+	recent := s.writtenRecently(ctx, id)
+	readCtx := ctx
+	if recent {
+		readCtx = WithPrimaryRead(ctx)
+	}
+
 	// Get from database
-	user, err := s.repo.GetByID(ctx, id)
+	readCtx, cancel := stepContext(readCtx, pointReadTimeout)
+	user, err := s.repo.GetByID(readCtx, id)
+	cancel()
 	if err != nil {
 		return nil, err
 	}
+	// Never serve, or re-cache, an erased row that slipped past the scope
+	if isErased(user) {
+		return nil, domain.ErrUserNotFound
+	}
 
-	// Update cache
+	// Update cache even if the client disconnected after the read. A write
+	// that landed while we read from a replica may have made the row stale,
+	// so look again and keep it only briefly if so.
 	if s.cache != nil {
-		_ = s.cache.Set(ctx, user)
+		if !recent {
+			recent = s.writtenRecently(ctx, id)
+		}
+		s.cacheUser(ctx, user, recent, false)
 	}
 
 	return user, nil
 }
 
-func (s *UserService) UpdateUser(ctx context.Context, user *domain.User) error {
-	err := s.repo.Update(ctx, user)
+// UserExists reports whether the account exists, answering from the cache
+// when it can and otherwise with an existence query rather than a full read
+func (s *UserService) UserExists(ctx context.Context, id uint) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	if s.cache != nil {
+		cacheCtx, cancel := stepContext(ctx, cacheOpTimeout)
+		user, err := s.cache.Get(cacheCtx, id)
+		cancel()
+		if err == nil && !isErased(user) {
+			return true, nil
+		}
+	}
+
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	defer cancel()
+	return s.repo.Exists(readCtx, id)
+}
+
+// UpdateUser saves the user's profile: it is UpdateProfile with every
+// profile field (names, username, email) taken from user. Everything else
+// is kept as stored, since user is usually a cached copy that may be
+// stale. On success user holds the saved record.
+func (s *UserService) UpdateUser(ctx context.Context, user *domain.User) (changed []string, err error) {
+	saved, changed, err := s.UpdateProfile(ctx, user.ID, map[string]interface{}{
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+		"username":   user.Username,
+		"email":      user.Email,
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	*user = *saved
+	return changed, nil
+}
+
+// profileFields are the fields UpdateProfile writes, by their JSON names,
+// which are also their columns
+var profileFields = map[string]func(user *domain.User, value string){
+	"first_name": func(user *domain.User, value string) { user.FirstName = value },
+	"last_name":  func(user *domain.User, value string) { user.LastName = value },
+	"username":   func(user *domain.User, value string) { user.Username = value },
+	"email":      func(user *domain.User, value string) { user.Email = value },
+}
+
+// UpdateProfile sets the profile fields in fields, keyed by their JSON
+// names, and leaves the rest alone; an empty string clears a field. Only
+// the columns that change are written. Taking a username or email another
+// account already uses fails with a ValidationError wrapping
+// domain.ErrDuplicateUser. The check runs in the write's transaction with
+// the new values locked, so concurrent claims get a deterministic answer.
+// A new email starts out unverified and is audited with the same commit;
+// the old address gets a security alert.
+//
+// It returns the saved record and the fields that differ from what was
+// stored, by their JSON names. When none do nothing is written or
+// invalidated.
+func (s *UserService) UpdateProfile(ctx context.Context, id uint, fields map[string]interface{}) (*domain.User, []string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	values := make(map[string]string, len(fields))
+	invalid := make(map[string]string)
+	for name, value := range fields {
+		str, ok := value.(string)
+		switch {
+		case profileFields[name] == nil:
+			invalid[name] = "can't be updated here"
+		case !ok:
+			invalid[name] = name + " must be a string"
+		default:
+			values[name] = str
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, nil, &ValidationError{Fields: invalid}
+	}
+	return s.updateProfile(ctx, id, values, false)
+}
+
+// updateProfile is UpdateProfile once fields have been checked. A changed
+// email counts as verified if emailVerified, as when the user followed a
+// link sent to it, and unverified otherwise.
+func (s *UserService) updateProfile(ctx context.Context, id uint, values map[string]string, emailVerified bool) (*domain.User, []string, error) {
+	var previous, saved *domain.User
+	var changed []string
+	var username, email string
+	writeCtx, cancel := stepContext(ctx, writeTimeout)
+	err := s.WithTransaction(writeCtx, func(ctx context.Context, tx *TxService) error {
+		current, err := tx.GetUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		previous, saved = current, current
+		username, email = "", ""
+		updated := *current
+		for name, value := range values {
+			profileFields[name](&updated, value)
+		}
+		changed = changedProfileFields(current, &updated)
+		if len(changed) == 0 {
+			return nil
+		}
 
-	// Invalidate cache
+		writes := make(map[string]interface{}, len(changed)+1)
+		for _, name := range changed {
+			writes[name] = values[name]
+		}
+		// Only claim what changes, so accounts that predate the check can
+		// still edit other fields
+		if !strings.EqualFold(updated.Username, current.Username) {
+			username = updated.Username
+		}
+		reason := "changed by user"
+		if updated.Email != current.Email {
+			email = updated.Email
+			writes["email_verified_at"] = nil
+			if emailVerified {
+				writes["email_verified_at"] = s.now().UTC()
+				reason = "confirmed by email"
+			}
+		}
+		taken, err := tx.LockConflicts(ctx, id, username, email)
+		if err != nil {
+			return err
+		}
+		if len(taken) > 0 {
+			return duplicateFieldsError(taken)
+		}
+		if err := tx.UpdateFields(ctx, id, writes); err != nil {
+			return err
+		}
+		if saved, err = tx.GetUser(ctx, id); err != nil {
+			return err
+		}
+
+		if email == "" {
+			return nil
+		}
+		return tx.Audit(ctx, &AuditEntry{
+			Action:    AuditEmailChanged,
+			ActorID:   id,
+			TargetID:  id,
+			Reason:    reason,
+			CreatedAt: s.now().UTC(),
+		})
+	})
+	cancel()
+	if errors.Is(err, domain.ErrDuplicateUser) {
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			// The unique index caught what the check couldn't
+			return nil, nil, s.claimConflict(ctx, id, username, email)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(changed) == 0 {
+		return saved, changed, nil
+	}
+
+	// Invalidate cache; the write committed so this must not be skipped
+	s.markWritten(ctx, id)
 	if s.cache != nil {
-		_ = s.cache.Delete(ctx, user.ID)
-		_ = s.cache.DeleteByEmail(ctx, user.Email)
+		cacheCtx, cancel := bestEffortContext(ctx, cacheOpTimeout)
+		_ = s.cache.Delete(cacheCtx, id)
+		_ = s.cache.DeleteByEmail(cacheCtx, saved.Email)
+		if previous.Email != saved.Email {
+			_ = s.cache.DeleteByEmail(cacheCtx, previous.Email)
+		}
+		cancel()
 	}
 
-	return nil
+	// Tell the old address, in case whoever changed it wasn't the owner
+	if previous.Email != saved.Email {
+		s.sendSecurityAlert(ctx, AlertEmailChanged, previous, previous.Email, ClientInfoFrom(ctx), alertDetails{NewEmail: saved.Email})
+	}
+
+	return saved, changed, nil
 }
 
-func (s *UserService) DeleteUser(ctx context.Context, id uint) error {
-	return s.repo.SoftDelete(ctx, id)
+// claimConflict names the fields behind a unique violation on claiming
+// username and email, either of which may be empty. It looks again for the
+// accounts holding them; if that fails, or whoever held them has already
+// let go, every field claimed is reported.
+func (s *UserService) claimConflict(ctx context.Context, id uint, username, email string) error {
+	var taken []string
+	readCtx, cancel := stepContext(ctx, pointReadTimeout)
+	defer cancel()
+	err := s.WithTransaction(readCtx, func(ctx context.Context, tx *TxService) error {
+		var err error
+		taken, err = tx.LockConflicts(ctx, id, username, email)
+		return err
+	})
+	if err != nil || len(taken) == 0 {
+		taken = nil
+		if username != "" {
+			taken = append(taken, "username")
+		}
+		if email != "" {
+			taken = append(taken, "email")
+		}
+	}
+	return duplicateFieldsError(taken)
 }
 
-func (s *UserService) ListUsers(ctx context.Context, page, pageSize int) ([]*domain.User, int64, error) {
+// changedProfileFields lists the user-editable fields that differ between
+// before and after, in a fixed order. A username differing only in case is
+// a change; it's shown as typed.
+func changedProfileFields(before, after *domain.User) []string {
+	changed := []string{}
+	for _, field := range []struct {
+		name          string
+		before, after string
+	}{
+		{"first_name", before.FirstName, after.FirstName},
+		{"last_name", before.LastName, after.LastName},
+		{"username", before.Username, after.Username},
+		{"email", before.Email, after.Email},
+	} {
+		if field.before != field.after {
+			changed = append(changed, field.name)
+		}
+	}
+	return changed
+}
+
+// duplicateFieldsError names the fields another account already uses
+func duplicateFieldsError(fields []string) error {
+	verr := &ValidationError{Fields: make(map[string]string), Err: domain.ErrDuplicateUser}
+	for _, field := range fields {
+		switch field {
+		case "username":
+			verr.Fields[field] = "Username is already taken"
+		case "email":
+			verr.Fields[field] = "Email is already registered"
+		}
+	}
+	return verr
+}
+
+// ListUsersOptions narrow and order ListUsers
+type ListUsersOptions struct {
+	Filter domain.UserFilter
+	// Sort defaults to newest first. Without an order, dates sort newest
+	// first and names from A to Z.
+	Sort domain.UserSort
+	// IncludeDeleted lists soft-deleted accounts too, which also makes
+	// erased a status to filter by. It is for admins only.
+	IncludeDeleted bool
+}
+
+// ListUsers returns one page of users and the total across all pages. An
+// empty status filter lists every account that hasn't been erased.
+func (s *UserService) ListUsers(ctx context.Context, page, pageSize int, opts ListUsersOptions) ([]*domain.User, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+	filter := opts.Filter
+	for _, status := range filter.Statuses {
+		if !isListableStatus(status) && !(opts.IncludeDeleted && status == domain.StatusErased) {
+			return nil, 0, &ValidationError{Fields: map[string]string{
+				"status": "must be one of active, banned, pending_deletion",
+			}}
+		}
+	}
+	if !filter.CreatedAfter.IsZero() && !filter.CreatedBefore.IsZero() && !filter.CreatedBefore.After(filter.CreatedAfter) {
+		return nil, 0, &ValidationError{Fields: map[string]string{
+			"created_before": "must be after created_after",
+		}}
+	}
+	sort, err := resolveUserSort(opts.Sort)
+	if err != nil {
+		return nil, 0, err
+	}
+	filter.Query = strings.TrimSpace(filter.Query)
+
 	offset := (page - 1) * pageSize
-	return s.repo.List(ctx, offset, pageSize)
+	if opts.IncludeDeleted {
+		return s.repo.ListWithDeleted(ctx, filter, offset, pageSize, sort)
+	}
+	return s.repo.List(ctx, filter, offset, pageSize, sort)
+}
+
+// resolveUserSort fills in what sort leaves out, refusing fields and orders
+// there are none of
+func resolveUserSort(sort domain.UserSort) (domain.UserSort, error) {
+	if sort.Field == "" {
+		sort.Field = domain.DefaultUserSort.Field
+	}
+	if sort.Order == "" {
+		switch sort.Field {
+		case domain.SortByCreatedAt, domain.SortByLastLogin:
+			sort.Order = domain.SortDescending
+		default:
+			sort.Order = domain.SortAscending
+		}
+	}
+
+	fields := make(map[string]string)
+	if !sort.Field.Valid() {
+		names := make([]string, len(domain.UserSortFields))
+		for i, field := range domain.UserSortFields {
+			names[i] = string(field)
+		}
+		fields["sort"] = "must be one of " + strings.Join(names, ", ")
+	}
+	if !sort.Order.Valid() {
+		fields["order"] = "must be asc or desc"
+	}
+	if len(fields) > 0 {
+		return sort, &ValidationError{Fields: fields}
+	}
+	return sort, nil
+}
+
+// isListableStatus reports whether ListUsers can filter by status. Erased
+// accounts are soft-deleted, so no listing ever contains them.
+func isListableStatus(status domain.UserStatus) bool {
+	switch status {
+	case domain.StatusActive, domain.StatusBanned, domain.StatusPendingDeletion:
+		return true
+	}
+	return false
+}
+
+// isErased reports whether the account is gone as far as callers know
+func isErased(user *domain.User) bool {
+	return user.Status == domain.StatusErased || user.IsDeleted()
 }
@@ -0,0 +1,678 @@
+// internal/application/user_service_test.go
+package application_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/testsupport"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type fakeRevoker struct {
+	mu      sync.Mutex
+	revoked []uint
+}
+
+func (r *fakeRevoker) RevokeUserSessions(ctx context.Context, userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revoked = append(r.revoked, userID)
+	return nil
+}
+
+type fakePublisher struct {
+	mu     sync.Mutex
+	events []application.Event
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, event application.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+type fakeAuditLogger struct {
+	mu      sync.Mutex
+	entries []application.AuditEntry
+	// err, when set, fails every Record
+	err error
+}
+
+func (a *fakeAuditLogger) Record(ctx context.Context, entry *application.AuditEntry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.err != nil {
+		return a.err
+	}
+	a.entries = append(a.entries, *entry)
+	return nil
+}
+
+func (a *fakeAuditLogger) WithTx(tx *gorm.DB) application.AuditLogger {
+	return a
+}
+
+type fakeBlocklist struct {
+	mu      sync.Mutex
+	blocked map[uint]bool
+}
+
+func newFakeBlocklist() *fakeBlocklist {
+	return &fakeBlocklist{blocked: make(map[uint]bool)}
+}
+
+func (b *fakeBlocklist) Block(ctx context.Context, userID uint) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.blocked[userID] = true
+	return nil
+}
+
+func (b *fakeBlocklist) Unblock(ctx context.Context, userID uint) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.blocked, userID)
+	return nil
+}
+
+func TestRegister_HashesPasswordAndNormalizesEmail(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	tm := testsupport.NewTxManager(repo)
+	svc := application.NewUserService(repo, tm, nil)
+
+	user := &domain.User{Username: "alice", Email: " Alice@Example.com "}
+	if _, err := svc.Register(context.Background(), user, "secret123", ""); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	stored, ok := repo.User(user.ID)
+	if !ok {
+		t.Fatal("expected user to be stored")
+	}
+	if stored.Email != "alice@example.com" {
+		t.Errorf("expected normalized email, got %q", stored.Email)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(repo.PasswordHash(user.ID)), []byte("secret123")) != nil {
+		t.Error("expected stored password to be a bcrypt hash of the input")
+	}
+	if tm.Commits() != 1 {
+		t.Errorf("expected one committed transaction, got %d", tm.Commits())
+	}
+}
+
+// racedSignupRepo hides every email from the registration check, as if a
+// concurrent signup committed between the check and the insert
+type racedSignupRepo struct {
+	*testsupport.UserRepository
+}
+
+func (racedSignupRepo) GetByEmailUnscoped(ctx context.Context, email string) (*domain.User, error) {
+	return nil, domain.ErrUserNotFound
+}
+
+func TestRegister_RollsBackOnConflict(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	tm := testsupport.NewTxManager(repo)
+	svc := application.NewUserService(racedSignupRepo{repo}, tm, nil)
+
+	// Only the insert catches the email taken behind the check's back
+	repo.AddUser("alice@example.com", "secret123")
+
+	user := &domain.User{Username: "alice", Email: "alice@example.com"}
+	_, err := svc.Register(context.Background(), user, "secret123", "")
+	if !errors.Is(err, domain.ErrDuplicateUser) {
+		t.Fatalf("expected ErrDuplicateUser, got %v", err)
+	}
+	if tm.Rollbacks() != 1 {
+		t.Errorf("expected the transaction to roll back, got %d rollbacks", tm.Rollbacks())
+	}
+}
+
+// racedClaimRepo lets the first conflict check pass and the write that
+// follows hit a unique index, as if another account claimed the value in
+// between
+type racedClaimRepo struct {
+	*testsupport.UserRepository
+	checks int
+}
+
+func (r *racedClaimRepo) WithTx(tx *gorm.DB) application.UserRepository {
+	return r
+}
+
+func (r *racedClaimRepo) LockConflicts(ctx context.Context, id uint, username, email string) ([]string, error) {
+	r.checks++
+	if r.checks == 1 {
+		return nil, nil
+	}
+	return r.UserRepository.LockConflicts(ctx, id, username, email)
+}
+
+func (r *racedClaimRepo) UpdateFields(ctx context.Context, id uint, fields map[string]interface{}) error {
+	return domain.ErrDuplicateUser
+}
+
+func TestUpdateProfile_UniqueIndexNamesTheField(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+		want   string
+	}{
+		{"username held", map[string]interface{}{"username": "bob"}, "[username]"},
+		{"email held", map[string]interface{}{"username": "alice2", "email": "bob@example.com"}, "[email]"},
+		// Whoever held it has let go; every claimed field may be at fault
+		{"holder gone", map[string]interface{}{"username": "carol", "email": "carol@example.com"}, "[email username]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := testsupport.NewUserRepository()
+			alice := repo.AddUser("alice@example.com", "secret123")
+			bob := repo.AddUser("bob@example.com", "secret123")
+			bob.Username = "bob"
+			repo.Put(bob)
+			svc := application.NewUserService(&racedClaimRepo{UserRepository: repo}, testsupport.NewTxManager(repo), nil)
+
+			_, _, err := svc.UpdateProfile(context.Background(), alice.ID, tt.fields)
+			var verr *application.ValidationError
+			if !errors.As(err, &verr) || !errors.Is(err, domain.ErrDuplicateUser) {
+				t.Fatalf("expected a duplicate ValidationError, got %v", err)
+			}
+			var fields []string
+			for field := range verr.Fields {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			if fmt.Sprint(fields) != tt.want {
+				t.Errorf("expected %s blamed, got %v", tt.want, verr.Fields)
+			}
+		})
+	}
+}
+
+func TestLogin_Credentials(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	clock := testsupport.NewClock()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil, application.WithClock(clock.Now))
+	defer svc.Wait()
+
+	got, err := svc.Login(context.Background(), " ALICE@example.com", "secret123")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if got.ID != user.ID || got.LastLogin == nil || !got.LastLogin.Equal(clock.Now()) {
+		t.Errorf("unexpected login result %+v", got)
+	}
+
+	if _, err := svc.Login(context.Background(), "alice@example.com", "wrong"); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials for wrong password, got %v", err)
+	}
+	if _, err := svc.Login(context.Background(), "bob@example.com", "secret123"); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials for unknown email, got %v", err)
+	}
+}
+
+func TestGetUser_ReadsThroughCache(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache)
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.GetUser(context.Background(), user.ID); err != nil {
+			t.Fatalf("get user: %v", err)
+		}
+	}
+
+	if calls := repo.Calls("GetByID"); calls != 1 {
+		t.Errorf("expected the second read to be served from cache, got %d repository reads", calls)
+	}
+	if _, ok := cache.Cached(user.ID); !ok {
+		t.Error("expected user to be cached")
+	}
+
+	if _, err := svc.GetUser(context.Background(), 99); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+type fakeCacheObserver struct {
+	hits, misses int
+	warms        []string
+}
+
+func (o *fakeCacheObserver) ObserveCacheReads(hits, misses int) {
+	o.hits += hits
+	o.misses += misses
+}
+
+func (o *fakeCacheObserver) ObserveCacheWarm(source string) {
+	o.warms = append(o.warms, source)
+}
+
+func TestRegisterAndLogin_WarmTheCache(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	cache := testsupport.NewUserCache()
+	observer := &fakeCacheObserver{}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache,
+		application.WithCacheObserver(observer),
+	)
+	defer svc.Wait()
+	ctx := context.Background()
+
+	user := &domain.User{Username: "alice", Email: "alice@example.com"}
+	if _, err := svc.Register(ctx, user, "secret123", ""); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	cached, ok := cache.Cached(user.ID)
+	if !ok || cached.Email != "alice@example.com" || cached.Status != domain.StatusActive {
+		t.Fatalf("expected the new user cached, got %+v", cached)
+	}
+	if byEmail, ok := cache.CachedByEmail("alice@example.com"); !ok || byEmail.ID != user.ID {
+		t.Errorf("expected the new user cached by email, got %+v", byEmail)
+	}
+
+	// The next request is served from the cache
+	if _, err := svc.GetUser(ctx, user.ID); err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if calls := repo.Calls("GetByID"); calls != 0 || observer.hits != 1 {
+		t.Errorf("expected a cache hit, got %d repository reads and %d hits", calls, observer.hits)
+	}
+
+	// Login refreshes the entry, last login included, and still checks
+	// the password against the database
+	if _, err := svc.Login(ctx, "alice@example.com", "wrong"); !errors.Is(err, application.ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+	if _, err := svc.Login(ctx, "alice@example.com", "secret123"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if cached, _ := cache.Cached(user.ID); cached.LastLogin == nil {
+		t.Errorf("expected the logged in user cached, got %+v", cached)
+	}
+	if len(observer.warms) != 2 || observer.warms[0] != application.CacheWarmRegister || observer.warms[1] != application.CacheWarmLogin {
+		t.Errorf("expected register and login warms, got %v", observer.warms)
+	}
+}
+
+func TestUserExists_NeverLoadsTheRow(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache)
+	ctx := context.Background()
+
+	if exists, err := svc.UserExists(ctx, user.ID); err != nil || !exists {
+		t.Fatalf("expected the user to exist, got %v (%v)", exists, err)
+	}
+	if exists, err := svc.UserExists(ctx, 99); err != nil || exists {
+		t.Errorf("expected no user 99, got %v (%v)", exists, err)
+	}
+
+	// A cached user is answered without the database
+	if err := cache.Set(ctx, user); err != nil {
+		t.Fatalf("cache: %v", err)
+	}
+	if exists, _ := svc.UserExists(ctx, user.ID); !exists {
+		t.Error("expected the cached user to exist")
+	}
+	if calls := repo.Calls("Exists"); calls != 2 {
+		t.Errorf("expected 2 existence queries, got %d", calls)
+	}
+	if calls := repo.Calls("GetByID"); calls != 0 {
+		t.Errorf("expected no full reads, got %d", calls)
+	}
+
+	erased := *user
+	erased.Status = domain.StatusErased
+	repo.Put(&erased)
+	if err := cache.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("cache: %v", err)
+	}
+	if exists, _ := svc.UserExists(ctx, user.ID); exists {
+		t.Error("expected an erased user not to exist")
+	}
+}
+
+func TestUpdateUser_NoOpWritesNothing(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	audit := &fakeAuditLogger{}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache,
+		application.WithAuditLogger(audit),
+	)
+	before, _ := repo.GetByID(context.Background(), user.ID)
+
+	changed, err := svc.UpdateUser(context.Background(), user)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if changed == nil || len(changed) != 0 {
+		t.Errorf("expected an empty change list, got %#v", changed)
+	}
+	after, _ := repo.GetByID(context.Background(), user.ID)
+	if !after.UpdatedAt.Equal(before.UpdatedAt) {
+		t.Error("expected a no-op update not to be written")
+	}
+	if len(cache.DeletedEmails()) != 0 || len(audit.entries) != 0 {
+		t.Error("expected nothing invalidated or audited")
+	}
+
+	user.LastName = "Smith"
+	user.Email = "alice2@example.com"
+	changed, err = svc.UpdateUser(context.Background(), user)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if !equalStrings(changed, []string{"last_name", "email"}) {
+		t.Errorf("expected last_name and email changed, got %v", changed)
+	}
+}
+
+func TestUpdateProfile_WritesOnlyGivenFields(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	ctx := context.Background()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	alice.FirstName, alice.LastName = "Alice", "Smith"
+	repo.Put(alice)
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	user, changed, err := svc.UpdateProfile(ctx, alice.ID, map[string]interface{}{"first_name": ""})
+	if err != nil {
+		t.Fatalf("update profile: %v", err)
+	}
+	if !equalStrings(changed, []string{"first_name"}) || user.FirstName != "" || user.LastName != "Smith" {
+		t.Errorf("expected only the first name cleared, got %v and %q %q", changed, user.FirstName, user.LastName)
+	}
+	if repo.Calls("Update") != 0 {
+		t.Error("expected the changed column written, not the whole record")
+	}
+
+	var verr *application.ValidationError
+	for _, fields := range []map[string]interface{}{
+		{"role": "admin"},
+		{"status": "active", "first_name": "Alice"},
+		{"last_name": 42},
+	} {
+		if _, _, err := svc.UpdateProfile(ctx, alice.ID, fields); !errors.As(err, &verr) {
+			t.Errorf("%v: expected a validation error, got %v", fields, err)
+		}
+	}
+	if stored, _ := repo.GetByID(ctx, alice.ID); stored.Role == domain.RoleAdmin || stored.FirstName != "" {
+		t.Errorf("expected nothing written, got %+v", stored)
+	}
+	if _, _, err := svc.UpdateProfile(ctx, 999, map[string]interface{}{"first_name": "Ghost"}); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestUpdateUser_KeepsFieldsOutsideTheProfile(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	stored := repo.AddUser("alice@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache)
+	defer svc.Wait()
+	ctx := context.Background()
+
+	// A cached copy may have gone stale
+	user, err := svc.GetUser(ctx, stored.ID)
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	user, _ = svc.GetUser(ctx, stored.ID)
+	user.MustResetPassword = true
+	user.LastName = "Smith"
+	if _, err := svc.UpdateUser(ctx, user); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	saved, _ := repo.GetByID(ctx, stored.ID)
+	if saved.LastName != "Smith" || saved.MustResetPassword {
+		t.Errorf("expected only the profile changed, got %+v", saved)
+	}
+	if user.MustResetPassword {
+		t.Error("expected user to hold the saved record")
+	}
+	if _, err := svc.Login(ctx, "alice@example.com", "secret123"); err != nil {
+		t.Errorf("expected the password to still work, got %v", err)
+	}
+}
+
+func TestUpdateUser_InvalidatesCache(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	user := repo.AddUser("alice@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache)
+
+	if _, err := svc.GetUser(context.Background(), user.ID); err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+
+	user.FirstName = "Alice"
+	if _, err := svc.UpdateUser(context.Background(), user); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	if _, ok := cache.Cached(user.ID); ok {
+		t.Error("expected cached user to be invalidated")
+	}
+	if emails := cache.DeletedEmails(); len(emails) != 1 || emails[0] != "alice@example.com" {
+		t.Errorf("expected email key to be invalidated, got %v", emails)
+	}
+	got, _ := svc.GetUser(context.Background(), user.ID)
+	if got.FirstName != "Alice" {
+		t.Errorf("expected update to be visible, got %+v", got)
+	}
+}
+
+func TestListUsers_Paginates(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		repo.AddUser(email, "secret123")
+	}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	first, total, err := svc.ListUsers(context.Background(), 1, 2, application.ListUsersOptions{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if total != 3 || len(first) != 2 {
+		t.Fatalf("expected 2 of 3 users, got %d of %d", len(first), total)
+	}
+
+	second, _, err := svc.ListUsers(context.Background(), 2, 2, application.ListUsersOptions{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(second) != 1 {
+		t.Fatalf("expected 1 user on the last page, got %d", len(second))
+	}
+	for _, u := range first {
+		if u.ID == second[0].ID {
+			t.Errorf("user %d appears on both pages", u.ID)
+		}
+	}
+}
+
+func TestListUsers_FiltersByStatus(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	repo.AddUser("a@example.com", "secret123")
+	banned := repo.AddUser("b@example.com", "secret123")
+	repo.AddUser("c@example.com", "secret123")
+	if err := repo.UpdateFields(context.Background(), banned.ID, map[string]interface{}{
+		"status": string(domain.StatusBanned),
+	}); err != nil {
+		t.Fatalf("ban: %v", err)
+	}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	users, total, err := svc.ListUsers(context.Background(), 1, 1, application.ListUsersOptions{Filter: domain.UserFilter{Statuses: []domain.UserStatus{domain.StatusActive}}})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if total != 2 || len(users) != 1 || users[0].Status != domain.StatusActive {
+		t.Errorf("expected 1 of 2 active users, got %d of %d", len(users), total)
+	}
+
+	users, total, _ = svc.ListUsers(context.Background(), 1, 10, application.ListUsersOptions{Filter: domain.UserFilter{Statuses: []domain.UserStatus{domain.StatusBanned}}})
+	if total != 1 || len(users) != 1 || users[0].ID != banned.ID {
+		t.Errorf("expected only user %d, got %d users (total %d)", banned.ID, len(users), total)
+	}
+
+	_, _, err = svc.ListUsers(context.Background(), 1, 10, application.ListUsersOptions{Filter: domain.UserFilter{Statuses: []domain.UserStatus{domain.StatusActive, "suspended"}}})
+	var verr *application.ValidationError
+	if !errors.As(err, &verr) || verr.Fields["status"] == "" {
+		t.Fatalf("expected a status validation error, got %v", err)
+	}
+	if repo.Calls("List") != 2 {
+		t.Errorf("an invalid filter must not reach the repository")
+	}
+}
+
+func TestListUsers_Sorts(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	ctx := context.Background()
+	for _, name := range []string{"carol", "alice", "bob"} {
+		user := repo.AddUser(name+"@example.com", "secret123")
+		repo.UpdateFields(ctx, user.ID, map[string]interface{}{"username": name})
+	}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	tests := []struct {
+		sort domain.UserSort
+		want string
+	}{
+		{domain.UserSort{Field: domain.SortByUsername, Order: domain.SortAscending}, "[alice bob carol]"},
+		{domain.UserSort{Field: domain.SortByUsername, Order: domain.SortDescending}, "[carol bob alice]"},
+		{domain.UserSort{Field: domain.SortByEmail, Order: domain.SortDescending}, "[carol bob alice]"},
+		// Names default to A to Z
+		{domain.UserSort{Field: domain.SortByEmail}, "[alice bob carol]"},
+	}
+	for _, tt := range tests {
+		users, _, err := svc.ListUsers(ctx, 1, 10, application.ListUsersOptions{Sort: tt.sort})
+		if err != nil {
+			t.Fatalf("list by %v: %v", tt.sort, err)
+		}
+		names := make([]string, len(users))
+		for i, u := range users {
+			names[i] = u.Username
+		}
+		if got := fmt.Sprint(names); got != tt.want {
+			t.Errorf("by %v: expected %s, got %s", tt.sort, tt.want, got)
+		}
+	}
+
+	_, _, err := svc.ListUsers(ctx, 1, 10, application.ListUsersOptions{Sort: domain.UserSort{Field: "password", Order: "sideways"}})
+	var verr *application.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if verr.Fields["sort"] != "must be one of created_at, username, email, last_login" || verr.Fields["order"] == "" {
+		t.Errorf("expected the allowed values listed, got %v", verr.Fields)
+	}
+}
+
+func TestListUsers_FiltersByCreatedAt(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	ctx := context.Background()
+	week := time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC)
+	for id, created := range map[uint]time.Time{
+		1: week.Add(-time.Nanosecond),
+		2: week,
+		3: week.Add(3 * 24 * time.Hour),
+		4: week.Add(7 * 24 * time.Hour),
+	} {
+		repo.Put(&domain.User{ID: id, Username: fmt.Sprintf("user%d", id), Status: domain.StatusActive, CreatedAt: created})
+	}
+	repo.Put(&domain.User{ID: 5, Username: "gone", Status: domain.StatusErased, CreatedAt: week.Add(time.Hour),
+		DeletedAt: gorm.DeletedAt{Time: week.Add(2 * time.Hour), Valid: true}})
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	ids := func(opts application.ListUsersOptions) string {
+		t.Helper()
+		opts.Sort = domain.UserSort{Field: domain.SortByCreatedAt, Order: domain.SortAscending}
+		users, total, err := svc.ListUsers(ctx, 1, 10, opts)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		got := make([]uint, len(users))
+		for i, u := range users {
+			got[i] = u.ID
+		}
+		return fmt.Sprintf("%v of %d", got, total)
+	}
+
+	// The week starts with, and includes, its first instant and stops
+	// short of the next week's
+	lastWeek := domain.UserFilter{CreatedAfter: week, CreatedBefore: week.Add(7 * 24 * time.Hour)}
+	if got := ids(application.ListUsersOptions{Filter: lastWeek}); got != "[2 3] of 2" {
+		t.Errorf("expected users 2 and 3, got %s", got)
+	}
+	if got := ids(application.ListUsersOptions{Filter: domain.UserFilter{CreatedBefore: week}}); got != "[1] of 1" {
+		t.Errorf("expected only user 1, got %s", got)
+	}
+	if got := ids(application.ListUsersOptions{Filter: lastWeek, IncludeDeleted: true}); got != "[2 5 3] of 3" {
+		t.Errorf("expected the deleted user included, got %s", got)
+	}
+	erased := domain.UserFilter{Statuses: []domain.UserStatus{domain.StatusErased}}
+	if got := ids(application.ListUsersOptions{Filter: erased, IncludeDeleted: true}); got != "[5] of 1" {
+		t.Errorf("expected only the erased user, got %s", got)
+	}
+
+	var verr *application.ValidationError
+	if _, _, err := svc.ListUsers(ctx, 1, 10, application.ListUsersOptions{Filter: erased}); !errors.As(err, &verr) {
+		t.Errorf("expected erased refused without deleted accounts, got %v", err)
+	}
+	backwards := domain.UserFilter{CreatedAfter: week, CreatedBefore: week}
+	if _, _, err := svc.ListUsers(ctx, 1, 10, application.ListUsersOptions{Filter: backwards}); !errors.As(err, &verr) || verr.Fields["created_before"] == "" {
+		t.Errorf("expected an empty range refused, got %v", err)
+	}
+}
+
+func TestListUsers_Searches(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	ctx := context.Background()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	repo.AddUser("bob@example.com", "secret123")
+	malice := repo.AddUser("mallory@example.org", "secret123")
+	repo.UpdateFields(ctx, malice.ID, map[string]interface{}{"username": "Malice"})
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+
+	// "ALIC" is in alice's email and in Malice's username
+	users, total, err := svc.ListUsers(ctx, 1, 1, application.ListUsersOptions{Filter: domain.UserFilter{Query: "  ALIC "}})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if total != 2 || len(users) != 1 {
+		t.Errorf("expected 1 of 2 matches, got %d of %d", len(users), total)
+	}
+
+	users, total, _ = svc.ListUsers(ctx, 1, 10, application.ListUsersOptions{Filter: domain.UserFilter{Query: ".org"}})
+	if total != 1 || len(users) != 1 || users[0].ID != malice.ID {
+		t.Errorf("expected only user %d, got %d users (total %d)", malice.ID, len(users), total)
+	}
+
+	repo.UpdateFields(ctx, alice.ID, map[string]interface{}{"status": string(domain.StatusBanned)})
+	active := domain.UserFilter{Statuses: []domain.UserStatus{domain.StatusActive}, Query: "alic"}
+	if _, total, _ = svc.ListUsers(ctx, 1, 10, application.ListUsersOptions{Filter: active}); total != 1 {
+		t.Errorf("expected the status filter applied to the search, got %d", total)
+	}
+
+	// A blank query lists everyone, as before
+	if _, total, _ = svc.ListUsers(ctx, 1, 10, application.ListUsersOptions{Filter: domain.UserFilter{Query: "   "}}); total != 3 {
+		t.Errorf("expected all 3 users, got %d", total)
+	}
+}
@@ -1,18 +1,70 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"maps"
+	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// DefaultJWTSecret is the development fallback for JWT_SECRET
+const DefaultJWTSecret = "your-super-secret-key-change-in-production"
+
+// MinSnapshotSecretLength keeps SNAPSHOT_SIGNING_SECRET and STORAGE_URL_SECRET
+// out of guessing range
+const MinSnapshotSecretLength = 32
+
+// DefaultMaxBodySize is the MAX_BODY_SIZE fallback, 1MB
+const DefaultMaxBodySize = 1 << 20
+
+// JWTKey is one kid:secret entry of JWT_SECRETS
+type JWTKey struct {
+	ID     string
+	Secret string
+}
+
 type Config struct {
-	Port      string
-	JWTSecret string
-	JWTExpire time.Duration
+	// Environment is e.g. development, staging or production
+	Environment string
+	Port        string
+	JWTSecret   string
+	JWTExpire   time.Duration
+	// JWTAlgorithm is HS256 (signed with JWTSecret), RS256 or EdDSA. The
+	// asymmetric ones sign with the PEM key at JWTPrivateKeyPath, and
+	// publish the public key so other services can verify tokens.
+	// JWTPublicKeyPath is optional; when set it must match.
+	JWTAlgorithm      string
+	JWTPrivateKeyPath string
+	JWTPublicKeyPath  string
+	// JWTSecrets rotates the HS256 secret and replaces JWTSecret when set.
+	// The first key signs; the others verify the tokens they signed until
+	// JWTSecretGracePeriod after JWTSecretsRotatedAt, or after startup
+	// when that is zero.
+	JWTSecrets           []JWTKey
+	JWTSecretGracePeriod time.Duration
+	JWTSecretsRotatedAt  time.Time
+	// JWTRememberMeExpire is how long the access token of a login asking
+	// to be remembered lasts, and so the longest any token can
+	JWTRememberMeExpire time.Duration
+	// JWTRefreshExpire is how long a refresh token lasts; each refresh
+	// issues a new one
+	JWTRefreshExpire time.Duration
+	// JWTIssuer is the iss of issued tokens, required of validated ones;
+	// empty means auth.DefaultIssuer. JWTAudience, when set, is the aud
+	// of issued tokens, and validated ones must name one of its services.
+	JWTIssuer   string
+	JWTAudience []string
 
 	// Database config
 	DBHost            string
@@ -33,7 +85,143 @@ type Config struct {
 	RedisDB       int
 
 	// Cache
-	CacheUserTTL time.Duration
+	CacheUserTTL      time.Duration
+	BlocklistLocalTTL time.Duration
+
+	// HealthCacheTTL is how long /health reuses a dependency check
+	HealthCacheTTL time.Duration
+
+	// MaxBodySize caps request bodies, in bytes. Routes such as uploads
+	// may set a limit of their own.
+	MaxBodySize int64
+
+	// Last login batching
+	LastLoginBufferSize    int
+	LastLoginFlushInterval time.Duration
+
+	// Internal service-to-service API keys (client name -> key)
+	InternalAPIKeys map[string]string
+	// InternalRequestSigning makes internal REST clients also sign each
+	// request with their key, see package reqsign
+	InternalRequestSigning bool
+	// Admin tooling API keys (client name -> key)
+	AdminAPIKeys map[string]string
+	// SnapshotSigningSecret signs user snapshots exported through the admin
+	// API; the snapshot routes are only mounted when it is set
+	SnapshotSigningSecret string
+
+	// File storage for avatars, exports and import results: local, s3 or
+	// empty for none. Local files are downloaded through /files links
+	// signed with StorageURLSecret; without it they can't be linked to.
+	StorageBackend     string
+	StorageLocalPath   string
+	StorageURLSecret   string
+	StorageS3Endpoint  string
+	StorageS3Region    string
+	StorageS3Bucket    string
+	StorageS3AccessKey string
+	StorageS3SecretKey string
+	// StorageS3PathStyle addresses the bucket in the path, for MinIO
+	StorageS3PathStyle bool
+	// StorageS3SSE is AES256 or aws:kms to encrypt objects at rest
+	StorageS3SSE      string
+	StorageS3KMSKeyID string
+
+	// gRPC API for internal consumers; an empty GRPCPort disables it.
+	// Clients authenticate with an InternalAPIKeys key or, when
+	// GRPCClientCAFile is set, a client certificate signed by that CA.
+	GRPCPort         string
+	GRPCTLSCertFile  string
+	GRPCTLSKeyFile   string
+	GRPCClientCAFile string
+
+	// Login hooks
+	LoginHookTimeout        time.Duration
+	BreachedPasswordsFile   string
+	BreachedPasswordsFPRate float64
+
+	// PathNormalization is rewrite, redirect or off: what to do with
+	// requests for /users/login/, //users/login and the like
+	PathNormalization string
+
+	// RequestLogSkipPaths are paths whose successful requests aren't
+	// logged, such as probes. RequestLogSampleRate is the share of the
+	// rest that are, from 0 to 1; failed requests are always logged.
+	RequestLogSkipPaths  []string
+	RequestLogSampleRate float64
+
+	// TracingEnabled exports spans over OTLP, set when
+	// OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+	// is. The exporter reads the rest of its settings itself.
+	TracingEnabled bool
+
+	// RequestTimeout is how long a handler has to answer before its
+	// database and Redis calls are abandoned and it answers 504. It must
+	// stay below the server's 15s write timeout for the 504 to get out.
+	// InternalRequestTimeout replaces it for the internal API, whose
+	// callers have tighter budgets; zero keeps the global one.
+	RequestTimeout         time.Duration
+	InternalRequestTimeout time.Duration
+
+	// CORS: the browser origins allowed to call the API, such as
+	// https://shop.example.com or https://*.preview.example.com, and
+	// what they may send. "*" allows any origin, but not with credentials.
+	CORSAllowedOrigins   []string
+	CORSAllowedMethods   []string
+	CORSAllowedHeaders   []string
+	CORSAllowCredentials bool
+	CORSMaxAge           time.Duration
+
+	// Security headers. HSTSMaxAge is sent on TLS requests, zero turning
+	// it off; the HTTP server doesn't do TLS itself, so it takes
+	// TrustForwardedProto, trusting the proxy's X-Forwarded-Proto, to
+	// send it at all. DocsContentSecurityPolicy replaces the built-in
+	// policy for the Swagger UI page when set.
+	HSTSMaxAge                time.Duration
+	TrustForwardedProto       bool
+	ReferrerPolicy            string
+	DocsContentSecurityPolicy string
+
+	// TrustedProxies are the CIDRs of the proxies in front of the service,
+	// whose ClientIPHeader (X-Forwarded-For, Forwarded or X-Real-IP) names
+	// the client. From anywhere else the header is ignored, so behind a
+	// proxy that isn't listed every client shares its IP and its limits.
+	TrustedProxies []string
+	ClientIPHeader string
+
+	// ErrorFormatCompat keeps the old text/plain error bodies for clients
+	// that don't send Accept: application/json; the rest get JSON
+	ErrorFormatCompat bool
+
+	// RegistrationMode is open, invite (a valid invite code is required)
+	// or closed
+	RegistrationMode string
+
+	// Security alert kill-switches, and how long a device stays known for
+	// new-device alerts
+	SecurityAlertNewDevice       bool
+	SecurityAlertPasswordChanged bool
+	SecurityAlertEmailChanged    bool
+	KnownDeviceTTL               time.Duration
+
+	// Account deletion
+	DeletionGracePeriod time.Duration
+	ErasureInterval     time.Duration
+	// DeletedAccountHintWindow is how long after deletion a signup reusing
+	// the account's email is told it was deleted; zero turns the hint off
+	DeletedAccountHintWindow time.Duration
+	// PurgeRetention is how long a soft-deleted account must stay deleted
+	// before an admin may remove its row for good
+	PurgeRetention time.Duration
+	// MailDomainCheck rejects signups whose email domain has no MX
+	// records. Off by default since it puts DNS on the signup path.
+	MailDomainCheck bool
+
+	// TermsVersion is the current terms of service version; accounts
+	// created before TermsUpdatedAt are shown a notice until they dismiss
+	// it. Empty turns the notice off.
+	TermsVersion   string
+	TermsUpdatedAt time.Time
 
 	// Rate limiting config
 	RateLimitGlobal        float64
@@ -42,19 +230,48 @@ type Config struct {
 	RateLimitLoginBurst    int
 	RateLimitRegister      float64
 	RateLimitRegisterBurst int
+	// RateLimitModes sets limiter scopes (global, register, login, ...)
+	// to enforce, warn or off; unlisted scopes are enforced. Reloaded on
+	// SIGHUP, see LoadRateLimitModes.
+	RateLimitModes map[string]string
+	// RateLimitFailureModes sets what limiter scopes do when Redis fails
+	// them: open lets requests through, closed turns them away and local
+	// limits them in memory. Unlisted scopes fail over to local.
+	RateLimitFailureModes map[string]string
 }
 
 func Load() *Config {
 	_ = godotenv.Load()
 
+	environment := getEnv("ENVIRONMENT", "development")
 	port := getEnv("PORT", "8081")
-	jwtSecret := getEnv("JWT_SECRET", "your-super-secret-key-change-in-production")
-	jwtExpireStr := getEnv("JWT_EXPIRE", "24h")
+	jwtSecret := getEnv("JWT_SECRET", DefaultJWTSecret)
+	jwtExpireStr := getEnv("JWT_EXPIRE", "15m")
 
 	jwtExpire, err := time.ParseDuration(jwtExpireStr)
 	if err != nil {
 		log.Fatalf("Invalid JWT_EXPIRE: %v", err)
 	}
+	// Unparsable values load as zero and fail Validate
+	jwtRefreshExpire, _ := time.ParseDuration(getEnv("JWT_REFRESH_EXPIRE", "720h"))
+	jwtRememberMeExpireStr := getEnv("JWT_REMEMBER_ME_EXPIRE", "720h")
+	jwtRememberMeExpire, _ := time.ParseDuration(jwtRememberMeExpireStr)
+	jwtAlgorithm := getEnv("JWT_ALGORITHM", "HS256")
+	jwtPrivateKeyPath := getEnv("JWT_PRIVATE_KEY_PATH", "")
+	jwtPublicKeyPath := getEnv("JWT_PUBLIC_KEY_PATH", "")
+	// Newest first, e.g. JWT_SECRETS=2024-06:new-secret,2024-01:old-secret
+	jwtSecrets := parseJWTKeys(getEnv("JWT_SECRETS", ""))
+	jwtSecretGracePeriod, _ := time.ParseDuration(getEnv("JWT_SECRET_GRACE_PERIOD", jwtRememberMeExpireStr))
+	var jwtSecretsRotatedAt time.Time
+	if raw := getEnv("JWT_SECRETS_ROTATED_AT", ""); raw != "" {
+		jwtSecretsRotatedAt, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			log.Fatalf("Invalid JWT_SECRETS_ROTATED_AT: %v", err)
+		}
+	}
+	jwtIssuer := getEnv("JWT_ISSUER", "user-service")
+	// e.g. JWT_AUDIENCE=user-service,cart-service
+	jwtAudience := parseList(getEnv("JWT_AUDIENCE", ""))
 
 	// Database configuration
 	dbHost := getEnv("DB_HOST", "postgres")
@@ -85,6 +302,118 @@ func Load() *Config {
 	// Cache config
 	cacheUserTTLStr := getEnv("CACHE_USER_TTL", "5m")
 	cacheUserTTL, _ := time.ParseDuration(cacheUserTTLStr)
+	blocklistLocalTTLStr := getEnv("BLOCKLIST_LOCAL_TTL", "5s")
+	blocklistLocalTTL, _ := time.ParseDuration(blocklistLocalTTLStr)
+
+	// Health checks share one ping per dependency within this window
+	healthCacheTTLStr := getEnv("HEALTH_CACHE_TTL", "5s")
+	healthCacheTTL, _ := time.ParseDuration(healthCacheTTLStr)
+
+	maxBodySize := getEnvAsInt("MAX_BODY_SIZE", DefaultMaxBodySize)
+
+	// Last login batching config
+	lastLoginBufferSize := getEnvAsInt("LAST_LOGIN_BUFFER_SIZE", 1024)
+	lastLoginFlushIntervalStr := getEnv("LAST_LOGIN_FLUSH_INTERVAL", "5s")
+	lastLoginFlushInterval, _ := time.ParseDuration(lastLoginFlushIntervalStr)
+
+	// Internal API keys, e.g. "checkout:key1,cart:key2"
+	internalAPIKeys := getEnvAsMap("INTERNAL_API_KEYS")
+	internalRequestSigning := getEnvAsBool("INTERNAL_REQUEST_SIGNING", false)
+	adminAPIKeys := getEnvAsMap("ADMIN_API_KEYS")
+	snapshotSigningSecret := getEnv("SNAPSHOT_SIGNING_SECRET", "")
+
+	// File storage is off unless a backend is chosen
+	storageBackend := strings.ToLower(getEnv("STORAGE_BACKEND", ""))
+	storageLocalPath := getEnv("STORAGE_LOCAL_PATH", "./data/files")
+	storageURLSecret := getEnv("STORAGE_URL_SECRET", "")
+	storageS3Endpoint := getEnv("STORAGE_S3_ENDPOINT", "")
+	storageS3Region := getEnv("STORAGE_S3_REGION", "us-east-1")
+	storageS3Bucket := getEnv("STORAGE_S3_BUCKET", "")
+	storageS3AccessKey := getEnv("STORAGE_S3_ACCESS_KEY", "")
+	storageS3SecretKey := getEnv("STORAGE_S3_SECRET_KEY", "")
+	storageS3PathStyle := getEnvAsBool("STORAGE_S3_PATH_STYLE", false)
+	storageS3SSE := getEnv("STORAGE_S3_SSE", "")
+	storageS3KMSKeyID := getEnv("STORAGE_S3_KMS_KEY_ID", "")
+
+	// gRPC server; set GRPC_PORT to an empty string to disable it
+	grpcPort := getEnv("GRPC_PORT", "9090")
+	grpcTLSCertFile := getEnv("GRPC_TLS_CERT_FILE", "")
+	grpcTLSKeyFile := getEnv("GRPC_TLS_KEY_FILE", "")
+	grpcClientCAFile := getEnv("GRPC_CLIENT_CA_FILE", "")
+
+	// Login hooks; the breached-password check is off without a corpus
+	loginHookTimeoutStr := getEnv("LOGIN_HOOK_TIMEOUT", "200ms")
+	loginHookTimeout, _ := time.ParseDuration(loginHookTimeoutStr)
+	breachedPasswordsFile := getEnv("BREACHED_PASSWORDS_FILE", "")
+	breachedPasswordsFPRate := getEnvAsFloat("BREACHED_PASSWORDS_FP_RATE", 0.001)
+
+	// Trailing and doubled slashes: rewrite, redirect (308) or off
+	pathNormalization := strings.ToLower(getEnv("PATH_NORMALIZATION", "rewrite"))
+
+	// Request logging; probes would drown out everything else
+	requestLogSkipPaths := parseList(getEnv("REQUEST_LOG_SKIP_PATHS", "/health,/livez"))
+	requestLogSampleRate := getEnvAsFloat("REQUEST_LOG_SAMPLE_RATE", 1)
+
+	// Tracing is off without a collector to send spans to
+	tracingEnabled := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "") != "" || getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "") != ""
+
+	// Request deadlines
+	requestTimeoutStr := getEnv("REQUEST_TIMEOUT", "10s")
+	requestTimeout, _ := time.ParseDuration(requestTimeoutStr)
+	internalRequestTimeoutStr := getEnv("INTERNAL_REQUEST_TIMEOUT", "0")
+	internalRequestTimeout, _ := time.ParseDuration(internalRequestTimeoutStr)
+
+	// CORS; open to any origin, without credentials, unless narrowed
+	corsAllowedOrigins := parseList(getEnv("CORS_ALLOWED_ORIGINS", "*"))
+	corsAllowedMethods := parseList(getEnv("CORS_ALLOWED_METHODS", "GET,HEAD,POST,PUT,PATCH,DELETE"))
+	corsAllowedHeaders := parseList(getEnv("CORS_ALLOWED_HEADERS", "Authorization,Content-Type,Idempotency-Key,If-None-Match,X-Request-ID"))
+	corsAllowCredentials := getEnvAsBool("CORS_ALLOW_CREDENTIALS", false)
+	corsMaxAgeStr := getEnv("CORS_MAX_AGE", "10m")
+	corsMaxAge, _ := time.ParseDuration(corsMaxAgeStr)
+
+	// Security headers; 180 days of HSTS once requests arrive over TLS
+	hstsMaxAgeStr := getEnv("HSTS_MAX_AGE", "4320h")
+	hstsMaxAge, _ := time.ParseDuration(hstsMaxAgeStr)
+	trustForwardedProto := getEnvAsBool("TRUST_FORWARDED_PROTO", false)
+	referrerPolicy := getEnv("REFERRER_POLICY", "no-referrer")
+	docsContentSecurityPolicy := getEnv("DOCS_CONTENT_SECURITY_POLICY", "")
+
+	// Client IPs; no forwarding header is believed until proxies are listed
+	trustedProxies := parseList(getEnv("TRUSTED_PROXIES", ""))
+	clientIPHeader := getEnv("CLIENT_IP_HEADER", "X-Forwarded-For")
+
+	// Plain-text errors for clients that predate the JSON error body
+	errorFormatCompat := getEnvAsBool("ERROR_FORMAT_COMPAT", false)
+
+	// Who may sign up: open, invite or closed
+	registrationMode := strings.ToLower(getEnv("REGISTRATION_MODE", "open"))
+
+	// Security alerts are on unless switched off one by one
+	securityAlertNewDevice := getEnvAsBool("SECURITY_ALERT_NEW_DEVICE", true)
+	securityAlertPasswordChanged := getEnvAsBool("SECURITY_ALERT_PASSWORD_CHANGED", true)
+	securityAlertEmailChanged := getEnvAsBool("SECURITY_ALERT_EMAIL_CHANGED", true)
+	knownDeviceTTLStr := getEnv("KNOWN_DEVICE_TTL", "2160h")
+	knownDeviceTTL, _ := time.ParseDuration(knownDeviceTTLStr)
+
+	// Deletion requests are erased after the grace period (30 days)
+	deletionGracePeriodStr := getEnv("DELETION_GRACE_PERIOD", "720h")
+	deletionGracePeriod, _ := time.ParseDuration(deletionGracePeriodStr)
+	erasureIntervalStr := getEnv("ERASURE_INTERVAL", "1h")
+	erasureInterval, _ := time.ParseDuration(erasureIntervalStr)
+	deletedAccountHintWindow, _ := time.ParseDuration(getEnv("DELETED_ACCOUNT_HINT_WINDOW", "720h"))
+	purgeRetention, _ := time.ParseDuration(getEnv("PURGE_RETENTION", "720h"))
+	mailDomainCheck := getEnvAsBool("MAIL_DOMAIN_CHECK", false)
+
+	// Terms of service, e.g. TERMS_VERSION=2024-06 and
+	// TERMS_UPDATED_AT=2024-06-01T00:00:00Z
+	termsVersion := getEnv("TERMS_VERSION", "")
+	var termsUpdatedAt time.Time
+	if raw := getEnv("TERMS_UPDATED_AT", ""); raw != "" {
+		termsUpdatedAt, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			log.Fatalf("Invalid TERMS_UPDATED_AT: %v", err)
+		}
+	}
 
 	// Rate limiting configuration
 	rateLimitGlobal := getEnvAsFloat("RATE_LIMIT_GLOBAL", 100.0)
@@ -93,34 +422,305 @@ func Load() *Config {
 	rateLimitLoginBurst := getEnvAsInt("RATE_LIMIT_LOGIN_BURST", 2)
 	rateLimitRegister := getEnvAsFloat("RATE_LIMIT_REGISTER", 0.083) // 5/min
 	rateLimitRegisterBurst := getEnvAsInt("RATE_LIMIT_REGISTER_BURST", 1)
+	// Per-scope modes, e.g. "login:warn,recover:off"
+	rateLimitModes := getEnvAsMap("RATE_LIMIT_MODES")
+	// Login and register would rather refuse than be guessed at unlimited
+	rateLimitFailureModes := parseMap(getEnv("RATE_LIMIT_FAILURE_MODES", "login:closed,register:closed"))
 
 	return &Config{
-		Port:                   port,
-		JWTSecret:              jwtSecret,
-		JWTExpire:              jwtExpire,
-		DBHost:                 dbHost,
-		DBPort:                 dbPort,
-		DBUser:                 dbUser,
-		DBPassword:             dbPassword,
-		DBName:                 dbName,
-		DBSSLMode:              dbSSLMode,
-		DBMaxIdleConns:         dbMaxIdleConns,
-		DBMaxOpenConns:         dbMaxOpenConns,
-		DBConnMaxLifeTime:      dbConnMaxLifeTime,
-		DBConnMaxIdleTime:      dbConnMaxIdleTime,
-		DBRetryAttempts:        dbRetryAttempts,
-		DBRetryDelay:           dbRetryDelay,
-		RedisAddr:              redisAddr,
-		RedisPassword:          redisPassword,
-		RedisDB:                redisDB,
-		CacheUserTTL:           cacheUserTTL,
-		RateLimitGlobal:        rateLimitGlobal,
-		RateLimitGlobalBurst:   rateLimitGlobalBurst,
-		RateLimitLogin:         rateLimitLogin,
-		RateLimitLoginBurst:    rateLimitLoginBurst,
-		RateLimitRegister:      rateLimitRegister,
-		RateLimitRegisterBurst: rateLimitRegisterBurst,
+		Environment:                  environment,
+		Port:                         port,
+		JWTSecret:                    jwtSecret,
+		JWTExpire:                    jwtExpire,
+		JWTRefreshExpire:             jwtRefreshExpire,
+		JWTRememberMeExpire:          jwtRememberMeExpire,
+		JWTAlgorithm:                 jwtAlgorithm,
+		JWTPrivateKeyPath:            jwtPrivateKeyPath,
+		JWTPublicKeyPath:             jwtPublicKeyPath,
+		JWTSecrets:                   jwtSecrets,
+		JWTSecretGracePeriod:         jwtSecretGracePeriod,
+		JWTSecretsRotatedAt:          jwtSecretsRotatedAt,
+		JWTIssuer:                    jwtIssuer,
+		JWTAudience:                  jwtAudience,
+		DBHost:                       dbHost,
+		DBPort:                       dbPort,
+		DBUser:                       dbUser,
+		DBPassword:                   dbPassword,
+		DBName:                       dbName,
+		DBSSLMode:                    dbSSLMode,
+		DBMaxIdleConns:               dbMaxIdleConns,
+		DBMaxOpenConns:               dbMaxOpenConns,
+		DBConnMaxLifeTime:            dbConnMaxLifeTime,
+		DBConnMaxIdleTime:            dbConnMaxIdleTime,
+		DBRetryAttempts:              dbRetryAttempts,
+		DBRetryDelay:                 dbRetryDelay,
+		RedisAddr:                    redisAddr,
+		RedisPassword:                redisPassword,
+		RedisDB:                      redisDB,
+		CacheUserTTL:                 cacheUserTTL,
+		BlocklistLocalTTL:            blocklistLocalTTL,
+		HealthCacheTTL:               healthCacheTTL,
+		MaxBodySize:                  int64(maxBodySize),
+		LastLoginBufferSize:          lastLoginBufferSize,
+		LastLoginFlushInterval:       lastLoginFlushInterval,
+		InternalAPIKeys:              internalAPIKeys,
+		InternalRequestSigning:       internalRequestSigning,
+		AdminAPIKeys:                 adminAPIKeys,
+		SnapshotSigningSecret:        snapshotSigningSecret,
+		StorageBackend:               storageBackend,
+		StorageLocalPath:             storageLocalPath,
+		StorageURLSecret:             storageURLSecret,
+		StorageS3Endpoint:            storageS3Endpoint,
+		StorageS3Region:              storageS3Region,
+		StorageS3Bucket:              storageS3Bucket,
+		StorageS3AccessKey:           storageS3AccessKey,
+		StorageS3SecretKey:           storageS3SecretKey,
+		StorageS3PathStyle:           storageS3PathStyle,
+		StorageS3SSE:                 storageS3SSE,
+		StorageS3KMSKeyID:            storageS3KMSKeyID,
+		GRPCPort:                     grpcPort,
+		GRPCTLSCertFile:              grpcTLSCertFile,
+		GRPCTLSKeyFile:               grpcTLSKeyFile,
+		GRPCClientCAFile:             grpcClientCAFile,
+		LoginHookTimeout:             loginHookTimeout,
+		BreachedPasswordsFile:        breachedPasswordsFile,
+		BreachedPasswordsFPRate:      breachedPasswordsFPRate,
+		ErrorFormatCompat:            errorFormatCompat,
+		RequestLogSkipPaths:          requestLogSkipPaths,
+		RequestLogSampleRate:         requestLogSampleRate,
+		TracingEnabled:               tracingEnabled,
+		RequestTimeout:               requestTimeout,
+		InternalRequestTimeout:       internalRequestTimeout,
+		CORSAllowedOrigins:           corsAllowedOrigins,
+		CORSAllowedMethods:           corsAllowedMethods,
+		CORSAllowedHeaders:           corsAllowedHeaders,
+		CORSAllowCredentials:         corsAllowCredentials,
+		CORSMaxAge:                   corsMaxAge,
+		HSTSMaxAge:                   hstsMaxAge,
+		TrustForwardedProto:          trustForwardedProto,
+		ReferrerPolicy:               referrerPolicy,
+		DocsContentSecurityPolicy:    docsContentSecurityPolicy,
+		TrustedProxies:               trustedProxies,
+		ClientIPHeader:               clientIPHeader,
+		PathNormalization:            pathNormalization,
+		RegistrationMode:             registrationMode,
+		SecurityAlertNewDevice:       securityAlertNewDevice,
+		SecurityAlertPasswordChanged: securityAlertPasswordChanged,
+		SecurityAlertEmailChanged:    securityAlertEmailChanged,
+		KnownDeviceTTL:               knownDeviceTTL,
+		DeletionGracePeriod:          deletionGracePeriod,
+		ErasureInterval:              erasureInterval,
+		DeletedAccountHintWindow:     deletedAccountHintWindow,
+		PurgeRetention:               purgeRetention,
+		MailDomainCheck:              mailDomainCheck,
+		TermsVersion:                 termsVersion,
+		TermsUpdatedAt:               termsUpdatedAt,
+		RateLimitGlobal:              rateLimitGlobal,
+		RateLimitGlobalBurst:         rateLimitGlobalBurst,
+		RateLimitLogin:               rateLimitLogin,
+		RateLimitLoginBurst:          rateLimitLoginBurst,
+		RateLimitRegister:            rateLimitRegister,
+		RateLimitRegisterBurst:       rateLimitRegisterBurst,
+		RateLimitModes:               rateLimitModes,
+		RateLimitFailureModes:        rateLimitFailureModes,
+	}
+}
+
+// LoadRateLimitModes rereads RATE_LIMIT_MODES for a reload. The process
+// environment is fixed once started, so a value in .env takes precedence
+// here; Load gives the environment precedence.
+func LoadRateLimitModes() map[string]string {
+	if env, err := godotenv.Read(); err == nil {
+		if value, ok := env["RATE_LIMIT_MODES"]; ok {
+			return parseMap(value)
+		}
+	}
+	return getEnvAsMap("RATE_LIMIT_MODES")
+}
+
+// Validate reports settings the service can't start with. Unparsable
+// durations load as zero, so those are caught here too.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Port == "" {
+		errs = append(errs, errors.New("PORT is empty"))
+	}
+	if c.JWTExpire <= 0 {
+		errs = append(errs, errors.New("JWT_EXPIRE must be positive"))
+	}
+	if c.JWTRefreshExpire < c.JWTExpire {
+		errs = append(errs, errors.New("JWT_REFRESH_EXPIRE must be at least JWT_EXPIRE"))
+	}
+	if c.JWTRememberMeExpire < c.JWTExpire {
+		errs = append(errs, errors.New("JWT_REMEMBER_ME_EXPIRE must be at least JWT_EXPIRE"))
+	}
+	switch c.JWTAlgorithm {
+	case "", "HS256":
+		if err := validateJWTKeys(c.JWTSecrets); err != nil {
+			errs = append(errs, err)
+		}
+		if len(c.JWTSecrets) > 1 && c.JWTSecretGracePeriod <= 0 {
+			errs = append(errs, errors.New("JWT_SECRET_GRACE_PERIOD must be a positive duration"))
+		}
+	case "RS256", "EdDSA":
+		if c.JWTPrivateKeyPath == "" {
+			errs = append(errs, fmt.Errorf("JWT_PRIVATE_KEY_PATH is required for %s", c.JWTAlgorithm))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("JWT_ALGORITHM must be HS256, RS256 or EdDSA, not %q", c.JWTAlgorithm))
 	}
+	if c.DBHost == "" || c.DBName == "" {
+		errs = append(errs, errors.New("DB_HOST and DB_NAME are required"))
+	}
+	if c.DBRetryAttempts < 1 {
+		errs = append(errs, errors.New("DB_RETRY_ATTEMPTS must be at least 1"))
+	}
+	if c.MaxBodySize <= 0 {
+		errs = append(errs, errors.New("MAX_BODY_SIZE must be a positive number of bytes"))
+	}
+	for _, origin := range c.CORSAllowedOrigins {
+		if origin == "*" {
+			if c.CORSAllowCredentials {
+				errs = append(errs, errors.New("CORS_ALLOWED_ORIGINS can't be * with CORS_ALLOW_CREDENTIALS; list the origins"))
+			}
+			continue
+		}
+		if !validCORSOrigin(origin) {
+			errs = append(errs, fmt.Errorf("CORS_ALLOWED_ORIGINS entry %q must be scheme://host[:port], optionally with *. before the host", origin))
+		}
+	}
+	if c.InternalRequestTimeout < 0 {
+		errs = append(errs, errors.New("INTERNAL_REQUEST_TIMEOUT must not be negative"))
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err != nil {
+			if _, err := netip.ParseAddr(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("TRUSTED_PROXIES entry %q must be a CIDR or an IP address", proxy))
+			}
+		}
+	}
+	switch http.CanonicalHeaderKey(c.ClientIPHeader) {
+	case "", "X-Forwarded-For", "Forwarded", "X-Real-Ip":
+	default:
+		errs = append(errs, fmt.Errorf("CLIENT_IP_HEADER must be X-Forwarded-For, Forwarded or X-Real-IP, not %q", c.ClientIPHeader))
+	}
+	if c.HSTSMaxAge < 0 {
+		errs = append(errs, errors.New("HSTS_MAX_AGE must not be negative"))
+	}
+	if c.RequestLogSampleRate < 0 || c.RequestLogSampleRate > 1 {
+		errs = append(errs, errors.New("REQUEST_LOG_SAMPLE_RATE must be between 0 and 1"))
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"CACHE_USER_TTL", c.CacheUserTTL},
+		{"LAST_LOGIN_FLUSH_INTERVAL", c.LastLoginFlushInterval},
+		{"ERASURE_INTERVAL", c.ErasureInterval},
+		{"REQUEST_TIMEOUT", c.RequestTimeout},
+	} {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be a positive duration", d.name))
+		}
+	}
+	if (c.GRPCTLSCertFile == "") != (c.GRPCTLSKeyFile == "") {
+		errs = append(errs, errors.New("GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE must be set together"))
+	}
+	switch c.RegistrationMode {
+	case "", "open", "invite", "closed":
+	default:
+		errs = append(errs, fmt.Errorf("REGISTRATION_MODE must be open, invite or closed, not %q", c.RegistrationMode))
+	}
+	switch c.PathNormalization {
+	case "", "rewrite", "redirect", "off":
+	default:
+		errs = append(errs, fmt.Errorf("PATH_NORMALIZATION must be rewrite, redirect or off, not %q", c.PathNormalization))
+	}
+	if c.SnapshotSigningSecret != "" && len(c.SnapshotSigningSecret) < MinSnapshotSecretLength {
+		errs = append(errs, fmt.Errorf("SNAPSHOT_SIGNING_SECRET must be at least %d characters", MinSnapshotSecretLength))
+	}
+	switch c.StorageBackend {
+	case "":
+	case "local":
+		if c.StorageLocalPath == "" {
+			errs = append(errs, errors.New("STORAGE_LOCAL_PATH is required for local storage"))
+		}
+	case "s3":
+		if c.StorageS3Endpoint == "" || c.StorageS3Bucket == "" || c.StorageS3AccessKey == "" || c.StorageS3SecretKey == "" {
+			errs = append(errs, errors.New("STORAGE_S3_ENDPOINT, STORAGE_S3_BUCKET, STORAGE_S3_ACCESS_KEY and STORAGE_S3_SECRET_KEY are required for s3 storage"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("STORAGE_BACKEND must be local, s3 or empty, not %q", c.StorageBackend))
+	}
+	if c.StorageURLSecret != "" && len(c.StorageURLSecret) < MinSnapshotSecretLength {
+		errs = append(errs, fmt.Errorf("STORAGE_URL_SECRET must be at least %d characters", MinSnapshotSecretLength))
+	}
+	if c.RateLimitGlobal <= 0 {
+		errs = append(errs, errors.New("RATE_LIMIT_GLOBAL must be positive"))
+	}
+	if err := ValidateRateLimitModes(c.RateLimitModes); err != nil {
+		errs = append(errs, err)
+	}
+	for _, scope := range slices.Sorted(maps.Keys(c.RateLimitFailureModes)) {
+		switch mode := c.RateLimitFailureModes[scope]; strings.ToLower(strings.TrimSpace(mode)) {
+		case "open", "closed", "local":
+		default:
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_FAILURE_MODES: %s must be open, closed or local, not %q", scope, mode))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateRateLimitModes checks each mode in RATE_LIMIT_MODES
+func ValidateRateLimitModes(modes map[string]string) error {
+	scopes := make([]string, 0, len(modes))
+	for scope := range modes {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	var errs []error
+	for _, scope := range scopes {
+		switch mode := modes[scope]; strings.ToLower(strings.TrimSpace(mode)) {
+		case "enforce", "warn", "off":
+		default:
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_MODES: %s must be enforce, warn or off, not %q", scope, mode))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MaxTokenTTL is the longest an access token can last. Revoking every
+// session has to last this long.
+func (c *Config) MaxTokenTTL() time.Duration {
+	return max(c.JWTExpire, c.JWTRememberMeExpire)
+}
+
+// DisabledSecurityAlerts names the security alerts switched off
+func (c *Config) DisabledSecurityAlerts() []string {
+	var disabled []string
+	for _, alert := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"new_device", c.SecurityAlertNewDevice},
+		{"password_changed", c.SecurityAlertPasswordChanged},
+		{"email_changed", c.SecurityAlertEmailChanged},
+	} {
+		if !alert.enabled {
+			disabled = append(disabled, alert.name)
+		}
+	}
+	return disabled
+}
+
+// IsProduction reports whether the config points at production
+func (c *Config) IsProduction() bool {
+	switch strings.ToLower(c.Environment) {
+	case "production", "prod":
+		return true
+	}
+	return false
 }
 
 func getEnv(key, fallback string) string {
@@ -145,3 +745,78 @@ func getEnvAsFloat(key string, fallback float64) float64 {
 	}
 	return fallback
 }
+
+func getEnvAsBool(key string, fallback bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// getEnvAsMap parses a comma-separated list of name:value pairs
+func getEnvAsMap(key string) map[string]string {
+	return parseMap(getEnv(key, ""))
+}
+
+// parseJWTKeys keeps the order of JWT_SECRETS, and malformed entries for
+// Validate to report. Secrets may contain colons; IDs can't.
+func parseJWTKeys(value string) []JWTKey {
+	var keys []JWTKey
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		id, secret, _ := strings.Cut(entry, ":")
+		keys = append(keys, JWTKey{ID: id, Secret: secret})
+	}
+	return keys
+}
+
+func validateJWTKeys(keys []JWTKey) error {
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key.ID == "" || key.Secret == "" {
+			return errors.New("JWT_SECRETS entries must be kid:secret")
+		}
+		if seen[key.ID] {
+			return fmt.Errorf("JWT_SECRETS lists kid %q twice", key.ID)
+		}
+		seen[key.ID] = true
+	}
+	return nil
+}
+
+// validCORSOrigin accepts an origin such as https://shop.example.com or
+// http://localhost:3000, with an optional "*." wildcard before the host.
+// Paths aren't part of an origin.
+func validCORSOrigin(origin string) bool {
+	u, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+	return (u.Path == "" || u.Path == "/") && u.User == nil && u.RawQuery == "" && u.Fragment == ""
+}
+
+// parseList splits a comma-separated value, dropping empty entries
+func parseList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+func parseMap(value string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || name == "" || value == "" {
+			continue
+		}
+		result[name] = value
+	}
+	return result
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrAccessTokenNotFound is returned for a token ID the user doesn't own
+var ErrAccessTokenNotFound = errors.New("access token not found")
+
+// AccessTokenPrefix starts every personal access token, so they are told
+// apart from session JWTs and easy to spot in leaked text
+const AccessTokenPrefix = "pat_"
+
+// Personal access token scopes. A write token may also read.
+const (
+	ScopeUserRead  = "user:read"
+	ScopeUserWrite = "user:write"
+)
+
+// AccessTokenScopes are the scopes a token may be given
+var AccessTokenScopes = []string{ScopeUserRead, ScopeUserWrite}
+
+// AccessToken is a long-lived credential a user creates for scripts and
+// integrations. Only a hash of the secret is kept.
+type AccessToken struct {
+	ID     uint
+	UserID uint
+	Name   string
+	Scopes []string
+	// ExpiresAt is nil for a token that never expires
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// Expired reports whether the token is past its expiry at now
+func (t *AccessToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// Allows reports whether the token grants scope
+func (t *AccessToken) Allows(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope || (s == ScopeUserWrite && scope == ScopeUserRead) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrBulkJobNotFound is returned for an unknown bulk job ID
+var ErrBulkJobNotFound = errors.New("bulk job not found")
+
+// BulkAction is what a bulk job does to each of its users
+type BulkAction string
+
+const (
+	BulkSuspend            BulkAction = "suspend"
+	BulkUnsuspend          BulkAction = "unsuspend"
+	BulkForcePasswordReset BulkAction = "force-password-reset"
+)
+
+// BulkActions are the actions a bulk job may run
+var BulkActions = []BulkAction{BulkSuspend, BulkUnsuspend, BulkForcePasswordReset}
+
+// Valid reports whether a is a known action
+func (a BulkAction) Valid() bool {
+	for _, action := range BulkActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// BulkJobStatus is where a bulk job is in its life
+type BulkJobStatus string
+
+const (
+	BulkJobQueued  BulkJobStatus = "queued"
+	BulkJobRunning BulkJobStatus = "running"
+	BulkJobDone    BulkJobStatus = "done"
+)
+
+// BulkFailure is one user a bulk job couldn't act on, and why
+type BulkFailure struct {
+	UserID uint   `json:"user_id"`
+	Error  string `json:"error"`
+}
+
+// BulkJob applies one administrative action to a list of users in the
+// background. UserIDs are worked through in order; Processed is how many
+// have been tried, so an interrupted job picks up where it stopped.
+type BulkJob struct {
+	ID     uint
+	Action BulkAction
+	// Reason is recorded on each user's audit entry
+	Reason      string
+	RequestedBy string
+	UserIDs     []uint
+	Status      BulkJobStatus
+	Processed   int
+	Failures    []BulkFailure
+	// ReportKey is where the finished job's report is stored, if anywhere
+	ReportKey  string
+	CreatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// Total is how many users the job covers
+func (j *BulkJob) Total() int {
+	return len(j.UserIDs)
+}
+
+// Succeeded is how many users the job has acted on so far
+func (j *BulkJob) Succeeded() int {
+	return j.Processed - len(j.Failures)
+}
@@ -0,0 +1,9 @@
+package domain
+
+import "errors"
+
+// Errors shared by the repository and service layers
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrDuplicateUser = errors.New("user already exists")
+)
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Reasons an invite code can't be used to register
+var (
+	ErrInviteNotFound  = errors.New("invite not found")
+	ErrInviteRevoked   = errors.New("invite revoked")
+	ErrInviteExpired   = errors.New("invite expired")
+	ErrInviteExhausted = errors.New("invite has no uses left")
+)
+
+// Invite lets up to MaxUses accounts register while registration is
+// invite-only
+type Invite struct {
+	Code string
+	// CreatedBy names who minted the code, e.g. the admin API client
+	CreatedBy string
+	MaxUses   int
+	Uses      int
+	// ExpiresAt is nil for a code that never expires
+	ExpiresAt *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// Usable reports why the invite can't be redeemed at now, or nil if it can
+func (i *Invite) Usable(now time.Time) error {
+	switch {
+	case i.RevokedAt != nil:
+		return ErrInviteRevoked
+	case i.ExpiresAt != nil && !now.Before(*i.ExpiresAt):
+		return ErrInviteExpired
+	case i.Uses >= i.MaxUses:
+		return ErrInviteExhausted
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package domain
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrNoticeNotFound is returned for a code the user has no active
+	// notice under
+	ErrNoticeNotFound = errors.New("notice not found")
+	// ErrNoticeNotDismissible is returned for dismissing a notice that
+	// stays until the account state behind it changes
+	ErrNoticeNotDismissible = errors.New("notice cannot be dismissed")
+)
+
+// NoticeSeverity is how prominently a client should show a notice
+type NoticeSeverity string
+
+const (
+	NoticeInfo     NoticeSeverity = "info"
+	NoticeWarning  NoticeSeverity = "warning"
+	NoticeCritical NoticeSeverity = "critical"
+)
+
+// NoticeSeverities are the severities a notice may have, least severe first
+var NoticeSeverities = []NoticeSeverity{NoticeInfo, NoticeWarning, NoticeCritical}
+
+func (s NoticeSeverity) Valid() bool {
+	return s.rank() >= 0
+}
+
+func (s NoticeSeverity) rank() int {
+	for i, severity := range NoticeSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// Codes of the notices derived from account state. Authored notices can't
+// use them.
+const (
+	NoticeVerifyEmail     = "verify_email"
+	NoticePasswordReset   = "password_reset_required"
+	NoticeDeletionPending = "deletion_scheduled"
+	NoticeTermsUpdated    = "terms_updated"
+)
+
+// DerivedNoticeCodes are the codes ActiveNotices derives from account state
+var DerivedNoticeCodes = []string{NoticeVerifyEmail, NoticePasswordReset, NoticeDeletionPending, NoticeTermsUpdated}
+
+// Notice is something a user should see about their account
+type Notice struct {
+	Code        string
+	Severity    NoticeSeverity
+	Message     string
+	Dismissible bool
+	// Version tells revisions of a notice apart, so dismissing one doesn't
+	// hide the next (e.g. the terms of service version)
+	Version string
+	// ExpiresAt is when the notice stops applying; nil for open-ended
+	ExpiresAt *time.Time
+}
+
+// UserNotice is a notice an admin wrote for one user. A user has at most
+// one per code.
+type UserNotice struct {
+	ID          uint
+	UserID      uint
+	Code        string
+	Severity    NoticeSeverity
+	Message     string
+	Dismissible bool
+	// ExpiresAt is nil for a notice that stays until removed
+	ExpiresAt *time.Time
+	// CreatedBy is the API client that wrote the notice
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// Expired reports whether the notice is past its expiry at now
+func (n *UserNotice) Expired(now time.Time) bool {
+	return n.ExpiresAt != nil && !now.Before(*n.ExpiresAt)
+}
+
+// NoticeState is what ActiveNotices needs besides the user
+type NoticeState struct {
+	// TermsVersion is the current terms of service version, "" when terms
+	// aren't tracked. Accounts created at or after TermsUpdatedAt agreed to
+	// it at signup; a zero TermsUpdatedAt shows the notice to everyone.
+	TermsVersion   string
+	TermsUpdatedAt time.Time
+	// DeletionGracePeriod dates the erasure of a pending deletion
+	DeletionGracePeriod time.Duration
+	// Authored are the admin-written notices for the user
+	Authored []*UserNotice
+	// Dismissed maps the code of each notice the user dismissed to the
+	// version they dismissed
+	Dismissed map[string]string
+}
+
+// ActiveNotices returns the notices user should see at now, most severe
+// first and then by code. It only reads its arguments.
+func ActiveNotices(user *User, state NoticeState, now time.Time) []Notice {
+	var notices []Notice
+
+	if user.MustResetPassword {
+		notices = append(notices, Notice{
+			Code:     NoticePasswordReset,
+			Severity: NoticeCritical,
+			Message:  "Your password must be reset before you can continue.",
+		})
+	}
+	if user.IsPendingDeletion() && user.DeletionRequestedAt != nil {
+		erasure := user.DeletionRequestedAt.Add(state.DeletionGracePeriod)
+		notices = append(notices, Notice{
+			Code:      NoticeDeletionPending,
+			Severity:  NoticeWarning,
+			Message:   "Your account is scheduled for deletion. Cancel the request to keep it.",
+			ExpiresAt: &erasure,
+		})
+	}
+	if !user.IsEmailVerified() {
+		notices = append(notices, Notice{
+			Code:        NoticeVerifyEmail,
+			Severity:    NoticeWarning,
+			Message:     "Please verify your email address.",
+			Dismissible: true,
+		})
+	}
+	if state.TermsVersion != "" && (state.TermsUpdatedAt.IsZero() || user.CreatedAt.Before(state.TermsUpdatedAt)) {
+		notices = append(notices, Notice{
+			Code:        NoticeTermsUpdated,
+			Severity:    NoticeInfo,
+			Message:     "Our terms of service have been updated.",
+			Dismissible: true,
+			Version:     state.TermsVersion,
+		})
+	}
+	for _, authored := range state.Authored {
+		if authored.Expired(now) {
+			continue
+		}
+		notices = append(notices, Notice{
+			Code:        authored.Code,
+			Severity:    authored.Severity,
+			Message:     authored.Message,
+			Dismissible: authored.Dismissible,
+			// A reissued notice gets a new ID, so it shows again
+			Version:   strconv.FormatUint(uint64(authored.ID), 10),
+			ExpiresAt: authored.ExpiresAt,
+		})
+	}
+
+	active := notices[:0]
+	for _, notice := range notices {
+		if version, ok := state.Dismissed[notice.Code]; ok && notice.Dismissible && version == notice.Version {
+			continue
+		}
+		active = append(active, notice)
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		if ri, rj := active[i].Severity.rank(), active[j].Severity.rank(); ri != rj {
+			return ri > rj
+		}
+		return active[i].Code < active[j].Code
+	})
+	return active
+}
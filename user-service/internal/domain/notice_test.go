@@ -0,0 +1,140 @@
+// internal/domain/notice_test.go
+package domain
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestActiveNotices(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	verified := now.Add(-48 * time.Hour)
+	requested := now.Add(-24 * time.Hour)
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+
+	active := func() *User {
+		return &User{Status: StatusActive, EmailVerifiedAt: &verified, CreatedAt: now.Add(-72 * time.Hour)}
+	}
+
+	tests := []struct {
+		name      string
+		user      func(u *User)
+		state     NoticeState
+		wantCodes []string
+	}{
+		{name: "nothing to say", wantCodes: nil},
+		{
+			name:      "unverified email",
+			user:      func(u *User) { u.EmailVerifiedAt = nil },
+			wantCodes: []string{NoticeVerifyEmail},
+		},
+		{
+			name:      "unverified email dismissed",
+			user:      func(u *User) { u.EmailVerifiedAt = nil },
+			state:     NoticeState{Dismissed: map[string]string{NoticeVerifyEmail: ""}},
+			wantCodes: nil,
+		},
+		{
+			name:      "password reset required",
+			user:      func(u *User) { u.MustResetPassword = true },
+			wantCodes: []string{NoticePasswordReset},
+		},
+		{
+			name:      "password reset can't be dismissed",
+			user:      func(u *User) { u.MustResetPassword = true },
+			state:     NoticeState{Dismissed: map[string]string{NoticePasswordReset: ""}},
+			wantCodes: []string{NoticePasswordReset},
+		},
+		{
+			name: "pending deletion",
+			user: func(u *User) {
+				u.Status = StatusPendingDeletion
+				u.DeletionRequestedAt = &requested
+			},
+			wantCodes: []string{NoticeDeletionPending},
+		},
+		{
+			name:      "terms updated after signup",
+			state:     NoticeState{TermsVersion: "2024-05", TermsUpdatedAt: now.Add(-time.Hour)},
+			wantCodes: []string{NoticeTermsUpdated},
+		},
+		{
+			name:      "terms agreed at signup",
+			user:      func(u *User) { u.CreatedAt = now },
+			state:     NoticeState{TermsVersion: "2024-05", TermsUpdatedAt: now.Add(-time.Hour)},
+			wantCodes: nil,
+		},
+		{
+			name:      "terms version dismissed",
+			state:     NoticeState{TermsVersion: "2024-05", Dismissed: map[string]string{NoticeTermsUpdated: "2024-05"}},
+			wantCodes: nil,
+		},
+		{
+			name:      "newer terms after a dismissal",
+			state:     NoticeState{TermsVersion: "2024-06", Dismissed: map[string]string{NoticeTermsUpdated: "2024-05"}},
+			wantCodes: []string{NoticeTermsUpdated},
+		},
+		{
+			name: "authored notices until they expire",
+			state: NoticeState{Authored: []*UserNotice{
+				{ID: 1, Code: "billing_issue", Severity: NoticeCritical, ExpiresAt: &later},
+				{ID: 2, Code: "old_promo", Severity: NoticeInfo, ExpiresAt: &earlier},
+			}},
+			wantCodes: []string{"billing_issue"},
+		},
+		{
+			name: "reissued authored notice shows again",
+			state: NoticeState{
+				Authored:  []*UserNotice{{ID: 3, Code: "survey", Severity: NoticeInfo, Dismissible: true}},
+				Dismissed: map[string]string{"survey": "2"},
+			},
+			wantCodes: []string{"survey"},
+		},
+		{
+			name: "everything, most severe first",
+			user: func(u *User) {
+				u.EmailVerifiedAt = nil
+				u.MustResetPassword = true
+				u.Status = StatusPendingDeletion
+				u.DeletionRequestedAt = &requested
+			},
+			state: NoticeState{
+				TermsVersion: "2024-05",
+				Authored:     []*UserNotice{{ID: 1, Code: "maintenance", Severity: NoticeInfo}},
+			},
+			wantCodes: []string{NoticePasswordReset, NoticeDeletionPending, NoticeVerifyEmail, "maintenance", NoticeTermsUpdated},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := active()
+			if tt.user != nil {
+				tt.user(user)
+			}
+			var codes []string
+			for _, notice := range ActiveNotices(user, tt.state, now) {
+				codes = append(codes, notice.Code)
+			}
+			if !reflect.DeepEqual(codes, tt.wantCodes) {
+				t.Errorf("got %v, want %v", codes, tt.wantCodes)
+			}
+		})
+	}
+}
+
+func TestActiveNotices_DatesTheDeletion(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)
+	requested := now.Add(-24 * time.Hour)
+	user := &User{Status: StatusPendingDeletion, DeletionRequestedAt: &requested, EmailVerifiedAt: &now}
+
+	notices := ActiveNotices(user, NoticeState{DeletionGracePeriod: 30 * 24 * time.Hour}, now)
+	if len(notices) != 1 || notices[0].Dismissible {
+		t.Fatalf("expected one undismissible notice, got %+v", notices)
+	}
+	if want := requested.Add(30 * 24 * time.Hour); notices[0].ExpiresAt == nil || !notices[0].ExpiresAt.Equal(want) {
+		t.Errorf("expected it to end at erasure %v, got %v", want, notices[0].ExpiresAt)
+	}
+}
@@ -0,0 +1,46 @@
+package domain
+
+// NotificationCategory classifies the email sent to users so it can be
+// matched against their preferences
+type NotificationCategory string
+
+const (
+	// NotificationTransactional mail answers something the user just did,
+	// such as an address verification or password reset link
+	NotificationTransactional NotificationCategory = "transactional"
+	// NotificationSecurityCritical mail reports a change to the account's
+	// credentials, such as a new email address or password
+	NotificationSecurityCritical NotificationCategory = "security_critical"
+	// NotificationSecurityAlert mail warns about activity worth a look,
+	// such as a sign-in from a new device
+	NotificationSecurityAlert NotificationCategory = "security_alert"
+	NotificationMarketing     NotificationCategory = "marketing"
+	NotificationProductUpdate NotificationCategory = "product_update"
+)
+
+// NotificationPreferences are the optional categories a user has chosen.
+// The zero value is what new accounts get: security alerts on, marketing
+// and product updates off. Transactional and security-critical mail has
+// no switch because it can't be turned off.
+type NotificationPreferences struct {
+	SecurityAlertsMuted bool
+	Marketing           bool
+	ProductUpdates      bool
+}
+
+// Allows reports whether mail in category may be sent. Unknown categories
+// are refused, so new kinds of mail must be classified before they go out.
+func (p NotificationPreferences) Allows(category NotificationCategory) bool {
+	switch category {
+	case NotificationTransactional, NotificationSecurityCritical:
+		return true
+	case NotificationSecurityAlert:
+		return !p.SecurityAlertsMuted
+	case NotificationMarketing:
+		return p.Marketing
+	case NotificationProductUpdate:
+		return p.ProductUpdates
+	default:
+		return false
+	}
+}
@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Preferences are a user's display settings, such as their locale and
+// theme. They are stored as one JSON document, so a new setting needs an
+// entry in PreferenceSchema rather than a migration. Values are what
+// encoding/json decodes into an interface{}.
+type Preferences map[string]interface{}
+
+// PreferenceKind is the JSON type a preference holds
+type PreferenceKind string
+
+const (
+	PreferenceString PreferenceKind = "string"
+	PreferenceBool   PreferenceKind = "boolean"
+	PreferenceObject PreferenceKind = "object"
+)
+
+// PreferenceSpec is what one preference key accepts
+type PreferenceSpec struct {
+	Kind PreferenceKind
+	// Values, when set, are the only strings accepted
+	Values []string
+	// Pattern, when set, is what a string must match
+	Pattern *regexp.Regexp
+	// Fields are an object's own keys
+	Fields map[string]PreferenceSpec
+}
+
+// localePattern is a BCP 47 tag as clients send it, such as "en" or "pt-BR"
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// PreferenceSchema lists the preferences a user may set. Anything else is
+// refused, so the document can't fill up with whatever clients send.
+var PreferenceSchema = map[string]PreferenceSpec{
+	"locale": {Kind: PreferenceString, Pattern: localePattern},
+	"theme":  {Kind: PreferenceString, Values: []string{"light", "dark", "system"}},
+	"accessibility": {Kind: PreferenceObject, Fields: map[string]PreferenceSpec{
+		"reduced_motion": {Kind: PreferenceBool},
+		"high_contrast":  {Kind: PreferenceBool},
+	}},
+}
+
+// PreferenceError is a key or value Preferences.Merge refused
+type PreferenceError struct {
+	// Key is the dotted path to the key, such as "accessibility.high_contrast"
+	Key string
+	// Unknown is set when the key isn't in the schema at all
+	Unknown bool
+	// Accepted are the keys the key's object takes, set when Unknown
+	Accepted []string
+	Reason   string
+}
+
+func (e *PreferenceError) Error() string {
+	return fmt.Sprintf("preference %q: %s", e.Key, e.Reason)
+}
+
+// Merge returns p with patch applied as a JSON merge patch: objects merge
+// key by key, null removes a key and anything else replaces it. The patch
+// is checked against PreferenceSchema first, and an error is always a
+// *PreferenceError. p itself is never modified.
+func (p Preferences) Merge(patch map[string]interface{}) (Preferences, error) {
+	if err := checkPreferences("", PreferenceSchema, patch); err != nil {
+		return nil, err
+	}
+	return Preferences(mergePreferences(p, patch)), nil
+}
+
+// Equal reports whether p and other hold the same settings
+func (p Preferences) Equal(other Preferences) bool {
+	if len(p) == 0 && len(other) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(p, other)
+}
+
+func checkPreferences(prefix string, schema map[string]PreferenceSpec, patch map[string]interface{}) error {
+	for key, value := range patch {
+		path := prefix + key
+		spec, ok := schema[key]
+		if !ok {
+			return &PreferenceError{Key: path, Unknown: true, Accepted: preferenceKeys(schema), Reason: "unknown preference"}
+		}
+		// null clears the key, whatever its kind
+		if value == nil {
+			continue
+		}
+		if err := spec.check(path, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (spec PreferenceSpec) check(path string, value interface{}) error {
+	switch spec.Kind {
+	case PreferenceBool:
+		if _, ok := value.(bool); !ok {
+			return &PreferenceError{Key: path, Reason: "must be a boolean"}
+		}
+	case PreferenceString:
+		s, ok := value.(string)
+		if !ok {
+			return &PreferenceError{Key: path, Reason: "must be a string"}
+		}
+		if len(spec.Values) > 0 && !contains(spec.Values, s) {
+			return &PreferenceError{Key: path, Reason: "must be one of " + strings.Join(spec.Values, ", ")}
+		}
+		if spec.Pattern != nil && !spec.Pattern.MatchString(s) {
+			return &PreferenceError{Key: path, Reason: "is not in a recognized format"}
+		}
+	case PreferenceObject:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return &PreferenceError{Key: path, Reason: "must be an object"}
+		}
+		return checkPreferences(path+".", spec.Fields, fields)
+	}
+	return nil
+}
+
+// mergePreferences applies patch to a copy of base, copying the objects it
+// descends into so base shares nothing that changed. Objects left empty
+// are dropped.
+func mergePreferences(base, patch map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(patch))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range patch {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		if fields, ok := value.(map[string]interface{}); ok {
+			current, _ := merged[key].(map[string]interface{})
+			nested := mergePreferences(current, fields)
+			if len(nested) == 0 {
+				delete(merged, key)
+				continue
+			}
+			value = nested
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// preferenceKeys lists schema's keys in order
+func preferenceKeys(schema map[string]PreferenceSpec) []string {
+	keys := make([]string, 0, len(schema))
+	for key := range schema {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
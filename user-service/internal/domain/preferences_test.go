@@ -0,0 +1,82 @@
+// internal/domain/preferences_test.go
+package domain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPreferences_MergeDeepMerges(t *testing.T) {
+	current := Preferences{
+		"locale":        "en",
+		"theme":         "dark",
+		"accessibility": map[string]interface{}{"reduced_motion": true, "high_contrast": false},
+	}
+
+	merged, err := current.Merge(map[string]interface{}{
+		"theme":         nil,
+		"locale":        "pt-BR",
+		"accessibility": map[string]interface{}{"high_contrast": true},
+	})
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	want := Preferences{
+		"locale":        "pt-BR",
+		"accessibility": map[string]interface{}{"reduced_motion": true, "high_contrast": true},
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("expected %v, got %v", want, merged)
+	}
+	if current["theme"] != "dark" || current["accessibility"].(map[string]interface{})["high_contrast"] != false {
+		t.Errorf("expected the original left alone, got %v", current)
+	}
+
+	// An object whose keys are all cleared goes with them
+	merged, err = merged.Merge(map[string]interface{}{
+		"accessibility": map[string]interface{}{"reduced_motion": nil, "high_contrast": nil},
+	})
+	if err != nil || !reflect.DeepEqual(merged, Preferences{"locale": "pt-BR"}) {
+		t.Errorf("expected only the locale left, got %v (%v)", merged, err)
+	}
+	if !merged.Equal(Preferences{"locale": "pt-BR"}) || !Preferences(nil).Equal(Preferences{}) {
+		t.Error("expected Equal to compare contents")
+	}
+}
+
+func TestPreferences_MergeChecksTheSchema(t *testing.T) {
+	tests := []struct {
+		name        string
+		patch       map[string]interface{}
+		wantKey     string
+		wantUnknown bool
+		wantAccepts []string
+	}{
+		{name: "unknown key", patch: map[string]interface{}{"colour": "red"},
+			wantKey: "colour", wantUnknown: true, wantAccepts: []string{"accessibility", "locale", "theme"}},
+		{name: "unknown nested key", patch: map[string]interface{}{"accessibility": map[string]interface{}{"font_size": 2.0}},
+			wantKey: "accessibility.font_size", wantUnknown: true, wantAccepts: []string{"high_contrast", "reduced_motion"}},
+		{name: "wrong type", patch: map[string]interface{}{"theme": true}, wantKey: "theme"},
+		{name: "not among the values", patch: map[string]interface{}{"theme": "sepia"}, wantKey: "theme"},
+		{name: "malformed locale", patch: map[string]interface{}{"locale": "English"}, wantKey: "locale"},
+		{name: "scalar for an object", patch: map[string]interface{}{"accessibility": true}, wantKey: "accessibility"},
+		{name: "wrong nested type", patch: map[string]interface{}{"accessibility": map[string]interface{}{"high_contrast": "yes"}},
+			wantKey: "accessibility.high_contrast"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Preferences{"theme": "dark"}.Merge(tt.patch)
+			var prefErr *PreferenceError
+			if !errors.As(err, &prefErr) {
+				t.Fatalf("expected a PreferenceError, got %v", err)
+			}
+			if prefErr.Key != tt.wantKey || prefErr.Unknown != tt.wantUnknown {
+				t.Errorf("expected %q (unknown %v), got %+v", tt.wantKey, tt.wantUnknown, prefErr)
+			}
+			if tt.wantAccepts != nil && !reflect.DeepEqual(prefErr.Accepted, tt.wantAccepts) {
+				t.Errorf("expected %v accepted, got %v", tt.wantAccepts, prefErr.Accepted)
+			}
+		})
+	}
+}
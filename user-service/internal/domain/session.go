@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrSessionNotFound is returned for a session ID the user doesn't have,
+// or no longer has active
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is one login: the device it came from and every token issued
+// for it, including those refreshed since. Revoking it signs that device
+// out.
+type Session struct {
+	// ID is random, and is the sid claim of the session's access tokens
+	ID        string
+	UserID    uint
+	UserAgent string
+	IP        string
+	CreatedAt time.Time
+	// LastUsedAt is the login or the latest refresh
+	LastUsedAt time.Time
+	// ExpiresAt is when the session's refresh tokens have all run out;
+	// each refresh pushes it back
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
+
+// Active reports whether the session can still be used at now
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -6,23 +6,142 @@ import (
 	"gorm.io/gorm"
 )
 
+// UserStatus is the lifecycle state of an account
+type UserStatus string
+
+const (
+	StatusActive UserStatus = "active"
+	StatusBanned UserStatus = "banned"
+	// StatusPendingDeletion accounts are erased once the grace period ends
+	StatusPendingDeletion UserStatus = "pending_deletion"
+	// StatusErased accounts have had their personal data anonymized
+	StatusErased UserStatus = "erased"
+)
+
+// Role decides what an account may do beyond managing itself
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleUser || r == RoleAdmin
+}
+
+// UserSortField is what a user listing can be ordered by
+type UserSortField string
+
+const (
+	SortByCreatedAt UserSortField = "created_at"
+	SortByUsername  UserSortField = "username"
+	SortByEmail     UserSortField = "email"
+	SortByLastLogin UserSortField = "last_login"
+)
+
+// UserSortFields are all the fields a listing can be ordered by
+var UserSortFields = []UserSortField{SortByCreatedAt, SortByUsername, SortByEmail, SortByLastLogin}
+
+func (f UserSortField) Valid() bool {
+	for _, field := range UserSortFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// SortOrder is the direction of a UserSort
+type SortOrder string
+
+const (
+	SortAscending  SortOrder = "asc"
+	SortDescending SortOrder = "desc"
+)
+
+func (o SortOrder) Valid() bool {
+	return o == SortAscending || o == SortDescending
+}
+
+// UserSort orders a user listing. Users the field doesn't tell apart, and
+// those who never logged in when sorting by last login, keep a fixed order
+// among themselves so pages don't overlap.
+type UserSort struct {
+	Field UserSortField
+	Order SortOrder
+}
+
+// DefaultUserSort is newest accounts first
+var DefaultUserSort = UserSort{Field: SortByCreatedAt, Order: SortDescending}
+
+// UserFilter narrows a user listing; its zero fields don't
+type UserFilter struct {
+	// Statuses keeps users in any of these states
+	Statuses []UserStatus
+	// Query keeps users whose username or email contains it, in any case
+	Query string
+	// CreatedAfter and CreatedBefore bound when the account was created,
+	// the first inclusively and the second not, so consecutive ranges
+	// count every signup once
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
 type User struct {
 	ID        uint
 	Username  string
 	Email     string
-	Password  string
 	FirstName string
 	LastName  string
-	LastLogin *time.Time
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt
+	Status    UserStatus
+	Role      Role
+	// EmailVerifiedAt is nil until the user confirms their address
+	EmailVerifiedAt *time.Time
+	// DeletionRequestedAt starts the erasure grace period
+	DeletionRequestedAt *time.Time
+	// MustResetPassword limits the next login to choosing a new password,
+	// e.g. after the old one turned up in a credential dump
+	MustResetPassword bool
+	// TokenVersion goes up when the user logs out everywhere; tokens
+	// issued under an older version are refused
+	TokenVersion  uint
+	Notifications NotificationPreferences
+	Preferences   Preferences
+	LastLogin     *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt
+}
+
+// Credentials are what an account proves its owner with. They are read and
+// written apart from User, so nothing that caches, serializes or publishes
+// a User can carry the password hash.
+type Credentials struct {
+	UserID       uint
+	PasswordHash string
 }
 
 func (u *User) IsDeleted() bool {
 	return u.DeletedAt.Valid
 }
 
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) IsBanned() bool {
+	return u.Status == StatusBanned
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+func (u *User) IsPendingDeletion() bool {
+	return u.Status == StatusPendingDeletion
+}
+
 func (u *User) FullName() string {
 	return u.FirstName + " " + u.LastName
 }
@@ -1,54 +1,324 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type JWTManager struct {
-	secret     []byte
+	// method signs with signKey; tokens verify with verifyKey and only
+	// under method, so an HMAC token can't pass as an RS256 one
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	keyID     string
+	// previousKeys still verify tokens signed before a rotation
+	previousKeys []verificationKey
+	// issuer is set on every token and required of the ones validated;
+	// audience likewise, when there is one
+	issuer     string
+	audience   []string
 	expiration time.Duration
+	// maxExpiration caps WithExpiry; zero leaves it uncapped
+	maxExpiration time.Duration
+	now           func() time.Time
+	observer      Observer
+
+	// refreshTokens is nil unless WithRefreshTokens was given
+	refreshTokens     RefreshTokenStore
+	refreshExpiration time.Duration
+}
+
+// JWT operations and their outcomes, as reported to an Observer
+const (
+	OperationGenerate = "generate"
+	OperationValidate = "validate"
+
+	OutcomeOK = "ok"
+	// OutcomeError is a token that couldn't be signed
+	OutcomeError        = "error"
+	OutcomeExpired      = "expired"
+	OutcomeBadSignature = "bad_signature"
+	OutcomeMalformed    = "malformed"
+	// OutcomeWrongIssuer and OutcomeWrongAudience are tokens meant for
+	// another service
+	OutcomeWrongIssuer   = "wrong_issuer"
+	OutcomeWrongAudience = "wrong_audience"
+	// OutcomeInvalid covers the other claim checks, e.g. not valid yet
+	OutcomeInvalid = "invalid"
+)
+
+// DefaultIssuer is the iss of tokens from a manager without WithIssuer
+const DefaultIssuer = "user-service"
+
+// ErrForeignToken is a validly signed token issued by someone else or for
+// another audience, e.g. minted by a service sharing the secret, or one
+// missing the iss or aud claim. It wraps the jwt error saying which claim
+// didn't match.
+var ErrForeignToken = errors.New("token is not meant for this service")
+
+// Observer receives the outcome and duration of each JWT operation
+type Observer interface {
+	ObserveJWT(operation, outcome string, duration time.Duration)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) ObserveJWT(operation, outcome string, duration time.Duration) {}
+
+// ValidationOutcome classifies an error from ValidateToken
+func ValidationOutcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeOK
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return OutcomeExpired
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return OutcomeBadSignature
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return OutcomeMalformed
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return OutcomeWrongIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return OutcomeWrongAudience
+	default:
+		return OutcomeInvalid
+	}
 }
 
 type Claims struct {
 	UserID uint `json:"user_id"`
+	// Scopes narrows what the token may do; none means a full user session
+	Scopes []string `json:"scopes,omitempty"`
+	// Impersonator is the admin acting as UserID, if any
+	Impersonator uint `json:"impersonator,omitempty"`
+	// Role is the user's role when the token was issued
+	Role string `json:"role,omitempty"`
+	// TokenVersion is the user's token version when the token was issued
+	TokenVersion uint `json:"token_version,omitempty"`
+	// SessionID is the login the token belongs to; tokens from a pair
+	// carry their refresh token family
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func NewJWTManager(secret string, expire time.Duration) *JWTManager {
-	return &JWTManager{
-		secret:     []byte(secret),
+// ScopeAccountRecovery marks a session opened with a recovery code. It is
+// only accepted where changing the email or password is allowed.
+const ScopeAccountRecovery = "account:recover"
+
+// ScopePasswordReset marks the session Login opens for an account that
+// must reset its password. It is only accepted for changing the password.
+const ScopePasswordReset = "password:reset"
+
+// TokenOption adds optional claims to a generated token
+type TokenOption func(*Claims)
+
+// WithExpiry makes the token expire d after it is issued instead of after
+// the manager's default, up to WithMaxExpiry
+func WithExpiry(d time.Duration) TokenOption {
+	return func(c *Claims) {
+		c.ExpiresAt = jwt.NewNumericDate(c.IssuedAt.Time.Add(d))
+	}
+}
+
+// HasScope reports whether the token carries scope
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// WithScopes limits the token to the given scopes
+func WithScopes(scopes ...string) TokenOption {
+	return func(c *Claims) {
+		c.Scopes = scopes
+	}
+}
+
+// WithImpersonator marks the token as issued to adminID acting as the user
+func WithImpersonator(adminID uint) TokenOption {
+	return func(c *Claims) {
+		c.Impersonator = adminID
+	}
+}
+
+// WithRole records the user's role in the token. The role is fixed for
+// the token's lifetime, so changing it should revoke the user's sessions.
+func WithRole(role string) TokenOption {
+	return func(c *Claims) {
+		c.Role = role
+	}
+}
+
+// WithTokenVersion stamps the token with the user's token version, so
+// raising the version ends it
+func WithTokenVersion(version uint) TokenOption {
+	return func(c *Claims) {
+		c.TokenVersion = version
+	}
+}
+
+// WithSessionID ties the token to a session, so revoking the session ends
+// it
+func WithSessionID(id string) TokenOption {
+	return func(c *Claims) {
+		c.SessionID = id
+	}
+}
+
+// JWTOption configures optional JWTManager behavior
+type JWTOption func(*JWTManager)
+
+// WithObserver reports every GenerateToken and ValidateToken call
+func WithObserver(observer Observer) JWTOption {
+	return func(j *JWTManager) {
+		j.observer = observer
+	}
+}
+
+// WithClock sets the time source used to stamp and validate tokens
+func WithClock(now func() time.Time) JWTOption {
+	return func(j *JWTManager) {
+		j.now = now
+	}
+}
+
+// WithIssuer names who issues the tokens, instead of DefaultIssuer.
+// Tokens with another iss are rejected.
+func WithIssuer(issuer string) JWTOption {
+	return func(j *JWTManager) {
+		j.issuer = issuer
+	}
+}
+
+// WithAudience sets aud on every token to the services meant to accept
+// it, and rejects tokens whose aud names none of them. Without it tokens
+// have no aud and any is accepted.
+func WithAudience(audience ...string) JWTOption {
+	return func(j *JWTManager) {
+		j.audience = audience
+	}
+}
+
+// WithMaxExpiry caps how long after issue a token may expire, whatever
+// WithExpiry asks for. Anything that must outlive every token, such as a
+// revocation, has to last this long.
+func WithMaxExpiry(d time.Duration) JWTOption {
+	return func(j *JWTManager) {
+		j.maxExpiration = d
+	}
+}
+
+// NewJWTManager signs tokens with HS256 and secret, which every service
+// verifying them must share. See NewJWTManagerRS256 and
+// NewJWTManagerEdDSA for keys others can verify with.
+func NewJWTManager(secret string, expire time.Duration, opts ...JWTOption) *JWTManager {
+	j := &JWTManager{
+		method:     jwt.SigningMethodHS256,
+		signKey:    []byte(secret),
+		verifyKey:  []byte(secret),
+		issuer:     DefaultIssuer,
 		expiration: expire,
+		now:        time.Now,
+		observer:   nopObserver{},
 	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// GenerateToken issues a token for userID. Every token gets a random jti so
+// a specific one can be told apart in logs and support requests.
+func (j *JWTManager) GenerateToken(userID uint, opts ...TokenOption) (string, error) {
+	token, _, err := j.IssueToken(userID, opts...)
+	return token, err
 }
 
-func (j *JWTManager) GenerateToken(userID uint) (string, error) {
-	claims := &Claims{
+// IssueToken is GenerateToken also returning the token's claims, e.g. to
+// tell the client when it expires
+func (j *JWTManager) IssueToken(userID uint, opts ...TokenOption) (token string, claims *Claims, err error) {
+	start := time.Now()
+	defer func() {
+		outcome := OutcomeOK
+		if err != nil {
+			outcome = OutcomeError
+		}
+		j.observer.ObserveJWT(OperationGenerate, outcome, time.Since(start))
+	}()
+
+	id, err := randomHex(16)
+	if err != nil {
+		return "", nil, err
+	}
+
+	claims = &Claims{
 		UserID: userID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.expiration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "user-service",
+			ID:        id,
+			ExpiresAt: jwt.NewNumericDate(j.now().Add(j.expiration)),
+			IssuedAt:  jwt.NewNumericDate(j.now()),
+			Issuer:    j.issuer,
+			Audience:  j.audience,
 		},
 	}
+	for _, opt := range opts {
+		opt(claims)
+	}
+	if j.maxExpiration > 0 && claims.ExpiresAt.Sub(claims.IssuedAt.Time) > j.maxExpiration {
+		claims.ExpiresAt = jwt.NewNumericDate(claims.IssuedAt.Add(j.maxExpiration))
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	return token.SignedString(j.secret)
+	unsigned := jwt.NewWithClaims(j.method, claims)
+	if j.keyID != "" {
+		unsigned.Header["kid"] = j.keyID
+	}
+	if token, err = unsigned.SignedString(j.signKey); err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
 }
 
-// ValidationToken: parse token and verify claims
+// ValidationToken: parse token and verify claims. ValidationOutcome says
+// why a token was rejected.
 func (j *JWTManager) ValidateToken(tokenStr string) (*Claims, error) {
+	start := time.Now()
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return j.secret, nil
-	})
+	parserOpts := []jwt.ParserOption{
+		jwt.WithTimeFunc(j.now),
+		jwt.WithValidMethods([]string{j.method.Alg()}),
+		jwt.WithIssuer(j.issuer),
+	}
+	if len(j.audience) > 0 {
+		parserOpts = append(parserOpts, jwt.WithAudience(j.audience...))
+	}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, j.verificationKey, parserOpts...)
+	j.observer.ObserveJWT(OperationValidate, ValidationOutcome(err), time.Since(start))
 
+	// iss and aud are the only claims the parser requires
+	if errors.Is(err, jwt.ErrTokenInvalidIssuer) || errors.Is(err, jwt.ErrTokenInvalidAudience) ||
+		errors.Is(err, jwt.ErrTokenRequiredClaimMissing) {
+		return nil, fmt.Errorf("%w: %w", ErrForeignToken, err)
+	}
 	if err != nil || !token.Valid {
 		return nil, err
 	}
 
 	return claims, nil
 }
+
+// ExpiresIn returns how long the validated claims remain valid, by the
+// manager's clock
+func (j *JWTManager) ExpiresIn(claims *Claims) time.Duration {
+	if claims.ExpiresAt == nil {
+		return 0
+	}
+	return claims.ExpiresAt.Time.Sub(j.now())
+}
@@ -0,0 +1,93 @@
+// internal/infrastructure/auth/jwt_test.go
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestValidateToken_IssuerAndAudience(t *testing.T) {
+	users := NewJWTManager("shared-secret", time.Hour, WithAudience("user-service", "cart-service"))
+	token, err := users.GenerateToken(1)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	claims, err := users.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("expected the manager's own token valid, got %v", err)
+	}
+	if claims.Issuer != DefaultIssuer || len(claims.Audience) != 2 {
+		t.Errorf("expected iss %s and both audiences, got %q %v", DefaultIssuer, claims.Issuer, claims.Audience)
+	}
+
+	// Any one listed audience is enough
+	cart := NewJWTManager("shared-secret", time.Hour, WithAudience("cart-service"))
+	if _, err := cart.ValidateToken(token); err != nil {
+		t.Errorf("expected the cart service to accept the token, got %v", err)
+	}
+
+	// Tokens other services mint with the same secret
+	billing, _ := NewJWTManager("shared-secret", time.Hour, WithAudience("billing-service")).GenerateToken(1)
+	other, _ := NewJWTManager("shared-secret", time.Hour, WithIssuer("billing-service"), WithAudience("user-service")).GenerateToken(1)
+	noAudience, _ := NewJWTManager("shared-secret", time.Hour).GenerateToken(1)
+
+	tests := []struct {
+		name    string
+		token   string
+		outcome string
+	}{
+		{"other audience", billing, OutcomeWrongAudience},
+		{"no audience", noAudience, OutcomeInvalid},
+		{"other issuer", other, OutcomeWrongIssuer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := users.ValidateToken(tt.token)
+			if !errors.Is(err, ErrForeignToken) {
+				t.Fatalf("expected ErrForeignToken, got %v", err)
+			}
+			if got := ValidationOutcome(err); got != tt.outcome {
+				t.Errorf("expected outcome %s, got %s", tt.outcome, got)
+			}
+		})
+	}
+
+	// A manager without an audience ignores aud but still checks iss
+	plain := NewJWTManager("shared-secret", time.Hour)
+	if _, err := plain.ValidateToken(billing); err != nil {
+		t.Errorf("expected any audience accepted, got %v", err)
+	}
+	if _, err := plain.ValidateToken(other); !errors.Is(err, jwt.ErrTokenInvalidIssuer) {
+		t.Errorf("expected the other issuer refused, got %v", err)
+	}
+}
+
+func TestIssueToken_Expiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	j := NewJWTManager("secret", 15*time.Minute, WithMaxExpiry(30*24*time.Hour),
+		WithClock(func() time.Time { return now }))
+
+	tests := []struct {
+		name string
+		opts []TokenOption
+		want time.Duration
+	}{
+		{"default", nil, 15 * time.Minute},
+		{"longer", []TokenOption{WithExpiry(30 * 24 * time.Hour)}, 30 * 24 * time.Hour},
+		{"capped", []TokenOption{WithExpiry(365 * 24 * time.Hour)}, 30 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, claims, err := j.IssueToken(1, tt.opts...)
+			if err != nil {
+				t.Fatalf("issue: %v", err)
+			}
+			if want := now.Add(tt.want); !claims.ExpiresAt.Time.Equal(want) {
+				t.Errorf("expected expiry %s, got %s", want, claims.ExpiresAt.Time)
+			}
+		})
+	}
+}
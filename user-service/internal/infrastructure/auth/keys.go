@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms, as named by JWT_ALGORITHM and in the token header
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// MinRSAKeyBits is the smallest RSA key NewJWTManagerRS256 accepts
+const MinRSAKeyBits = 2048
+
+// JWK is a public verification key in RFC 7517 form
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	// RSA modulus and exponent
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// OKP curve and public key, for Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at a JWKS URL
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWTManagerRS256 signs tokens with an RSA private key so other
+// services can verify them with the public key alone. Both keys are PEM;
+// publicKeyPEM may be empty, in which case it is derived from the private
+// key, and otherwise must match it.
+func NewJWTManagerRS256(privateKeyPEM, publicKeyPEM []byte, expire time.Duration, opts ...JWTOption) (*JWTManager, error) {
+	private, err := jwt.ParseRSAPrivateKeyFromPEM(privateKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse RSA private key: %w", err)
+	}
+	if bits := private.N.BitLen(); bits < MinRSAKeyBits {
+		return nil, fmt.Errorf("RSA key is %d bits, at least %d are required", bits, MinRSAKeyBits)
+	}
+	public := &private.PublicKey
+	if len(publicKeyPEM) > 0 {
+		if public, err = jwt.ParseRSAPublicKeyFromPEM(publicKeyPEM); err != nil {
+			return nil, fmt.Errorf("failed to parse RSA public key: %w", err)
+		}
+		if !public.Equal(&private.PublicKey) {
+			return nil, errors.New("RSA public key does not match the private key")
+		}
+	}
+	return newAsymmetricJWTManager(jwt.SigningMethodRS256, private, public, expire, opts)
+}
+
+// NewJWTManagerEdDSA is NewJWTManagerRS256 with an Ed25519 key pair
+func NewJWTManagerEdDSA(privateKeyPEM, publicKeyPEM []byte, expire time.Duration, opts ...JWTOption) (*JWTManager, error) {
+	parsed, err := jwt.ParseEdPrivateKeyFromPEM(privateKeyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Ed25519 private key: %w", err)
+	}
+	private, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an Ed25519 key")
+	}
+	public := private.Public().(ed25519.PublicKey)
+	if len(publicKeyPEM) > 0 {
+		parsed, err := jwt.ParseEdPublicKeyFromPEM(publicKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Ed25519 public key: %w", err)
+		}
+		if given, ok := parsed.(ed25519.PublicKey); !ok || !given.Equal(public) {
+			return nil, errors.New("Ed25519 public key does not match the private key")
+		}
+	}
+	return newAsymmetricJWTManager(jwt.SigningMethodEdDSA, private, public, expire, opts)
+}
+
+func newAsymmetricJWTManager(method jwt.SigningMethod, private crypto.Signer, public crypto.PublicKey, expire time.Duration, opts []JWTOption) (*JWTManager, error) {
+	j := NewJWTManager("", expire, opts...)
+	j.method, j.signKey, j.verifyKey = method, private, public
+	jwk, err := publicJWK(method.Alg(), public)
+	if err != nil {
+		return nil, err
+	}
+	j.keyID = jwk.KeyID
+	return j, nil
+}
+
+// Algorithm names the algorithm tokens are signed with
+func (j *JWTManager) Algorithm() string {
+	return j.method.Alg()
+}
+
+// JWKS returns the keys tokens can be verified with. It is empty for an
+// HS256 manager, whose secret must never be published.
+func (j *JWTManager) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if jwk, err := publicJWK(j.method.Alg(), j.verifyKey); err == nil {
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// publicJWK describes public as a JWK whose kid is its RFC 7638 thumbprint,
+// so the ID changes exactly when the key does
+func publicJWK(alg string, public interface{}) (JWK, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	var jwk JWK
+	var members interface{}
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		jwk = JWK{KeyType: "RSA", N: b64(key.N.Bytes()), E: b64(big.NewInt(int64(key.E)).Bytes())}
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	case ed25519.PublicKey:
+		jwk = JWK{KeyType: "OKP", Curve: "Ed25519", X: b64(key)}
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	default:
+		return JWK{}, fmt.Errorf("no public JWK for %T", public)
+	}
+
+	// The thumbprint hashes the required members in lexical order
+	canonical, err := json.Marshal(members)
+	if err != nil {
+		return JWK{}, err
+	}
+	sum := sha256.Sum256(canonical)
+	jwk.Use, jwk.Algorithm, jwk.KeyID = "sig", alg, b64(sum[:])
+	return jwk, nil
+}
@@ -0,0 +1,164 @@
+// internal/infrastructure/auth/keys_test.go
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func pemBlock(t *testing.T, blockType string, der []byte, err error) []byte {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("marshal %s: %v", blockType, err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+}
+
+func rsaKeyPEM(t *testing.T, bits int) (private, public []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	private = pemBlock(t, "PRIVATE KEY", der, err)
+	der, err = x509.MarshalPKIXPublicKey(&key.PublicKey)
+	return private, pemBlock(t, "PUBLIC KEY", der, err)
+}
+
+func edKeyPEM(t *testing.T) (private, public []byte) {
+	t.Helper()
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate Ed25519 key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	private = pemBlock(t, "PRIVATE KEY", der, err)
+	der, err = x509.MarshalPKIXPublicKey(pub)
+	return private, pemBlock(t, "PUBLIC KEY", der, err)
+}
+
+// verifyWithJWK checks token the way a downstream service would: with
+// nothing but the published key
+func verifyWithJWK(t *testing.T, token string, set JWKSet) (*Claims, error) {
+	t.Helper()
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		for _, jwk := range set.Keys {
+			if jwk.KeyID != token.Header["kid"] {
+				continue
+			}
+			decode := base64.RawURLEncoding.DecodeString
+			switch jwk.KeyType {
+			case "RSA":
+				n, _ := decode(jwk.N)
+				e, _ := decode(jwk.E)
+				return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+			case "OKP":
+				x, _ := decode(jwk.X)
+				return ed25519.PublicKey(x), nil
+			}
+		}
+		t.Fatalf("no key %v in %+v", token.Header["kid"], set)
+		return nil, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
+	return claims, err
+}
+
+func TestAsymmetricJWTManager(t *testing.T) {
+	rsaPrivate, rsaPublic := rsaKeyPEM(t, 2048)
+	edPrivate, edPublic := edKeyPEM(t)
+
+	tests := []struct {
+		name    string
+		alg     string
+		manager func() (*JWTManager, error)
+	}{
+		{"RS256", AlgorithmRS256, func() (*JWTManager, error) { return NewJWTManagerRS256(rsaPrivate, rsaPublic, time.Hour) }},
+		{"RS256 deriving the public key", AlgorithmRS256, func() (*JWTManager, error) { return NewJWTManagerRS256(rsaPrivate, nil, time.Hour) }},
+		{"EdDSA", AlgorithmEdDSA, func() (*JWTManager, error) { return NewJWTManagerEdDSA(edPrivate, edPublic, time.Hour) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, err := tt.manager()
+			if err != nil {
+				t.Fatalf("new manager: %v", err)
+			}
+			if manager.Algorithm() != tt.alg {
+				t.Errorf("expected %s, got %s", tt.alg, manager.Algorithm())
+			}
+
+			token, err := manager.GenerateToken(42)
+			if err != nil {
+				t.Fatalf("generate: %v", err)
+			}
+			if claims, err := manager.ValidateToken(token); err != nil || claims.UserID != 42 {
+				t.Fatalf("expected the token to validate, got %v (%v)", claims, err)
+			}
+
+			set := manager.JWKS()
+			if len(set.Keys) != 1 || set.Keys[0].Algorithm != tt.alg || set.Keys[0].Use != "sig" {
+				t.Fatalf("expected one signing key, got %+v", set)
+			}
+			if claims, err := verifyWithJWK(t, token, set); err != nil || claims.UserID != 42 {
+				t.Errorf("expected the published key to verify the token, got %v (%v)", claims, err)
+			}
+		})
+	}
+}
+
+func TestAsymmetricJWTManager_RejectsOtherAlgorithms(t *testing.T) {
+	private, public := rsaKeyPEM(t, 2048)
+	manager, err := NewJWTManagerRS256(private, public, time.Hour)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+
+	// An HS256 token keyed with the public key, which anyone can fetch
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: 1}).SignedString(public)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if _, err := manager.ValidateToken(forged); err == nil {
+		t.Fatal("expected an HS256 token rejected by an RS256 manager")
+	}
+
+	// And the other way round
+	hmacToken, _ := NewJWTManager("secret", time.Hour).GenerateToken(1)
+	if _, err := manager.ValidateToken(hmacToken); err == nil {
+		t.Error("expected an HMAC token rejected")
+	}
+	if got := NewJWTManager("secret", time.Hour).JWKS(); len(got.Keys) != 0 {
+		t.Errorf("expected no published key for HS256, got %+v", got)
+	}
+}
+
+func TestAsymmetricJWTManager_KeyChecks(t *testing.T) {
+	private, _ := rsaKeyPEM(t, 2048)
+	_, otherPublic := rsaKeyPEM(t, 2048)
+	weak, _ := rsaKeyPEM(t, 1024)
+	edPrivate, _ := edKeyPEM(t)
+
+	if _, err := NewJWTManagerRS256(private, otherPublic, time.Hour); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("expected mismatched keys rejected, got %v", err)
+	}
+	if _, err := NewJWTManagerRS256(weak, nil, time.Hour); err == nil {
+		t.Error("expected a 1024-bit key rejected")
+	}
+	if _, err := NewJWTManagerRS256(edPrivate, nil, time.Hour); err == nil {
+		t.Error("expected an Ed25519 key rejected for RS256")
+	}
+	if _, err := NewJWTManagerEdDSA(private, nil, time.Hour); err == nil {
+		t.Error("expected an RSA key rejected for EdDSA")
+	}
+}
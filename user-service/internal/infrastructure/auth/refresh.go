@@ -0,0 +1,210 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrRefreshTokensNotConfigured is returned by the refresh methods of a
+	// manager built without WithRefreshTokens
+	ErrRefreshTokensNotConfigured = errors.New("refresh tokens not configured")
+	// ErrInvalidRefreshToken covers unknown, expired and revoked tokens
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is a token presented again after it was
+	// exchanged. Only a copy of it can do that, so every token descended
+	// from the same login is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// RefreshTokenPrefix starts every refresh token, so they are told apart
+// from access tokens
+const RefreshTokenPrefix = "rt_"
+
+// refreshTokenBytes is the random part of a refresh token, before encoding
+const refreshTokenBytes = 32
+
+// RefreshToken is a stored refresh token. Only a hash of the secret is kept.
+// Tokens from one login share a Family, which each refresh extends.
+type RefreshToken struct {
+	Hash      string
+	UserID    uint
+	Family    string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	// RotatedAt is when the token was exchanged for the next one
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+}
+
+// RefreshTokenStore persists refresh tokens by hash
+type RefreshTokenStore interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	// Get fails with ErrInvalidRefreshToken for an unknown hash
+	Get(ctx context.Context, hash string) (*RefreshToken, error)
+	// Rotate marks the token exchanged at at, if it is unexchanged,
+	// unrevoked and unexpired at at. Concurrent calls for the same token
+	// can only both succeed once; ok reports whether this one did.
+	Rotate(ctx context.Context, hash string, at time.Time) (ok bool, err error)
+	// RevokeFamily revokes every token of the family
+	RevokeFamily(ctx context.Context, family string, at time.Time) error
+}
+
+// TokenPair is a short-lived access token with the refresh token that
+// replaces it
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	// IssuedAt is AccessToken's iat, and ExpiresAt when it expires,
+	// ExpiresIn after issue
+	IssuedAt         time.Time
+	ExpiresAt        time.Time
+	ExpiresIn        time.Duration
+	RefreshExpiresIn time.Duration
+}
+
+// WithRefreshTokens makes GenerateTokenPair issue refresh tokens that last
+// ttl, kept in store
+func WithRefreshTokens(store RefreshTokenStore, ttl time.Duration) JWTOption {
+	return func(j *JWTManager) {
+		j.refreshTokens = store
+		j.refreshExpiration = ttl
+	}
+}
+
+// GenerateTokenPair issues an access token for userID, with opts applied,
+// together with a refresh token starting a new family
+func (j *JWTManager) GenerateTokenPair(ctx context.Context, userID uint, opts ...TokenOption) (*TokenPair, error) {
+	return j.GenerateSessionTokenPair(ctx, userID, "", opts...)
+}
+
+// GenerateSessionTokenPair is GenerateTokenPair for a recorded session:
+// the refresh token family is session, which every token of the pair and
+// its refreshes carries as its sid claim. An empty session gets a random
+// family.
+func (j *JWTManager) GenerateSessionTokenPair(ctx context.Context, userID uint, session string, opts ...TokenOption) (*TokenPair, error) {
+	if j.refreshTokens == nil {
+		return nil, ErrRefreshTokensNotConfigured
+	}
+	family := session
+	if family == "" {
+		var err error
+		if family, err = randomHex(16); err != nil {
+			return nil, err
+		}
+	}
+	return j.issuePair(ctx, userID, family, opts)
+}
+
+// RefreshTokenPair exchanges refreshToken for a new pair in the same
+// family. check is asked whether the user may still have the session, the
+// family, and returns the options for the new access token; its error is
+// returned as is. A token can only be exchanged once: presenting
+// it again fails with ErrRefreshTokenReused and revokes its family.
+func (j *JWTManager) RefreshTokenPair(ctx context.Context, refreshToken string, check func(ctx context.Context, userID uint, session string) ([]TokenOption, error)) (*TokenPair, error) {
+	if j.refreshTokens == nil {
+		return nil, ErrRefreshTokensNotConfigured
+	}
+	if !strings.HasPrefix(refreshToken, RefreshTokenPrefix) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	hash := hashRefreshToken(refreshToken)
+	now := j.now()
+	ok, err := j.refreshTokens.Rotate(ctx, hash, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	stored, err := j.refreshTokens.Get(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if stored.RotatedAt != nil && stored.RevokedAt == nil {
+			if err := j.refreshTokens.RevokeFamily(ctx, stored.Family, now); err != nil {
+				return nil, fmt.Errorf("failed to revoke reused refresh token: %w", err)
+			}
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+
+	opts, err := check(ctx, stored.UserID, stored.Family)
+	if err != nil {
+		if revokeErr := j.refreshTokens.RevokeFamily(ctx, stored.Family, now); revokeErr != nil {
+			return nil, errors.Join(err, revokeErr)
+		}
+		return nil, err
+	}
+	return j.issuePair(ctx, stored.UserID, stored.Family, opts)
+}
+
+// RevokeRefreshToken revokes refreshToken's family, signing out the login
+// it came from. Unknown tokens are ErrInvalidRefreshToken.
+func (j *JWTManager) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
+	if j.refreshTokens == nil {
+		return ErrRefreshTokensNotConfigured
+	}
+	if !strings.HasPrefix(refreshToken, RefreshTokenPrefix) {
+		return ErrInvalidRefreshToken
+	}
+	stored, err := j.refreshTokens.Get(ctx, hashRefreshToken(refreshToken))
+	if err != nil {
+		return err
+	}
+	return j.refreshTokens.RevokeFamily(ctx, stored.Family, j.now())
+}
+
+func (j *JWTManager) issuePair(ctx context.Context, userID uint, family string, opts []TokenOption) (*TokenPair, error) {
+	opts = append(opts[:len(opts):len(opts)], WithSessionID(family))
+	access, claims, err := j.IssueToken(userID, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	refresh := RefreshTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	now := j.now()
+	err = j.refreshTokens.Create(ctx, &RefreshToken{
+		Hash:      hashRefreshToken(refresh),
+		UserID:    userID,
+		Family:    family,
+		ExpiresAt: now.Add(j.refreshExpiration),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:      access,
+		RefreshToken:     refresh,
+		IssuedAt:         claims.IssuedAt.Time,
+		ExpiresAt:        claims.ExpiresAt.Time,
+		ExpiresIn:        claims.ExpiresAt.Sub(claims.IssuedAt.Time),
+		RefreshExpiresIn: j.refreshExpiration,
+	}, nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// HMACKey is one HS256 secret of a rotating set
+type HMACKey struct {
+	ID     string
+	Secret string
+	// RetiresAt ends the grace period of a key that no longer signs:
+	// tokens it signed are rejected from then on. Zero never retires it.
+	RetiresAt time.Time
+}
+
+type verificationKey struct {
+	id        string
+	key       interface{}
+	retiresAt time.Time
+}
+
+// NewJWTManagerHMAC signs with keys[0], the newest key, naming it in each
+// token's kid header. Tokens signed with the other keys keep validating
+// until their RetiresAt, so rotating the secret doesn't end every session.
+func NewJWTManagerHMAC(keys []HMACKey, expire time.Duration, opts ...JWTOption) (*JWTManager, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no HMAC keys")
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key.ID == "" || key.Secret == "" {
+			return nil, errors.New("every HMAC key needs an ID and a secret")
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate HMAC key ID %q", key.ID)
+		}
+		seen[key.ID] = true
+	}
+
+	j := NewJWTManager(keys[0].Secret, expire, opts...)
+	j.keyID = keys[0].ID
+	for _, key := range keys[1:] {
+		j.previousKeys = append(j.previousKeys, verificationKey{
+			id:        key.ID,
+			key:       []byte(key.Secret),
+			retiresAt: key.RetiresAt,
+		})
+	}
+	return j, nil
+}
+
+// verificationKey picks the key named by the token's kid. A token without
+// one predates key IDs and may verify with any key still in its grace
+// period; a manager without key IDs ignores the header.
+func (j *JWTManager) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == j.keyID || j.keyID == "" {
+		return j.verifyKey, nil
+	}
+
+	now := j.now()
+	if kid == "" {
+		set := jwt.VerificationKeySet{Keys: []jwt.VerificationKey{j.verifyKey}}
+		for _, old := range j.previousKeys {
+			if old.active(now) {
+				set.Keys = append(set.Keys, old.key)
+			}
+		}
+		return set, nil
+	}
+	for _, old := range j.previousKeys {
+		if old.id != kid {
+			continue
+		}
+		if !old.active(now) {
+			return nil, fmt.Errorf("key %q is retired", kid)
+		}
+		return old.key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+func (k verificationKey) active(now time.Time) bool {
+	return k.retiresAt.IsZero() || now.Before(k.retiresAt)
+}
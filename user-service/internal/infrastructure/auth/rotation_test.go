@@ -0,0 +1,81 @@
+// internal/infrastructure/auth/rotation_test.go
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJWTManagerHMAC_Rotation(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	retiresAt := now.Add(48 * time.Hour)
+
+	before, err := NewJWTManagerHMAC([]HMACKey{{ID: "2024-01", Secret: "old-secret"}}, 72*time.Hour, WithClock(clock))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	oldToken, _ := before.GenerateToken(1)
+	legacyToken, _ := NewJWTManager("old-secret", 72*time.Hour, WithClock(clock)).GenerateToken(2)
+
+	rotated, err := NewJWTManagerHMAC([]HMACKey{
+		{ID: "2024-06", Secret: "new-secret"},
+		{ID: "2024-01", Secret: "old-secret", RetiresAt: retiresAt},
+	}, 72*time.Hour, WithClock(clock))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	newToken, _ := rotated.GenerateToken(3)
+
+	// The new key signs, and the old manager can't verify it
+	if _, err := before.ValidateToken(newToken); err == nil {
+		t.Error("expected a token from the new key unknown to the old manager")
+	}
+	for name, token := range map[string]string{"new": newToken, "old": oldToken, "without kid": legacyToken} {
+		if _, err := rotated.ValidateToken(token); err != nil {
+			t.Errorf("expected the %s token valid during the grace period, got %v", name, err)
+		}
+	}
+
+	now = retiresAt
+	if _, err := rotated.ValidateToken(newToken); err != nil {
+		t.Errorf("expected the new token still valid, got %v", err)
+	}
+	for name, token := range map[string]string{"old": oldToken, "without kid": legacyToken} {
+		_, err := rotated.ValidateToken(token)
+		if got := ValidationOutcome(err); got != OutcomeBadSignature {
+			t.Errorf("expected the %s token rejected once the old key retired, got %s (%v)", name, got, err)
+		}
+	}
+}
+
+func TestJWTManagerHMAC_RemovedKey(t *testing.T) {
+	old, _ := NewJWTManagerHMAC([]HMACKey{{ID: "2024-01", Secret: "old-secret"}}, time.Hour)
+	token, _ := old.GenerateToken(1)
+
+	rotated, _ := NewJWTManagerHMAC([]HMACKey{{ID: "2024-06", Secret: "new-secret"}}, time.Hour)
+	if _, err := rotated.ValidateToken(token); ValidationOutcome(err) != OutcomeBadSignature {
+		t.Errorf("expected a token from a removed key rejected, got %v", err)
+	}
+
+	// Reusing the kid with another secret doesn't help a forger either
+	forged, _ := NewJWTManagerHMAC([]HMACKey{{ID: "2024-06", Secret: "guess"}}, time.Hour)
+	token, _ = forged.GenerateToken(1)
+	if _, err := rotated.ValidateToken(token); err == nil {
+		t.Error("expected a token signed with the wrong secret rejected")
+	}
+}
+
+func TestNewJWTManagerHMAC_Keys(t *testing.T) {
+	tests := map[string][]HMACKey{
+		"no keys":      nil,
+		"no ID":        {{Secret: "secret"}},
+		"no secret":    {{ID: "a"}},
+		"duplicate ID": {{ID: "a", Secret: "one"}, {ID: "a", Secret: "two"}},
+	}
+	for name, keys := range tests {
+		if _, err := NewJWTManagerHMAC(keys, time.Hour); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
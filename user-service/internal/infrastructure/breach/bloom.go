@@ -0,0 +1,66 @@
+package breach
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"math"
+)
+
+// BloomFilter is a fixed-size set of SHA-1 digests with no false negatives
+// and a tunable false positive rate
+type BloomFilter struct {
+	bits   []uint64
+	m      uint64 // number of bits
+	hashes uint64 // probes per entry
+}
+
+// NewBloomFilter sizes a filter for n entries at false positive rate p
+func NewBloomFilter(n int, p float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.001
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &BloomFilter{
+		bits:   make([]uint64, (m+63)/64),
+		m:      m,
+		hashes: k,
+	}
+}
+
+// Add inserts a SHA-1 digest
+func (f *BloomFilter) Add(digest [sha1.Size]byte) {
+	h1, h2 := split(digest)
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// MayContain reports false only if digest was never added
+func (f *BloomFilter) MayContain(digest [sha1.Size]byte) bool {
+	h1, h2 := split(digest)
+	for i := uint64(0); i < f.hashes; i++ {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// split derives the two base hashes for double hashing from the digest,
+// which is already uniformly distributed
+func split(digest [sha1.Size]byte) (uint64, uint64) {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1
+	return h1, h2
+}
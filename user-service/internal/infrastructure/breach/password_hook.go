@@ -0,0 +1,99 @@
+package breach
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"user-service/internal/application"
+)
+
+var _ application.LoginHook = (*PasswordHook)(nil)
+
+// PasswordHook denies logins whose password appears in a breach corpus.
+// It runs after the credential check so only the account owner learns
+// their password is compromised.
+type PasswordHook struct {
+	application.NopLoginHook
+	filter *BloomFilter
+}
+
+// LoadPasswordHook builds the filter from a corpus file with one entry per
+// line: either a plaintext password or a SHA-1 hex digest, optionally
+// followed by ":count" as in the Have I Been Pwned downloads.
+func LoadPasswordHook(path string, falsePositiveRate float64) (*PasswordHook, error) {
+	digests, err := readCorpus(path)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := NewBloomFilter(len(digests), falsePositiveRate)
+	for _, d := range digests {
+		filter.Add(d)
+	}
+
+	return &PasswordHook{filter: filter}, nil
+}
+
+func (h *PasswordHook) AfterSuccess(ctx context.Context, req *application.LoginRequest) error {
+	if h.filter.MayContain(sha1.Sum([]byte(req.Password))) {
+		return application.DenyLogin("password found in a known data breach; reset it to continue")
+	}
+	return nil
+}
+
+func readCorpus(path string) ([][sha1.Size]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open breach corpus: %w", err)
+	}
+	defer file.Close()
+
+	var digests [][sha1.Size]byte
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		digests = append(digests, corpusDigest(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read breach corpus: %w", err)
+	}
+
+	return digests, nil
+}
+
+// corpusDigest treats a 40 hex character entry as an existing SHA-1 digest.
+// Only a trailing run of digits is a count, so a password may contain
+// colons.
+func corpusDigest(line string) [sha1.Size]byte {
+	entry := line
+	if i := strings.LastIndex(line, ":"); i >= 0 && isCount(line[i+1:]) {
+		entry = line[:i]
+	}
+	if len(entry) == 2*sha1.Size {
+		var digest [sha1.Size]byte
+		if _, err := hex.Decode(digest[:], []byte(entry)); err == nil {
+			return digest
+		}
+	}
+	return sha1.Sum([]byte(entry))
+}
+
+func isCount(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+// internal/infrastructure/breach/password_hook_test.go
+package breach
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+)
+
+func TestBloomFilter_NoFalseNegativesAndBoundedFalsePositives(t *testing.T) {
+	filter := NewBloomFilter(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		filter.Add(sha1.Sum([]byte(fmt.Sprintf("member-%d", i))))
+	}
+
+	for i := 0; i < 10000; i++ {
+		if !filter.MayContain(sha1.Sum([]byte(fmt.Sprintf("member-%d", i)))) {
+			t.Fatalf("false negative for member-%d", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.MayContain(sha1.Sum([]byte(fmt.Sprintf("other-%d", i)))) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / 10000; rate > 0.03 {
+		t.Errorf("false positive rate %.3f is far above the configured 0.01", rate)
+	}
+}
+
+func TestPasswordHook_DeniesBreachedPasswords(t *testing.T) {
+	hashed := sha1.Sum([]byte("letmein1"))
+	corpus := strings.Join([]string{
+		"password123",
+		"hunter2:1234",
+		"pass:word",
+		strings.ToUpper(hex.EncodeToString(hashed[:])) + ":4211",
+		"",
+	}, "\n")
+
+	path := filepath.Join(t.TempDir(), "corpus.txt")
+	if err := os.WriteFile(path, []byte(corpus), 0o600); err != nil {
+		t.Fatalf("write corpus: %v", err)
+	}
+
+	hook, err := LoadPasswordHook(path, 0.0001)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	user := &domain.User{ID: 1}
+	for password, breached := range map[string]bool{
+		"password123":         true,
+		"hunter2":             true,
+		"hunter2:1234":        false,
+		"pass:word":           true,
+		"letmein1":            true,
+		"correct-horse-42":    false,
+		"another-fine-secret": false,
+	} {
+		err := hook.AfterSuccess(context.Background(), &application.LoginRequest{Password: password, User: user})
+		if got := errors.Is(err, application.ErrLoginDenied); got != breached {
+			t.Errorf("%q: denied=%v, want %v (err %v)", password, got, breached, err)
+		}
+	}
+}
+
+func TestLoadPasswordHook_MissingFile(t *testing.T) {
+	if _, err := LoadPasswordHook(filepath.Join(t.TempDir(), "missing.txt"), 0.001); err == nil {
+		t.Fatal("expected an error for a missing corpus")
+	}
+}
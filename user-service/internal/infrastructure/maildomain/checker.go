@@ -0,0 +1,150 @@
+package maildomain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"user-service/internal/application"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var _ application.MailDomainChecker = (*Checker)(nil)
+
+// Default cache lifetimes. Domains that take mail rarely stop, so a
+// positive answer is kept longer than a negative one, which a domain
+// being set up may soon turn around.
+const (
+	DefaultPositiveTTL = 6 * time.Hour
+	DefaultNegativeTTL = 15 * time.Minute
+)
+
+// Resolver looks up MX records; *net.Resolver satisfies it
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+}
+
+// Cache remembers lookup results across replicas
+type Cache interface {
+	// GetMailDomain reports the cached answer for domain, found false on
+	// a miss
+	GetMailDomain(ctx context.Context, domain string) (canReceive, found bool, err error)
+	SetMailDomain(ctx context.Context, domain string, canReceive bool, ttl time.Duration) error
+}
+
+// Checker decides whether a domain can receive mail from its MX records.
+// Concurrent lookups of one domain share a single DNS query, and results
+// are cached so a burst of signups doesn't hammer the resolver.
+type Checker struct {
+	resolver    Resolver
+	cache       Cache
+	positiveTTL time.Duration
+	negativeTTL time.Duration
+	lookups     singleflight.Group
+}
+
+type Option func(*Checker)
+
+// WithCache caches answers; without it every lookup not already in flight
+// goes to DNS
+func WithCache(cache Cache) Option {
+	return func(c *Checker) {
+		c.cache = cache
+	}
+}
+
+// WithTTLs sets how long answers are cached
+func WithTTLs(positive, negative time.Duration) Option {
+	return func(c *Checker) {
+		c.positiveTTL = positive
+		c.negativeTTL = negative
+	}
+}
+
+// NewChecker builds a checker on resolver, net.DefaultResolver when nil
+func NewChecker(resolver Resolver, opts ...Option) *Checker {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	c := &Checker{
+		resolver:    resolver,
+		positiveTTL: DefaultPositiveTTL,
+		negativeTTL: DefaultNegativeTTL,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// CanReceiveMail reports false only on a definitive answer: the domain
+// doesn't exist, has no MX records, or publishes a null MX. Timeouts and
+// other DNS failures are returned as errors and not cached.
+func (c *Checker) CanReceiveMail(ctx context.Context, domain string) (bool, error) {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if c.cache != nil {
+		canReceive, found, err := c.cache.GetMailDomain(ctx, domain)
+		if err != nil {
+			log.Printf("Mail domain cache read failed for %s: %v", domain, err)
+		}
+		if found {
+			return canReceive, nil
+		}
+	}
+
+	result, err, _ := c.lookups.Do(domain, func() (interface{}, error) {
+		// Detached from the first caller's cancellation, since others may
+		// be waiting on the answer; the caller's deadline still applies
+		// to each waiter through its own context
+		lookupCtx := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			lookupCtx, cancel = context.WithDeadline(lookupCtx, deadline)
+			defer cancel()
+		}
+		return c.lookup(lookupCtx, domain)
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.(bool), nil
+}
+
+func (c *Checker) lookup(ctx context.Context, domain string) (bool, error) {
+	records, err := c.resolver.LookupMX(ctx, domain)
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		records, err = nil, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to look up MX for %s: %w", domain, err)
+	}
+
+	canReceive := acceptsMail(records)
+	if c.cache != nil {
+		ttl := c.positiveTTL
+		if !canReceive {
+			ttl = c.negativeTTL
+		}
+		if err := c.cache.SetMailDomain(ctx, domain, canReceive, ttl); err != nil {
+			log.Printf("Mail domain cache write failed for %s: %v", domain, err)
+		}
+	}
+	return canReceive, nil
+}
+
+// acceptsMail reports whether records name a mail server. A lone "." is
+// the null MX of RFC 7505, published by domains that take no mail.
+func acceptsMail(records []*net.MX) bool {
+	for _, mx := range records {
+		if host := strings.TrimSuffix(mx.Host, "."); host != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+// internal/infrastructure/maildomain/checker_test.go
+package maildomain
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers from a table, counting queries. A domain missing
+// from both tables doesn't exist.
+type fakeResolver struct {
+	records map[string][]*net.MX
+	errs    map[string]error
+	release chan struct{}
+	queries atomic.Int32
+}
+
+func (r *fakeResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	r.queries.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+	if err, ok := r.errs[name]; ok {
+		return nil, err
+	}
+	records, ok := r.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+type memoryCache struct {
+	mu      sync.Mutex
+	answers map[string]bool
+	ttls    map[string]time.Duration
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{answers: make(map[string]bool), ttls: make(map[string]time.Duration)}
+}
+
+func (c *memoryCache) GetMailDomain(ctx context.Context, domain string) (bool, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	canReceive, found := c.answers[domain]
+	return canReceive, found, nil
+}
+
+func (c *memoryCache) SetMailDomain(ctx context.Context, domain string, canReceive bool, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.answers[domain], c.ttls[domain] = canReceive, ttl
+	return nil
+}
+
+func TestChecker_CanReceiveMail(t *testing.T) {
+	resolver := &fakeResolver{
+		records: map[string][]*net.MX{
+			"example.com": {{Host: "mx.example.com.", Pref: 10}},
+			"nullmx.test": {{Host: ".", Pref: 0}},
+			"nomx.test":   {},
+		},
+		errs: map[string]error{
+			"flaky.test": &net.DNSError{Err: "server misbehaving", Name: "flaky.test", IsTemporary: true},
+		},
+	}
+	cache := newMemoryCache()
+	checker := NewChecker(resolver, WithCache(cache))
+	ctx := context.Background()
+
+	tests := []struct {
+		domain  string
+		want    bool
+		wantErr bool
+	}{
+		{domain: "Example.com.", want: true},
+		{domain: "nullmx.test"},
+		{domain: "nomx.test"},
+		{domain: "missing.test"},
+		{domain: "flaky.test", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := checker.CanReceiveMail(ctx, tt.domain)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("CanReceiveMail(%q) = %v, %v; want %v (error %v)", tt.domain, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	if cache.ttls["example.com"] != DefaultPositiveTTL || cache.ttls["missing.test"] != DefaultNegativeTTL {
+		t.Errorf("expected positive and negative answers cached, got %v", cache.ttls)
+	}
+	if _, found := cache.answers["flaky.test"]; found {
+		t.Error("expected a transient failure not cached")
+	}
+
+	// Cached answers don't go back to DNS
+	before := resolver.queries.Load()
+	if ok, _ := checker.CanReceiveMail(ctx, "example.com"); !ok {
+		t.Error("expected the cached answer")
+	}
+	if got := resolver.queries.Load(); got != before {
+		t.Errorf("expected no query for a cached domain, got %d more", got-before)
+	}
+}
+
+func TestChecker_SharesConcurrentLookups(t *testing.T) {
+	resolver := &fakeResolver{
+		records: map[string][]*net.MX{"example.com": {{Host: "mx.example.com."}}},
+		release: make(chan struct{}),
+	}
+	checker := NewChecker(resolver)
+
+	var wg sync.WaitGroup
+	results := make(chan bool, 20)
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, _ := checker.CanReceiveMail(context.Background(), "example.com")
+			results <- ok
+		}()
+	}
+	// Let the goroutines pile up on the first query
+	for resolver.queries.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(resolver.release)
+	wg.Wait()
+	close(results)
+
+	for ok := range results {
+		if !ok {
+			t.Fatal("expected every caller to get the answer")
+		}
+	}
+	if got := resolver.queries.Load(); got != 1 {
+		t.Errorf("expected one DNS query, got %d", got)
+	}
+}
+
+func TestChecker_TimeoutIsNotDefinitive(t *testing.T) {
+	resolver := &fakeResolver{errs: map[string]error{"slow.test": context.DeadlineExceeded}}
+	_, err := NewChecker(resolver).CanReceiveMail(context.Background(), "slow.test")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the timeout returned, got %v", err)
+	}
+}
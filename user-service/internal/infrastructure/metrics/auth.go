@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"time"
+
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/interfaces/http/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	_ auth.Observer           = (*AuthMetrics)(nil)
+	_ middleware.AuthObserver = (*AuthMetrics)(nil)
+)
+
+// AuthMetrics records JWT issuance and validation and AuthMiddleware
+// outcomes. Labels only ever hold the fixed operation and outcome names,
+// never user IDs or token contents.
+type AuthMetrics struct {
+	jwtDuration  *prometheus.HistogramVec
+	jwtTotal     *prometheus.CounterVec
+	authDuration prometheus.Histogram
+	authTotal    *prometheus.CounterVec
+}
+
+// NewAuthMetrics creates the collectors and registers them with reg
+func NewAuthMetrics(reg prometheus.Registerer) *AuthMetrics {
+	m := &AuthMetrics{
+		jwtDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "user_service",
+			Subsystem: "auth",
+			Name:      "jwt_duration_seconds",
+			Help:      "Duration of JWT signing and validation.",
+			Buckets:   []float64{.00005, .0001, .00025, .0005, .001, .005},
+		}, []string{"operation"}),
+		jwtTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "user_service",
+			Subsystem: "auth",
+			Name:      "jwt_operations_total",
+			Help:      "JWTs generated and validated, by outcome.",
+		}, []string{"operation", "outcome"}),
+		authDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "user_service",
+			Subsystem: "auth",
+			Name:      "middleware_duration_seconds",
+			Help:      "Time AuthMiddleware spends checking a request, excluding the handler.",
+			Buckets:   []float64{.0001, .0005, .001, .005, .01, .05, .1},
+		}),
+		authTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "user_service",
+			Subsystem: "auth",
+			Name:      "middleware_requests_total",
+			Help:      "Requests checked by AuthMiddleware, by outcome.",
+		}, []string{"outcome"}),
+	}
+
+	reg.MustRegister(m.jwtDuration, m.jwtTotal, m.authDuration, m.authTotal)
+	return m
+}
+
+func (m *AuthMetrics) ObserveJWT(operation, outcome string, duration time.Duration) {
+	m.jwtDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	m.jwtTotal.WithLabelValues(operation, outcome).Inc()
+}
+
+func (m *AuthMetrics) ObserveAuth(outcome string, duration time.Duration) {
+	m.authDuration.Observe(duration.Seconds())
+	m.authTotal.WithLabelValues(outcome).Inc()
+}
@@ -0,0 +1,59 @@
+// internal/infrastructure/metrics/auth_test.go
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/infrastructure/metrics"
+	"user-service/internal/interfaces/http/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAuthMetrics_CountsExpiredTokens(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := metrics.NewAuthMetrics(reg)
+
+	// Tokens from this manager are already expired when issued
+	jwtManager := auth.NewJWTManager("test-secret", -time.Minute, auth.WithObserver(m))
+	token, err := jwtManager.GenerateToken(1)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	handler := middleware.AuthMiddleware(jwtManager, middleware.WithAuthObserver(m))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("handler reached with an expired token")
+		}),
+	)
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+
+	want := `
+# HELP user_service_auth_jwt_operations_total JWTs generated and validated, by outcome.
+# TYPE user_service_auth_jwt_operations_total counter
+user_service_auth_jwt_operations_total{operation="generate",outcome="ok"} 1
+user_service_auth_jwt_operations_total{operation="validate",outcome="expired"} 1
+# HELP user_service_auth_middleware_requests_total Requests checked by AuthMiddleware, by outcome.
+# TYPE user_service_auth_middleware_requests_total counter
+user_service_auth_middleware_requests_total{outcome="expired"} 1
+`
+	err = testutil.GatherAndCompare(reg, strings.NewReader(want),
+		"user_service_auth_jwt_operations_total",
+		"user_service_auth_middleware_requests_total",
+	)
+	if err != nil {
+		t.Error(err)
+	}
+}
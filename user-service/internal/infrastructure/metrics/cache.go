@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"sync/atomic"
+
+	"user-service/internal/application"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ application.CacheObserver = (*CacheMetrics)(nil)
+
+// CacheMetrics counts user cache hits and misses, and the entries written
+// ahead of a read by Register and Login. Hits served from those entries
+// show up as hits that no earlier miss paid for.
+type CacheMetrics struct {
+	reads *prometheus.CounterVec
+	warms *prometheus.CounterVec
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewCacheMetrics creates the collector and registers it with reg
+func NewCacheMetrics(reg prometheus.Registerer) *CacheMetrics {
+	m := &CacheMetrics{
+		reads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "user_service",
+			Subsystem: "cache",
+			Name:      "reads_total",
+			Help:      "User cache lookups by result (hit, miss).",
+		}, []string{"result"}),
+		warms: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "user_service",
+			Subsystem: "cache",
+			Name:      "warms_total",
+			Help:      "User cache entries written by a write path (register, login) before any read.",
+		}, []string{"source"}),
+	}
+
+	reg.MustRegister(m.reads, m.warms)
+	return m
+}
+
+func (m *CacheMetrics) ObserveCacheReads(hits, misses int) {
+	if hits > 0 {
+		m.reads.WithLabelValues("hit").Add(float64(hits))
+		m.hits.Add(int64(hits))
+	}
+	if misses > 0 {
+		m.reads.WithLabelValues("miss").Add(float64(misses))
+		m.misses.Add(int64(misses))
+	}
+}
+
+func (m *CacheMetrics) ObserveCacheWarm(source string) {
+	m.warms.WithLabelValues(source).Inc()
+}
+
+// HitRatio is the share of lookups answered from the cache since this
+// instance started. ok is false until there has been a lookup.
+func (m *CacheMetrics) HitRatio() (ratio float64, ok bool) {
+	hits, misses := m.hits.Load(), m.misses.Load()
+	if hits+misses == 0 {
+		return 0, false
+	}
+	return float64(hits) / float64(hits+misses), true
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"strconv"
+
+	"user-service/internal/interfaces/http/respond"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ respond.LegacyErrorObserver = (*ErrorFormatMetrics)(nil)
+
+// ErrorFormatMetrics counts error responses still sent as plain text.
+// Once it stays flat, ERROR_FORMAT_COMPAT can be switched off.
+type ErrorFormatMetrics struct {
+	legacy *prometheus.CounterVec
+}
+
+// NewErrorFormatMetrics creates the collector and registers it with reg
+func NewErrorFormatMetrics(reg prometheus.Registerer) *ErrorFormatMetrics {
+	m := &ErrorFormatMetrics{
+		legacy: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "user_service",
+			Subsystem: "http",
+			Name:      "legacy_error_responses_total",
+			Help:      "Error responses sent as text/plain for clients that don't accept JSON, by status code.",
+		}, []string{"status"}),
+	}
+
+	reg.MustRegister(m.legacy)
+	return m
+}
+
+func (m *ErrorFormatMetrics) ObserveLegacyError(status int) {
+	m.legacy.WithLabelValues(strconv.Itoa(status)).Inc()
+}
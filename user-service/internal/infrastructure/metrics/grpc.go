@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"time"
+
+	usergrpc "user-service/internal/interfaces/grpc"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ usergrpc.RPCObserver = (*GRPCMetrics)(nil)
+
+// GRPCMetrics records gRPC call latency and status codes
+type GRPCMetrics struct {
+	duration *prometheus.HistogramVec
+	total    *prometheus.CounterVec
+}
+
+// NewGRPCMetrics creates the collectors and registers them with reg
+func NewGRPCMetrics(reg prometheus.Registerer) *GRPCMetrics {
+	m := &GRPCMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "user_service",
+			Subsystem: "grpc",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of gRPC calls.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"method"}),
+		total: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "user_service",
+			Subsystem: "grpc",
+			Name:      "requests_total",
+			Help:      "gRPC calls by status code.",
+		}, []string{"method", "code"}),
+	}
+
+	reg.MustRegister(m.duration, m.total)
+	return m
+}
+
+func (m *GRPCMetrics) ObserveRPC(method, code string, duration time.Duration) {
+	m.duration.WithLabelValues(method).Observe(duration.Seconds())
+	m.total.WithLabelValues(method, code).Inc()
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"time"
+
+	"user-service/internal/jobs"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ jobs.Observer = (*JobMetrics)(nil)
+
+// JobMetrics records background job run time and outcomes
+type JobMetrics struct {
+	duration *prometheus.HistogramVec
+	total    *prometheus.CounterVec
+}
+
+// NewJobMetrics creates the collectors and registers them with reg
+func NewJobMetrics(reg prometheus.Registerer) *JobMetrics {
+	m := &JobMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "user_service",
+			Subsystem: "jobs",
+			Name:      "run_duration_seconds",
+			Help:      "Duration of background job runs.",
+			Buckets:   []float64{.001, .01, .1, .5, 1, 5, 15, 60, 300},
+		}, []string{"job"}),
+		total: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "user_service",
+			Subsystem: "jobs",
+			Name:      "runs_total",
+			Help:      "Background job runs by outcome (ok, error, panic, skipped).",
+		}, []string{"job", "outcome"}),
+	}
+
+	reg.MustRegister(m.duration, m.total)
+	return m
+}
+
+func (m *JobMetrics) ObserveJob(name, outcome string, duration time.Duration) {
+	// A skipped run did no work, so it would only drag the histogram down
+	if outcome != jobs.OutcomeSkipped {
+		m.duration.WithLabelValues(name).Observe(duration.Seconds())
+	}
+	m.total.WithLabelValues(name, outcome).Inc()
+}
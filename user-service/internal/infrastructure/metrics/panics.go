@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"user-service/internal/interfaces/http/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ middleware.PanicObserver = (*PanicMetrics)(nil)
+
+// PanicMetrics counts handler panics middleware.Recover caught. Any at all
+// is a bug to chase down in the logs.
+type PanicMetrics struct {
+	panics prometheus.Counter
+}
+
+// NewPanicMetrics creates the collector and registers it with reg
+func NewPanicMetrics(reg prometheus.Registerer) *PanicMetrics {
+	m := &PanicMetrics{
+		panics: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "user_service",
+			Subsystem: "http",
+			Name:      "panics_total",
+			Help:      "Panics recovered from HTTP handlers.",
+		}),
+	}
+
+	reg.MustRegister(m.panics)
+	return m
+}
+
+func (m *PanicMetrics) ObservePanic() {
+	m.panics.Inc()
+}
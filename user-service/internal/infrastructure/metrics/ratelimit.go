@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"user-service/internal/interfaces/http/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ middleware.RateLimitObserver = (*RateLimitMetrics)(nil)
+
+// rejectionWindow is how far back RejectionsLastHour looks, in minutes
+const rejectionWindow = 60
+
+// RateLimitMetrics counts requests turned away by the rate limiters, and
+// those a limiter in warn mode would have turned away, as well as those
+// a Redis limiter decided without Redis. Besides the
+// Prometheus counter it keeps a per-minute tally of the enforced
+// rejections in the last hour for the admin overview, which has no
+// Prometheus server to ask.
+type RateLimitMetrics struct {
+	rejected      *prometheus.CounterVec
+	redisFailures *prometheus.CounterVec
+
+	mu      sync.Mutex
+	minutes [rejectionWindow]int64
+	// stamps holds the minute each slot in minutes was counted for
+	stamps [rejectionWindow]int64
+	now    func() time.Time
+}
+
+// NewRateLimitMetrics creates the collector and registers it with reg
+func NewRateLimitMetrics(reg prometheus.Registerer) *RateLimitMetrics {
+	m := &RateLimitMetrics{
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "user_service",
+			Subsystem: "ratelimit",
+			Name:      "rejections_total",
+			Help:      "Requests over a rate limit, by limiter scope and mode (enforce, warn).",
+		}, []string{"scope", "mode"}),
+		redisFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "user_service",
+			Subsystem: "ratelimit",
+			Name:      "redis_failures_total",
+			Help:      "Requests a Redis rate limiter couldn't count in Redis, by limiter scope and failure mode (open, closed, local).",
+		}, []string{"scope", "mode"}),
+		now: time.Now,
+	}
+
+	reg.MustRegister(m.rejected, m.redisFailures)
+	return m
+}
+
+func (m *RateLimitMetrics) ObserveRateLimited(scope string, mode middleware.RateLimitMode) {
+	m.rejected.WithLabelValues(scope, string(mode)).Inc()
+	if mode != middleware.RateLimitEnforce {
+		return
+	}
+
+	minute := m.now().Unix() / 60
+	slot := minute % rejectionWindow
+	m.mu.Lock()
+	if m.stamps[slot] != minute {
+		m.stamps[slot] = minute
+		m.minutes[slot] = 0
+	}
+	m.minutes[slot]++
+	m.mu.Unlock()
+}
+
+func (m *RateLimitMetrics) ObserveRedisFailure(scope string, mode middleware.FailureMode) {
+	m.redisFailures.WithLabelValues(scope, string(mode)).Inc()
+}
+
+// RejectionsLastHour is how many requests this instance rejected in the
+// last 60 minutes, counted in whole minutes
+func (m *RateLimitMetrics) RejectionsLastHour() int64 {
+	oldest := m.now().Unix()/60 - rejectionWindow + 1
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var total int64
+	for slot, stamp := range m.stamps {
+		if stamp >= oldest {
+			total += m.minutes[slot]
+		}
+	}
+	return total
+}
@@ -0,0 +1,65 @@
+// internal/infrastructure/metrics/ratelimit_test.go
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"user-service/internal/interfaces/http/middleware"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRateLimitMetrics_RejectionsLastHour(t *testing.T) {
+	m := NewRateLimitMetrics(prometheus.NewRegistry())
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	m.ObserveRateLimited("login", middleware.RateLimitEnforce)
+	m.ObserveRateLimited("login", middleware.RateLimitEnforce)
+	now = now.Add(30 * time.Minute)
+	m.ObserveRateLimited("global", middleware.RateLimitEnforce)
+	// Warnings let the request through, so they aren't rejections
+	m.ObserveRateLimited("global", middleware.RateLimitWarn)
+	if got := m.RejectionsLastHour(); got != 3 {
+		t.Errorf("expected 3 rejections, got %d", got)
+	}
+
+	// An hour on, the first two age out and their slot is reused
+	now = now.Add(30 * time.Minute)
+	m.ObserveRateLimited("login", middleware.RateLimitEnforce)
+	if got := m.RejectionsLastHour(); got != 2 {
+		t.Errorf("expected 2 rejections, got %d", got)
+	}
+	now = now.Add(31 * time.Minute)
+	if got := m.RejectionsLastHour(); got != 1 {
+		t.Errorf("expected 1 rejection, got %d", got)
+	}
+
+	if got := testutil.ToFloat64(m.rejected.WithLabelValues("login", "enforce")); got != 3 {
+		t.Errorf("expected the login counter at 3, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.rejected.WithLabelValues("global", "warn")); got != 1 {
+		t.Errorf("expected 1 global warning, got %v", got)
+	}
+}
+
+func TestRateLimitMetrics_RedisFailures(t *testing.T) {
+	m := NewRateLimitMetrics(prometheus.NewRegistry())
+
+	m.ObserveRedisFailure("login", middleware.FailClosed)
+	m.ObserveRedisFailure("global", middleware.FailoverLocal)
+	m.ObserveRedisFailure("global", middleware.FailoverLocal)
+
+	if got := testutil.ToFloat64(m.redisFailures.WithLabelValues("global", "local")); got != 2 {
+		t.Errorf("expected 2 global failovers, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.redisFailures.WithLabelValues("login", "closed")); got != 1 {
+		t.Errorf("expected 1 login refusal, got %v", got)
+	}
+	// Failures aren't rejections over the limit
+	if got := m.RejectionsLastHour(); got != 0 {
+		t.Errorf("expected no rejections, got %d", got)
+	}
+}
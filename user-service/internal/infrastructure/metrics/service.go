@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"time"
+
+	"user-service/internal/application"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ application.OperationObserver = (*ServiceMetrics)(nil)
+
+// ServiceMetrics records UserService operation latency and outcomes
+type ServiceMetrics struct {
+	duration *prometheus.HistogramVec
+	total    *prometheus.CounterVec
+}
+
+// NewServiceMetrics creates the collectors and registers them with reg
+func NewServiceMetrics(reg prometheus.Registerer) *ServiceMetrics {
+	m := &ServiceMetrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "user_service",
+			Subsystem: "service",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of UserService operations.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"operation"}),
+		total: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "user_service",
+			Subsystem: "service",
+			Name:      "operations_total",
+			Help:      "UserService operations by outcome.",
+		}, []string{"operation", "outcome"}),
+	}
+
+	reg.MustRegister(m.duration, m.total)
+	return m
+}
+
+func (m *ServiceMetrics) ObserveOperation(operation, outcome string, duration time.Duration) {
+	m.duration.WithLabelValues(operation).Observe(duration.Seconds())
+	m.total.WithLabelValues(operation, outcome).Inc()
+}
@@ -0,0 +1,46 @@
+// Package notify delivers the messages the service sends users outside
+// of its API responses
+package notify
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+)
+
+var _ application.PasswordResetNotifier = (*LogNotifier)(nil)
+var _ application.EmailChangeNotifier = (*LogNotifier)(nil)
+
+// LogNotifier stands in for an email sender: it logs each reset or email
+// change it is handed instead of mailing it. The token itself is only
+// logged when showTokens is set, which must never be in production, where
+// it would let anyone with the logs reset any password or take over any
+// account's email.
+type LogNotifier struct {
+	showTokens bool
+}
+
+func NewLogNotifier(showTokens bool) *LogNotifier {
+	return &LogNotifier{showTokens: showTokens}
+}
+
+func (n *LogNotifier) SendPasswordReset(ctx context.Context, user *domain.User, token string, expiresAt time.Time) error {
+	if n.showTokens {
+		log.Printf("Password reset for user %d, valid until %s: token=%s", user.ID, expiresAt.UTC().Format(time.RFC3339), token)
+		return nil
+	}
+	log.Printf("Password reset for user %d, valid until %s (no email sender configured)", user.ID, expiresAt.UTC().Format(time.RFC3339))
+	return nil
+}
+
+func (n *LogNotifier) SendEmailChange(ctx context.Context, user *domain.User, newEmail, token string, expiresAt time.Time) error {
+	if n.showTokens {
+		log.Printf("Email change for user %d to %s, valid until %s: token=%s", user.ID, newEmail, expiresAt.UTC().Format(time.RFC3339), token)
+		return nil
+	}
+	log.Printf("Email change for user %d, valid until %s (no email sender configured)", user.ID, expiresAt.UTC().Format(time.RFC3339))
+	return nil
+}
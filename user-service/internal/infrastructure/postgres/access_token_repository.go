@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"user-service/internal/application"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+var _ application.AccessTokenRepository = (*AccessTokenRepository)(nil)
+var _ application.SessionRevoker = (*AccessTokenRepository)(nil)
+
+type AccessTokenModel struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index"`
+	Name      string `gorm:"size:100;not null"`
+	TokenHash string `gorm:"size:64;not null;uniqueIndex"`
+	// Scopes are comma-separated
+	Scopes     string `gorm:"size:200;not null"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+func (AccessTokenModel) TableName() string {
+	return "access_tokens"
+}
+
+func (m *AccessTokenModel) ToDomain() *domain.AccessToken {
+	var scopes []string
+	if m.Scopes != "" {
+		scopes = strings.Split(m.Scopes, ",")
+	}
+	return &domain.AccessToken{
+		ID:         m.ID,
+		UserID:     m.UserID,
+		Name:       m.Name,
+		Scopes:     scopes,
+		ExpiresAt:  utcPtr(m.ExpiresAt),
+		LastUsedAt: utcPtr(m.LastUsedAt),
+		CreatedAt:  utc(m.CreatedAt),
+	}
+}
+
+type AccessTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewAccessTokenRepository(db *gorm.DB) *AccessTokenRepository {
+	return &AccessTokenRepository{db: db}
+}
+
+func (r *AccessTokenRepository) WithTx(tx *gorm.DB) application.AccessTokenRepository {
+	return &AccessTokenRepository{db: tx}
+}
+
+func (r *AccessTokenRepository) Create(ctx context.Context, token *domain.AccessToken, hash string) error {
+	model := &AccessTokenModel{
+		UserID:    token.UserID,
+		Name:      token.Name,
+		TokenHash: hash,
+		Scopes:    strings.Join(token.Scopes, ","),
+		ExpiresAt: utcPtr(token.ExpiresAt),
+		CreatedAt: utc(token.CreatedAt),
+	}
+	if err := r.db.WithContext(ctx).Create(model).Error; err != nil {
+		return fmt.Errorf("failed to create access token: %w", err)
+	}
+	token.ID, token.CreatedAt = model.ID, utc(model.CreatedAt)
+	return nil
+}
+
+func (r *AccessTokenRepository) ListByUser(ctx context.Context, userID uint) ([]*domain.AccessToken, error) {
+	var models []AccessTokenModel
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Find(&models).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access tokens: %w", err)
+	}
+	tokens := make([]*domain.AccessToken, len(models))
+	for i := range models {
+		tokens[i] = models[i].ToDomain()
+	}
+	return tokens, nil
+}
+
+func (r *AccessTokenRepository) GetByHash(ctx context.Context, hash string) (*domain.AccessToken, error) {
+	var model AccessTokenModel
+	err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrAccessTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+	return model.ToDomain(), nil
+}
+
+// Delete scopes the DELETE to the owner, so one user can't revoke
+// another's token by guessing IDs
+func (r *AccessTokenRepository) Delete(ctx context.Context, userID, id uint) error {
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND user_id = ?", id, userID).
+		Delete(&AccessTokenModel{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete access token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+func (r *AccessTokenRepository) DeleteByUser(ctx context.Context, userID uint) error {
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&AccessTokenModel{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete access tokens: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserSessions deletes the user's tokens, so revoking their sessions
+// signs their scripts out too
+func (r *AccessTokenRepository) RevokeUserSessions(ctx context.Context, userID uint) error {
+	return r.DeleteByUser(ctx, userID)
+}
+
+// UpdateLastUsed writes a batch of last_used_at values in one transaction
+func (r *AccessTokenRepository) UpdateLastUsed(ctx context.Context, used map[uint]time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for id, at := range used {
+			err := tx.Model(&AccessTokenModel{}).
+				Where("id = ?", id).
+				UpdateColumn("last_used_at", at.UTC()).Error
+			if err != nil {
+				return fmt.Errorf("failed to update last use of access token %d: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
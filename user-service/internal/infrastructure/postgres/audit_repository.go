@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user-service/internal/application"
+
+	"gorm.io/gorm"
+)
+
+var _ application.AuditLogger = (*AuditRepository)(nil)
+
+type AuditLogModel struct {
+	ID        uint                   `gorm:"primaryKey"`
+	Action    string                 `gorm:"size:50;not null;index"`
+	ActorID   uint                   `gorm:"index"`
+	TargetID  uint                   `gorm:"not null;index"`
+	Reason    string                 `gorm:"size:500"`
+	Metadata  map[string]interface{} `gorm:"type:jsonb;serializer:json"`
+	CreatedAt time.Time              `gorm:"index"`
+}
+
+func (AuditLogModel) TableName() string {
+	return "audit_logs"
+}
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) WithTx(tx *gorm.DB) application.AuditLogger {
+	return &AuditRepository{db: tx}
+}
+
+func (r *AuditRepository) Record(ctx context.Context, entry *application.AuditEntry) error {
+	model := &AuditLogModel{
+		Action:    entry.Action,
+		ActorID:   entry.ActorID,
+		TargetID:  entry.TargetID,
+		Reason:    entry.Reason,
+		Metadata:  entry.Metadata,
+		CreatedAt: utc(entry.CreatedAt),
+	}
+
+	if err := r.db.WithContext(ctx).Create(model).Error; err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+
+	entry.CreatedAt = utc(model.CreatedAt)
+	return nil
+}
@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/application"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+var _ application.BulkJobRepository = (*BulkJobRepository)(nil)
+
+type BulkJobModel struct {
+	ID          uint                 `gorm:"primaryKey"`
+	Action      string               `gorm:"size:50;not null"`
+	Reason      string               `gorm:"size:1000;not null"`
+	RequestedBy string               `gorm:"size:100"`
+	UserIDs     []uint               `gorm:"type:jsonb;serializer:json;not null"`
+	Status      string               `gorm:"size:20;not null;index"`
+	Processed   int                  `gorm:"not null;default:0"`
+	Failures    []domain.BulkFailure `gorm:"type:jsonb;serializer:json"`
+	ReportKey   string               `gorm:"size:200"`
+	CreatedAt   time.Time
+	StartedAt   *time.Time
+	FinishedAt  *time.Time
+}
+
+func (BulkJobModel) TableName() string {
+	return "bulk_jobs"
+}
+
+func (m *BulkJobModel) ToDomain() *domain.BulkJob {
+	return &domain.BulkJob{
+		ID:          m.ID,
+		Action:      domain.BulkAction(m.Action),
+		Reason:      m.Reason,
+		RequestedBy: m.RequestedBy,
+		UserIDs:     m.UserIDs,
+		Status:      domain.BulkJobStatus(m.Status),
+		Processed:   m.Processed,
+		Failures:    m.Failures,
+		ReportKey:   m.ReportKey,
+		CreatedAt:   utc(m.CreatedAt),
+		StartedAt:   utcPtr(m.StartedAt),
+		FinishedAt:  utcPtr(m.FinishedAt),
+	}
+}
+
+type BulkJobRepository struct {
+	db *gorm.DB
+}
+
+func NewBulkJobRepository(db *gorm.DB) *BulkJobRepository {
+	return &BulkJobRepository{db: db}
+}
+
+func (r *BulkJobRepository) Create(ctx context.Context, job *domain.BulkJob) error {
+	model := &BulkJobModel{
+		Action:      string(job.Action),
+		Reason:      job.Reason,
+		RequestedBy: job.RequestedBy,
+		UserIDs:     job.UserIDs,
+		Status:      string(job.Status),
+		CreatedAt:   utc(job.CreatedAt),
+	}
+	if err := r.db.WithContext(ctx).Create(model).Error; err != nil {
+		return fmt.Errorf("failed to create bulk job: %w", err)
+	}
+	job.ID, job.CreatedAt = model.ID, utc(model.CreatedAt)
+	return nil
+}
+
+func (r *BulkJobRepository) Get(ctx context.Context, id uint) (*domain.BulkJob, error) {
+	var model BulkJobModel
+	err := r.db.WithContext(ctx).First(&model, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrBulkJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bulk job: %w", err)
+	}
+	return model.ToDomain(), nil
+}
+
+func (r *BulkJobRepository) NextUnfinished(ctx context.Context) (*domain.BulkJob, error) {
+	var model BulkJobModel
+	err := r.db.WithContext(ctx).
+		Where("status <> ?", string(domain.BulkJobDone)).
+		Order("id").
+		First(&model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find unfinished bulk job: %w", err)
+	}
+	return model.ToDomain(), nil
+}
+
+func (r *BulkJobRepository) SaveProgress(ctx context.Context, job *domain.BulkJob) error {
+	// Select writes Failures even when it is empty
+	err := r.db.WithContext(ctx).
+		Model(&BulkJobModel{ID: job.ID}).
+		Select("status", "processed", "failures", "report_key", "started_at", "finished_at").
+		Updates(&BulkJobModel{
+			Status:     string(job.Status),
+			Processed:  job.Processed,
+			Failures:   job.Failures,
+			ReportKey:  job.ReportKey,
+			StartedAt:  utcPtr(job.StartedAt),
+			FinishedAt: utcPtr(job.FinishedAt),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to save bulk job progress: %w", err)
+	}
+	return nil
+}
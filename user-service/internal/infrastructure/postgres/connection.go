@@ -6,6 +6,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/uptrace/opentelemetry-go-extra/otelgorm"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -45,6 +46,12 @@ func NewConnection(cfg *DBConfig) (*gorm.DB, error) {
 		if err == nil {
 			break
 		}
+		// A failed Open still leaves its pool behind
+		if db != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				sqlDB.Close()
+			}
+		}
 		log.Printf("Failed to connect to database (attempt %d/%d): %v",
 			i+1, cfg.RetryAttempts, err)
 
@@ -58,6 +65,12 @@ func NewConnection(cfg *DBConfig) (*gorm.DB, error) {
 			cfg.RetryAttempts, err)
 	}
 
+	// Queries become spans beneath the request's; without their values,
+	// which include emails and password hashes
+	if err := db.Use(otelgorm.NewPlugin(otelgorm.WithoutQueryVariables(), otelgorm.WithoutMetrics())); err != nil {
+		return nil, fmt.Errorf("failed to set up tracing: %w", err)
+	}
+
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sql.DB: %w", err)
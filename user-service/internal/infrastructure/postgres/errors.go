@@ -3,13 +3,14 @@ package postgres
 import (
 	"errors"
 	"strings"
+	"user-service/internal/domain"
 
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
 var (
-	ErrUserNotFound   = errors.New("user not found")
-	ErrDuplicateUser  = errors.New("user already exists")
+	ErrUserNotFound   = domain.ErrUserNotFound
+	ErrDuplicateUser  = domain.ErrDuplicateUser
 	ErrOptimisticLock = errors.New("record was modified by another process")
 	ErrEmailExists    = errors.New("email already exists")
 )
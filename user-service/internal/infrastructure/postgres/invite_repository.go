@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/application"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+var _ application.InviteRepository = (*InviteRepository)(nil)
+
+type InviteModel struct {
+	ID        uint   `gorm:"primaryKey"`
+	Code      string `gorm:"size:64;not null;uniqueIndex"`
+	CreatedBy string `gorm:"size:100"`
+	MaxUses   int    `gorm:"not null"`
+	Uses      int    `gorm:"not null;default:0"`
+	ExpiresAt *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+func (InviteModel) TableName() string {
+	return "invites"
+}
+
+func (m *InviteModel) ToDomain() *domain.Invite {
+	return &domain.Invite{
+		Code:      m.Code,
+		CreatedBy: m.CreatedBy,
+		MaxUses:   m.MaxUses,
+		Uses:      m.Uses,
+		ExpiresAt: utcPtr(m.ExpiresAt),
+		RevokedAt: utcPtr(m.RevokedAt),
+		CreatedAt: utc(m.CreatedAt),
+	}
+}
+
+type InviteRepository struct {
+	db *gorm.DB
+}
+
+func NewInviteRepository(db *gorm.DB) *InviteRepository {
+	return &InviteRepository{db: db}
+}
+
+func (r *InviteRepository) WithTx(tx *gorm.DB) application.InviteRepository {
+	return &InviteRepository{db: tx}
+}
+
+func (r *InviteRepository) Create(ctx context.Context, invite *domain.Invite) error {
+	model := &InviteModel{
+		Code:      invite.Code,
+		CreatedBy: invite.CreatedBy,
+		MaxUses:   invite.MaxUses,
+		Uses:      invite.Uses,
+		ExpiresAt: utcPtr(invite.ExpiresAt),
+		CreatedAt: utc(invite.CreatedAt),
+	}
+	if err := r.db.WithContext(ctx).Create(model).Error; err != nil {
+		return fmt.Errorf("failed to create invite: %w", err)
+	}
+	invite.CreatedAt = utc(model.CreatedAt)
+	return nil
+}
+
+// Get returns the invite with the given code
+func (r *InviteRepository) Get(ctx context.Context, code string) (*domain.Invite, error) {
+	var model InviteModel
+	err := r.db.WithContext(ctx).Where("code = ?", code).First(&model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrInviteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite: %w", err)
+	}
+	return model.ToDomain(), nil
+}
+
+func (r *InviteRepository) Revoke(ctx context.Context, code string, at time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&InviteModel{}).
+		Where("code = ? AND revoked_at IS NULL", code).
+		Update("revoked_at", at.UTC())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke invite: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		// Already revoked is fine; unknown isn't
+		_, err := r.Get(ctx, code)
+		return err
+	}
+	return nil
+}
+
+// Consume increments uses with a single conditional UPDATE. Concurrent
+// redemptions of the same code queue on its row lock and re-check the
+// condition once the one ahead commits, so the last use can only go once.
+func (r *InviteRepository) Consume(ctx context.Context, code string, now time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&InviteModel{}).
+		Where("code = ? AND revoked_at IS NULL AND uses < max_uses", code).
+		Where("expires_at IS NULL OR expires_at > ?", now.UTC()).
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return fmt.Errorf("failed to redeem invite: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	// Nothing matched; say why
+	invite, err := r.Get(ctx, code)
+	if err != nil {
+		return err
+	}
+	if err := invite.Usable(now); err != nil {
+		return err
+	}
+	return domain.ErrInviteExhausted
+}
@@ -0,0 +1,106 @@
+// internal/infrastructure/postgres/invite_repository_test.go
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+func seedInvite(t *testing.T, repo *InviteRepository, invite domain.Invite) string {
+	t.Helper()
+	if err := repo.Create(context.Background(), &invite); err != nil {
+		t.Fatalf("seed %s: %v", invite.Code, err)
+	}
+	return invite.Code
+}
+
+func TestInviteRepository_ConcurrentRedemptionsStopAtMaxUses(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewInviteRepository(db)
+	users := NewUserRepository(db)
+	txManager := NewTransactionManager(db)
+	code := seedInvite(t, repo, domain.Invite{Code: "RACE-RACE-RACE", MaxUses: 5, CreatedAt: time.Now()})
+
+	// Each racer redeems and creates its account in one transaction, as
+	// Register does
+	const racers = 20
+	errs := make([]error, racers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = txManager.ExecuteInTx(context.Background(), func(tx *gorm.DB) error {
+				if err := repo.WithTx(tx).Consume(context.Background(), code, time.Now()); err != nil {
+					return err
+				}
+				name := fmt.Sprintf("racer%d", i)
+				return users.WithTx(tx).Create(context.Background(), &domain.User{
+					Username: name, Email: name + "@example.com",
+				}, "hash")
+			})
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	redeemed := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			redeemed++
+		case !errors.Is(err, domain.ErrInviteExhausted):
+			t.Errorf("racer %d: expected ErrInviteExhausted, got %v", i, err)
+		}
+	}
+	invite, err := repo.Get(context.Background(), code)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	var accounts int64
+	db.Model(&UserModel{}).Count(&accounts)
+	if redeemed != 5 || invite.Uses != 5 || accounts != 5 {
+		t.Errorf("expected 5 redemptions, got %d (uses %d, accounts %d)", redeemed, invite.Uses, accounts)
+	}
+}
+
+func TestInviteRepository_ConsumeSaysWhy(t *testing.T) {
+	repo := NewInviteRepository(openTestDB(t))
+	ctx := context.Background()
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+
+	seedInvite(t, repo, domain.Invite{Code: "USED", MaxUses: 1, Uses: 1, CreatedAt: now})
+	seedInvite(t, repo, domain.Invite{Code: "OLD", MaxUses: 1, ExpiresAt: &expired, CreatedAt: now})
+	seedInvite(t, repo, domain.Invite{Code: "GONE", MaxUses: 1, CreatedAt: now})
+	if err := repo.Revoke(ctx, "GONE", now); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if err := repo.Revoke(ctx, "GONE", now); err != nil {
+		t.Errorf("expected revoking twice to be a no-op, got %v", err)
+	}
+
+	for code, want := range map[string]error{
+		"USED":    domain.ErrInviteExhausted,
+		"OLD":     domain.ErrInviteExpired,
+		"GONE":    domain.ErrInviteRevoked,
+		"MISSING": domain.ErrInviteNotFound,
+	} {
+		if err := repo.Consume(ctx, code, now); !errors.Is(err, want) {
+			t.Errorf("%s: expected %v, got %v", code, want, err)
+		}
+	}
+	if err := repo.Revoke(ctx, "MISSING", now); !errors.Is(err, domain.ErrInviteNotFound) {
+		t.Errorf("expected ErrInviteNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user-service/internal/application"
+
+	"gorm.io/gorm"
+)
+
+var _ application.LoginAttemptStore = (*LoginAttemptRepository)(nil)
+
+// LoginAttemptModel deliberately holds no email so erasure doesn't have to
+// touch it
+type LoginAttemptModel struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"index"`
+	Success   bool      `gorm:"not null"`
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (LoginAttemptModel) TableName() string {
+	return "login_attempts"
+}
+
+type LoginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+func (r *LoginAttemptRepository) RecordLoginAttempt(ctx context.Context, attempt *application.LoginAttempt) error {
+	model := &LoginAttemptModel{
+		UserID:    attempt.UserID,
+		Success:   attempt.Success,
+		CreatedAt: utc(attempt.CreatedAt),
+	}
+
+	if err := r.db.WithContext(ctx).Create(model).Error; err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Migrate brings the schema up to date for every model this package owns
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(
+		&UserModel{},
+		&AuditLogModel{},
+		&LoginAttemptModel{},
+		&InviteModel{},
+		&RecoveryCodeModel{},
+		&AccessTokenModel{},
+		&BulkJobModel{},
+		&RefreshTokenModel{},
+		&NoticeModel{},
+		&NoticeDismissalModel{},
+		&SessionModel{},
+	); err != nil {
+		return fmt.Errorf("failed to migrate: %w", err)
+	}
+	// The tests' SQLite stand-in has neither extensions nor gin
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	for _, stmt := range indexes {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to migrate: %w", err)
+		}
+	}
+	return nil
+}
+
+// indexes are the ones struct tags can't declare. The trigram indexes
+// serve the user search's ILIKE '%q%', which a btree can't; pg_trgm is a
+// trusted extension, so the service's own role can install it.
+var indexes = []string{
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+	`CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING gin (username gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING gin (email gin_trgm_ops)`,
+}
+
+// errDryRun rolls back CheckMigrations' transaction
+var errDryRun = errors.New("dry run")
+
+// CheckMigrations runs Migrate in a transaction that is always rolled back,
+// reporting whether the schema can be brought up to date without changing
+// it. DDL is transactional in Postgres, so nothing is left behind.
+func CheckMigrations(ctx context.Context, db *gorm.DB) error {
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := Migrate(tx); err != nil {
+			return err
+		}
+		return errDryRun
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user-service/internal/application"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+var _ application.NoticeRepository = (*NoticeRepository)(nil)
+
+type NoticeModel struct {
+	ID          uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"not null;uniqueIndex:idx_user_notices_user_code"`
+	Code        string `gorm:"size:50;not null;uniqueIndex:idx_user_notices_user_code"`
+	Severity    string `gorm:"size:20;not null"`
+	Message     string `gorm:"size:500;not null"`
+	Dismissible bool   `gorm:"not null;default:false"`
+	ExpiresAt   *time.Time
+	CreatedBy   string `gorm:"size:100"`
+	CreatedAt   time.Time
+}
+
+func (NoticeModel) TableName() string {
+	return "user_notices"
+}
+
+func (m *NoticeModel) ToDomain() *domain.UserNotice {
+	return &domain.UserNotice{
+		ID:          m.ID,
+		UserID:      m.UserID,
+		Code:        m.Code,
+		Severity:    domain.NoticeSeverity(m.Severity),
+		Message:     m.Message,
+		Dismissible: m.Dismissible,
+		ExpiresAt:   utcPtr(m.ExpiresAt),
+		CreatedBy:   m.CreatedBy,
+		CreatedAt:   utc(m.CreatedAt),
+	}
+}
+
+// NoticeDismissalModel is the latest version of a notice a user dismissed
+type NoticeDismissalModel struct {
+	ID          uint      `gorm:"primaryKey"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_notice_dismissals_user_code"`
+	Code        string    `gorm:"size:50;not null;uniqueIndex:idx_notice_dismissals_user_code"`
+	Version     string    `gorm:"size:100;not null;default:''"`
+	DismissedAt time.Time `gorm:"not null"`
+}
+
+func (NoticeDismissalModel) TableName() string {
+	return "notice_dismissals"
+}
+
+type NoticeRepository struct {
+	db *gorm.DB
+}
+
+func NewNoticeRepository(db *gorm.DB) *NoticeRepository {
+	return &NoticeRepository{db: db}
+}
+
+func (r *NoticeRepository) ListByUser(ctx context.Context, userID uint) ([]*domain.UserNotice, error) {
+	var models []NoticeModel
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("id").
+		Find(&models).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notices: %w", err)
+	}
+	notices := make([]*domain.UserNotice, len(models))
+	for i := range models {
+		notices[i] = models[i].ToDomain()
+	}
+	return notices, nil
+}
+
+// Put deletes and inserts rather than updating in place, so a reissued
+// notice gets a new ID and shows again to users who dismissed the old one
+func (r *NoticeRepository) Put(ctx context.Context, notice *domain.UserNotice) error {
+	model := &NoticeModel{
+		UserID:      notice.UserID,
+		Code:        notice.Code,
+		Severity:    string(notice.Severity),
+		Message:     notice.Message,
+		Dismissible: notice.Dismissible,
+		ExpiresAt:   utcPtr(notice.ExpiresAt),
+		CreatedBy:   notice.CreatedBy,
+		CreatedAt:   utc(notice.CreatedAt),
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND code = ?", notice.UserID, notice.Code).
+			Delete(&NoticeModel{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(model).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put notice: %w", err)
+	}
+	notice.ID, notice.CreatedAt = model.ID, utc(model.CreatedAt)
+	return nil
+}
+
+func (r *NoticeRepository) Delete(ctx context.Context, userID uint, code string) error {
+	result := r.db.WithContext(ctx).
+		Where("user_id = ? AND code = ?", userID, code).
+		Delete(&NoticeModel{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete notice: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrNoticeNotFound
+	}
+	return nil
+}
+
+func (r *NoticeRepository) Dismissals(ctx context.Context, userID uint) (map[string]string, error) {
+	var models []NoticeDismissalModel
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("failed to list notice dismissals: %w", err)
+	}
+	dismissed := make(map[string]string, len(models))
+	for _, m := range models {
+		dismissed[m.Code] = m.Version
+	}
+	return dismissed, nil
+}
+
+func (r *NoticeRepository) Dismiss(ctx context.Context, userID uint, code, version string, at time.Time) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND code = ?", userID, code).
+			Delete(&NoticeDismissalModel{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&NoticeDismissalModel{
+			UserID:      userID,
+			Code:        code,
+			Version:     version,
+			DismissedAt: at.UTC(),
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to dismiss notice: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// internal/infrastructure/postgres/notice_repository_test.go
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-service/internal/domain"
+)
+
+func TestNoticeRepository_PutReplacesByCode(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewNoticeRepository(db)
+	ctx := context.Background()
+
+	first := &domain.UserNotice{UserID: 1, Code: "survey", Severity: domain.NoticeInfo, Message: "Tell us", CreatedAt: time.Now()}
+	if err := repo.Put(ctx, first); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	second := &domain.UserNotice{UserID: 1, Code: "survey", Severity: domain.NoticeWarning, Message: "Last chance", CreatedAt: time.Now()}
+	if err := repo.Put(ctx, second); err != nil {
+		t.Fatalf("reissue: %v", err)
+	}
+	if err := repo.Put(ctx, &domain.UserNotice{UserID: 2, Code: "survey", Severity: domain.NoticeInfo, Message: "Tell us", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("put for another user: %v", err)
+	}
+
+	notices, err := repo.ListByUser(ctx, 1)
+	if err != nil || len(notices) != 1 {
+		t.Fatalf("expected one notice, got %+v (%v)", notices, err)
+	}
+	if notices[0].ID == first.ID || notices[0].Message != "Last chance" {
+		t.Errorf("expected the reissued notice under a new ID, got %+v", notices[0])
+	}
+
+	if err := repo.Delete(ctx, 1, "survey"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := repo.Delete(ctx, 1, "survey"); !errors.Is(err, domain.ErrNoticeNotFound) {
+		t.Errorf("expected ErrNoticeNotFound, got %v", err)
+	}
+}
+
+func TestNoticeRepository_DismissKeepsTheLatestVersion(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewNoticeRepository(db)
+	ctx := context.Background()
+
+	for _, version := range []string{"2024-05", "2024-06"} {
+		if err := repo.Dismiss(ctx, 1, domain.NoticeTermsUpdated, version, time.Now()); err != nil {
+			t.Fatalf("dismiss %s: %v", version, err)
+		}
+	}
+	dismissed, err := repo.Dismissals(ctx, 1)
+	if err != nil {
+		t.Fatalf("dismissals: %v", err)
+	}
+	if len(dismissed) != 1 || dismissed[domain.NoticeTermsUpdated] != "2024-06" {
+		t.Errorf("expected only the latest dismissal, got %v", dismissed)
+	}
+}
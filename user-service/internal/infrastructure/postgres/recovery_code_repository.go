@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user-service/internal/application"
+
+	"gorm.io/gorm"
+)
+
+var _ application.RecoveryCodeRepository = (*RecoveryCodeRepository)(nil)
+
+type RecoveryCodeModel struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;uniqueIndex:idx_recovery_codes_user_hash"`
+	CodeHash  string `gorm:"size:64;not null;uniqueIndex:idx_recovery_codes_user_hash"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (RecoveryCodeModel) TableName() string {
+	return "recovery_codes"
+}
+
+type RecoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{db: db}
+}
+
+func (r *RecoveryCodeRepository) WithTx(tx *gorm.DB) application.RecoveryCodeRepository {
+	return &RecoveryCodeRepository{db: tx}
+}
+
+// Replace deletes the user's codes and inserts the new ones; run it in a
+// transaction so the user is never left with neither
+func (r *RecoveryCodeRepository) Replace(ctx context.Context, userID uint, hashes []string, at time.Time) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Where("user_id = ?", userID).Delete(&RecoveryCodeModel{}).Error; err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	models := make([]RecoveryCodeModel, len(hashes))
+	for i, hash := range hashes {
+		models[i] = RecoveryCodeModel{UserID: userID, CodeHash: hash, CreatedAt: utc(at)}
+	}
+	if len(models) == 0 {
+		return nil
+	}
+	if err := db.Create(&models).Error; err != nil {
+		return fmt.Errorf("failed to store recovery codes: %w", err)
+	}
+	return nil
+}
+
+// Consume sets used_at with a single conditional UPDATE, so of two
+// requests racing with the same code only one sees a row change
+func (r *RecoveryCodeRepository) Consume(ctx context.Context, userID uint, hash string, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&RecoveryCodeModel{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", at.UTC())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *RecoveryCodeRepository) CountRemaining(ctx context.Context, userID uint) (int, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&RecoveryCodeModel{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return int(count), nil
+}
+
+func (r *RecoveryCodeRepository) DeleteByUser(ctx context.Context, userID uint) error {
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&RecoveryCodeModel{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// internal/infrastructure/postgres/recovery_code_repository_test.go
+package postgres
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecoveryCodeRepository_ConcurrentUseSucceedsOnce(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewRecoveryCodeRepository(db)
+	user := seedUser(t, NewUserRepository(db), "alice")
+	ctx := context.Background()
+
+	if err := repo.Replace(ctx, user.ID, []string{"hash-a", "hash-b"}, time.Now()); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+
+	const racers = 10
+	used := make([]bool, racers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			ok, err := repo.Consume(ctx, user.ID, "hash-a", time.Now())
+			if err != nil {
+				t.Errorf("consume: %v", err)
+			}
+			used[i] = ok
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	wins := 0
+	for _, ok := range used {
+		if ok {
+			wins++
+		}
+	}
+	if wins != 1 {
+		t.Fatalf("expected the code to be used exactly once, got %d", wins)
+	}
+	if remaining, err := repo.CountRemaining(ctx, user.ID); err != nil || remaining != 1 {
+		t.Fatalf("expected 1 code left, got %d (%v)", remaining, err)
+	}
+
+	// Regenerating drops the old codes, used or not
+	if err := repo.Replace(ctx, user.ID, []string{"hash-c"}, time.Now()); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if ok, err := repo.Consume(ctx, user.ID, "hash-b", time.Now()); err != nil || ok {
+		t.Errorf("expected a replaced code to be unusable, got %v (%v)", ok, err)
+	}
+	if remaining, err := repo.CountRemaining(ctx, user.ID); err != nil || remaining != 1 {
+		t.Errorf("expected 1 code after replacing, got %d (%v)", remaining, err)
+	}
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/application"
+	"user-service/internal/infrastructure/auth"
+
+	"gorm.io/gorm"
+)
+
+var _ auth.RefreshTokenStore = (*RefreshTokenRepository)(nil)
+var _ application.SessionRevoker = (*RefreshTokenRepository)(nil)
+
+type RefreshTokenModel struct {
+	ID        uint      `gorm:"primaryKey"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	UserID    uint      `gorm:"not null;index"`
+	Family    string    `gorm:"size:32;not null;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	RotatedAt *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+func (RefreshTokenModel) TableName() string {
+	return "refresh_tokens"
+}
+
+func (m *RefreshTokenModel) ToAuth() *auth.RefreshToken {
+	return &auth.RefreshToken{
+		Hash:      m.TokenHash,
+		UserID:    m.UserID,
+		Family:    m.Family,
+		ExpiresAt: utc(m.ExpiresAt),
+		CreatedAt: utc(m.CreatedAt),
+		RotatedAt: utcPtr(m.RotatedAt),
+		RevokedAt: utcPtr(m.RevokedAt),
+	}
+}
+
+type RefreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db}
+}
+
+func (r *RefreshTokenRepository) Create(ctx context.Context, token *auth.RefreshToken) error {
+	model := &RefreshTokenModel{
+		TokenHash: token.Hash,
+		UserID:    token.UserID,
+		Family:    token.Family,
+		ExpiresAt: utc(token.ExpiresAt),
+		CreatedAt: utc(token.CreatedAt),
+	}
+	if err := r.db.WithContext(ctx).Create(model).Error; err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *RefreshTokenRepository) Get(ctx context.Context, hash string) (*auth.RefreshToken, error) {
+	var model RefreshTokenModel
+	err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, auth.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	return model.ToAuth(), nil
+}
+
+// Rotate is a single conditional UPDATE, so of two concurrent exchanges of
+// the same token only the first to take the row lock matches
+func (r *RefreshTokenRepository) Rotate(ctx context.Context, hash string, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&RefreshTokenModel{}).
+		Where("token_hash = ? AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > ?", hash, at.UTC()).
+		UpdateColumn("rotated_at", at.UTC())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, family string, at time.Time) error {
+	err := r.db.WithContext(ctx).
+		Model(&RefreshTokenModel{}).
+		Where("family = ? AND revoked_at IS NULL", family).
+		UpdateColumn("revoked_at", at.UTC()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every refresh token the user holds, so a
+// password change or ban signs out their refreshable logins too
+func (r *RefreshTokenRepository) RevokeUserSessions(ctx context.Context, userID uint) error {
+	err := r.db.WithContext(ctx).
+		Model(&RefreshTokenModel{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		UpdateColumn("revoked_at", time.Now().UTC()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes tokens that expired before before, reporting how
+// many went
+func (r *RefreshTokenRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("expires_at < ?", before.UTC()).
+		Delete(&RefreshTokenModel{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,111 @@
+// internal/infrastructure/postgres/refresh_token_repository_test.go
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/infrastructure/auth"
+)
+
+func TestRefreshTokenRepository_ConcurrentRotationSucceedsOnce(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewRefreshTokenRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	token := &auth.RefreshToken{Hash: "hash-a", UserID: 1, Family: "family-a", ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+	if err := repo.Create(ctx, token); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	const racers = 10
+	rotated := make([]bool, racers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			ok, err := repo.Rotate(ctx, "hash-a", time.Now())
+			if err != nil {
+				t.Errorf("rotate: %v", err)
+			}
+			rotated[i] = ok
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	wins := 0
+	for _, ok := range rotated {
+		if ok {
+			wins++
+		}
+	}
+	if wins != 1 {
+		t.Fatalf("expected exactly one rotation, got %d", wins)
+	}
+	stored, err := repo.Get(ctx, "hash-a")
+	if err != nil || stored.RotatedAt == nil {
+		t.Fatalf("expected the token marked rotated, got %+v (%v)", stored, err)
+	}
+}
+
+func TestRefreshTokenRepository_RevocationAndExpiry(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewRefreshTokenRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, token := range []*auth.RefreshToken{
+		{Hash: "a1", UserID: 1, Family: "a", ExpiresAt: now.Add(time.Hour), CreatedAt: now},
+		{Hash: "a2", UserID: 1, Family: "a", ExpiresAt: now.Add(time.Hour), CreatedAt: now},
+		{Hash: "b1", UserID: 1, Family: "b", ExpiresAt: now.Add(time.Hour), CreatedAt: now},
+		{Hash: "c1", UserID: 2, Family: "c", ExpiresAt: now.Add(time.Hour), CreatedAt: now},
+		{Hash: "old", UserID: 2, Family: "d", ExpiresAt: now.Add(-time.Hour), CreatedAt: now.Add(-2 * time.Hour)},
+	} {
+		if err := repo.Create(ctx, token); err != nil {
+			t.Fatalf("create %s: %v", token.Hash, err)
+		}
+	}
+
+	if ok, err := repo.Rotate(ctx, "old", now); ok || err != nil {
+		t.Errorf("expected an expired token left alone, got %v (%v)", ok, err)
+	}
+
+	if err := repo.RevokeFamily(ctx, "a", now); err != nil {
+		t.Fatalf("revoke family: %v", err)
+	}
+	for hash, wantRevoked := range map[string]bool{"a1": true, "a2": true, "b1": false} {
+		stored, _ := repo.Get(ctx, hash)
+		if (stored.RevokedAt != nil) != wantRevoked {
+			t.Errorf("%s: expected revoked=%v, got %+v", hash, wantRevoked, stored)
+		}
+	}
+	if ok, _ := repo.Rotate(ctx, "a1", now); ok {
+		t.Error("expected a revoked token not to rotate")
+	}
+
+	if err := repo.RevokeUserSessions(ctx, 1); err != nil {
+		t.Fatalf("revoke user: %v", err)
+	}
+	if stored, _ := repo.Get(ctx, "b1"); stored.RevokedAt == nil {
+		t.Error("expected every token of the user revoked")
+	}
+	if stored, _ := repo.Get(ctx, "c1"); stored.RevokedAt != nil {
+		t.Error("expected other users' tokens kept")
+	}
+
+	deleted, err := repo.DeleteExpired(ctx, now)
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected the expired token deleted, got %d (%v)", deleted, err)
+	}
+	if _, err := repo.Get(ctx, "old"); !errors.Is(err, auth.ErrInvalidRefreshToken) {
+		t.Errorf("expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
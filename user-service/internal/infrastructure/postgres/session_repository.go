@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"user-service/internal/application"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+var _ application.SessionRepository = (*SessionRepository)(nil)
+
+type SessionModel struct {
+	// ID is also the family of the session's refresh tokens
+	ID         string    `gorm:"primaryKey;size:32"`
+	UserID     uint      `gorm:"not null;index"`
+	UserAgent  string    `gorm:"size:512"`
+	IP         string    `gorm:"size:64"`
+	CreatedAt  time.Time `gorm:"not null"`
+	LastUsedAt time.Time `gorm:"not null"`
+	ExpiresAt  time.Time `gorm:"not null;index"`
+	RevokedAt  *time.Time
+}
+
+func (SessionModel) TableName() string {
+	return "sessions"
+}
+
+func (m *SessionModel) ToDomain() *domain.Session {
+	return &domain.Session{
+		ID:         m.ID,
+		UserID:     m.UserID,
+		UserAgent:  m.UserAgent,
+		IP:         m.IP,
+		CreatedAt:  utc(m.CreatedAt),
+		LastUsedAt: utc(m.LastUsedAt),
+		ExpiresAt:  utc(m.ExpiresAt),
+		RevokedAt:  utcPtr(m.RevokedAt),
+	}
+}
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) Create(ctx context.Context, session *domain.Session) error {
+	model := &SessionModel{
+		ID:         session.ID,
+		UserID:     session.UserID,
+		UserAgent:  truncate(session.UserAgent, 512),
+		IP:         truncate(session.IP, 64),
+		CreatedAt:  utc(session.CreatedAt),
+		LastUsedAt: utc(session.LastUsedAt),
+		ExpiresAt:  utc(session.ExpiresAt),
+	}
+	if err := r.db.WithContext(ctx).Create(model).Error; err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) Get(ctx context.Context, id string) (*domain.Session, error) {
+	var model SessionModel
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return model.ToDomain(), nil
+}
+
+func (r *SessionRepository) ListActive(ctx context.Context, userID uint, now time.Time) ([]*domain.Session, error) {
+	var models []SessionModel
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now.UTC()).
+		Order("last_used_at DESC").
+		Find(&models).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	sessions := make([]*domain.Session, len(models))
+	for i := range models {
+		sessions[i] = models[i].ToDomain()
+	}
+	return sessions, nil
+}
+
+func (r *SessionRepository) Touch(ctx context.Context, id string, at, expiresAt time.Time) error {
+	err := r.db.WithContext(ctx).
+		Model(&SessionModel{}).
+		Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"last_used_at": at.UTC(),
+			"expires_at":   expiresAt.UTC(),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	return nil
+}
+
+// Revoke only matches the user's own unrevoked, unexpired session, so
+// guessing another user's session ID reveals nothing
+func (r *SessionRepository) Revoke(ctx context.Context, userID uint, id string, at time.Time) error {
+	result := r.db.WithContext(ctx).
+		Model(&SessionModel{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", id, userID, at.UTC()).
+		UpdateColumn("revoked_at", at.UTC())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrSessionNotFound
+	}
+	return nil
+}
+
+// RevokeUserSessions ends every session the user has, so they drop off
+// the list after a logout everywhere, password change or ban
+func (r *SessionRepository) RevokeUserSessions(ctx context.Context, userID uint) error {
+	err := r.db.WithContext(ctx).
+		Model(&SessionModel{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		UpdateColumn("revoked_at", time.Now().UTC()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) Anonymize(ctx context.Context, userID uint) error {
+	err := r.db.WithContext(ctx).
+		Model(&SessionModel{}).
+		Where("user_id = ?", userID).
+		UpdateColumns(map[string]interface{}{
+			"user_agent": "",
+			"ip":         "",
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to anonymize sessions: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes sessions that expired before before, reporting
+// how many went
+func (r *SessionRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("expires_at < ?", before.UTC()).
+		Delete(&SessionModel{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// truncate cuts s to at most n bytes, so an oversized header can't fail
+// the login
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
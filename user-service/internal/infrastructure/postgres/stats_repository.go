@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"user-service/internal/application"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+var (
+	_ application.ActivityStatsRepository = (*StatsRepository)(nil)
+	_ application.UserCountRepository     = (*StatsRepository)(nil)
+)
+
+// StatsRepository runs the date_trunc bucketed counts behind the admin
+// activity dashboard. Empty buckets are filled in by the service.
+type StatsRepository struct {
+	db *gorm.DB
+}
+
+func NewStatsRepository(db *gorm.DB) *StatsRepository {
+	return &StatsRepository{db: db}
+}
+
+// CountSignups includes accounts deleted since, the signup still happened
+func (r *StatsRepository) CountSignups(ctx context.Context, granularity application.Granularity, from, to time.Time) ([]application.BucketCount, error) {
+	return r.countBuckets(ctx, "users", "", granularity, from, to)
+}
+
+// CountLogins counts successful logins only
+func (r *StatsRepository) CountLogins(ctx context.Context, granularity application.Granularity, from, to time.Time) ([]application.BucketCount, error) {
+	return r.countBuckets(ctx, "login_attempts", "success = true", granularity, from, to)
+}
+
+// CountDeletions counts deletion requests from the audit log
+func (r *StatsRepository) CountDeletions(ctx context.Context, granularity application.Granularity, from, to time.Time) ([]application.BucketCount, error) {
+	filter := fmt.Sprintf("action = '%s'", application.AuditDeletionRequested)
+	return r.countBuckets(ctx, "audit_logs", filter, granularity, from, to)
+}
+
+// CountUsersByStatus reads past the default scope so erased accounts are
+// counted too
+func (r *StatsRepository) CountUsersByStatus(ctx context.Context) (map[domain.UserStatus]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.WithContext(ctx).
+		Model(&UserModel{}).
+		Unscoped().
+		Select("status, count(*) AS count").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	counts := make(map[domain.UserStatus]int64, len(rows))
+	for _, row := range rows {
+		counts[domain.UserStatus(row.Status)] = row.Count
+	}
+	return counts, nil
+}
+
+// countBuckets groups rows of table by date_trunc(granularity, created_at)
+// in UTC. table and filter are constants from this file, never user input.
+func (r *StatsRepository) countBuckets(
+	ctx context.Context,
+	table, filter string,
+	granularity application.Granularity,
+	from, to time.Time,
+) ([]application.BucketCount, error) {
+	where := "created_at >= ? AND created_at < ?"
+	if filter != "" {
+		where += " AND " + filter
+	}
+
+	query := fmt.Sprintf(`
+		SELECT date_trunc(?, created_at AT TIME ZONE 'UTC') AS start, count(*) AS count
+		FROM %s
+		WHERE %s
+		GROUP BY 1
+		ORDER BY 1`, table, where)
+
+	var rows []struct {
+		Start time.Time
+		Count int64
+	}
+	if err := r.db.WithContext(ctx).Raw(query, string(granularity), from.UTC(), to.UTC()).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count %s: %w", table, err)
+	}
+
+	counts := make([]application.BucketCount, len(rows))
+	for i, row := range rows {
+		// AT TIME ZONE 'UTC' yields a timestamp without zone; pin it to UTC
+		start := row.Start
+		counts[i] = application.BucketCount{
+			Start: time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC),
+			Count: row.Count,
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,101 @@
+// internal/infrastructure/postgres/stats_repository_test.go
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+)
+
+func TestStatsRepository_BucketsSeededMonth(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	at := func(day, hour int) time.Time {
+		return time.Date(2024, time.January, day, hour, 30, 0, 0, time.UTC)
+	}
+
+	// Signups on Jan 1 (x2), Jan 15 and Jan 31, one of them since deleted
+	for i, created := range []time.Time{at(1, 0), at(1, 23), at(15, 12), at(31, 23)} {
+		user := &UserModel{
+			Username:  fmt.Sprintf("user%d", i),
+			Email:     fmt.Sprintf("user%d@example.com", i),
+			Password:  "x",
+			CreatedAt: created,
+		}
+		if err := db.Create(user).Error; err != nil {
+			t.Fatalf("seed user: %v", err)
+		}
+		if i == 0 {
+			db.Delete(user)
+		}
+	}
+
+	// Successful logins on Jan 2 (x2) and Jan 20; failures are not counted
+	for _, attempt := range []LoginAttemptModel{
+		{UserID: 1, Success: true, CreatedAt: at(2, 8)},
+		{UserID: 1, Success: true, CreatedAt: at(2, 22)},
+		{UserID: 2, Success: true, CreatedAt: at(20, 5)},
+		{UserID: 2, Success: false, CreatedAt: at(20, 6)},
+	} {
+		if err := db.Create(&attempt).Error; err != nil {
+			t.Fatalf("seed login attempt: %v", err)
+		}
+	}
+
+	// Deletion requests on Jan 8; other audit actions are ignored
+	for _, entry := range []AuditLogModel{
+		{Action: application.AuditDeletionRequested, TargetID: 1, CreatedAt: at(8, 0)},
+		{Action: application.AuditUserBanned, TargetID: 2, CreatedAt: at(8, 1)},
+	} {
+		if err := db.Create(&entry).Error; err != nil {
+			t.Fatalf("seed audit log: %v", err)
+		}
+	}
+
+	repo := NewStatsRepository(db)
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	asMap := func(counts []application.BucketCount) map[string]int64 {
+		m := make(map[string]int64)
+		for _, c := range counts {
+			m[c.Start.Format("2006-01-02")] = c.Count
+		}
+		return m
+	}
+
+	signups, err := repo.CountSignups(ctx, application.GranularityDay, from, to)
+	if err != nil {
+		t.Fatalf("count signups: %v", err)
+	}
+	if got := asMap(signups); got["2024-01-01"] != 2 || got["2024-01-15"] != 1 || got["2024-01-31"] != 1 || len(got) != 3 {
+		t.Errorf("unexpected daily signups %v", got)
+	}
+
+	logins, err := repo.CountLogins(ctx, application.GranularityDay, from, to)
+	if err != nil {
+		t.Fatalf("count logins: %v", err)
+	}
+	if got := asMap(logins); got["2024-01-02"] != 2 || got["2024-01-20"] != 1 || len(got) != 2 {
+		t.Errorf("unexpected daily logins %v", got)
+	}
+
+	deletions, err := repo.CountDeletions(ctx, application.GranularityWeek, from, to)
+	if err != nil {
+		t.Fatalf("count deletions: %v", err)
+	}
+	if got := asMap(deletions); got["2024-01-08"] != 1 || len(got) != 1 {
+		t.Errorf("unexpected weekly deletions %v", got)
+	}
+
+	weekly, err := repo.CountSignups(ctx, application.GranularityWeek, from, to)
+	if err != nil {
+		t.Fatalf("count weekly signups: %v", err)
+	}
+	if got := asMap(weekly); got["2024-01-01"] != 2 || got["2024-01-15"] != 1 || got["2024-01-29"] != 1 {
+		t.Errorf("unexpected weekly signups %v", got)
+	}
+}
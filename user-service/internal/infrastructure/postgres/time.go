@@ -0,0 +1,22 @@
+package postgres
+
+import "time"
+
+// Every timestamp is stored and loaded in UTC. The driver returns
+// timestamptz values in the session's zone, and callers may hand in local
+// times, so models normalize both ways rather than trusting either side.
+
+func utc(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.UTC()
+}
+
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	normalized := t.UTC()
+	return &normalized
+}
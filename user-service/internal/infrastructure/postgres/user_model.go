@@ -8,16 +8,32 @@ import (
 )
 
 type UserModel struct {
-	ID        uint           `gorm:"primaryKey"`
-	Username  string         `gorm:"size:100;not null" json:"username"`
-	Email     string         `gorm:"size:100;not null;uniqueIndex" json:"email"`
-	Password  string         `gorm:"not null" json:"-"` // json:"-" to never expose
-	FirstName string         `gorm:"size:100" json:"first_name,omitempty"`
-	LastName  string         `gorm:"size:100" json:"last_name,omitempty"`
-	LastLogin *time.Time     `json:"last_login,omitempty"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID       uint   `gorm:"primaryKey"`
+	Username string `gorm:"size:100;not null" json:"username"`
+	Email    string `gorm:"size:100;not null;uniqueIndex" json:"email"`
+	// Password is the bcrypt hash. ToDomain leaves it out; see
+	// UserRepository.GetCredentials.
+	Password        string     `gorm:"not null" json:"-"`
+	FirstName       string     `gorm:"size:100" json:"first_name,omitempty"`
+	LastName        string     `gorm:"size:100" json:"last_name,omitempty"`
+	Status          string     `gorm:"size:20;not null;default:active;index" json:"status"`
+	Role            string     `gorm:"size:20;not null;default:user" json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	// Indexed for the erasure job's grace period scan
+	DeletionRequestedAt *time.Time `gorm:"index" json:"deletion_requested_at,omitempty"`
+	MustResetPassword   bool       `gorm:"not null;default:false" json:"must_reset_password"`
+	TokenVersion        uint       `gorm:"not null;default:0" json:"token_version"`
+	// Notification preferences; false is the default for each column
+	MuteSecurityAlerts   bool `gorm:"not null;default:false" json:"mute_security_alerts"`
+	NotifyMarketing      bool `gorm:"not null;default:false" json:"notify_marketing"`
+	NotifyProductUpdates bool `gorm:"not null;default:false" json:"notify_product_updates"`
+	// Preferences are the display settings, checked against
+	// domain.PreferenceSchema before they get here
+	Preferences map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"preferences,omitempty"`
+	LastLogin   *time.Time             `json:"last_login,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt         `gorm:"index" json:"-"`
 }
 
 func (UserModel) TableName() string {
@@ -27,20 +43,31 @@ func (UserModel) TableName() string {
 func (m *UserModel) ToDomain() *domain.User {
 	var deletedAt gorm.DeletedAt
 	if m.DeletedAt.Valid {
-		deletedAt = m.DeletedAt
+		deletedAt = gorm.DeletedAt{Time: utc(m.DeletedAt.Time), Valid: true}
 	}
 
 	return &domain.User{
-		ID:        m.ID,
-		Username:  m.Username,
-		Email:     m.Email,
-		Password:  m.Password,
-		FirstName: m.FirstName,
-		LastName:  m.LastName,
-		LastLogin: m.LastLogin,
-		CreatedAt: m.CreatedAt,
-		UpdatedAt: m.UpdatedAt,
-		DeletedAt: deletedAt,
+		ID:                  m.ID,
+		Username:            m.Username,
+		Email:               m.Email,
+		FirstName:           m.FirstName,
+		LastName:            m.LastName,
+		Status:              domain.UserStatus(m.Status),
+		Role:                domain.Role(m.Role),
+		EmailVerifiedAt:     utcPtr(m.EmailVerifiedAt),
+		DeletionRequestedAt: utcPtr(m.DeletionRequestedAt),
+		MustResetPassword:   m.MustResetPassword,
+		TokenVersion:        m.TokenVersion,
+		Notifications: domain.NotificationPreferences{
+			SecurityAlertsMuted: m.MuteSecurityAlerts,
+			Marketing:           m.NotifyMarketing,
+			ProductUpdates:      m.NotifyProductUpdates,
+		},
+		Preferences: domain.Preferences(m.Preferences),
+		LastLogin:   utcPtr(m.LastLogin),
+		CreatedAt:   utc(m.CreatedAt),
+		UpdatedAt:   utc(m.UpdatedAt),
+		DeletedAt:   deletedAt,
 	}
 
 }
@@ -50,11 +77,29 @@ func (m *UserModel) FromDomain(user *domain.User) {
 	m.ID = user.ID
 	m.Username = user.Username
 	m.Email = user.Email
-	m.Password = user.Password
 	m.FirstName = user.FirstName
 	m.LastName = user.LastName
-	m.LastLogin = user.LastLogin
-	m.CreatedAt = user.CreatedAt
-	m.UpdatedAt = user.UpdatedAt
+	m.Status = string(user.Status)
+	if m.Status == "" {
+		m.Status = string(domain.StatusActive)
+	}
+	m.Role = string(user.Role)
+	if m.Role == "" {
+		m.Role = string(domain.RoleUser)
+	}
+	m.EmailVerifiedAt = utcPtr(user.EmailVerifiedAt)
+	m.DeletionRequestedAt = utcPtr(user.DeletionRequestedAt)
+	m.MustResetPassword = user.MustResetPassword
+	m.TokenVersion = user.TokenVersion
+	m.MuteSecurityAlerts = user.Notifications.SecurityAlertsMuted
+	m.NotifyMarketing = user.Notifications.Marketing
+	m.NotifyProductUpdates = user.Notifications.ProductUpdates
+	m.Preferences = user.Preferences
+	m.LastLogin = utcPtr(user.LastLogin)
+	m.CreatedAt = utc(user.CreatedAt)
+	m.UpdatedAt = utc(user.UpdatedAt)
 	m.DeletedAt = user.DeletedAt
+	if m.DeletedAt.Valid {
+		m.DeletedAt.Time = m.DeletedAt.Time.UTC()
+	}
 }
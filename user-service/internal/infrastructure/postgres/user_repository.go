@@ -2,16 +2,22 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 	"user-service/internal/application"
 	"user-service/internal/domain"
 
 	_ "github.com/lib/pq"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
 )
 
 var _ application.UserRepository = (*UserRepository)(nil)
+var _ application.LastLoginStore = (*UserRepository)(nil)
 
 type UserRepository struct {
 	db *gorm.DB
@@ -25,9 +31,21 @@ func (r *UserRepository) WithTx(tx *gorm.DB) application.UserRepository {
 	return &UserRepository{db: tx}
 }
 
-func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
+// reader is the session for a read, pinned to the primary when the caller
+// asked with application.WithPrimaryRead. Without replicas configured the
+// clause is a no-op.
+func (r *UserRepository) reader(ctx context.Context) *gorm.DB {
+	db := r.db.WithContext(ctx)
+	if application.PrimaryReadRequested(ctx) {
+		db = db.Clauses(dbresolver.Write)
+	}
+	return db
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *domain.User, passwordHash string) error {
 	model := &UserModel{}
 	model.FromDomain(user)
+	model.Password = passwordHash
 
 	result := r.db.WithContext(ctx).Create(model)
 	if result.Error != nil {
@@ -37,9 +55,12 @@ func (r *UserRepository) Create(ctx context.Context, user *domain.User) error {
 		return fmt.Errorf("failed to create user: %w", result.Error)
 	}
 
+	// The status and role may have been defaulted
 	user.ID = model.ID
-	user.CreatedAt = model.CreatedAt
-	user.UpdatedAt = model.UpdatedAt
+	user.Status = domain.UserStatus(model.Status)
+	user.Role = domain.Role(model.Role)
+	user.CreatedAt = utc(model.CreatedAt)
+	user.UpdatedAt = utc(model.UpdatedAt)
 
 	return nil
 }
@@ -59,9 +80,40 @@ func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.
 	return model.ToDomain(), nil
 }
 
+// GetByEmailUnscoped is GetByEmail including soft-deleted accounts, which
+// still hold their email under the unique index
+func (r *UserRepository) GetByEmailUnscoped(ctx context.Context, email string) (*domain.User, error) {
+	var model UserModel
+
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("email = ?", email).
+		First(&model).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user by email: %w", err)
+	}
+	return model.ToDomain(), nil
+}
+
 func (r *UserRepository) GetByID(ctx context.Context, id uint) (*domain.User, error) {
 	var user UserModel
-	err := r.db.WithContext(ctx).First(&user, id).Error
+	err := r.reader(ctx).First(&user, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	return user.ToDomain(), nil
+}
+
+// GetByIDUnscoped is GetByID including soft-deleted accounts
+func (r *UserRepository) GetByIDUnscoped(ctx context.Context, id uint) (*domain.User, error) {
+	var user UserModel
+	err := r.reader(ctx).Unscoped().First(&user, id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrUserNotFound
@@ -71,12 +123,49 @@ func (r *UserRepository) GetByID(ctx context.Context, id uint) (*domain.User, er
 	return user.ToDomain(), nil
 }
 
+// GetCredentials reads from the primary, so a password that was just
+// changed is the one checked
+func (r *UserRepository) GetCredentials(ctx context.Context, id uint) (*domain.Credentials, error) {
+	var model UserModel
+	err := r.db.WithContext(ctx).Select("id", "password").First(&model, id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("failed to get credentials: %w", err)
+	}
+	return &domain.Credentials{UserID: model.ID, PasswordHash: model.Password}, nil
+}
+
+func (r *UserRepository) GetByIDs(ctx context.Context, ids []uint) ([]*domain.User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var models []*UserModel
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("failed to get users by id: %w", err)
+	}
+
+	users := make([]*domain.User, len(models))
+	for i, model := range models {
+		users[i] = model.ToDomain()
+	}
+	return users, nil
+}
+
 func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 	model := &UserModel{}
 	model.FromDomain(user)
 
-	err := r.db.WithContext(ctx).Save(model)
+	// The user carries no hash, and saving its zero value would lock the
+	// account out. A stale token version would bring back logged out
+	// sessions; only UpdateFields changes it.
+	err := r.db.WithContext(ctx).Omit("password", "token_version").Save(model)
 	if err.Error != nil {
+		if IsDuplicateError(err.Error) {
+			return ErrDuplicateUser
+		}
 		return fmt.Errorf("failed to update user: %w", err.Error)
 	}
 
@@ -85,12 +174,19 @@ func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
 }
 
 func (r *UserRepository) UpdateFields(ctx context.Context, id uint, fields map[string]interface{}) error {
+	fields, err := encodeJSONColumns(fields)
+	if err != nil {
+		return fmt.Errorf("failed to update fields: %w", err)
+	}
 	result := r.db.WithContext(ctx).
 		Model(&UserModel{}).
 		Where("id = ?", id).
 		Updates(fields)
 
 	if result.Error != nil {
+		if IsDuplicateError(result.Error) {
+			return ErrDuplicateUser
+		}
 		return fmt.Errorf("failed to update fields: %w", result.Error)
 	}
 
@@ -101,6 +197,72 @@ func (r *UserRepository) UpdateFields(ctx context.Context, id uint, fields map[s
 	return nil
 }
 
+// UpdateFieldsIfStatus applies fields only while the user is still in the
+// given status, so concurrent state transitions can't overwrite each other.
+// It reports false when the row exists but has moved on.
+func (r *UserRepository) UpdateFieldsIfStatus(ctx context.Context, id uint, status domain.UserStatus, fields map[string]interface{}) (bool, error) {
+	fields, err := encodeJSONColumns(fields)
+	if err != nil {
+		return false, fmt.Errorf("failed to update fields: %w", err)
+	}
+	result := r.db.WithContext(ctx).
+		Model(&UserModel{}).
+		Where("id = ? AND status = ?", id, string(status)).
+		Updates(fields)
+
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update fields: %w", result.Error)
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// jsonColumns are the users columns stored as JSON
+var jsonColumns = []string{"preferences"}
+
+// encodeJSONColumns encodes the values of jsonColumns in fields, as
+// updates from a map bypass the columns' serializer. fields itself is
+// left alone.
+func encodeJSONColumns(fields map[string]interface{}) (map[string]interface{}, error) {
+	var encoded map[string]interface{}
+	for _, column := range jsonColumns {
+		value, ok := fields[column]
+		if !ok {
+			continue
+		}
+		if encoded == nil {
+			encoded = make(map[string]interface{}, len(fields))
+			for k, v := range fields {
+				encoded[k] = v
+			}
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("encoding %s: %w", column, err)
+		}
+		encoded[column] = string(data)
+	}
+	if encoded == nil {
+		return fields, nil
+	}
+	return encoded, nil
+}
+
+// UpdateLastLogins writes a batch of last_login values in one transaction
+func (r *UserRepository) UpdateLastLogins(ctx context.Context, logins map[uint]time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for id, at := range logins {
+			err := tx.Model(&UserModel{}).
+				Where("id = ?", id).
+				UpdateColumn("last_login", at.UTC()).Error
+			if err != nil {
+				return fmt.Errorf("failed to update last login for user %d: %w", id, err)
+			}
+		}
+		return nil
+	})
+}
+
 func (r *UserRepository) SoftDelete(ctx context.Context, id uint) error {
 	result := r.db.WithContext(ctx).Delete(&UserModel{}, id)
 
@@ -131,12 +293,13 @@ func (r *UserRepository) HardDelete(ctx context.Context, id uint) error {
 	return nil
 }
 
-// Restore - restore soft deleted record
+// Restore clears deleted_at of a soft-deleted record. Rows that aren't
+// deleted don't match, so of two concurrent restores only one succeeds.
 func (r *UserRepository) Restore(ctx context.Context, id uint) error {
 	result := r.db.WithContext(ctx).
 		Model(&UserModel{}).
 		Unscoped().
-		Where("id = ?", id).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
 		Update("deleted_at", nil)
 
 	if result.Error != nil {
@@ -150,20 +313,39 @@ func (r *UserRepository) Restore(ctx context.Context, id uint) error {
 	return nil
 }
 
-func (r *UserRepository) List(ctx context.Context, offset, limit int) ([]*domain.User, int64, error) {
+func (r *UserRepository) List(ctx context.Context, filter domain.UserFilter, offset, limit int, sort domain.UserSort) ([]*domain.User, int64, error) {
+	return r.page(r.db.WithContext(ctx), filter, offset, limit, sort)
+}
+
+// ListWithDeleted lists Unscoped, so the soft-deleted rows gorm otherwise
+// leaves out are there too
+func (r *UserRepository) ListWithDeleted(ctx context.Context, filter domain.UserFilter, offset, limit int, sort domain.UserSort) ([]*domain.User, int64, error) {
+	return r.page(r.db.WithContext(ctx).Unscoped(), filter, offset, limit, sort)
+}
+
+// page returns the users filter keeps, in sort order, from offset, and how
+// many it keeps in all
+func (r *UserRepository) page(db *gorm.DB, filter domain.UserFilter, offset, limit int, sort domain.UserSort) ([]*domain.User, int64, error) {
+	order, err := userOrder(sort)
+	if err != nil {
+		return nil, 0, err
+	}
 	var models []*UserModel
 	var total int64
 
-	// Count total
-	if err := r.db.Model(&UserModel{}).Count(&total).Error; err != nil {
+	// Count total, under the same filter as the page
+	if err := db.Model(&UserModel{}).
+		Scopes(withFilter(filter)).
+		Count(&total).Error; err != nil {
 		return nil, 0, fmt.Errorf("failed to count user: %w", err)
 	}
 
 	// Get paginated date
-	err := r.db.WithContext(ctx).
+	err = db.
+		Scopes(withFilter(filter)).
 		Offset(offset).
 		Limit(limit).
-		Order("created_at DESC").
+		Order(order).
 		Find(&models).Error
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to list users: %w", err)
@@ -176,6 +358,206 @@ func (r *UserRepository) List(ctx context.Context, offset, limit int) ([]*domain
 	return users, total, nil
 }
 
+// ListPendingDeletion returns accounts awaiting erasure whose request was made
+// at or before requestedBefore, oldest first
+func (r *UserRepository) ListPendingDeletion(ctx context.Context, requestedBefore time.Time, limit int) ([]*domain.User, error) {
+	var models []*UserModel
+
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND deletion_requested_at <= ?", string(domain.StatusPendingDeletion), requestedBefore.UTC()).
+		Order("deletion_requested_at ASC").
+		Limit(limit).
+		Find(&models).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending deletions: %w", err)
+	}
+
+	users := make([]*domain.User, len(models))
+	for i, model := range models {
+		users[i] = model.ToDomain()
+	}
+	return users, nil
+}
+
+func (r *UserRepository) PagePendingDeletion(ctx context.Context, offset, limit int) ([]*domain.User, int64, error) {
+	db := r.db.WithContext(ctx).Where("status = ?", string(domain.StatusPendingDeletion))
+
+	var total int64
+	if err := db.Model(&UserModel{}).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count pending deletions: %w", err)
+	}
+
+	var models []*UserModel
+	err := db.
+		Order("deletion_requested_at ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&models).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list pending deletions: %w", err)
+	}
+
+	users := make([]*domain.User, len(models))
+	for i, model := range models {
+		users[i] = model.ToDomain()
+	}
+	return users, total, nil
+}
+
+// SearchByUsername pages through users by username prefix using the ID as
+// a keyset cursor, so deep pages cost the same as the first
+func (r *UserRepository) SearchByUsername(ctx context.Context, prefix string, afterID uint, limit int, statuses []domain.UserStatus) ([]*domain.User, error) {
+	var models []*UserModel
+
+	pattern := likeEscaper.Replace(strings.ToLower(prefix)) + "%"
+	err := r.db.WithContext(ctx).
+		Scopes(withStatuses(statuses)).
+		Where("LOWER(username) LIKE ? ESCAPE '\\' AND id > ? AND status <> ?",
+			pattern, afterID, string(domain.StatusErased)).
+		Order("id ASC").
+		Limit(limit).
+		Find(&models).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	users := make([]*domain.User, len(models))
+	for i, model := range models {
+		users[i] = model.ToDomain()
+	}
+	return users, nil
+}
+
+// userSortColumns and sortDirections are all the ORDER BY a listing can
+// produce. Sort specifications are only ever looked up in them, so nothing
+// a caller passes reaches the SQL.
+var (
+	userSortColumns = map[domain.UserSortField]string{
+		domain.SortByCreatedAt: "created_at",
+		domain.SortByUsername:  "username",
+		domain.SortByEmail:     "email",
+		domain.SortByLastLogin: "last_login",
+	}
+	sortDirections = map[domain.SortOrder]string{
+		domain.SortAscending:  "ASC",
+		domain.SortDescending: "DESC",
+	}
+)
+
+// userOrder is the ORDER BY clause for sort. Ties go by ID in the same
+// direction so pages never overlap, and users who never logged in come
+// last either way.
+func userOrder(sort domain.UserSort) (string, error) {
+	column, ok := userSortColumns[sort.Field]
+	if !ok {
+		return "", fmt.Errorf("invalid sort field %q", sort.Field)
+	}
+	direction, ok := sortDirections[sort.Order]
+	if !ok {
+		return "", fmt.Errorf("invalid sort order %q", sort.Order)
+	}
+	return fmt.Sprintf("%s %s NULLS LAST, id %s", column, direction, direction), nil
+}
+
+// withFilter limits a query to the users filter keeps. Query matches with
+// ILIKE, which the trigram indexes Migrate creates serve even for matches
+// in the middle of a value.
+func withFilter(filter domain.UserFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Scopes(withStatuses(filter.Statuses))
+		if filter.Query != "" {
+			pattern := "%" + likeEscaper.Replace(filter.Query) + "%"
+			db = db.Where("(username ILIKE ? ESCAPE '\\' OR email ILIKE ? ESCAPE '\\')", pattern, pattern)
+		}
+		if !filter.CreatedAfter.IsZero() {
+			db = db.Where("created_at >= ?", filter.CreatedAfter.UTC())
+		}
+		if !filter.CreatedBefore.IsZero() {
+			db = db.Where("created_at < ?", filter.CreatedBefore.UTC())
+		}
+		return db
+	}
+}
+
+// withStatuses limits a query to the given account states; none means all
+func withStatuses(statuses []domain.UserStatus) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(statuses) == 0 {
+			return db
+		}
+		values := make([]string, len(statuses))
+		for i, s := range statuses {
+			values[i] = string(s)
+		}
+		return db.Where("status IN ?", values)
+	}
+}
+
+// LockConflicts takes a transaction-scoped advisory lock per value before
+// looking for other holders FOR UPDATE: row locks alone can't stop two
+// transactions from claiming a value no row holds yet. Emails are checked
+// against soft-deleted rows too, like the unique index. The tests' SQLite
+// stand-in has no advisory locks, and serializes writers anyway.
+func (r *UserRepository) LockConflicts(ctx context.Context, id uint, username, email string) ([]string, error) {
+	db := r.db.WithContext(ctx)
+	checks := []struct {
+		field, value, lockKey, where string
+		unscoped                     bool
+	}{
+		{"username", username, "users.username:" + strings.ToLower(username), "LOWER(username) = LOWER(?)", false},
+		{"email", email, "users.email:" + email, "email = ?", true},
+	}
+
+	var taken []string
+	for _, c := range checks {
+		if c.value == "" {
+			continue
+		}
+		if db.Dialector.Name() == "postgres" {
+			if err := db.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", c.lockKey).Error; err != nil {
+				return nil, fmt.Errorf("failed to lock %s: %w", c.field, err)
+			}
+		}
+
+		query := db
+		if c.unscoped {
+			query = query.Unscoped()
+		}
+		var ids []uint
+		err := query.Model(&UserModel{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(c.where, c.value).
+			Where("id <> ?", id).
+			Limit(1).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %s: %w", c.field, err)
+		}
+		if len(ids) > 0 {
+			taken = append(taken, c.field)
+		}
+	}
+	return taken, nil
+}
+
+// likeEscaper makes LIKE wildcards in user input match literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Exists runs a LIMIT 1 probe instead of loading the row. Soft-deleted
+// rows are excluded by the default scope.
+func (r *UserRepository) Exists(ctx context.Context, id uint) (bool, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).
+		Model(&UserModel{}).
+		Where("id = ? AND status <> ?", id, string(domain.StatusErased)).
+		Limit(1).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check user exists: %w", err)
+	}
+	return len(ids) > 0, nil
+}
+
 func (r *UserRepository) ExistsEmail(ctx context.Context, email string) (bool, error) {
 	var count int64
 	err := r.db.WithContext(ctx).
@@ -0,0 +1,669 @@
+// internal/infrastructure/postgres/user_repository_test.go
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/testsupport/pgtest"
+
+	"gorm.io/gorm"
+)
+
+// openTestDB returns an empty, migrated database; see pgtest for how it is
+// provisioned and when tests are skipped
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return pgtest.DB(t, Migrate)
+}
+
+func seedUser(t *testing.T, repo *UserRepository, name string) *domain.User {
+	t.Helper()
+	user := &domain.User{
+		Username:  name,
+		Email:     name + "@example.com",
+		FirstName: "First",
+		LastName:  "Last",
+	}
+	if err := repo.Create(context.Background(), user, "hash"); err != nil {
+		t.Fatalf("seed %s: %v", name, err)
+	}
+	return user
+}
+
+func TestUserRepository_CreateAndGet(t *testing.T) {
+	repo := NewUserRepository(openTestDB(t))
+	ctx := context.Background()
+
+	user := seedUser(t, repo, "alice")
+	if user.ID == 0 || user.CreatedAt.IsZero() || user.UpdatedAt.IsZero() || user.Status != domain.StatusActive || user.Role != domain.RoleUser {
+		t.Fatalf("expected generated fields to be copied back, got %+v", user)
+	}
+
+	byID, err := repo.GetByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("get by id: %v", err)
+	}
+	if byID.Email != "alice@example.com" || byID.Status != domain.StatusActive || byID.Role != domain.RoleUser {
+		t.Errorf("unexpected user %+v", byID)
+	}
+
+	byEmail, err := repo.GetByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatalf("get by email: %v", err)
+	}
+	if byEmail.ID != user.ID {
+		t.Errorf("expected id %d, got %d", user.ID, byEmail.ID)
+	}
+
+	if _, err := repo.GetByID(ctx, user.ID+100); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+	if _, err := repo.GetByEmail(ctx, "nobody@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestUserRepository_CreateDuplicateEmail(t *testing.T) {
+	repo := NewUserRepository(openTestDB(t))
+	seedUser(t, repo, "alice")
+
+	dup := &domain.User{Username: "other", Email: "alice@example.com"}
+	err := repo.Create(context.Background(), dup, "hash")
+	if !errors.Is(err, ErrDuplicateUser) {
+		t.Fatalf("expected ErrDuplicateUser, got %v", err)
+	}
+}
+
+func TestUserRepository_UpdateKeepsUntouchedColumns(t *testing.T) {
+	repo := NewUserRepository(openTestDB(t))
+	ctx := context.Background()
+	seeded := seedUser(t, repo, "alice")
+
+	user, err := repo.GetByID(ctx, seeded.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	user.FirstName = "Alicia"
+	if err := repo.Update(ctx, user); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	got, err := repo.GetByID(ctx, seeded.ID)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got.FirstName != "Alicia" {
+		t.Errorf("expected first name to change, got %q", got.FirstName)
+	}
+	if got.LastName != "Last" || got.Email != "alice@example.com" {
+		t.Errorf("update clobbered other columns: %+v", got)
+	}
+	// The user carries no hash, and the update must not blank it
+	if credentials, err := repo.GetCredentials(ctx, seeded.ID); err != nil || credentials.PasswordHash != "hash" {
+		t.Errorf("update clobbered the password: %+v (%v)", credentials, err)
+	}
+	if !got.CreatedAt.Equal(seeded.CreatedAt) {
+		t.Errorf("created_at changed from %v to %v", seeded.CreatedAt, got.CreatedAt)
+	}
+}
+
+func TestUserRepository_UpdateFields(t *testing.T) {
+	repo := NewUserRepository(openTestDB(t))
+	ctx := context.Background()
+	user := seedUser(t, repo, "alice")
+
+	if err := repo.UpdateFields(ctx, user.ID, map[string]interface{}{"first_name": "Alicia"}); err != nil {
+		t.Fatalf("update fields: %v", err)
+	}
+	got, _ := repo.GetByID(ctx, user.ID)
+	if got.FirstName != "Alicia" || got.LastName != "Last" {
+		t.Errorf("unexpected user after update %+v", got)
+	}
+
+	err := repo.UpdateFields(ctx, user.ID+100, map[string]interface{}{"first_name": "x"})
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound for missing row, got %v", err)
+	}
+
+	if err := repo.SoftDelete(ctx, user.ID); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	err = repo.UpdateFields(ctx, user.ID, map[string]interface{}{"first_name": "x"})
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound for soft deleted row, got %v", err)
+	}
+}
+
+func TestUserRepository_Preferences(t *testing.T) {
+	repo := NewUserRepository(openTestDB(t))
+	ctx := context.Background()
+	user := seedUser(t, repo, "alice")
+
+	prefs := domain.Preferences{"theme": "dark", "accessibility": map[string]interface{}{"high_contrast": true}}
+	if err := repo.UpdateFields(ctx, user.ID, map[string]interface{}{"preferences": prefs}); err != nil {
+		t.Fatalf("update fields: %v", err)
+	}
+	got, _ := repo.GetByID(ctx, user.ID)
+	if !reflect.DeepEqual(got.Preferences, prefs) {
+		t.Errorf("expected %v back, got %v", prefs, got.Preferences)
+	}
+
+	// Saving the whole user keeps them
+	got.FirstName = "Alicia"
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, _ = repo.GetByID(ctx, user.ID)
+	if !reflect.DeepEqual(got.Preferences, prefs) {
+		t.Errorf("expected the preferences kept, got %v", got.Preferences)
+	}
+}
+
+func TestUserRepository_UpdateFieldsIfStatus(t *testing.T) {
+	repo := NewUserRepository(openTestDB(t))
+	ctx := context.Background()
+	user := seedUser(t, repo, "alice")
+
+	ok, err := repo.UpdateFieldsIfStatus(ctx, user.ID, domain.StatusBanned,
+		map[string]interface{}{"status": string(domain.StatusActive)})
+	if err != nil || ok {
+		t.Fatalf("expected no update on status mismatch, got ok=%v err=%v", ok, err)
+	}
+
+	ok, err = repo.UpdateFieldsIfStatus(ctx, user.ID, domain.StatusActive,
+		map[string]interface{}{"status": string(domain.StatusBanned)})
+	if err != nil || !ok {
+		t.Fatalf("expected update on status match, got ok=%v err=%v", ok, err)
+	}
+	got, _ := repo.GetByID(ctx, user.ID)
+	if got.Status != domain.StatusBanned {
+		t.Errorf("expected banned, got %s", got.Status)
+	}
+}
+
+func TestUserRepository_UpdateLastLogins(t *testing.T) {
+	repo := NewUserRepository(openTestDB(t))
+	ctx := context.Background()
+	alice := seedUser(t, repo, "alice")
+	bob := seedUser(t, repo, "bob")
+
+	at := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	err := repo.UpdateLastLogins(ctx, map[uint]time.Time{alice.ID: at, bob.ID: at.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("update last logins: %v", err)
+	}
+
+	got, _ := repo.GetByID(ctx, bob.ID)
+	if got.LastLogin == nil || !got.LastLogin.Equal(at.Add(time.Hour)) {
+		t.Errorf("unexpected last login %v", got.LastLogin)
+	}
+}
+
+func TestUserRepository_SoftDeleteRestoreHardDelete(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+	user := seedUser(t, repo, "alice")
+
+	if err := repo.SoftDelete(ctx, user.ID); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("soft deleted user should be hidden, got %v", err)
+	}
+	if exists, _ := repo.ExistsEmail(ctx, user.Email); exists {
+		t.Error("soft deleted email should not be reported as existing")
+	}
+	if found, err := repo.GetByEmailUnscoped(ctx, user.Email); err != nil || !found.IsDeleted() {
+		t.Errorf("expected the soft deleted user found unscoped, got %+v (%v)", found, err)
+	}
+	if found, err := repo.GetByIDUnscoped(ctx, user.ID); err != nil || !found.IsDeleted() {
+		t.Errorf("expected the soft deleted user found by ID unscoped, got %+v (%v)", found, err)
+	}
+	if err := repo.SoftDelete(ctx, user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("second soft delete should report not found, got %v", err)
+	}
+
+	// The row is still there for unscoped queries
+	var model UserModel
+	if err := db.Unscoped().First(&model, user.ID).Error; err != nil {
+		t.Fatalf("unscoped lookup: %v", err)
+	}
+	if !model.DeletedAt.Valid {
+		t.Error("expected deleted_at to be set")
+	}
+
+	if err := repo.Restore(ctx, user.ID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, user.ID); err != nil {
+		t.Errorf("restored user should be visible, got %v", err)
+	}
+	if err := repo.Restore(ctx, user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("restoring a live user should report not found, got %v", err)
+	}
+
+	if err := repo.HardDelete(ctx, user.ID); err != nil {
+		t.Fatalf("hard delete: %v", err)
+	}
+	var count int64
+	db.Unscoped().Model(&UserModel{}).Where("id = ?", user.ID).Count(&count)
+	if count != 0 {
+		t.Error("hard delete should remove the row")
+	}
+	if err := repo.HardDelete(ctx, user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+	if err := repo.Restore(ctx, user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestUserRepository_ListPagination(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	base := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		user := seedUser(t, repo, fmt.Sprintf("user%d", i))
+		db.Model(&UserModel{}).Where("id = ?", user.ID).
+			UpdateColumn("created_at", base.Add(time.Duration(i)*time.Hour))
+	}
+	if err := repo.SoftDelete(ctx, 1); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+
+	page, total, err := repo.List(ctx, domain.UserFilter{}, 0, 2, domain.DefaultUserSort)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if total != 4 {
+		t.Errorf("expected total 4 excluding soft deleted, got %d", total)
+	}
+	if len(page) != 2 || page[0].Username != "user4" || page[1].Username != "user3" {
+		t.Errorf("expected newest first, got %v", usernames(page))
+	}
+
+	last, _, err := repo.List(ctx, domain.UserFilter{}, 2, 2, domain.DefaultUserSort)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(last) != 2 || last[0].Username != "user2" || last[1].Username != "user1" {
+		t.Errorf("unexpected second page %v", usernames(last))
+	}
+}
+
+func TestUserRepository_ListFiltersByStatus(t *testing.T) {
+	repo := NewUserRepository(openTestDB(t))
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name   string
+		status domain.UserStatus
+	}{
+		{"active1", domain.StatusActive},
+		{"active2", domain.StatusActive},
+		{"banned", domain.StatusBanned},
+		{"pending", domain.StatusPendingDeletion},
+	} {
+		user := seedUser(t, repo, tc.name)
+		if err := repo.UpdateFields(ctx, user.ID, map[string]interface{}{"status": string(tc.status)}); err != nil {
+			t.Fatalf("set status: %v", err)
+		}
+	}
+
+	page, total, err := repo.List(ctx, domain.UserFilter{Statuses: []domain.UserStatus{domain.StatusActive}}, 0, 1, domain.DefaultUserSort)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if total != 2 || len(page) != 1 || page[0].Status != domain.StatusActive {
+		t.Errorf("expected 1 of 2 active users, got %v of %d", usernames(page), total)
+	}
+
+	page, total, _ = repo.List(ctx, domain.UserFilter{Statuses: []domain.UserStatus{domain.StatusBanned, domain.StatusPendingDeletion}}, 0, 10, domain.DefaultUserSort)
+	if total != 2 || len(page) != 2 {
+		t.Errorf("expected banned and pending, got %v (total %d)", usernames(page), total)
+	}
+
+	matches, _ := repo.SearchByUsername(ctx, "", 0, 10, []domain.UserStatus{domain.StatusActive})
+	if got := usernames(matches); fmt.Sprint(got) != "[active1 active2]" {
+		t.Errorf("expected search to respect the filter, got %v", got)
+	}
+}
+
+func TestUserRepository_Search(t *testing.T) {
+	repo := NewUserRepository(openTestDB(t))
+	ctx := context.Background()
+
+	for _, name := range []string{"alice", "malice", "bob", "ali_ce"} {
+		seedUser(t, repo, name)
+	}
+	banned := seedUser(t, repo, "Alison")
+	repo.UpdateFields(ctx, banned.ID, map[string]interface{}{"status": string(domain.StatusBanned)})
+
+	// Matches anywhere in the username or email, in any case
+	page, total, err := repo.List(ctx, domain.UserFilter{Query: "ALI"}, 0, 2, domain.DefaultUserSort)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if total != 4 || len(page) != 2 {
+		t.Errorf("expected 2 of 4 matches, got %v of %d", usernames(page), total)
+	}
+	if _, total, _ := repo.List(ctx, domain.UserFilter{Query: "@example"}, 0, 10, domain.DefaultUserSort); total != 5 {
+		t.Errorf("expected every email to match, got %d", total)
+	}
+	// Wildcards in the query match literally
+	if page, total, _ := repo.List(ctx, domain.UserFilter{Query: "i_c"}, 0, 10, domain.DefaultUserSort); total != 1 || page[0].Username != "ali_ce" {
+		t.Errorf("expected only ali_ce, got %v", usernames(page))
+	}
+	if _, total, _ := repo.List(ctx, domain.UserFilter{Statuses: []domain.UserStatus{domain.StatusActive}, Query: "ali"}, 0, 10, domain.DefaultUserSort); total != 3 {
+		t.Errorf("expected the status filter applied, got %d", total)
+	}
+}
+
+func TestUserRepository_ListSorts(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	// Created carol, alice, bob; only carol and bob have logged in, bob last
+	base := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"carol", "alice", "bob"} {
+		user := seedUser(t, repo, name)
+		db.Model(&UserModel{}).Where("id = ?", user.ID).
+			UpdateColumn("created_at", base.Add(time.Duration(i)*time.Hour))
+		if name != "alice" {
+			db.Model(&UserModel{}).Where("id = ?", user.ID).
+				UpdateColumn("last_login", base.Add(time.Duration(10+i)*time.Hour))
+		}
+	}
+
+	tests := []struct {
+		sort domain.UserSort
+		want string
+	}{
+		{domain.UserSort{Field: domain.SortByUsername, Order: domain.SortAscending}, "[alice bob carol]"},
+		{domain.UserSort{Field: domain.SortByUsername, Order: domain.SortDescending}, "[carol bob alice]"},
+		{domain.UserSort{Field: domain.SortByCreatedAt, Order: domain.SortAscending}, "[carol alice bob]"},
+		{domain.UserSort{Field: domain.SortByCreatedAt, Order: domain.SortDescending}, "[bob alice carol]"},
+		// Never having logged in sorts last both ways
+		{domain.UserSort{Field: domain.SortByLastLogin, Order: domain.SortAscending}, "[carol bob alice]"},
+		{domain.UserSort{Field: domain.SortByLastLogin, Order: domain.SortDescending}, "[bob carol alice]"},
+	}
+	for _, tt := range tests {
+		page, _, err := repo.List(ctx, domain.UserFilter{}, 0, 10, tt.sort)
+		if err != nil {
+			t.Fatalf("list by %v: %v", tt.sort, err)
+		}
+		if got := fmt.Sprint(usernames(page)); got != tt.want {
+			t.Errorf("by %v: expected %s, got %s", tt.sort, tt.want, got)
+		}
+	}
+
+	if _, _, err := repo.List(ctx, domain.UserFilter{}, 0, 10, domain.UserSort{Field: "password_hash", Order: domain.SortAscending}); err == nil {
+		t.Error("expected an unknown sort field refused")
+	}
+}
+
+func TestUserRepository_ListFiltersByCreatedAt(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	week := time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC)
+	for name, created := range map[string]time.Time{
+		"before": week.Add(-time.Microsecond),
+		"first":  week,
+		"middle": week.Add(3 * 24 * time.Hour),
+		"gone":   week.Add(4 * 24 * time.Hour),
+		"next":   week.Add(7 * 24 * time.Hour),
+	} {
+		user := seedUser(t, repo, name)
+		db.Model(&UserModel{}).Where("id = ?", user.ID).UpdateColumn("created_at", created)
+		if name == "gone" {
+			if err := repo.SoftDelete(ctx, user.ID); err != nil {
+				t.Fatalf("soft delete: %v", err)
+			}
+		}
+	}
+	byAge := domain.UserSort{Field: domain.SortByCreatedAt, Order: domain.SortAscending}
+
+	// From the week's first instant up to, not including, the next week's
+	lastWeek := domain.UserFilter{CreatedAfter: week, CreatedBefore: week.Add(7 * 24 * time.Hour)}
+	page, total, err := repo.List(ctx, lastWeek, 0, 10, byAge)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if got := fmt.Sprint(usernames(page)); total != 2 || got != "[first middle]" {
+		t.Errorf("expected first and middle, got %s of %d", got, total)
+	}
+
+	page, total, err = repo.ListWithDeleted(ctx, lastWeek, 0, 10, byAge)
+	if err != nil {
+		t.Fatalf("list with deleted: %v", err)
+	}
+	if got := fmt.Sprint(usernames(page)); total != 3 || got != "[first middle gone]" {
+		t.Errorf("expected the soft-deleted user included, got %s of %d", got, total)
+	}
+
+	// The range composes with search
+	searched := domain.UserFilter{Query: "ext", CreatedAfter: week.Add(7 * 24 * time.Hour)}
+	if page, total, _ := repo.List(ctx, searched, 0, 10, byAge); total != 1 || page[0].Username != "next" {
+		t.Errorf("expected only next, got %v", usernames(page))
+	}
+	if _, total, _ := repo.List(ctx, domain.UserFilter{CreatedBefore: week}, 0, 10, byAge); total != 1 {
+		t.Errorf("expected only the user created before the week, got %d", total)
+	}
+}
+
+func TestUserOrder(t *testing.T) {
+	tests := []struct {
+		sort    domain.UserSort
+		want    string
+		wantErr bool
+	}{
+		{domain.DefaultUserSort, "created_at DESC NULLS LAST, id DESC", false},
+		{domain.UserSort{Field: domain.SortByEmail, Order: domain.SortAscending}, "email ASC NULLS LAST, id ASC", false},
+		{domain.UserSort{Field: domain.SortByLastLogin, Order: domain.SortDescending}, "last_login DESC NULLS LAST, id DESC", false},
+		{domain.UserSort{Field: "id; DROP TABLE users", Order: domain.SortAscending}, "", true},
+		{domain.UserSort{Field: domain.SortByEmail, Order: "ASC, password_hash"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := userOrder(tt.sort)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("userOrder(%v) = %q, %v; want %q, error %v", tt.sort, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestUserRepository_LockConflicts(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewUserRepository(db)
+	ctx := context.Background()
+
+	alice := seedUser(t, repo, "alice")
+	seedUser(t, repo, "Bob")
+	gone := seedUser(t, repo, "carol")
+	if err := repo.SoftDelete(ctx, gone.ID); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		txRepo := NewUserRepository(tx)
+
+		taken, err := txRepo.LockConflicts(ctx, alice.ID, "bob", "bob@example.com")
+		if err != nil {
+			return err
+		}
+		if fmt.Sprint(taken) != "[username email]" {
+			t.Errorf("expected both fields taken, got %v", taken)
+		}
+
+		// A soft-deleted account frees its username but, like the unique
+		// index, not its email
+		taken, _ = txRepo.LockConflicts(ctx, alice.ID, "carol", "carol@example.com")
+		if fmt.Sprint(taken) != "[email]" {
+			t.Errorf("expected only the email taken, got %v", taken)
+		}
+
+		taken, _ = txRepo.LockConflicts(ctx, alice.ID, "alice", "alice@example.com")
+		if len(taken) != 0 {
+			t.Errorf("an account never conflicts with itself, got %v", taken)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+
+	// The unique index backs the check up
+	alice.Email = "bob@example.com"
+	if err := repo.Update(ctx, alice); !errors.Is(err, ErrDuplicateUser) {
+		t.Errorf("expected ErrDuplicateUser, got %v", err)
+	}
+}
+
+func TestUserRepository_ListPendingDeletion(t *testing.T) {
+	repo := NewUserRepository(openTestDB(t))
+	ctx := context.Background()
+
+	cutoff := time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name      string
+		requested time.Time
+	}{
+		{"late", cutoff.Add(-time.Hour)},
+		{"early", cutoff.Add(-48 * time.Hour)},
+		{"future", cutoff.Add(time.Hour)},
+	} {
+		user := seedUser(t, repo, tc.name)
+		err := repo.UpdateFields(ctx, user.ID, map[string]interface{}{
+			"status":                string(domain.StatusPendingDeletion),
+			"deletion_requested_at": tc.requested,
+		})
+		if err != nil {
+			t.Fatalf("mark pending: %v", err)
+		}
+	}
+	seedUser(t, repo, "active")
+
+	due, err := repo.ListPendingDeletion(ctx, cutoff, 10)
+	if err != nil {
+		t.Fatalf("list pending: %v", err)
+	}
+	if got := usernames(due); len(got) != 2 || got[0] != "early" || got[1] != "late" {
+		t.Errorf("expected [early late], got %v", got)
+	}
+
+	limited, _ := repo.ListPendingDeletion(ctx, cutoff, 1)
+	if len(limited) != 1 {
+		t.Errorf("expected limit to apply, got %d", len(limited))
+	}
+}
+
+func TestUserRepository_GetByIDsAndSearchByUsername(t *testing.T) {
+	repo := NewUserRepository(openTestDB(t))
+	ctx := context.Background()
+
+	alice := seedUser(t, repo, "Alice")
+	literal := seedUser(t, repo, "al_x")
+	wildcard := seedUser(t, repo, "alzx")
+	erased := seedUser(t, repo, "alice-erased")
+	if err := repo.UpdateFields(ctx, erased.ID, map[string]interface{}{"status": string(domain.StatusErased)}); err != nil {
+		t.Fatalf("erase: %v", err)
+	}
+	seedUser(t, repo, "bob")
+
+	found, err := repo.GetByIDs(ctx, []uint{alice.ID, wildcard.ID, 999})
+	if err != nil {
+		t.Fatalf("get by ids: %v", err)
+	}
+	if len(found) != 2 {
+		t.Errorf("expected the 2 existing users, got %v", usernames(found))
+	}
+
+	matches, err := repo.SearchByUsername(ctx, "AL", 0, 10, nil)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if got := usernames(matches); fmt.Sprint(got) != "[Alice al_x alzx]" {
+		t.Errorf("expected case-insensitive prefix matches in ID order without erased users, got %v", got)
+	}
+
+	// LIKE wildcards in the query match literally
+	matches, _ = repo.SearchByUsername(ctx, "al_", 0, 10, nil)
+	if got := usernames(matches); len(got) != 1 || got[0] != literal.Username {
+		t.Errorf("expected only al_x, got %v", got)
+	}
+
+	page, _ := repo.SearchByUsername(ctx, "al", alice.ID, 1, nil)
+	if got := usernames(page); len(got) != 1 || got[0] != literal.Username {
+		t.Errorf("expected the page after alice to start at al_x, got %v", got)
+	}
+}
+
+func TestTransactionManager_RollsBackOnError(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewUserRepository(db)
+	tm := NewTransactionManager(db)
+	ctx := context.Background()
+
+	boom := errors.New("boom")
+	err := tm.ExecuteInTx(ctx, func(tx *gorm.DB) error {
+		txRepo := repo.WithTx(tx)
+		if err := txRepo.Create(ctx, &domain.User{Username: "alice", Email: "alice@example.com"}, "hash"); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+	if exists, _ := repo.ExistsEmail(ctx, "alice@example.com"); exists {
+		t.Error("insert should have been rolled back")
+	}
+
+	err = tm.ExecuteInTx(ctx, func(tx *gorm.DB) error {
+		return repo.WithTx(tx).Create(ctx, &domain.User{Username: "bob", Email: "bob@example.com"}, "hash")
+	})
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if exists, _ := repo.ExistsEmail(ctx, "bob@example.com"); !exists {
+		t.Error("insert should have been committed")
+	}
+}
+
+func TestTransactionManager_RollsBackOnPanic(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewUserRepository(db)
+	tm := NewTransactionManager(db)
+	ctx := context.Background()
+
+	func() {
+		defer func() { _ = recover() }()
+		_ = tm.ExecuteInTx(ctx, func(tx *gorm.DB) error {
+			_ = repo.WithTx(tx).Create(ctx, &domain.User{Username: "alice", Email: "alice@example.com"}, "hash")
+			panic("boom")
+		})
+	}()
+
+	if exists, _ := repo.ExistsEmail(ctx, "alice@example.com"); exists {
+		t.Error("insert should have been rolled back after panic")
+	}
+}
+
+func usernames(users []*domain.User) []string {
+	names := make([]string, len(users))
+	for i, u := range users {
+		names[i] = u.Username
+	}
+	return names
+}
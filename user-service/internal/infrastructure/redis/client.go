@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -33,6 +34,13 @@ func NewRedisClient(addr, password string, db int) (*RedisClient, error) {
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
+	// Commands become spans beneath the request's. Their arguments stay
+	// out: keys and values hold emails and tokens.
+	if err := redisotel.InstrumentTracing(client, redisotel.WithDBStatement(false)); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to set up Redis tracing: %w", err)
+	}
+
 	return &RedisClient{client: client}, nil
 }
 
@@ -55,14 +63,105 @@ func (r *RedisClient) Get(ctx context.Context, key string, dest interface{}) err
 	return json.Unmarshal([]byte(val), dest)
 }
 
+// MGet fetches keys in one round trip. Each present key's JSON value is
+// passed to decode with its index in keys; absent keys are skipped.
+func (r *RedisClient) MGet(ctx context.Context, keys []string, decode func(i int, data []byte) error) error {
+	vals, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return err
+	}
+	for i, val := range vals {
+		s, ok := val.(string)
+		if !ok {
+			continue
+		}
+		if err := decode(i, []byte(s)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *RedisClient) Delete(ctx context.Context, keys ...string) error {
 	return r.client.Del(ctx, keys...).Err()
 }
 
+// DeleteMatching deletes every key matching pattern. It walks the keyspace
+// with SCAN rather than KEYS, so a large keyspace doesn't block the server.
+func (r *RedisClient) DeleteMatching(ctx context.Context, pattern string) error {
+	iter := r.client.Scan(ctx, 0, pattern, 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
 func (r *RedisClient) Exists(ctx context.Context, keys ...string) (int64, error) {
 	return r.client.Exists(ctx, keys...).Result()
 }
 
+// Set operations
+func (r *RedisClient) SAdd(ctx context.Context, key string, members ...interface{}) error {
+	return r.client.SAdd(ctx, key, members...).Err()
+}
+
+func (r *RedisClient) SRem(ctx context.Context, key string, members ...interface{}) error {
+	return r.client.SRem(ctx, key, members...).Err()
+}
+
+func (r *RedisClient) SIsMember(ctx context.Context, key string, member interface{}) (bool, error) {
+	return r.client.SIsMember(ctx, key, member).Result()
+}
+
+// Hash operations. HSet JSON-encodes the value like Set; HGetAll returns the
+// raw field values for the caller to decode.
+func (r *RedisClient) HSet(ctx context.Context, key, field string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	return r.client.HSet(ctx, key, field, data).Err()
+}
+
+func (r *RedisClient) HDel(ctx context.Context, key string, fields ...string) error {
+	return r.client.HDel(ctx, key, fields...).Err()
+}
+
+func (r *RedisClient) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return r.client.HGetAll(ctx, key).Result()
+}
+
+// Locking primitives. Values are stored raw so they can be compared in Lua.
+func (r *RedisClient) SetNX(ctx context.Context, key, value string, expiration time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, expiration).Result()
+}
+
+// compareAndDelete deletes key only if it still holds the expected value
+var compareAndDelete = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// DeleteIfValue removes key if its value is still value, reporting whether
+// it did
+func (r *RedisClient) DeleteIfValue(ctx context.Context, key, value string) (bool, error) {
+	n, err := compareAndDelete.Run(ctx, r.client, []string{key}, value).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
 // For rate limiting
 func (r *RedisClient) Incr(ctx context.Context, key string) (int64, error) {
 	return r.client.Incr(ctx, key).Result()
@@ -76,6 +175,32 @@ func (r *RedisClient) TTL(ctx context.Context, key string) (time.Duration, error
 	return r.client.TTL(ctx, key).Result()
 }
 
+// Script is a Lua script for Eval. After its first run it's sent by its
+// SHA rather than in full, so declare scripts once, as package variables.
+type Script struct {
+	script *redis.Script
+}
+
+func NewScript(src string) *Script {
+	return &Script{script: redis.NewScript(src)}
+}
+
+// Eval runs script atomically with keys and args and returns its result:
+// an int64 for a Lua number, a string, or a []interface{} for a table
+func (r *RedisClient) Eval(ctx context.Context, script *Script, keys []string, args ...interface{}) (interface{}, error) {
+	return script.script.Run(ctx, r.client, keys, args...).Result()
+}
+
+// Publish sends a JSON-encoded message to a pub/sub channel
+func (r *RedisClient) Publish(ctx context.Context, channel string, message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	return r.client.Publish(ctx, channel, data).Err()
+}
+
 // Pipeline for atomic operations
 func (r *RedisClient) Pipeline() redis.Pipeliner {
 	return r.client.Pipeline()
@@ -0,0 +1,57 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"user-service/internal/application"
+)
+
+var _ application.DeviceStore = (*DeviceStore)(nil)
+
+// DefaultKnownDeviceTTL is how long a device stays known without a login
+// from it
+const DefaultKnownDeviceTTL = 90 * 24 * time.Hour
+
+// DeviceStore keeps one key per user and device fingerprint. Each login
+// from a device renews its TTL, so only devices unused for the whole TTL
+// are forgotten.
+type DeviceStore struct {
+	client *RedisClient
+	ttl    time.Duration
+}
+
+// NewDeviceStore forgets devices after ttl, or DefaultKnownDeviceTTL when
+// ttl isn't positive
+func NewDeviceStore(client *RedisClient, ttl time.Duration) *DeviceStore {
+	if ttl <= 0 {
+		ttl = DefaultKnownDeviceTTL
+	}
+	return &DeviceStore{client: client, ttl: ttl}
+}
+
+func (d *DeviceStore) RememberDevice(ctx context.Context, userID uint, fingerprint string) (bool, error) {
+	key := deviceKeyPrefix(userID) + fingerprint
+	added, err := d.client.SetNX(ctx, key, "1", d.ttl)
+	if err != nil {
+		return false, err
+	}
+	if added {
+		return false, nil
+	}
+	if err := d.client.Expire(ctx, key, d.ttl); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// ForgetDevices deletes the user's device keys, found by scanning for
+// their prefix
+func (d *DeviceStore) ForgetDevices(ctx context.Context, userID uint) error {
+	return d.client.DeleteMatching(ctx, deviceKeyPrefix(userID)+"*")
+}
+
+func deviceKeyPrefix(userID uint) string {
+	return fmt.Sprintf("auth:known_device:%d:", userID)
+}
@@ -0,0 +1,69 @@
+// internal/infrastructure/redis/device_store_test.go
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestDeviceStore_KnownUntilUnusedForTTL(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := NewRedisClient(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("connect to miniredis: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	store := NewDeviceStore(client, time.Hour)
+
+	if known, err := store.RememberDevice(ctx, 1, "laptop"); err != nil || known {
+		t.Fatalf("expected a new device, got %v (%v)", known, err)
+	}
+	if known, _ := store.RememberDevice(ctx, 2, "laptop"); known {
+		t.Error("devices must be per user")
+	}
+
+	// Each login renews the TTL
+	mr.FastForward(50 * time.Minute)
+	if known, _ := store.RememberDevice(ctx, 1, "laptop"); !known {
+		t.Error("expected the device to be known")
+	}
+	mr.FastForward(50 * time.Minute)
+	if known, _ := store.RememberDevice(ctx, 1, "laptop"); !known {
+		t.Error("expected a login to renew the device")
+	}
+
+	mr.FastForward(2 * time.Hour)
+	if known, _ := store.RememberDevice(ctx, 1, "laptop"); known {
+		t.Error("expected an unused device to be forgotten")
+	}
+}
+
+func TestDeviceStore_ForgetDevices(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := NewRedisClient(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("connect to miniredis: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	store := NewDeviceStore(client, time.Hour)
+	store.RememberDevice(ctx, 1, "laptop")
+	store.RememberDevice(ctx, 1, "phone")
+	store.RememberDevice(ctx, 12, "laptop")
+
+	if err := store.ForgetDevices(ctx, 1); err != nil {
+		t.Fatalf("forget devices: %v", err)
+	}
+	if known, _ := store.RememberDevice(ctx, 1, "phone"); known {
+		t.Error("expected the user's devices to be forgotten")
+	}
+	if known, _ := store.RememberDevice(ctx, 12, "laptop"); !known {
+		t.Error("expected another user's devices to be kept")
+	}
+}
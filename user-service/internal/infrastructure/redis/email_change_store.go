@@ -0,0 +1,63 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"user-service/internal/application"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var _ application.EmailChangeStore = (*EmailChangeStore)(nil)
+
+// EmailChangeStore keeps one key per user holding their pending new
+// address and the hash of the token confirming it, expiring with the token
+type EmailChangeStore struct {
+	client *RedisClient
+}
+
+type pendingEmailChange struct {
+	Hash  string `json:"hash"`
+	Email string `json:"email"`
+}
+
+func NewEmailChangeStore(client *RedisClient) *EmailChangeStore {
+	return &EmailChangeStore{client: client}
+}
+
+func (s *EmailChangeStore) SaveEmailChange(ctx context.Context, userID uint, hash, email string, ttl time.Duration) error {
+	return s.client.Set(ctx, s.key(userID), pendingEmailChange{Hash: hash, Email: email}, ttl)
+}
+
+func (s *EmailChangeStore) ConsumeEmailChange(ctx context.Context, userID uint, hash string) (string, bool, error) {
+	var pending pendingEmailChange
+	err := s.client.Get(ctx, s.key(userID), &pending)
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if pending.Hash != hash {
+		return "", false, nil
+	}
+	// Deleting only the value just read means a request replacing it in
+	// between wins, and a concurrent confirm finds nothing
+	value, err := json.Marshal(pending)
+	if err != nil {
+		return "", false, err
+	}
+	deleted, err := s.client.DeleteIfValue(ctx, s.key(userID), string(value))
+	if err != nil || !deleted {
+		return "", false, err
+	}
+	return pending.Email, true, nil
+}
+
+func (s *EmailChangeStore) key(userID uint) string {
+	return fmt.Sprintf("auth:email_change:%d", userID)
+}
@@ -0,0 +1,49 @@
+// internal/infrastructure/redis/email_change_store_test.go
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestEmailChangeStore_SingleUse(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := NewRedisClient(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("connect to miniredis: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	store := NewEmailChangeStore(client)
+
+	if err := store.SaveEmailChange(ctx, 1, "first", "old-choice@example.com", time.Hour); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	// Asking again replaces the first change, token and address both
+	if err := store.SaveEmailChange(ctx, 1, "second", "new@example.com", time.Hour); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, used, err := store.ConsumeEmailChange(ctx, 1, "first"); err != nil || used {
+		t.Errorf("expected the replaced token refused, got %v (%v)", used, err)
+	}
+	if _, used, _ := store.ConsumeEmailChange(ctx, 2, "second"); used {
+		t.Error("changes must be per user")
+	}
+	email, used, err := store.ConsumeEmailChange(ctx, 1, "second")
+	if err != nil || !used || email != "new@example.com" {
+		t.Fatalf("expected the change to new@example.com, got %q %v (%v)", email, used, err)
+	}
+	if _, used, _ := store.ConsumeEmailChange(ctx, 1, "second"); used {
+		t.Error("expected a token to work once")
+	}
+
+	store.SaveEmailChange(ctx, 1, "third", "new@example.com", time.Hour)
+	mr.FastForward(61 * time.Minute)
+	if _, used, _ := store.ConsumeEmailChange(ctx, 1, "third"); used {
+		t.Error("expected the token expired")
+	}
+}
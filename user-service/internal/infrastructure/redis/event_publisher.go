@@ -0,0 +1,28 @@
+package redis
+
+import (
+	"context"
+
+	"user-service/internal/application"
+)
+
+var _ application.EventPublisher = (*EventPublisher)(nil)
+
+// UserEventsChannel is the pub/sub channel user events are published on
+const UserEventsChannel = "events:user"
+
+type EventPublisher struct {
+	client  *RedisClient
+	channel string
+}
+
+func NewEventPublisher(client *RedisClient) *EventPublisher {
+	return &EventPublisher{
+		client:  client,
+		channel: UserEventsChannel,
+	}
+}
+
+func (p *EventPublisher) Publish(ctx context.Context, event application.Event) error {
+	return p.client.Publish(ctx, p.channel, event)
+}
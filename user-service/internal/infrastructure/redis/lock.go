@@ -0,0 +1,47 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"user-service/internal/jobs"
+)
+
+var _ jobs.Locker = (*DistributedLock)(nil)
+
+// DistributedLock is a single-node Redis lease (SET NX PX). Each holder gets
+// a random token so an expired holder can't release someone else's lease.
+type DistributedLock struct {
+	client *RedisClient
+}
+
+func NewDistributedLock(client *RedisClient) *DistributedLock {
+	return &DistributedLock{client: client}
+}
+
+func (l *DistributedLock) TryLock(ctx context.Context, key string, ttl time.Duration) (func(ctx context.Context) error, bool, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, false, fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	acquired, err := l.client.SetNX(ctx, key, token, ttl)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to acquire lock %s: %w", key, err)
+	}
+	if !acquired {
+		return nil, false, nil
+	}
+
+	release := func(ctx context.Context) error {
+		if _, err := l.client.DeleteIfValue(ctx, key, token); err != nil {
+			return fmt.Errorf("failed to release lock %s: %w", key, err)
+		}
+		return nil
+	}
+	return release, true, nil
+}
@@ -0,0 +1,51 @@
+// internal/infrastructure/redis/lock_test.go
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestDistributedLock_ExclusiveUntilReleasedOrExpired(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := NewRedisClient(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("connect to miniredis: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	first := NewDistributedLock(client)
+	second := NewDistributedLock(client)
+
+	release, ok, err := first.TryLock(ctx, "locks:test", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected first lock to succeed, got %v (%v)", ok, err)
+	}
+	if _, ok, _ := second.TryLock(ctx, "locks:test", time.Minute); ok {
+		t.Fatal("lock must be exclusive")
+	}
+
+	if err := release(ctx); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	release2, ok, _ := second.TryLock(ctx, "locks:test", time.Minute)
+	if !ok {
+		t.Fatal("expected lock to be free after release")
+	}
+
+	// A stale holder releasing after expiry must not drop the new lease
+	mr.FastForward(2 * time.Minute)
+	if _, ok, _ := first.TryLock(ctx, "locks:test", time.Minute); !ok {
+		t.Fatal("expected expired lease to be reacquirable")
+	}
+	if err := release2(ctx); err != nil {
+		t.Fatalf("stale release: %v", err)
+	}
+	if !mr.Exists("locks:test") {
+		t.Error("stale holder released someone else's lease")
+	}
+}
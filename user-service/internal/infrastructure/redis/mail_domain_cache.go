@@ -0,0 +1,43 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-service/internal/infrastructure/maildomain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var _ maildomain.Cache = (*MailDomainCache)(nil)
+
+// MailDomainCache stores whether a domain can receive mail, shared by
+// every replica's registration checks
+type MailDomainCache struct {
+	client *RedisClient
+}
+
+func NewMailDomainCache(client *RedisClient) *MailDomainCache {
+	return &MailDomainCache{client: client}
+}
+
+func (c *MailDomainCache) GetMailDomain(ctx context.Context, domain string) (bool, bool, error) {
+	var canReceive bool
+	err := c.client.Get(ctx, c.key(domain), &canReceive)
+	if errors.Is(err, redis.Nil) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return canReceive, true, nil
+}
+
+func (c *MailDomainCache) SetMailDomain(ctx context.Context, domain string, canReceive bool, ttl time.Duration) error {
+	return c.client.Set(ctx, c.key(domain), canReceive, ttl)
+}
+
+func (c *MailDomainCache) key(domain string) string {
+	return "maildomain:" + domain
+}
@@ -0,0 +1,39 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"user-service/internal/application"
+)
+
+var _ application.PasswordResetStore = (*PasswordResetStore)(nil)
+
+// PasswordResetStore keeps one key per user holding the hash of their
+// outstanding reset token, expiring with the token
+type PasswordResetStore struct {
+	client *RedisClient
+}
+
+func NewPasswordResetStore(client *RedisClient) *PasswordResetStore {
+	return &PasswordResetStore{client: client}
+}
+
+func (s *PasswordResetStore) SavePasswordReset(ctx context.Context, userID uint, hash string, ttl time.Duration) error {
+	return s.client.Set(ctx, s.key(userID), hash, ttl)
+}
+
+func (s *PasswordResetStore) ConsumePasswordReset(ctx context.Context, userID uint, hash string) (bool, error) {
+	// Set stores values as JSON, so compare against the encoded hash
+	value, err := json.Marshal(hash)
+	if err != nil {
+		return false, err
+	}
+	return s.client.DeleteIfValue(ctx, s.key(userID), string(value))
+}
+
+func (s *PasswordResetStore) key(userID uint) string {
+	return fmt.Sprintf("auth:password_reset:%d", userID)
+}
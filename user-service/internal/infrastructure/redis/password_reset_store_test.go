@@ -0,0 +1,48 @@
+// internal/infrastructure/redis/password_reset_store_test.go
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestPasswordResetStore_SingleUse(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client, err := NewRedisClient(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("connect to miniredis: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	store := NewPasswordResetStore(client)
+
+	if err := store.SavePasswordReset(ctx, 1, "first", 15*time.Minute); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	// Asking again replaces the first token
+	if err := store.SavePasswordReset(ctx, 1, "second", 15*time.Minute); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if used, err := store.ConsumePasswordReset(ctx, 1, "first"); err != nil || used {
+		t.Errorf("expected the replaced token refused, got %v (%v)", used, err)
+	}
+	if used, _ := store.ConsumePasswordReset(ctx, 2, "second"); used {
+		t.Error("tokens must be per user")
+	}
+	if used, err := store.ConsumePasswordReset(ctx, 1, "second"); err != nil || !used {
+		t.Fatalf("expected the token used, got %v (%v)", used, err)
+	}
+	if used, _ := store.ConsumePasswordReset(ctx, 1, "second"); used {
+		t.Error("expected a token to work once")
+	}
+
+	store.SavePasswordReset(ctx, 1, "third", 15*time.Minute)
+	mr.FastForward(16 * time.Minute)
+	if used, _ := store.ConsumePasswordReset(ctx, 1, "third"); used {
+		t.Error("expected the token expired")
+	}
+}
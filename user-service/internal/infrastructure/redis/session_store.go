@@ -0,0 +1,97 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"user-service/internal/application"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var _ application.SessionRevoker = (*SessionStore)(nil)
+var _ application.RevokedSessionCache = (*SessionStore)(nil)
+
+// SessionStore records, per user, the moment all previously issued tokens
+// stopped being valid. Entries live as long as a token can, after which
+// every token issued before the revocation has expired on its own. It also
+// keeps the IDs of single tokens revoked by logging out, each for as long
+// as its token had left, and of revoked sessions, for as long as a token
+// can live.
+type SessionStore struct {
+	client   *RedisClient
+	tokenTTL time.Duration
+}
+
+func NewSessionStore(client *RedisClient, tokenTTL time.Duration) *SessionStore {
+	return &SessionStore{
+		client:   client,
+		tokenTTL: tokenTTL,
+	}
+}
+
+// RevokeUserSessions invalidates every token issued to the user until now
+func (s *SessionStore) RevokeUserSessions(ctx context.Context, userID uint) error {
+	return s.client.Set(ctx, s.revokedKey(userID), time.Now().Unix(), s.tokenTTL)
+}
+
+// RevokedAt returns when the user's sessions were last revoked, if ever
+func (s *SessionStore) RevokedAt(ctx context.Context, userID uint) (time.Time, bool, error) {
+	var unix int64
+	err := s.client.Get(ctx, s.revokedKey(userID), &unix)
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to read revocation: %w", err)
+	}
+
+	return time.Unix(unix, 0), true, nil
+}
+
+// RevokeToken invalidates the token with the given jti for ttl, the time
+// it had left to live
+func (s *SessionStore) RevokeToken(ctx context.Context, tokenID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	return s.client.Set(ctx, s.revokedTokenKey(tokenID), 1, ttl)
+}
+
+// IsTokenRevoked reports whether the token with the given jti was revoked
+func (s *SessionStore) IsTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.revokedTokenKey(tokenID))
+	if err != nil {
+		return false, fmt.Errorf("failed to read token revocation: %w", err)
+	}
+	return n > 0, nil
+}
+
+// MarkSessionRevoked makes SessionRevoked report the session id revoked
+// until every access token it could have issued has expired
+func (s *SessionStore) MarkSessionRevoked(ctx context.Context, id string) error {
+	return s.client.Set(ctx, s.revokedSessionKey(id), 1, s.tokenTTL)
+}
+
+// SessionRevoked reports whether MarkSessionRevoked was called for id
+func (s *SessionStore) SessionRevoked(ctx context.Context, id string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.revokedSessionKey(id))
+	if err != nil {
+		return false, fmt.Errorf("failed to read session revocation: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *SessionStore) revokedKey(userID uint) string {
+	return fmt.Sprintf("auth:revoked_before:%d", userID)
+}
+
+func (s *SessionStore) revokedTokenKey(tokenID string) string {
+	return "auth:revoked_token:" + tokenID
+}
+
+func (s *SessionStore) revokedSessionKey(id string) string {
+	return "auth:revoked_session:" + id
+}
@@ -0,0 +1,32 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"user-service/internal/application"
+)
+
+var _ application.StatsCache = (*StatsCache)(nil)
+
+// StatsCache stores computed activity windows under the key chosen by the
+// stats service
+type StatsCache struct {
+	client *RedisClient
+}
+
+func NewStatsCache(client *RedisClient) *StatsCache {
+	return &StatsCache{client: client}
+}
+
+func (c *StatsCache) GetActivity(ctx context.Context, key string) (*application.ActivityReport, error) {
+	var report application.ActivityReport
+	if err := c.client.Get(ctx, key, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+func (c *StatsCache) SetActivity(ctx context.Context, key string, report *application.ActivityReport, ttl time.Duration) error {
+	return c.client.Set(ctx, key, report, ttl)
+}
@@ -0,0 +1,89 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"user-service/internal/application"
+)
+
+var _ application.UserBlocklist = (*UserBlocklist)(nil)
+
+// revokedUsersKey is the Redis set of user IDs whose tokens are rejected
+const revokedUsersKey = "auth:revoked_users"
+
+type blocklistEntry struct {
+	blocked   bool
+	expiresAt time.Time
+}
+
+// UserBlocklist is the Redis "revoked users" set: banned accounts and
+// accounts pending deletion or erased. Lookups are cached
+// in-process for localTTL so the auth path costs at most one Redis call per
+// user every few seconds; changes made on this instance apply immediately.
+type UserBlocklist struct {
+	client   *RedisClient
+	localTTL time.Duration
+
+	mu    sync.RWMutex
+	local map[uint]blocklistEntry
+}
+
+func NewUserBlocklist(client *RedisClient, localTTL time.Duration) *UserBlocklist {
+	return &UserBlocklist{
+		client:   client,
+		localTTL: localTTL,
+		local:    make(map[uint]blocklistEntry),
+	}
+}
+
+func (b *UserBlocklist) Block(ctx context.Context, userID uint) error {
+	if err := b.client.SAdd(ctx, revokedUsersKey, userID); err != nil {
+		return err
+	}
+	b.remember(userID, true)
+	return nil
+}
+
+func (b *UserBlocklist) Unblock(ctx context.Context, userID uint) error {
+	if err := b.client.SRem(ctx, revokedUsersKey, userID); err != nil {
+		return err
+	}
+	b.remember(userID, false)
+	return nil
+}
+
+// IsBlocked reports whether the user is in the revoked set
+func (b *UserBlocklist) IsBlocked(ctx context.Context, userID uint) (bool, error) {
+	b.mu.RLock()
+	entry, ok := b.local[userID]
+	b.mu.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.blocked, nil
+	}
+
+	blocked, err := b.client.SIsMember(ctx, revokedUsersKey, userID)
+	if err != nil {
+		return false, err
+	}
+	b.remember(userID, blocked)
+	return blocked, nil
+}
+
+func (b *UserBlocklist) remember(userID uint, blocked bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.local[userID] = blocklistEntry{blocked: blocked, expiresAt: now.Add(b.localTTL)}
+
+	// Opportunistically drop expired entries so the map stays bounded
+	if len(b.local) > 10000 {
+		for id, e := range b.local {
+			if now.After(e.expiresAt) {
+				delete(b.local, id)
+			}
+		}
+	}
+}
@@ -2,12 +2,18 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
+	"user-service/internal/application"
 	"user-service/internal/domain"
 )
 
+var _ application.UserCache = (*UserCache)(nil)
+var _ application.WriteMarker = (*UserCache)(nil)
+var _ application.TokenVersionCache = (*UserCache)(nil)
+
 type UserCache struct {
 	client *RedisClient
 	ttl    time.Duration
@@ -25,6 +31,12 @@ func (c *UserCache) Set(ctx context.Context, user *domain.User) error {
 	return c.client.Set(ctx, key, user, c.ttl)
 }
 
+// SetWithTTL caches user for ttl instead of the configured TTL
+func (c *UserCache) SetWithTTL(ctx context.Context, user *domain.User, ttl time.Duration) error {
+	key := c.userKey(user.ID)
+	return c.client.Set(ctx, key, user, ttl)
+}
+
 func (c *UserCache) Get(ctx context.Context, userID uint) (*domain.User, error) {
 	key := c.userKey(userID)
 	var user domain.User
@@ -37,6 +49,32 @@ func (c *UserCache) Get(ctx context.Context, userID uint) (*domain.User, error)
 	return &user, nil
 }
 
+// GetMany reads all of userIDs with a single MGET
+func (c *UserCache) GetMany(ctx context.Context, userIDs []uint) (map[uint]*domain.User, error) {
+	users := make(map[uint]*domain.User, len(userIDs))
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+
+	keys := make([]string, len(userIDs))
+	for i, id := range userIDs {
+		keys[i] = c.userKey(id)
+	}
+	err := c.client.MGet(ctx, keys, func(i int, data []byte) error {
+		var user domain.User
+		if err := json.Unmarshal(data, &user); err != nil {
+			return fmt.Errorf("failed to unmarshal cached user %d: %w", userIDs[i], err)
+		}
+		users[userIDs[i]] = &user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
 func (c *UserCache) Delete(ctx context.Context, userID uint) error {
 	key := c.userKey(userID)
 	return c.client.Delete(ctx, key)
@@ -64,10 +102,49 @@ func (c *UserCache) DeleteByEmail(ctx context.Context, email string) error {
 	return c.client.Delete(ctx, key)
 }
 
+// MarkWritten stores when the user was last mutated, for
+// application.RecentWriteWindow
+func (c *UserCache) MarkWritten(ctx context.Context, userID uint) error {
+	key := c.writtenKey(userID)
+	return c.client.Set(ctx, key, time.Now().UTC().UnixMilli(), application.RecentWriteWindow)
+}
+
+// WrittenRecently reports whether the user's write marker is still live
+func (c *UserCache) WrittenRecently(ctx context.Context, userID uint) (bool, error) {
+	n, err := c.client.Exists(ctx, c.writtenKey(userID))
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetTokenVersion reads the version cached by SetTokenVersion, failing
+// with redis.Nil when there is none
+func (c *UserCache) GetTokenVersion(ctx context.Context, userID uint) (uint, error) {
+	var version uint
+	if err := c.client.Get(ctx, c.tokenVersionKey(userID), &version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// SetTokenVersion caches the user's token version for the user TTL
+func (c *UserCache) SetTokenVersion(ctx context.Context, userID uint, version uint) error {
+	return c.client.Set(ctx, c.tokenVersionKey(userID), version, c.ttl)
+}
+
 func (c *UserCache) userKey(userID uint) string {
 	return fmt.Sprintf("user:id:%d", userID)
 }
 
+func (c *UserCache) writtenKey(userID uint) string {
+	return fmt.Sprintf("user:written:%d", userID)
+}
+
+func (c *UserCache) tokenVersionKey(userID uint) string {
+	return fmt.Sprintf("user:token_version:%d", userID)
+}
+
 func (c *UserCache) emailKey(email string) string {
 	return fmt.Sprintf("user:email:%s", email)
 }
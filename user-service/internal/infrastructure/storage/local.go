@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"user-service/internal/infrastructure/auth"
+)
+
+var _ Storage = (*Local)(nil)
+
+// metaSuffix marks the sidecar file holding an object's content type
+const metaSuffix = ".meta"
+
+// Local stores files on disk under a root directory. Each key maps to one
+// file, named by its escaped key, in a two-level directory picked by the
+// key's hash, so no directory grows past a few thousand entries.
+type Local struct {
+	root      string
+	signer    *auth.URLSigner
+	urlPrefix string
+}
+
+// LocalOption configures optional Local behavior
+type LocalOption func(*Local)
+
+// WithLocalSignedURLs makes SignedURL return links under urlPrefix, like
+// "/files", signed with signer. Mount a handler that checks them and
+// serves the file, see ServeFile.
+func WithLocalSignedURLs(signer *auth.URLSigner, urlPrefix string) LocalOption {
+	return func(l *Local) {
+		l.signer = signer
+		l.urlPrefix = strings.TrimSuffix(urlPrefix, "/")
+	}
+}
+
+// NewLocal stores files under root, creating it if needed
+func NewLocal(root string, opts ...LocalOption) (*Local, error) {
+	if root == "" {
+		return nil, errors.New("storage: local root is required")
+	}
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("storage: failed to create %s: %w", root, err)
+	}
+	l := &Local{root: root}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l, nil
+}
+
+// path returns where key lives. Escaping makes the key a single path
+// element, so no key can name a file outside its shard.
+func (l *Local) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	shard := hex.EncodeToString(sum[:2])
+	return filepath.Join(l.root, shard[:2], shard[2:], url.PathEscape(key))
+}
+
+type localMeta struct {
+	ContentType string `json:"content_type"`
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) (*Object, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	path := l.path(key)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("storage: failed to create %s: %w", dir, err)
+	}
+
+	// Write beside the destination and rename over it, so readers never
+	// see a partial file
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("storage: failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, &ctxReader{ctx: ctx, r: r})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("storage: failed to write %s: %w", key, err)
+	}
+
+	contentType := contentTypeOrDefault(opts.ContentType)
+	meta, _ := json.Marshal(localMeta{ContentType: contentType})
+	if err := writeFileAtomic(path+metaSuffix, meta); err != nil {
+		return nil, fmt.Errorf("storage: failed to write %s metadata: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("storage: failed to store %s: %w", key, err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("storage: failed to stat %s: %w", key, err)
+	}
+	return &Object{Key: key, ContentType: contentType, Size: size, ModTime: info.ModTime().UTC()}, nil
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, nil, err
+	}
+	path := l.path(key)
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("storage: failed to open %s: %w", key, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("storage: failed to stat %s: %w", key, err)
+	}
+
+	obj := &Object{Key: key, ContentType: contentTypeOrDefault(""), Size: info.Size(), ModTime: info.ModTime().UTC()}
+	var meta localMeta
+	if raw, err := os.ReadFile(path + metaSuffix); err == nil && json.Unmarshal(raw, &meta) == nil {
+		obj.ContentType = contentTypeOrDefault(meta.ContentType)
+	}
+	return f, obj, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	path := l.path(key)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("storage: failed to delete %s: %w", key, err)
+	}
+	if err := os.Remove(path + metaSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("storage: failed to delete %s metadata: %w", key, err)
+	}
+	return nil
+}
+
+// SignedURL returns the URL prefix, the key and a signature; the link
+// works whether or not key exists yet
+func (l *Local) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	if l.signer == nil {
+		return "", ErrSignedURLsUnsupported
+	}
+	return l.signer.Sign(l.urlPrefix+"/"+key, ttl), nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".meta-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ctxReader stops a copy once ctx is done
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
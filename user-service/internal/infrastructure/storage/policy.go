@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrTooLarge is content over the policy's MaxSize
+	ErrTooLarge = errors.New("storage: file too large")
+	// ErrContentType is content of a type the policy doesn't allow
+	ErrContentType = errors.New("storage: content type not allowed")
+)
+
+// sniffLen is how much content http.DetectContentType looks at
+const sniffLen = 512
+
+// Policy is what a caller accepts into storage. Each feature has its own,
+// e.g. small images for avatars.
+type Policy struct {
+	// MaxSize is the largest file in bytes; 0 means no limit
+	MaxSize int64
+	// ContentTypes are the accepted media types, like "image/png",
+	// without parameters; empty accepts any
+	ContentTypes []string
+	// Sniff takes the content type from the content itself instead of
+	// trusting the caller's, for files that come from users
+	Sniff bool
+}
+
+// WithPolicy wraps store so every Put is held to policy. A Put that breaks
+// it fails with ErrTooLarge or ErrContentType and stores nothing.
+func WithPolicy(store Storage, policy Policy) Storage {
+	return &policyStorage{Storage: store, policy: policy}
+}
+
+type policyStorage struct {
+	Storage
+	policy Policy
+}
+
+func (s *policyStorage) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) (*Object, error) {
+	if s.policy.Sniff {
+		buffered := bufio.NewReaderSize(r, sniffLen)
+		head, err := buffered.Peek(sniffLen)
+		if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, err
+		}
+		opts.ContentType = http.DetectContentType(head)
+		r = buffered
+	}
+
+	contentType, err := s.policy.checkType(opts.ContentType)
+	if err != nil {
+		return nil, err
+	}
+	opts.ContentType = contentType
+
+	if s.policy.MaxSize > 0 {
+		r = &limitedReader{r: r, remaining: s.policy.MaxSize}
+	}
+	return s.Storage.Put(ctx, key, r, opts)
+}
+
+// checkType normalizes contentType and checks it against the policy
+func (p Policy) checkType(contentType string) (string, error) {
+	if len(p.ContentTypes) == 0 {
+		return contentType, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrContentType, contentType)
+	}
+	for _, allowed := range p.ContentTypes {
+		if strings.EqualFold(mediaType, allowed) {
+			return contentType, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s, want one of %s", ErrContentType, mediaType, strings.Join(p.ContentTypes, ", "))
+}
+
+// limitedReader fails with ErrTooLarge once more than remaining bytes have
+// been read, unlike io.LimitReader, which quietly truncates
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	// Read one byte past the limit to tell "exactly at" from "over"
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return 0, ErrTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ Storage = (*S3)(nil)
+
+// Server-side encryption modes for S3Config.SSE
+const (
+	SSENone = ""
+	SSES3   = "AES256"
+	SSEKMS  = "aws:kms"
+)
+
+// S3Config locates a bucket on S3 or an S3-compatible server like MinIO
+type S3Config struct {
+	// Endpoint is the server's base URL, like "https://s3.eu-west-1.amazonaws.com"
+	// or "http://minio:9000"
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses the bucket as endpoint/bucket/key rather than
+	// bucket.endpoint/key; MinIO and most self-hosted servers need it
+	PathStyle bool
+	// SSE asks the server to encrypt objects at rest: SSES3, SSEKMS or
+	// SSENone
+	SSE string
+	// KMSKeyID picks the key for SSEKMS; empty uses the bucket's default
+	KMSKeyID string
+}
+
+// Validate reports the first missing or invalid setting
+func (c S3Config) Validate() error {
+	u, err := url.Parse(c.Endpoint)
+	switch {
+	case c.Endpoint == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+		return fmt.Errorf("storage: invalid S3 endpoint %q", c.Endpoint)
+	case c.Region == "":
+		return errors.New("storage: S3 region is required")
+	case c.Bucket == "":
+		return errors.New("storage: S3 bucket is required")
+	case c.AccessKey == "" || c.SecretKey == "":
+		return errors.New("storage: S3 credentials are required")
+	}
+	switch c.SSE {
+	case SSENone, SSES3, SSEKMS:
+	default:
+		return fmt.Errorf("storage: invalid S3 server-side encryption %q, want %s or %s", c.SSE, SSES3, SSEKMS)
+	}
+	return nil
+}
+
+// S3 stores files in one bucket of an S3-compatible server, signing
+// requests with AWS Signature Version 4
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+// S3Option configures optional S3 behavior
+type S3Option func(*S3)
+
+// WithS3HTTPClient sets the client requests are sent with
+func WithS3HTTPClient(client *http.Client) S3Option {
+	return func(s *S3) {
+		s.client = client
+	}
+}
+
+// WithS3Clock sets the time requests and links are signed at
+func WithS3Clock(now func() time.Time) S3Option {
+	return func(s *S3) {
+		s.now = now
+	}
+}
+
+func NewS3(cfg S3Config, opts ...S3Option) (*S3, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	endpoint, _ := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	s := &S3{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
+}
+
+// objectURL returns the URL of key, which ValidateKey has made safe to
+// use unescaped
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	if s.cfg.PathStyle {
+		u.Path += "/" + s.cfg.Bucket + "/" + key
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path += "/" + key
+	}
+	return &u
+}
+
+// Put spools the content to a temp file first: S3 needs the length and
+// payload hash before the body, and the content may be of unknown size
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, opts PutOptions) (*Object, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	spool, err := os.CreateTemp("", "s3-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("storage: failed to spool %s: %w", key, err)
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), &ctxReader{ctx: ctx, r: r})
+	if err != nil {
+		return nil, fmt.Errorf("storage: failed to read %s: %w", key, err)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("storage: failed to spool %s: %w", key, err)
+	}
+
+	contentType := contentTypeOrDefault(opts.ContentType)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), io.NopCloser(spool))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	switch s.cfg.SSE {
+	case SSES3:
+		req.Header.Set("X-Amz-Server-Side-Encryption", SSES3)
+	case SSEKMS:
+		req.Header.Set("X-Amz-Server-Side-Encryption", SSEKMS)
+		if s.cfg.KMSKeyID != "" {
+			req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", s.cfg.KMSKeyID)
+		}
+	}
+	resp, err := s.do(req, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return nil, fmt.Errorf("storage: failed to put %s: %w", key, err)
+	}
+	resp.Body.Close()
+
+	return &Object{Key: key, ContentType: contentType, Size: size, ModTime: s.now().UTC()}, nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, fmt.Errorf("storage: failed to get %s: %w", key, err)
+	}
+
+	obj := &Object{
+		Key:         key,
+		ContentType: contentTypeOrDefault(resp.Header.Get("Content-Type")),
+		Size:        resp.ContentLength,
+	}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		obj.ModTime = modTime.UTC()
+	}
+	return resp.Body, obj, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, emptyPayloadHash)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("storage: failed to delete %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// SignedURL presigns a GET of key, good for at most 7 days as S3 allows
+func (s *S3) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	if ttl <= 0 || ttl > maxPresignTTL {
+		return "", fmt.Errorf("storage: signed url ttl must be between 1s and %s", maxPresignTTL)
+	}
+	return s.presign(s.objectURL(key), ttl).String(), nil
+}
+
+// do signs and sends req, turning error responses into errors. A 404 is
+// ErrNotFound.
+func (s *S3) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(req, payloadHash)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("s3 answered %s: %s", strconv.Itoa(resp.StatusCode), strings.TrimSpace(string(body)))
+}
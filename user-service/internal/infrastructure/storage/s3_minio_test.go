@@ -0,0 +1,135 @@
+//go:build minio
+
+// internal/infrastructure/storage/s3_minio_test.go
+//
+// Runs the storage contract against a real MinIO:
+//
+//	go test -tags minio ./internal/infrastructure/storage/
+//
+// Set TEST_MINIO_ENDPOINT (with TEST_MINIO_ACCESS_KEY and
+// TEST_MINIO_SECRET_KEY) to use a running server instead of a container.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	minioImage     = "minio/minio:latest"
+	minioAccessKey = "minioadmin"
+	minioSecretKey = "minioadmin"
+)
+
+func minioEndpoint(t *testing.T) (endpoint, accessKey, secretKey string) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping MinIO integration test in short mode")
+	}
+	if endpoint := os.Getenv("TEST_MINIO_ENDPOINT"); endpoint != "" {
+		return endpoint, os.Getenv("TEST_MINIO_ACCESS_KEY"), os.Getenv("TEST_MINIO_SECRET_KEY")
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Skipf("Docker not available: %v", r)
+			}
+		}()
+		testcontainers.SkipIfProviderIsNotHealthy(t)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        minioImage,
+			Cmd:          []string{"server", "/data"},
+			ExposedPorts: []string{"9000/tcp"},
+			Env: map[string]string{
+				"MINIO_ROOT_USER":     minioAccessKey,
+				"MINIO_ROOT_PASSWORD": minioSecretKey,
+			},
+			WaitingFor: wait.ForHTTP("/minio/health/ready").WithPort("9000/tcp").WithStartupTimeout(time.Minute),
+		},
+		Started: true,
+	})
+	if err != nil {
+		t.Fatalf("start minio: %v", err)
+	}
+	t.Cleanup(func() { container.Terminate(context.Background()) })
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatalf("minio host: %v", err)
+	}
+	port, err := container.MappedPort(ctx, "9000/tcp")
+	if err != nil {
+		t.Fatalf("minio port: %v", err)
+	}
+	return fmt.Sprintf("http://%s:%s", host, port.Port()), minioAccessKey, minioSecretKey
+}
+
+// createBucket makes a fresh bucket for the test
+func createBucket(t *testing.T, s *S3) {
+	t.Helper()
+	u := *s.endpoint
+	u.Path += "/" + s.cfg.Bucket
+	req, _ := http.NewRequest(http.MethodPut, u.String(), nil)
+	resp, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		t.Fatalf("create bucket: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestS3_MinIOContract(t *testing.T) {
+	endpoint, accessKey, secretKey := minioEndpoint(t)
+	s, err := NewS3(S3Config{
+		Endpoint:  endpoint,
+		Region:    "us-east-1",
+		Bucket:    fmt.Sprintf("contract-%d", time.Now().UnixNano()),
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("new s3: %v", err)
+	}
+	createBucket(t, s)
+
+	testContract(t, s)
+
+	// A presigned link downloads without credentials
+	ctx := context.Background()
+	if _, err := s.Put(ctx, "shared/report.csv", io.LimitReader(zeros{}, 1<<20), PutOptions{ContentType: "text/csv"}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	link, err := s.SignedURL(ctx, "shared/report.csv", time.Minute)
+	if err != nil {
+		t.Fatalf("signed url: %v", err)
+	}
+	resp, err := http.Get(link)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength != 1<<20 {
+		t.Errorf("expected the file downloaded, got %d with %d bytes", resp.StatusCode, resp.ContentLength)
+	}
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat  = "20060102T150405Z"
+	// emptyPayloadHash is the SHA-256 of an empty body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// unsignedPayload stands in for the body hash of presigned links
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// maxPresignTTL is the longest expiry S3 accepts for a presigned link
+	maxPresignTTL = 7 * 24 * time.Hour
+)
+
+// unsignedHeaders may be changed by proxies on the way, so they are left
+// out of the signature
+var unsignedHeaders = map[string]bool{
+	"authorization": true,
+	"user-agent":    true,
+	"expect":        true,
+}
+
+// sign adds the headers of an AWS Signature Version 4 to req. Every header
+// already set is signed, so set them all before calling it.
+func (s *S3) sign(req *http.Request, payloadHash string) {
+	now := s.now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if !unsignedHeaders[name] {
+			headers[name] = strings.Join(values, ",")
+		}
+	}
+	signedHeaders, canonicalHeaders := canonicalizeHeaders(headers)
+
+	scope := s.scope(now)
+	signature := s.signature(now, scope, canonicalRequest(req.Method, req.URL, canonicalHeaders, signedHeaders, payloadHash))
+	req.Header.Set("Authorization", sigV4Algorithm+
+		" Credential="+s.cfg.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+signature)
+}
+
+// presign returns u with a query-string signature for a GET that expires
+// after ttl
+func (s *S3) presign(u *url.URL, ttl time.Duration) *url.URL {
+	now := s.now().UTC()
+	scope := s.scope(now)
+
+	signed := *u
+	query := signed.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", s.cfg.AccessKey+"/"+scope)
+	query.Set("X-Amz-Date", now.Format(amzDateFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(ttl/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+	signed.RawQuery = canonicalQuery(query)
+
+	signedHeaders, canonicalHeaders := canonicalizeHeaders(map[string]string{"host": u.Host})
+	signature := s.signature(now, scope, canonicalRequest(http.MethodGet, &signed, canonicalHeaders, signedHeaders, unsignedPayload))
+	signed.RawQuery += "&X-Amz-Signature=" + signature
+	return &signed
+}
+
+func (s *S3) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.cfg.Region + "/s3/aws4_request"
+}
+
+func (s *S3) signature(now time.Time, scope, canonical string) string {
+	hash := sha256.Sum256([]byte(canonical))
+	stringToSign := sigV4Algorithm + "\n" +
+		now.Format(amzDateFormat) + "\n" +
+		scope + "\n" +
+		hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func canonicalRequest(method string, u *url.URL, canonicalHeaders, signedHeaders, payloadHash string) string {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return method + "\n" +
+		path + "\n" +
+		canonicalQuery(u.Query()) + "\n" +
+		canonicalHeaders + "\n" +
+		signedHeaders + "\n" +
+		payloadHash
+}
+
+// canonicalizeHeaders returns the sorted header names joined by ";" and
+// the "name:value\n" lines for them
+func canonicalizeHeaders(headers map[string]string) (signed, canonical string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.Join(strings.Fields(headers[name]), " ") + "\n")
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+// canonicalQuery sorts the parameters and escapes them as AWS does: spaces
+// as %20 rather than +
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,144 @@
+// Package storage keeps files such as avatars and exports behind one
+// interface, with a local-disk backend and an S3-compatible one chosen at
+// startup. Callers enforce their size and content-type rules by wrapping a
+// Storage with WithPolicy.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is a Get for a key that holds nothing
+	ErrNotFound = errors.New("storage: object not found")
+	// ErrInvalidKey is a key that isn't a clean relative path, see
+	// ValidateKey
+	ErrInvalidKey = errors.New("storage: invalid key")
+	// ErrSignedURLsUnsupported is SignedURL on a backend built without a
+	// way to sign links
+	ErrSignedURLsUnsupported = errors.New("storage: signed urls not configured")
+)
+
+// MaxKeyLength bounds keys so every backend, and every filesystem under the
+// local one, can hold them
+const MaxKeyLength = 200
+
+// Object describes a stored file
+type Object struct {
+	Key         string
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// PutOptions describe the content being stored
+type PutOptions struct {
+	// ContentType is what Get reports and downloads are served with;
+	// empty stores application/octet-stream
+	ContentType string
+}
+
+// Storage stores files under slash-separated keys like "avatars/42.png".
+// Content is streamed in and out, so large files are never held in memory.
+type Storage interface {
+	// Put stores r's content under key, replacing what was there. Readers
+	// see either the old content or the new, never part of it.
+	Put(ctx context.Context, key string, r io.Reader, opts PutOptions) (*Object, error)
+	// Get opens key for reading; close the reader when done. A missing
+	// key is ErrNotFound.
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a link that downloads key without credentials
+	// until ttl has passed
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// ValidateKey accepts slash-separated relative paths of letters, digits,
+// dots, dashes and underscores, with no empty, "." or ".." segments, so a
+// key can't escape its backend's root or mean different things to
+// different backends
+func ValidateKey(key string) error {
+	if key == "" || len(key) > MaxKeyLength {
+		return fmt.Errorf("%w: %q must be 1 to %d characters", ErrInvalidKey, key, MaxKeyLength)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("%w: %q has an empty or relative segment", ErrInvalidKey, key)
+		}
+	}
+	for _, c := range key {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '/', c == '.', c == '-', c == '_':
+		default:
+			return fmt.Errorf("%w: %q contains %q", ErrInvalidKey, key, c)
+		}
+	}
+	return nil
+}
+
+func contentTypeOrDefault(contentType string) string {
+	if contentType == "" {
+		return "application/octet-stream"
+	}
+	return contentType
+}
+
+// Writer streams into a Storage for code that produces a file by writing
+// to it, like an encoder. The object is stored when Close returns nil.
+type Writer struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+	obj  *Object
+	err  error
+}
+
+// NewWriter starts a Put of key that is fed by writes to the returned
+// Writer. Cancel ctx or call CloseWithError to abandon it.
+func NewWriter(ctx context.Context, store Storage, key string, opts PutOptions) *Writer {
+	pr, pw := io.Pipe()
+	w := &Writer{pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(w.done)
+		w.obj, w.err = store.Put(ctx, key, pr, opts)
+		// Unblock writes if Put gave up before reading everything
+		pr.CloseWithError(errPutFinished(w.err))
+	}()
+	return w
+}
+
+func errPutFinished(err error) error {
+	if err != nil {
+		return err
+	}
+	return io.ErrClosedPipe
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	return w.pw.Write(p)
+}
+
+// Close finishes the content and waits for the Put, returning its error
+func (w *Writer) Close() error {
+	w.pw.Close()
+	<-w.done
+	return w.err
+}
+
+// CloseWithError abandons the Put; nothing is stored
+func (w *Writer) CloseWithError(err error) error {
+	w.pw.CloseWithError(err)
+	<-w.done
+	return w.err
+}
+
+// Object returns what Close stored, or nil before a successful Close
+func (w *Writer) Object() *Object {
+	return w.obj
+}
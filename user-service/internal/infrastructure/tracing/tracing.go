@@ -0,0 +1,65 @@
+// Package tracing sets up OpenTelemetry tracing for the service. Spans are
+// started wherever there is work to see, through otel's global tracer
+// provider; until Setup installs an exporter they cost next to nothing
+// and go nowhere.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ServiceName is what spans are reported under unless OTEL_SERVICE_NAME
+// says otherwise
+const ServiceName = "user-service"
+
+// Config says whether spans are exported
+type Config struct {
+	// Export sends spans to an OTLP/HTTP collector. Where it is, and the
+	// headers, timeouts and sampling, come from the standard
+	// OTEL_EXPORTER_OTLP_* and OTEL_TRACES_SAMPLER* variables.
+	Export bool
+	// Environment is recorded on every span as deployment.environment
+	Environment string
+}
+
+// Setup installs the W3C trace context propagator and, when cfg.Export is
+// set, a tracer provider batching spans to the collector. The returned
+// shutdown flushes the spans still buffered and is safe to call either way.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !cfg.Export {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: creating the OTLP exporter: %w", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", ServiceName),
+			attribute.String("deployment.environment", cfg.Environment),
+		),
+		// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES win
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: describing the service: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
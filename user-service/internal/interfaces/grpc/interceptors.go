@@ -0,0 +1,90 @@
+package grpc
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"user-service/internal/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader carries the request ID; callers may set it to correlate
+// logs across services, and it is echoed back in the response header
+const RequestIDHeader = "x-request-id"
+
+// RPCObserver receives the duration and status code of each call
+type RPCObserver interface {
+	ObserveRPC(method, code string, duration time.Duration)
+}
+
+type callKey struct{}
+
+// callInfo is filled in by the interceptors of one call as it proceeds
+type callInfo struct {
+	requestID string
+	client    string
+}
+
+// withCall returns the call's info, attaching a fresh one if the context
+// doesn't carry it yet
+func withCall(ctx context.Context) (context.Context, *callInfo) {
+	if call, ok := ctx.Value(callKey{}).(*callInfo); ok {
+		return ctx, call
+	}
+	call := &callInfo{}
+	return context.WithValue(ctx, callKey{}, call), call
+}
+
+// RequestIDFromContext returns the ID LoggingInterceptor assigned the call
+func RequestIDFromContext(ctx context.Context) string {
+	if call, ok := ctx.Value(callKey{}).(*callInfo); ok {
+		return call.requestID
+	}
+	return ""
+}
+
+// LoggingInterceptor assigns every call a request ID and logs one line
+// per call with the authenticated client, status code and duration
+func LoggingInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx, call := withCall(ctx)
+		call.requestID = incomingRequestID(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, call.requestID))
+		// For the service's own log lines
+		ctx = logging.WithRequestID(ctx, call.requestID)
+
+		resp, err := handler(ctx, req)
+
+		client := call.client
+		if client == "" {
+			client = "-"
+		}
+		log.Printf("gRPC %s request_id=%s client=%s code=%s duration=%s",
+			info.FullMethod, call.requestID, client, status.Code(err), time.Since(start))
+		return resp, err
+	}
+}
+
+// MetricsInterceptor reports every call to observer
+func MetricsInterceptor(observer RPCObserver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observer.ObserveRPC(info.FullMethod, status.Code(err).String(), time.Since(start))
+		return resp, err
+	}
+}
+
+// incomingRequestID reuses the caller's request ID or makes a new one
+func incomingRequestID(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if ids := md.Get(RequestIDHeader); len(ids) > 0 && ids[0] != "" && len(ids[0]) <= 64 {
+		return ids[0]
+	}
+	return logging.NewRequestID()
+}
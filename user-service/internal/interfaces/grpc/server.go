@@ -0,0 +1,194 @@
+// Package grpc serves the internal user profile API defined in
+// proto/user/v1 for the cart, order and other backend services.
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strconv"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	userv1 "user-service/proto/user/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// UserReader is the part of application.UserService the API reads from
+type UserReader interface {
+	GetUser(ctx context.Context, id uint) (*domain.User, error)
+	GetUsers(ctx context.Context, ids []uint) (map[uint]*domain.User, error)
+	SearchUsers(ctx context.Context, prefix string, afterID uint, limit int) ([]*domain.User, error)
+}
+
+var _ UserReader = (*application.UserService)(nil)
+
+var _ userv1.UserServiceServer = (*UserServer)(nil)
+
+// UserServer implements userv1.UserServiceServer
+type UserServer struct {
+	userv1.UnimplementedUserServiceServer
+	users UserReader
+}
+
+func NewUserServer(users UserReader) *UserServer {
+	return &UserServer{users: users}
+}
+
+// NewServer returns a server with the user API registered behind the
+// interceptor chain: logging, then metrics, then authentication, so
+// rejected calls are still logged and counted. opts typically carry the
+// transport credentials.
+func NewServer(users UserReader, auth *Authenticator, observer RPCObserver, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(
+		LoggingInterceptor(),
+		MetricsInterceptor(observer),
+		auth.UnaryInterceptor(),
+	))
+	server := grpc.NewServer(opts...)
+	userv1.RegisterUserServiceServer(server, NewUserServer(users))
+	return server
+}
+
+func (s *UserServer) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.GetUserResponse, error) {
+	if req.GetId() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	user, err := s.users.GetUser(ctx, uint(req.GetId()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	// Erased accounts keep their row but are gone as far as callers know
+	if user.Status == domain.StatusErased {
+		return nil, toStatus(domain.ErrUserNotFound)
+	}
+
+	return &userv1.GetUserResponse{User: toSummary(user)}, nil
+}
+
+func (s *UserServer) BatchGetUsers(ctx context.Context, req *userv1.BatchGetUsersRequest) (*userv1.BatchGetUsersResponse, error) {
+	ids := make([]uint, len(req.GetIds()))
+	for i, id := range req.GetIds() {
+		ids[i] = uint(id)
+	}
+
+	users, err := s.users.GetUsers(ctx, ids)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &userv1.BatchGetUsersResponse{}
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		user, ok := users[id]
+		if !ok || user.Status == domain.StatusErased {
+			resp.MissingIds = append(resp.MissingIds, uint64(id))
+			continue
+		}
+		resp.Users = append(resp.Users, toSummary(user))
+	}
+
+	return resp, nil
+}
+
+func (s *UserServer) SearchUsers(ctx context.Context, req *userv1.SearchUsersRequest) (*userv1.SearchUsersResponse, error) {
+	afterID, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+
+	limit := int(req.GetPageSize())
+	if limit <= 0 {
+		limit = application.DefaultSearchPageSize
+	}
+	if limit > application.MaxSearchPageSize {
+		limit = application.MaxSearchPageSize
+	}
+
+	users, err := s.users.SearchUsers(ctx, req.GetQuery(), afterID, limit)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &userv1.SearchUsersResponse{Users: make([]*userv1.UserSummary, len(users))}
+	for i, user := range users {
+		resp.Users[i] = toSummary(user)
+	}
+	// A full page may have more behind it
+	if len(users) == limit {
+		resp.NextPageToken = encodePageToken(users[len(users)-1].ID)
+	}
+
+	return resp, nil
+}
+
+// Page tokens are opaque to callers; they wrap the last ID of the page
+func encodePageToken(lastID uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(lastID), 10)))
+}
+
+func decodePageToken(token string) (uint, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
+
+func toSummary(user *domain.User) *userv1.UserSummary {
+	return &userv1.UserSummary{
+		Id:        uint64(user.ID),
+		Username:  user.Username,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Status:    toStatusEnum(user.Status),
+		CreatedAt: timestamppb.New(user.CreatedAt),
+	}
+}
+
+func toStatusEnum(s domain.UserStatus) userv1.UserStatus {
+	switch s {
+	case domain.StatusActive:
+		return userv1.UserStatus_USER_STATUS_ACTIVE
+	case domain.StatusBanned:
+		return userv1.UserStatus_USER_STATUS_BANNED
+	case domain.StatusPendingDeletion:
+		return userv1.UserStatus_USER_STATUS_PENDING_DELETION
+	}
+	return userv1.UserStatus_USER_STATUS_UNSPECIFIED
+}
+
+// toStatus maps a service error onto a gRPC status. Unexpected errors are
+// logged and reported without detail.
+func toStatus(err error) error {
+	var verr *application.ValidationError
+
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, domain.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.As(err, &verr):
+		return status.Error(codes.InvalidArgument, verr.Error())
+	}
+	log.Printf("gRPC request failed: %v", err)
+	return status.Error(codes.Internal, "internal error")
+}
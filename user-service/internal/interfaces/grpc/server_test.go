@@ -0,0 +1,259 @@
+// internal/interfaces/grpc/server_test.go
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/infrastructure/redis"
+	"user-service/internal/testsupport"
+	userv1 "user-service/proto/user/v1"
+
+	"github.com/alicebob/miniredis/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testAPIKey = "cart-key"
+
+// recordingObserver collects the codes MetricsInterceptor reports
+type recordingObserver struct {
+	mu    sync.Mutex
+	codes []string
+}
+
+func (o *recordingObserver) ObserveRPC(method, code string, duration time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.codes = append(o.codes, code)
+}
+
+func (o *recordingObserver) Codes() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.codes...)
+}
+
+type testEnv struct {
+	client   userv1.UserServiceClient
+	repo     *testsupport.UserRepository
+	cache    *redis.UserCache
+	redis    *miniredis.Miniredis
+	observer *recordingObserver
+}
+
+// newTestEnv serves the API over an in-memory connection, backed by a real
+// UserService with a miniredis cache
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	redisClient, err := redis.NewRedisClient(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("redis: %v", err)
+	}
+	t.Cleanup(func() { redisClient.Close() })
+
+	repo := testsupport.NewUserRepository()
+	cache := redis.NewUserCache(redisClient, time.Minute)
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache)
+	observer := &recordingObserver{}
+	server := NewServer(svc, NewAuthenticator(map[string]string{"cart": testAPIKey}), observer)
+
+	ln := bufconn.Listen(1 << 20)
+	go server.Serve(ln)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &testEnv{
+		client:   userv1.NewUserServiceClient(conn),
+		repo:     repo,
+		cache:    cache,
+		redis:    mr,
+		observer: observer,
+	}
+}
+
+// authed attaches the test API key to ctx
+func authed() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), APIKeyHeader, testAPIKey)
+}
+
+func (e *testEnv) addUser(t *testing.T, username string) *domain.User {
+	t.Helper()
+	u := e.repo.AddUser(username+"@example.com", "secret123")
+	u.Username = username
+	e.repo.Put(u)
+	return u
+}
+
+func TestAuth_RejectsCallsWithoutAValidKey(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.addUser(t, "alice")
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"no key", context.Background(), codes.Unauthenticated},
+		{"wrong key", metadata.AppendToOutgoingContext(context.Background(), APIKeyHeader, "nope"), codes.Unauthenticated},
+		{"valid key", authed(), codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header metadata.MD
+			_, err := env.client.GetUser(tt.ctx, &userv1.GetUserRequest{Id: uint64(user.ID)}, grpc.Header(&header))
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("expected %s, got %s (%v)", tt.want, got, err)
+			}
+			if len(header.Get(RequestIDHeader)) != 1 {
+				t.Errorf("expected a request ID in the response header, got %v", header)
+			}
+		})
+	}
+
+	// Rejected calls are still counted
+	want := []string{"Unauthenticated", "Unauthenticated", "OK"}
+	if got := env.observer.Codes(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected observed codes %v, got %v", want, got)
+	}
+}
+
+func TestBatchGetUsers_UsesOneMGetAndReadsOnlyMissesFromTheDatabase(t *testing.T) {
+	env := newTestEnv(t)
+	alice, bob, carol := env.addUser(t, "alice"), env.addUser(t, "bob"), env.addUser(t, "carol")
+	for _, u := range []*domain.User{alice, bob} {
+		if err := env.cache.Set(context.Background(), u); err != nil {
+			t.Fatalf("prime cache: %v", err)
+		}
+	}
+
+	resp, err := env.client.BatchGetUsers(authed(), &userv1.BatchGetUsersRequest{
+		Ids: []uint64{uint64(carol.ID), uint64(alice.ID), 999, uint64(bob.ID), uint64(alice.ID)},
+	})
+	if err != nil {
+		t.Fatalf("batch get: %v", err)
+	}
+
+	var got []string
+	for _, u := range resp.GetUsers() {
+		got = append(got, u.GetUsername())
+	}
+	if fmt.Sprint(got) != "[carol alice bob]" {
+		t.Errorf("expected users in request order without duplicates, got %v", got)
+	}
+	if fmt.Sprint(resp.GetMissingIds()) != "[999]" {
+		t.Errorf("expected 999 to be missing, got %v", resp.GetMissingIds())
+	}
+	if n := env.repo.Calls("GetByIDs"); n != 1 {
+		t.Errorf("expected one database read for the misses, got %d", n)
+	}
+	if !env.redis.Exists(fmt.Sprintf("user:id:%d", carol.ID)) {
+		t.Error("expected carol to be cached after the database read")
+	}
+
+	// Now all three are cached: one MGET and no database read
+	before := env.redis.CommandCount()
+	resp, err = env.client.BatchGetUsers(authed(), &userv1.BatchGetUsersRequest{
+		Ids: []uint64{uint64(alice.ID), uint64(bob.ID), uint64(carol.ID)},
+	})
+	if err != nil {
+		t.Fatalf("batch get: %v", err)
+	}
+	if len(resp.GetUsers()) != 3 {
+		t.Errorf("expected 3 users, got %d", len(resp.GetUsers()))
+	}
+	if n := env.redis.CommandCount() - before; n != 1 {
+		t.Errorf("expected a single MGET, got %d Redis commands", n)
+	}
+	if n := env.repo.Calls("GetByIDs"); n != 1 {
+		t.Errorf("expected the cached batch to skip the database, got %d reads", n)
+	}
+}
+
+func TestBatchGetUsers_RejectsOversizedBatches(t *testing.T) {
+	env := newTestEnv(t)
+
+	ids := make([]uint64, application.MaxBatchGetUsers+1)
+	for i := range ids {
+		ids[i] = uint64(i + 1)
+	}
+	_, err := env.client.BatchGetUsers(authed(), &userv1.BatchGetUsersRequest{Ids: ids})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestGetUser_HidesErasedAccounts(t *testing.T) {
+	env := newTestEnv(t)
+	alice := env.addUser(t, "alice")
+	erased := env.addUser(t, "gone")
+	erased.Status = domain.StatusErased
+	env.repo.Put(erased)
+
+	resp, err := env.client.GetUser(authed(), &userv1.GetUserRequest{Id: uint64(alice.ID)})
+	if err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	if resp.GetUser().GetUsername() != "alice" || resp.GetUser().GetStatus() != userv1.UserStatus_USER_STATUS_ACTIVE {
+		t.Errorf("unexpected summary %v", resp.GetUser())
+	}
+
+	for _, id := range []uint{erased.ID, 999} {
+		if _, err := env.client.GetUser(authed(), &userv1.GetUserRequest{Id: uint64(id)}); status.Code(err) != codes.NotFound {
+			t.Errorf("expected NotFound for user %d, got %v", id, err)
+		}
+	}
+}
+
+func TestSearchUsers_PagesWithTokens(t *testing.T) {
+	env := newTestEnv(t)
+	for i := 0; i < 3; i++ {
+		env.addUser(t, fmt.Sprintf("alice%d", i))
+	}
+	env.addUser(t, "bob")
+
+	first, err := env.client.SearchUsers(authed(), &userv1.SearchUsersRequest{Query: "ALICE", PageSize: 2})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(first.GetUsers()) != 2 || first.GetNextPageToken() == "" {
+		t.Fatalf("expected a full first page with a token, got %v", first)
+	}
+
+	second, err := env.client.SearchUsers(authed(), &userv1.SearchUsersRequest{
+		Query: "alice", PageSize: 2, PageToken: first.GetNextPageToken(),
+	})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(second.GetUsers()) != 1 || second.GetUsers()[0].GetUsername() != "alice2" || second.GetNextPageToken() != "" {
+		t.Errorf("expected the last page to hold alice2 only, got %v", second)
+	}
+
+	_, err = env.client.SearchUsers(authed(), &userv1.SearchUsersRequest{Query: "alice", PageToken: "!!"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for a bad token, got %v", err)
+	}
+}
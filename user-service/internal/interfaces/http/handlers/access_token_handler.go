@@ -0,0 +1,137 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+)
+
+type createAccessTokenRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+	// Scopes default to user:read
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is optional; without it the token lasts until revoked
+	ExpiresAt *respond.Time `json:"expires_at"`
+}
+
+// AccessTokenResponse describes a personal access token, never its secret
+type AccessTokenResponse struct {
+	ID         uint          `json:"id"`
+	Name       string        `json:"name"`
+	Scopes     []string      `json:"scopes"`
+	ExpiresAt  *respond.Time `json:"expires_at,omitempty"`
+	LastUsedAt *respond.Time `json:"last_used_at"`
+	CreatedAt  respond.Time  `json:"created_at"`
+}
+
+func newAccessTokenResponse(token *domain.AccessToken) AccessTokenResponse {
+	return AccessTokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		Scopes:     token.Scopes,
+		ExpiresAt:  respond.NewTimePtr(token.ExpiresAt),
+		LastUsedAt: respond.NewTimePtr(token.LastUsedAt),
+		CreatedAt:  respond.NewTime(token.CreatedAt),
+	}
+}
+
+// ListAccessTokens serves GET /users/me/tokens, the caller's personal
+// access tokens
+func (h *UserHandler) ListAccessTokens(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := h.service.ListAccessTokens(r.Context(), userID)
+	if err != nil {
+		respond.Error(w, r, "Failed to list access tokens", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]AccessTokenResponse, len(tokens))
+	for i, token := range tokens {
+		resp[i] = newAccessTokenResponse(token)
+	}
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"tokens": resp,
+	})
+}
+
+// CreateAccessToken serves POST /users/me/tokens. The token's secret is
+// shown this once.
+func (h *UserHandler) CreateAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req createAccessTokenRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		if err != nil {
+			respond.Error(w, r, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		writeFieldErrors(w, r, fields)
+		return
+	}
+
+	token := &domain.AccessToken{
+		UserID: userID,
+		Name:   req.Name,
+		Scopes: req.Scopes,
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.Time()
+		token.ExpiresAt = &expiresAt
+	}
+	secret, err := h.service.CreateAccessToken(r.Context(), token)
+	if err != nil {
+		var verr *application.ValidationError
+		if errors.As(err, &verr) {
+			writeFieldErrors(w, r, verr.Fields)
+			return
+		}
+		respond.Error(w, r, "Failed to create access token", http.StatusInternalServerError)
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, map[string]interface{}{
+		"message": "Copy this token now. It won't be shown again.",
+		"token":   secret,
+		"details": newAccessTokenResponse(token),
+	})
+}
+
+// RevokeAccessToken serves DELETE /users/me/tokens/{id}
+func (h *UserHandler) RevokeAccessToken(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil || id == 0 {
+		respond.Error(w, r, "Access token not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.service.RevokeAccessToken(r.Context(), userID, uint(id)); err != nil {
+		if errors.Is(err, domain.ErrAccessTokenNotFound) {
+			respond.Error(w, r, "Access token not found", http.StatusNotFound)
+			return
+		}
+		respond.Error(w, r, "Failed to revoke access token", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,512 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+)
+
+type deletionActionRequest struct {
+	UserID uint   `json:"user_id" validate:"required"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// AdminListUsers lists accounts in any state for the admin tabs.
+// ?status=active,banned limits the listing to those states; without it
+// every account that hasn't been erased is listed, or every account at all
+// with ?include_deleted=true.
+func (h *UserHandler) AdminListUsers(w http.ResponseWriter, r *http.Request) {
+	h.listUsers(w, r, parseStatuses(r.URL.Query().Get("status")), true)
+}
+
+// parseStatuses splits a comma-separated status list, leaving validation
+// to the service
+func parseStatuses(value string) []domain.UserStatus {
+	var statuses []domain.UserStatus
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			statuses = append(statuses, domain.UserStatus(part))
+		}
+	}
+	return statuses
+}
+
+// PendingDeletionResponse is one account in the pending deletions listing
+type PendingDeletionResponse struct {
+	UserID      uint         `json:"user_id"`
+	Email       string       `json:"email"`
+	RequestedAt respond.Time `json:"requested_at"`
+	EraseAfter  respond.Time `json:"erase_after"`
+}
+
+// ListPendingDeletions shows accounts waiting out their erasure grace
+// period, a page at a time
+func (h *UserHandler) ListPendingDeletions(w http.ResponseWriter, r *http.Request) {
+	page, pageSize := parsePage(r)
+	pending, total, err := h.service.ListPendingDeletions(r.Context(), page, pageSize)
+	if err != nil {
+		respond.Error(w, r, "Failed to list pending deletions", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]PendingDeletionResponse, len(pending))
+	for i, p := range pending {
+		items[i] = PendingDeletionResponse{
+			UserID:      p.UserID,
+			Email:       p.Email,
+			RequestedAt: respond.NewTime(p.RequestedAt),
+			EraseAfter:  respond.NewTime(p.EraseAfter),
+		}
+	}
+
+	respond.JSON(w, http.StatusOK, respond.NewPaginated(items, page, pageSize, total))
+}
+
+// CancelDeletion withdraws a user's deletion request, e.g. after they
+// contacted support
+func (h *UserHandler) CancelDeletion(w http.ResponseWriter, r *http.Request) {
+	h.deletionAction(w, r, h.service.CancelDeletion, "Deletion request cancelled")
+}
+
+// ExpediteDeletion erases an account without waiting for the grace period
+func (h *UserHandler) ExpediteDeletion(w http.ResponseWriter, r *http.Request) {
+	h.deletionAction(w, r, h.service.ExpediteDeletion, "User erased")
+}
+
+func (h *UserHandler) deletionAction(
+	w http.ResponseWriter,
+	r *http.Request,
+	action func(ctx context.Context, id uint, actorID uint, reason string) error,
+	message string,
+) {
+	var req deletionActionRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if err := validate.Struct(req); err != nil {
+		writeFieldErrors(w, r, map[string]string{
+			"user_id": "user_id and reason are required",
+		})
+		return
+	}
+
+	// Admin callers are API clients, not users, so the audit actor is the
+	// system and the client name travels with the reason
+	reason := fmt.Sprintf("%s (via %s)", req.Reason, middleware.GetAPIClient(r))
+
+	if err := action(r.Context(), req.UserID, 0, reason); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUserNotFound):
+			respond.Error(w, r, "User not found", http.StatusNotFound)
+		case errors.Is(err, application.ErrDeletionNotPending):
+			respond.WriteError(w, r, http.StatusConflict, "deletion_not_pending", "The user has no pending deletion request.")
+		default:
+			respond.Error(w, r, "Could not process deletion request", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"message": message,
+		"user_id": req.UserID,
+	})
+}
+
+type createInviteRequest struct {
+	// MaxUses defaults to application.DefaultInviteMaxUses
+	MaxUses   int           `json:"max_uses" validate:"gte=0"`
+	ExpiresAt *respond.Time `json:"expires_at"`
+}
+
+type revokeInviteRequest struct {
+	Code   string `json:"code" validate:"required,max=64"`
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// InviteResponse is a minted invite code
+type InviteResponse struct {
+	Code      string        `json:"code"`
+	CreatedBy string        `json:"created_by"`
+	MaxUses   int           `json:"max_uses"`
+	Uses      int           `json:"uses"`
+	ExpiresAt *respond.Time `json:"expires_at,omitempty"`
+	CreatedAt respond.Time  `json:"created_at"`
+}
+
+// CreateInvite mints an invite code for invite-only registration
+func (h *UserHandler) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	var req createInviteRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		writeFieldErrors(w, r, map[string]string{"max_uses": "max_uses must not be negative"})
+		return
+	}
+
+	invite := &domain.Invite{
+		CreatedBy: middleware.GetAPIClient(r),
+		MaxUses:   req.MaxUses,
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.Time()
+		invite.ExpiresAt = &expiresAt
+	}
+	if err := h.service.CreateInvite(r.Context(), invite); err != nil {
+		var verr *application.ValidationError
+		if errors.As(err, &verr) {
+			writeFieldErrors(w, r, verr.Fields)
+			return
+		}
+		respond.Error(w, r, "Could not create invite", http.StatusInternalServerError)
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, map[string]interface{}{
+		"invite": InviteResponse{
+			Code:      invite.Code,
+			CreatedBy: invite.CreatedBy,
+			MaxUses:   invite.MaxUses,
+			Uses:      invite.Uses,
+			ExpiresAt: respond.NewTimePtr(invite.ExpiresAt),
+			CreatedAt: respond.NewTime(invite.CreatedAt),
+		},
+	})
+}
+
+// RevokeInvite stops an invite code from admitting further signups
+func (h *UserHandler) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	var req revokeInviteRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		writeFieldErrors(w, r, map[string]string{"code": "code and reason are required"})
+		return
+	}
+
+	reason := fmt.Sprintf("%s (via %s)", req.Reason, middleware.GetAPIClient(r))
+	if err := h.service.RevokeInvite(r.Context(), req.Code, reason); err != nil {
+		if errors.Is(err, domain.ErrInviteNotFound) {
+			respond.Error(w, r, "Invite not found", http.StatusNotFound)
+			return
+		}
+		respond.Error(w, r, "Could not revoke invite", http.StatusInternalServerError)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Invite revoked",
+		"code":    req.Code,
+	})
+}
+
+// importSnapshotRequest is an exported snapshot as returned by
+// ExportSnapshot, plus whether differing fields may be overwritten
+type importSnapshotRequest struct {
+	application.SignedSnapshot
+	Overwrite bool `json:"overwrite"`
+}
+
+// SnapshotConflictResponse is a field an import would overwrite
+type SnapshotConflictResponse struct {
+	Field    string      `json:"field"`
+	Current  interface{} `json:"current"`
+	Snapshot interface{} `json:"snapshot"`
+	// Overwritable says whether retrying with "overwrite": true resolves it
+	Overwritable bool `json:"overwritable"`
+}
+
+// ExportSnapshot serves GET /admin/users/{id}/snapshot: the account's
+// profile and preferences, signed so ImportSnapshot can tell it wasn't
+// edited. It can be posted to /admin/users/import-snapshot as is.
+func (h *UserHandler) ExportSnapshot(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil || id == 0 {
+		respond.Error(w, r, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	reason := fmt.Sprintf("exported via %s", middleware.GetAPIClient(r))
+	bundle, err := h.service.ExportSnapshot(r.Context(), uint(id), reason)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			respond.Error(w, r, "User not found", http.StatusNotFound)
+			return
+		}
+		respond.Error(w, r, "Could not export user", http.StatusInternalServerError)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, bundle)
+}
+
+// ImportSnapshot serves POST /admin/users/import-snapshot. The account
+// with the snapshot's email is updated, or created if there is none.
+// Fields that would overwrite different values are answered with 409 and
+// nothing is written, unless the body sets "overwrite": true.
+func (h *UserHandler) ImportSnapshot(w http.ResponseWriter, r *http.Request) {
+	var req importSnapshotRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if len(req.Snapshot) == 0 || req.Signature == "" {
+		writeFieldErrors(w, r, map[string]string{"snapshot": "snapshot and signature are required"})
+		return
+	}
+
+	reason := fmt.Sprintf("imported via %s", middleware.GetAPIClient(r))
+	result, err := h.service.ImportSnapshot(r.Context(), &req.SignedSnapshot, req.Overwrite, reason)
+	if err != nil {
+		var verr *application.ValidationError
+		switch {
+		case errors.Is(err, application.ErrInvalidSnapshotSignature):
+			respond.WriteError(w, r, http.StatusBadRequest, "invalid_signature", "The snapshot wasn't signed by this service or was changed after export.")
+		case errors.As(err, &verr):
+			writeFieldErrors(w, r, verr.Fields)
+		default:
+			respond.Error(w, r, "Could not import snapshot", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if len(result.Conflicts) > 0 {
+		conflicts := make([]SnapshotConflictResponse, len(result.Conflicts))
+		for i, c := range result.Conflicts {
+			conflicts[i] = SnapshotConflictResponse{
+				Field:        c.Field,
+				Current:      c.Current,
+				Snapshot:     c.Snapshot,
+				Overwritable: c.Forceable,
+			}
+		}
+		respond.WriteErrorDetails(w, r, http.StatusConflict, "snapshot_conflict",
+			"Nothing was imported. These fields differ from the existing account.",
+			map[string]interface{}{
+				"user_id":   result.UserID,
+				"conflicts": conflicts,
+			})
+		return
+	}
+
+	applied := result.Applied
+	if applied == nil {
+		applied = []string{}
+	}
+	resp := map[string]interface{}{
+		"user_id": result.UserID,
+		"created": result.Created,
+		"applied": applied,
+	}
+	status := http.StatusOK
+	switch {
+	case result.Created:
+		status = http.StatusCreated
+		resp["message"] = "User created. They must reset their password to log in."
+	case len(applied) == 0:
+		resp["message"] = "Nothing to import"
+	default:
+		resp["message"] = "User updated"
+	}
+	respond.JSON(w, status, resp)
+}
+
+type forcePasswordResetRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type bulkForcePasswordResetRequest struct {
+	UserIDs []uint `json:"user_ids" validate:"required,min=1,dive,required"`
+	Reason  string `json:"reason" validate:"required,max=500"`
+}
+
+// ForcePasswordReset serves POST /admin/users/{id}/force-password-reset:
+// the user is signed out and their next login can only choose a new
+// password
+func (h *UserHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil || id == 0 {
+		respond.Error(w, r, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var req forcePasswordResetRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		writeFieldErrors(w, r, map[string]string{"reason": "reason is required"})
+		return
+	}
+
+	reason := fmt.Sprintf("%s (via %s)", req.Reason, middleware.GetAPIClient(r))
+	result, err := h.service.ForcePasswordReset(r.Context(), []uint{uint(id)}, reason, 0)
+	if err != nil {
+		respond.Error(w, r, "Could not force password reset", http.StatusInternalServerError)
+		return
+	}
+	if len(result.NotFound) > 0 {
+		respond.Error(w, r, "User not found", http.StatusNotFound)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"message": "The user must choose a new password at their next login",
+		"user_id": id,
+	})
+}
+
+type restoreUserRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// GetUserForRestore serves GET /admin/users/{id}/restore: the account,
+// soft-deleted or not, so support can check it is the right one
+func (h *UserHandler) GetUserForRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil || id == 0 {
+		respond.Error(w, r, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.service.GetUserUnscoped(r.Context(), uint(id))
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		respond.Error(w, r, "User not found", http.StatusNotFound)
+	case err != nil:
+		respond.Error(w, r, "Failed to get user", http.StatusInternalServerError)
+	default:
+		respond.JSON(w, http.StatusOK, newAccountResponse(user))
+	}
+}
+
+// RestoreUser serves POST /admin/users/{id}/restore, bringing a
+// soft-deleted account back. Restoring an account that isn't deleted is
+// a 409.
+func (h *UserHandler) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil || id == 0 {
+		respond.Error(w, r, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req restoreUserRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		writeFieldErrors(w, r, map[string]string{"reason": "reason is required"})
+		return
+	}
+
+	reason := fmt.Sprintf("%s (via %s)", req.Reason, middleware.GetAPIClient(r))
+	user, err := h.service.RestoreUser(r.Context(), uint(id), reason, 0)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrUserNotFound):
+			respond.Error(w, r, "User not found", http.StatusNotFound)
+		case errors.Is(err, application.ErrUserNotDeleted):
+			respond.WriteError(w, r, http.StatusConflict, "user_not_deleted", "The user is not deleted.")
+		case errors.Is(err, application.ErrUserErased):
+			respond.WriteError(w, r, http.StatusConflict, "user_erased", "The user's personal data was erased when they were deleted, so they can't be restored.")
+		default:
+			respond.Error(w, r, "Could not restore user", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"message": "User restored",
+		"user":    newAccountResponse(user),
+	})
+}
+
+// PurgeUser serves DELETE /admin/users/{id}?confirm=<email>, removing a
+// soft-deleted account for good. confirm must be the account's email, so
+// a mistyped ID can't purge the wrong one. Accounts deleted too recently
+// get a 409 saying when they can be purged.
+func (h *UserHandler) PurgeUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil || id == 0 {
+		respond.Error(w, r, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	confirm := r.URL.Query().Get("confirm")
+	if confirm == "" {
+		writeFieldErrors(w, r, map[string]string{"confirm": "confirm must be the user's email"})
+		return
+	}
+
+	reason := fmt.Sprintf("purge (via %s)", middleware.GetAPIClient(r))
+	err = h.service.PurgeUser(r.Context(), uint(id), confirm, reason, 0)
+	if err != nil {
+		var tooEarly *application.PurgeTooEarlyError
+		switch {
+		case errors.Is(err, domain.ErrUserNotFound):
+			respond.Error(w, r, "User not found", http.StatusNotFound)
+		case errors.Is(err, application.ErrPurgeNotConfirmed):
+			writeFieldErrors(w, r, map[string]string{"confirm": "confirm must be the user's email"})
+		case errors.Is(err, application.ErrUserNotDeleted):
+			respond.WriteError(w, r, http.StatusConflict, "user_not_deleted", "Only deleted users can be purged.")
+		case errors.As(err, &tooEarly):
+			respond.WriteErrorDetails(w, r, http.StatusConflict, "retention_period",
+				"The user was deleted too recently to be purged.",
+				map[string]interface{}{"purge_after": respond.NewTime(tooEarly.PurgeAfter)})
+		default:
+			respond.Error(w, r, "Could not purge user", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"message": "User purged",
+		"user_id": id,
+	})
+}
+
+// BulkForcePasswordReset serves POST /admin/users/force-password-reset,
+// ForcePasswordReset for up to application.MaxForcedResetBatch users.
+// Unknown IDs are listed in not_found rather than failing the request.
+func (h *UserHandler) BulkForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req bulkForcePasswordResetRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		writeFieldErrors(w, r, map[string]string{"user_ids": "user_ids and reason are required"})
+		return
+	}
+
+	reason := fmt.Sprintf("%s (via %s)", req.Reason, middleware.GetAPIClient(r))
+	result, err := h.service.ForcePasswordReset(r.Context(), req.UserIDs, reason, 0)
+	if err != nil {
+		var verr *application.ValidationError
+		if errors.As(err, &verr) {
+			writeFieldErrors(w, r, verr.Fields)
+			return
+		}
+		if result == nil {
+			respond.Error(w, r, "Could not force password reset", http.StatusInternalServerError)
+			return
+		}
+		// Some users were flagged before the failure; say which
+		respond.WriteErrorDetails(w, r, http.StatusInternalServerError, respond.CodeInternal,
+			"Could not force password reset for every user",
+			map[string]interface{}{
+				"reset":     result.Reset,
+				"not_found": result.NotFound,
+			})
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"reset":     result.Reset,
+		"not_found": result.NotFound,
+	})
+}
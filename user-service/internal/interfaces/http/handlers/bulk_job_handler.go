@@ -0,0 +1,116 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/logging"
+)
+
+// maxListedBulkFailures bounds the failures a job status lists; the
+// report has the rest
+const maxListedBulkFailures = 100
+
+type startBulkJobRequest struct {
+	Action  string `json:"action" validate:"required"`
+	UserIDs []uint `json:"user_ids" validate:"required"`
+	Reason  string `json:"reason" validate:"required,max=500"`
+}
+
+// StartBulkJob serves POST /admin/users/bulk, queueing an action on up to
+// application.MaxBulkJobUsers users. It answers 202 at once; the job runs
+// in the background and GET /admin/jobs/{id} follows it.
+func (h *UserHandler) StartBulkJob(w http.ResponseWriter, r *http.Request) {
+	var req startBulkJobRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		writeFieldErrors(w, r, map[string]string{"user_ids": "action, user_ids and reason are required"})
+		return
+	}
+
+	client := middleware.GetAPIClient(r)
+	job := &domain.BulkJob{
+		Action:      domain.BulkAction(req.Action),
+		Reason:      fmt.Sprintf("%s (via %s)", req.Reason, client),
+		RequestedBy: client,
+		UserIDs:     req.UserIDs,
+	}
+	if err := h.service.StartBulkJob(r.Context(), job); err != nil {
+		var verr *application.ValidationError
+		if errors.As(err, &verr) {
+			writeFieldErrors(w, r, verr.Fields)
+			return
+		}
+		respond.Error(w, r, "Could not queue bulk job", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", "/admin/jobs/"+strconv.FormatUint(uint64(job.ID), 10))
+	respond.JSON(w, http.StatusAccepted, map[string]interface{}{
+		"job": bulkJobJSON(job, ""),
+	})
+}
+
+// GetBulkJob serves GET /admin/jobs/{id} with the bulk job's progress and,
+// once it is done, a link to its report
+func (h *UserHandler) GetBulkJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil || id == 0 {
+		respond.Error(w, r, "Job not found", http.StatusNotFound)
+		return
+	}
+	job, err := h.service.GetBulkJob(r.Context(), uint(id))
+	if errors.Is(err, domain.ErrBulkJobNotFound) {
+		respond.Error(w, r, "Job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respond.Error(w, r, "Could not get job", http.StatusInternalServerError)
+		return
+	}
+
+	link, err := h.service.BulkJobReportURL(r.Context(), job)
+	if err != nil {
+		// The progress is still worth showing
+		logging.Printf(r.Context(), "Failed to link the report of bulk job %d: %v", job.ID, err)
+	}
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"job": bulkJobJSON(job, link),
+	})
+}
+
+func bulkJobJSON(job *domain.BulkJob, reportURL string) map[string]interface{} {
+	failures := job.Failures
+	if len(failures) > maxListedBulkFailures {
+		failures = failures[:maxListedBulkFailures]
+	}
+	if failures == nil {
+		failures = []domain.BulkFailure{}
+	}
+	item := map[string]interface{}{
+		"id":           job.ID,
+		"action":       job.Action,
+		"status":       job.Status,
+		"requested_by": job.RequestedBy,
+		"total":        job.Total(),
+		"processed":    job.Processed,
+		"succeeded":    job.Succeeded(),
+		"failed":       len(job.Failures),
+		"failures":     failures,
+		"created_at":   respond.NewTime(job.CreatedAt),
+		"started_at":   respond.NewTimePtr(job.StartedAt),
+		"finished_at":  respond.NewTimePtr(job.FinishedAt),
+	}
+	if reportURL != "" {
+		item["report_url"] = reportURL
+	}
+	return item
+}
@@ -0,0 +1,170 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"user-service/internal/interfaces/http/respond"
+)
+
+// bodyError is a request body decodeJSON refused, with the response that
+// says why
+type bodyError struct {
+	status  int
+	code    string
+	message string
+	fields  map[string]string
+}
+
+func (e *bodyError) Error() string {
+	return e.message
+}
+
+// errEmptyBody is returned for a body with no JSON in it. Handlers whose
+// body is optional treat it as {}.
+var errEmptyBody = &bodyError{
+	status:  http.StatusBadRequest,
+	code:    respond.CodeInvalidJSON,
+	message: "Request body is empty; expected a JSON object",
+}
+
+// decodeJSON decodes exactly one JSON document from body into dst. Fields
+// dst doesn't have are refused rather than dropped, so a typo such as
+// "usernmae" isn't silently ignored. A body over the limit set by
+// middleware.MaxBodyBytes is a 413. Errors are *bodyError.
+func decodeJSON(body io.Reader, dst interface{}) error {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	// Anything but whitespace after the document is a second one
+	if err := dec.Decode(new(json.RawMessage)); !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return decodeError(err)
+		}
+		return &bodyError{
+			status:  http.StatusBadRequest,
+			code:    respond.CodeInvalidJSON,
+			message: fmt.Sprintf("Request body must be a single JSON document; more follows at byte %d", dec.InputOffset()),
+		}
+	}
+	return nil
+}
+
+// decodeError explains err from json.Decoder.Decode
+func decodeError(err error) error {
+	var (
+		syntaxErr  *json.SyntaxError
+		typeErr    *json.UnmarshalTypeError
+		tooLarge   *http.MaxBytesError
+		badRequest = func(message string) *bodyError {
+			return &bodyError{status: http.StatusBadRequest, code: respond.CodeInvalidJSON, message: message}
+		}
+	)
+	switch {
+	case errors.Is(err, io.EOF):
+		return errEmptyBody
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return badRequest("Malformed JSON: the body ends in the middle of a value")
+	case errors.As(err, &syntaxErr):
+		return badRequest(fmt.Sprintf("Malformed JSON at byte %d: %s", syntaxErr.Offset, strings.TrimPrefix(syntaxErr.Error(), "json: ")))
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			return badRequest(fmt.Sprintf("Request body must be %s, not %s", jsonKind(typeErr.Type), typeErr.Value))
+		}
+		return &bodyError{
+			status:  http.StatusBadRequest,
+			code:    respond.CodeValidationFailed,
+			message: fmt.Sprintf("Field %q at byte %d must be %s, not %s", field, typeErr.Offset, jsonKind(typeErr.Type), typeErr.Value),
+			fields:  map[string]string{field: "must be " + jsonKind(typeErr.Type)},
+		}
+	case errors.As(err, &tooLarge):
+		return &bodyError{
+			status:  http.StatusRequestEntityTooLarge,
+			code:    respond.StatusCode(http.StatusRequestEntityTooLarge),
+			message: "Request body must be at most " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes",
+		}
+	}
+	// DisallowUnknownFields has no error type of its own
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field, unquoteErr := strconv.Unquote(name)
+		if unquoteErr != nil {
+			field = name
+		}
+		return &bodyError{
+			status:  http.StatusBadRequest,
+			code:    respond.CodeUnknownField,
+			message: fmt.Sprintf("Unknown field %q", field),
+			fields:  map[string]string{field: "unknown field"},
+		}
+	}
+	// Such as a value a type's UnmarshalJSON refused
+	return badRequest("Invalid request body: " + strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// jsonKind names the JSON value a Go type is decoded from, with an article
+func jsonKind(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a " + t.Kind().String()
+}
+
+// writeBodyError answers with what decodeJSON found wrong with the body
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var bodyErr *bodyError
+	if !errors.As(err, &bodyErr) {
+		respond.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if bodyErr.fields != nil {
+		respond.WriteError(w, r, bodyErr.status, bodyErr.code, bodyErr.message, bodyErr.fields)
+		return
+	}
+	respond.WriteError(w, r, bodyErr.status, bodyErr.code, bodyErr.message)
+}
+
+// decodeBody decodes r's JSON body into dst with decodeJSON, writing the
+// error response itself when it returns false
+func decodeBody(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if err := decodeJSON(r.Body, dst); err != nil {
+		writeBodyError(w, r, err)
+		return false
+	}
+	return true
+}
+
+// decodeOptionalBody is decodeBody for a body that may be left out
+// entirely, leaving dst as it was
+func decodeOptionalBody(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if err := decodeJSON(r.Body, dst); err != nil && !errors.Is(err, errEmptyBody) {
+		writeBodyError(w, r, err)
+		return false
+	}
+	return true
+}
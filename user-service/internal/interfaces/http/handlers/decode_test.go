@@ -0,0 +1,91 @@
+// internal/interfaces/http/handlers/decode_test.go
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"user-service/internal/interfaces/http/respond"
+)
+
+func TestDecodeBody(t *testing.T) {
+	type request struct {
+		Username string `json:"username"`
+		Age      int    `json:"age"`
+		Prefs    struct {
+			Marketing bool `json:"marketing"`
+		} `json:"prefs"`
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		optional    bool
+		wantStatus  int
+		wantCode    string
+		wantField   string
+		wantMessage string
+	}{
+		{name: "valid", body: `{"username": "alice", "age": 30}`},
+		{name: "trailing whitespace", body: "{\"username\": \"alice\"}\n\t "},
+		{name: "empty", body: ``, wantStatus: http.StatusBadRequest, wantCode: respond.CodeInvalidJSON, wantMessage: "empty"},
+		{name: "empty but optional", body: `  `, optional: true},
+		{name: "unknown field", body: `{"usernmae": "alice"}`,
+			wantStatus: http.StatusBadRequest, wantCode: respond.CodeUnknownField, wantField: "usernmae"},
+		{name: "syntax error", body: `{"username": "alice",}`,
+			wantStatus: http.StatusBadRequest, wantCode: respond.CodeInvalidJSON, wantMessage: "at byte 22"},
+		{name: "truncated", body: `{"username": "al`,
+			wantStatus: http.StatusBadRequest, wantCode: respond.CodeInvalidJSON, wantMessage: "ends"},
+		{name: "wrong type", body: `{"age": "thirty"}`,
+			wantStatus: http.StatusBadRequest, wantCode: respond.CodeValidationFailed, wantField: "age", wantMessage: "at byte"},
+		{name: "wrong nested type", body: `{"prefs": {"marketing": "yes"}}`,
+			wantStatus: http.StatusBadRequest, wantCode: respond.CodeValidationFailed, wantField: "prefs.marketing"},
+		{name: "not an object", body: `["alice"]`,
+			wantStatus: http.StatusBadRequest, wantCode: respond.CodeInvalidJSON, wantMessage: "must be an object"},
+		{name: "two documents", body: `{"username": "alice"} {"username": "bob"}`,
+			wantStatus: http.StatusBadRequest, wantCode: respond.CodeInvalidJSON, wantMessage: "single JSON document"},
+		{name: "too large", body: `{"username": "` + strings.Repeat("a", 2048) + `"}`,
+			wantStatus: http.StatusRequestEntityTooLarge, wantCode: "request_entity_too_large", wantMessage: "at most 1024 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			req.Body = http.MaxBytesReader(rec, req.Body, 1024)
+			var dst request
+			decode := decodeBody
+			if tt.optional {
+				decode = decodeOptionalBody
+			}
+
+			ok := decode(rec, req, &dst)
+			if tt.wantStatus == 0 {
+				if !ok {
+					t.Fatalf("expected the body accepted, got %d: %s", rec.Code, rec.Body)
+				}
+				return
+			}
+			if ok || rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %v and %d", tt.wantStatus, ok, rec.Code)
+			}
+
+			var body respond.ErrorBody
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("expected the error envelope: %v", err)
+			}
+			if body.Error.Code != tt.wantCode {
+				t.Errorf("expected code %q, got %+v", tt.wantCode, body.Error)
+			}
+			if tt.wantField != "" && body.Error.Fields[tt.wantField] == "" {
+				t.Errorf("expected field %q named, got %+v", tt.wantField, body.Error)
+			}
+			if !strings.Contains(body.Error.Message, tt.wantMessage) {
+				t.Errorf("expected a message with %q, got %q", tt.wantMessage, body.Error.Message)
+			}
+		})
+	}
+}
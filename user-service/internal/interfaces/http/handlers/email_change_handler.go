@@ -0,0 +1,112 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/normalize"
+)
+
+// ChangeEmailRequest is the body of POST /users/me/email.
+// password isn't needed in a recovery session.
+type ChangeEmailRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password"`
+}
+
+// RequestEmailChange serves POST /users/me/email, the only way to change
+// the account's email. It sends a confirmation link to the new address;
+// the account keeps its email until the link is followed.
+func (h *UserHandler) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req ChangeEmailRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	req.Email = normalize.Email(req.Email)
+	fields, err := validateRequest(req)
+	if err != nil {
+		respond.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	recovered := middleware.IsRecoverySession(r)
+	if !recovered && req.Password == "" {
+		if fields == nil {
+			fields = make(map[string]string)
+		}
+		fields["password"] = "password is required"
+	}
+	if len(fields) > 0 {
+		writeFieldErrors(w, r, fields)
+		return
+	}
+
+	err = h.service.RequestEmailChange(r.Context(), userID, application.EmailChange{
+		Email:     req.Email,
+		Password:  req.Password,
+		Recovered: recovered,
+	})
+	if err != nil {
+		var verr *application.ValidationError
+		switch {
+		case errors.Is(err, application.ErrInvalidCredentials):
+			writeFieldErrors(w, r, map[string]string{"password": "Password is incorrect"})
+		case errors.As(err, &verr) && errors.Is(err, domain.ErrDuplicateUser):
+			respond.WriteError(w, r, http.StatusConflict, respond.CodeAlreadyInUse, "Already in use", verr.Fields)
+		case errors.As(err, &verr):
+			writeFieldErrors(w, r, verr.Fields)
+		case errors.Is(err, application.ErrEmailChangesNotConfigured):
+			respond.Error(w, r, "Email change is not enabled", http.StatusNotFound)
+		case errors.Is(err, domain.ErrUserNotFound):
+			respond.Error(w, r, "User not found", http.StatusNotFound)
+		default:
+			respond.Error(w, r, "Failed to start email change", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respond.JSON(w, http.StatusAccepted, map[string]interface{}{
+		"message": "We've sent a link to the new address. Your email changes once you follow it.",
+	})
+}
+
+// ConfirmEmailChange serves GET /users/confirm-email?token=, the link
+// RequestEmailChange sends. The token is the only credential.
+func (h *UserHandler) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" || len(token) > 128 {
+		writeFieldErrors(w, r, map[string]string{"token": "token is required"})
+		return
+	}
+
+	_, err := h.service.ConfirmEmailChange(r.Context(), token)
+	if err != nil {
+		var verr *application.ValidationError
+		switch {
+		case errors.Is(err, application.ErrInvalidEmailChangeToken):
+			respond.WriteError(w, r, http.StatusBadRequest, "invalid_email_change_token", "This link is invalid or has expired. Ask for a new one.")
+		case errors.As(err, &verr) && errors.Is(err, domain.ErrDuplicateUser):
+			respond.WriteError(w, r, http.StatusConflict, respond.CodeAlreadyInUse, "Already in use", verr.Fields)
+		case errors.As(err, &verr):
+			writeFieldErrors(w, r, verr.Fields)
+		case errors.Is(err, application.ErrEmailChangesNotConfigured):
+			respond.Error(w, r, "Email change is not enabled", http.StatusNotFound)
+		default:
+			respond.Error(w, r, "Failed to change email", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// The link is unauthenticated, so the account stays out of the answer
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Email changed",
+	})
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/logging"
+)
+
+// EndpointSwitchPath is where the kill switches are managed; it can't be
+// switched off itself
+const EndpointSwitchPath = "/admin/endpoints/disable"
+
+// MaxEndpointDisableTTL caps how long a timed disable may last
+const MaxEndpointDisableTTL = 7 * 24 * time.Hour
+
+// EndpointsHandler manages the per-route kill switches
+type EndpointsHandler struct {
+	switches *middleware.EndpointSwitches
+	// routePattern resolves a path to the pattern the router serves it with
+	routePattern func(path string) (string, bool)
+}
+
+func NewEndpointsHandler(switches *middleware.EndpointSwitches, routePattern func(path string) (string, bool)) *EndpointsHandler {
+	return &EndpointsHandler{switches: switches, routePattern: routePattern}
+}
+
+type disableEndpointRequest struct {
+	Pattern    string `json:"pattern" validate:"required"`
+	Message    string `json:"message" validate:"max=200"`
+	TTLSeconds int    `json:"ttl_seconds" validate:"min=0"`
+}
+
+// List serves GET /admin/endpoints/disable, the disabled routes
+func (h *EndpointsHandler) List(w http.ResponseWriter, r *http.Request) {
+	disabled, err := h.switches.List(r.Context())
+	if err != nil {
+		respond.Error(w, r, "Could not list disabled endpoints", http.StatusInternalServerError)
+		return
+	}
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"disabled": disabled,
+	})
+}
+
+// Disable serves POST /admin/endpoints/disable, turning a route off
+func (h *EndpointsHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	var req disableEndpointRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		writeFieldErrors(w, r, fields)
+		return
+	}
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl > MaxEndpointDisableTTL {
+		writeFieldErrors(w, r, map[string]string{
+			"ttl_seconds": fmt.Sprintf("at most %d", int(MaxEndpointDisableTTL.Seconds())),
+		})
+		return
+	}
+	pattern, problem := h.canonical(req.Pattern)
+	if problem != "" {
+		writeFieldErrors(w, r, map[string]string{"pattern": problem})
+		return
+	}
+
+	client := middleware.GetAPIClient(r)
+	d, err := h.switches.Disable(r.Context(), pattern, req.Message, client, ttl)
+	if err != nil {
+		respond.Error(w, r, "Could not disable endpoint", http.StatusInternalServerError)
+		return
+	}
+	logging.Printf(r.Context(), "Endpoint %s disabled by %s (ttl %s)", pattern, client, ttl)
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"pattern":  pattern,
+		"disabled": d,
+	})
+}
+
+// Enable serves DELETE /admin/endpoints/disable?pattern=, turning a route
+// back on
+func (h *EndpointsHandler) Enable(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("pattern")
+	if pattern == "" {
+		writeFieldErrors(w, r, map[string]string{"pattern": "pattern is required"})
+		return
+	}
+	// Only exact patterns are stored, but a route that has since been
+	// removed must still be enableable, so no resolution here
+	if err := h.switches.Enable(r.Context(), pattern); err != nil {
+		respond.Error(w, r, "Could not enable endpoint", http.StatusInternalServerError)
+		return
+	}
+	logging.Printf(r.Context(), "Endpoint %s enabled by %s", pattern, middleware.GetAPIClient(r))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// canonical accepts only patterns exactly as the router registered them,
+// so a switch is never stored under a spelling the router won't report
+func (h *EndpointsHandler) canonical(pattern string) (string, string) {
+	resolved, ok := h.routePattern(pattern)
+	switch {
+	case !ok:
+		return "", "no route matches this pattern"
+	case resolved != pattern:
+		return "", fmt.Sprintf("not a route pattern, did you mean %q?", resolved)
+	case resolved == EndpointSwitchPath:
+		return "", "the kill switch endpoint can't be disabled"
+	}
+	return resolved, ""
+}
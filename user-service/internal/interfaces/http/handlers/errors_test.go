@@ -0,0 +1,188 @@
+// internal/interfaces/http/handlers/errors_test.go
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/testsupport"
+)
+
+// errorCode is the code of a decoded error response, "" for any other body
+func errorCode(body map[string]interface{}) string {
+	detail, _ := body["error"].(map[string]interface{})
+	code, _ := detail["code"].(string)
+	return code
+}
+
+// Clients switch on these codes, so they must not change
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    func(h *UserHandler) http.HandlerFunc
+		method     string
+		body       string
+		userID     uint
+		svc        *testsupport.MockUserService
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "register validation",
+			handler:    func(h *UserHandler) http.HandlerFunc { return h.Register },
+			method:     http.MethodPost,
+			body:       `{"username":"al","email":"not-an-email","password":"123"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   respond.CodeValidationFailed,
+		},
+		{
+			name:    "register taken email",
+			handler: func(h *UserHandler) http.HandlerFunc { return h.Register },
+			method:  http.MethodPost,
+			body:    `{"username":"alice","email":"alice@example.com","password":"secret123"}`,
+			svc: &testsupport.MockUserService{
+				RegisterFn: func(ctx context.Context, user *domain.User, password, inviteCode string) (bool, error) {
+					return false, domain.ErrDuplicateUser
+				},
+			},
+			wantStatus: http.StatusConflict,
+			wantCode:   respond.CodeEmailTaken,
+		},
+		{
+			name:       "register malformed body",
+			handler:    func(h *UserHandler) http.HandlerFunc { return h.Register },
+			method:     http.MethodPost,
+			body:       `{`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   respond.CodeInvalidJSON,
+		},
+		{
+			name:       "register unknown field",
+			handler:    func(h *UserHandler) http.HandlerFunc { return h.Register },
+			method:     http.MethodPost,
+			body:       `{"usernmae": "alice"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   respond.CodeUnknownField,
+		},
+		{
+			name:    "login wrong password",
+			handler: func(h *UserHandler) http.HandlerFunc { return h.Login },
+			method:  http.MethodPost,
+			body:    `{"email":"alice@example.com","password":"wrong-password"}`,
+			svc: &testsupport.MockUserService{
+				LoginFn: func(ctx context.Context, email, password string) (*domain.User, error) {
+					return nil, application.ErrInvalidCredentials
+				},
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   respond.CodeInvalidCredentials,
+		},
+		{
+			name:    "login banned",
+			handler: func(h *UserHandler) http.HandlerFunc { return h.Login },
+			method:  http.MethodPost,
+			body:    `{"email":"alice@example.com","password":"secret123"}`,
+			svc: &testsupport.MockUserService{
+				LoginFn: func(ctx context.Context, email, password string) (*domain.User, error) {
+					return nil, application.ErrUserBanned
+				},
+			},
+			wantStatus: http.StatusForbidden,
+			wantCode:   "account_banned",
+		},
+		{
+			name:    "current user gone",
+			handler: func(h *UserHandler) http.HandlerFunc { return h.GetCurrentUser },
+			method:  http.MethodGet,
+			userID:  7,
+			svc: &testsupport.MockUserService{
+				GetUserFn: func(ctx context.Context, id uint) (*domain.User, error) {
+					return nil, domain.ErrUserNotFound
+				},
+			},
+			wantStatus: http.StatusNotFound,
+			wantCode:   respond.CodeNotFound,
+		},
+		{
+			name:       "current user without a token",
+			handler:    func(h *UserHandler) http.HandlerFunc { return h.GetCurrentUser },
+			method:     http.MethodGet,
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   respond.CodeUnauthorized,
+		},
+		{
+			name:    "update to a taken username",
+			handler: func(h *UserHandler) http.HandlerFunc { return h.UpdateUser },
+			method:  http.MethodPut,
+			body:    `{"username":"bob"}`,
+			userID:  7,
+			svc: &testsupport.MockUserService{
+				UpdateProfileFn: func(ctx context.Context, id uint, fields map[string]interface{}) (*domain.User, []string, error) {
+					return nil, nil, &application.ValidationError{
+						Fields: map[string]string{"username": "Username already taken"},
+						Err:    domain.ErrDuplicateUser,
+					}
+				},
+			},
+			wantStatus: http.StatusConflict,
+			wantCode:   respond.CodeAlreadyInUse,
+		},
+		{
+			name:    "delete with the wrong password",
+			handler: func(h *UserHandler) http.HandlerFunc { return h.DeleteUser },
+			method:  http.MethodDelete,
+			body:    `{"password":"wrong-password"}`,
+			userID:  7,
+			svc: &testsupport.MockUserService{
+				DeleteUserWithPasswordFn: func(ctx context.Context, id uint, password string) error {
+					return application.ErrInvalidCredentials
+				},
+			},
+			wantStatus: http.StatusForbidden,
+			wantCode:   "invalid_password",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := tt.svc
+			if svc == nil {
+				svc = &testsupport.MockUserService{}
+			}
+			h := NewUserHandler(svc, auth.NewJWTManager("test-secret", time.Hour))
+
+			req := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body))
+			var handler http.Handler = tt.handler(h)
+			if tt.userID != 0 {
+				token, err := h.jwtManager.GenerateToken(tt.userID)
+				if err != nil {
+					t.Fatalf("generate token: %v", err)
+				}
+				req.Header.Set("Authorization", "Bearer "+token)
+				handler = middleware.AuthMiddleware(h.jwtManager)(handler)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body)
+			}
+			var body respond.ErrorBody
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode %q: %v", rr.Body, err)
+			}
+			if body.Error.Code != tt.wantCode || body.Error.Message == "" {
+				t.Errorf("expected code %q with a message, got %+v", tt.wantCode, body.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"user-service/internal/infrastructure/storage"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/logging"
+)
+
+// FilesPrefix is where locally stored files are downloaded from, through
+// links from Storage.SignedURL
+const FilesPrefix = "/files"
+
+// FilesHandler serves stored files. It does no auth of its own: mount it
+// behind middleware.RequireSignedURL.
+type FilesHandler struct {
+	store storage.Storage
+}
+
+func NewFilesHandler(store storage.Storage) *FilesHandler {
+	return &FilesHandler{store: store}
+}
+
+// Download serves GET /files/{key...}. Files are sent as attachments that
+// browsers mustn't sniff, so an uploaded file can't run as a page here.
+func (h *FilesHandler) Download(w http.ResponseWriter, r *http.Request) {
+	body, obj, err := h.store.Get(r.Context(), r.PathValue("key"))
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		respond.Error(w, r, "File not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respond.Error(w, r, "Could not read file", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", obj.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	w.Header().Set("Content-Disposition", "attachment")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Links are personal and short-lived; shared caches mustn't keep them
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		logging.Printf(r.Context(), "Download of %s interrupted: %v", obj.Key, err)
+	}
+}
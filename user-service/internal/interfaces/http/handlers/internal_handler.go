@@ -0,0 +1,35 @@
+package http
+
+import (
+	"net/http"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/normalize"
+)
+
+type emailLookupQuery struct {
+	Email string `validate:"required,email"`
+}
+
+// LookupByEmail lets internal services (checkout) ask whether an account
+// exists for an email. Only mounted behind APIKeyAuth.
+func (h *UserHandler) LookupByEmail(w http.ResponseWriter, r *http.Request) {
+	// Normalize exactly like registration before validating the syntax
+	query := emailLookupQuery{Email: normalize.Email(r.URL.Query().Get("email"))}
+	if err := validate.Struct(query); err != nil {
+		writeFieldErrors(w, r, map[string]string{"email": "Invalid email format"})
+		return
+	}
+
+	result, err := h.service.LookupByEmail(r.Context(), query.Email, middleware.GetAPIClient(r))
+	if err != nil {
+		respond.Error(w, r, "Could not look up email", http.StatusInternalServerError)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"exists":         result.Exists,
+		"user_id":        result.UserID,
+		"email_verified": result.EmailVerified,
+	})
+}
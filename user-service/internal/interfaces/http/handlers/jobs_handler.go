@@ -0,0 +1,83 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/jobs"
+)
+
+type JobsHandler struct {
+	scheduler *jobs.Scheduler
+}
+
+func NewJobsHandler(scheduler *jobs.Scheduler) *JobsHandler {
+	return &JobsHandler{scheduler: scheduler}
+}
+
+// List serves GET /admin/jobs with each job's schedule and last run
+func (h *JobsHandler) List(w http.ResponseWriter, r *http.Request) {
+	statuses := h.scheduler.Statuses()
+	items := make([]map[string]interface{}, len(statuses))
+	for i, status := range statuses {
+		items[i] = jobStatusJSON(status)
+	}
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"jobs": items,
+	})
+}
+
+// Run serves POST /admin/jobs/{name}/run, running the job now and waiting
+// for it to finish
+func (h *JobsHandler) Run(w http.ResponseWriter, r *http.Request) {
+	status, err := h.scheduler.Run(r.Context(), r.PathValue("name"))
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		respond.Error(w, r, "Job not found", http.StatusNotFound)
+	case errors.Is(err, jobs.ErrJobRunning), errors.Is(err, jobs.ErrLockHeld):
+		respond.Error(w, r, err.Error(), http.StatusConflict)
+	case errors.Is(err, jobs.ErrClosed):
+		respond.Error(w, r, "Shutting down", http.StatusServiceUnavailable)
+	case err != nil:
+		// The job ran and failed; its status carries the error
+		respond.WriteErrorDetails(w, r, http.StatusInternalServerError, "job_failed", "The job failed",
+			map[string]interface{}{"job": jobStatusJSON(status)})
+	default:
+		respond.JSON(w, http.StatusOK, map[string]interface{}{
+			"job": jobStatusJSON(status),
+		})
+	}
+}
+
+func jobStatusJSON(status jobs.Status) map[string]interface{} {
+	item := map[string]interface{}{
+		"name":             status.Name,
+		"interval":         status.Interval.String(),
+		"running":          status.Running,
+		"last_run":         respond.NewTimePtr(status.LastRun),
+		"last_duration_ms": status.LastDuration.Milliseconds(),
+		"last_error":       status.LastError,
+		"runs":             status.Runs,
+		"failures":         status.Failures,
+	}
+	if !status.NextRun.IsZero() {
+		item["next_run"] = respond.NewTime(status.NextRun)
+	}
+	return item
+}
+
+// OverviewSection reports every job's status in the admin overview
+func (h *JobsHandler) OverviewSection() OverviewSection {
+	return OverviewSection{
+		Name: "jobs",
+		Load: func(ctx context.Context) (interface{}, error) {
+			statuses := h.scheduler.Statuses()
+			items := make([]map[string]interface{}, len(statuses))
+			for i, status := range statuses {
+				items[i] = jobStatusJSON(status)
+			}
+			return items, nil
+		},
+	}
+}
@@ -0,0 +1,149 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+)
+
+// NoticeResponse is a notice as GET /users/me lists it
+type NoticeResponse struct {
+	Code        string                `json:"code"`
+	Severity    domain.NoticeSeverity `json:"severity"`
+	Message     string                `json:"message"`
+	Dismissible bool                  `json:"dismissible"`
+	Version     string                `json:"version,omitempty"`
+	ExpiresAt   *respond.Time         `json:"expires_at,omitempty"`
+}
+
+func newNoticeResponses(notices []domain.Notice) []NoticeResponse {
+	resp := make([]NoticeResponse, len(notices))
+	for i, notice := range notices {
+		resp[i] = NoticeResponse{
+			Code:        notice.Code,
+			Severity:    notice.Severity,
+			Message:     notice.Message,
+			Dismissible: notice.Dismissible,
+			Version:     notice.Version,
+			ExpiresAt:   respond.NewTimePtr(notice.ExpiresAt),
+		}
+	}
+	return resp
+}
+
+// DismissNotice serves POST /users/me/notices/{code}/dismiss
+func (h *UserHandler) DismissNotice(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.service.DismissNotice(r.Context(), userID, r.PathValue("code"))
+	switch {
+	case errors.Is(err, domain.ErrNoticeNotFound):
+		respond.Error(w, r, "Notice not found", http.StatusNotFound)
+	case errors.Is(err, domain.ErrNoticeNotDismissible):
+		respond.WriteError(w, r, http.StatusConflict, "notice_not_dismissible", "This notice stays until the account issue behind it is resolved.")
+	case errors.Is(err, application.ErrNoticesNotConfigured):
+		respond.Error(w, r, "Notices are not enabled", http.StatusNotFound)
+	case err != nil:
+		respond.Error(w, r, "Could not dismiss notice", http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type addNoticeRequest struct {
+	Code string `json:"code" validate:"required"`
+	// Severity defaults to info
+	Severity    string        `json:"severity"`
+	Message     string        `json:"message" validate:"required"`
+	Dismissible bool          `json:"dismissible"`
+	ExpiresAt   *respond.Time `json:"expires_at"`
+}
+
+// AddNotice serves POST /admin/users/{id}/notices, showing the user a
+// notice until it expires, is dismissed or is removed. A notice with the
+// same code is replaced.
+func (h *UserHandler) AddNotice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil || id == 0 {
+		respond.Error(w, r, "User not found", http.StatusNotFound)
+		return
+	}
+	var req addNoticeRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		writeFieldErrors(w, r, map[string]string{"code": "code and message are required"})
+		return
+	}
+
+	notice := &domain.UserNotice{
+		UserID:      uint(id),
+		Code:        req.Code,
+		Severity:    domain.NoticeSeverity(req.Severity),
+		Message:     req.Message,
+		Dismissible: req.Dismissible,
+		CreatedBy:   middleware.GetAPIClient(r),
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.Time()
+		notice.ExpiresAt = &expiresAt
+	}
+	if err := h.service.AddNotice(r.Context(), notice); err != nil {
+		var verr *application.ValidationError
+		switch {
+		case errors.As(err, &verr):
+			writeFieldErrors(w, r, verr.Fields)
+		case errors.Is(err, domain.ErrUserNotFound):
+			respond.Error(w, r, "User not found", http.StatusNotFound)
+		case errors.Is(err, application.ErrNoticesNotConfigured):
+			respond.Error(w, r, "Notices are not enabled", http.StatusNotFound)
+		default:
+			respond.Error(w, r, "Could not add notice", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, map[string]interface{}{
+		"notice": map[string]interface{}{
+			"id":          notice.ID,
+			"user_id":     notice.UserID,
+			"code":        notice.Code,
+			"severity":    notice.Severity,
+			"message":     notice.Message,
+			"dismissible": notice.Dismissible,
+			"expires_at":  respond.NewTimePtr(notice.ExpiresAt),
+			"created_by":  notice.CreatedBy,
+			"created_at":  respond.NewTime(notice.CreatedAt),
+		},
+	})
+}
+
+// RemoveNotice serves DELETE /admin/users/{id}/notices/{code}
+func (h *UserHandler) RemoveNotice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil || id == 0 {
+		respond.Error(w, r, "Notice not found", http.StatusNotFound)
+		return
+	}
+
+	err = h.service.RemoveNotice(r.Context(), uint(id), r.PathValue("code"), middleware.GetAPIClient(r))
+	switch {
+	case errors.Is(err, domain.ErrNoticeNotFound):
+		respond.Error(w, r, "Notice not found", http.StatusNotFound)
+	case errors.Is(err, application.ErrNoticesNotConfigured):
+		respond.Error(w, r, "Notices are not enabled", http.StatusNotFound)
+	case err != nil:
+		respond.Error(w, r, "Could not remove notice", http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,89 @@
+// internal/interfaces/http/handlers/notice_handler_test.go
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/testsupport"
+)
+
+func TestDismissNotice(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"dismissed", nil, http.StatusNoContent},
+		{"no such notice", domain.ErrNoticeNotFound, http.StatusNotFound},
+		{"must be acted on", domain.ErrNoticeNotDismissible, http.StatusConflict},
+		{"not enabled", application.ErrNoticesNotConfigured, http.StatusNotFound},
+		{"store down", errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotCode string
+			svc := &testsupport.MockUserService{
+				DismissNoticeFn: func(ctx context.Context, userID uint, code string) error {
+					gotCode = code
+					return tt.err
+				},
+			}
+			h := newTestHandler(svc)
+			token, err := h.jwtManager.GenerateToken(7)
+			if err != nil {
+				t.Fatalf("generate token: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/users/me/notices/terms_updated/dismiss", nil)
+			req.SetPathValue("code", "terms_updated")
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+			middleware.AuthMiddleware(h.jwtManager)(http.HandlerFunc(h.DismissNotice)).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if gotCode != "terms_updated" {
+				t.Errorf("expected the code from the path, got %q", gotCode)
+			}
+		})
+	}
+}
+
+func TestGetCurrentUser_ListsNotices(t *testing.T) {
+	user := &domain.User{ID: 7, Email: "alice@example.com", Status: domain.StatusActive}
+	svc := &testsupport.MockUserService{
+		GetUserFn: func(ctx context.Context, id uint) (*domain.User, error) { return user, nil },
+		NoticesFn: func(ctx context.Context, u *domain.User) ([]domain.Notice, error) {
+			return []domain.Notice{{Code: domain.NoticeVerifyEmail, Severity: domain.NoticeWarning, Message: "Verify", Dismissible: true}}, nil
+		},
+	}
+	h := newTestHandler(svc)
+	token, err := h.jwtManager.GenerateToken(7)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	middleware.AuthMiddleware(h.jwtManager)(http.HandlerFunc(h.GetCurrentUser)).ServeHTTP(rr, req)
+
+	var body struct {
+		Notices []NoticeResponse
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", rr.Code, rr.Body.String())
+	}
+	if len(body.Notices) != 1 || body.Notices[0].Code != domain.NoticeVerifyEmail || !body.Notices[0].Dismissible {
+		t.Errorf("unexpected notices %+v", body.Notices)
+	}
+}
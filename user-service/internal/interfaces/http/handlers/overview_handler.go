@@ -0,0 +1,156 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/logging"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// DefaultOverviewBudget bounds how long the overview waits on its
+	// slowest section
+	DefaultOverviewBudget = 2 * time.Second
+	// DefaultOverviewCacheTTL keeps a status page polling every few
+	// seconds from recomputing every section
+	DefaultOverviewCacheTTL = 10 * time.Second
+
+	overviewCacheKey = "admin:overview"
+	// overviewUnavailable replaces a section that failed or ran out of time
+	overviewUnavailable = "unavailable"
+)
+
+// OverviewSection is one part of the admin overview. Load must return
+// once ctx is done.
+type OverviewSection struct {
+	Name string
+	Load func(ctx context.Context) (interface{}, error)
+}
+
+// OverviewCache stores the assembled overview as JSON
+type OverviewCache interface {
+	Get(ctx context.Context, key string, dest interface{}) error
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+}
+
+// OverviewOption configures an OverviewHandler
+type OverviewOption func(*OverviewHandler)
+
+// WithOverviewCache serves the overview from cache for ttl
+func WithOverviewCache(cache OverviewCache, ttl time.Duration) OverviewOption {
+	return func(h *OverviewHandler) {
+		h.cache = cache
+		h.cacheTTL = ttl
+	}
+}
+
+// WithOverviewBudget replaces DefaultOverviewBudget
+func WithOverviewBudget(d time.Duration) OverviewOption {
+	return func(h *OverviewHandler) {
+		h.budget = d
+	}
+}
+
+type OverviewHandler struct {
+	sections []OverviewSection
+	budget   time.Duration
+	cache    OverviewCache
+	cacheTTL time.Duration
+}
+
+func NewOverviewHandler(sections []OverviewSection, opts ...OverviewOption) *OverviewHandler {
+	h := &OverviewHandler{
+		sections: sections,
+		budget:   DefaultOverviewBudget,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Overview serves GET /admin/overview. Sections load concurrently; one
+// that fails or overruns the budget reads "unavailable" instead of failing
+// the response.
+func (h *OverviewHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if h.cache != nil {
+		var cached map[string]interface{}
+		if err := h.cache.Get(ctx, overviewCacheKey, &cached); err == nil && cached != nil {
+			respond.JSON(w, http.StatusOK, cached)
+			return
+		}
+	}
+
+	overview := h.assemble(ctx)
+
+	// Only a complete overview is cached, so a blip isn't served for the
+	// whole TTL
+	if h.cache != nil && complete(overview) {
+		if err := h.cache.Set(ctx, overviewCacheKey, overview, h.cacheTTL); err != nil {
+			logging.Printf(ctx, "Failed to cache admin overview: %v", err)
+		}
+	}
+
+	respond.JSON(w, http.StatusOK, overview)
+}
+
+func (h *OverviewHandler) assemble(ctx context.Context) map[string]interface{} {
+	ctx, cancel := context.WithTimeout(ctx, h.budget)
+	defer cancel()
+
+	results := make([]interface{}, len(h.sections))
+	var g errgroup.Group
+	for i, section := range h.sections {
+		g.Go(func() error {
+			results[i] = loadSection(ctx, section)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	overview := make(map[string]interface{}, len(h.sections)+1)
+	for i, section := range h.sections {
+		overview[section.Name] = results[i]
+	}
+	overview["generated_at"] = respond.NewTime(time.Now())
+	return overview
+}
+
+// loadSection stops waiting on Load once ctx is done, so a source that
+// ignores its context can't hold up the response
+func loadSection(ctx context.Context, section OverviewSection) interface{} {
+	type result struct {
+		value interface{}
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := section.Load(ctx)
+		done <- result{value, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			logging.Printf(ctx, "Admin overview section %s failed: %v", section.Name, res.err)
+			return overviewUnavailable
+		}
+		return res.value
+	case <-ctx.Done():
+		logging.Printf(ctx, "Admin overview section %s timed out", section.Name)
+		return overviewUnavailable
+	}
+}
+
+func complete(overview map[string]interface{}) bool {
+	for _, value := range overview {
+		if value == overviewUnavailable {
+			return false
+		}
+	}
+	return true
+}
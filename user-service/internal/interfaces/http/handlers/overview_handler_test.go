@@ -0,0 +1,124 @@
+// internal/interfaces/http/handlers/overview_handler_test.go
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/interfaces/http/router"
+)
+
+type memoryOverviewCache struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (c *memoryOverviewCache) Get(ctx context.Context, key string, dest interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.values[key]
+	if !ok {
+		return errors.New("miss")
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func (c *memoryOverviewCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values == nil {
+		c.values = make(map[string][]byte)
+	}
+	c.values[key] = data
+	return nil
+}
+
+func getOverview(t *testing.T, h *OverviewHandler) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.Overview(rec, httptest.NewRequest(http.MethodGet, "/admin/overview", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return body
+}
+
+func TestOverview_SectionsDegradeWithinBudget(t *testing.T) {
+	stuck := make(chan struct{})
+	defer close(stuck)
+
+	cache := &memoryOverviewCache{}
+	h := NewOverviewHandler([]OverviewSection{
+		{Name: "ok", Load: func(ctx context.Context) (interface{}, error) {
+			return map[string]int{"count": 3}, nil
+		}},
+		{Name: "failing", Load: func(ctx context.Context) (interface{}, error) {
+			return nil, errors.New("boom")
+		}},
+		// Ignores its context; the handler must stop waiting anyway
+		{Name: "stuck", Load: func(ctx context.Context) (interface{}, error) {
+			<-stuck
+			return "late", nil
+		}},
+	}, WithOverviewBudget(50*time.Millisecond), WithOverviewCache(cache, time.Minute))
+
+	start := time.Now()
+	body := getOverview(t, h)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("overview took %v, past its budget", elapsed)
+	}
+
+	ok, _ := body["ok"].(map[string]interface{})
+	if ok["count"] != float64(3) {
+		t.Errorf("unexpected ok section %v", body["ok"])
+	}
+	if body["failing"] != overviewUnavailable || body["stuck"] != overviewUnavailable {
+		t.Errorf("expected failed sections to be unavailable, got %v and %v", body["failing"], body["stuck"])
+	}
+	if body["generated_at"] == nil {
+		t.Error("expected generated_at")
+	}
+	// A partial overview isn't worth serving to the next caller
+	if len(cache.values) != 0 {
+		t.Error("expected a degraded overview not to be cached")
+	}
+}
+
+func TestOverview_ServesCompleteOverviewFromCache(t *testing.T) {
+	loads := 0
+	cache := &memoryOverviewCache{}
+	h := NewOverviewHandler([]OverviewSection{
+		{Name: "users", Load: func(ctx context.Context) (interface{}, error) {
+			loads++
+			return loads, nil
+		}},
+	}, WithOverviewCache(cache, time.Minute))
+
+	first := getOverview(t, h)
+	second := getOverview(t, h)
+	if loads != 1 || first["users"] != float64(1) || second["users"] != float64(1) {
+		t.Errorf("expected the second overview from cache, loaded %d times: %v then %v", loads, first, second)
+	}
+
+	rt := router.New()
+	rt.Group().HandleFunc("GET /admin/overview", h.Overview)
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/overview", nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("expected 405 allowing GET for POST, got %d %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"unicode/utf8"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/normalize"
+)
+
+// MergePatchContentType is the media type of an RFC 7396 JSON Merge Patch
+const MergePatchContentType = "application/merge-patch+json"
+
+// immutablePatchFields are account fields PATCH /users/me refuses, with
+// where to change them instead. PUT /users/update refuses the email too.
+var immutablePatchFields = map[string]string{
+	"id":       "id can't be changed",
+	"email":    "email can't be changed here; use POST /users/me/email, which verifies the new address",
+	"password": "password can't be patched; use PUT /users/me/password",
+}
+
+// profilePatch holds the values a patch sets. Names may be cleared; the
+// username may not.
+type profilePatch struct {
+	FirstName string
+	LastName  string
+	Username  string
+}
+
+// PatchCurrentUser serves PATCH /users/me with JSON Merge Patch semantics:
+// absent fields are left alone, null clears a field and a value replaces
+// it. Unlike PUT /users/update, an empty string is a value.
+func (h *UserHandler) PatchCurrentUser(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != MergePatchContentType && mediaType != "application/json") {
+			respond.Error(w, r, "Content-Type must be "+MergePatchContentType, http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	// A patch that isn't an object would replace the whole account
+	var patch map[string]json.RawMessage
+	if !decodeBody(w, r, &patch) {
+		return
+	}
+	if patch == nil {
+		respond.Error(w, r, "Patch must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	values, set, fields := parseProfilePatch(patch)
+	if len(fields) > 0 {
+		writeFieldErrors(w, r, fields)
+		return
+	}
+
+	update := make(map[string]interface{})
+	for name, value := range map[string]string{
+		"first_name": values.FirstName,
+		"last_name":  values.LastName,
+		"username":   values.Username,
+	} {
+		if set[name] {
+			update[name] = value
+		}
+	}
+
+	user, changed, err := h.service.UpdateProfile(r.Context(), userID, update)
+	if err != nil {
+		var verr *application.ValidationError
+		switch {
+		case errors.As(err, &verr) && errors.Is(err, domain.ErrDuplicateUser):
+			respond.WriteError(w, r, http.StatusConflict, respond.CodeAlreadyInUse, "Already in use", verr.Fields)
+		case errors.As(err, &verr):
+			writeFieldErrors(w, r, verr.Fields)
+		case errors.Is(err, domain.ErrUserNotFound):
+			respond.Error(w, r, "User not found", http.StatusNotFound)
+		default:
+			respond.Error(w, r, "Failed to update user", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	message := "User updated successfully"
+	if len(changed) == 0 {
+		message, changed = "Nothing to update", []string{}
+	}
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"message": message,
+		"user":    newAccountResponse(user),
+		"changed": changed,
+	})
+}
+
+// parseProfilePatch reads the members of a merge patch into values,
+// normalized, with set naming the fields it touches. A null clears a
+// field, so it sets the empty string. fields holds the members that
+// can't be applied; the limits are those of the columns.
+func parseProfilePatch(patch map[string]json.RawMessage) (values profilePatch, set map[string]bool, fields map[string]string) {
+	set = make(map[string]bool)
+	fields = make(map[string]string)
+	for name, raw := range patch {
+		var target *string
+		switch name {
+		case "first_name":
+			target = &values.FirstName
+		case "last_name":
+			target = &values.LastName
+		case "username":
+			target = &values.Username
+		default:
+			if msg, ok := immutablePatchFields[name]; ok {
+				fields[name] = msg
+			} else {
+				fields[name] = "Unknown field"
+			}
+			continue
+		}
+
+		set[name] = true
+		if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			if name == "username" {
+				fields[name] = "Username can't be cleared"
+			}
+			continue
+		}
+		if err := json.Unmarshal(raw, target); err != nil {
+			fields[name] = name + " must be a string or null"
+		}
+	}
+
+	values.FirstName = normalize.Name(values.FirstName)
+	values.LastName = normalize.Name(values.LastName)
+	values.Username = normalize.Username(values.Username)
+	for name, value := range map[string]string{"first_name": values.FirstName, "last_name": values.LastName} {
+		if fields[name] == "" && utf8.RuneCountInString(value) > 100 {
+			fields[name] = name + " must be at most 100 characters"
+		}
+	}
+	if set["username"] && fields["username"] == "" {
+		if n := utf8.RuneCountInString(values.Username); n < 3 || n > 50 {
+			fields["username"] = "Username must be 3 to 50 characters"
+		}
+	}
+	return values, set, fields
+}
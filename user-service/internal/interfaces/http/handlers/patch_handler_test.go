@@ -0,0 +1,138 @@
+// internal/interfaces/http/handlers/patch_handler_test.go
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/testsupport"
+)
+
+func patchRequest(t *testing.T, h *UserHandler, userID uint, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	token, err := h.jwtManager.GenerateToken(userID)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPatch, "/users/me", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+	middleware.AuthMiddleware(h.jwtManager)(http.HandlerFunc(h.PatchCurrentUser)).ServeHTTP(rr, req)
+	return rr
+}
+
+type patchResponse struct {
+	Message string              `json:"message"`
+	Changed []string            `json:"changed"`
+	Error   respond.ErrorDetail `json:"error"`
+	User    struct {
+		FirstName string
+		LastName  string
+		Username  string
+		Password  *string
+	} `json:"user"`
+}
+
+func decodePatch(t *testing.T, rr *httptest.ResponseRecorder) patchResponse {
+	t.Helper()
+	var resp patchResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return resp
+}
+
+func TestPatchCurrentUser(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	alice.Username, alice.FirstName, alice.LastName = "alice", "Alice", "Smith"
+	repo.Put(alice)
+	h := NewUserHandler(application.NewUserService(repo, testsupport.NewTxManager(repo), nil),
+		auth.NewJWTManager("test-secret", time.Hour))
+
+	// null clears, absent fields stay, values are normalized
+	rr := patchRequest(t, h, alice.ID, MergePatchContentType, `{"last_name":null,"first_name":"  Ally "}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	resp := decodePatch(t, rr)
+	if len(resp.Changed) != 2 || resp.User.LastName != "" || resp.User.FirstName != "Ally" || resp.User.Username != "alice" {
+		t.Errorf("unexpected patch result %+v", resp)
+	}
+	if resp.User.Password != nil {
+		t.Error("expected no password in the response")
+	}
+	stored, _ := repo.GetByID(context.Background(), alice.ID)
+	if stored.LastName != "" || stored.FirstName != "Ally" || stored.Email != "alice@example.com" {
+		t.Errorf("expected last_name cleared and first_name set, got %+v", stored)
+	}
+
+	// Applying the same patch again, or an empty one, changes nothing
+	for _, body := range []string{`{"last_name":null,"first_name":"Ally"}`, `{}`} {
+		rr = patchRequest(t, h, alice.ID, "application/json", body)
+		if resp := decodePatch(t, rr); rr.Code != http.StatusOK || resp.Message != "Nothing to update" || len(resp.Changed) != 0 {
+			t.Errorf("expected a no-op for %s, got %d %+v", body, rr.Code, resp)
+		}
+	}
+}
+
+func TestPatchCurrentUser_Rejects(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	alice.Username = "alice"
+	repo.Put(alice)
+	h := NewUserHandler(application.NewUserService(repo, testsupport.NewTxManager(repo), nil),
+		auth.NewJWTManager("test-secret", time.Hour))
+
+	tests := []struct {
+		name       string
+		body       string
+		wantFields []string
+	}{
+		{"email", `{"email":"new@example.com"}`, []string{"email"}},
+		{"id and password", `{"id":9,"password":"hunter22","first_name":"A"}`, []string{"id", "password"}},
+		{"unknown field", `{"role":"admin"}`, []string{"role"}},
+		{"clearing the username", `{"username":null}`, []string{"username"}},
+		{"short username", `{"username":"al"}`, []string{"username"}},
+		{"not a string", `{"first_name":42}`, []string{"first_name"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := patchRequest(t, h, alice.ID, MergePatchContentType, tt.body)
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d: %s", rr.Code, rr.Body)
+			}
+			resp := decodePatch(t, rr)
+			if len(resp.Error.Fields) != len(tt.wantFields) {
+				t.Errorf("expected errors for %v, got %v", tt.wantFields, resp.Error.Fields)
+			}
+			for _, field := range tt.wantFields {
+				if resp.Error.Fields[field] == "" {
+					t.Errorf("expected a %s error, got %v", field, resp.Error.Fields)
+				}
+			}
+		})
+	}
+
+	stored, _ := repo.GetByID(context.Background(), alice.ID)
+	if stored.Email != "alice@example.com" || stored.Username != "alice" || stored.FirstName != "" {
+		t.Errorf("a rejected patch must not be saved, got %+v", stored)
+	}
+
+	if rr := patchRequest(t, h, alice.ID, MergePatchContentType, `["first_name"]`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a patch that isn't an object, got %d", rr.Code)
+	}
+	if rr := patchRequest(t, h, alice.ID, "text/plain", `{}`); rr.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415 for a non-JSON patch, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,279 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/normalize"
+)
+
+// GenerateRecoveryCodes issues a new set of recovery codes, replacing any
+// the caller had. The body must carry the current password. The codes are
+// shown this once.
+func (h *UserHandler) GenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Password string `json:"password" validate:"required"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		if err != nil {
+			respond.Error(w, r, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		writeFieldErrors(w, r, fields)
+		return
+	}
+
+	codes, err := h.service.GenerateRecoveryCodes(r.Context(), userID, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, application.ErrInvalidCredentials):
+			writeFieldErrors(w, r, map[string]string{"password": "Password is incorrect"})
+		case errors.Is(err, domain.ErrUserNotFound):
+			respond.Error(w, r, "User not found", http.StatusNotFound)
+		default:
+			respond.Error(w, r, "Failed to generate recovery codes", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respond.JSON(w, http.StatusCreated, map[string]interface{}{
+		"message": "Store these codes somewhere safe. Each works once, and they won't be shown again.",
+		"codes":   codes,
+	})
+}
+
+// RecoverRequest is the body of POST /users/recover
+type RecoverRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Code  string `json:"code" validate:"required,max=64"`
+}
+
+// Recover trades an email and one of its recovery codes for a short
+// session that can only change the account's email and password
+func (h *UserHandler) Recover(w http.ResponseWriter, r *http.Request) {
+	var req RecoverRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	req.Email = normalize.Email(req.Email)
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		if err != nil {
+			respond.Error(w, r, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		writeFieldErrors(w, r, fields)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := h.service.Recover(ctx, req.Email, req.Code)
+	if err != nil {
+		if errors.Is(err, application.ErrInvalidRecoveryCode) {
+			respond.Error(w, r, "Invalid email or recovery code", http.StatusUnauthorized)
+			return
+		}
+		respond.Error(w, r, "Failed to recover account", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := h.restrictedSession(ctx, user, auth.ScopeAccountRecovery, application.RecoverySessionTTL)
+	if err != nil {
+		respond.Error(w, r, "Could not generate token", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"message":            "Recovery code accepted. Change your password now.",
+		"token":              token,
+		"expires_in_seconds": int64(application.RecoverySessionTTL.Seconds()),
+	}
+	// The code is already used; only the count is left out if this fails
+	if remaining, err := h.service.RecoveryCodesRemaining(ctx, user.ID); err == nil {
+		resp["recovery_codes_remaining"] = remaining
+	}
+	respond.JSON(w, http.StatusOK, resp)
+}
+
+// loginForReset answers a correct login to an account that must reset its
+// password with a short session that can only change it
+func (h *UserHandler) loginForReset(w http.ResponseWriter, r *http.Request, user *domain.User) {
+	token, err := h.restrictedSession(r.Context(), user, auth.ScopePasswordReset, application.PasswordResetSessionTTL)
+	if err != nil {
+		respond.Error(w, r, "Could not generate token", http.StatusInternalServerError)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"message":                 "Choose a new password to finish logging in.",
+		"password_reset_required": true,
+		"user":                    UserResponse{ID: user.ID, Username: user.Username, Email: user.Email},
+		"token":                   token,
+		"expires_in_seconds":      int64(application.PasswordResetSessionTTL.Seconds()),
+	})
+}
+
+// restrictedSession issues a token limited to scope that lasts ttl
+func (h *UserHandler) restrictedSession(ctx context.Context, user *domain.User, scope string, ttl time.Duration) (string, error) {
+	version, err := h.service.TokenVersion(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	return h.jwtManager.GenerateToken(user.ID,
+		auth.WithScopes(scope),
+		auth.WithExpiry(ttl),
+		auth.WithTokenVersion(version),
+	)
+}
+
+// ChangePasswordRequest is the body of PUT /users/me/password.
+// current_password isn't needed in a recovery or forced-reset session.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangePassword sets a new password and signs the account out everywhere,
+// including the session that made the change
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	var req ChangePasswordRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	recovered := middleware.IsRecoverySession(r)
+	forced := middleware.IsPasswordResetSession(r)
+	fields := make(map[string]string)
+	if !recovered && !forced && req.CurrentPassword == "" {
+		fields["current_password"] = "current_password is required"
+	}
+	if len(normalize.Password(req.NewPassword)) < 6 {
+		fields["new_password"] = "new_password must be at least 6 characters"
+	}
+	if len(fields) > 0 {
+		writeFieldErrors(w, r, fields)
+		return
+	}
+
+	err := h.service.ChangePassword(r.Context(), userID, application.PasswordChange{
+		Current:   req.CurrentPassword,
+		New:       req.NewPassword,
+		Recovered: recovered,
+		Forced:    forced,
+	})
+	if err != nil {
+		var verr *application.ValidationError
+		switch {
+		case errors.Is(err, application.ErrInvalidCredentials):
+			writeFieldErrors(w, r, map[string]string{"current_password": "Current password is incorrect"})
+		case errors.As(err, &verr):
+			writeFieldErrors(w, r, verr.Fields)
+		case errors.Is(err, domain.ErrUserNotFound):
+			respond.Error(w, r, "User not found", http.StatusNotFound)
+		default:
+			respond.Error(w, r, "Failed to change password", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Password changed. Log in again with the new password.",
+	})
+}
+
+// ForgotPasswordRequest is the body of POST /users/forgot-password
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ForgotPassword sends a password reset token to the account with the
+// given email. The answer is the same whether or not there is one.
+func (h *UserHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	req.Email = normalize.Email(req.Email)
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		if err != nil {
+			respond.Error(w, r, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		writeFieldErrors(w, r, fields)
+		return
+	}
+
+	err := h.service.ForgotPassword(r.Context(), req.Email)
+	switch {
+	case errors.Is(err, application.ErrPasswordResetsNotConfigured):
+		respond.Error(w, r, "Password reset is not enabled", http.StatusNotFound)
+	case err != nil:
+		respond.Error(w, r, "Failed to start password reset", http.StatusInternalServerError)
+	default:
+		respond.JSON(w, http.StatusAccepted, map[string]interface{}{
+			"message": "If an account uses this email, we've sent it a link to reset the password.",
+		})
+	}
+}
+
+// ResetPasswordRequest is the body of POST /users/reset-password
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required,max=128"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// ResetForgottenPassword sets a new password with a token from
+// ForgotPassword. Every session the account had is signed out.
+func (h *UserHandler) ResetForgottenPassword(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		if err != nil {
+			respond.Error(w, r, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		writeFieldErrors(w, r, fields)
+		return
+	}
+
+	err := h.service.ResetForgottenPassword(r.Context(), req.Token, req.NewPassword)
+	if err != nil {
+		var verr *application.ValidationError
+		switch {
+		case errors.Is(err, application.ErrInvalidResetToken):
+			respond.WriteError(w, r, http.StatusBadRequest, "invalid_reset_token", "This reset link is invalid or has expired. Ask for a new one.")
+		case errors.As(err, &verr):
+			writeFieldErrors(w, r, verr.Fields)
+		case errors.Is(err, application.ErrPasswordResetsNotConfigured):
+			respond.Error(w, r, "Password reset is not enabled", http.StatusNotFound)
+		default:
+			respond.Error(w, r, "Failed to reset password", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Password reset. Log in with the new password.",
+	})
+}
@@ -0,0 +1,240 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/logging"
+)
+
+// errSessionEnded is a refresh refused because the account can no longer
+// hold a session, e.g. it was banned or must reset its password
+var errSessionEnded = errors.New("session ended")
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// sessionResponse starts a session for user and answers with its tokens,
+// the access token built with extra on top of the session's claims.
+// RefreshToken is empty when the manager doesn't issue refresh tokens.
+func (h *UserHandler) sessionResponse(ctx context.Context, user *domain.User, extra ...auth.TokenOption) (map[string]interface{}, error) {
+	claims, err := h.sessionClaims(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	var sessionID string
+	session, err := h.service.StartSession(ctx, user.ID)
+	switch {
+	case errors.Is(err, application.ErrSessionsNotConfigured):
+	case err != nil:
+		return nil, err
+	default:
+		sessionID = session.ID
+		claims = append(claims, auth.WithSessionID(sessionID))
+	}
+	claims = append(claims, extra...)
+	pair, err := h.jwtManager.GenerateSessionTokenPair(ctx, user.ID, sessionID, claims...)
+	if errors.Is(err, auth.ErrRefreshTokensNotConfigured) {
+		token, issued, err := h.jwtManager.IssueToken(user.ID, claims...)
+		if err != nil {
+			return nil, err
+		}
+		return tokenJSON(token, issued.IssuedAt.Time, issued.ExpiresAt.Time), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pairJSON(pair), nil
+}
+
+// sessionClaims are what a full session's access token says about user
+func (h *UserHandler) sessionClaims(ctx context.Context, user *domain.User) ([]auth.TokenOption, error) {
+	version, err := h.service.TokenVersion(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return []auth.TokenOption{auth.WithRole(string(user.Role)), auth.WithTokenVersion(version)}, nil
+}
+
+// tokenJSON describes an access token the OAuth way, so clients needn't
+// decode it to know when to refresh. token predates access_token and is
+// kept for existing clients.
+func tokenJSON(token string, issuedAt, expiresAt time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"access_token": token,
+		"token":        token,
+		"token_type":   "Bearer",
+		"issued_at":    respond.NewTime(issuedAt),
+		"expires_at":   respond.NewTime(expiresAt),
+		"expires_in":   int64(expiresAt.Sub(issuedAt) / time.Second),
+	}
+}
+
+func pairJSON(pair *auth.TokenPair) map[string]interface{} {
+	resp := tokenJSON(pair.AccessToken, pair.IssuedAt, pair.ExpiresAt)
+	resp["refresh_token"] = pair.RefreshToken
+	resp["refresh_expires_in"] = int64(pair.RefreshExpiresIn / time.Second)
+	return resp
+}
+
+// Refresh serves POST /users/refresh, exchanging a refresh token for a new
+// access and refresh token. Each refresh token works once; a second use
+// means it was copied, so every token from that login is revoked.
+func (h *UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		writeFieldErrors(w, r, map[string]string{"refresh_token": "refresh_token is required"})
+		return
+	}
+
+	pair, err := h.jwtManager.RefreshTokenPair(r.Context(), req.RefreshToken, h.checkRefresh)
+	switch {
+	case errors.Is(err, auth.ErrRefreshTokenReused):
+		respond.WriteError(w, r, http.StatusUnauthorized, "refresh_token_reused", "This refresh token was already used; sign in again.")
+	case errors.Is(err, auth.ErrInvalidRefreshToken), errors.Is(err, domain.ErrUserNotFound):
+		respond.Error(w, r, "Invalid refresh token", http.StatusUnauthorized)
+	case errors.Is(err, errSessionEnded):
+		respond.WriteError(w, r, http.StatusUnauthorized, "session_ended", "Sign in again to continue.")
+	case errors.Is(err, auth.ErrRefreshTokensNotConfigured):
+		respond.Error(w, r, "Refresh tokens are not enabled", http.StatusNotFound)
+	case err != nil:
+		respond.Error(w, r, "Could not refresh session", http.StatusInternalServerError)
+	default:
+		respond.JSON(w, http.StatusOK, pairJSON(pair))
+	}
+}
+
+// checkRefresh only lets an account that could log in right now refresh
+// a session that wasn't signed out, and gives the new token the account's
+// current role and token version
+func (h *UserHandler) checkRefresh(ctx context.Context, userID uint, session string) ([]auth.TokenOption, error) {
+	user, err := h.service.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status != domain.StatusActive || user.MustResetPassword {
+		return nil, errSessionEnded
+	}
+	err = h.service.UseSession(ctx, userID, session)
+	if errors.Is(err, domain.ErrSessionNotFound) {
+		return nil, errSessionEnded
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.sessionClaims(ctx, user)
+}
+
+// RevokeRefreshToken serves POST /users/refresh/revoke, signing out the
+// login the refresh token belongs to. The token is the credential, so a
+// client can sign out after its access token has expired.
+func (h *UserHandler) RevokeRefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		writeFieldErrors(w, r, map[string]string{"refresh_token": "refresh_token is required"})
+		return
+	}
+
+	err := h.jwtManager.RevokeRefreshToken(r.Context(), req.RefreshToken)
+	switch {
+	case errors.Is(err, auth.ErrInvalidRefreshToken):
+		respond.Error(w, r, "Invalid refresh token", http.StatusUnauthorized)
+	case errors.Is(err, auth.ErrRefreshTokensNotConfigured):
+		respond.Error(w, r, "Refresh tokens are not enabled", http.StatusNotFound)
+	case err != nil:
+		respond.Error(w, r, "Could not revoke refresh token", http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Logout serves POST /users/logout, ending the session the bearer token
+// belongs to. The access token is revoked for the rest of its lifetime
+// when a TokenRevoker is configured, and its session drops off the
+// session list; a refresh_token in the body is revoked too, so the client
+// can't quietly sign back in. Logging out is idempotent: an unknown
+// refresh token or ended session doesn't fail it.
+func (h *UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	info := middleware.GetTokenInfo(r)
+	if info == nil {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+	var req logoutRequest
+	if !decodeOptionalBody(w, r, &req) {
+		return
+	}
+
+	if req.RefreshToken != "" {
+		err := h.jwtManager.RevokeRefreshToken(r.Context(), req.RefreshToken)
+		if err != nil && !errors.Is(err, auth.ErrInvalidRefreshToken) && !errors.Is(err, auth.ErrRefreshTokensNotConfigured) {
+			respond.Error(w, r, "Could not log out", http.StatusInternalServerError)
+			return
+		}
+	}
+	if info.Claims.SessionID != "" {
+		err := h.service.RevokeSession(r.Context(), info.Claims.UserID, info.Claims.SessionID)
+		if err != nil && !errors.Is(err, domain.ErrSessionNotFound) && !errors.Is(err, application.ErrSessionsNotConfigured) {
+			respond.Error(w, r, "Could not log out", http.StatusInternalServerError)
+			return
+		}
+	}
+	if h.tokenRevoker != nil && info.Claims.ID != "" {
+		if err := h.tokenRevoker.RevokeToken(r.Context(), info.Claims.ID, info.ExpiresIn); err != nil {
+			logging.Printf(r.Context(), "Failed to revoke token of user %d: %v", info.Claims.UserID, err)
+			respond.Error(w, r, "Could not log out", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll serves POST /users/logout-all, ending every session the user
+// has, this one included, along with their refresh tokens. Personal
+// access tokens are revoked one by one and keep working.
+func (h *UserHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.service.LogoutAll(r.Context(), userID)
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		respond.Error(w, r, "User not found", http.StatusNotFound)
+	case err != nil:
+		respond.Error(w, r, "Could not log out", http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// JWKS serves GET /auth/.well-known/jwks.json, the public keys other
+// services verify access tokens with. An HS256 deployment has none to
+// publish, and its key set is empty.
+func (h *UserHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	set := h.jwtManager.JWKS()
+	// Verifiers may hold on to the key for a while; a rotation should
+	// publish the new key this long before signing with it
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respond.JSON(w, http.StatusOK, set)
+}
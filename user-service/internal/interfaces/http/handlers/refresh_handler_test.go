@@ -0,0 +1,144 @@
+// internal/interfaces/http/handlers/refresh_handler_test.go
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/testsupport"
+)
+
+func TestRefresh(t *testing.T) {
+	clock := testsupport.NewClock()
+	store := testsupport.NewRefreshTokenStore()
+	jwtManager := auth.NewJWTManager(testsupport.JWTSecret, 15*time.Minute,
+		auth.WithClock(clock.Now),
+		auth.WithRefreshTokens(store, 24*time.Hour),
+	)
+	user := &domain.User{ID: 7, Email: "alice@example.com", Status: domain.StatusActive}
+	svc := &testsupport.MockUserService{
+		LoginFn: func(ctx context.Context, email, password string) (*domain.User, error) {
+			return user, nil
+		},
+		GetUserFn: func(ctx context.Context, id uint) (*domain.User, error) {
+			if id != user.ID {
+				return nil, domain.ErrUserNotFound
+			}
+			return user, nil
+		},
+	}
+	h := NewUserHandler(svc, jwtManager)
+
+	post := func(handler http.HandlerFunc, path, body string) (int, map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		var resp map[string]interface{}
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr.Code, resp
+	}
+	login := func() string {
+		t.Helper()
+		status, resp := post(h.Login, "/users/login", `{"email":"alice@example.com","password":"secret123"}`)
+		token, _ := resp["refresh_token"].(string)
+		if status != http.StatusOK || token == "" || resp["expires_in"] != float64(900) {
+			t.Fatalf("expected a token pair, got %d %v", status, resp)
+		}
+		return token
+	}
+	refresh := func(token string) (int, map[string]interface{}) {
+		return post(h.Refresh, "/users/refresh", `{"refresh_token":"`+token+`"}`)
+	}
+
+	t.Run("rotates once", func(t *testing.T) {
+		token := login()
+		status, resp := refresh(token)
+		next, _ := resp["refresh_token"].(string)
+		if status != http.StatusOK || next == "" || next == token {
+			t.Fatalf("expected a new pair, got %d %v", status, resp)
+		}
+		access, _ := resp["access_token"].(string)
+		if claims, err := jwtManager.ValidateToken(access); err != nil || claims.UserID != 7 {
+			t.Fatalf("expected a valid access token for user 7, got %+v (%v)", claims, err)
+		}
+		if resp["token_type"] != "Bearer" || resp["expires_in"] != float64(900) || resp["issued_at"] == nil {
+			t.Errorf("expected the refresh to describe the token like a login, got %v", resp)
+		}
+
+		if status, resp := refresh(token); status != http.StatusUnauthorized || errorCode(resp) != "refresh_token_reused" {
+			t.Errorf("expected the reuse refused, got %d %v", status, resp)
+		}
+		if status, _ := refresh(next); status != http.StatusUnauthorized {
+			t.Errorf("expected the family revoked after reuse, got %d", status)
+		}
+	})
+
+	t.Run("carries the current role", func(t *testing.T) {
+		token := login()
+		user.Role = domain.RoleAdmin
+		defer func() { user.Role = "" }()
+		_, resp := refresh(token)
+		access, _ := resp["token"].(string)
+		if claims, err := jwtManager.ValidateToken(access); err != nil || claims.Role != string(domain.RoleAdmin) {
+			t.Errorf("expected the refreshed token to carry the admin role, got %+v (%v)", claims, err)
+		}
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		token := login()
+		clock.Advance(24 * time.Hour)
+		if status, _ := refresh(token); status != http.StatusUnauthorized {
+			t.Errorf("expected an expired token refused, got %d", status)
+		}
+	})
+
+	t.Run("ends with the account", func(t *testing.T) {
+		token := login()
+		user.Status = domain.StatusBanned
+		defer func() { user.Status = domain.StatusActive }()
+		if status, resp := refresh(token); status != http.StatusUnauthorized || errorCode(resp) != "session_ended" {
+			t.Errorf("expected a banned account refused, got %d %v", status, resp)
+		}
+		// Refused for good, not just while banned
+		user.Status = domain.StatusActive
+		if status, _ := refresh(token); status != http.StatusUnauthorized {
+			t.Errorf("expected the token revoked, got %d", status)
+		}
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		token := login()
+		rr := httptest.NewRecorder()
+		h.RevokeRefreshToken(rr, httptest.NewRequest(http.MethodPost, "/users/refresh/revoke",
+			strings.NewReader(`{"refresh_token":"`+token+`"}`)))
+		if rr.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d", rr.Code)
+		}
+		if status, _ := refresh(token); status != http.StatusUnauthorized {
+			t.Errorf("expected a revoked token refused, got %d", status)
+		}
+	})
+
+	t.Run("without refresh tokens", func(t *testing.T) {
+		plain := newTestHandler(svc)
+		rr := httptest.NewRecorder()
+		plain.Login(rr, httptest.NewRequest(http.MethodPost, "/users/login",
+			strings.NewReader(`{"email":"alice@example.com","password":"secret123"}`)))
+		var resp map[string]interface{}
+		json.NewDecoder(rr.Body).Decode(&resp)
+		if rr.Code != http.StatusOK || resp["token"] == nil || resp["refresh_token"] != nil {
+			t.Errorf("expected an access token only, got %d %v", rr.Code, resp)
+		}
+		rr = httptest.NewRecorder()
+		plain.Refresh(rr, httptest.NewRequest(http.MethodPost, "/users/refresh", strings.NewReader(`{"refresh_token":"rt_x"}`)))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", rr.Code)
+		}
+	})
+}
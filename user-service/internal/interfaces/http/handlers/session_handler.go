@@ -0,0 +1,85 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+)
+
+// SessionResponse describes one login of the caller's
+type SessionResponse struct {
+	ID         string       `json:"id"`
+	UserAgent  string       `json:"user_agent"`
+	IP         string       `json:"ip"`
+	CreatedAt  respond.Time `json:"created_at"`
+	LastUsedAt respond.Time `json:"last_used"`
+	// Current marks the session the request was made with
+	Current bool `json:"current"`
+}
+
+// ListSessions serves GET /users/me/sessions, the caller's active logins
+func (h *UserHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := h.service.ListSessions(r.Context(), userID)
+	if errors.Is(err, application.ErrSessionsNotConfigured) {
+		respond.Error(w, r, "Sessions are not enabled", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respond.Error(w, r, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	var current string
+	if info := middleware.GetTokenInfo(r); info != nil {
+		current = info.Claims.SessionID
+	}
+	resp := make([]SessionResponse, len(sessions))
+	for i, session := range sessions {
+		resp[i] = SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  respond.NewTime(session.CreatedAt),
+			LastUsedAt: respond.NewTime(session.LastUsedAt),
+			Current:    session.ID == current,
+		}
+	}
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"sessions": resp,
+	})
+}
+
+// RevokeSession serves DELETE /users/me/sessions/{id}, signing that login
+// out. Revoking the current session works too, and is a logout.
+func (h *UserHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
+		return
+	}
+
+	id := r.PathValue("id")
+	err := h.service.RevokeSession(r.Context(), userID, id)
+	switch {
+	case errors.Is(err, domain.ErrSessionNotFound):
+		respond.Error(w, r, "Session not found", http.StatusNotFound)
+	case errors.Is(err, application.ErrSessionsNotConfigured):
+		respond.Error(w, r, "Sessions are not enabled", http.StatusNotFound)
+	case err != nil:
+		respond.Error(w, r, "Failed to revoke session", http.StatusInternalServerError)
+	default:
+		respond.JSON(w, http.StatusOK, map[string]interface{}{
+			"message": "Session revoked",
+			"id":      id,
+		})
+	}
+}
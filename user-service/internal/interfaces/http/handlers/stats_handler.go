@@ -0,0 +1,95 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"user-service/internal/application"
+	"user-service/internal/interfaces/http/respond"
+)
+
+type StatsHandler struct {
+	stats application.ActivityStats
+}
+
+func NewStatsHandler(stats application.ActivityStats) *StatsHandler {
+	return &StatsHandler{stats: stats}
+}
+
+// Activity serves GET /admin/stats/activity?granularity=day&from=&to=.
+// from and to accept a date (2006-01-02) or an RFC 3339 timestamp.
+func (h *StatsHandler) Activity(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	fields := make(map[string]string)
+
+	from, err := parseStatsTime(query.Get("from"))
+	if err != nil {
+		fields["from"] = "must be a date (YYYY-MM-DD) or RFC 3339 timestamp"
+	}
+	to, err := parseStatsTime(query.Get("to"))
+	if err != nil {
+		fields["to"] = "must be a date (YYYY-MM-DD) or RFC 3339 timestamp"
+	}
+	if len(fields) > 0 {
+		writeFieldErrors(w, r, fields)
+		return
+	}
+
+	report, err := h.stats.Activity(r.Context(), query.Get("granularity"), from, to)
+	if err != nil {
+		var verr *application.ValidationError
+		if errors.As(err, &verr) {
+			writeFieldErrors(w, r, verr.Fields)
+			return
+		}
+		respond.Error(w, r, "Failed to load activity stats", http.StatusInternalServerError)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, newActivityResponse(report))
+}
+
+// ActivityResponse is an ActivityReport with its timestamps in UTC
+type ActivityResponse struct {
+	Granularity application.Granularity  `json:"granularity"`
+	From        respond.Time             `json:"from"`
+	To          respond.Time             `json:"to"`
+	Buckets     []ActivityBucketResponse `json:"buckets"`
+}
+
+type ActivityBucketResponse struct {
+	Start     respond.Time `json:"start"`
+	Signups   int64        `json:"signups"`
+	Logins    int64        `json:"logins"`
+	Deletions int64        `json:"deletions"`
+}
+
+func newActivityResponse(report *application.ActivityReport) ActivityResponse {
+	buckets := make([]ActivityBucketResponse, len(report.Buckets))
+	for i, bucket := range report.Buckets {
+		buckets[i] = ActivityBucketResponse{
+			Start:     respond.NewTime(bucket.Start),
+			Signups:   bucket.Signups,
+			Logins:    bucket.Logins,
+			Deletions: bucket.Deletions,
+		}
+	}
+	return ActivityResponse{
+		Granularity: report.Granularity,
+		From:        respond.NewTime(report.From),
+		To:          respond.NewTime(report.To),
+		Buckets:     buckets,
+	}
+}
+
+// parseStatsTime returns the zero time for an empty value so the service
+// applies its default window. A bare date is midnight UTC.
+func parseStatsTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return respond.ParseTime(value)
+}
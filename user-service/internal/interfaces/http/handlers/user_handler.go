@@ -1,14 +1,21 @@
 package http
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"user-service/internal/application"
 	"user-service/internal/domain"
 	"user-service/internal/infrastructure/auth"
 	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/normalize"
 
 	"github.com/go-playground/validator/v10"
 )
@@ -19,6 +26,17 @@ type RegisterRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required,min=6"`
+	// InviteCode is required while registration is invite-only
+	InviteCode string `json:"invite_code,omitempty" validate:"max=64"`
+}
+
+// user returns the account the request asks for; the password is passed
+// to the service separately
+func (r *RegisterRequest) user() *domain.User {
+	return &domain.User{
+		Username: r.Username,
+		Email:    r.Email,
+	}
 }
 
 type UserResponse struct {
@@ -27,204 +45,787 @@ type UserResponse struct {
 	Email    string `json:"email"`
 }
 
+// AccountResponse is a full account as shown to its owner and in
+// listings. Field names match what the API sent before it had a DTO; the
+// password hash is never included.
+type AccountResponse struct {
+	ID                  uint
+	Username            string
+	Email               string
+	FirstName           string
+	LastName            string
+	Status              domain.UserStatus
+	Role                domain.Role
+	EmailVerifiedAt     *respond.Time
+	DeletionRequestedAt *respond.Time
+	Notifications       domain.NotificationPreferences
+	LastLogin           *respond.Time
+	CreatedAt           respond.Time
+	UpdatedAt           respond.Time
+	DeletedAt           *respond.Time
+	// RecoveryCodesRemaining is only shown to the account's owner
+	RecoveryCodesRemaining *int `json:",omitempty"`
+	// Notices are only shown to the account's owner, most severe first
+	Notices []NoticeResponse `json:",omitempty"`
+}
+
+func newAccountResponse(user *domain.User) AccountResponse {
+	resp := AccountResponse{
+		ID:                  user.ID,
+		Username:            user.Username,
+		Email:               user.Email,
+		FirstName:           user.FirstName,
+		LastName:            user.LastName,
+		Status:              user.Status,
+		Role:                user.Role,
+		EmailVerifiedAt:     respond.NewTimePtr(user.EmailVerifiedAt),
+		DeletionRequestedAt: respond.NewTimePtr(user.DeletionRequestedAt),
+		Notifications:       user.Notifications,
+		LastLogin:           respond.NewTimePtr(user.LastLogin),
+		CreatedAt:           respond.NewTime(user.CreatedAt),
+		UpdatedAt:           respond.NewTime(user.UpdatedAt),
+	}
+	if user.DeletedAt.Valid {
+		resp.DeletedAt = respond.NewTimePtr(&user.DeletedAt.Time)
+	}
+	return resp
+}
+
 type UserHandler struct {
-	service    *application.UserService
+	service    application.UserServiceInterface
 	jwtManager *auth.JWTManager
+	// registerDedup collapses double-submitted signups; nil without Redis
+	registerDedup Deduplicator
+	// tokenRevoker makes logging out end the access token; nil without Redis
+	tokenRevoker TokenRevoker
+	// rememberMeTTL is how long a remembered login's access token lasts;
+	// zero gives it the usual one
+	rememberMeTTL time.Duration
 }
 
-func NewUserHandler(s *application.UserService, jwt *auth.JWTManager) *UserHandler {
-	return &UserHandler{service: s, jwtManager: jwt}
+// Deduplicator runs serve for the first of several identical requests,
+// identified by key, and gives the others its response
+type Deduplicator interface {
+	Serve(w http.ResponseWriter, r *http.Request, key string, serve http.HandlerFunc)
 }
 
-func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// UserHandlerOption configures a UserHandler
+type UserHandlerOption func(*UserHandler)
+
+// WithRegisterDeduplicator answers a signup repeated from the same client
+// while the first is in flight with the first's response, rather than the
+// conflict it would otherwise race into
+func WithRegisterDeduplicator(d Deduplicator) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.registerDedup = d
 	}
+}
 
-	var req RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
-		return
+// TokenRevoker invalidates a single access token, by jti, for the time it
+// had left
+type TokenRevoker interface {
+	RevokeToken(ctx context.Context, tokenID string, ttl time.Duration) error
+}
+
+// WithTokenRevoker makes Logout revoke the access token it was called with.
+// Without it the token stays valid until it expires.
+func WithTokenRevoker(revoker TokenRevoker) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.tokenRevoker = revoker
 	}
+}
 
-	// FIX: Remove spaces in validation tags
-	// validate:"required,min=3,max=50" NOT "required, min=3, max=50"
-	if err := validate.Struct(req); err != nil {
-		validationErrors, ok := err.(validator.ValidationErrors)
-		if !ok {
-			http.Error(w, "Validation failed", http.StatusBadRequest)
-			return
-		}
+// WithRememberMe makes a login with "remember_me" get an access token
+// lasting ttl instead of the manager's default
+func WithRememberMe(ttl time.Duration) UserHandlerOption {
+	return func(h *UserHandler) {
+		h.rememberMeTTL = ttl
+	}
+}
 
-		// Tạo map chứa lỗi cho từng field
-		errorMessages := make(map[string]string)
-		for _, e := range validationErrors {
-			errorMessages[strings.ToLower(e.Field())] = formatValidationError(e)
-		}
+func NewUserHandler(s application.UserServiceInterface, jwt *auth.JWTManager, opts ...UserHandlerOption) *UserHandler {
+	h := &UserHandler{service: s, jwtManager: jwt}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":  "Validation failed",
-			"fields": errorMessages,
-		})
+// Register creates an account. With a deduplicator, a signup repeated from
+// the same client while the first is in flight gets the first's response.
+func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRegisterRequest(w, r)
+	if !ok {
 		return
 	}
 
-	u := domain.User{
-		Username: strings.TrimSpace(req.Username),
-		Email:    strings.ToLower(strings.TrimSpace(req.Email)),
-		Password: req.Password,
+	register := func(w http.ResponseWriter, r *http.Request) {
+		h.register(w, r, req)
 	}
+	if h.registerDedup != nil {
+		clientIP := application.ClientInfoFrom(r.Context()).IP
+		h.registerDedup.Serve(w, r, middleware.DedupKey(req.Email, clientIP), register)
+		return
+	}
+	register(w, r)
+}
+
+func (h *UserHandler) register(w http.ResponseWriter, r *http.Request, req *RegisterRequest) {
+	u := req.user()
 
 	ctx := r.Context() // FIX: Add context
-	if err := h.service.Register(ctx, &u); err != nil {
-		if strings.Contains(err.Error(), "already registered") {
-			http.Error(w, "Email already registered", http.StatusConflict)
+	replayed, err := h.service.Register(ctx, u, req.Password, req.InviteCode)
+	if err != nil {
+		if writeRegistrationRefused(w, r, err) {
 			return
 		}
-		http.Error(w, "Could not register user", http.StatusInternalServerError)
+		// Checked before the plain conflict it wraps
+		if errors.Is(err, application.ErrEmailBelongsToDeletedAccount) {
+			respond.WriteError(w, r, http.StatusConflict, "email_belongs_to_deleted_account", "This email belongs to an account that was recently deleted. Use account recovery to restore it instead of signing up again.")
+			return
+		}
+		// A signup racing another for the same email fails at insert
+		if errors.Is(err, application.ErrEmailAlreadyRegistered) || errors.Is(err, domain.ErrDuplicateUser) {
+			respond.WriteError(w, r, http.StatusConflict, respond.CodeEmailTaken, "Email already registered")
+			return
+		}
+		var verr *application.ValidationError
+		if errors.As(err, &verr) {
+			writeFieldErrors(w, r, verr.Fields)
+			return
+		}
+		respond.Error(w, r, "Could not register user", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "User registered successfully",
+	// A retry of a signup that already went through gets the account it
+	// created instead of a conflict
+	status, message := http.StatusCreated, "User registered successfully"
+	if replayed {
+		status, message = http.StatusOK, "User already registered"
+	}
+	respond.JSON(w, status, map[string]interface{}{
+		"message": message,
 		"user": UserResponse{
 			ID:       u.ID,
 			Username: u.Username,
 			Email:    u.Email,
 		},
+		"replayed": replayed,
 	})
 }
 
-func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// registrationRefusals are the errors Register returns when the
+// registration mode or the invite code turns the signup away
+var registrationRefusals = []struct {
+	err     error
+	code    string
+	message string
+}{
+	{application.ErrRegistrationClosed, "registration_closed", "Registration is closed at the moment. Please check back soon."},
+	{application.ErrInviteRequired, "invite_required", "Registration is invite-only. Please enter your invite code."},
+	{domain.ErrInviteNotFound, "invite_invalid", "That invite code isn't valid."},
+	{domain.ErrInviteRevoked, "invite_invalid", "That invite code isn't valid."},
+	{domain.ErrInviteExpired, "invite_expired", "That invite code has expired."},
+	{domain.ErrInviteExhausted, "invite_exhausted", "That invite code has already been used up."},
+}
+
+// writeRegistrationRefused sends a 403 naming why the signup was turned
+// away, reporting whether err was such a refusal
+func writeRegistrationRefused(w http.ResponseWriter, r *http.Request, err error) bool {
+	for _, refusal := range registrationRefusals {
+		if errors.Is(err, refusal.err) {
+			respond.WriteError(w, r, http.StatusForbidden, refusal.code, refusal.message)
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRegistration runs the Register checks without creating anything,
+// so the signup form can show inline feedback
+func (h *UserHandler) ValidateRegistration(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeRegisterRequest(w, r)
+	if !ok {
 		return
 	}
 
-	var req struct {
-		Email    string `json:"email" validate:"required,email"`
-		Password string `json:"password" validate:"required"`
+	if err := h.service.ValidateRegistration(r.Context(), req.user(), req.Password); err != nil {
+		var verr *application.ValidationError
+		if errors.As(err, &verr) {
+			writeFieldErrors(w, r, verr.Fields)
+			return
+		}
+		respond.Error(w, r, "Could not validate registration", http.StatusInternalServerError)
+		return
+	}
+
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"valid": true,
+	})
+}
+
+// decodeRegisterRequest parses the request body with parseRegisterRequest,
+// writing the error response itself when it returns false
+func decodeRegisterRequest(w http.ResponseWriter, r *http.Request) (*RegisterRequest, bool) {
+	req, fields, err := parseRegisterRequest(r.Body)
+	switch {
+	case err != nil:
+		writeBodyError(w, r, err)
+		return nil, false
+	case fields != nil:
+		writeFieldErrors(w, r, fields)
+		return nil, false
+	}
+	return req, true
+}
+
+// parseRegisterRequest decodes, normalizes and validates a signup body.
+// Normalizing first means the length rules apply to what gets stored, so a
+// whitespace-only username can't slip through as an empty one. Field
+// problems come back as a map; a malformed body as an error.
+func parseRegisterRequest(body io.Reader) (*RegisterRequest, map[string]string, error) {
+	var req RegisterRequest
+	if err := decodeJSON(body, &req); err != nil {
+		return nil, nil, err
+	}
+	req.Username = normalize.Username(req.Username)
+	req.Email = normalize.Email(req.Email)
+	req.Password = normalize.Password(req.Password)
+
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		return nil, fields, err
+	}
+	return &req, nil, nil
+}
+
+// LoginRequest is the body of POST /login
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+	// RememberMe asks for a long-lived access token, see WithRememberMe
+	RememberMe bool `json:"remember_me"`
+}
+
+// parseLoginRequest decodes, normalizes and validates a login body, in the
+// same way as parseRegisterRequest. An empty body is validated as {} so the
+// caller is told which fields are missing rather than "invalid request".
+func parseLoginRequest(body io.Reader) (*LoginRequest, map[string]string, error) {
+	var req LoginRequest
+	if err := decodeJSON(body, &req); err != nil && !errors.Is(err, errEmptyBody) {
+		return nil, nil, err
 	}
+	req.Email = normalize.Email(req.Email)
+	req.Password = normalize.Password(req.Password)
+
+	if fields, err := validateRequest(req); fields != nil || err != nil {
+		return nil, fields, err
+	}
+	return &req, nil, nil
+}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request", http.StatusBadRequest)
+// validateRequest runs the struct's validate tags, returning the problems
+// keyed by lower-cased field name. Tags must not contain spaces:
+// "required,min=3", not "required, min=3".
+func validateRequest(req interface{}) (map[string]string, error) {
+	err := validate.Struct(req)
+	if err == nil {
+		return nil, nil
+	}
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return nil, err
+	}
+
+	errorMessages := make(map[string]string)
+	for _, e := range validationErrors {
+		errorMessages[strings.ToLower(e.Field())] = formatValidationError(e)
+	}
+	return errorMessages, nil
+}
+
+// writeFieldErrors sends a 400 with the per-field error map
+func writeFieldErrors(w http.ResponseWriter, r *http.Request, fields map[string]string) {
+	respond.WriteError(w, r, http.StatusBadRequest, respond.CodeValidationFailed, "Validation failed", fields)
+}
+
+func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
+	req, fields, err := parseLoginRequest(r.Body)
+	if err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+	if fields != nil {
+		writeFieldErrors(w, r, fields)
 		return
 	}
 
 	ctx := r.Context()
 	user, err := h.service.Login(ctx, req.Email, req.Password)
 	if err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		if errors.Is(err, application.ErrUserBanned) {
+			respond.WriteError(w, r, http.StatusForbidden, "account_banned", "This account has been banned.")
+			return
+		}
+		var denied *application.LoginDeniedError
+		if errors.As(err, &denied) {
+			respond.WriteError(w, r, http.StatusForbidden, "login_denied", denied.Reason)
+			return
+		}
+		respond.WriteError(w, r, http.StatusUnauthorized, respond.CodeInvalidCredentials, "Invalid credentials")
 		return
 	}
 
-	token, err := h.jwtManager.GenerateToken(user.ID)
-	if err != nil {
-		http.Error(w, "Could not generate token", http.StatusInternalServerError)
+	if user.MustResetPassword {
+		h.loginForReset(w, r, user)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Login successful",
-		"user":    UserResponse{ID: user.ID, Username: user.Username, Email: user.Email},
-		"token":   token,
-	})
+	var extra []auth.TokenOption
+	if req.RememberMe && h.rememberMeTTL > 0 {
+		extra = append(extra, auth.WithExpiry(h.rememberMeTTL))
+	}
+	resp, err := h.sessionResponse(ctx, user, extra...)
+	if err != nil {
+		respond.Error(w, r, "Could not generate token", http.StatusInternalServerError)
+		return
+	}
+	resp["message"] = "Login successful"
+	resp["user"] = UserResponse{ID: user.ID, Username: user.Username, Email: user.Email}
+	respond.JSON(w, http.StatusOK, resp)
 }
 
+// GetCurrentUser shows the caller's account, with an ETag so pollers can
+// use HEAD or If-None-Match to see whether it changed
 func (h *UserHandler) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r)
 	if userID == 0 {
-		http.Error(w, "User not found in context", http.StatusUnauthorized)
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
 		return
 	}
 
 	ctx := r.Context()
 	user, err := h.service.GetUser(ctx, uint(userID))
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		respond.Error(w, r, "User not found", http.StatusNotFound)
 		return
 	}
 
-	// Don't send password
-	user.Password = ""
+	resp := newAccountResponse(user)
+	// The account is still worth showing without the count
+	if remaining, err := h.service.RecoveryCodesRemaining(ctx, user.ID); err == nil {
+		resp.RecoveryCodesRemaining = &remaining
+	}
+	if notices, err := h.service.Notices(ctx, user); err == nil {
+		resp.Notices = newNoticeResponses(notices)
+	}
+	respond.JSONWithETag(w, r, http.StatusOK, resp)
+}
+
+// GetUserByID serves GET /users/{id}, any account by ID for admins. Erased
+// accounts are gone, but ones waiting out their deletion grace period are
+// shown with their status.
+func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil || id == 0 {
+		respond.Error(w, r, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+	user, err := h.service.GetUser(r.Context(), uint(id))
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		respond.Error(w, r, "User not found", http.StatusNotFound)
+	case err != nil:
+		respond.Error(w, r, "Failed to get user", http.StatusInternalServerError)
+	default:
+		respond.JSON(w, http.StatusOK, newAccountResponse(user))
+	}
 }
 
-func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// UserExists serves HEAD /users/{id}: 200 when the account exists and 404
+// when it doesn't, without loading or serializing the profile
+func (h *UserHandler) UserExists(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 0)
+	if err != nil || id == 0 {
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	exists, err := h.service.UserExists(r.Context(), uint(id))
+	switch {
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+	case !exists:
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// notificationPreferenceKeys are the keys of the preferences document
+// that switch mail on and off. They have columns of their own, which mail
+// delivery reads; the rest of the document is domain.Preferences.
+var notificationPreferenceKeys = []string{"transactional", "security_critical", "security_alerts", "marketing", "product_updates"}
+
+// preferencesDocument is the caller's preferences as one JSON object. The
+// notification categories that can't be turned off are listed too, so
+// clients can show them as locked.
+func preferencesDocument(notifications domain.NotificationPreferences, settings domain.Preferences) map[string]interface{} {
+	doc := map[string]interface{}{
+		"transactional":     true,
+		"security_critical": true,
+		"security_alerts":   !notifications.SecurityAlertsMuted,
+		"marketing":         notifications.Marketing,
+		"product_updates":   notifications.ProductUpdates,
+	}
+	for key, value := range settings {
+		doc[key] = value
+	}
+	return doc
+}
+
+// acceptedPreferenceKeys lists every key PATCH /users/me/preferences takes
+func acceptedPreferenceKeys() []string {
+	keys := append([]string(nil), notificationPreferenceKeys...)
+	for key := range domain.PreferenceSchema {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writePreferenceError answers for a key or value domain.Preferences
+// refused. An unknown key is told the keys its object accepts.
+func writePreferenceError(w http.ResponseWriter, r *http.Request, prefErr *domain.PreferenceError) {
+	if !prefErr.Unknown {
+		writeFieldErrors(w, r, map[string]string{prefErr.Key: prefErr.Reason})
+		return
+	}
+	accepted := prefErr.Accepted
+	if !strings.Contains(prefErr.Key, ".") {
+		accepted = acceptedPreferenceKeys()
+	}
+	respond.WriteError(w, r, http.StatusBadRequest, respond.CodeUnknownField,
+		fmt.Sprintf("Unknown preference %q; accepted keys are %s", prefErr.Key, strings.Join(accepted, ", ")),
+		map[string]string{prefErr.Key: "unknown preference"})
+}
+
+// GetPreferences serves GET /users/me/preferences: the caller's
+// notification preferences and display settings
+func (h *UserHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r)
 	if userID == 0 {
-		http.Error(w, "User not found in context", http.StatusUnauthorized)
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
 		return
 	}
 
-	var updateReq struct {
-		FirstName string `json:"first_name"`
-		LastName  string `json:"last_name"`
-		Username  string `json:"username"`
+	user, err := h.service.GetUser(r.Context(), uint(userID))
+	if err != nil {
+		respond.Error(w, r, "User not found", http.StatusNotFound)
+		return
 	}
+	respond.JSON(w, http.StatusOK, preferencesDocument(user.Notifications, user.Preferences))
+}
 
-	if err := json.NewDecoder(r.Body).Decode(&updateReq); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+// UpdatePreferences serves PATCH /users/me/preferences. The body is a JSON
+// merge patch of the preferences document: keys left out are unchanged,
+// objects merge key by key and null clears a display setting.
+// transactional and security_critical are only accepted as true.
+func (h *UserHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
 		return
 	}
-
 	ctx := r.Context()
 
-	// Get current user
+	var patch map[string]interface{}
+	if !decodeBody(w, r, &patch) {
+		return
+	}
+
+	// The notification switches come out of the patch; what's left is
+	// checked against the schema before anything is written
+	var update application.NotificationPreferencesUpdate
+	notify := false
+	fields := make(map[string]string)
+	for _, key := range notificationPreferenceKeys {
+		value, ok := patch[key]
+		if !ok {
+			continue
+		}
+		delete(patch, key)
+		on, isBool := value.(bool)
+		if !isBool {
+			fields[key] = "must be a boolean"
+			continue
+		}
+		notify = true
+		switch key {
+		case "transactional":
+			if !on {
+				fields[key] = "Transactional email can't be turned off"
+			}
+		case "security_critical":
+			if !on {
+				fields[key] = "Security notices can't be turned off"
+			}
+		case "security_alerts":
+			update.SecurityAlerts = &on
+		case "marketing":
+			update.Marketing = &on
+		case "product_updates":
+			update.ProductUpdates = &on
+		}
+	}
+	if len(fields) > 0 {
+		writeFieldErrors(w, r, fields)
+		return
+	}
+	if _, err := domain.Preferences(nil).Merge(patch); err != nil {
+		var prefErr *domain.PreferenceError
+		if errors.As(err, &prefErr) {
+			writePreferenceError(w, r, prefErr)
+			return
+		}
+		respond.Error(w, r, "Invalid preferences", http.StatusBadRequest)
+		return
+	}
+
 	user, err := h.service.GetUser(ctx, uint(userID))
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		respond.Error(w, r, "User not found", http.StatusNotFound)
+		return
+	}
+	notifications, settings := user.Notifications, user.Preferences
+	if notify {
+		prefs, err := h.service.UpdateNotificationPreferences(ctx, uint(userID), update)
+		if err != nil {
+			writePreferencesUpdateError(w, r, err)
+			return
+		}
+		notifications = *prefs
+	}
+	if len(patch) > 0 {
+		settings, err = h.service.UpdatePreferences(ctx, uint(userID), patch)
+		if err != nil {
+			writePreferencesUpdateError(w, r, err)
+			return
+		}
+	}
+	respond.JSON(w, http.StatusOK, preferencesDocument(notifications, settings))
+}
+
+func writePreferencesUpdateError(w http.ResponseWriter, r *http.Request, err error) {
+	var prefErr *domain.PreferenceError
+	switch {
+	case errors.As(err, &prefErr):
+		writePreferenceError(w, r, prefErr)
+	case errors.Is(err, domain.ErrUserNotFound):
+		respond.Error(w, r, "User not found", http.StatusNotFound)
+	default:
+		respond.Error(w, r, "Failed to update preferences", http.StatusInternalServerError)
+	}
+}
+
+// TokenResponse is the decoded view of the caller's own token. The token
+// itself is never echoed back.
+type TokenResponse struct {
+	UserID           uint         `json:"user_id"`
+	TokenID          string       `json:"jti"`
+	Issuer           string       `json:"issuer"`
+	IssuedAt         respond.Time `json:"iat"`
+	ExpiresAt        respond.Time `json:"exp"`
+	ExpiresInSeconds int64        `json:"expires_in_seconds"`
+	Scopes           []string     `json:"scopes"`
+	Impersonator     *uint        `json:"impersonator"`
+}
+
+// GetCurrentToken shows the claims of the token the request was made with,
+// to answer "which token is my app actually sending?" without jwt.io
+func (h *UserHandler) GetCurrentToken(w http.ResponseWriter, r *http.Request) {
+	info := middleware.GetTokenInfo(r)
+	if info == nil {
+		respond.Error(w, r, "Token not found in context", http.StatusUnauthorized)
 		return
 	}
 
-	// Update fields
-	if updateReq.FirstName != "" {
-		user.FirstName = updateReq.FirstName
+	claims := info.Claims
+	resp := TokenResponse{
+		UserID:           claims.UserID,
+		TokenID:          claims.ID,
+		Issuer:           claims.Issuer,
+		ExpiresInSeconds: int64(info.ExpiresIn / time.Second),
+		Scopes:           claims.Scopes,
+	}
+	if claims.IssuedAt != nil {
+		resp.IssuedAt = respond.NewTime(claims.IssuedAt.Time)
+	}
+	if claims.ExpiresAt != nil {
+		resp.ExpiresAt = respond.NewTime(claims.ExpiresAt.Time)
 	}
-	if updateReq.LastName != "" {
-		user.LastName = updateReq.LastName
+	if resp.Scopes == nil {
+		resp.Scopes = []string{}
 	}
-	if updateReq.Username != "" {
-		user.Username = updateReq.Username
+	if claims.Impersonator != 0 {
+		impersonator := claims.Impersonator
+		resp.Impersonator = &impersonator
 	}
 
-	// Save updates
-	if err := h.service.UpdateUser(ctx, user); err != nil {
-		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+	respond.JSON(w, http.StatusOK, resp)
+}
+
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
 		return
 	}
 
-	// Return updated user (without password)
-	user.Password = ""
+	// A field left out is left alone; an empty string clears it, which
+	// validation refuses for the username. The email is only decoded to
+	// say where it is changed.
+	var updateReq struct {
+		FirstName *string `json:"first_name"`
+		LastName  *string `json:"last_name"`
+		Username  *string `json:"username" validate:"omitempty,min=3,max=50"`
+		Email     *string `json:"email"`
+	}
+
+	if !decodeBody(w, r, &updateReq) {
+		return
+	}
+	// Moving the account to another address takes proof the user controls
+	// it, which only POST /users/me/email asks for
+	if updateReq.Email != nil {
+		writeFieldErrors(w, r, map[string]string{"email": immutablePatchFields["email"]})
+		return
+	}
+	fields := make(map[string]interface{})
+	for _, field := range []struct {
+		name      string
+		value     *string
+		normalize func(string) string
+	}{
+		{"first_name", updateReq.FirstName, normalize.Name},
+		{"last_name", updateReq.LastName, normalize.Name},
+		{"username", updateReq.Username, normalize.Username},
+	} {
+		if field.value != nil {
+			*field.value = field.normalize(*field.value)
+			fields[field.name] = *field.value
+		}
+	}
+	if fieldErrors, err := validateRequest(updateReq); fieldErrors != nil || err != nil {
+		if err != nil {
+			respond.Error(w, r, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		writeFieldErrors(w, r, fieldErrors)
+		return
+	}
+
+	user, changed, err := h.service.UpdateProfile(r.Context(), userID, fields)
+	if err != nil {
+		var verr *application.ValidationError
+		switch {
+		case errors.As(err, &verr) && errors.Is(err, domain.ErrDuplicateUser):
+			respond.WriteError(w, r, http.StatusConflict, respond.CodeAlreadyInUse, "Already in use", verr.Fields)
+		case errors.As(err, &verr):
+			writeFieldErrors(w, r, verr.Fields)
+		case errors.Is(err, domain.ErrUserNotFound):
+			respond.Error(w, r, "User not found", http.StatusNotFound)
+		default:
+			respond.Error(w, r, "Failed to update user", http.StatusInternalServerError)
+		}
+		return
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "User updated successfully",
-		"user":    user,
+	// changed lets clients update what they hold without refetching
+	message := "User updated successfully"
+	if len(changed) == 0 {
+		message, changed = "Nothing to update", []string{}
+	}
+	respond.JSON(w, http.StatusOK, map[string]interface{}{
+		"message": message,
+		"user":    newAccountResponse(user),
+		"changed": changed,
 	})
 }
 
+// ListUsers is the listing for signed-in users, which only ever shows
+// active accounts. Only admins may add the soft-deleted ones.
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	info := middleware.GetTokenInfo(r)
+	h.listUsers(w, r, []domain.UserStatus{domain.StatusActive},
+		info != nil && info.Claims.Role == string(domain.RoleAdmin))
+}
+
+// listUsers writes one page of users in the given states along with the
+// pagination envelope, whose counts respect the same filter. The q,
+// created_after and created_before parameters narrow the listing, sort and
+// order arrange it, and include_deleted=true, if allowDeleted, adds
+// soft-deleted accounts.
+func (h *UserHandler) listUsers(w http.ResponseWriter, r *http.Request, statuses []domain.UserStatus, allowDeleted bool) {
+	page, pageSize := parsePage(r)
+	query := r.URL.Query()
+	opts := application.ListUsersOptions{
+		Filter: domain.UserFilter{Statuses: statuses, Query: query.Get("q")},
+		Sort: domain.UserSort{
+			Field: domain.UserSortField(query.Get("sort")),
+			Order: domain.SortOrder(strings.ToLower(query.Get("order"))),
+		},
+		IncludeDeleted: query.Get("include_deleted") == "true",
+	}
+	if opts.IncludeDeleted && !allowDeleted {
+		respond.Error(w, r, "Only admins can list deleted accounts", http.StatusForbidden)
+		return
+	}
+	fields := make(map[string]string)
+	for name, bound := range map[string]*time.Time{
+		"created_after":  &opts.Filter.CreatedAfter,
+		"created_before": &opts.Filter.CreatedBefore,
+	} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				fields[name] = "must be an RFC3339 timestamp"
+				continue
+			}
+			*bound = t
+		}
+	}
+	if len(fields) > 0 {
+		writeFieldErrors(w, r, fields)
 		return
 	}
 
-	// Parse query params
-	page := 1
-	pageSize := 10
+	ctx := r.Context()
+	users, total, err := h.service.ListUsers(ctx, page, pageSize, opts)
+	if err != nil {
+		var verr *application.ValidationError
+		if errors.As(err, &verr) {
+			writeFieldErrors(w, r, verr.Fields)
+			return
+		}
+		respond.Error(w, r, "Failed to list users", http.StatusInternalServerError)
+		return
+	}
+
+	accounts := make([]AccountResponse, len(users))
+	for i, user := range users {
+		accounts[i] = newAccountResponse(user)
+	}
+
+	respond.JSON(w, http.StatusOK, respond.NewPaginated(accounts, page, pageSize, total))
+}
+
+// parsePage reads the page and page_size parameters of a page-numbered
+// listing, falling back to the first page of 10 and capping the size at 100
+func parsePage(r *http.Request) (page, pageSize int) {
+	page = 1
+	pageSize = 10
 
 	if p := r.URL.Query().Get("page"); p != "" {
 		fmt.Sscanf(p, "%d", &page)
@@ -245,49 +846,50 @@ func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
 	if page <= 0 {
 		page = 1
 	}
-	ctx := r.Context()
-	users, total, err := h.service.ListUsers(ctx, page, pageSize)
-	if err != nil {
-		http.Error(w, "Failed to list users", http.StatusInternalServerError)
-		return
-	}
-
-	// Remove passwords
-	for _, user := range users {
-		user.Password = ""
-	}
+	return page, pageSize
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"users":       users,
-		"total":       total,
-		"page":        page,
-		"page_size":   pageSize,
-		"total_pages": (total + int64(pageSize) - 1) / int64(pageSize),
-	})
+// DeleteUserRequest is the body of DELETE /users/delete
+type DeleteUserRequest struct {
+	Password string `json:"password"`
 }
 
+// DeleteUser serves DELETE /users/delete, scheduling the caller's account
+// for deletion once they confirm it with their password
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		respond.Error(w, r, "User not found in context", http.StatusUnauthorized)
 		return
 	}
 
-	userID := middleware.GetUserID(r)
-	if userID == 0 {
-		http.Error(w, "User not found in context", http.StatusUnauthorized)
+	var req DeleteUserRequest
+	if !decodeOptionalBody(w, r, &req) {
+		return
+	}
+	if req.Password == "" {
+		writeFieldErrors(w, r, map[string]string{"password": "password is required"})
 		return
 	}
 
 	ctx := r.Context()
-	if err := h.service.DeleteUser(ctx, uint(userID)); err != nil {
-		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+	if err := h.service.DeleteUserWithPassword(ctx, uint(userID), req.Password); err != nil {
+		switch {
+		case errors.Is(err, application.ErrInvalidCredentials):
+			respond.WriteError(w, r, http.StatusForbidden, "invalid_password", "The password is incorrect.")
+		case errors.Is(err, application.ErrUserBanned):
+			respond.WriteError(w, r, http.StatusForbidden, "account_banned", "Account is banned")
+		case errors.Is(err, domain.ErrUserNotFound):
+			respond.Error(w, r, "User not found", http.StatusNotFound)
+		default:
+			respond.Error(w, r, "Failed to delete user", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "User delete successfully",
+	// Erasure happens after the grace period; logging in again cancels it
+	respond.JSON(w, http.StatusAccepted, map[string]interface{}{
+		"message": "Account scheduled for deletion. Log in again to cancel.",
 		"user_id": userID,
 	})
 }
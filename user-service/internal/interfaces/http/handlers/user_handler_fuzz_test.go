@@ -0,0 +1,126 @@
+// internal/interfaces/http/handlers/user_handler_fuzz_test.go
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"user-service/internal/normalize"
+)
+
+var registerSeeds = []string{
+	`{"username":"alice","email":"alice@example.com","password":"secret123"}`,
+	`{"username":"  Alice  ","email":"  ALICE@Example.com ","password":" secret123 "}`,
+	`{"username":"      ","email":"bob@example.com","password":"secret123"}`,
+	`{"username":"  ab  ","email":"bob@example.com","password":"secret123"}`,
+	`{"username":"bob","email":"bob@example.com","password":"          "}`,
+	`{"username":"bob","email":" ","password":"secret123"}`,
+	`{"username":"bob","email":"not-an-email","password":"secret123"}`,
+	`{"username":1,"email":"bob@example.com"}`,
+	`{}`,
+	`null`,
+	`not json`,
+}
+
+// FuzzParseRegisterRequest checks that whatever the body, a request that
+// passes validation yields a user whose fields are non-empty, already
+// canonical and within the declared limits
+func FuzzParseRegisterRequest(f *testing.F) {
+	for _, seed := range registerSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		user, fields, err := parseRegisterRequest(bytes.NewReader(body))
+		if err != nil || fields != nil {
+			if user != nil {
+				t.Fatalf("rejected body %q still produced a user", body)
+			}
+			return
+		}
+
+		if user.Username == "" || user.Email == "" || user.Password == "" {
+			t.Fatalf("body %q produced a user with an empty field: %+v", body, user)
+		}
+		if normalize.Username(user.Username) != user.Username ||
+			normalize.Email(user.Email) != user.Email ||
+			normalize.Password(user.Password) != user.Password {
+			t.Fatalf("body %q produced non-canonical fields: %+v", body, user)
+		}
+		if n := len([]rune(user.Username)); n < 3 || n > 50 {
+			t.Fatalf("body %q produced a %d character username", body, n)
+		}
+
+		// Parsing the accepted result again gives the same user
+		again, fields, err := parseRegisterRequest(bytes.NewReader(mustJSON(t, RegisterRequest{
+			Username: user.Username,
+			Email:    user.Email,
+			Password: user.Password,
+		})))
+		if err != nil || fields != nil || *again != *user {
+			t.Fatalf("re-parsing %+v gave %+v (%v, %v)", user, again, fields, err)
+		}
+	})
+}
+
+func FuzzParseLoginRequest(f *testing.F) {
+	f.Add([]byte(`{"email":"alice@example.com","password":"secret123"}`))
+	f.Add([]byte(`{"email":"  ALICE@example.com\t","password":" secret123 "}`))
+	f.Add([]byte(`{"email":"alice@example.com","password":"   "}`))
+	f.Add([]byte(`{"email":"","password":"secret123"}`))
+	f.Add([]byte(`[]`))
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req, fields, err := parseLoginRequest(bytes.NewReader(body))
+		if err != nil || fields != nil {
+			return
+		}
+		if req.Email == "" || req.Password == "" {
+			t.Fatalf("body %q passed validation with an empty field: %+v", body, req)
+		}
+		if normalize.Email(req.Email) != req.Email || normalize.Password(req.Password) != req.Password {
+			t.Fatalf("body %q produced non-canonical fields: %+v", body, req)
+		}
+	})
+}
+
+func TestParseRegisterRequest_ValidatesNormalizedFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantField string
+	}{
+		{"whitespace-only username", `{"username":"      ","email":"bob@example.com","password":"secret123"}`, "username"},
+		{"username short once trimmed", `{"username":"  ab  ","email":"bob@example.com","password":"secret123"}`, "username"},
+		{"whitespace-only password", `{"username":"bob","email":"bob@example.com","password":"          "}`, "password"},
+		{"password short once trimmed", `{"username":"bob","email":"bob@example.com","password":"   ab1   "}`, "password"},
+		{"blank email", `{"username":"bob","email":"   ","password":"secret123"}`, "email"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, fields, err := parseRegisterRequest(bytes.NewReader([]byte(tt.body)))
+			if err != nil {
+				t.Fatalf("unexpected decode error: %v", err)
+			}
+			if _, ok := fields[tt.wantField]; !ok {
+				t.Errorf("expected a %s error, got %v", tt.wantField, fields)
+			}
+		})
+	}
+
+	user, fields, err := parseRegisterRequest(bytes.NewReader([]byte(registerSeeds[1])))
+	if err != nil || fields != nil {
+		t.Fatalf("expected the padded request to be accepted, got %v, %v", fields, err)
+	}
+	if user.Username != "Alice" || user.Email != "alice@example.com" || user.Password != "secret123" {
+		t.Errorf("unexpected normalized user %+v", user)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return data
+}
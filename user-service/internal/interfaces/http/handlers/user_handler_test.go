@@ -0,0 +1,1421 @@
+// internal/interfaces/http/handlers/user_handler_test.go
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/interfaces/http/middleware"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/interfaces/http/router"
+	"user-service/internal/testsupport"
+)
+
+func newTestHandler(svc *testsupport.MockUserService) *UserHandler {
+	return NewUserHandler(svc, auth.NewJWTManager("test-secret", time.Hour))
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		registerFn func(ctx context.Context, user *domain.User, password, inviteCode string) (bool, error)
+		wantStatus int
+		wantCalled bool
+	}{
+		{
+			name: "success",
+			body: `{"username":"alice","email":"Alice@Example.com","password":"secret123"}`,
+			registerFn: func(ctx context.Context, user *domain.User, password, inviteCode string) (bool, error) {
+				if user.Email != "alice@example.com" {
+					return false, errors.New("email not normalized")
+				}
+				user.ID = 42
+				return false, nil
+			},
+			wantStatus: http.StatusCreated,
+			wantCalled: true,
+		},
+		{
+			name: "retry of a signup that went through",
+			body: `{"username":"alice","email":"alice@example.com","password":"secret123"}`,
+			registerFn: func(ctx context.Context, user *domain.User, password, inviteCode string) (bool, error) {
+				user.ID = 42
+				return true, nil
+			},
+			wantStatus: http.StatusOK,
+			wantCalled: true,
+		},
+		{
+			name:       "validation failure",
+			body:       `{"username":"al","email":"not-an-email","password":"123"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "malformed body",
+			body:       `{`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "weak password from service policy",
+			body: `{"username":"alice","email":"alice@example.com","password":"aaaaaaaa"}`,
+			registerFn: func(ctx context.Context, user *domain.User, password, inviteCode string) (bool, error) {
+				return false, &application.ValidationError{
+					Fields: map[string]string{"password": "too weak"},
+				}
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCalled: true,
+		},
+		{
+			name: "email already registered",
+			body: `{"username":"alice","email":"alice@example.com","password":"secret123"}`,
+			registerFn: func(ctx context.Context, user *domain.User, password, inviteCode string) (bool, error) {
+				return false, &application.ValidationError{
+					Fields: map[string]string{"email": "Email already registered"},
+					Err:    application.ErrEmailAlreadyRegistered,
+				}
+			},
+			wantStatus: http.StatusConflict,
+			wantCalled: true,
+		},
+		{
+			name: "lost the race for the email",
+			body: `{"username":"alice","email":"alice@example.com","password":"secret123"}`,
+			registerFn: func(ctx context.Context, user *domain.User, password, inviteCode string) (bool, error) {
+				return false, fmt.Errorf("failed to register user: %w", domain.ErrDuplicateUser)
+			},
+			wantStatus: http.StatusConflict,
+			wantCalled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &testsupport.MockUserService{RegisterFn: tt.registerFn}
+			h := newTestHandler(svc)
+
+			req := httptest.NewRequest(http.MethodPost, "/users/register", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			h.Register(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if svc.Called("Register") != tt.wantCalled {
+				t.Errorf("Register called = %v, want %v", svc.Called("Register"), tt.wantCalled)
+			}
+		})
+	}
+}
+
+func TestRegister_EmailOfADeletedAccount(t *testing.T) {
+	svc := &testsupport.MockUserService{
+		RegisterFn: func(ctx context.Context, user *domain.User, password, inviteCode string) (bool, error) {
+			return false, &application.ValidationError{
+				Fields: map[string]string{"email": "Email belongs to a recently deleted account"},
+				Err:    application.ErrEmailBelongsToDeletedAccount,
+			}
+		},
+	}
+	h := newTestHandler(svc)
+
+	req := httptest.NewRequest(http.MethodPost, "/users/register",
+		strings.NewReader(`{"username":"alice","email":"alice@example.com","password":"secret123"}`))
+	rr := httptest.NewRecorder()
+	h.Register(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var body respond.ErrorBody
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Error.Code != "email_belongs_to_deleted_account" || body.Error.Message == "" {
+		t.Errorf("expected the deleted account code with a message, got %v", body)
+	}
+}
+
+func TestRegister_RefusalsNameTheReason(t *testing.T) {
+	tests := []struct {
+		err      error
+		wantCode string
+	}{
+		{application.ErrRegistrationClosed, "registration_closed"},
+		{application.ErrInviteRequired, "invite_required"},
+		{domain.ErrInviteNotFound, "invite_invalid"},
+		{fmt.Errorf("failed to register user: %w", domain.ErrInviteRevoked), "invite_invalid"},
+		{fmt.Errorf("failed to register user: %w", domain.ErrInviteExpired), "invite_expired"},
+		{fmt.Errorf("failed to register user: %w", domain.ErrInviteExhausted), "invite_exhausted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantCode, func(t *testing.T) {
+			var gotCode string
+			svc := &testsupport.MockUserService{
+				RegisterFn: func(ctx context.Context, user *domain.User, password, inviteCode string) (bool, error) {
+					gotCode = inviteCode
+					return false, tt.err
+				},
+			}
+			h := newTestHandler(svc)
+
+			req := httptest.NewRequest(http.MethodPost, "/users/register",
+				strings.NewReader(`{"username":"alice","email":"alice@example.com","password":"secret123","invite_code":"k7qm-x2pd-9rtw"}`))
+			rr := httptest.NewRecorder()
+			h.Register(rr, req)
+
+			if rr.Code != http.StatusForbidden {
+				t.Fatalf("expected 403, got %d: %s", rr.Code, rr.Body.String())
+			}
+			var body respond.ErrorBody
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if body.Error.Code != tt.wantCode || body.Error.Message == "" {
+				t.Errorf("expected error %q with a message, got %v", tt.wantCode, body)
+			}
+			// Codes are normalized by the service
+			if gotCode != "k7qm-x2pd-9rtw" {
+				t.Errorf("expected the invite code to be passed on, got %q", gotCode)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	t.Run("success returns token", func(t *testing.T) {
+		svc := &testsupport.MockUserService{
+			LoginFn: func(ctx context.Context, email, password string) (*domain.User, error) {
+				return &domain.User{ID: 7, Username: "alice", Email: email}, nil
+			},
+		}
+		h := newTestHandler(svc)
+
+		req := httptest.NewRequest(http.MethodPost, "/users/login",
+			strings.NewReader(`{"email":"alice@example.com","password":"secret123"}`))
+		rr := httptest.NewRecorder()
+		h.Login(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rr.Code)
+		}
+
+		var resp struct {
+			AccessToken string       `json:"access_token"`
+			Token       string       `json:"token"`
+			TokenType   string       `json:"token_type"`
+			ExpiresIn   int64        `json:"expires_in"`
+			IssuedAt    respond.Time `json:"issued_at"`
+			ExpiresAt   respond.Time `json:"expires_at"`
+			User        UserResponse `json:"user"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.AccessToken == "" || resp.Token != resp.AccessToken {
+			t.Errorf("expected the token as access_token and token, got %q and %q", resp.AccessToken, resp.Token)
+		}
+		if resp.TokenType != "Bearer" {
+			t.Errorf("expected token_type Bearer, got %q", resp.TokenType)
+		}
+		if resp.User.ID != 7 || resp.User.Username != "alice" {
+			t.Errorf("expected the user under user, got %+v", resp.User)
+		}
+
+		claims, err := h.jwtManager.ValidateToken(resp.AccessToken)
+		if err != nil {
+			t.Fatalf("token should validate: %v", err)
+		}
+		if claims.UserID != 7 {
+			t.Errorf("expected user_id 7 in claims, got %d", claims.UserID)
+		}
+		// The manager lasts an hour; the metadata must match the token
+		if resp.ExpiresIn != int64(time.Hour/time.Second) {
+			t.Errorf("expected expires_in 3600, got %d", resp.ExpiresIn)
+		}
+		if !resp.IssuedAt.Time().Equal(claims.IssuedAt.Time) || !resp.ExpiresAt.Time().Equal(claims.ExpiresAt.Time) {
+			t.Errorf("expected issued_at %v and expires_at %v, got %v and %v",
+				claims.IssuedAt.Time, claims.ExpiresAt.Time, resp.IssuedAt.Time(), resp.ExpiresAt.Time())
+		}
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		svc := &testsupport.MockUserService{
+			LoginFn: func(ctx context.Context, email, password string) (*domain.User, error) {
+				return nil, errors.New("invalid credentials")
+			},
+		}
+		h := newTestHandler(svc)
+
+		req := httptest.NewRequest(http.MethodPost, "/users/login",
+			strings.NewReader(`{"email":"alice@example.com","password":"wrong"}`))
+		rr := httptest.NewRecorder()
+		h.Login(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401, got %d", rr.Code)
+		}
+		if strings.Contains(rr.Body.String(), "fields") {
+			t.Errorf("wrong credentials must not reveal field detail: %q", rr.Body)
+		}
+	})
+
+	t.Run("denied by login hook", func(t *testing.T) {
+		svc := &testsupport.MockUserService{
+			LoginFn: func(ctx context.Context, email, password string) (*domain.User, error) {
+				return nil, application.DenyLogin("password found in a known data breach")
+			},
+		}
+		h := newTestHandler(svc)
+
+		req := httptest.NewRequest(http.MethodPost, "/users/login",
+			strings.NewReader(`{"email":"alice@example.com","password":"password123"}`))
+		rr := httptest.NewRecorder()
+		h.Login(rr, req)
+
+		if rr.Code != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d", rr.Code)
+		}
+		var resp respond.ErrorBody
+		json.NewDecoder(rr.Body).Decode(&resp)
+		if resp.Error.Code != "login_denied" {
+			t.Errorf("expected login_denied code, got %v", resp)
+		}
+	})
+
+	t.Run("normalizes before calling the service", func(t *testing.T) {
+		var gotEmail, gotPassword string
+		svc := &testsupport.MockUserService{
+			LoginFn: func(ctx context.Context, email, password string) (*domain.User, error) {
+				gotEmail, gotPassword = email, password
+				return &domain.User{ID: 7, Email: email}, nil
+			},
+		}
+		h := newTestHandler(svc)
+
+		req := httptest.NewRequest(http.MethodPost, "/users/login",
+			strings.NewReader(`{"email":"  Alice@Example.COM\t","password":" secret123 "}`))
+		rr := httptest.NewRecorder()
+		h.Login(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rr.Code)
+		}
+		if gotEmail != "alice@example.com" || gotPassword != "secret123" {
+			t.Errorf("service got %q / %q", gotEmail, gotPassword)
+		}
+	})
+
+	validation := []struct {
+		name       string
+		body       string
+		wantFields []string
+	}{
+		{"empty body", ``, []string{"email", "password"}},
+		{"missing password", `{"email":"alice@example.com"}`, []string{"password"}},
+		{"blank password", `{"email":"alice@example.com","password":"   "}`, []string{"password"}},
+		{"invalid email syntax", `{"email":"alice-at-example.com","password":"secret123"}`, []string{"email"}},
+	}
+	for _, tt := range validation {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &testsupport.MockUserService{}
+			h := newTestHandler(svc)
+
+			req := httptest.NewRequest(http.MethodPost, "/users/login", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			h.Login(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d", rr.Code)
+			}
+			var resp respond.ErrorBody
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(resp.Error.Fields) != len(tt.wantFields) {
+				t.Errorf("expected errors for %v, got %v", tt.wantFields, resp.Error.Fields)
+			}
+			for _, field := range tt.wantFields {
+				if resp.Error.Fields[field] == "" {
+					t.Errorf("expected a %s error, got %v", field, resp.Error.Fields)
+				}
+			}
+			if svc.Called("Login") {
+				t.Error("service should not be called for an invalid request")
+			}
+		})
+	}
+
+	t.Run("wrong method", func(t *testing.T) {
+		svc := &testsupport.MockUserService{}
+		h := newTestHandler(svc)
+		rt := router.New()
+		rt.Group().HandleFunc("POST /users/login", h.Login)
+
+		req := httptest.NewRequest(http.MethodGet, "/users/login", nil)
+		rr := httptest.NewRecorder()
+		rt.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("expected 405, got %d", rr.Code)
+		}
+		if allow := rr.Header().Get("Allow"); allow != http.MethodPost {
+			t.Errorf("expected Allow: POST, got %q", allow)
+		}
+		if svc.Called("Login") {
+			t.Error("service should not be called for wrong method")
+		}
+	})
+}
+
+func TestValidateRegistration(t *testing.T) {
+	t.Run("passes", func(t *testing.T) {
+		svc := &testsupport.MockUserService{
+			ValidateRegistrationFn: func(ctx context.Context, user *domain.User, password string) error {
+				return nil
+			},
+		}
+		h := newTestHandler(svc)
+
+		req := httptest.NewRequest(http.MethodPost, "/users/register/validate",
+			strings.NewReader(`{"username":"alice","email":"alice@example.com","password":"secret123"}`))
+		rr := httptest.NewRecorder()
+		h.ValidateRegistration(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if svc.Called("Register") {
+			t.Error("dry run must never register")
+		}
+	})
+
+	t.Run("returns field map", func(t *testing.T) {
+		svc := &testsupport.MockUserService{
+			ValidateRegistrationFn: func(ctx context.Context, user *domain.User, password string) error {
+				return &application.ValidationError{
+					Fields: map[string]string{"email": "Email already registered"},
+					Err:    application.ErrEmailAlreadyRegistered,
+				}
+			},
+		}
+		h := newTestHandler(svc)
+
+		req := httptest.NewRequest(http.MethodPost, "/users/register/validate",
+			strings.NewReader(`{"username":"alice","email":"alice@example.com","password":"secret123"}`))
+		rr := httptest.NewRecorder()
+		h.ValidateRegistration(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d", rr.Code)
+		}
+		var resp respond.ErrorBody
+		json.NewDecoder(rr.Body).Decode(&resp)
+		if resp.Error.Fields["email"] == "" {
+			t.Errorf("expected email field error, got %v", resp.Error.Fields)
+		}
+	})
+}
+
+func TestLookupByEmail(t *testing.T) {
+	var gotEmail string
+	svc := &testsupport.MockUserService{
+		LookupByEmailFn: func(ctx context.Context, email, caller string) (*application.EmailLookup, error) {
+			gotEmail = email
+			return &application.EmailLookup{Exists: true, UserID: 5, EmailVerified: true}, nil
+		},
+	}
+	h := newTestHandler(svc)
+
+	req := httptest.NewRequest(http.MethodGet, "/internal/users/by-email?email=%20Alice@Example.COM%20", nil)
+	rr := httptest.NewRecorder()
+	h.LookupByEmail(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if gotEmail != "alice@example.com" {
+		t.Errorf("expected normalized email, got %q", gotEmail)
+	}
+
+	var resp map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&resp)
+	if resp["exists"] != true || resp["user_id"] != float64(5) || resp["email_verified"] != true {
+		t.Errorf("unexpected response: %v", resp)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/internal/users/by-email?email=not-an-email", nil)
+	rr = httptest.NewRecorder()
+	h.LookupByEmail(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid email, got %d", rr.Code)
+	}
+}
+
+func TestExpediteDeletion(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		expediteFn func(ctx context.Context, id uint, actorID uint, reason string) error
+		wantStatus int
+	}{
+		{
+			name: "success",
+			body: `{"user_id":5,"reason":"support ticket"}`,
+			expediteFn: func(ctx context.Context, id uint, actorID uint, reason string) error {
+				if id != 5 || !strings.HasPrefix(reason, "support ticket") {
+					return errors.New("unexpected arguments")
+				}
+				return nil
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing reason",
+			body:       `{"user_id":5}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "not pending",
+			body: `{"user_id":5,"reason":"support ticket"}`,
+			expediteFn: func(ctx context.Context, id uint, actorID uint, reason string) error {
+				return application.ErrDeletionNotPending
+			},
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name: "unknown user",
+			body: `{"user_id":5,"reason":"support ticket"}`,
+			expediteFn: func(ctx context.Context, id uint, actorID uint, reason string) error {
+				return domain.ErrUserNotFound
+			},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &testsupport.MockUserService{ExpediteDeletionFn: tt.expediteFn}
+			h := newTestHandler(svc)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/deletions/expedite", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			h.ExpediteDeletion(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeleteUser_ConfirmsPassword(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	cache := testsupport.NewUserCache()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache)
+	h := NewUserHandler(svc, auth.NewJWTManager("test-secret", time.Hour))
+	token, err := h.jwtManager.GenerateToken(alice.ID)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	deleteAccount := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/users/delete", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		middleware.AuthMiddleware(h.jwtManager)(http.HandlerFunc(h.DeleteUser)).ServeHTTP(rr, req)
+		return rr
+	}
+
+	for body, want := range map[string]int{
+		"":                         http.StatusBadRequest,
+		`{}`:                       http.StatusBadRequest,
+		`{"password":"wrong-one"}`: http.StatusForbidden,
+	} {
+		if rr := deleteAccount(body); rr.Code != want {
+			t.Errorf("%q: expected %d, got %d: %s", body, want, rr.Code, rr.Body)
+		}
+	}
+	if stored, _ := repo.User(alice.ID); stored.IsPendingDeletion() {
+		t.Fatal("expected the account kept without the right password")
+	}
+	if rr := deleteAccount(`{"password":"wrong-one"}`); !strings.Contains(rr.Body.String(), `"invalid_password"`) {
+		t.Errorf("expected the invalid_password code, got %s", rr.Body)
+	}
+
+	_, _ = svc.GetUser(context.Background(), alice.ID)
+	if rr := deleteAccount(`{"password":"secret123"}`); rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body)
+	}
+	svc.Wait()
+	stored, _ := repo.User(alice.ID)
+	if !stored.IsPendingDeletion() || stored.TokenVersion != 1 {
+		t.Errorf("expected pending deletion at token version 1, got %s at %d", stored.Status, stored.TokenVersion)
+	}
+	if _, ok := cache.Cached(alice.ID); ok {
+		t.Error("expected the cached user invalidated")
+	}
+}
+
+func TestGetUserByID(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	leaving := repo.AddUser("leaving@example.com", "secret123")
+	erased := repo.AddUser("erased@example.com", "secret123")
+	ctx := context.Background()
+	repo.UpdateFields(ctx, leaving.ID, map[string]interface{}{"status": string(domain.StatusPendingDeletion)})
+	repo.UpdateFields(ctx, erased.ID, map[string]interface{}{"status": string(domain.StatusErased)})
+	h := NewUserHandler(application.NewUserService(repo, testsupport.NewTxManager(repo), nil), auth.NewJWTManager("test-secret", time.Hour))
+	get := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users/"+id, nil)
+		req.SetPathValue("id", id)
+		rr := httptest.NewRecorder()
+		h.GetUserByID(rr, req)
+		return rr
+	}
+
+	rr := get(fmt.Sprint(alice.ID))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var account map[string]interface{}
+	json.NewDecoder(rr.Body).Decode(&account)
+	if account["Email"] != "alice@example.com" {
+		t.Errorf("expected alice, got %v", account)
+	}
+	if strings.Contains(strings.ToLower(rr.Body.String()), "password") {
+		t.Errorf("the password must never be shown: %s", rr.Body)
+	}
+
+	// Waiting out the grace period is still an account
+	rr = get(fmt.Sprint(leaving.ID))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), string(domain.StatusPendingDeletion)) {
+		t.Errorf("expected the pending account shown, got %d: %s", rr.Code, rr.Body)
+	}
+
+	for id, want := range map[string]int{
+		fmt.Sprint(erased.ID): http.StatusNotFound,
+		"999":                 http.StatusNotFound,
+		"abc":                 http.StatusBadRequest,
+		"0":                   http.StatusBadRequest,
+		"-1":                  http.StatusBadRequest,
+	} {
+		if rr := get(id); rr.Code != want {
+			t.Errorf("%s: expected %d, got %d: %s", id, want, rr.Code, rr.Body)
+		}
+	}
+}
+
+func TestGetUserByID_SlowDatabaseTimesOut(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	repo.ReadDelay = time.Second
+	h := NewUserHandler(application.NewUserService(repo, testsupport.NewTxManager(repo), nil), auth.NewJWTManager("test-secret", time.Hour))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", h.GetUserByID)
+	handler := middleware.Timeout(50 * time.Millisecond)(mux)
+
+	rr := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/users/%d", alice.ID), nil))
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504, got %d: %s", rr.Code, rr.Body)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the query abandoned at the deadline, took %v", elapsed)
+	}
+	var body respond.ErrorBody
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil || body.Error.Code != "gateway_timeout" {
+		t.Errorf("expected a gateway_timeout error body, got %+v (%v)", body, err)
+	}
+}
+
+func TestUpdateUser_Conflicts(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	bob := repo.AddUser("bob@example.com", "secret123")
+	alice.Username, bob.Username = "alice", "bob"
+	repo.Put(alice)
+	repo.Put(bob)
+	h := NewUserHandler(application.NewUserService(repo, testsupport.NewTxManager(repo), nil),
+		auth.NewJWTManager("test-secret", time.Hour))
+
+	tests := []struct {
+		name      string
+		body      string
+		wantField string
+	}{
+		{"username", `{"username":"Bob"}`, "username"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := updateRequest(t, h, alice.ID, tt.body)
+			if rr.Code != http.StatusConflict {
+				t.Fatalf("expected 409, got %d: %s", rr.Code, rr.Body)
+			}
+			var resp respond.ErrorBody
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(resp.Error.Fields) != 1 || resp.Error.Fields[tt.wantField] == "" {
+				t.Errorf("expected only a %s conflict, got %v", tt.wantField, resp.Error.Fields)
+			}
+
+			stored, _ := repo.GetByID(context.Background(), alice.ID)
+			if stored.Username != "alice" || stored.Email != "alice@example.com" {
+				t.Errorf("a rejected update must not be saved, got %+v", stored)
+			}
+		})
+	}
+
+}
+
+func TestUpdateUser_RefusesEmail(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	h := NewUserHandler(application.NewUserService(repo, testsupport.NewTxManager(repo), nil),
+		auth.NewJWTManager("test-secret", time.Hour))
+
+	// The email only changes through POST /users/me/email, which proves
+	// the new address is the user's
+	for _, body := range []string{
+		`{"email":"mallory@example.com"}`,
+		`{"email":"alice@example.com"}`,
+		`{"first_name":"Alice","email":"mallory@example.com"}`,
+	} {
+		rr := updateRequest(t, h, alice.ID, body)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d: %s", body, rr.Code, rr.Body)
+		}
+		var resp respond.ErrorBody
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || !strings.Contains(resp.Error.Fields["email"], "POST /users/me/email") {
+			t.Errorf("%s: expected the email pointed at POST /users/me/email, got %+v (%v)", body, resp, err)
+		}
+	}
+
+	stored, _ := repo.GetByID(context.Background(), alice.ID)
+	if stored.Email != "alice@example.com" || stored.FirstName != "" {
+		t.Errorf("a refused update must not be saved, got %q / %q", stored.FirstName, stored.Email)
+	}
+}
+
+func TestUpdateUser_RenameInvalidatesOldKeys(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	verified := time.Now()
+	alice.EmailVerifiedAt = &verified
+	repo.Put(alice)
+	cache := testsupport.NewUserCache()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache)
+	h := NewUserHandler(svc, auth.NewJWTManager("test-secret", time.Hour))
+
+	// Warm the cache under the old keys
+	if _, err := svc.GetUser(context.Background(), alice.ID); err != nil {
+		t.Fatalf("get user: %v", err)
+	}
+	_ = cache.SetByEmail(context.Background(), "alice@example.com", alice)
+
+	rr := updateRequest(t, h, alice.ID, `{"username":"  alice2 "}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+
+	stored, _ := repo.GetByID(context.Background(), alice.ID)
+	if stored.Username != "alice2" || stored.Email != "alice@example.com" {
+		t.Errorf("expected the normalized rename to be saved, got %q / %q", stored.Username, stored.Email)
+	}
+	if stored.EmailVerifiedAt == nil {
+		t.Error("a rename must keep the email verified")
+	}
+	if _, ok := cache.Cached(alice.ID); ok {
+		t.Error("expected the cached user to be invalidated")
+	}
+	if _, err := cache.GetByEmail(context.Background(), "alice@example.com"); err == nil {
+		t.Error("expected the email key to be invalidated")
+	}
+
+	// Keeping your own username, in any case, is not a conflict
+	if rr := updateRequest(t, h, alice.ID, `{"username":"ALICE2","first_name":"Alice"}`); rr.Code != http.StatusOK {
+		t.Errorf("expected 200 re-casing own username, got %d: %s", rr.Code, rr.Body)
+	}
+}
+
+func TestUpdateUser_ReportsChangedFields(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	alice := repo.AddUser("alice@example.com", "secret123")
+	alice.Username, alice.FirstName = "alice", "Alice"
+	repo.Put(alice)
+	cache := testsupport.NewUserCache()
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), cache)
+	h := NewUserHandler(svc, auth.NewJWTManager("test-secret", time.Hour))
+
+	tests := []struct {
+		name        string
+		body        string
+		wantChanged []string
+	}{
+		{"same values", `{"first_name":"Alice","username":"alice"}`, []string{}},
+		{"padded same value", `{"first_name":" Alice ","username":" alice"}`, []string{}},
+		// Whitespace is trimmed to an empty string, which clears
+		{"whitespace only", `{"first_name":"   ","last_name":"\t"}`, []string{"first_name"}},
+		{"partial", `{"first_name":" Al ","username":"alice3","last_name":""}`, []string{"first_name", "username"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A cached copy shows whether the update invalidated anything
+			if _, err := svc.GetUser(context.Background(), alice.ID); err != nil {
+				t.Fatalf("get user: %v", err)
+			}
+
+			rr := updateRequest(t, h, alice.ID, tt.body)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+			}
+			var resp struct {
+				Changed []string `json:"changed"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Changed == nil || fmt.Sprint(resp.Changed) != fmt.Sprint(tt.wantChanged) {
+				t.Errorf("expected changed %v, got %v", tt.wantChanged, resp.Changed)
+			}
+			if _, cached := cache.Cached(alice.ID); cached != (len(tt.wantChanged) == 0) {
+				t.Errorf("expected the cache invalidated only on a change, still cached = %v", cached)
+			}
+		})
+	}
+
+	stored, _ := repo.GetByID(context.Background(), alice.ID)
+	if stored.FirstName != "Al" || stored.Username != "alice3" || stored.LastName != "" {
+		t.Errorf("unexpected stored profile %q %q %q", stored.FirstName, stored.LastName, stored.Username)
+	}
+}
+
+func TestUpdateUser_OmittedEmptyAndSet(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	ctx := context.Background()
+	// Each case gets a fresh user%d, at user%d@example.com
+	profile := func() *domain.User {
+		user := repo.AddUser("", "secret123")
+		user.Username, user.FirstName, user.LastName = fmt.Sprintf("user%d", user.ID), "First", "Last"
+		user.Email = fmt.Sprintf("user%d@example.com", user.ID)
+		repo.Put(user)
+		return user
+	}
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+	h := NewUserHandler(svc, auth.NewJWTManager("test-secret", time.Hour))
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		// want is the stored first name, last name, username and email,
+		// with %d standing for the user's ID
+		want string
+	}{
+		{"first name omitted", `{"last_name":"Last"}`, http.StatusOK, "First Last user%d user%d@example.com"},
+		{"first name cleared", `{"first_name":""}`, http.StatusOK, " Last user%d user%d@example.com"},
+		{"first name set", `{"first_name":"Ada"}`, http.StatusOK, "Ada Last user%d user%d@example.com"},
+		{"last name omitted", `{"first_name":"First"}`, http.StatusOK, "First Last user%d user%d@example.com"},
+		{"last name cleared", `{"last_name":""}`, http.StatusOK, "First  user%d user%d@example.com"},
+		{"last name set", `{"last_name":"Lovelace"}`, http.StatusOK, "First Lovelace user%d user%d@example.com"},
+		{"username omitted", `{}`, http.StatusOK, "First Last user%d user%d@example.com"},
+		{"username cleared", `{"username":""}`, http.StatusBadRequest, "First Last user%d user%d@example.com"},
+		{"username set", `{"username":"renamed%d"}`, http.StatusOK, "First Last renamed%d user%d@example.com"},
+		{"email omitted", `{"first_name":null}`, http.StatusOK, "First Last user%d user%d@example.com"},
+		{"email cleared", `{"email":""}`, http.StatusBadRequest, "First Last user%d user%d@example.com"},
+		{"email set", `{"email":"new%d@example.com"}`, http.StatusBadRequest, "First Last user%d user%d@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := profile()
+			body := strings.ReplaceAll(tt.body, "%d", fmt.Sprint(user.ID))
+			if rr := updateRequest(t, h, user.ID, body); rr.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rr.Code, rr.Body)
+			}
+			stored, _ := repo.GetByID(ctx, user.ID)
+			got := strings.Join([]string{stored.FirstName, stored.LastName, stored.Username, stored.Email}, " ")
+			if want := strings.ReplaceAll(tt.want, "%d", fmt.Sprint(user.ID)); got != want {
+				t.Errorf("expected %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func updateRequest(t *testing.T, h *UserHandler, userID uint, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	token, err := h.jwtManager.GenerateToken(userID)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPut, "/users/update", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	middleware.AuthMiddleware(h.jwtManager)(http.HandlerFunc(h.UpdateUser)).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestRequestEmailChange_RecoverySession(t *testing.T) {
+	var got []application.EmailChange
+	svc := &testsupport.MockUserService{
+		RequestEmailChangeFn: func(ctx context.Context, id uint, change application.EmailChange) error {
+			got = append(got, change)
+			return nil
+		},
+	}
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	h := NewUserHandler(svc, jwtManager)
+	session, err := jwtManager.GenerateToken(1)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	recovery, err := jwtManager.GenerateToken(1, auth.WithScopes(auth.ScopeAccountRecovery))
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+
+	send := func(handler http.HandlerFunc, method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		middleware.AuthMiddleware(jwtManager, middleware.AllowRecoverySessions())(handler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	// A normal session proves itself with the password; a recovery
+	// session already did with a code. Either way the new address is
+	// only sent a link.
+	if rr := send(h.RequestEmailChange, http.MethodPost, "/users/me/email", session, `{"email":"new@example.com"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without the password, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := send(h.RequestEmailChange, http.MethodPost, "/users/me/email", recovery, `{"email":"new@example.com"}`); rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 from a recovery session, got %d: %s", rr.Code, rr.Body)
+	}
+	if len(got) != 1 || !got[0].Recovered || got[0].Email != "new@example.com" {
+		t.Errorf("expected one recovered change to new@example.com, got %+v", got)
+	}
+
+	// PUT /users/update can't be used to skip the link
+	if rr := send(h.UpdateUser, http.MethodPut, "/users/update", recovery, `{"email":"new@example.com"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an email update, got %d: %s", rr.Code, rr.Body)
+	}
+}
+
+func TestGetCurrentToken(t *testing.T) {
+	clock := testsupport.NewClock()
+	jwtManager := testsupport.NewJWTManager(clock, time.Hour)
+	h := NewUserHandler(&testsupport.MockUserService{}, jwtManager)
+	endpoint := middleware.AuthMiddleware(jwtManager)(http.HandlerFunc(h.GetCurrentToken))
+
+	tests := []struct {
+		name             string
+		opts             []auth.TokenOption
+		age              time.Duration
+		wantExpiresIn    int64
+		wantScopes       []string
+		wantImpersonator uint
+	}{
+		{name: "fresh token", wantExpiresIn: 3600, wantScopes: []string{}},
+		{name: "about to expire", age: 59*time.Minute + 30*time.Second, wantExpiresIn: 30, wantScopes: []string{}},
+		{
+			name:             "impersonated",
+			opts:             []auth.TokenOption{auth.WithImpersonator(99), auth.WithScopes("profile:read")},
+			age:              10 * time.Minute,
+			wantExpiresIn:    3000,
+			wantScopes:       []string{"profile:read"},
+			wantImpersonator: 99,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuedAt := clock.Now()
+			token, err := jwtManager.GenerateToken(7, tt.opts...)
+			if err != nil {
+				t.Fatalf("generate token: %v", err)
+			}
+			clock.Advance(tt.age)
+			defer clock.Advance(-tt.age)
+
+			req := httptest.NewRequest(http.MethodGet, "/users/me/token", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rr := httptest.NewRecorder()
+			endpoint.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", rr.Code)
+			}
+			if strings.Contains(rr.Body.String(), token) {
+				t.Fatal("the raw token must never be echoed back")
+			}
+
+			var resp TokenResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.UserID != 7 || resp.TokenID == "" || resp.Issuer != "user-service" {
+				t.Errorf("unexpected identity claims %+v", resp)
+			}
+			if !resp.IssuedAt.Time().Equal(issuedAt) || !resp.ExpiresAt.Time().Equal(issuedAt.Add(time.Hour)) {
+				t.Errorf("expected iat %v and exp an hour later, got %v / %v", issuedAt, resp.IssuedAt.Time(), resp.ExpiresAt.Time())
+			}
+			if resp.ExpiresInSeconds != tt.wantExpiresIn {
+				t.Errorf("expected %ds until expiry, got %d", tt.wantExpiresIn, resp.ExpiresInSeconds)
+			}
+			if fmt.Sprint(resp.Scopes) != fmt.Sprint(tt.wantScopes) {
+				t.Errorf("expected scopes %v, got %v", tt.wantScopes, resp.Scopes)
+			}
+			switch {
+			case tt.wantImpersonator == 0 && resp.Impersonator != nil:
+				t.Errorf("expected no impersonator, got %d", *resp.Impersonator)
+			case tt.wantImpersonator != 0 && (resp.Impersonator == nil || *resp.Impersonator != tt.wantImpersonator):
+				t.Errorf("expected impersonator %d, got %v", tt.wantImpersonator, resp.Impersonator)
+			}
+		})
+	}
+}
+
+// seedListing stores two users in each listable state and one erased
+// user, returning the handler over a real service
+func seedListing(t *testing.T) (*UserHandler, map[domain.UserStatus][]uint) {
+	t.Helper()
+	repo := testsupport.NewUserRepository()
+	byStatus := make(map[domain.UserStatus][]uint)
+	id := uint(1)
+	for _, status := range []domain.UserStatus{
+		domain.StatusActive, domain.StatusBanned, domain.StatusPendingDeletion,
+	} {
+		for i := 0; i < 2; i++ {
+			repo.Put(&domain.User{ID: id, Username: fmt.Sprintf("user%d", id), Status: status})
+			byStatus[status] = append(byStatus[status], id)
+			id++
+		}
+	}
+	repo.Put(&domain.User{ID: id, Username: "erased", Status: domain.StatusErased})
+	if err := repo.SoftDelete(context.Background(), id); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+
+	svc := application.NewUserService(repo, testsupport.NewTxManager(repo), nil)
+	return NewUserHandler(svc, auth.NewJWTManager("test-secret", time.Hour)), byStatus
+}
+
+type listResponse struct {
+	Users []struct {
+		ID uint `json:"ID"`
+	} `json:"items"`
+	Total      int64 `json:"total"`
+	TotalPages int64 `json:"total_pages"`
+}
+
+func getListing(t *testing.T, handler http.HandlerFunc, target string) (int, listResponse) {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest(http.MethodGet, target, nil))
+	var resp listResponse
+	if rr.Code == http.StatusOK {
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return rr.Code, resp
+}
+
+func sortedIDs(resp listResponse) []uint {
+	ids := make([]uint, len(resp.Users))
+	for i, u := range resp.Users {
+		ids[i] = u.ID
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestAdminListUsers_Tabs(t *testing.T) {
+	h, byStatus := seedListing(t)
+	all := append(append(append([]uint(nil), byStatus[domain.StatusActive]...),
+		byStatus[domain.StatusBanned]...), byStatus[domain.StatusPendingDeletion]...)
+
+	tests := []struct {
+		query string
+		want  []uint
+	}{
+		{"", all},
+		{"?status=active", byStatus[domain.StatusActive]},
+		{"?status=banned", byStatus[domain.StatusBanned]},
+		{"?status=pending_deletion", byStatus[domain.StatusPendingDeletion]},
+		{"?status=active,banned", append(append([]uint(nil), byStatus[domain.StatusActive]...), byStatus[domain.StatusBanned]...)},
+		{"?status=banned,%20pending_deletion,", append(append([]uint(nil), byStatus[domain.StatusBanned]...), byStatus[domain.StatusPendingDeletion]...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			code, resp := getListing(t, h.AdminListUsers, "/admin/users"+tt.query)
+			if code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", code)
+			}
+			if got := sortedIDs(resp); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected users %v, got %v", tt.want, got)
+			}
+			if resp.Total != int64(len(tt.want)) {
+				t.Errorf("expected total %d, got %d", len(tt.want), resp.Total)
+			}
+		})
+	}
+
+	// The total counts the whole filtered set, not just the page
+	_, resp := getListing(t, h.AdminListUsers, "/admin/users?status=active,banned&page_size=1")
+	if len(resp.Users) != 1 || resp.Total != 4 || resp.TotalPages != 4 {
+		t.Errorf("expected 1 of 4 users over 4 pages, got %d of %d over %d", len(resp.Users), resp.Total, resp.TotalPages)
+	}
+}
+
+func TestListPendingDeletions_Paginated(t *testing.T) {
+	repo := testsupport.NewUserRepository()
+	requested := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for id := uint(1); id <= 3; id++ {
+		at := requested.Add(time.Duration(id) * time.Hour)
+		repo.Put(&domain.User{ID: id, Username: fmt.Sprintf("user%d", id),
+			Status: domain.StatusPendingDeletion, DeletionRequestedAt: &at})
+	}
+	repo.Put(&domain.User{ID: 4, Username: "user4", Status: domain.StatusActive})
+	h := NewUserHandler(application.NewUserService(repo, testsupport.NewTxManager(repo), nil),
+		auth.NewJWTManager("test-secret", time.Hour))
+
+	rr := httptest.NewRecorder()
+	h.ListPendingDeletions(rr, httptest.NewRequest(http.MethodGet, "/admin/deletions?page=2&page_size=2", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Items []struct {
+			UserID uint `json:"user_id"`
+		} `json:"items"`
+		Total      int64 `json:"total"`
+		Page       int   `json:"page"`
+		TotalPages int64 `json:"total_pages"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Items) != 1 || resp.Items[0].UserID != 3 {
+		t.Errorf("expected the newest request alone on page 2, got %+v", resp.Items)
+	}
+	if resp.Total != 3 || resp.Page != 2 || resp.TotalPages != 2 {
+		t.Errorf("expected page 2 of 2 over 3 accounts, got page %d of %d over %d", resp.Page, resp.TotalPages, resp.Total)
+	}
+}
+
+func TestAdminListUsers_RejectsUnknownStatus(t *testing.T) {
+	h, _ := seedListing(t)
+	for _, query := range []string{"?status=suspended", "?status=active,deactivated", "?status=erased", "?status=ACTIVE"} {
+		if code, _ := getListing(t, h.AdminListUsers, "/admin/users"+query); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, code)
+		}
+	}
+}
+
+func TestListUsers_ShowsOnlyActiveAccounts(t *testing.T) {
+	h, byStatus := seedListing(t)
+
+	// A status filter is an admin feature; here it is ignored
+	code, resp := getListing(t, h.ListUsers, "/users?status=banned")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if got := sortedIDs(resp); fmt.Sprint(got) != fmt.Sprint(byStatus[domain.StatusActive]) {
+		t.Errorf("expected only active users %v, got %v", byStatus[domain.StatusActive], got)
+	}
+	if resp.Total != int64(len(byStatus[domain.StatusActive])) {
+		t.Errorf("expected total %d, got %d", len(byStatus[domain.StatusActive]), resp.Total)
+	}
+}
+
+func TestListUsers_Search(t *testing.T) {
+	h, byStatus := seedListing(t)
+
+	tests := []struct {
+		handler http.HandlerFunc
+		target  string
+		want    []uint
+	}{
+		{h.ListUsers, "/users?q=USER2", byStatus[domain.StatusActive][1:]},
+		{h.ListUsers, "/users?q=user", byStatus[domain.StatusActive]},
+		// Searching doesn't reach past the active accounts
+		{h.ListUsers, "/users?q=user3", nil},
+		{h.AdminListUsers, "/admin/users?q=user&status=banned", byStatus[domain.StatusBanned]},
+		{h.AdminListUsers, "/admin/users?q=nobody", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			code, resp := getListing(t, tt.handler, tt.target)
+			if code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", code)
+			}
+			if got := sortedIDs(resp); fmt.Sprint(got) != fmt.Sprint(append([]uint{}, tt.want...)) {
+				t.Errorf("expected users %v, got %v", tt.want, got)
+			}
+			if resp.Total != int64(len(tt.want)) {
+				t.Errorf("expected total %d, got %d", len(tt.want), resp.Total)
+			}
+		})
+	}
+
+	// The total is of the matches, not of every user
+	_, resp := getListing(t, h.AdminListUsers, "/admin/users?q=user&page_size=1")
+	if len(resp.Users) != 1 || resp.Total != 6 || resp.TotalPages != 6 {
+		t.Errorf("expected 1 of 6 matches over 6 pages, got %d of %d over %d", len(resp.Users), resp.Total, resp.TotalPages)
+	}
+}
+
+func TestListUsers_Sort(t *testing.T) {
+	h, _ := seedListing(t)
+
+	tests := []struct {
+		handler http.HandlerFunc
+		target  string
+		want    string
+	}{
+		{h.ListUsers, "/users?sort=username", "[1 2]"},
+		{h.ListUsers, "/users?sort=username&order=DESC", "[2 1]"},
+		{h.AdminListUsers, "/admin/users?sort=created_at&order=asc&status=active,banned", "[1 2 3 4]"},
+		{h.AdminListUsers, "/admin/users?sort=created_at&status=active,banned", "[4 3 2 1]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			code, resp := getListing(t, tt.handler, tt.target)
+			if code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", code)
+			}
+			ids := make([]uint, len(resp.Users))
+			for i, u := range resp.Users {
+				ids[i] = u.ID
+			}
+			if got := fmt.Sprint(ids); got != tt.want {
+				t.Errorf("expected users %s, got %s", tt.want, got)
+			}
+		})
+	}
+
+	// The error names what is allowed
+	for target, want := range map[string]string{
+		"/users?sort=password_hash":     "created_at, username, email, last_login",
+		"/users?sort=username&order=up": "asc or desc",
+	} {
+		rr := httptest.NewRecorder()
+		h.ListUsers(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("%s: expected 400 naming %q, got %d %s", target, want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestListUsers_CreatedRangeAndDeleted(t *testing.T) {
+	h, byStatus := seedListing(t)
+	all := append(append(append([]uint(nil), byStatus[domain.StatusActive]...),
+		byStatus[domain.StatusBanned]...), byStatus[domain.StatusPendingDeletion]...)
+
+	// seedListing's users were all created at the zero time
+	code, resp := getListing(t, h.AdminListUsers, "/admin/users?created_after=0001-01-01T00:00:00Z&created_before=2024-01-01T00:00:00%2B02:00")
+	if code != http.StatusOK || resp.Total != int64(len(all)) {
+		t.Errorf("expected all %d users, got %d (%d)", len(all), resp.Total, code)
+	}
+	if _, resp = getListing(t, h.AdminListUsers, "/admin/users?created_after=2024-01-01T00:00:00Z"); resp.Total != 0 {
+		t.Errorf("expected no users, got %d", resp.Total)
+	}
+	_, resp = getListing(t, h.AdminListUsers, "/admin/users?include_deleted=true")
+	if got := sortedIDs(resp); fmt.Sprint(got) != fmt.Sprint(append(all, 7)) {
+		t.Errorf("expected the erased user included, got %v", got)
+	}
+
+	for target, want := range map[string]int{
+		"/users?created_after=yesterday":             http.StatusBadRequest,
+		"/users?created_before=2024-01-01":           http.StatusBadRequest,
+		"/users?created_before=2024-01-01T00:00:00Z": http.StatusOK,
+		// Without an admin token
+		"/users?include_deleted=true": http.StatusForbidden,
+	} {
+		rr := httptest.NewRecorder()
+		h.ListUsers(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		if rr.Code != want {
+			t.Errorf("%s: expected %d, got %d", target, want, rr.Code)
+		}
+	}
+}
+
+type fakeActivityStats struct {
+	granularity string
+	from, to    time.Time
+	err         error
+}
+
+func (f *fakeActivityStats) Activity(ctx context.Context, granularity string, from, to time.Time) (*application.ActivityReport, error) {
+	f.granularity, f.from, f.to = granularity, from, to
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &application.ActivityReport{
+		Granularity: application.Granularity(granularity),
+		From:        from,
+		To:          to,
+		Buckets:     []application.ActivityBucket{{Start: from, Signups: 3}},
+	}, nil
+}
+
+func TestStatsActivity(t *testing.T) {
+	stats := &fakeActivityStats{}
+	h := NewStatsHandler(stats)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/stats/activity?granularity=week&from=2024-01-01&to=2024-02-01T00:00:00Z", nil)
+	rr := httptest.NewRecorder()
+	h.Activity(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if stats.granularity != "week" || !stats.from.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected arguments %q %v", stats.granularity, stats.from)
+	}
+	var resp application.ActivityReport
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || len(resp.Buckets) != 1 || resp.Buckets[0].Signups != 3 {
+		t.Errorf("unexpected response %+v (%v)", resp, err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/stats/activity?from=yesterday", nil)
+	rr = httptest.NewRecorder()
+	h.Activity(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unparseable date, got %d", rr.Code)
+	}
+
+	stats.err = &application.ValidationError{Fields: map[string]string{"to": "range too long"}}
+	req = httptest.NewRequest(http.MethodGet, "/admin/stats/activity", nil)
+	rr = httptest.NewRecorder()
+	h.Activity(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for service validation error, got %d", rr.Code)
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	var got application.NotificationPreferencesUpdate
+	var gotPatch map[string]interface{}
+	svc := &testsupport.MockUserService{
+		GetUserFn: func(ctx context.Context, id uint) (*domain.User, error) {
+			return &domain.User{
+				ID:            id,
+				Notifications: domain.NotificationPreferences{Marketing: true},
+				Preferences:   domain.Preferences{"theme": "dark"},
+			}, nil
+		},
+		UpdateNotificationPreferencesFn: func(ctx context.Context, id uint, update application.NotificationPreferencesUpdate) (*domain.NotificationPreferences, error) {
+			got = update
+			return &domain.NotificationPreferences{SecurityAlertsMuted: true}, nil
+		},
+		UpdatePreferencesFn: func(ctx context.Context, id uint, patch map[string]interface{}) (domain.Preferences, error) {
+			gotPatch = patch
+			return domain.Preferences{"theme": "dark", "locale": "en-GB"}, nil
+		},
+	}
+	h := newTestHandler(svc)
+	token, err := h.jwtManager.GenerateToken(7)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	send := func(method, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		req := httptest.NewRequest(method, "/users/me/preferences", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler := h.GetPreferences
+		if method == http.MethodPatch {
+			handler = h.UpdatePreferences
+		}
+		middleware.AuthMiddleware(h.jwtManager)(http.HandlerFunc(handler)).ServeHTTP(rr, req)
+		var doc map[string]interface{}
+		_ = json.Unmarshal(rr.Body.Bytes(), &doc)
+		return rr, doc
+	}
+
+	rr, doc := send(http.MethodGet, "")
+	want := map[string]interface{}{
+		"transactional": true, "security_critical": true, "security_alerts": true,
+		"marketing": true, "product_updates": false, "theme": "dark",
+	}
+	if rr.Code != http.StatusOK || !reflect.DeepEqual(doc, want) {
+		t.Errorf("GET: %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Only the notification switches in the patch
+	rr, doc = send(http.MethodPatch, `{"security_alerts":false,"transactional":true}`)
+	want = map[string]interface{}{
+		"transactional": true, "security_critical": true, "security_alerts": false,
+		"marketing": false, "product_updates": false, "theme": "dark",
+	}
+	if rr.Code != http.StatusOK || !reflect.DeepEqual(doc, want) {
+		t.Errorf("PATCH: %d %s", rr.Code, rr.Body.String())
+	}
+	if got.SecurityAlerts == nil || *got.SecurityAlerts || got.Marketing != nil || got.ProductUpdates != nil {
+		t.Errorf("expected only security_alerts=false to reach the service, got %+v", got)
+	}
+	if svc.Called("UpdatePreferences") {
+		t.Error("expected no display settings written")
+	}
+
+	// Display settings go to the document, without the switches
+	svc.Calls = nil
+	rr, doc = send(http.MethodPatch, `{"locale":"en-GB","marketing":true}`)
+	if rr.Code != http.StatusOK || doc["locale"] != "en-GB" || doc["theme"] != "dark" {
+		t.Errorf("PATCH settings: %d %s", rr.Code, rr.Body.String())
+	}
+	if !reflect.DeepEqual(gotPatch, map[string]interface{}{"locale": "en-GB"}) {
+		t.Errorf("expected only the locale in the patch, got %v", gotPatch)
+	}
+
+	svc.Calls = nil
+	rr, _ = send(http.MethodPatch, `{"transactional":false,"security_critical":false,"marketing":true}`)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "security_critical") {
+		t.Errorf("expected the locked categories rejected, got %d %s", rr.Code, rr.Body.String())
+	}
+	if svc.Called("UpdateNotificationPreferences") {
+		t.Error("a rejected update must not reach the service")
+	}
+
+	// An unknown key is told what is accepted, and nothing is written
+	tests := []struct {
+		body, wantField, wantListed string
+	}{
+		{`{"marketing":true,"colour":"red"}`, "colour", "accessibility, locale, marketing, product_updates, security_alerts, security_critical, theme, transactional"},
+		{`{"accessibility":{"font_size":2}}`, "accessibility.font_size", "high_contrast, reduced_motion"},
+	}
+	for _, tt := range tests {
+		svc.Calls = nil
+		rr, _ = send(http.MethodPatch, tt.body)
+		var body respond.ErrorBody
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d %s", tt.body, rr.Code, rr.Body)
+		}
+		if body.Error.Code != respond.CodeUnknownField || body.Error.Fields[tt.wantField] == "" || !strings.HasSuffix(body.Error.Message, tt.wantListed) {
+			t.Errorf("%s: expected %s refused listing %q, got %+v", tt.body, tt.wantField, tt.wantListed, body.Error)
+		}
+		if svc.Called("UpdateNotificationPreferences") || svc.Called("UpdatePreferences") {
+			t.Errorf("%s: a rejected update must not reach the service", tt.body)
+		}
+	}
+
+	rr, _ = send(http.MethodPatch, `{"theme":"sepia"}`)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "must be one of") {
+		t.Errorf("expected an unknown theme refused, got %d %s", rr.Code, rr.Body)
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+
+	"user-service/internal/interfaces/http/respond"
+)
+
+const apiClientKey = contextKey("apiClient")
+
+// APIKeyAuth protects internal endpoints. keys maps client name -> key;
+// the matching client name is stored in the request context.
+func APIKeyAuth(keys map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented := r.Header.Get("X-API-Key")
+			if presented == "" {
+				respond.Error(w, r, "missing api key", http.StatusUnauthorized)
+				return
+			}
+
+			client := ""
+			for name, key := range keys {
+				// Compare against every key so timing doesn't leak which matched
+				if subtle.ConstantTimeCompare([]byte(presented), []byte(key)) == 1 {
+					client = name
+				}
+			}
+			if client == "" {
+				respond.Error(w, r, "invalid api key", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), apiClientKey, client)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetAPIClient returns the internal client name set by APIKeyAuth
+func GetAPIClient(r *http.Request) string {
+	if v, ok := r.Context().Value(apiClientKey).(string); ok {
+		return v
+	}
+	return ""
+}
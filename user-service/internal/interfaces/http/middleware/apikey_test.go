@@ -0,0 +1,49 @@
+// internal/interfaces/http/middleware/apikey_test.go
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	var client string
+	handler := APIKeyAuth(map[string]string{"checkout": "key-1", "cart": "key-2"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client = GetAPIClient(r)
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus int
+		wantClient string
+	}{
+		{"missing key", "", http.StatusUnauthorized, ""},
+		{"wrong key", "nope", http.StatusUnauthorized, ""},
+		{"checkout key", "key-1", http.StatusOK, "checkout"},
+		{"cart key", "key-2", http.StatusOK, "cart"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client = ""
+			req := httptest.NewRequest(http.MethodGet, "/internal/users/by-email", nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if client != tt.wantClient {
+				t.Errorf("expected client %q, got %q", tt.wantClient, client)
+			}
+		})
+	}
+}
@@ -2,47 +2,476 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+	"user-service/internal/domain"
 	"user-service/internal/infrastructure/auth"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/logging"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type contextKey string
 
-const userIDKey = contextKey("userID")
+const (
+	userIDKey    = contextKey("userID")
+	tokenInfoKey = contextKey("tokenInfo")
+)
+
+// TokenInfo describes the token that authenticated the request, worked out
+// once by AuthMiddleware so handlers never re-parse the token
+type TokenInfo struct {
+	Claims    *auth.Claims
+	ExpiresIn time.Duration
+	// AccessTokenID is set when a personal access token was used; Claims
+	// then stand in for it, with its scopes and timestamps
+	AccessTokenID uint
+}
+
+// RevocationChecker reports when a user's sessions were last revoked
+type RevocationChecker interface {
+	RevokedAt(ctx context.Context, userID uint) (time.Time, bool, error)
+}
+
+// TokenRevocationChecker reports whether a single token, identified by its
+// jti, was revoked by logging out
+type TokenRevocationChecker interface {
+	IsTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+}
+
+// BlocklistChecker reports whether a user's account is inactive (banned or
+// deleted)
+type BlocklistChecker interface {
+	IsBlocked(ctx context.Context, userID uint) (bool, error)
+}
+
+// TokenVersionChecker returns the token version a user's tokens must carry
+type TokenVersionChecker interface {
+	TokenVersion(ctx context.Context, userID uint) (uint, error)
+}
+
+// SessionChecker reports whether a session's tokens are still accepted
+type SessionChecker interface {
+	SessionActive(ctx context.Context, id string) (bool, error)
+}
+
+// AccessTokenVerifier resolves a personal access token to its record,
+// failing for unknown, revoked and expired ones
+type AccessTokenVerifier interface {
+	VerifyAccessToken(ctx context.Context, token string) (*domain.AccessToken, error)
+}
+
+// AuthMiddleware outcomes besides the auth.ValidationOutcome values for a
+// rejected token
+const (
+	AuthOK              = "ok"
+	AuthMissingHeader   = "missing_header"
+	AuthBadHeader       = "bad_header"
+	AuthRevoked         = "revoked"
+	AuthAccountInactive = "account_inactive"
+	// AuthRecoveryOnly is a recovery session used outside the routes that
+	// accept one
+	AuthRecoveryOnly = "recovery_only"
+	// AuthPasswordResetOnly is a forced-reset session used anywhere but
+	// the password change
+	AuthPasswordResetOnly = "password_reset_only"
+	// AuthAccessTokenNotAllowed is a personal access token used on a route
+	// that only takes sessions
+	AuthAccessTokenNotAllowed = "access_token_not_allowed"
+	// AuthInvalidAccessToken is an unknown, revoked or expired personal
+	// access token
+	AuthInvalidAccessToken = "invalid_access_token"
+	// AuthInsufficientScope is a personal access token without the scope
+	// the request needs
+	AuthInsufficientScope = "insufficient_scope"
+)
+
+// AuthObserver receives the outcome of each AuthMiddleware check and how
+// long the check took, excluding the handler
+type AuthObserver interface {
+	ObserveAuth(outcome string, duration time.Duration)
+}
+
+type authOptions struct {
+	revocations      RevocationChecker
+	tokenRevocations TokenRevocationChecker
+	blocklist        BlocklistChecker
+	tokenVersions    TokenVersionChecker
+	sessions         SessionChecker
+	observer         AuthObserver
+	// allowRecovery accepts auth.ScopeAccountRecovery tokens
+	allowRecovery bool
+	// allowPasswordReset accepts auth.ScopePasswordReset tokens
+	allowPasswordReset bool
+	accessTokens       AccessTokenVerifier
+	// allowAccessTokens accepts personal access tokens
+	allowAccessTokens bool
+}
+
+// AuthOption configures optional AuthMiddleware checks
+type AuthOption func(*authOptions)
+
+// WithRevocationCheck rejects tokens issued before the user's sessions were
+// revoked (account deleted, logout everywhere, ...). Redis errors degrade open.
+func WithRevocationCheck(checker RevocationChecker) AuthOption {
+	return func(o *authOptions) {
+		o.revocations = checker
+	}
+}
+
+// WithTokenRevocationCheck rejects tokens that were logged out. Redis
+// errors degrade open.
+func WithTokenRevocationCheck(checker TokenRevocationChecker) AuthOption {
+	return func(o *authOptions) {
+		o.tokenRevocations = checker
+	}
+}
+
+// WithBlocklistCheck rejects tokens of banned or deleted users before they
+// expire, with the account_inactive code. The checker should cache answers
+// in-process so this costs at most one Redis call per request; lookup
+// failures degrade open, trading a brief window for availability.
+func WithBlocklistCheck(checker BlocklistChecker) AuthOption {
+	return func(o *authOptions) {
+		o.blocklist = checker
+	}
+}
+
+// WithTokenVersionCheck rejects tokens issued under an older token
+// version than the user's, i.e. before they logged out everywhere. Unlike
+// WithRevocationCheck it holds without Redis. Lookup failures degrade
+// open, except for users that no longer exist.
+func WithTokenVersionCheck(checker TokenVersionChecker) AuthOption {
+	return func(o *authOptions) {
+		o.tokenVersions = checker
+	}
+}
+
+// WithSessionCheck rejects tokens of sessions that were signed out one by
+// one. Tokens without a session ID predate sessions and pass. Lookup
+// failures degrade open.
+func WithSessionCheck(checker SessionChecker) AuthOption {
+	return func(o *authOptions) {
+		o.sessions = checker
+	}
+}
+
+// WithAuthObserver reports every request's auth outcome
+func WithAuthObserver(observer AuthObserver) AuthOption {
+	return func(o *authOptions) {
+		o.observer = observer
+	}
+}
+
+// AllowRecoverySessions accepts the restricted sessions opened with a
+// recovery code. Only routes that change the email or password use it;
+// everywhere else those tokens are refused with the recovery_session code.
+func AllowRecoverySessions() AuthOption {
+	return func(o *authOptions) {
+		o.allowRecovery = true
+	}
+}
+
+// AllowPasswordResetSessions accepts the restricted sessions Login opens
+// for accounts that must reset their password. Only the password change
+// uses it; everywhere else they are refused with password_reset_required.
+func AllowPasswordResetSessions() AuthOption {
+	return func(o *authOptions) {
+		o.allowPasswordReset = true
+	}
+}
+
+// WithAccessTokens recognizes personal access tokens, the bearer tokens
+// starting with domain.AccessTokenPrefix. Routes still have to opt in with
+// AllowAccessTokens.
+func WithAccessTokens(verifier AccessTokenVerifier) AuthOption {
+	return func(o *authOptions) {
+		o.accessTokens = verifier
+	}
+}
+
+// AllowAccessTokens accepts personal access tokens on the route, as long as
+// they have the scope the method needs: user:read for GET and HEAD,
+// user:write otherwise. Elsewhere they are refused with
+// access_token_not_allowed, so a leaked token can't manage the account.
+func AllowAccessTokens() AuthOption {
+	return func(o *authOptions) {
+		o.allowAccessTokens = true
+	}
+}
 
 // AuthMiddleware nhận vào jwtManager để validate token
-func AuthMiddleware(jwtManager *auth.JWTManager) func(http.Handler) http.Handler {
+func AuthMiddleware(jwtManager *auth.JWTManager, opts ...AuthOption) func(http.Handler) http.Handler {
+	options := &authOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			observe := func(outcome string) {
+				if options.observer != nil {
+					options.observer.ObserveAuth(outcome, time.Since(start))
+				}
+			}
+
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, "missing authorization header", http.StatusUnauthorized)
+				observe(AuthMissingHeader)
+				respond.Error(w, r, "missing authorization header", http.StatusUnauthorized)
 				return
 			}
 
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				http.Error(w, "invalid authorization header", http.StatusUnauthorized)
+				observe(AuthBadHeader)
+				respond.Error(w, r, "invalid authorization header", http.StatusUnauthorized)
 				return
 			}
 
 			tokenStr := parts[1]
 
+			if strings.HasPrefix(tokenStr, domain.AccessTokenPrefix) && options.accessTokens != nil {
+				info, outcome := checkAccessToken(w, r, options, tokenStr)
+				if info == nil {
+					observe(outcome)
+					return
+				}
+				if options.blocklist != nil && isBlocked(w, r, options.blocklist, info.Claims.UserID) {
+					observe(AuthAccountInactive)
+					return
+				}
+				noteUserID(r.Context(), info.Claims.UserID)
+				ctx := context.WithValue(r.Context(), userIDKey, info.Claims.UserID)
+				ctx = context.WithValue(ctx, tokenInfoKey, info)
+				observe(AuthOK)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			// ✅ Gọi method ValidateToken trên jwtManager
 			claims, err := jwtManager.ValidateToken(tokenStr)
+			if errors.Is(err, auth.ErrForeignToken) {
+				observe(auth.ValidationOutcome(err))
+				respond.WriteError(w, r, http.StatusUnauthorized, "wrong_audience", "This token was issued for another service.")
+				return
+			}
 			if err != nil {
-				http.Error(w, "invalid token", http.StatusUnauthorized)
+				observe(auth.ValidationOutcome(err))
+				respond.Error(w, r, "invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			if !options.allowRecovery && claims.HasScope(auth.ScopeAccountRecovery) {
+				observe(AuthRecoveryOnly)
+				respond.WriteError(w, r, http.StatusForbidden, "recovery_session", "This session can only change the account's email and password.")
+				return
+			}
+			if !options.allowPasswordReset && claims.HasScope(auth.ScopePasswordReset) {
+				observe(AuthPasswordResetOnly)
+				respond.WriteError(w, r, http.StatusForbidden, "password_reset_required", "Choose a new password before using this account.")
+				return
+			}
+
+			if options.revocations != nil && isRevoked(r.Context(), options.revocations, claims) {
+				observe(AuthRevoked)
+				respond.Error(w, r, "token has been revoked", http.StatusUnauthorized)
+				return
+			}
+			if options.tokenRevocations != nil && isTokenRevoked(r.Context(), options.tokenRevocations, claims) {
+				observe(AuthRevoked)
+				respond.Error(w, r, "token has been revoked", http.StatusUnauthorized)
+				return
+			}
+			if options.tokenVersions != nil && isStaleVersion(r.Context(), options.tokenVersions, claims) {
+				observe(AuthRevoked)
+				respond.Error(w, r, "token has been revoked", http.StatusUnauthorized)
+				return
+			}
+			if options.sessions != nil && isSessionEnded(r.Context(), options.sessions, claims) {
+				observe(AuthRevoked)
+				respond.Error(w, r, "token has been revoked", http.StatusUnauthorized)
+				return
+			}
+
+			if options.blocklist != nil && isBlocked(w, r, options.blocklist, claims.UserID) {
+				observe(AuthAccountInactive)
 				return
 			}
 
 			// Inject user_id vào context → handler có thể lấy ra
+			noteUserID(r.Context(), claims.UserID)
 			ctx := context.WithValue(r.Context(), userIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, tokenInfoKey, &TokenInfo{
+				Claims:    claims,
+				ExpiresIn: jwtManager.ExpiresIn(claims),
+			})
+			observe(AuthOK)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// isBlocked answers account_inactive for a banned or deleted user.
+// Lookup failures are logged and treated as not blocked.
+func isBlocked(w http.ResponseWriter, r *http.Request, checker BlocklistChecker, userID uint) bool {
+	blocked, err := checker.IsBlocked(r.Context(), userID)
+	if err != nil {
+		logging.Printf(r.Context(), "Blocklist check failed for user %d: %v", userID, err)
+		return false
+	}
+	if blocked {
+		respond.WriteError(w, r, http.StatusUnauthorized, "account_inactive", "This account is no longer active.")
+	}
+	return blocked
+}
+
+// checkAccessToken authenticates a personal access token, answering the
+// request itself and returning the outcome when it is refused. The
+// session checks don't apply: revoking a user's sessions deletes their
+// tokens, and the verifier refuses those of an account that can't be used.
+func checkAccessToken(w http.ResponseWriter, r *http.Request, options *authOptions, secret string) (*TokenInfo, string) {
+	if !options.allowAccessTokens {
+		respond.WriteError(w, r, http.StatusForbidden, "access_token_not_allowed", "This endpoint needs a session; personal access tokens can't be used here.")
+		return nil, AuthAccessTokenNotAllowed
+	}
+
+	token, err := options.accessTokens.VerifyAccessToken(r.Context(), secret)
+	if err != nil {
+		respond.Error(w, r, "invalid token", http.StatusUnauthorized)
+		return nil, AuthInvalidAccessToken
+	}
+
+	scope := domain.ScopeUserWrite
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		scope = domain.ScopeUserRead
+	}
+	if !token.Allows(scope) {
+		respond.WriteError(w, r, http.StatusForbidden, "insufficient_scope", "This access token needs the "+scope+" scope.")
+		return nil, AuthInsufficientScope
+	}
+
+	claims := &auth.Claims{
+		UserID: token.UserID,
+		Scopes: token.Scopes,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       domain.AccessTokenPrefix + strconv.FormatUint(uint64(token.ID), 10),
+			IssuedAt: jwt.NewNumericDate(token.CreatedAt),
+		},
+	}
+	info := &TokenInfo{Claims: claims, AccessTokenID: token.ID}
+	if token.ExpiresAt != nil {
+		claims.ExpiresAt = jwt.NewNumericDate(*token.ExpiresAt)
+		info.ExpiresIn = time.Until(*token.ExpiresAt)
+	}
+	return info, ""
+}
+
+// isRevoked reports whether the token was issued at or before the user's last
+// session revocation. Lookup failures are logged and treated as not revoked.
+func isRevoked(ctx context.Context, checker RevocationChecker, claims *auth.Claims) bool {
+	revokedAt, ok, err := checker.RevokedAt(ctx, claims.UserID)
+	if err != nil {
+		logging.Printf(ctx, "Revocation check failed for user %d: %v", claims.UserID, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	if claims.IssuedAt == nil {
+		return true
+	}
+	return !claims.IssuedAt.Time.After(revokedAt)
+}
+
+// isTokenRevoked reports whether the token itself was logged out. Tokens
+// without a jti predate logout and can't be. Lookup failures are logged
+// and treated as not revoked.
+func isTokenRevoked(ctx context.Context, checker TokenRevocationChecker, claims *auth.Claims) bool {
+	if claims.ID == "" {
+		return false
+	}
+	revoked, err := checker.IsTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		logging.Printf(ctx, "Token revocation check failed for user %d: %v", claims.UserID, err)
+		return false
+	}
+	return revoked
+}
+
+// isStaleVersion reports whether the token predates the user's current
+// token version. Lookup failures are logged and treated as current, but a
+// user that is gone has no valid tokens.
+func isStaleVersion(ctx context.Context, checker TokenVersionChecker, claims *auth.Claims) bool {
+	version, err := checker.TokenVersion(ctx, claims.UserID)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return true
+	}
+	if err != nil {
+		logging.Printf(ctx, "Token version check failed for user %d: %v", claims.UserID, err)
+		return false
+	}
+	return claims.TokenVersion < version
+}
+
+// isSessionEnded reports whether the token's session was signed out.
+// Tokens without a session can't be. Lookup failures are logged and
+// treated as active.
+func isSessionEnded(ctx context.Context, checker SessionChecker, claims *auth.Claims) bool {
+	if claims.SessionID == "" {
+		return false
+	}
+	active, err := checker.SessionActive(ctx, claims.SessionID)
+	if err != nil {
+		logging.Printf(ctx, "Session check failed for user %d: %v", claims.UserID, err)
+		return false
+	}
+	return !active
+}
+
+// GetTokenInfo returns the authenticating token's details, or nil outside
+// AuthMiddleware
+func GetTokenInfo(r *http.Request) *TokenInfo {
+	info, _ := r.Context().Value(tokenInfoKey).(*TokenInfo)
+	return info
+}
+
+// IsRecoverySession reports whether the request was authenticated with a
+// token opened by a recovery code
+func IsRecoverySession(r *http.Request) bool {
+	info := GetTokenInfo(r)
+	return info != nil && info.Claims.HasScope(auth.ScopeAccountRecovery)
+}
+
+// IsPasswordResetSession reports whether the request was authenticated with
+// the token Login issues to an account that must reset its password
+func IsPasswordResetSession(r *http.Request) bool {
+	info := GetTokenInfo(r)
+	return info != nil && info.Claims.HasScope(auth.ScopePasswordReset)
+}
+
+// RequireRole only lets through requests whose session token was issued to
+// a user with role, answering 403 otherwise. It goes inside
+// AuthMiddleware. Personal access tokens carry no role, so they never pass.
+func RequireRole(role domain.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			info := GetTokenInfo(r)
+			if info == nil || info.Claims.Role != string(role) {
+				respond.WriteError(w, r, http.StatusForbidden, "insufficient_role", "This endpoint needs the "+string(role)+" role.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // GetUserID : helper để lấy userID từ context trong handler
 func GetUserID(r *http.Request) uint {
 	if v := r.Context().Value(userIDKey); v != nil {
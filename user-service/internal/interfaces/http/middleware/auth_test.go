@@ -0,0 +1,430 @@
+// internal/interfaces/http/middleware/auth_test.go
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"user-service/internal/domain"
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/infrastructure/redis"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/testsupport"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestRedis(t testing.TB) (*miniredis.Miniredis, *redis.RedisClient) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client, err := redis.NewRedisClient(mr.Addr(), "", 0)
+	if err != nil {
+		t.Fatalf("connect to miniredis: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return mr, client
+}
+
+func authRequest(t *testing.T, handler http.Handler, token string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestAuthMiddleware_RejectsRevokedSessions(t *testing.T) {
+	_, client := newTestRedis(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	sessions := redis.NewSessionStore(client, time.Hour)
+
+	handler := AuthMiddleware(jwtManager, WithRevocationCheck(sessions))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	token, err := jwtManager.GenerateToken(1)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+
+	if code := authRequest(t, handler, token); code != http.StatusOK {
+		t.Fatalf("expected 200 before revocation, got %d", code)
+	}
+
+	// Account deletion revokes the user's sessions
+	if err := sessions.RevokeUserSessions(context.Background(), 1); err != nil {
+		t.Fatalf("revoke sessions: %v", err)
+	}
+
+	if code := authRequest(t, handler, token); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for deleted user's token, got %d", code)
+	}
+
+	// Other users are unaffected
+	other, _ := jwtManager.GenerateToken(2)
+	if code := authRequest(t, handler, other); code != http.StatusOK {
+		t.Fatalf("expected 200 for unrelated user, got %d", code)
+	}
+}
+
+func TestAuthMiddleware_RejectsLoggedOutTokens(t *testing.T) {
+	mr, client := newTestRedis(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	sessions := redis.NewSessionStore(client, time.Hour)
+
+	handler := AuthMiddleware(jwtManager, WithTokenRevocationCheck(sessions))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	token, _ := jwtManager.GenerateToken(1)
+	other, _ := jwtManager.GenerateToken(1)
+	claims, err := jwtManager.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("validate token: %v", err)
+	}
+	if err := sessions.RevokeToken(context.Background(), claims.ID, time.Minute); err != nil {
+		t.Fatalf("revoke token: %v", err)
+	}
+
+	if code := authRequest(t, handler, token); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a logged out token, got %d", code)
+	}
+	// The user's other sessions stay signed in
+	if code := authRequest(t, handler, other); code != http.StatusOK {
+		t.Fatalf("expected 200 for another token, got %d", code)
+	}
+
+	// The entry lives only as long as the token would have
+	mr.FastForward(time.Minute)
+	if revoked, _ := sessions.IsTokenRevoked(context.Background(), claims.ID); revoked {
+		t.Error("expected the revocation to expire with the token")
+	}
+
+	mr.Close()
+	if code := authRequest(t, handler, other); code != http.StatusOK {
+		t.Fatalf("expected request to pass when Redis is down, got %d", code)
+	}
+}
+
+func TestAuthMiddleware_RevocationDegradesOpen(t *testing.T) {
+	mr, client := newTestRedis(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	sessions := redis.NewSessionStore(client, time.Hour)
+
+	handler := AuthMiddleware(jwtManager, WithRevocationCheck(sessions))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	token, _ := jwtManager.GenerateToken(1)
+	mr.Close()
+
+	if code := authRequest(t, handler, token); code != http.StatusOK {
+		t.Fatalf("expected request to pass when Redis is down, got %d", code)
+	}
+}
+
+func TestAuthMiddleware_RejectsBannedUsers(t *testing.T) {
+	_, client := newTestRedis(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+
+	// Blocklist used by the service (ban) and by another instance's middleware
+	serviceList := redis.NewUserBlocklist(client, time.Hour)
+	middlewareList := redis.NewUserBlocklist(client, 50*time.Millisecond)
+
+	handler := AuthMiddleware(jwtManager, WithBlocklistCheck(middlewareList))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	token, _ := jwtManager.GenerateToken(1)
+	if code := authRequest(t, handler, token); code != http.StatusOK {
+		t.Fatalf("expected 200 before ban, got %d", code)
+	}
+
+	if err := serviceList.Block(context.Background(), 1); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+
+	// The middleware's in-process cache may serve the old answer briefly
+	time.Sleep(60 * time.Millisecond)
+	if code := authRequest(t, handler, token); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for banned user, got %d", code)
+	}
+
+	if err := serviceList.Unblock(context.Background(), 1); err != nil {
+		t.Fatalf("unblock: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if code := authRequest(t, handler, token); code != http.StatusOK {
+		t.Fatalf("expected 200 after unban, got %d", code)
+	}
+}
+
+func TestAuthMiddleware_InactiveAccountResponse(t *testing.T) {
+	_, client := newTestRedis(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	blocklist := redis.NewUserBlocklist(client, time.Hour)
+
+	handler := AuthMiddleware(jwtManager, WithBlocklistCheck(blocklist))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	// Deleting an account puts it on the same list as a ban
+	if err := blocklist.Block(context.Background(), 1); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+	token, _ := jwtManager.GenerateToken(1)
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rr.Code)
+	}
+	var body respond.ErrorBody
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body.Error.Code != "account_inactive" {
+		t.Errorf("expected account_inactive, got %v", body)
+	}
+}
+
+func TestAuthMiddleware_BlocklistCostsAtMostOneRedisCall(t *testing.T) {
+	mr, client := newTestRedis(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+
+	handler := AuthMiddleware(jwtManager, WithBlocklistCheck(redis.NewUserBlocklist(client, time.Hour)))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	token, _ := jwtManager.GenerateToken(1)
+
+	before := mr.CommandCount()
+	authRequest(t, handler, token)
+	if n := mr.CommandCount() - before; n != 1 {
+		t.Errorf("expected one Redis call on a cold cache, got %d", n)
+	}
+
+	before = mr.CommandCount()
+	for i := 0; i < 5; i++ {
+		authRequest(t, handler, token)
+	}
+	if n := mr.CommandCount() - before; n != 0 {
+		t.Errorf("expected cached lookups to skip Redis, got %d calls", n)
+	}
+}
+
+func TestAuthMiddleware_BlocklistDegradesOpen(t *testing.T) {
+	mr, client := newTestRedis(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	blocklist := redis.NewUserBlocklist(client, 50*time.Millisecond)
+
+	handler := AuthMiddleware(jwtManager, WithBlocklistCheck(blocklist))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	if err := blocklist.Block(context.Background(), 1); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+	blocked, _ := jwtManager.GenerateToken(1)
+	active, _ := jwtManager.GenerateToken(2)
+	mr.Close()
+
+	// A cached answer still applies while Redis is down...
+	if code := authRequest(t, handler, blocked); code != http.StatusUnauthorized {
+		t.Fatalf("expected the cached block to hold, got %d", code)
+	}
+	// ...but an unknown user is let through rather than locked out
+	if code := authRequest(t, handler, active); code != http.StatusOK {
+		t.Fatalf("expected request to pass when Redis is down, got %d", code)
+	}
+
+	// Once the cached entry expires the blocked user is let through too:
+	// availability wins over enforcement during an outage
+	time.Sleep(60 * time.Millisecond)
+	if code := authRequest(t, handler, blocked); code != http.StatusOK {
+		t.Fatalf("expected request to pass once the cache expired, got %d", code)
+	}
+}
+
+func TestAuthMiddleware_RejectsExpiredTokens(t *testing.T) {
+	clock := testsupport.NewClock()
+	jwtManager := testsupport.NewJWTManager(clock, time.Hour)
+
+	handler := AuthMiddleware(jwtManager)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	token, err := jwtManager.GenerateToken(1)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+
+	clock.Advance(59 * time.Minute)
+	if code := authRequest(t, handler, token); code != http.StatusOK {
+		t.Fatalf("expected 200 before expiry, got %d", code)
+	}
+
+	clock.Advance(2 * time.Minute)
+	if code := authRequest(t, handler, token); code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after expiry, got %d", code)
+	}
+}
+
+func TestAuthMiddleware_RestrictedSessions(t *testing.T) {
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	everywhere := AuthMiddleware(jwtManager)(ok)
+	emailOrPassword := AuthMiddleware(jwtManager, AllowRecoverySessions())(ok)
+	passwordChange := AuthMiddleware(jwtManager, AllowRecoverySessions(), AllowPasswordResetSessions())(ok)
+
+	full, _ := jwtManager.GenerateToken(1)
+	recovery, _ := jwtManager.GenerateToken(1, auth.WithScopes(auth.ScopeAccountRecovery))
+	reset, _ := jwtManager.GenerateToken(1, auth.WithScopes(auth.ScopePasswordReset))
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		token   string
+		want    int
+	}{
+		{"full session anywhere", everywhere, full, http.StatusOK},
+		{"recovery session on ordinary routes", everywhere, recovery, http.StatusForbidden},
+		{"recovery session on email change", emailOrPassword, recovery, http.StatusOK},
+		{"reset session on ordinary routes", everywhere, reset, http.StatusForbidden},
+		{"reset session on email change", emailOrPassword, reset, http.StatusForbidden},
+		{"reset session on password change", passwordChange, reset, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := authRequest(t, tt.handler, tt.token); code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, code)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	adminOnly := AuthMiddleware(jwtManager)(RequireRole(domain.RoleAdmin)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	))
+
+	admin, _ := jwtManager.GenerateToken(1, auth.WithRole(string(domain.RoleAdmin)))
+	user, _ := jwtManager.GenerateToken(2, auth.WithRole(string(domain.RoleUser)))
+	// Tokens issued before roles were added to the claims
+	legacy, _ := jwtManager.GenerateToken(3)
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"admin", admin, http.StatusOK},
+		{"user", user, http.StatusForbidden},
+		{"no role claim", legacy, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := authRequest(t, adminOnly, tt.token); code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, code)
+			}
+		})
+	}
+
+	// Outside AuthMiddleware there is no token to check
+	rr := httptest.NewRecorder()
+	RequireRole(domain.RoleAdmin)(http.NotFoundHandler()).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without a token, got %d", rr.Code)
+	}
+}
+
+func TestAuthMiddleware_ForeignTokens(t *testing.T) {
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour, auth.WithAudience("user-service"))
+	handler := AuthMiddleware(jwtManager)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Minted by another service sharing the secret
+	token, _ := auth.NewJWTManager("test-secret", time.Hour, auth.WithAudience("cart-service")).GenerateToken(1)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var body respond.ErrorBody
+	json.NewDecoder(rr.Body).Decode(&body)
+	if rr.Code != http.StatusUnauthorized || body.Error.Code != "wrong_audience" {
+		t.Errorf("expected 401 wrong_audience, got %d %v", rr.Code, body)
+	}
+}
+
+type fakeTokenVersions map[uint]uint
+
+func (v fakeTokenVersions) TokenVersion(ctx context.Context, userID uint) (uint, error) {
+	version, ok := v[userID]
+	if !ok {
+		return 0, domain.ErrUserNotFound
+	}
+	return version, nil
+}
+
+func TestAuthMiddleware_RejectsStaleTokenVersions(t *testing.T) {
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	versions := fakeTokenVersions{1: 2}
+	handler := AuthMiddleware(jwtManager, WithTokenVersionCheck(versions))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	current, _ := jwtManager.GenerateToken(1, auth.WithTokenVersion(2))
+	stale, _ := jwtManager.GenerateToken(1, auth.WithTokenVersion(1))
+	unversioned, _ := jwtManager.GenerateToken(1)
+	gone, _ := jwtManager.GenerateToken(2)
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"current", current, http.StatusOK},
+		{"stale", stale, http.StatusUnauthorized},
+		{"issued before versions", unversioned, http.StatusUnauthorized},
+		{"user gone", gone, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := authRequest(t, handler, tt.token); code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, code)
+			}
+		})
+	}
+}
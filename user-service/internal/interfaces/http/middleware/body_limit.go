@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+)
+
+// limitedBody is a request body capped by MaxBodyBytes. It keeps the body
+// it wraps so a route's own limit replaces the global one instead of
+// nesting inside it, where the smaller of the two would always win.
+type limitedBody struct {
+	io.ReadCloser
+	unlimited io.ReadCloser
+}
+
+// MaxBodyBytes caps request bodies at n bytes with http.MaxBytesReader.
+// Reading past the cap fails with an *http.MaxBytesError, which the
+// handlers' decoder answers with a 413. Applied again closer to a route,
+// such as an upload, the inner limit is the one that counts.
+func MaxBodyBytes(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body != nil && r.Body != http.NoBody {
+				body := r.Body
+				if limited, ok := body.(*limitedBody); ok {
+					body = limited.unlimited
+				}
+				r.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, body, n), unlimited: body}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// internal/interfaces/http/middleware/body_limit_test.go
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readBody answers with how much of the body it read, or 413 with the
+// limit it hit
+func readBody(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.Write(body)
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	global := MaxBodyBytes(10)
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		body       string
+		wantStatus int
+	}{
+		{"within the limit", global(http.HandlerFunc(readBody)), "0123456789", http.StatusOK},
+		{"over the limit", global(http.HandlerFunc(readBody)), "0123456789a", http.StatusRequestEntityTooLarge},
+		// A route's own limit replaces the global one, larger or smaller
+		{"raised by the route", global(MaxBodyBytes(20)(http.HandlerFunc(readBody))), strings.Repeat("a", 15), http.StatusOK},
+		{"lowered by the route", global(MaxBodyBytes(5)(http.HandlerFunc(readBody))), "0123456", http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus == http.StatusOK && rec.Body.String() != tt.body {
+				t.Errorf("expected the whole body read, got %q", rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"user-service/internal/application"
+)
+
+// ClientInfo stores the caller's IP and user agent in the context for the
+// service layer, which quotes them in security alerts
+func ClientInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := application.WithClientInfo(r.Context(), application.ClientInfo{
+			IP:        getClientIP(r),
+			UserAgent: r.UserAgent(),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
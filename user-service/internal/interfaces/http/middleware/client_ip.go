@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// TrustedProxies are the networks whose forwarding headers ClientIP
+// believes: the load balancers and proxies in front of the service
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies accepts CIDRs such as 10.0.0.0/8, or single
+// addresses
+func ParseTrustedProxies(entries []string) (TrustedProxies, error) {
+	trusted := make(TrustedProxies, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			trusted = append(trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		trusted = append(trusted, prefix.Masked())
+	}
+	return trusted, nil
+}
+
+func (t TrustedProxies) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range t {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIPOption configures ClientIP
+type ClientIPOption func(*clientIPOptions)
+
+type clientIPOptions struct {
+	header string
+}
+
+// WithClientIPHeader names the header the trusted proxies report the
+// client in: X-Forwarded-For, the default, Forwarded (RFC 7239) or
+// X-Real-IP. Only the one the proxies set can be trusted; any other
+// reaches the service as the client sent it.
+func WithClientIPHeader(header string) ClientIPOption {
+	return func(o *clientIPOptions) {
+		if header != "" {
+			o.header = http.CanonicalHeaderKey(header)
+		}
+	}
+}
+
+// ClientIP works out the client's address once for everything inside it,
+// the rate limits, logs, traces and security alerts. A request straight
+// from the client is known by its connection's address, and forwarding
+// headers are ignored: anyone can send them. Only from a trusted proxy is
+// the header believed, and then it's read right to left, past the trusted
+// proxies each appending the address they were connected from, to the
+// first hop that isn't one. Entries left of that are the client's word.
+func ClientIP(trusted TrustedProxies, opts ...ClientIPOption) func(http.Handler) http.Handler {
+	options := clientIPOptions{header: "X-Forwarded-For"}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, trusted, options.header)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+		})
+	}
+}
+
+func resolveClientIP(r *http.Request, trusted TrustedProxies, header string) string {
+	peer, ok := parseHop(r.RemoteAddr)
+	if !ok {
+		return remoteHost(r)
+	}
+	if !trusted.contains(peer) {
+		return peer.String()
+	}
+
+	var hops []string
+	switch header {
+	case "Forwarded":
+		hops = forwardedFor(r.Header.Values("Forwarded"))
+	case "X-Real-Ip":
+		hops = []string{r.Header.Get("X-Real-IP")}
+	default:
+		for _, value := range r.Header.Values(header) {
+			hops = append(hops, strings.Split(value, ",")...)
+		}
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			// A hop the proxy couldn't name, such as "unknown": the
+			// nearest address known is as far as it goes
+			break
+		}
+		client = hop
+		if !trusted.contains(hop) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// forwardedFor lists the for= node of each element of Forwarded headers,
+// such as for=192.0.2.60;proto=https or for="[2001:db8::17]:4711"
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			node := ""
+			for _, pair := range strings.Split(element, ";") {
+				name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(name, "for") {
+					node = strings.Trim(value, `"`)
+				}
+			}
+			hops = append(hops, node)
+		}
+	}
+	return hops
+}
+
+// parseHop reads an address as headers and RemoteAddr give it, with or
+// without a port, IPv6 bracketed or not
+func parseHop(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// remoteHost is the host of RemoteAddr, whatever it holds
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
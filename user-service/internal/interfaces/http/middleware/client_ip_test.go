@@ -0,0 +1,170 @@
+// internal/interfaces/http/middleware/client_ip_test.go
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "2001:db8:ffff::/48", "192.0.2.10"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		remote  string
+		header  string
+		headers map[string][]string
+		want    string
+	}{
+		{
+			name:   "direct client",
+			remote: "203.0.113.7:4000",
+			want:   "203.0.113.7",
+		},
+		{
+			name:    "spoofed X-Forwarded-For from an untrusted peer",
+			remote:  "203.0.113.7:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "spoofed X-Real-IP from an untrusted peer",
+			remote:  "203.0.113.7:4000",
+			header:  "X-Real-IP",
+			headers: map[string][]string{"X-Real-IP": {"198.51.100.1"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "spoofed Forwarded from an untrusted peer",
+			remote:  "203.0.113.7:4000",
+			header:  "Forwarded",
+			headers: map[string][]string{"Forwarded": {"for=198.51.100.1"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "one trusted proxy",
+			remote:  "10.0.0.5:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "trusted hops are walked past",
+			remote:  "10.0.0.5:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7, 192.0.2.10, 10.1.2.3"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "entries the client prepended are ignored",
+			remote:  "10.0.0.5:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1, 10.9.9.9, 203.0.113.7, 10.1.2.3"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "headers repeated across lines read as one chain",
+			remote:  "10.0.0.5:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1", "203.0.113.7, 10.1.2.3"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "every hop trusted",
+			remote:  "10.0.0.5:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"10.2.2.2, 10.1.1.1"}},
+			want:    "10.2.2.2",
+		},
+		{
+			name:    "a hop the proxy couldn't name stops the walk",
+			remote:  "10.0.0.5:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1, unknown, 10.1.2.3"}},
+			want:    "10.1.2.3",
+		},
+		{
+			name:   "trusted proxy without the header",
+			remote: "10.0.0.5:4000",
+			want:   "10.0.0.5",
+		},
+		{
+			name:    "the configured header only",
+			remote:  "10.0.0.5:4000",
+			header:  "Forwarded",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			want:    "10.0.0.5",
+		},
+		{
+			name:    "IPv6 with ports",
+			remote:  "[2001:db8:ffff::1]:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"[2001:db8::17]:4711, 2001:db8:ffff::2"}},
+			want:    "2001:db8::17",
+		},
+		{
+			name:    "IPv4-mapped IPv6 peer",
+			remote:  "[::ffff:10.0.0.5]:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "X-Real-IP",
+			remote:  "10.0.0.5:4000",
+			header:  "X-Real-IP",
+			headers: map[string][]string{"X-Real-IP": {"203.0.113.7"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "Forwarded",
+			remote:  "10.0.0.5:4000",
+			header:  "Forwarded",
+			headers: map[string][]string{"Forwarded": {`for=198.51.100.1, For="[2001:db8::17]:4711";proto=https, for=10.1.2.3;by=10.0.0.5`}},
+			want:    "2001:db8::17",
+		},
+		{
+			name:    "Forwarded with an obfuscated hop",
+			remote:  "10.0.0.5:4000",
+			header:  "Forwarded",
+			headers: map[string][]string{"Forwarded": {"for=203.0.113.7, for=_hidden, for=10.1.2.3"}},
+			want:    "10.1.2.3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := ClientIP(trusted, WithClientIPHeader(tt.header))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = getClientIP(r)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+			req.RemoteAddr = tt.remote
+			for name, values := range tt.headers {
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClientIP_WithoutTheMiddlewareHeadersAreIgnored(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.RemoteAddr = "203.0.113.7:4000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := getClientIP(req); got != "203.0.113.7" {
+		t.Errorf("expected the connection's address, got %s", got)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/8", "::1", "192.0.2.1"}); err != nil {
+		t.Errorf("expected CIDRs and addresses accepted, got %v", err)
+	}
+	for _, bad := range []string{"10.0.0.0/33", "proxy.internal", ""} {
+		if _, err := ParseTrustedProxies([]string{bad}); err == nil {
+			t.Errorf("expected %q refused", bad)
+		}
+	}
+}
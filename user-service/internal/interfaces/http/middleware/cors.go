@@ -1,18 +1,135 @@
 package middleware
 
-import "net/http"
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
 
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// CORSConfig says which browser origins may call the API and how
+type CORSConfig struct {
+	// AllowedOrigins are origins such as https://shop.example.com. A
+	// leading "*." in the host, as in https://*.preview.example.com,
+	// matches any subdomain; "*" alone matches every origin.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are response headers scripts may read beyond the
+	// handful browsers always allow
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and Authorization with
+	// cross-origin requests. It can't be combined with "*".
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight answer
+	MaxAge time.Duration
+}
+
+// originPattern is one of AllowedOrigins
+type originPattern struct {
+	any bool
+	// prefix and suffix surround the subdomain of a wildcard, such as
+	// "https://" and ".preview.example.com"; exact origins have no suffix
+	prefix, suffix string
+}
+
+func parseOriginPattern(origin string) originPattern {
+	origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+	if origin == "*" {
+		return originPattern{any: true}
+	}
+	if scheme, host, ok := strings.Cut(origin, "://*."); ok {
+		return originPattern{prefix: scheme + "://", suffix: "." + host}
+	}
+	return originPattern{prefix: origin}
+}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+func (p originPattern) matches(origin string) bool {
+	if p.any {
+		return true
+	}
+	if p.suffix == "" {
+		return origin == p.prefix
+	}
+	if !strings.HasPrefix(origin, p.prefix) || !strings.HasSuffix(origin, p.suffix) {
+		return false
+	}
+	// The subdomain can't smuggle in a path, port or credentials
+	sub := origin[len(p.prefix) : len(origin)-len(p.suffix)]
+	return sub != "" && !strings.ContainsAny(sub, "/:@?#")
+}
+
+// CORS answers cross-origin requests from the origins cfg allows. A
+// preflight is answered here, before auth and rate limiting, which it
+// couldn't pass: browsers send it without credentials. Requests from any
+// other origin get no CORS headers at all, so the browser withholds the
+// response from the page; the request itself still runs, as CORS only
+// ever protects what the page can read.
+func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
+	patterns := make([]originPattern, len(cfg.AllowedOrigins))
+	anyOrigin := false
+	for i, origin := range cfg.AllowedOrigins {
+		patterns[i] = parseOriginPattern(origin)
+		anyOrigin = anyOrigin || patterns[i].any
+	}
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	exposed := strings.Join(cfg.ExposedHeaders, ", ")
+	maxAge := strconv.FormatInt(int64(cfg.MaxAge/time.Second), 10)
+
+	allowed := func(origin string) bool {
+		origin = strings.ToLower(origin)
+		for _, p := range patterns {
+			if p.matches(origin) {
+				return true
+			}
 		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			// Caches must not hand one origin's answer to another
+			h.Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if allowed(origin) {
+				if anyOrigin && !cfg.AllowCredentials {
+					h.Set("Access-Control-Allow-Origin", "*")
+				} else {
+					h.Set("Access-Control-Allow-Origin", origin)
+				}
+				if cfg.AllowCredentials {
+					h.Set("Access-Control-Allow-Credentials", "true")
+				}
+				if preflight {
+					h.Set("Access-Control-Allow-Methods", methods)
+					if headers != "" {
+						h.Set("Access-Control-Allow-Headers", headers)
+					}
+					if cfg.MaxAge > 0 {
+						h.Set("Access-Control-Max-Age", maxAge)
+					}
+				} else if exposed != "" {
+					h.Set("Access-Control-Expose-Headers", exposed)
+				}
+			}
 
-		next.ServeHTTP(w, r)
-	})
+			if preflight {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
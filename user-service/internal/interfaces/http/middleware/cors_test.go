@@ -0,0 +1,114 @@
+// internal/interfaces/http/middleware/cors_test.go
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	cfg := CORSConfig{
+		AllowedOrigins:   []string{"https://shop.example.com", "https://*.preview.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}
+
+	tests := []struct {
+		name      string
+		cfg       CORSConfig
+		origin    string
+		preflight bool
+		// wantOrigin is the Access-Control-Allow-Origin expected, "" for
+		// no CORS headers at all
+		wantOrigin string
+	}{
+		{name: "exact", cfg: cfg, origin: "https://shop.example.com", wantOrigin: "https://shop.example.com"},
+		{name: "exact preflight", cfg: cfg, origin: "https://shop.example.com", preflight: true, wantOrigin: "https://shop.example.com"},
+		{name: "wildcard", cfg: cfg, origin: "https://pr-42.preview.example.com", wantOrigin: "https://pr-42.preview.example.com"},
+		{name: "nested wildcard", cfg: cfg, origin: "https://a.b.preview.example.com", preflight: true, wantOrigin: "https://a.b.preview.example.com"},
+		{name: "case", cfg: cfg, origin: "https://Shop.Example.com", wantOrigin: "https://Shop.Example.com"},
+		{name: "denied", cfg: cfg, origin: "https://evil.example.net"},
+		{name: "denied preflight", cfg: cfg, origin: "https://evil.example.net", preflight: true},
+		{name: "wildcard needs a subdomain", cfg: cfg, origin: "https://preview.example.com"},
+		{name: "wildcard suffix only", cfg: cfg, origin: "https://evilpreview.example.com"},
+		{name: "wildcard as a prefix", cfg: cfg, origin: "https://x.preview.example.com.evil.net"},
+		{name: "wildcard with port", cfg: cfg, origin: "https://x.preview.example.com:8443"},
+		{name: "wrong scheme", cfg: cfg, origin: "http://shop.example.com"},
+		{name: "subdomain of exact", cfg: cfg, origin: "https://www.shop.example.com"},
+		{name: "any origin", cfg: CORSConfig{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}},
+			origin: "https://anyone.example.org", preflight: true, wantOrigin: "*"},
+		{name: "none allowed", cfg: CORSConfig{}, origin: "https://shop.example.com", preflight: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached := false
+			handler := CORS(tt.cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reached = true
+			}))
+
+			method := http.MethodGet
+			if tt.preflight {
+				method = http.MethodOptions
+			}
+			req := httptest.NewRequest(method, "/users/me", nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if reached == tt.preflight {
+				t.Errorf("expected preflights answered by CORS and nothing else, reached the handler: %v", reached)
+			}
+			if tt.preflight && rec.Code != http.StatusNoContent {
+				t.Errorf("expected a preflight answered 204, got %d", rec.Code)
+			}
+			if !strings.Contains(strings.Join(rec.Header().Values("Vary"), ","), "Origin") {
+				t.Errorf("expected Vary: Origin, got %v", rec.Header())
+			}
+
+			got := rec.Header().Get("Access-Control-Allow-Origin")
+			if got != tt.wantOrigin {
+				t.Fatalf("expected Access-Control-Allow-Origin %q, got %q", tt.wantOrigin, got)
+			}
+			if tt.wantOrigin == "" {
+				for header := range rec.Header() {
+					if strings.HasPrefix(header, "Access-Control-") {
+						t.Errorf("expected no CORS headers, got %s", header)
+					}
+				}
+				return
+			}
+			if tt.cfg.AllowCredentials && rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Error("expected credentials allowed")
+			}
+			if tt.preflight {
+				if rec.Header().Get("Access-Control-Allow-Methods") != strings.Join(tt.cfg.AllowedMethods, ", ") {
+					t.Errorf("expected the allowed methods, got %v", rec.Header())
+				}
+			} else if rec.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+				t.Errorf("expected the exposed headers, got %v", rec.Header())
+			}
+		})
+	}
+}
+
+func TestCORS_SameOriginUntouched(t *testing.T) {
+	handler := CORS(CORSConfig{AllowedOrigins: []string{"*"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+
+	// An OPTIONS without an Origin isn't a preflight; the router answers it
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, "/users/me", nil))
+	if rec.Code != http.StatusMethodNotAllowed || len(rec.Header()) != 0 {
+		t.Errorf("expected the request passed through untouched, got %d %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"user-service/internal/infrastructure/redis"
+	"user-service/internal/logging"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultDedupWindow is how long a request's response is handed to
+	// identical requests, counted from when it started
+	DefaultDedupWindow = 5 * time.Second
+
+	// dedupPollInterval is how often a duplicate checks for the response
+	dedupPollInterval = 25 * time.Millisecond
+)
+
+// RedisDeduplicator collapses identical requests that arrive together, like
+// a double-clicked submit button: the first runs and the others wait for
+// its response and get a copy. Server errors aren't shared; waiters then
+// run for real.
+type RedisDeduplicator struct {
+	client *redis.RedisClient
+	scope  string
+	window time.Duration
+}
+
+func NewRedisDeduplicator(client *redis.RedisClient, scope string, window time.Duration) *RedisDeduplicator {
+	return &RedisDeduplicator{client: client, scope: scope, window: window}
+}
+
+// DedupKey hashes parts into a key, so emails and IPs aren't stored in Redis
+// as they are
+func DedupKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Serve runs serve unless a request with the same key is already running
+// or finished within the window, in which case it replays that response.
+// Duplicates give up waiting when the window ends and run serve
+// themselves, as does everything while Redis is down.
+func (d *RedisDeduplicator) Serve(w http.ResponseWriter, r *http.Request, key string, serve http.HandlerFunc) {
+	ctx := r.Context()
+	redisKey := "dedup:" + d.scope + ":" + key
+
+	pending, _ := json.Marshal(idempotentResponse{})
+	claimed, err := d.client.SetNX(ctx, redisKey, string(pending), d.window)
+	if err != nil {
+		logging.Printf(ctx, "Redis dedup error: %v", err)
+		serve(w, r)
+		return
+	}
+	if !claimed {
+		if stored, ok := d.await(ctx, redisKey); ok {
+			writeStoredResponse(w, stored)
+			return
+		}
+		serve(w, r)
+		return
+	}
+
+	rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	serve(rec, r)
+
+	// Duplicates are waiting even if this client has gone
+	storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+	defer cancel()
+	if rec.status >= http.StatusInternalServerError {
+		if err := d.client.Delete(storeCtx, redisKey); err != nil {
+			logging.Printf(ctx, "Redis dedup error: %v", err)
+		}
+		return
+	}
+	stored := idempotentResponse{
+		Status:      rec.status,
+		ContentType: rec.Header().Get("Content-Type"),
+		Body:        rec.body.Bytes(),
+	}
+	// Keep the key's expiry so the window stays counted from the start
+	ttl, err := d.client.TTL(storeCtx, redisKey)
+	if err != nil || ttl <= 0 {
+		return
+	}
+	if err := d.client.Set(storeCtx, redisKey, stored, ttl); err != nil {
+		logging.Printf(ctx, "Redis dedup error: %v", err)
+	}
+}
+
+// await polls for the first request's response until it's stored, the key
+// goes away or the window is up
+func (d *RedisDeduplicator) await(ctx context.Context, redisKey string) (idempotentResponse, bool) {
+	ctx, cancel := context.WithTimeout(ctx, d.window)
+	defer cancel()
+	ticker := time.NewTicker(dedupPollInterval)
+	defer ticker.Stop()
+
+	for {
+		var stored idempotentResponse
+		err := d.client.Get(ctx, redisKey, &stored)
+		switch {
+		case errors.Is(err, goredis.Nil):
+			// The first request failed or the window ended
+			return idempotentResponse{}, false
+		case err != nil:
+			if ctx.Err() == nil {
+				logging.Printf(ctx, "Redis dedup error: %v", err)
+			}
+			return idempotentResponse{}, false
+		case stored.Status != 0:
+			return stored, true
+		}
+
+		select {
+		case <-ctx.Done():
+			return idempotentResponse{}, false
+		case <-ticker.C:
+		}
+	}
+}
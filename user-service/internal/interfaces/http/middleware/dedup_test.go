@@ -0,0 +1,107 @@
+// internal/interfaces/http/middleware/dedup_test.go
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRedisDeduplicator_DuplicateGetsTheFirstResponse(t *testing.T) {
+	_, client := newTestRedis(t)
+	dedup := NewRedisDeduplicator(client, "register", DefaultDedupWindow)
+
+	var calls atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if n == 1 {
+			close(started)
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"call":%d}`, n)
+	}
+	send := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		dedup.Serve(rec, httptest.NewRequest(http.MethodPost, "/users/register", nil), DedupKey("alice@example.com", "10.0.0.1"), serve)
+		return rec
+	}
+
+	var wg sync.WaitGroup
+	var first, second *httptest.ResponseRecorder
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		first = send()
+	}()
+	<-started
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		second = send()
+	}()
+	// Let the duplicate start waiting before the first finishes
+	time.Sleep(3 * dedupPollInterval)
+	close(release)
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected one registration, got %d", calls.Load())
+	}
+	for _, rec := range []*httptest.ResponseRecorder{first, second} {
+		if rec.Code != http.StatusCreated || rec.Body.String() != `{"call":1}` || rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected response %d %s %v", rec.Code, rec.Body, rec.Header())
+		}
+	}
+
+	// Another client, or the same one with another email, isn't a duplicate
+	rec := httptest.NewRecorder()
+	dedup.Serve(rec, httptest.NewRequest(http.MethodPost, "/users/register", nil), DedupKey("alice@example.com", "10.0.0.2"), serve)
+	if calls.Load() != 2 {
+		t.Errorf("expected a different client to register for real, got %d calls", calls.Load())
+	}
+}
+
+func TestRedisDeduplicator_FallsBackToServing(t *testing.T) {
+	mr, client := newTestRedis(t)
+	dedup := NewRedisDeduplicator(client, "register", DefaultDedupWindow)
+	key := DedupKey("bob@example.com", "10.0.0.1")
+
+	var calls int
+	status := http.StatusInternalServerError
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}
+	send := func() int {
+		rec := httptest.NewRecorder()
+		dedup.Serve(rec, httptest.NewRequest(http.MethodPost, "/users/register", nil), key, serve)
+		return rec.Code
+	}
+
+	// A server error isn't handed on; the retry runs for real
+	send()
+	status = http.StatusCreated
+	if code := send(); code != http.StatusCreated || calls != 2 {
+		t.Fatalf("expected the retry after a 500 to run, got %d after %d calls", code, calls)
+	}
+
+	// Once the window is over the response isn't shared any more
+	mr.FastForward(DefaultDedupWindow)
+	send()
+	if calls != 3 {
+		t.Errorf("expected a request after the window to run, got %d calls", calls)
+	}
+
+	// Without Redis every request runs
+	mr.Close()
+	if code := send(); code != http.StatusCreated || calls != 4 {
+		t.Errorf("expected requests to run without Redis, got %d after %d calls", code, calls)
+	}
+}
@@ -0,0 +1,208 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"user-service/internal/infrastructure/redis"
+	"user-service/internal/interfaces/http/respond"
+)
+
+const (
+	// DefaultEndpointSwitchRefresh is how often instances should Refresh
+	// their copy of the disabled endpoints
+	DefaultEndpointSwitchRefresh = 2 * time.Second
+
+	// disabledEndpointsKey is the Redis hash of route pattern -> disable
+	disabledEndpointsKey = "endpoints:disabled"
+)
+
+// EndpointDisable describes why, by whom and until when a route is off
+type EndpointDisable struct {
+	// Message is shown to callers; empty gives a generic one
+	Message    string     `json:"message,omitempty"`
+	DisabledBy string     `json:"disabled_by"`
+	DisabledAt time.Time  `json:"disabled_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+func (d EndpointDisable) expired(now time.Time) bool {
+	return d.ExpiresAt != nil && !now.Before(*d.ExpiresAt)
+}
+
+// EndpointSwitches is the registry of routes turned off during an
+// incident, keyed by the router's pattern. It is shared through Redis;
+// requests are checked against an in-process copy that the owner keeps
+// current with Refresh, so the request path never waits on Redis. Changes
+// made on this instance apply here immediately. Without Redis it only
+// covers this instance.
+type EndpointSwitches struct {
+	client *redis.RedisClient
+	now    func() time.Time
+
+	mu       sync.Mutex
+	disabled map[string]EndpointDisable
+}
+
+func NewEndpointSwitches(client *redis.RedisClient) *EndpointSwitches {
+	return &EndpointSwitches{
+		client:   client,
+		now:      time.Now,
+		disabled: make(map[string]EndpointDisable),
+	}
+}
+
+// Disable turns pattern off until Enable or, when ttl is positive, until
+// ttl has passed
+func (s *EndpointSwitches) Disable(ctx context.Context, pattern, message, disabledBy string, ttl time.Duration) (EndpointDisable, error) {
+	d := EndpointDisable{
+		Message:    message,
+		DisabledBy: disabledBy,
+		DisabledAt: s.now().UTC(),
+	}
+	if ttl > 0 {
+		expiresAt := d.DisabledAt.Add(ttl)
+		d.ExpiresAt = &expiresAt
+	}
+
+	if s.client != nil {
+		if err := s.client.HSet(ctx, disabledEndpointsKey, pattern, d); err != nil {
+			return d, err
+		}
+	}
+	s.mu.Lock()
+	s.disabled[pattern] = d
+	s.mu.Unlock()
+	return d, nil
+}
+
+// Enable turns pattern back on
+func (s *EndpointSwitches) Enable(ctx context.Context, pattern string) error {
+	if s.client != nil {
+		if err := s.client.HDel(ctx, disabledEndpointsKey, pattern); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	delete(s.disabled, pattern)
+	s.mu.Unlock()
+	return nil
+}
+
+// List returns the routes currently off, read fresh from Redis
+func (s *EndpointSwitches) List(ctx context.Context) (map[string]EndpointDisable, error) {
+	if err := s.Refresh(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	live := make(map[string]EndpointDisable, len(s.disabled))
+	for pattern, d := range s.disabled {
+		if !d.expired(now) {
+			live[pattern] = d
+		}
+	}
+	return live, nil
+}
+
+// Disabled reports whether pattern is off, by the local copy
+func (s *EndpointSwitches) Disabled(pattern string) (EndpointDisable, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.disabled[pattern]
+	if !ok || d.expired(s.now()) {
+		return EndpointDisable{}, false
+	}
+	return d, true
+}
+
+// Refresh replaces the local copy with the Redis hash, dropping entries
+// that have expired from both. On error the last known state stays.
+func (s *EndpointSwitches) Refresh(ctx context.Context) error {
+	if s.client == nil {
+		return nil
+	}
+	fields, err := s.client.HGetAll(ctx, disabledEndpointsKey)
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	disabled := make(map[string]EndpointDisable, len(fields))
+	var expired []string
+	for pattern, raw := range fields {
+		var d EndpointDisable
+		if err := json.Unmarshal([]byte(raw), &d); err != nil {
+			log.Printf("Ignoring malformed endpoint switch %q: %v", pattern, err)
+			continue
+		}
+		if d.expired(now) {
+			expired = append(expired, pattern)
+			continue
+		}
+		disabled[pattern] = d
+	}
+	if len(expired) > 0 {
+		_ = s.client.HDel(ctx, disabledEndpointsKey, expired...)
+	}
+
+	s.mu.Lock()
+	s.disabled = disabled
+	s.mu.Unlock()
+	return nil
+}
+
+// RouteMatcher reports the handler and pattern a request is routed to, as
+// *http.ServeMux does
+type RouteMatcher interface {
+	Handler(r *http.Request) (h http.Handler, pattern string)
+}
+
+// RoutePattern returns the pattern mux routes path to, which is what
+// switches are keyed by. Wildcard patterns resolve to themselves. ok is
+// false for paths no route matches.
+func RoutePattern(mux RouteMatcher, path string) (pattern string, ok bool) {
+	r := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: path}}
+	_, pattern = mux.Handler(r)
+	return pattern, pattern != ""
+}
+
+// EndpointKillSwitch answers 503 endpoint_disabled for requests mux would
+// route to a disabled pattern. The pattern comes from the router itself, so
+// trailing slashes, doubled slashes or dot segments can't reach a disabled
+// route under another spelling.
+func EndpointKillSwitch(mux RouteMatcher, switches *EndpointSwitches) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, pattern := mux.Handler(r)
+			if pattern == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			d, disabled := switches.Disabled(pattern)
+			if !disabled {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			message := d.Message
+			if message == "" {
+				message = "This endpoint is temporarily disabled."
+			}
+			if d.ExpiresAt != nil {
+				retry := math.Ceil(d.ExpiresAt.Sub(switches.now()).Seconds())
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(retry, 1))))
+			}
+			respond.WriteError(w, r, http.StatusServiceUnavailable, "endpoint_disabled", message)
+		})
+	}
+}
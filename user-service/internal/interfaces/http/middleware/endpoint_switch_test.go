@@ -0,0 +1,121 @@
+// internal/interfaces/http/middleware/endpoint_switch_test.go
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/interfaces/http/respond"
+)
+
+func newSwitchedMux(switches *EndpointSwitches) http.Handler {
+	mux := http.NewServeMux()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.Handle("/users/register", ok)
+	mux.Handle("/users/login", ok)
+	mux.Handle("/admin/users/{id}/snapshot", ok)
+	return EndpointKillSwitch(mux, switches)(mux)
+}
+
+func switchedStatus(handler http.Handler, method, target string) (int, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, "/", strings.NewReader("{}"))
+	// Set the path directly: parsed as a target, "//users/register" would
+	// become a host and a path
+	req.URL.Path = target
+	handler.ServeHTTP(rec, req)
+	return rec.Code, rec
+}
+
+func TestEndpointKillSwitch_CantBeBypassed(t *testing.T) {
+	switches := NewEndpointSwitches(nil)
+	handler := newSwitchedMux(switches)
+	ctx := context.Background()
+
+	if _, err := switches.Disable(ctx, "/users/register", "Signups are paused.", "ops", 0); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if _, err := switches.Disable(ctx, "/admin/users/{id}/snapshot", "", "ops", 0); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+
+	for _, target := range []string{
+		"/users/register",
+		"//users/register",
+		"/users/./register",
+		"/users/login/../register",
+		"/users//register",
+		"/admin/users/42/snapshot",
+		"/admin/users/42//snapshot",
+	} {
+		code, rec := switchedStatus(handler, http.MethodPost, target)
+		if code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected 503, got %d", target, code)
+			continue
+		}
+		var body respond.ErrorBody
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Code != "endpoint_disabled" {
+			t.Errorf("%s: unexpected body %s", target, rec.Body)
+		}
+	}
+
+	// Trailing slashes aren't routed to the disabled pattern at all
+	if code, _ := switchedStatus(handler, http.MethodPost, "/users/register/"); code == http.StatusOK {
+		t.Error("a trailing slash must not reach the handler")
+	}
+	if code, _ := switchedStatus(handler, http.MethodPost, "/users/login"); code != http.StatusOK {
+		t.Errorf("other routes must keep working, got %d", code)
+	}
+
+	if err := switches.Enable(ctx, "/users/register"); err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	if code, _ := switchedStatus(handler, http.MethodPost, "/users/register"); code != http.StatusOK {
+		t.Errorf("expected the route back on, got %d", code)
+	}
+}
+
+func TestEndpointSwitches_SharedAndExpiring(t *testing.T) {
+	_, client := newTestRedis(t)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	ctx := context.Background()
+
+	// Two instances of the service sharing Redis
+	first, second := NewEndpointSwitches(client), NewEndpointSwitches(client)
+	first.now, second.now = clock, clock
+	handler := newSwitchedMux(second)
+
+	if _, err := first.Disable(ctx, "/users/register", "", "ops", 10*time.Minute); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if code, _ := switchedStatus(handler, http.MethodPost, "/users/register"); code != http.StatusOK {
+		t.Fatalf("expected the other instance unaffected until it refreshes, got %d", code)
+	}
+	if err := second.Refresh(ctx); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	code, rec := switchedStatus(handler, http.MethodPost, "/users/register")
+	if code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "600" {
+		t.Fatalf("expected 503 with Retry-After 600, got %d %q", code, rec.Header().Get("Retry-After"))
+	}
+
+	// A forgotten switch turns itself off, and is cleared from Redis
+	now = now.Add(10 * time.Minute)
+	if code, _ := switchedStatus(handler, http.MethodPost, "/users/register"); code != http.StatusOK {
+		t.Errorf("expected the switch expired, got %d", code)
+	}
+	if err := second.Refresh(ctx); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if fields, _ := client.HGetAll(ctx, disabledEndpointsKey); len(fields) != 0 {
+		t.Errorf("expected expired switches removed, got %v", fields)
+	}
+}
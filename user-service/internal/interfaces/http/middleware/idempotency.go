@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"user-service/internal/infrastructure/redis"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/logging"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// IdempotencyKeyHeader lets a client retry an unsafe request without
+	// repeating its effect
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response replayed from an earlier
+	// request with the same key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodyBytes bounds what is buffered to fingerprint a request
+	maxIdempotentBodyBytes = 1 << 20
+	// idempotencyPendingTTL caps how long a crashed request holds its key
+	idempotencyPendingTTL = time.Minute
+)
+
+// idempotentResponse is what Redis holds for a key. Status stays zero while
+// the first request is still running.
+type idempotentResponse struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// RedisIdempotencyMiddleware replays the stored response to requests that
+// repeat an earlier request's Idempotency-Key, so a client that timed out
+// can retry and get the original answer. A key reused with a different
+// request gets 422, and one whose request is still running gets 409.
+// Requests without the header pass straight through, as do all requests
+// while Redis is down. Server errors and rate limiting aren't stored, so
+// those can be retried for real.
+func RedisIdempotencyMiddleware(client *redis.RedisClient, scope string, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				respond.WriteError(w, r, http.StatusBadRequest, "invalid_idempotency_key", fmt.Sprintf("Idempotency-Key must be at most %d characters.", maxIdempotencyKeyLength))
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodyBytes+1))
+			var tooLarge *http.MaxBytesError
+			if err != nil && !errors.As(err, &tooLarge) {
+				respond.Error(w, r, "Invalid request", http.StatusBadRequest)
+				return
+			}
+			if len(body) > maxIdempotentBodyBytes || tooLarge != nil {
+				// Too big to fingerprint; let the handler reject it
+				r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+				next.ServeHTTP(w, r)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := requestFingerprint(r, body)
+
+			ctx := r.Context()
+			redisKey := fmt.Sprintf("idempotency:%s:%s", scope, key)
+			pending, _ := json.Marshal(idempotentResponse{Fingerprint: fingerprint})
+
+			reserved, err := client.SetNX(ctx, redisKey, string(pending), idempotencyPendingTTL)
+			if err != nil {
+				logging.Printf(ctx, "Redis idempotency error: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !reserved {
+				replayIdempotent(w, r, client, redisKey, fingerprint)
+				return
+			}
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			// Store even if the client has gone; its retry wants this answer
+			storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+			defer cancel()
+			if rec.status >= http.StatusInternalServerError || rec.status == http.StatusTooManyRequests {
+				if err := client.Delete(storeCtx, redisKey); err != nil {
+					logging.Printf(ctx, "Redis idempotency error: %v", err)
+				}
+				return
+			}
+			stored := idempotentResponse{
+				Fingerprint: fingerprint,
+				Status:      rec.status,
+				ContentType: rec.Header().Get("Content-Type"),
+				Body:        rec.body.Bytes(),
+			}
+			if err := client.Set(storeCtx, redisKey, stored, ttl); err != nil {
+				logging.Printf(ctx, "Redis idempotency error: %v", err)
+			}
+		})
+	}
+}
+
+// replayIdempotent answers a request whose key is already taken
+func replayIdempotent(w http.ResponseWriter, r *http.Request, client *redis.RedisClient, redisKey, fingerprint string) {
+	var stored idempotentResponse
+	err := client.Get(r.Context(), redisKey, &stored)
+	switch {
+	case errors.Is(err, goredis.Nil):
+		// The first request failed and released the key just now
+		respondKeyInUse(w, r)
+		return
+	case err != nil:
+		logging.Printf(r.Context(), "Redis idempotency error: %v", err)
+		respond.Error(w, r, "Could not check Idempotency-Key", http.StatusServiceUnavailable)
+		return
+	}
+
+	if stored.Fingerprint != fingerprint {
+		respond.WriteError(w, r, http.StatusUnprocessableEntity, "idempotency_key_reused", "This Idempotency-Key was already used for a different request.")
+		return
+	}
+	if stored.Status == 0 {
+		respondKeyInUse(w, r)
+		return
+	}
+
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	writeStoredResponse(w, stored)
+}
+
+// writeStoredResponse sends a response recorded earlier
+func writeStoredResponse(w http.ResponseWriter, stored idempotentResponse) {
+	if stored.ContentType != "" {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+	w.WriteHeader(stored.Status)
+	w.Write(stored.Body)
+}
+
+func respondKeyInUse(w http.ResponseWriter, r *http.Request) {
+	respond.WriteError(w, r, http.StatusConflict, "idempotency_key_in_use", "A request with this Idempotency-Key is still in progress. Please retry shortly.")
+}
+
+// requestFingerprint identifies what a request asks for, so a key can only
+// replay the request it was first used with
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.Path)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder copies what the handler writes so it can be stored
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,99 @@
+// internal/interfaces/http/middleware/idempotency_test.go
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedisIdempotencyMiddleware(t *testing.T) {
+	mr, client := newTestRedis(t)
+
+	var calls int
+	status := http.StatusCreated
+	handler := RedisIdempotencyMiddleware(client, "register", time.Hour)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"call":%d,"echo":%s}`, calls, body)
+		}),
+	)
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users/register", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send("k1", `{"a":1}`)
+	if first.Code != http.StatusCreated || first.Body.String() != `{"call":1,"echo":{"a":1}}` {
+		t.Fatalf("first request: %d %s", first.Code, first.Body.String())
+	}
+
+	t.Run("exact retry replays the first response", func(t *testing.T) {
+		retry := send("k1", `{"a":1}`)
+		if calls != 1 {
+			t.Fatalf("expected the handler to run once, ran %d times", calls)
+		}
+		if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+			t.Errorf("expected %d %s, got %d %s", first.Code, first.Body.String(), retry.Code, retry.Body.String())
+		}
+		if retry.Header().Get(IdempotentReplayedHeader) != "true" || retry.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected replay headers: %v", retry.Header())
+		}
+	})
+
+	t.Run("key reused for a different body", func(t *testing.T) {
+		rec := send("k1", `{"a":2}`)
+		if rec.Code != http.StatusUnprocessableEntity || calls != 1 {
+			t.Errorf("expected 422 without running the handler, got %d after %d calls", rec.Code, calls)
+		}
+	})
+
+	t.Run("key still in progress", func(t *testing.T) {
+		// What the first request leaves behind until it finishes
+		fingerprint := requestFingerprint(httptest.NewRequest(http.MethodPost, "/users/register", nil), []byte(`{}`))
+		mr.Set("idempotency:register:k2", `{"fingerprint":"`+fingerprint+`","status":0}`)
+		before := calls
+		if rec := send("k2", `{}`); rec.Code != http.StatusConflict || calls != before {
+			t.Errorf("expected 409 without running the handler, got %d", rec.Code)
+		}
+	})
+
+	t.Run("server errors are not stored", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		send("k3", `{}`)
+		status = http.StatusCreated
+		before := calls
+		if rec := send("k3", `{}`); rec.Code != http.StatusCreated || calls != before+1 {
+			t.Errorf("expected the retry to run again, got %d", rec.Code)
+		}
+	})
+
+	t.Run("no key runs every time", func(t *testing.T) {
+		before := calls
+		send("", `{}`)
+		send("", `{}`)
+		if calls != before+2 {
+			t.Errorf("expected 2 handler runs, got %d", calls-before)
+		}
+	})
+
+	t.Run("degrades open without Redis", func(t *testing.T) {
+		mr.Close()
+		before := calls
+		if rec := send("k1", `{"a":1}`); rec.Code != http.StatusCreated || calls != before+1 {
+			t.Errorf("expected the handler to run, got %d", rec.Code)
+		}
+	})
+}
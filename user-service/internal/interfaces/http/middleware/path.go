@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// PathNormalization is what NormalizePath does with a non-canonical path
+type PathNormalization string
+
+const (
+	// PathRewrite serves the request as if the canonical path was asked for
+	PathRewrite PathNormalization = "rewrite"
+	// PathRedirect answers 308 to the canonical path, which clients follow
+	// with the same method and body
+	PathRedirect PathNormalization = "redirect"
+	// PathAsIs leaves paths to the router
+	PathAsIs PathNormalization = "off"
+)
+
+// ParsePathNormalization accepts rewrite, redirect or off; empty means
+// rewrite
+func ParsePathNormalization(s string) (PathNormalization, error) {
+	switch mode := PathNormalization(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return PathRewrite, nil
+	case PathRewrite, PathRedirect, PathAsIs:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid path normalization %q, want rewrite, redirect or off", s)
+}
+
+// CanonicalPath drops trailing slashes and collapses repeated slashes and
+// dot segments. None of our routes end in a slash, so /users/login/,
+// //users/login and /users/./login all mean /users/login.
+func CanonicalPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	return path.Clean("/" + p)
+}
+
+// NormalizePath sends requests for a non-canonical path to its canonical
+// form before anything that keys on the path, like route limits, auth and
+// metrics, sees them. Preflight requests are always rewritten: browsers
+// don't follow redirects for them.
+func NormalizePath(mode PathNormalization) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if mode == PathAsIs {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			escaped := r.URL.EscapedPath()
+			canonical := CanonicalPath(escaped)
+			if canonical == escaped {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if mode == PathRedirect && r.Method != http.MethodOptions {
+				target := canonical
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, target, http.StatusPermanentRedirect)
+				return
+			}
+
+			unescaped, err := url.PathUnescape(canonical)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rewritten := r.Clone(r.Context())
+			rewritten.URL.Path = unescaped
+			rewritten.URL.RawPath = ""
+			if unescaped != canonical {
+				rewritten.URL.RawPath = canonical
+			}
+			rewritten.RequestURI = rewritten.URL.RequestURI()
+			next.ServeHTTP(w, rewritten)
+		})
+	}
+}
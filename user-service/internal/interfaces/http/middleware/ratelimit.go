@@ -1,13 +1,20 @@
 package middleware
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
-	"net"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/logging"
+	"user-service/internal/normalize"
 
 	"golang.org/x/time/rate"
 )
@@ -19,86 +26,285 @@ type RateLimiter struct {
 	limit    rate.Limit
 	burst    int
 	ttl      time.Duration
+
+	rateLimitOptions
 }
 
-// visitor holds the rate limiter and last seen time for each visitor
-type visitor struct {
-	limiter  *rate.Limiter
-	lastSeen time.Time
+// RateLimitMode is how a limiter treats requests over its limit
+type RateLimitMode string
+
+const (
+	// RateLimitEnforce rejects them with a 429
+	RateLimitEnforce RateLimitMode = "enforce"
+	// RateLimitWarn lets them through with an X-RateLimit-Warning header,
+	// reporting them as would-be rejections, so a new limit can be watched
+	// before it's enforced
+	RateLimitWarn RateLimitMode = "warn"
+	// RateLimitOff lets them through silently
+	RateLimitOff RateLimitMode = "off"
+)
+
+// ParseRateLimitMode accepts enforce, warn or off
+func ParseRateLimitMode(s string) (RateLimitMode, error) {
+	switch mode := RateLimitMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case RateLimitEnforce, RateLimitWarn, RateLimitOff:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid rate limit mode %q, want enforce, warn or off", s)
+}
+
+// RateLimitModes holds the mode of each limiter scope. Limiters read it on
+// every request, so Set takes effect without a restart. Scopes without a
+// mode are enforced.
+type RateLimitModes struct {
+	modes atomic.Pointer[map[string]RateLimitMode]
+}
+
+func NewRateLimitModes(modes map[string]RateLimitMode) *RateLimitModes {
+	m := &RateLimitModes{}
+	m.Set(modes)
+	return m
+}
+
+// Set replaces every scope's mode at once
+func (m *RateLimitModes) Set(modes map[string]RateLimitMode) {
+	copied := make(map[string]RateLimitMode, len(modes))
+	for scope, mode := range modes {
+		copied[scope] = mode
+	}
+	m.modes.Store(&copied)
+}
+
+// Mode returns scope's mode; a nil RateLimitModes enforces everything
+func (m *RateLimitModes) Mode(scope string) RateLimitMode {
+	if m == nil {
+		return RateLimitEnforce
+	}
+	if mode, ok := (*m.modes.Load())[scope]; ok {
+		return mode
+	}
+	return RateLimitEnforce
+}
+
+// RateLimitObserver is told about every request a limiter turns away, or
+// in warn mode would have, and every one a Redis limiter had to decide
+// without Redis
+type RateLimitObserver interface {
+	ObserveRateLimited(scope string, mode RateLimitMode)
+	ObserveRedisFailure(scope string, mode FailureMode)
+}
+
+// RateLimitOption configures a limiter, in memory or in Redis
+type RateLimitOption func(*rateLimitOptions)
+
+type rateLimitOptions struct {
+	observer RateLimitObserver
+	modes    *RateLimitModes
+	// label names the limiter to the observer and in modes
+	label       string
+	algorithm   RateLimitAlgorithm
+	failureMode FailureMode
+	// failover is set for Redis limiters
+	failover *redisFailover
 }
 
-// NewRateLimiter creates a new rate limiter
-func NewRateLimiter(requestsPerSecond float64, burst int, ttl time.Duration) *RateLimiter {
-	rl := &RateLimiter{
-		visitors: make(map[string]*visitor),
-		limit:    rate.Limit(requestsPerSecond),
-		burst:    burst,
-		ttl:      ttl,
+// RateLimitAlgorithm is how a Redis limiter counts requests in its window
+type RateLimitAlgorithm string
+
+const (
+	// FixedWindow counts requests in a window that starts with the first
+	// one. It's one INCR, but a client can spend a window's limit at its
+	// end and another at the start of the next: twice the limit at once.
+	FixedWindow RateLimitAlgorithm = "fixed_window"
+	// SlidingWindow weighs the previous window's count by how much of it
+	// still overlaps the last window's length, so no burst across the
+	// boundary gets past the limit
+	SlidingWindow RateLimitAlgorithm = "sliding_window"
+)
+
+// WithAlgorithm picks how a Redis limiter counts; FixedWindow if unset.
+// The in-memory limiter is a token bucket, which has no window to cross,
+// and ignores it.
+func WithAlgorithm(algorithm RateLimitAlgorithm) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.algorithm = algorithm
+	}
+}
+
+// WithRejectionObserver reports each rejected request under scope. A nil
+// observer is ignored.
+func WithRejectionObserver(observer RateLimitObserver, scope string) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.observer = observer
+		o.label = scope
+	}
+}
+
+// WithModes looks the limiter's mode up in modes under scope
+func WithModes(modes *RateLimitModes, scope string) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.modes = modes
+		o.label = scope
+	}
+}
+
+func newRateLimitOptions(opts []RateLimitOption) rateLimitOptions {
+	var o rateLimitOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// RateLimitResult is a limiter's answer for one request, with what the
+// rate limit headers tell the client
+type RateLimitResult struct {
+	Allowed bool
+	// Limit is the most requests allowed at once, and Remaining how many
+	// of them are left after this one
+	Limit     int
+	Remaining int
+	// Reset is how long until the limit is back in full
+	Reset time.Duration
+	// RetryAfter is how long until a request that wasn't allowed would be
+	RetryAfter time.Duration
+}
+
+// setRateLimitHeaders tells the client where it stands. X-RateLimit-Reset
+// is a Unix time, rounded up so a client waiting for it isn't a moment
+// early.
+func setRateLimitHeaders(w http.ResponseWriter, res RateLimitResult) {
+	h := w.Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(res.Reset+time.Second-1).Unix(), 10))
+}
+
+// overLimit handles a request over the limit according to the limiter's
+// mode and reports whether it was rejected. Otherwise the caller serves it.
+// retryAfter is when the client may try again.
+func (o *rateLimitOptions) overLimit(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) (rejected bool) {
+	mode := o.modes.Mode(o.label)
+	if mode == RateLimitOff {
+		return false
+	}
+	if o.observer != nil {
+		o.observer.ObserveRateLimited(o.label, mode)
+	}
+	if mode == RateLimitWarn {
+		logging.Printf(r.Context(), "Rate limit %q would have rejected %s %s", o.label, r.Method, r.URL.Path)
+		w.Header().Set("X-RateLimit-Warning", "limit exceeded, not enforced")
+		return false
 	}
+	rateLimitExceededResponse(w, r, retryAfter)
+	return true
+}
 
-	// Cleanup goroutine để xóa các visitors cũ
-	go rl.cleanupVisitors()
+// visitor holds the rate limiter and last seen time for each visitor.
+// lastSeen is unix nanoseconds, updated atomically so returning visitors
+// only need the read lock.
+type visitor struct {
+	limiter  *rate.Limiter
+	lastSeen atomic.Int64
+}
 
-	return rl
+// NewRateLimiter creates a new rate limiter. Visitors idle for longer than
+// ttl are forgotten by EvictIdle, which the owner runs periodically.
+func NewRateLimiter(requestsPerSecond float64, burst int, ttl time.Duration, opts ...RateLimitOption) *RateLimiter {
+	return &RateLimiter{
+		visitors:         make(map[string]*visitor),
+		limit:            rate.Limit(requestsPerSecond),
+		burst:            burst,
+		ttl:              ttl,
+		rateLimitOptions: newRateLimitOptions(opts),
+	}
 }
 
 // getVisitor returns the rate limiter for the given IP
 func (rl *RateLimiter) getVisitor(ip string) *rate.Limiter {
+	now := time.Now().UnixNano()
+
+	// Fast path: returning visitors share the read lock
+	rl.mu.RLock()
+	v, exists := rl.visitors[ip]
+	rl.mu.RUnlock()
+	if exists {
+		v.touch(now)
+		return v.limiter
+	}
+
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	v, exists := rl.visitors[ip]
+	// Another request may have added the visitor since the read lock
+	v, exists = rl.visitors[ip]
 	if !exists {
-		limiter := rate.NewLimiter(rl.limit, rl.burst)
-		rl.visitors[ip] = &visitor{limiter, time.Now()}
-		return limiter
+		v = &visitor{limiter: rate.NewLimiter(rl.limit, rl.burst)}
+		rl.visitors[ip] = v
 	}
-
-	// Update last seen time
-	v.lastSeen = time.Now()
+	v.touch(now)
 	return v.limiter
 }
 
-// cleanupVisitors removes old entries from the visitors map
-func (rl *RateLimiter) cleanupVisitors() {
-	ticker := time.NewTicker(time.Minute)
-	defer ticker.Stop()
+// lastSeenResolution bounds how often lastSeen is rewritten; eviction
+// works in minutes, so finer updates only add cache-line traffic
+const lastSeenResolution = int64(time.Second)
+
+// take spends one of key's tokens if it has one
+func (rl *RateLimiter) take(key string) RateLimitResult {
+	l := rl.getVisitor(key)
+	now := time.Now()
+	res := RateLimitResult{Allowed: l.AllowN(now, 1), Limit: rl.burst}
+
+	tokens := l.TokensAt(now)
+	res.Remaining = max(int(tokens), 0)
+	if rl.limit > 0 && rl.limit != rate.Inf {
+		perToken := float64(time.Second) / float64(rl.limit)
+		res.Reset = time.Duration((float64(rl.burst) - tokens) * perToken)
+		if !res.Allowed {
+			res.RetryAfter = time.Duration((1 - tokens) * perToken)
+		}
+	}
+	return res
+}
 
-	for {
-		<-ticker.C
+func (v *visitor) touch(now int64) {
+	if now-v.lastSeen.Load() >= lastSeenResolution {
+		v.lastSeen.Store(now)
+	}
+}
 
-		// Collect expired IPs first
-		rl.mu.RLock()
-		var expiredIPs []string
-		for ip, v := range rl.visitors {
-			if time.Since(v.lastSeen) > rl.ttl {
-				expiredIPs = append(expiredIPs, ip)
-			}
+// EvictIdle removes visitors not seen for longer than the limiter's ttl
+// and returns how many it removed
+func (rl *RateLimiter) EvictIdle() int {
+	// Collect expired IPs first
+	rl.mu.RLock()
+	var expiredIPs []string
+	for ip, v := range rl.visitors {
+		if time.Since(time.Unix(0, v.lastSeen.Load())) > rl.ttl {
+			expiredIPs = append(expiredIPs, ip)
 		}
-		rl.mu.RUnlock()
+	}
+	rl.mu.RUnlock()
 
-		// Delete with write lock
-		if len(expiredIPs) > 0 {
-			rl.mu.Lock()
-			for _, ip := range expiredIPs {
-				delete(rl.visitors, ip)
-			}
-			rl.mu.Unlock()
+	// Delete with write lock
+	if len(expiredIPs) > 0 {
+		rl.mu.Lock()
+		for _, ip := range expiredIPs {
+			delete(rl.visitors, ip)
 		}
+		rl.mu.Unlock()
 	}
+	return len(expiredIPs)
 }
 
 // RateLimitMiddleware creates a new rate limiting middleware
 func RateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := getClientIP(r)
-
-			// Get the rate limiter for this IP
-			l := limiter.getVisitor(ip)
-
-			if !l.Allow() {
-				rateLimitExceededResponse(w)
+			res := limiter.take(getClientIP(r))
+			setRateLimitHeaders(w, res)
+			if !res.Allowed && limiter.overLimit(w, r, res.RetryAfter) {
 				return
 			}
 
@@ -108,84 +314,103 @@ func RateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
 }
 
 // Per-route rate limiting với config khác nhau
-func CustomRateLimitMiddleware(requestsPerSecond float64, burst int) func(http.Handler) http.Handler {
-	limiter := NewRateLimiter(requestsPerSecond, burst, 30*time.Minute)
+func CustomRateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
+	return RateLimitMiddleware(limiter)
+}
+
+// KeyFunc names who a request counts against. An empty key falls back to
+// the client IP.
+type KeyFunc func(r *http.Request) string
+
+// requestKey applies key, falling back to the client IP
+func requestKey(r *http.Request, key KeyFunc) string {
+	if k := key(r); k != "" {
+		return k
+	}
+	return getClientIP(r)
+}
 
+// KeyedRateLimitMiddleware limits requests per key instead of per IP, so
+// an attacker can't spread guesses against one account across addresses
+func KeyedRateLimitMiddleware(limiter *RateLimiter, key KeyFunc) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ip := getClientIP(r)
-			l := limiter.getVisitor(ip)
-
-			if !l.Allow() {
-				rateLimitExceededResponse(w)
+			res := limiter.take(requestKey(r, key))
+			setRateLimitHeaders(w, res)
+			if !res.Allowed && limiter.overLimit(w, r, res.RetryAfter) {
 				return
 			}
-
-			// Add rate limit headers
-			w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%.0f", requestsPerSecond))
-			w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", int(l.Tokens())))
-			w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(time.Second).Unix()))
-
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
-// getClientIP extracts the real client IP from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		ips := strings.Split(xff, ",")
-		// Take the first IP in the chain
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
+// maxKeyBodyBytes caps how much of a body EmailKey reads
+const maxKeyBodyBytes = 64 << 10
 
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return xri
+// EmailKey keys a request by the normalized "email" of its JSON body,
+// leaving the body for the handler to read. Bodies without one fall back to
+// the client IP. What it reads is put back in front of the rest, so the
+// handler sees a longer body whole and applies its own limit.
+func EmailKey(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	rest := r.Body
+	body, err := io.ReadAll(io.LimitReader(rest, maxKeyBodyBytes))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), rest), rest}
+	if err != nil {
+		return ""
 	}
 
-	// Fall back to RemoteAddr
-	ip, _, _ := net.SplitHostPort(r.RemoteAddr)
-	return ip
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return ""
+	}
+	if email := normalize.Email(payload.Email); email != "" {
+		return "email:" + email
+	}
+	return ""
 }
 
-// rateLimitExceededResponse sends a 429 Too Many Requests response
-func rateLimitExceededResponse(w http.ResponseWriter) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusTooManyRequests)
-
-	response := map[string]interface{}{
-		"error":   "rate_limit_exceeded",
-		"message": "Too many requests. Please try again later.",
+// getClientIP is the client's address as ClientIP worked it out, or
+// without it the connection's, forwarding headers never being trusted
+func getClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
 	}
+	return remoteHost(r)
+}
 
-	json.NewEncoder(w).Encode(response)
+// rateLimitExceededResponse sends a 429 Too Many Requests response saying
+// when to retry, in whole seconds and at least one
+func rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	respond.WriteErrorDetails(w, r, http.StatusTooManyRequests, respond.CodeRateLimitExceeded, "Too many requests. Please try again later.",
+		map[string]interface{}{"retry_after_seconds": seconds})
 }
 
 // UserRateLimitMiddleware limits requests per authenticated user
-func UserRateLimitMiddleware(requestsPerSecond float64, burst int) func(http.Handler) http.Handler {
-	limiter := NewRateLimiter(requestsPerSecond, burst, 30*time.Minute)
-
+func UserRateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Get user ID from context (set by AuthMiddleware)
 			userID := GetUserID(r)
 			if userID == 0 {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				respond.Error(w, r, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 
 			// Use user ID as key instead of IP
-			key := fmt.Sprintf("user:%d", userID)
-			l := limiter.getVisitor(key)
-
-			if !l.Allow() {
-				rateLimitExceededResponse(w)
+			res := limiter.take(fmt.Sprintf("user:%d", userID))
+			setRateLimitHeaders(w, res)
+			if !res.Allowed && limiter.overLimit(w, r, res.RetryAfter) {
 				return
 			}
 
@@ -0,0 +1,115 @@
+// internal/interfaces/http/middleware/ratelimit_bench_test.go
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// benchVisitors is the number of distinct clients in the steady-state
+// benchmarks; large enough that lookups, not one hot key, dominate
+const benchVisitors = 1024
+
+func benchIPs(n int) []string {
+	ips := make([]string, n)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+	}
+	return ips
+}
+
+// BenchmarkRateLimiter_GetVisitor measures the per-request visitor lookup.
+// "existing" is the steady state of returning clients; "new" inserts a
+// fresh visitor every call.
+func BenchmarkRateLimiter_GetVisitor(b *testing.B) {
+	b.Run("existing", func(b *testing.B) {
+		rl := NewRateLimiter(1e9, 1e9, time.Hour)
+		ips := benchIPs(benchVisitors)
+		for _, ip := range ips {
+			rl.getVisitor(ip)
+		}
+
+		var next atomic.Uint64
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			i := next.Add(1) * 7919
+			for pb.Next() {
+				rl.getVisitor(ips[i%benchVisitors])
+				i++
+			}
+		})
+	})
+
+	b.Run("new", func(b *testing.B) {
+		rl := NewRateLimiter(1e9, 1e9, time.Hour)
+		ips := benchIPs(b.N)
+
+		var next atomic.Int64
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				rl.getVisitor(ips[next.Add(1)-1])
+			}
+		})
+	})
+}
+
+// BenchmarkRateLimitMiddleware measures the full in-memory middleware,
+// including client IP extraction and the token bucket
+func BenchmarkRateLimitMiddleware(b *testing.B) {
+	rl := NewRateLimiter(1e9, 1e9, time.Hour)
+	handler := RateLimitMiddleware(rl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	addrs := benchIPs(benchVisitors)
+	for i := range addrs {
+		addrs[i] += ":4000"
+	}
+
+	var next atomic.Uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := next.Add(1) * 7919
+		req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+		rr := httptest.NewRecorder()
+		for pb.Next() {
+			req.RemoteAddr = addrs[i%benchVisitors]
+			handler.ServeHTTP(rr, req)
+			i++
+		}
+	})
+}
+
+// BenchmarkRedisRateLimiter_Allow measures the Redis limiter's round trips
+// against miniredis. Absolute numbers understate a networked Redis; use it
+// to compare algorithms, not to size production.
+func BenchmarkRedisRateLimiter_Allow(b *testing.B) {
+	for _, algorithm := range []RateLimitAlgorithm{FixedWindow, SlidingWindow} {
+		b.Run(string(algorithm), func(b *testing.B) {
+			_, client := newTestRedis(b)
+			rl := newRedisLimiter(client, "bench", 1<<30, time.Minute, []RateLimitOption{WithAlgorithm(algorithm)})
+			ips := benchIPs(benchVisitors)
+			ctx := context.Background()
+
+			var next atomic.Uint64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := next.Add(1) * 7919
+				for pb.Next() {
+					if _, err := rl.Allow(ctx, ips[i%benchVisitors]); err != nil {
+						b.Error(err)
+						return
+					}
+					i++
+				}
+			})
+		})
+	}
+}
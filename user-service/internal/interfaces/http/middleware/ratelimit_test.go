@@ -2,10 +2,17 @@
 package middleware
 
 import (
+	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"user-service/internal/interfaces/http/respond"
 )
 
 func TestRateLimiter(t *testing.T) {
@@ -36,6 +43,284 @@ func TestRateLimiter(t *testing.T) {
 			if rr.Code != http.StatusTooManyRequests {
 				t.Errorf("Request %d: expected 429, got %d", i+1, rr.Code)
 			}
+			var body respond.ErrorBody
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Error.Code != respond.CodeRateLimitExceeded {
+				t.Errorf("Request %d: expected rate_limit_exceeded, got %s", i+1, rr.Body)
+			}
 		}
 	}
 }
+
+func TestRateLimiter_EvictIdle(t *testing.T) {
+	rl := NewRateLimiter(1, 1, time.Minute)
+
+	handler := CustomRateLimitMiddleware(rl)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	request := func() int {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if first, second := request(), request(); first != http.StatusOK || second != http.StatusTooManyRequests {
+		t.Fatalf("expected 200 then 429, got %d and %d", first, second)
+	}
+	// A visitor seen within the ttl keeps its limiter, and its debt
+	if evicted := rl.EvictIdle(); evicted != 0 {
+		t.Fatalf("evicted %d active visitors", evicted)
+	}
+	if code := request(); code != http.StatusTooManyRequests {
+		t.Errorf("expected eviction to leave active visitors limited, got %d", code)
+	}
+
+	for _, v := range rl.visitors {
+		v.lastSeen.Store(time.Now().Add(-2 * time.Minute).UnixNano())
+	}
+	if evicted := rl.EvictIdle(); evicted != 1 {
+		t.Fatalf("expected the idle visitor to be evicted, got %d", evicted)
+	}
+	if code := request(); code != http.StatusOK {
+		t.Errorf("expected an evicted visitor to start over, got %d", code)
+	}
+}
+
+func TestKeyedRateLimitMiddleware_EmailKey(t *testing.T) {
+	rl := NewRateLimiter(1, 1, time.Minute)
+
+	handler := KeyedRateLimitMiddleware(rl, EmailKey)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The handler still gets the whole body
+			body, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(body), "@example.com") {
+				t.Errorf("body was not restored: %q", body)
+			}
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	request := func(email, ip string) int {
+		req := httptest.NewRequest("POST", "/users/recover", strings.NewReader(`{"email":"`+email+`","code":"x"}`))
+		req.RemoteAddr = ip + ":12345"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := request("alice@example.com", "10.0.0.1"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	// Another address doesn't get a fresh budget for the same account
+	if code := request(" Alice@Example.com ", "10.0.0.2"); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 for the same email, got %d", code)
+	}
+	if code := request("bob@example.com", "10.0.0.1"); code != http.StatusOK {
+		t.Errorf("expected another email to have its own budget, got %d", code)
+	}
+}
+
+func TestEmailKey_KeepsABodyPastTheLimitWhole(t *testing.T) {
+	want := `{"email":"alice@example.com","note":"` + strings.Repeat("x", maxKeyBodyBytes) + `"}`
+	req := httptest.NewRequest("POST", "/users/recover", strings.NewReader(want))
+
+	EmailKey(req)
+	got, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("expected the %d byte body whole, got %d bytes", len(want), len(got))
+	}
+}
+
+type recordingRateLimitObserver struct {
+	observed []RateLimitMode
+	failures []FailureMode
+}
+
+func (o *recordingRateLimitObserver) ObserveRateLimited(scope string, mode RateLimitMode) {
+	o.observed = append(o.observed, mode)
+}
+
+func (o *recordingRateLimitObserver) ObserveRedisFailure(scope string, mode FailureMode) {
+	o.failures = append(o.failures, mode)
+}
+
+func TestRateLimitModes_SameTraffic(t *testing.T) {
+	tests := []struct {
+		mode        RateLimitMode
+		wantCodes   []int
+		wantWarning []bool
+		wantReports int
+	}{
+		{
+			mode:        RateLimitEnforce,
+			wantCodes:   []int{200, 200, 429, 429},
+			wantWarning: []bool{false, false, false, false},
+			wantReports: 2,
+		},
+		{
+			mode:        RateLimitWarn,
+			wantCodes:   []int{200, 200, 200, 200},
+			wantWarning: []bool{false, false, true, true},
+			wantReports: 2,
+		},
+		{
+			mode:        RateLimitOff,
+			wantCodes:   []int{200, 200, 200, 200},
+			wantWarning: []bool{false, false, false, false},
+			wantReports: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			observer := &recordingRateLimitObserver{}
+			modes := NewRateLimitModes(map[string]RateLimitMode{"login": tt.mode})
+			rl := NewRateLimiter(0.001, 2, time.Minute,
+				WithRejectionObserver(observer, "login"),
+				WithModes(modes, "login"),
+			)
+			handler := CustomRateLimitMiddleware(rl)(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}),
+			)
+
+			for i := range tt.wantCodes {
+				req := httptest.NewRequest(http.MethodPost, "/users/login", nil)
+				req.RemoteAddr = "127.0.0.1:12345"
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+
+				if rr.Code != tt.wantCodes[i] {
+					t.Errorf("request %d: expected %d, got %d", i+1, tt.wantCodes[i], rr.Code)
+				}
+				if warned := rr.Header().Get("X-RateLimit-Warning") != ""; warned != tt.wantWarning[i] {
+					t.Errorf("request %d: warning header present = %v", i+1, warned)
+				}
+			}
+			if len(observer.observed) != tt.wantReports {
+				t.Fatalf("expected %d reports, got %v", tt.wantReports, observer.observed)
+			}
+			for _, mode := range observer.observed {
+				if mode != tt.mode {
+					t.Errorf("reported under %s, want %s", mode, tt.mode)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitModes_SetWhileServing(t *testing.T) {
+	modes := NewRateLimitModes(map[string]RateLimitMode{"global": RateLimitWarn})
+	rl := NewRateLimiter(0.001, 1, time.Minute, WithModes(modes, "global"))
+	handler := RateLimitMiddleware(rl)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	request := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	request()
+	if code := request(); code != http.StatusOK {
+		t.Fatalf("expected warn mode to let the request through, got %d", code)
+	}
+	// Scopes dropped from the set go back to enforcing
+	modes.Set(nil)
+	if code := request(); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the limit enforced after the switch, got %d", code)
+	}
+
+	if _, err := ParseRateLimitMode("Warn "); err != nil {
+		t.Errorf("expected a loose spelling to parse, got %v", err)
+	}
+	if _, err := ParseRateLimitMode("soft"); err == nil {
+		t.Error("expected an unknown mode to be refused")
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	_, client := newTestRedis(t)
+	asUser := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDKey, uint(7))))
+		})
+	}
+
+	tests := []struct {
+		name       string
+		limit      func(http.Handler) http.Handler
+		user       bool
+		maxRetry   int
+		exactRetry bool
+	}{
+		{name: "in-memory", limit: RateLimitMiddleware(NewRateLimiter(0.5, 2, time.Minute)), maxRetry: 2, exactRetry: true},
+		{name: "in-memory per user", limit: UserRateLimitMiddleware(NewRateLimiter(0.5, 2, time.Minute)), user: true, maxRetry: 2, exactRetry: true},
+		{name: "redis", limit: CustomRedisRateLimitMiddleware(client, "ip", 2, time.Minute), maxRetry: 60, exactRetry: true},
+		{name: "redis sliding window", limit: CustomRedisRateLimitMiddleware(client, "sliding", 2, time.Minute, WithAlgorithm(SlidingWindow)), maxRetry: 60},
+		{name: "redis per user", limit: RedisUserRateLimitMiddleware(client, http.NewServeMux(), 2, time.Minute), user: true, maxRetry: 60, exactRetry: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			if tt.user {
+				handler = asUser(handler)
+			}
+
+			for i, want := range []struct {
+				code      int
+				remaining string
+			}{{http.StatusOK, "1"}, {http.StatusOK, "0"}, {http.StatusTooManyRequests, "0"}} {
+				req := httptest.NewRequest(http.MethodPost, "/users/login", nil)
+				req.RemoteAddr = "203.0.113.7:4000"
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+
+				if rr.Code != want.code {
+					t.Fatalf("request %d: expected %d, got %d", i+1, want.code, rr.Code)
+				}
+				h := rr.Header()
+				if h.Get("X-RateLimit-Limit") != "2" || h.Get("X-RateLimit-Remaining") != want.remaining {
+					t.Errorf("request %d: expected limit 2 with %s remaining, got %q and %q",
+						i+1, want.remaining, h.Get("X-RateLimit-Limit"), h.Get("X-RateLimit-Remaining"))
+				}
+				reset, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+				if now := time.Now().Unix(); err != nil || reset < now || reset > now+61 {
+					t.Errorf("request %d: expected a reset within the window, got %q", i+1, h.Get("X-RateLimit-Reset"))
+				}
+				if want.code == http.StatusOK {
+					if h.Get("Retry-After") != "" {
+						t.Errorf("request %d: expected no Retry-After, got %q", i+1, h.Get("Retry-After"))
+					}
+					continue
+				}
+
+				retry, err := strconv.Atoi(h.Get("Retry-After"))
+				if err != nil || retry < 1 || retry > tt.maxRetry || tt.exactRetry && retry != tt.maxRetry {
+					t.Errorf("expected Retry-After of up to %ds, got %q", tt.maxRetry, h.Get("Retry-After"))
+				}
+				var body respond.ErrorBody
+				if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if got, _ := body.Error.Details["retry_after_seconds"].(float64); int(got) != retry {
+					t.Errorf("expected retry_after_seconds %d in the body, got %v", retry, body.Error.Details)
+				}
+			}
+		})
+	}
+}
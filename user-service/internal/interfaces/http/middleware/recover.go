@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"runtime/debug"
+
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/logging"
+)
+
+// PanicObserver is told about each panic Recover catches
+type PanicObserver interface {
+	ObservePanic()
+}
+
+// Recover turns a panicking handler into a 500 with the usual error body,
+// logging the stack with the request ID. If the handler had already
+// started its response there is nothing sound left to send, so the
+// connection is aborted instead, as net/http would. It goes outermost so
+// nothing escapes it; the request ID is read back off the response, where
+// RequestID put it. observer may be nil.
+func Recover(observer PanicObserver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				// Handlers abort on purpose with this; it isn't a bug
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				ctx := logging.WithRequestID(r.Context(), w.Header().Get(respond.RequestIDHeader))
+				logging.Printf(ctx, "panic serving %s %s: %v\n%s", r.Method, r.URL.Path, v, debug.Stack())
+				if observer != nil {
+					observer.ObservePanic()
+				}
+				if sw.wroteHeader {
+					panic(http.ErrAbortHandler)
+				}
+				// These described the response the handler didn't finish
+				for _, header := range []string{"Content-Length", "Content-Encoding", "Content-Disposition", "ETag", "Last-Modified"} {
+					w.Header().Del(header)
+				}
+				respond.Error(w, r, "Internal server error", http.StatusInternalServerError)
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// internal/interfaces/http/middleware/recover_test.go
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"user-service/internal/interfaces/http/respond"
+)
+
+type panicCounter struct{ count int }
+
+func (c *panicCounter) ObservePanic() { c.count++ }
+
+func TestRecover(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	counter := &panicCounter{}
+	handler := Recover(counter)(RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "512")
+		panic("nil map somewhere")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	req.Header.Set(respond.RequestIDHeader, "panicky-request")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected a JSON body, got %q", ct)
+	}
+	if cl := rec.Header().Get("Content-Length"); cl != "" {
+		t.Errorf("expected the handler's Content-Length dropped, got %q", cl)
+	}
+	if id := rec.Header().Get(respond.RequestIDHeader); id != "panicky-request" {
+		t.Errorf("expected the request ID kept, got %q", id)
+	}
+	var body respond.ErrorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("expected the error envelope, got %q: %v", rec.Body, err)
+	}
+	if body.Error.Code != respond.CodeInternal {
+		t.Errorf("expected code %q, got %+v", respond.CodeInternal, body.Error)
+	}
+	if strings.Contains(rec.Body.String(), "nil map") {
+		t.Error("expected the panic kept out of the response")
+	}
+
+	logged := logs.String()
+	for _, want := range []string{"panic serving GET /users/me: nil map somewhere", "recover_test.go", "request_id=panicky-request"} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("expected %q in the log, got %q", want, logged)
+		}
+	}
+	if counter.count != 1 {
+		t.Errorf("expected one panic counted, got %d", counter.count)
+	}
+}
+
+func TestRecover_AbortsStartedResponses(t *testing.T) {
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(new(bytes.Buffer))
+
+	handler := Recover(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"items": [`))
+		panic("halfway through")
+	}))
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("expected the connection aborted, got %v", v)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+}
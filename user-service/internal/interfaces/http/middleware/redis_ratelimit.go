@@ -2,121 +2,280 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"user-service/internal/infrastructure/redis"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/logging"
 )
 
+// fixedWindow counts a request and starts the window with the first one.
+// The expiry is only set when there is none, so traffic can't keep pushing
+// the window's end out; that also covers a counter left without one.
+//
+// KEYS[1] the counter
+// ARGV[1] window in milliseconds
+// Returns the count and the milliseconds left in the window.
+var fixedWindow = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < 0 then
+	ttl = tonumber(ARGV[1])
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return {count, ttl}
+`)
+
 type RedisRateLimiter struct {
 	client *redis.RedisClient
 	limit  int
 	window time.Duration
+	// scope namespaces the counters so limiters don't share a budget
+	scope string
+
+	rateLimitOptions
 }
 
-func NewRedisRateLimiter(client *redis.RedisClient, limit int, window time.Duration) *RedisRateLimiter {
+func NewRedisRateLimiter(client *redis.RedisClient, limit int, window time.Duration, opts ...RateLimitOption) *RedisRateLimiter {
 	return &RedisRateLimiter{
-		client: client,
-		limit:  limit,
-		window: window,
+		client:           client,
+		limit:            limit,
+		window:           window,
+		rateLimitOptions: newRedisRateLimitOptions(opts, limit, window),
 	}
 }
 
-func (rl *RedisRateLimiter) Allow(ctx context.Context, identifier string) (bool, error) {
+// Allow counts a request against identifier. The window ends, and the
+// count starts over, Reset from now.
+func (rl *RedisRateLimiter) Allow(ctx context.Context, identifier string) (RateLimitResult, error) {
 	key := fmt.Sprintf("rate_limit:%s", identifier)
+	if rl.scope != "" {
+		key = fmt.Sprintf("rate_limit:%s:%s", rl.scope, identifier)
+	}
 
-	// Use pipeline for atomic operations
-	pipe := rl.client.Pipeline()
+	res, err := rl.client.Eval(ctx, fixedWindow, []string{key}, rl.window.Milliseconds())
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("redis fixed window error: %w", err)
+	}
+	values, ok := scriptInts(res, 2)
+	if !ok {
+		return RateLimitResult{}, fmt.Errorf("unexpected fixed window result %v", res)
+	}
+	count, ttl := values[0], time.Duration(values[1])*time.Millisecond
 
-	// Increment counter
-	incr := pipe.Incr(ctx, key)
-	// Set expiration only if key doesn't exist
-	pipe.Expire(ctx, key, rl.window)
+	result := RateLimitResult{
+		Allowed:   count <= int64(rl.limit),
+		Limit:     rl.limit,
+		Remaining: max(rl.limit-int(count), 0),
+		Reset:     ttl,
+	}
+	if !result.Allowed {
+		result.RetryAfter = ttl
+	}
+	return result, nil
+}
 
-	_, err := pipe.Exec(ctx)
-	if err != nil {
-		return false, fmt.Errorf("redis pipeline error: %w", err)
+// scriptInts reads a script's reply of n integers
+func scriptInts(res interface{}, n int) ([]int64, bool) {
+	values, ok := res.([]interface{})
+	if !ok || len(values) != n {
+		return nil, false
+	}
+	ints := make([]int64, n)
+	for i, v := range values {
+		if ints[i], ok = v.(int64); !ok {
+			return nil, false
+		}
 	}
+	return ints, true
+}
+
+// FailureMode is what a Redis limiter does when Redis fails it
+type FailureMode string
 
-	count, err := incr.Result()
+const (
+	// FailOpen lets requests through unlimited
+	FailOpen FailureMode = "open"
+	// FailClosed turns them away with a 503, for endpoints where an
+	// unlimited attacker does more harm than an outage
+	FailClosed FailureMode = "closed"
+	// FailoverLocal limits them in memory instead, to the same rate per
+	// instance rather than across them
+	FailoverLocal FailureMode = "local"
+)
+
+// ParseFailureMode accepts open, closed or local
+func ParseFailureMode(s string) (FailureMode, error) {
+	switch mode := FailureMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case FailOpen, FailClosed, FailoverLocal:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid rate limit failure mode %q, want open, closed or local", s)
+}
+
+// WithFailureMode picks what a Redis limiter does when Redis fails it;
+// FailOpen if unset. The in-memory limiter has nothing to fail and ignores
+// it.
+func WithFailureMode(mode FailureMode) RateLimitOption {
+	return func(o *rateLimitOptions) {
+		o.failureMode = mode
+	}
+}
+
+// redisFailover is a Redis limiter's state for when Redis fails it
+type redisFailover struct {
+	// local stands in for Redis under FailoverLocal
+	local     *RateLimiter
+	lastEvict atomic.Int64
+	// failing is set from the first failure to the next success, so each
+	// outage is logged once however many requests it fails
+	failing atomic.Bool
+}
+
+// newRedisRateLimitOptions applies opts for a Redis limiter of limit
+// requests per window
+func newRedisRateLimitOptions(opts []RateLimitOption, limit int, window time.Duration) rateLimitOptions {
+	o := newRateLimitOptions(opts)
+	o.failover = &redisFailover{}
+	if o.failureMode == FailoverLocal {
+		// A bucket refilling at the same rate. Visitors idle for a window
+		// have a full bucket again, so forgetting them changes nothing.
+		o.failover.local = NewRateLimiter(float64(limit)/window.Seconds(), limit, window)
+	}
+	return o
+}
+
+// redisFailed answers a request Redis couldn't count according to the
+// failure mode, reporting whether it was rejected
+func (o *rateLimitOptions) redisFailed(w http.ResponseWriter, r *http.Request, identifier string, err error) (rejected bool) {
+	mode := o.failureMode
+	if mode == "" {
+		mode = FailOpen
+	}
+	if o.failover.failing.CompareAndSwap(false, true) {
+		logging.Printf(r.Context(), "Redis rate limit %q failing, now %s: %v", o.label, mode, err)
+	}
+	if o.observer != nil {
+		o.observer.ObserveRedisFailure(o.label, mode)
+	}
+
+	switch mode {
+	case FailClosed:
+		// Only an enforced limit closes; warn and off never turn anyone away
+		if o.modes.Mode(o.label) != RateLimitEnforce {
+			return false
+		}
+		w.Header().Set("Retry-After", "1")
+		respond.WriteError(w, r, http.StatusServiceUnavailable, "rate_limit_unavailable", "Service temporarily unavailable. Please try again later.")
+		return true
+	case FailoverLocal:
+		local := o.failover.local
+		if now := time.Now().UnixNano(); now-o.failover.lastEvict.Load() > int64(local.ttl) {
+			o.failover.lastEvict.Store(now)
+			local.EvictIdle()
+		}
+		res := local.take(identifier)
+		setRateLimitHeaders(w, res)
+		return !res.Allowed && o.overLimit(w, r, res.RetryAfter)
+	}
+	return false
+}
+
+// redisRecovered notes Redis counting again after failing
+func (o *rateLimitOptions) redisRecovered(ctx context.Context) {
+	if o.failover.failing.CompareAndSwap(true, false) {
+		logging.Printf(ctx, "Redis rate limit %q recovered", o.label)
+	}
+}
+
+// redisLimiter is a limiter counting in Redis, whichever the algorithm
+type redisLimiter interface {
+	Allow(ctx context.Context, identifier string) (RateLimitResult, error)
+	overLimit(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) (rejected bool)
+	redisFailed(w http.ResponseWriter, r *http.Request, identifier string, err error) (rejected bool)
+	redisRecovered(ctx context.Context)
+}
+
+// checkRedisLimit counts the request against identifier in rl, answering
+// it if it's turned away, and reports whether it was
+func checkRedisLimit(w http.ResponseWriter, r *http.Request, rl redisLimiter, identifier string) (rejected bool) {
+	res, err := rl.Allow(r.Context(), identifier)
 	if err != nil {
-		return false, fmt.Errorf("failed to get incr result: %w", err)
+		return rl.redisFailed(w, r, identifier, err)
 	}
+	rl.redisRecovered(r.Context())
+
+	setRateLimitHeaders(w, res)
+	return !res.Allowed && rl.overLimit(w, r, res.RetryAfter)
+}
 
-	return count <= int64(rl.limit), nil
+// newRedisLimiter builds the limiter for the algorithm opts pick
+func newRedisLimiter(client *redis.RedisClient, scope string, limit int, window time.Duration, opts []RateLimitOption) redisLimiter {
+	if newRateLimitOptions(opts).algorithm == SlidingWindow {
+		rl := NewSlidingWindowLimiter(client, limit, window, opts...)
+		rl.scope = scope
+		return rl
+	}
+	rl := NewRedisRateLimiter(client, limit, window, opts...)
+	rl.scope = scope
+	return rl
 }
 
 // RedisRateLimitMiddleware using Redis
 func RedisRateLimitMiddleware(rl *RedisRateLimiter) func(http.Handler) http.Handler {
+	return redisKeyedRateLimitMiddleware(rl, getClientIP)
+}
+
+func redisKeyedRateLimitMiddleware(rl redisLimiter, key KeyFunc) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx := r.Context()
-			ip := getClientIP(r)
-
-			allowed, err := rl.Allow(ctx, ip)
-			if err != nil {
-				// Fallback to allow request if Redis is down
-				// Log error for monitoring
-				fmt.Printf("Redis rate limit error: %v\n", err)
-				next.ServeHTTP(w, r)
+			if checkRedisLimit(w, r, rl, requestKey(r, key)) {
 				return
 			}
-
-			if !allowed {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusTooManyRequests)
-				json.NewEncoder(w).Encode(map[string]interface{}{
-					"error":   "rate_limit_exceeded",
-					"message": "Too many requests. Please try again later.",
-				})
-				return
-			}
-
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
-// Custom Redis rate limiter for different endpoints
-func CustomRedisRateLimitMiddleware(client *redis.RedisClient, limit int, window time.Duration) func(http.Handler) http.Handler {
-	rl := NewRedisRateLimiter(client, limit, window)
-	return RedisRateLimitMiddleware(rl)
+// Custom Redis rate limiter for different endpoints. scope keeps the
+// endpoint's counters apart from the global limiter's; routes that should
+// share a limit reuse the returned middleware. WithAlgorithm in opts picks
+// how it counts.
+func CustomRedisRateLimitMiddleware(client *redis.RedisClient, scope string, limit int, window time.Duration, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	return redisKeyedRateLimitMiddleware(newRedisLimiter(client, scope, limit, window, opts), getClientIP)
 }
 
-// RedisUserRateLimitMiddleware - rate limit based on authenticated user ID
-func RedisUserRateLimitMiddleware(client *redis.RedisClient, limit int, window time.Duration) func(http.Handler) http.Handler {
+// CustomRedisKeyedRateLimitMiddleware is CustomRedisRateLimitMiddleware
+// counting per key instead of per IP
+func CustomRedisKeyedRateLimitMiddleware(client *redis.RedisClient, scope string, limit int, window time.Duration, key KeyFunc, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	return redisKeyedRateLimitMiddleware(newRedisLimiter(client, scope, limit, window, opts), key)
+}
+
+// RedisUserRateLimitMiddleware - rate limit based on authenticated user ID.
+// Each user counts per endpoint, as mux names it, so a route's versioned
+// path and its legacy alias share one budget.
+func RedisUserRateLimitMiddleware(client *redis.RedisClient, mux RouteMatcher, limit int, window time.Duration, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	rl := newRedisLimiter(client, "", limit, window, opts)
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Get user ID from context
 			userID := GetUserID(r)
 			if userID == 0 {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				respond.Error(w, r, "Unauthorized", http.StatusUnauthorized)
 				return
 			}
 
-			// Create rate limiter with user-specific key
-			rl := NewRedisRateLimiter(client, limit, window)
-			identifier := fmt.Sprintf("user:%d:%s", userID, r.URL.Path)
-
-			ctx := r.Context()
-			allowed, err := rl.Allow(ctx, identifier)
-			if err != nil {
-				// Log error but allow request
-				log.Printf("Redis rate limit error for user %d: %v", userID, err)
-				next.ServeHTTP(w, r)
-				return
+			// Count per user and endpoint
+			_, endpoint := mux.Handler(r)
+			if endpoint == "" {
+				endpoint = r.URL.Path
 			}
-
-			if !allowed {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusTooManyRequests)
-				json.NewEncoder(w).Encode(map[string]interface{}{
-					"error":   "rate_limit_exceeded",
-					"message": "Too many requests. Please try again later.",
-				})
+			if checkRedisLimit(w, r, rl, fmt.Sprintf("user:%d:%s", userID, endpoint)) {
 				return
 			}
 
@@ -0,0 +1,214 @@
+// internal/interfaces/http/middleware/redis_ratelimit_test.go
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"user-service/internal/interfaces/http/router"
+)
+
+func TestRedisRateLimiter_WindowStartsWithTheFirstRequest(t *testing.T) {
+	mr, client := newTestRedis(t)
+	rl := NewRedisRateLimiter(client, 3, time.Minute)
+	rl.scope = "login"
+	ctx := context.Background()
+	const key = "rate_limit:login:203.0.113.7"
+
+	allow := func() (bool, time.Duration) {
+		t.Helper()
+		res, err := rl.Allow(ctx, "203.0.113.7")
+		if err != nil {
+			t.Fatalf("allow: %v", err)
+		}
+		return res.Allowed, res.Reset
+	}
+
+	if allowed, reset := allow(); !allowed || reset != time.Minute {
+		t.Fatalf("expected the first request allowed with the whole window left, got %v, %v", allowed, reset)
+	}
+
+	// Steady traffic doesn't move the window's end
+	for _, wantAllowed := range []bool{true, true, false, false} {
+		mr.FastForward(10 * time.Second)
+		allowed, reset := allow()
+		if allowed != wantAllowed {
+			t.Errorf("expected allowed %v, got %v", wantAllowed, allowed)
+		}
+		if ttl := mr.TTL(key); reset != ttl {
+			t.Errorf("expected reset %v to match the key's TTL %v", reset, ttl)
+		}
+	}
+	if ttl := mr.TTL(key); ttl != 20*time.Second {
+		t.Fatalf("expected the key to expire a minute after the first request, 20s from now, got %v", ttl)
+	}
+
+	mr.FastForward(20 * time.Second)
+	if mr.Exists(key) {
+		t.Fatal("expected the counter gone once the window ended")
+	}
+	if allowed, reset := allow(); !allowed || reset != time.Minute {
+		t.Errorf("expected a fresh window, got %v, %v", allowed, reset)
+	}
+}
+
+func TestRedisRateLimiter_CounterWithoutExpiryGetsOne(t *testing.T) {
+	mr, client := newTestRedis(t)
+	rl := NewRedisRateLimiter(client, 3, time.Minute)
+	// As left by the old INCR then EXPIRE if the second never ran
+	mr.Set("rate_limit:203.0.113.7", "1")
+
+	if res, err := rl.Allow(context.Background(), "203.0.113.7"); err != nil || res.Reset != time.Minute {
+		t.Fatalf("expected the window started over, got %+v, %v", res, err)
+	}
+	if ttl := mr.TTL("rate_limit:203.0.113.7"); ttl != time.Minute {
+		t.Errorf("expected the counter to expire, got TTL %v", ttl)
+	}
+}
+
+func TestRedisRateLimitMiddleware_ResetHeader(t *testing.T) {
+	mr, client := newTestRedis(t)
+	handler := CustomRedisRateLimitMiddleware(client, "login", 1, time.Minute)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users/login", nil)
+		req.RemoteAddr = "203.0.113.7:4000"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := request()
+	mr.FastForward(15 * time.Second)
+	second := request()
+	if first.Code != http.StatusOK || second.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 200 then 429, got %d and %d", first.Code, second.Code)
+	}
+
+	// Only Redis' clock moved on, so the window that began with the first
+	// request ends 60s and then 45s from now by the test's
+	now := time.Now()
+	for rr, left := range map[*httptest.ResponseRecorder]time.Duration{first: time.Minute, second: 45 * time.Second} {
+		reset, err := strconv.ParseInt(rr.Header().Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			t.Fatalf("expected X-RateLimit-Reset, got %q", rr.Header().Get("X-RateLimit-Reset"))
+		}
+		if want := now.Add(left).Unix(); reset < want || reset > want+1 {
+			t.Errorf("expected a reset at %d, got %d", want, reset)
+		}
+	}
+}
+
+func TestRedisRateLimiter_FailureModes(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      FailureMode
+		limitMode RateLimitMode
+		wantCodes []int
+	}{
+		{name: "open", mode: FailOpen, wantCodes: []int{200, 200, 200, 200}},
+		{name: "open by default", wantCodes: []int{200, 200, 200, 200}},
+		{name: "closed", mode: FailClosed, wantCodes: []int{503, 503, 503, 503}},
+		{name: "closed only when enforced", mode: FailClosed, limitMode: RateLimitWarn, wantCodes: []int{200, 200, 200, 200}},
+		{name: "local", mode: FailoverLocal, wantCodes: []int{200, 200, 429, 429}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr, client := newTestRedis(t)
+			mr.SetError("ERR connection lost")
+			observer := &recordingRateLimitObserver{}
+			opts := []RateLimitOption{WithRejectionObserver(observer, "login"), WithFailureMode(tt.mode)}
+			if tt.limitMode != "" {
+				opts = append(opts, WithModes(NewRateLimitModes(map[string]RateLimitMode{"login": tt.limitMode}), "login"))
+			}
+			handler := CustomRedisRateLimitMiddleware(client, "login", 2, time.Minute, opts...)(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}),
+			)
+
+			for i, want := range tt.wantCodes {
+				req := httptest.NewRequest(http.MethodPost, "/users/login", nil)
+				req.RemoteAddr = "203.0.113.7:4000"
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				if rr.Code != want {
+					t.Fatalf("request %d: expected %d, got %d: %s", i+1, want, rr.Code, rr.Body)
+				}
+				if want != http.StatusOK && rr.Header().Get("Retry-After") == "" {
+					t.Errorf("request %d: expected Retry-After on the %d", i+1, want)
+				}
+			}
+			if len(observer.failures) != len(tt.wantCodes) {
+				t.Errorf("expected every request reported as decided without Redis, got %v", observer.failures)
+			}
+		})
+	}
+}
+
+func TestRedisRateLimiter_BackOnRedisAfterAnOutage(t *testing.T) {
+	mr, client := newTestRedis(t)
+	handler := RedisUserRateLimitMiddleware(client, http.NewServeMux(), 3, time.Minute, WithFailureMode(FailoverLocal))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+	remaining := func() string {
+		req := httptest.NewRequest(http.MethodPut, "/users/update", nil)
+		req = req.WithContext(context.WithValue(req.Context(), userIDKey, uint(7)))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rr.Code)
+		}
+		return rr.Header().Get("X-RateLimit-Remaining")
+	}
+
+	if got := remaining(); got != "2" {
+		t.Fatalf("expected 2 remaining in Redis, got %s", got)
+	}
+	// The local limiter starts with its own full budget
+	mr.SetError("ERR connection lost")
+	if got := remaining(); got != "2" {
+		t.Errorf("expected 2 remaining locally, got %s", got)
+	}
+	// Redis picks up where it left off
+	mr.SetError("")
+	if got := remaining(); got != "1" {
+		t.Errorf("expected 1 remaining in Redis, got %s", got)
+	}
+}
+
+func TestRedisUserRateLimit_VersionedAndLegacyPathsShareABudget(t *testing.T) {
+	_, client := newTestRedis(t)
+	mux := router.New()
+	mux.Versioned(RedisUserRateLimitMiddleware(client, mux, 2, time.Minute)).HandleFunc("PUT /users/update",
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+	for i, tt := range []struct {
+		path string
+		want int
+	}{
+		{"/v1/users/update", http.StatusOK},
+		{"/users/update", http.StatusOK},
+		{"/users/update", http.StatusTooManyRequests},
+		{"/v1/users/update", http.StatusTooManyRequests},
+	} {
+		req := httptest.NewRequest(http.MethodPut, tt.path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), userIDKey, uint(7)))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("request %d to %s: expected %d, got %d", i+1, tt.path, tt.want, rr.Code)
+		}
+	}
+}
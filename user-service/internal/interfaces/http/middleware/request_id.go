@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/logging"
+)
+
+// maxRequestIDLength caps caller-supplied IDs so they can't bloat logs
+const maxRequestIDLength = 64
+
+// RequestID gives every request an ID, reusing the caller's X-Request-ID
+// when it sent a sane one and generating a UUID otherwise. The ID is
+// echoed in the response header, where respond.JSON picks it up for its
+// logs, and stored in the context, where logging.Printf picks it up for
+// everyone else's.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(respond.RequestIDHeader)
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+
+		w.Header().Set(respond.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// GetRequestID returns the ID RequestID stored in ctx, or ""
+func GetRequestID(ctx context.Context) string {
+	return logging.RequestID(ctx)
+}
+
+// validRequestID accepts short IDs made of letters, digits, '-', '_' and
+// '.', which covers UUIDs and trace IDs but nothing that could forge a log
+// line
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
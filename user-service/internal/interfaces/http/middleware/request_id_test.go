@@ -0,0 +1,80 @@
+// internal/interfaces/http/middleware/request_id_test.go
+package middleware
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = GetRequestID(r.Context())
+	}))
+
+	tests := []struct {
+		name     string
+		incoming string
+		wantSame bool
+	}{
+		{"no header", "", false},
+		{"uuid", "3f2b8c1e-9a4d-4f6b-8e2a-1c5d7e9f0a1b", true},
+		{"trace style", "svc.checkout_42", true},
+		{"log injection", "abc\nFAKE LOG LINE", false},
+		{"too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set("X-Request-ID", tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get("X-Request-ID")
+			if got == "" {
+				t.Fatal("expected a request ID on the response")
+			}
+			if got != seen {
+				t.Errorf("response header %q does not match context value %q", got, seen)
+			}
+			if (got == tt.incoming) != tt.wantSame {
+				t.Errorf("incoming %q, got %q", tt.incoming, got)
+			}
+			if !validRequestID(got) {
+				t.Errorf("generated ID %q is not itself valid", got)
+			}
+			if !tt.wantSame && len(got) != 36 {
+				t.Errorf("expected a generated UUID, got %q", got)
+			}
+		})
+	}
+}
+
+func TestRequestID_TagsLogLines(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	// A limiter in warn mode logs every request over its limit
+	modes := NewRateLimitModes(map[string]RateLimitMode{"global": RateLimitWarn})
+	rl := NewRateLimiter(0.001, 1, time.Minute, WithModes(modes, "global"))
+	handler := RequestID(RateLimitMiddleware(rl)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	for _, id := range []string{"first-request", "second-request"} {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.RemoteAddr = "127.0.0.1:12345"
+		req.Header.Set("X-Request-ID", id)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if !strings.Contains(logs.String(), "would have rejected GET /users request_id=second-request") {
+		t.Errorf("expected the warning tagged with its request, got %q", logs.String())
+	}
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"user-service/internal/logging"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestLogOption configures Logger
+type RequestLogOption func(*requestLogOptions)
+
+type requestLogOptions struct {
+	skip       map[string]bool
+	sampleRate float64
+}
+
+// WithLogSkipPaths leaves successful requests for paths out of the log,
+// such as the probes that would otherwise drown out everything else
+func WithLogSkipPaths(paths ...string) RequestLogOption {
+	return func(o *requestLogOptions) {
+		for _, path := range paths {
+			o.skip[path] = true
+		}
+	}
+}
+
+// WithLogSampleRate logs only rate, from 0 to 1, of successful requests
+func WithLogSampleRate(rate float64) RequestLogOption {
+	return func(o *requestLogOptions) {
+		o.sampleRate = rate
+	}
+}
+
+type requestLogKey struct{}
+
+// requestLogEntry is what Logger learns from the handlers inside it, which
+// only see the request after it has been through them
+type requestLogEntry struct {
+	userID uint
+}
+
+// noteUserID records the authenticated user for the request's log line
+// and its span
+func noteUserID(ctx context.Context, userID uint) {
+	if entry, ok := ctx.Value(requestLogKey{}).(*requestLogEntry); ok {
+		entry.userID = userID
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("user.id", int64(userID)))
+}
+
+// Logger logs one structured line per request with its method, path,
+// status, size, duration, client IP, request ID and, once authenticated,
+// user ID: at Info, Warn for 4xx and Error for 5xx. Failed requests are
+// always logged; skipping and sampling only apply to the rest. It goes
+// inside RequestID, whose ID it reports.
+func Logger(logger *slog.Logger, opts ...RequestLogOption) func(http.Handler) http.Handler {
+	options := requestLogOptions{skip: make(map[string]bool), sampleRate: 1}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			entry := &requestLogEntry{}
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			path := r.URL.Path
+
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), requestLogKey{}, entry)))
+
+			level := slog.LevelInfo
+			switch {
+			case sw.status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case sw.status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			case options.skip[path]:
+				return
+			case options.sampleRate < 1 && rand.Float64() >= options.sampleRate:
+				return
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", path),
+				slog.Int("status", sw.status),
+				slog.Int64("bytes", sw.bytes),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("client_ip", getClientIP(r)),
+				slog.String("request_id", logging.RequestID(r.Context())),
+			}
+			if entry.userID != 0 {
+				attrs = append(attrs, slog.Uint64("user_id", uint64(entry.userID)))
+			}
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
+
+// statusWriter notes the status and size of the response passing through
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	bytes       int64
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if !sw.wroteHeader {
+		sw.status = status
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	n, err := sw.ResponseWriter.Write(b)
+	sw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
@@ -0,0 +1,135 @@
+// internal/interfaces/http/middleware/request_log_test.go
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/infrastructure/auth"
+)
+
+// logLines decodes the JSON lines a slog.JSONHandler wrote to buf
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestLogger(t *testing.T) {
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	token, _ := jwtManager.GenerateToken(42)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/me", AuthMiddleware(jwtManager)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":42}`))
+	})))
+	mux.HandleFunc("POST /users/login", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad credentials", http.StatusUnauthorized)
+	})
+	mux.HandleFunc("GET /boom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	var buf bytes.Buffer
+	handler := RequestID(Logger(slog.New(slog.NewJSONHandler(&buf, nil)))(mux))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus float64
+		wantLevel  string
+		wantBytes  float64
+		wantUser   interface{}
+	}{
+		{"authenticated", http.MethodGet, "/users/me", token, 200, "INFO", 9, float64(42)},
+		{"client error", http.MethodPost, "/users/login", "", 401, "WARN", 16, nil},
+		{"server error", http.MethodGet, "/boom", "", 500, "ERROR", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.RemoteAddr = "192.0.2.7:5555"
+			req.Header.Set("X-Request-ID", "req-"+strings.ReplaceAll(tt.name, " ", "-"))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			lines := logLines(t, &buf)
+			if len(lines) != 1 {
+				t.Fatalf("expected one log line, got %d: %s", len(lines), buf.String())
+			}
+			entry := lines[0]
+			want := map[string]interface{}{
+				"level":      tt.wantLevel,
+				"method":     tt.method,
+				"path":       tt.path,
+				"status":     tt.wantStatus,
+				"bytes":      tt.wantBytes,
+				"client_ip":  "192.0.2.7",
+				"request_id": "req-" + strings.ReplaceAll(tt.name, " ", "-"),
+				"user_id":    tt.wantUser,
+			}
+			for key, value := range want {
+				if entry[key] != value {
+					t.Errorf("expected %s %v, got %v", key, value, entry[key])
+				}
+			}
+			if _, ok := entry["duration"].(float64); !ok {
+				t.Errorf("expected a duration, got %v", entry["duration"])
+			}
+		})
+	}
+}
+
+func TestLogger_SkipsAndSamplesSuccesses(t *testing.T) {
+	status := http.StatusOK
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	})
+
+	tests := []struct {
+		name   string
+		opts   []RequestLogOption
+		path   string
+		status int
+		want   int
+	}{
+		{"skipped path", []RequestLogOption{WithLogSkipPaths("/health")}, "/health", http.StatusOK, 0},
+		{"failing skipped path", []RequestLogOption{WithLogSkipPaths("/health")}, "/health", http.StatusServiceUnavailable, 1},
+		{"other path", []RequestLogOption{WithLogSkipPaths("/health")}, "/users", http.StatusOK, 1},
+		{"sampled out", []RequestLogOption{WithLogSampleRate(0)}, "/users", http.StatusOK, 0},
+		{"errors despite sampling", []RequestLogOption{WithLogSampleRate(0)}, "/users", http.StatusNotFound, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			handler := Logger(slog.New(slog.NewJSONHandler(&buf, nil)), tt.opts...)(next)
+			status = tt.status
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if got := len(logLines(t, &buf)); got != tt.want {
+				t.Errorf("expected %d log lines, got %d: %s", tt.want, got, buf.String())
+			}
+		})
+	}
+}
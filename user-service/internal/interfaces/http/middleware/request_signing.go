@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"user-service/internal/infrastructure/redis"
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/logging"
+	"user-service/reqsign"
+)
+
+const (
+	// SignatureWindow is how far a request's timestamp may be from our
+	// clock, either way
+	SignatureWindow = 5 * time.Minute
+
+	// maxSignedBodyBytes caps the body read to check a signature
+	maxSignedBodyBytes = 1 << 20
+)
+
+// NonceStore remembers signatures already accepted, so a captured request
+// can't be replayed within SignatureWindow
+type NonceStore interface {
+	// Claim records nonce for ttl, reporting false if it was already there
+	Claim(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// RedisNonceStore shares seen signatures across instances
+type RedisNonceStore struct {
+	client *redis.RedisClient
+}
+
+func NewRedisNonceStore(client *redis.RedisClient) *RedisNonceStore {
+	return &RedisNonceStore{client: client}
+}
+
+func (s *RedisNonceStore) Claim(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, "reqsig:"+nonce, "1", ttl)
+}
+
+// MemoryNonceStore is the single-instance fallback when Redis isn't
+// configured
+type MemoryNonceStore struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+	now  func() time.Time
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{seen: make(map[string]time.Time), now: time.Now}
+}
+
+func (s *MemoryNonceStore) Claim(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for n, expiresAt := range s.seen {
+		if !now.Before(expiresAt) {
+			delete(s.seen, n)
+		}
+	}
+	if _, ok := s.seen[nonce]; ok {
+		return false, nil
+	}
+	s.seen[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// SignatureOption configures RequireRequestSignature
+type SignatureOption func(*signatureVerifier)
+
+// WithSignatureClock sets the time source timestamps are checked against
+func WithSignatureClock(now func() time.Time) SignatureOption {
+	return func(v *signatureVerifier) {
+		v.now = now
+	}
+}
+
+type signatureVerifier struct {
+	keys   map[string]string
+	nonces NonceStore
+	now    func() time.Time
+}
+
+// RequireRequestSignature accepts only requests signed with reqsign by the
+// client APIKeyAuth identified, using that client's key, within
+// SignatureWindow and not seen before. It must run after APIKeyAuth.
+func RequireRequestSignature(keys map[string]string, nonces NonceStore, opts ...SignatureOption) func(http.Handler) http.Handler {
+	v := &signatureVerifier{keys: keys, nonces: nonces, now: time.Now}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, ok := v.keys[GetAPIClient(r)]
+			timestamp := r.Header.Get(reqsign.TimestampHeader)
+			presented := r.Header.Get(reqsign.SignatureHeader)
+			if !ok || timestamp == "" || presented == "" {
+				respond.Error(w, r, "missing request signature", http.StatusUnauthorized)
+				return
+			}
+
+			unix, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				respond.Error(w, r, "invalid signature timestamp", http.StatusUnauthorized)
+				return
+			}
+			if skew := v.now().Sub(time.Unix(unix, 0)); skew > SignatureWindow || skew < -SignatureWindow {
+				respond.Error(w, r, "signature timestamp outside the allowed window", http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodyBytes))
+			if err != nil {
+				respond.Error(w, r, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			expected := reqsign.Signature(key, r.Method, r.URL.RequestURI(), timestamp, body)
+			if !hmac.Equal([]byte(presented), []byte(expected)) {
+				respond.Error(w, r, "invalid request signature", http.StatusUnauthorized)
+				return
+			}
+
+			// Timestamps outside the window are refused above, so a nonce
+			// only needs to outlive the window on both sides
+			fresh, err := v.nonces.Claim(r.Context(), expected, 2*SignatureWindow)
+			if err != nil {
+				logging.Printf(r.Context(), "Request signature nonce check failed: %v", err)
+				respond.Error(w, r, "request signature check unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			if !fresh {
+				respond.Error(w, r, "replayed request", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// internal/interfaces/http/middleware/request_signing_test.go
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/reqsign"
+)
+
+func TestRequireRequestSignature(t *testing.T) {
+	keys := map[string]string{"checkout": "checkout-key", "cart": "cart-key"}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var gotBody string
+	handler := APIKeyAuth(keys)(RequireRequestSignature(keys, NewMemoryNonceStore(),
+		WithSignatureClock(func() time.Time { return now }),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	// newRequest signs a request as client at signedAt
+	newRequest := func(t *testing.T, client, target, body string, signedAt time.Time) *http.Request {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("X-API-Key", keys[client])
+		if err := reqsign.Sign(req, keys[client], signedAt); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return req
+	}
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("valid, then replayed", func(t *testing.T) {
+		req := newRequest(t, "checkout", "/internal/users/lookup?email=a%40example.com", `{"ids":[1,2]}`, now)
+		replay := req.Clone(req.Context())
+		replay.Body = io.NopCloser(strings.NewReader(`{"ids":[1,2]}`))
+
+		if rec := serve(req); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+		}
+		if gotBody != `{"ids":[1,2]}` {
+			t.Errorf("expected the body passed on intact, got %q", gotBody)
+		}
+		if rec := serve(replay); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "replayed") {
+			t.Errorf("expected the replay refused, got %d: %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("clock skew", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			offset time.Duration
+			want   int
+		}{
+			{"slightly behind", -SignatureWindow + time.Second, http.StatusOK},
+			{"slightly ahead", SignatureWindow - time.Second, http.StatusOK},
+			{"too old", -SignatureWindow - time.Second, http.StatusUnauthorized},
+			{"too far ahead", SignatureWindow + time.Second, http.StatusUnauthorized},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := newRequest(t, "cart", "/internal/users/lookup", tt.name, now.Add(tt.offset))
+				if rec := serve(req); rec.Code != tt.want {
+					t.Errorf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body)
+				}
+			})
+		}
+	})
+
+	t.Run("tampering", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			tamper func(req *http.Request)
+		}{
+			{"body", func(req *http.Request) {
+				req.Body = io.NopCloser(strings.NewReader(`{"ids":[1,2,3]}`))
+			}},
+			{"query", func(req *http.Request) {
+				req.URL.RawQuery = "email=b%40example.com"
+			}},
+			{"timestamp", func(req *http.Request) {
+				req.Header.Set(reqsign.TimestampHeader, "1717243201")
+			}},
+			{"another client's key", func(req *http.Request) {
+				req.Header.Set("X-API-Key", keys["cart"])
+			}},
+			{"unsigned", func(req *http.Request) {
+				req.Header.Del(reqsign.SignatureHeader)
+			}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := newRequest(t, "checkout", "/internal/users/lookup?email=a%40example.com", `{"ids":[1,2]}`, now)
+				tt.tamper(req)
+				if rec := serve(req); rec.Code != http.StatusUnauthorized {
+					t.Errorf("expected 401, got %d: %s", rec.Code, rec.Body)
+				}
+			})
+		}
+	})
+}
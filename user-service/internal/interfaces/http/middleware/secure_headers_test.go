@@ -0,0 +1,73 @@
+// internal/interfaces/http/middleware/secure_headers_test.go
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	withHSTS := WithHSTS(24*time.Hour, false)
+	behindProxy := WithHSTS(24*time.Hour, true)
+	docsPolicy := WithContentSecurityPolicy("/docs", "default-src 'none'")
+
+	tests := []struct {
+		name      string
+		opts      []SecureHeadersOption
+		path      string
+		tls       bool
+		forwarded string
+		want      map[string]string
+	}{
+		{name: "defaults", path: "/users/me", want: map[string]string{
+			"X-Content-Type-Options":    "nosniff",
+			"X-Frame-Options":           "DENY",
+			"Referrer-Policy":           "no-referrer",
+			"Strict-Transport-Security": "",
+			"Content-Security-Policy":   "",
+		}},
+		{name: "referrer policy", opts: []SecureHeadersOption{WithReferrerPolicy("strict-origin")}, path: "/users/me",
+			want: map[string]string{"Referrer-Policy": "strict-origin"}},
+		{name: "no referrer policy", opts: []SecureHeadersOption{WithReferrerPolicy("")}, path: "/users/me",
+			want: map[string]string{"Referrer-Policy": ""}},
+		{name: "hsts over tls", opts: []SecureHeadersOption{withHSTS}, path: "/users/me", tls: true,
+			want: map[string]string{"Strict-Transport-Security": "max-age=86400; includeSubDomains"}},
+		{name: "hsts off", opts: []SecureHeadersOption{WithHSTS(0, true)}, path: "/users/me", tls: true,
+			want: map[string]string{"Strict-Transport-Security": ""}},
+		{name: "no hsts over plain http", opts: []SecureHeadersOption{withHSTS}, path: "/users/me",
+			want: map[string]string{"Strict-Transport-Security": ""}},
+		{name: "forwarded proto ignored", opts: []SecureHeadersOption{withHSTS}, path: "/users/me", forwarded: "https",
+			want: map[string]string{"Strict-Transport-Security": ""}},
+		{name: "forwarded proto trusted", opts: []SecureHeadersOption{behindProxy}, path: "/users/me", forwarded: "https, http",
+			want: map[string]string{"Strict-Transport-Security": "max-age=86400; includeSubDomains"}},
+		{name: "forwarded http", opts: []SecureHeadersOption{behindProxy}, path: "/users/me", forwarded: "http",
+			want: map[string]string{"Strict-Transport-Security": ""}},
+		{name: "docs policy", opts: []SecureHeadersOption{docsPolicy}, path: "/docs",
+			want: map[string]string{"Content-Security-Policy": "default-src 'none'"}},
+		{name: "no policy elsewhere", opts: []SecureHeadersOption{docsPolicy}, path: "/openapi.json",
+			want: map[string]string{"Content-Security-Policy": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.forwarded)
+			}
+			rec := httptest.NewRecorder()
+			SecureHeaders(tt.opts...)(next).ServeHTTP(rec, req)
+
+			for header, want := range tt.want {
+				if got := rec.Header().Get(header); got != want {
+					t.Errorf("expected %s %q, got %q", header, want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"user-service/internal/infrastructure/auth"
+	"user-service/internal/interfaces/http/respond"
+)
+
+// RequireSignedURL serves only requests whose URL was signed by signer and
+// hasn't expired, answering 403 otherwise. It stands in for authentication
+// on download routes, whose links are handed to browsers and other tools
+// that carry no session.
+func RequireSignedURL(signer *auth.URLSigner) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			err := signer.Verify(r.URL.EscapedPath(), r.URL.Query())
+			switch {
+			case errors.Is(err, auth.ErrURLExpired):
+				respond.Error(w, r, "link expired", http.StatusForbidden)
+				return
+			case err != nil:
+				respond.Error(w, r, "invalid link signature", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// internal/interfaces/http/middleware/signed_url_test.go
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/infrastructure/auth"
+)
+
+func TestRequireSignedURL(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	signer := auth.NewURLSigner([]byte("file-url-secret"), auth.WithURLClock(func() time.Time { return now }))
+	handler := RequireSignedURL(signer)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	link := signer.Sign("/files/exports/42.zip", time.Hour)
+	other := auth.NewURLSigner([]byte("another-secret"), auth.WithURLClock(func() time.Time { return now }))
+
+	tests := []struct {
+		name    string
+		target  string
+		advance time.Duration
+		want    int
+	}{
+		{"valid", link, 0, http.StatusOK},
+		{"just before expiry", link, time.Hour - time.Second, http.StatusOK},
+		{"expired", link, time.Hour, http.StatusForbidden},
+		{"other path", strings.Replace(link, "42.zip", "43.zip", 1), 0, http.StatusForbidden},
+		{"extended expiry", strings.Replace(link, "expires=", "expires=9", 1), 0, http.StatusForbidden},
+		{"unsigned", "/files/exports/42.zip", 0, http.StatusForbidden},
+		{"other secret", other.Sign("/files/exports/42.zip", time.Hour), 0, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := now
+			now = now.Add(tt.advance)
+			defer func() { now = start }()
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"user-service/internal/infrastructure/redis"
+)
+
+// slidingWindow checks and counts a request in one step, so concurrent
+// requests can't all read the count before any adds to it. State is a hash
+// of the current window's start and the counts of it and the one before;
+// time is Redis', so every instance agrees on which window it is.
+//
+// KEYS[1] the limiter's hash
+// ARGV[1] window in milliseconds
+// ARGV[2] limit
+// Returns 1 if the request is allowed, 0 if not; the milliseconds left in
+// the current window; how many more requests would be allowed now; and for
+// a request that wasn't, the milliseconds until one would be.
+var slidingWindow = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local start = now - now % window
+
+local state = redis.call("HMGET", KEYS[1], "start", "current", "previous")
+local current = tonumber(state[2]) or 0
+local previous = tonumber(state[3]) or 0
+local stored = tonumber(state[1])
+if stored ~= start then
+	if stored == start - window then
+		previous = current
+	else
+		previous = 0
+	end
+	current = 0
+end
+
+-- The previous window counts for the share of it still overlapping, left
+-- of window. Compared scaled by window, the sums stay whole numbers.
+local allowed = 0
+local left = start + window - now
+local retry = 0
+if previous * left + current * window < limit * window then
+	current = current + 1
+	allowed = 1
+elseif current >= limit then
+	-- Once this window's count becomes the previous one it starts
+	-- sliding out
+	retry = left
+else
+	-- Wait until previous * left falls below the room this window leaves
+	local room = (limit - current) * window
+	retry = left - math.floor((room + previous - 1) / previous) + 1
+end
+redis.call("HSET", KEYS[1], "start", start, "current", current, "previous", previous)
+redis.call("PEXPIRE", KEYS[1], window * 2)
+local remaining = math.max(math.floor((limit * window - previous * left) / window) - current, 0)
+return {allowed, left, remaining, retry}
+`)
+
+// SlidingWindowLimiter limits requests in Redis with a sliding window
+// counter: at most limit in any window-long stretch, give or take the
+// assumption that the previous window's requests were spread evenly
+type SlidingWindowLimiter struct {
+	client *redis.RedisClient
+	limit  int
+	window time.Duration
+	// scope namespaces the counters so limiters don't share a budget
+	scope string
+
+	rateLimitOptions
+}
+
+func NewSlidingWindowLimiter(client *redis.RedisClient, limit int, window time.Duration, opts ...RateLimitOption) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{
+		client:           client,
+		limit:            limit,
+		window:           window,
+		rateLimitOptions: newRedisRateLimitOptions(opts, limit, window),
+	}
+}
+
+// Allow counts a request against identifier. Reset is when the current
+// window ends and its count starts sliding out.
+func (rl *SlidingWindowLimiter) Allow(ctx context.Context, identifier string) (RateLimitResult, error) {
+	// Not rate_limit:, whose keys are the fixed window's counters; a
+	// limiter switching algorithm would otherwise find the wrong type
+	key := fmt.Sprintf("rate_limit_sw:%s", identifier)
+	if rl.scope != "" {
+		key = fmt.Sprintf("rate_limit_sw:%s:%s", rl.scope, identifier)
+	}
+
+	res, err := rl.client.Eval(ctx, slidingWindow, []string{key}, rl.window.Milliseconds(), rl.limit)
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("redis sliding window error: %w", err)
+	}
+	values, ok := scriptInts(res, 4)
+	if !ok {
+		return RateLimitResult{}, fmt.Errorf("unexpected sliding window result %v", res)
+	}
+	return RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      rl.limit,
+		Remaining:  int(values[2]),
+		Reset:      time.Duration(values[1]) * time.Millisecond,
+		RetryAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
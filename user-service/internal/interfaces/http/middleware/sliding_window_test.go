@@ -0,0 +1,128 @@
+// internal/interfaces/http/middleware/sliding_window_test.go
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowLimiter_NoBurstAcrossTheBoundary(t *testing.T) {
+	mr, client := newTestRedis(t)
+	rl := NewSlidingWindowLimiter(client, 5, time.Minute)
+	ctx := context.Background()
+	windowStart := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	allowedAt := func(offset time.Duration, attempts int) int {
+		t.Helper()
+		mr.SetTime(windowStart.Add(offset))
+		allowed := 0
+		for range attempts {
+			res, err := rl.Allow(ctx, "203.0.113.7")
+			if err != nil {
+				t.Fatalf("allow: %v", err)
+			}
+			if res.Allowed {
+				allowed++
+			}
+		}
+		return allowed
+	}
+
+	// The whole limit at the end of one window...
+	if got := allowedAt(59*time.Second, 10); got != 5 {
+		t.Fatalf("expected 5 allowed late in the window, got %d", got)
+	}
+	// ...leaves one just after the next begins, as 1/60 of the previous
+	// window has slid out, where a fixed window would allow 5 more
+	if got := allowedAt(61*time.Second, 10); got != 1 {
+		t.Errorf("expected 1 allowed across the boundary, got %d", got)
+	}
+	// Another is allowed once a fifth of the previous window, one of its
+	// requests, has slid out
+	res, err := rl.Allow(ctx, "203.0.113.7")
+	if err != nil || res.Allowed || res.RetryAfter != 11*time.Second+time.Millisecond {
+		t.Errorf("expected a retry 11s on, got %+v, %v", res, err)
+	}
+	if got := allowedAt(72*time.Second, 1); got != 0 {
+		t.Errorf("expected none allowed just before the retry, got %d", got)
+	}
+	if got := allowedAt(72*time.Second+time.Millisecond, 2); got != 1 {
+		t.Errorf("expected 1 allowed at the retry, got %d", got)
+	}
+	// Halfway through, half the previous window still counts: 2.5 of it
+	// and the 2 already in this one leave room for 1
+	if got := allowedAt(90*time.Second, 10); got != 1 {
+		t.Errorf("expected 1 allowed halfway through, got %d", got)
+	}
+	// With a quiet window between, the limit is back in full
+	if got := allowedAt(180*time.Second, 10); got != 5 {
+		t.Errorf("expected 5 allowed after a quiet window, got %d", got)
+	}
+}
+
+func TestSlidingWindowLimiter_Concurrent(t *testing.T) {
+	mr, client := newTestRedis(t)
+	mr.SetTime(time.Date(2026, 1, 1, 12, 0, 30, 0, time.UTC))
+	handler := CustomRedisRateLimitMiddleware(client, "login", 10, time.Minute, WithAlgorithm(SlidingWindow))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}),
+	)
+
+	var allowed, rejected atomic.Int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			req := httptest.NewRequest(http.MethodPost, "/users/login", nil)
+			req.RemoteAddr = "203.0.113.7:4000"
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			switch rr.Code {
+			case http.StatusOK:
+				allowed.Add(1)
+			case http.StatusTooManyRequests:
+				rejected.Add(1)
+			default:
+				t.Errorf("unexpected status %d", rr.Code)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if allowed.Load() != 10 || rejected.Load() != 40 {
+		t.Errorf("expected exactly 10 allowed and 40 rejected, got %d and %d", allowed.Load(), rejected.Load())
+	}
+}
+
+func TestSlidingWindowLimiter_ScopesDontShare(t *testing.T) {
+	_, client := newTestRedis(t)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	login := CustomRedisRateLimitMiddleware(client, "login", 1, time.Minute, WithAlgorithm(SlidingWindow))(ok)
+	register := CustomRedisRateLimitMiddleware(client, "register", 1, time.Minute, WithAlgorithm(SlidingWindow))(ok)
+
+	for i, handler := range []http.Handler{login, register, login} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = "203.0.113.7:4000"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		want := http.StatusOK
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if rr.Code != want {
+			t.Errorf("request %d: expected %d, got %d", i+1, want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"user-service/internal/interfaces/http/respond"
+)
+
+type timeoutKey struct{}
+
+// requestTimeout is the deadline one request runs under
+type requestTimeout struct {
+	// client is the request's context before any timeout, so a route's
+	// own timeout can start over from it
+	client context.Context
+	// ctx is the context the handler runs under, with the deadline that
+	// applies
+	ctx context.Context
+}
+
+// Timeout gives handlers d to answer. The request's context gets the
+// deadline, which the service and repositories pass to Postgres and
+// Redis, so a slow query is abandoned rather than waited out. A handler
+// that then fails with a 5xx after the deadline answers 504 Gateway
+// Timeout with the usual error body instead. Applied again closer to a
+// route, the inner timeout replaces the outer one, longer or shorter,
+// rather than nesting inside it, where the shorter would always win.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if outer, ok := r.Context().Value(timeoutKey{}).(*requestTimeout); ok {
+				// Drop the outer deadline but keep the values added since,
+				// and still stop when the client goes away
+				ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), d)
+				defer cancel()
+				stop := context.AfterFunc(outer.client, cancel)
+				defer stop()
+				outer.ctx = ctx
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			state := &requestTimeout{client: r.Context()}
+			ctx = context.WithValue(ctx, timeoutKey{}, state)
+			state.ctx = ctx
+			r = r.WithContext(ctx)
+
+			tw := &timeoutWriter{ResponseWriter: w, r: r, state: state}
+			next.ServeHTTP(tw, r)
+			if !tw.wroteHeader && tw.expired() {
+				tw.WriteHeader(http.StatusGatewayTimeout)
+			}
+		})
+	}
+}
+
+// timeoutWriter swaps a server error written after the deadline for a 504
+type timeoutWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	state       *requestTimeout
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) expired() bool {
+	return errors.Is(tw.state.ctx.Err(), context.DeadlineExceeded)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	if status < http.StatusInternalServerError || !tw.expired() {
+		tw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	tw.timedOut = true
+	// These described the response the handler meant to send
+	for _, header := range []string{"Content-Length", "Content-Encoding", "Content-Disposition", "ETag"} {
+		tw.Header().Del(header)
+	}
+	respond.Error(tw.ResponseWriter, tw.r, "The request took too long to complete; try again", http.StatusGatewayTimeout)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	// The handler's own error body is dropped for the 504's
+	if tw.timedOut {
+		return len(b), nil
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
@@ -0,0 +1,102 @@
+// internal/interfaces/http/middleware/timeout_test.go
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"user-service/internal/interfaces/http/respond"
+)
+
+// slowHandler waits up to delay for its context, then fails with a 500
+// like a handler whose query was cancelled, or answers 200 in time
+func slowHandler(delay time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			respond.JSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		case <-r.Context().Done():
+			w.Header().Set("Content-Length", "64")
+			respond.Error(w, r, "Failed to get user", http.StatusInternalServerError)
+		}
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.Handler
+		want    int
+	}{
+		{"in time", Timeout(time.Second)(slowHandler(0)), http.StatusOK},
+		{"too slow", Timeout(20 * time.Millisecond)(slowHandler(time.Second)), http.StatusGatewayTimeout},
+		{"server error in time", Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			respond.Error(w, r, "Failed", http.StatusInternalServerError)
+		})), http.StatusInternalServerError},
+		{"client error after the deadline", Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			respond.Error(w, r, "Not found", http.StatusNotFound)
+		})), http.StatusNotFound},
+		{"nothing written", Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		})), http.StatusGatewayTimeout},
+		{"route allows longer", Timeout(20 * time.Millisecond)(Timeout(time.Second)(slowHandler(50 * time.Millisecond))), http.StatusOK},
+		{"route allows less", Timeout(time.Second)(Timeout(20 * time.Millisecond)(slowHandler(time.Second))), http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			start := time.Now()
+			tt.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/1", nil))
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body)
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("expected an answer without waiting the handler out, took %v", elapsed)
+			}
+			if tt.want != http.StatusGatewayTimeout {
+				return
+			}
+			var body respond.ErrorBody
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("expected the error envelope alone, got %q: %v", rec.Body, err)
+			}
+			if body.Error.Code != "gateway_timeout" {
+				t.Errorf("expected code gateway_timeout, got %+v", body.Error)
+			}
+			if cl := rec.Header().Get("Content-Length"); cl != "" {
+				t.Errorf("expected the handler's Content-Length dropped, got %q", cl)
+			}
+		})
+	}
+}
+
+func TestTimeout_RouteOverrideStillSeesTheClientLeave(t *testing.T) {
+	stopped := make(chan error, 1)
+	handler := Timeout(10 * time.Millisecond)(Timeout(time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		stopped <- r.Context().Err()
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	ctx, cancel := context.WithCancel(req.Context())
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		cancel()
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+
+	select {
+	case err := <-stopped:
+		if err != context.Canceled {
+			t.Errorf("expected the route's context cancelled with the client's, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the handler stopped when the client left")
+	}
+}
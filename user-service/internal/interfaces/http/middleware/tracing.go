@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"user-service/internal/logging"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer starts the server spans; it's a no-op until tracing is set up
+var tracer = otel.Tracer("user-service/internal/interfaces/http")
+
+// Tracing starts a server span per request, continuing the trace in the
+// caller's W3C traceparent header when there is one. Spans are named by
+// the route routes matches, such as "GET /users/{id}", so they group by
+// endpoint rather than by ID; routes may be nil. The span carries the
+// request ID, so it goes inside RequestID, and the user ID once the
+// request is authenticated.
+func Tracing(routes RouteMatcher) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			name := r.Method
+			attrs := []attribute.KeyValue{
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("client.address", getClientIP(r)),
+				attribute.String("request.id", logging.RequestID(ctx)),
+			}
+			if routes != nil {
+				if _, route := routes.Handler(r); route != "" {
+					// A ServeMux pattern may lead with the method
+					if _, path, ok := strings.Cut(route, " "); ok {
+						route = path
+					}
+					name += " " + route
+					attrs = append(attrs, attribute.String("http.route", route))
+				}
+			}
+
+			ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+			defer span.End()
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			span.SetAttributes(attribute.Int("http.response.status_code", sw.status))
+			if sw.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(sw.status))
+			}
+		})
+	}
+}
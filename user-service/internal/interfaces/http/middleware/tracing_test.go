@@ -0,0 +1,106 @@
+// internal/interfaces/http/middleware/tracing_test.go
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/infrastructure/auth"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	spanExporter    *tracetest.InMemoryExporter
+	installExporter sync.Once
+)
+
+// recordSpans installs a tracer provider keeping spans in memory. otel's
+// global tracers only ever delegate to the first provider set, so it's
+// installed once and emptied per test.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	installExporter.Do(func() {
+		spanExporter = tracetest.NewInMemoryExporter()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	spanExporter.Reset()
+	return spanExporter
+}
+
+func spanAttr(span tracetest.SpanStub, key string) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTracing(t *testing.T) {
+	spans := recordSpans(t)
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	token, _ := jwtManager.GenerateToken(42)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /users/{id}", AuthMiddleware(jwtManager)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})))
+	mux.HandleFunc("GET /boom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	handler := RequestID(Tracing(mux)(mux))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Request-ID", "trace-me")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	ended := spans.GetSpans()
+	if len(ended) != 1 {
+		t.Fatalf("expected one span, got %d", len(ended))
+	}
+	span := ended[0]
+	if span.Name != "GET /users/{id}" {
+		t.Errorf("expected the span named by its route, got %q", span.Name)
+	}
+	if got := span.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected the caller's trace continued, got trace %s", got)
+	}
+	if got := span.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("expected the caller's span as parent, got %s", got)
+	}
+	for key, want := range map[string]attribute.Value{
+		"request.id":                attribute.StringValue("trace-me"),
+		"user.id":                   attribute.Int64Value(42),
+		"http.route":                attribute.StringValue("/users/{id}"),
+		"http.response.status_code": attribute.IntValue(http.StatusNoContent),
+	} {
+		if got, ok := spanAttr(span, key); !ok || got != want {
+			t.Errorf("expected %s %v, got %v", key, want.Emit(), got.Emit())
+		}
+	}
+
+	spans.Reset()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/boom", nil))
+	ended = spans.GetSpans()
+	if len(ended) != 1 || ended[0].Status.Code != codes.Error {
+		t.Fatalf("expected a failed span for a 502, got %+v", ended)
+	}
+	if _, ok := spanAttr(ended[0], "user.id"); ok {
+		t.Error("expected no user ID on an anonymous request")
+	}
+	if ended[0].Parent.IsValid() {
+		t.Error("expected a new trace without a traceparent")
+	}
+}
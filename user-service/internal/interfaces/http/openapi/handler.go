@@ -0,0 +1,76 @@
+package openapi
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// SpecHandler serves doc as JSON. It is encoded once, up front.
+func SpecHandler(doc *Document) http.HandlerFunc {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		panic("openapi: encoding the document: " + err.Error())
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Write(body)
+	}
+}
+
+// swaggerUIVersion pins the swagger-ui-dist release the docs page loads
+const swaggerUIVersion = "5.17.14"
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>User Service API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@{{version}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@{{version}}/swagger-ui-bundle.js"></script>
+  <script>{{script}}</script>
+</body>
+</html>
+`
+
+// docsScript starts Swagger UI. It's kept apart from the page so
+// DocsContentSecurityPolicy can allow exactly this inline script.
+const docsScript = `window.ui = SwaggerUIBundle({ url: "{{spec}}", dom_id: "#swagger-ui" });`
+
+// docsPageScript is docsScript for the document at specPath
+func docsPageScript(specPath string) string {
+	return strings.ReplaceAll(docsScript, "{{spec}}", specPath)
+}
+
+// DocsContentSecurityPolicy is a policy under which the DocsHandler page
+// for specPath works and little else does: Swagger UI from unpkg, its own
+// inline start-up script by hash, and requests back to this service only
+func DocsContentSecurityPolicy(specPath string) string {
+	sum := sha256.Sum256([]byte(docsPageScript(specPath)))
+	return strings.Join([]string{
+		"default-src 'none'",
+		"script-src https://unpkg.com 'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'",
+		// Swagger UI styles some elements inline
+		"style-src https://unpkg.com 'unsafe-inline'",
+		"img-src 'self' data:",
+		"connect-src 'self'",
+		"base-uri 'none'",
+		"form-action 'none'",
+		"frame-ancestors 'none'",
+	}, "; ")
+}
+
+// DocsHandler serves a Swagger UI page browsing the document at specPath
+func DocsHandler(specPath string) http.HandlerFunc {
+	page := strings.NewReplacer("{{version}}", swaggerUIVersion, "{{script}}", docsPageScript(specPath)).Replace(docsPage)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	}
+}
@@ -0,0 +1,287 @@
+package openapi
+
+import (
+	"net/http"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+	userhttp "user-service/internal/interfaces/http/handlers"
+	"user-service/internal/interfaces/http/respond"
+)
+
+// Where the document and its Swagger UI are served
+const (
+	SpecPath = "/openapi.json"
+	DocsPath = "/docs"
+)
+
+// Values standing in for the types of map fields
+var (
+	str       = ""
+	integer   = 0
+	boolean   = false
+	timestamp = respond.Time{}
+	anything  = &Schema{}
+)
+
+func message() object {
+	return object{"message": str}
+}
+
+func query(name, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: "string"}}
+}
+
+// tokens is what login and refresh answer with. The refresh token is only
+// there when refresh tokens are enabled.
+func tokens() object {
+	return object{
+		"access_token":       str,
+		"token":              &Schema{Type: "string", Description: "The same as access_token, for older clients"},
+		"token_type":         &Schema{Type: "string", Enum: []string{"Bearer"}},
+		"issued_at":          timestamp,
+		"expires_at":         timestamp,
+		"expires_in":         &Schema{Type: "integer", Description: "Seconds until the access token expires"},
+		"refresh_token":      str,
+		"refresh_expires_in": integer,
+	}
+}
+
+// preferences is the preferences document: the notification switches,
+// then every display setting in domain.PreferenceSchema
+func preferences() object {
+	doc := object{
+		"transactional":     &Schema{Type: "boolean", Description: "Always true"},
+		"security_critical": &Schema{Type: "boolean", Description: "Always true"},
+		"security_alerts":   boolean,
+		"marketing":         boolean,
+		"product_updates":   boolean,
+	}
+	for key, spec := range domain.PreferenceSchema {
+		doc[key] = preferenceSchema(spec)
+	}
+	return doc
+}
+
+func preferenceSchema(spec domain.PreferenceSpec) *Schema {
+	schema := &Schema{Type: string(spec.Kind), Enum: spec.Values}
+	if spec.Kind == domain.PreferenceObject {
+		schema.Properties = make(map[string]*Schema, len(spec.Fields))
+		for key, field := range spec.Fields {
+			schema.Properties[key] = preferenceSchema(field)
+		}
+	}
+	return schema
+}
+
+// Routes lists every route SetupRoutes can mount. Routes behind config,
+// such as the admin API, are listed whether or not they are mounted.
+func Routes() []Route {
+	login := tokens()
+	login["message"] = str
+	login["user"] = userhttp.UserResponse{}
+	login["password_reset_required"] = boolean
+
+	const (
+		users    = "users"
+		account  = "account"
+		admin    = "admin"
+		internal = "internal"
+		ops      = "operations"
+	)
+	deletionAction := object{"user_id": integer, "reason": str}
+	reason := object{"reason": str}
+	job := object{"job": anything}
+
+	return []Route{
+		// Operations
+		{Method: http.MethodGet, Path: "/livez", Summary: "Liveness probe", Tag: ops,
+			Response: object{"status": str}},
+		{Method: http.MethodGet, Path: "/health", Summary: "Health of the service and its dependencies", Tag: ops,
+			Response: object{
+				"status":    &Schema{Type: "string", Enum: []string{"healthy", "unhealthy"}},
+				"timestamp": timestamp,
+				"services": &Schema{Type: "object", AdditionalProperties: &Schema{Type: "object", Properties: map[string]*Schema{
+					"status":     {Type: "string"},
+					"error":      {Type: "string"},
+					"checked_at": {Type: "string", Format: "date-time"},
+				}}},
+			}},
+		{Method: http.MethodGet, Path: "/metrics", Summary: "Prometheus metrics", Tag: ops,
+			Response: str, ContentType: "text/plain"},
+		{Method: http.MethodGet, Path: SpecPath, Summary: "This document", Tag: ops,
+			Response: anything},
+		{Method: http.MethodGet, Path: DocsPath, Summary: "Swagger UI for this document", Tag: ops,
+			Response: str, ContentType: "text/html"},
+		{Method: http.MethodGet, Path: "/auth/.well-known/jwks.json", Summary: "Public keys access tokens are verified with", Tag: ops,
+			Response: object{"keys": list{anything}}},
+		{Method: http.MethodGet, Path: userhttp.FilesPrefix + "/{key...}", Summary: "Download a stored file", Tag: ops,
+			Auth: AuthSignedURL, Response: str, ContentType: "application/octet-stream"},
+
+		// Signing up and in
+		{Method: http.MethodPost, Path: "/users/register", Summary: "Register", Tag: users, Versioned: true,
+			Request: userhttp.RegisterRequest{}, Status: http.StatusCreated,
+			Response: object{"message": str, "user": userhttp.UserResponse{}, "replayed": boolean}},
+		{Method: http.MethodPost, Path: "/users/register/validate", Summary: "Check a registration without creating the account", Tag: users, Versioned: true,
+			Request: userhttp.RegisterRequest{}, Response: object{"valid": boolean}},
+		{Method: http.MethodPost, Path: "/users/login", Summary: "Log in", Tag: users, Versioned: true,
+			Request: userhttp.LoginRequest{}, Response: login},
+		{Method: http.MethodPost, Path: "/users/recover", Summary: "Log in with a recovery code", Tag: users, Versioned: true,
+			Request:  userhttp.RecoverRequest{},
+			Response: object{"message": str, "token": str, "expires_in_seconds": integer, "recovery_codes_remaining": integer}},
+		{Method: http.MethodPost, Path: "/users/forgot-password", Summary: "Email a password reset token", Tag: users, Versioned: true,
+			Request: userhttp.ForgotPasswordRequest{}, Status: http.StatusAccepted, Response: message()},
+		{Method: http.MethodPost, Path: "/users/reset-password", Summary: "Set a new password with a reset token", Tag: users, Versioned: true,
+			Request: userhttp.ResetPasswordRequest{}, Response: message()},
+		{Method: http.MethodGet, Path: "/users/confirm-email", Summary: "Confirm an email change", Tag: users, Versioned: true,
+			Query:    []Parameter{query("token", "The token from the confirmation link")},
+			Response: message()},
+		{Method: http.MethodPost, Path: "/users/refresh", Summary: "Exchange a refresh token for new tokens", Tag: users, Versioned: true,
+			Request: object{"refresh_token": str}, Response: tokens()},
+		{Method: http.MethodPost, Path: "/users/refresh/revoke", Summary: "Revoke a refresh token", Tag: users, Versioned: true,
+			Request: object{"refresh_token": str}, Status: http.StatusNoContent},
+		{Method: http.MethodPost, Path: "/users/logout", Summary: "Log out of this session", Tag: users, Versioned: true,
+			Auth: AuthBearer, Request: object{"refresh_token": str}, Status: http.StatusNoContent},
+		{Method: http.MethodPost, Path: "/users/logout-all", Summary: "Log out of every session", Tag: users, Versioned: true,
+			Auth: AuthBearer, Status: http.StatusNoContent},
+
+		// The caller's account
+		{Method: http.MethodGet, Path: "/users/me", Summary: "Show the caller's account", Tag: account, Versioned: true,
+			Auth: AuthBearerOrToken, Response: userhttp.AccountResponse{}},
+		{Method: http.MethodPatch, Path: "/users/me", Summary: "Edit the caller's account with a JSON merge patch", Tag: account, Versioned: true,
+			Auth: AuthBearerOrToken, Request: object{"username": str, "first_name": str, "last_name": str},
+			Response: object{"message": str, "changed": list{str}, "user": userhttp.AccountResponse{}}},
+		{Method: http.MethodPut, Path: "/users/update", Summary: "Update the caller's profile", Tag: account, Versioned: true,
+			Auth: AuthBearer, Request: object{"username": str, "first_name": str, "last_name": str},
+			Response: object{"message": str, "changed": list{str}, "user": userhttp.AccountResponse{}}},
+		{Method: http.MethodGet, Path: "/users/me/token", Summary: "Show the claims of the caller's token", Tag: account, Versioned: true,
+			Auth: AuthBearerOrToken, Response: userhttp.TokenResponse{}},
+		{Method: http.MethodGet, Path: "/users/me/preferences", Summary: "Show notification preferences and display settings", Tag: account, Versioned: true,
+			Auth: AuthBearerOrToken, Response: preferences()},
+		{Method: http.MethodPatch, Path: "/users/me/preferences", Summary: "Change preferences with a JSON merge patch", Tag: account, Versioned: true,
+			Auth: AuthBearerOrToken, Request: preferences(), Response: preferences()},
+		{Method: http.MethodPut, Path: "/users/me/password", Summary: "Change password", Tag: account, Versioned: true,
+			Auth: AuthBearer, Request: userhttp.ChangePasswordRequest{}, Response: message()},
+		{Method: http.MethodPost, Path: "/users/me/email", Summary: "Start an email change", Tag: account, Versioned: true,
+			Auth: AuthBearer, Request: userhttp.ChangeEmailRequest{}, Status: http.StatusAccepted, Response: message()},
+		{Method: http.MethodPost, Path: "/users/me/recovery-codes", Summary: "Generate new recovery codes", Tag: account, Versioned: true,
+			Auth: AuthBearer, Status: http.StatusCreated, Response: object{"message": str, "codes": list{str}}},
+		{Method: http.MethodGet, Path: "/users/me/tokens", Summary: "List personal access tokens", Tag: account, Versioned: true,
+			Auth: AuthBearer, Response: object{"tokens": []userhttp.AccessTokenResponse{}}},
+		{Method: http.MethodPost, Path: "/users/me/tokens", Summary: "Create a personal access token", Tag: account, Versioned: true,
+			Auth: AuthBearer, Request: object{"name": str, "scopes": list{str}, "expires_at": timestamp}, Status: http.StatusCreated,
+			Response: object{"message": str, "token": str, "details": userhttp.AccessTokenResponse{}}},
+		{Method: http.MethodDelete, Path: "/users/me/tokens/{id}", Summary: "Revoke a personal access token", Tag: account, Versioned: true,
+			Auth: AuthBearer, Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/users/me/sessions", Summary: "List active sessions", Tag: account, Versioned: true,
+			Auth: AuthBearer, Response: object{"sessions": []userhttp.SessionResponse{}}},
+		{Method: http.MethodDelete, Path: "/users/me/sessions/{id}", Summary: "Sign a session out", Tag: account, Versioned: true,
+			Auth: AuthBearer, Response: object{"message": str, "id": str}},
+		{Method: http.MethodPost, Path: "/users/me/notices/{code}/dismiss", Summary: "Dismiss a notice", Tag: account, Versioned: true,
+			Auth: AuthBearer, Status: http.StatusNoContent},
+		{Method: http.MethodDelete, Path: "/users/delete", Summary: "Schedule the caller's account for deletion", Tag: account, Versioned: true,
+			Auth: AuthBearer, Request: userhttp.DeleteUserRequest{}, Status: http.StatusAccepted,
+			Response: object{"message": str, "user_id": integer}},
+
+		// Other users
+		{Method: http.MethodGet, Path: "/users", Summary: "List users (admins)", Tag: users, Versioned: true,
+			Auth: AuthBearerOrToken,
+			Query: []Parameter{
+				query("page", "1-based page number"),
+				query("page_size", "Users per page"),
+				query("q", "Matches username or email"),
+				query("sort", "Field to sort by"),
+				query("order", "asc or desc"),
+				query("created_after", "RFC 3339 timestamp"),
+				query("created_before", "RFC 3339 timestamp"),
+				query("include_deleted", "true to include soft-deleted users"),
+			},
+			Response: respond.Paginated[userhttp.AccountResponse]{}},
+		{Method: http.MethodGet, Path: "/users/{id}", Summary: "Show a user (admins)", Tag: users, Versioned: true,
+			Auth: AuthBearerOrToken, Response: userhttp.AccountResponse{}},
+		{Method: http.MethodHead, Path: "/users/{id}", Summary: "Check that a user exists", Tag: users, Versioned: true,
+			Auth: AuthBearerOrToken},
+
+		// Other services
+		{Method: http.MethodGet, Path: "/internal/users/by-email", Summary: "Look up an account by email", Tag: internal,
+			Auth: AuthInternalKey, Query: []Parameter{query("email", "")},
+			Response: object{"exists": boolean, "user_id": integer, "email_verified": boolean}},
+
+		// Admin
+		{Method: http.MethodGet, Path: "/admin/users", Summary: "List accounts in any state", Tag: admin,
+			Auth: AuthAdminKey,
+			Query: []Parameter{
+				query("page", "1-based page number"),
+				query("page_size", "Users per page"),
+				query("status", "Comma-separated states"),
+				query("include_deleted", ""),
+			},
+			Response: respond.Paginated[userhttp.AccountResponse]{}},
+		{Method: http.MethodGet, Path: "/admin/deletions", Summary: "List pending deletions", Tag: admin,
+			Auth:     AuthAdminKey,
+			Query:    []Parameter{query("page", "1-based page number"), query("page_size", "Accounts per page")},
+			Response: respond.Paginated[userhttp.PendingDeletionResponse]{}},
+		{Method: http.MethodPost, Path: "/admin/deletions/cancel", Summary: "Cancel a deletion", Tag: admin,
+			Auth: AuthAdminKey, Request: deletionAction, Response: object{"message": str, "user_id": integer}},
+		{Method: http.MethodPost, Path: "/admin/deletions/expedite", Summary: "Erase an account now", Tag: admin,
+			Auth: AuthAdminKey, Request: deletionAction, Response: object{"message": str, "user_id": integer}},
+		{Method: http.MethodGet, Path: "/admin/stats/activity", Summary: "Signups and logins over time", Tag: admin,
+			Auth: AuthAdminKey, Query: []Parameter{query("from", ""), query("to", ""), query("granularity", "")},
+			Response: userhttp.ActivityResponse{}},
+		{Method: http.MethodPost, Path: "/admin/invites", Summary: "Mint an invite code", Tag: admin,
+			Auth: AuthAdminKey, Request: object{"max_uses": integer, "expires_at": timestamp}, Status: http.StatusCreated,
+			Response: object{"invite": userhttp.InviteResponse{}}},
+		{Method: http.MethodPost, Path: "/admin/invites/revoke", Summary: "Revoke an invite code", Tag: admin,
+			Auth: AuthAdminKey, Request: object{"code": str, "reason": str}, Response: object{"message": str, "code": str}},
+		{Method: http.MethodGet, Path: "/admin/jobs", Summary: "List scheduled jobs", Tag: admin,
+			Auth: AuthAdminKey, Response: object{"jobs": list{anything}}},
+		{Method: http.MethodPost, Path: "/admin/jobs/{name}/run", Summary: "Run a scheduled job now", Tag: admin,
+			Auth: AuthAdminKey, Response: job},
+		{Method: http.MethodGet, Path: "/admin/jobs/{id}", Summary: "Follow a bulk job", Tag: admin,
+			Auth: AuthAdminKey, Response: job},
+		{Method: http.MethodGet, Path: "/admin/overview", Summary: "Dashboard overview", Tag: admin,
+			Auth: AuthAdminKey, Response: &Schema{Type: "object", AdditionalProperties: anything}},
+		{Method: http.MethodPost, Path: "/admin/users/{id}/force-password-reset", Summary: "Make a user choose a new password", Tag: admin,
+			Auth: AuthAdminKey, Request: reason, Response: object{"message": str, "user_id": integer}},
+		{Method: http.MethodPost, Path: "/admin/users/force-password-reset", Summary: "Make several users choose a new password", Tag: admin,
+			Auth: AuthAdminKey, Request: object{"user_ids": list{integer}, "reason": str},
+			Response: object{"reset": list{integer}, "not_found": list{integer}}},
+		{Method: http.MethodGet, Path: "/admin/users/{id}/restore", Summary: "Show an account, deleted or not", Tag: admin,
+			Auth: AuthAdminKey, Response: userhttp.AccountResponse{}},
+		{Method: http.MethodPost, Path: "/admin/users/{id}/restore", Summary: "Restore a soft-deleted account", Tag: admin,
+			Auth: AuthAdminKey, Request: reason, Response: object{"message": str, "user": userhttp.AccountResponse{}}},
+		{Method: http.MethodDelete, Path: "/admin/users/{id}", Summary: "Purge an account past its retention period", Tag: admin,
+			Auth: AuthAdminKey, Query: []Parameter{query("confirm", "The account's email")},
+			Response: object{"message": str, "user_id": integer}},
+		{Method: http.MethodPost, Path: "/admin/users/bulk", Summary: "Start a bulk job", Tag: admin,
+			Auth: AuthAdminKey, Request: object{"action": str, "user_ids": list{integer}, "reason": str},
+			Status: http.StatusAccepted, Response: job},
+		{Method: http.MethodPost, Path: "/admin/users/{id}/notices", Summary: "Show a user a notice", Tag: admin,
+			Auth:    AuthAdminKey,
+			Request: object{"code": str, "severity": str, "message": str, "dismissible": boolean, "expires_at": timestamp},
+			Status:  http.StatusCreated, Response: object{"notice": anything}},
+		{Method: http.MethodDelete, Path: "/admin/users/{id}/notices/{code}", Summary: "Remove a user's notice", Tag: admin,
+			Auth: AuthAdminKey, Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: userhttp.EndpointSwitchPath, Summary: "List disabled endpoints", Tag: admin,
+			Auth: AuthAdminKey, Response: object{"disabled": anything}},
+		{Method: http.MethodPost, Path: userhttp.EndpointSwitchPath, Summary: "Disable an endpoint", Tag: admin,
+			Auth: AuthAdminKey, Request: object{"pattern": str, "message": str, "ttl_seconds": integer},
+			Response: object{"pattern": str, "disabled": anything}},
+		{Method: http.MethodDelete, Path: userhttp.EndpointSwitchPath, Summary: "Enable a disabled endpoint", Tag: admin,
+			Auth: AuthAdminKey, Query: []Parameter{query("pattern", "")}, Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/admin/users/{id}/snapshot", Summary: "Export a signed snapshot of an account", Tag: admin,
+			Auth: AuthAdminKey, Response: application.SignedSnapshot{}},
+		{Method: http.MethodPost, Path: "/admin/users/import-snapshot", Summary: "Import a signed snapshot", Tag: admin,
+			Auth: AuthAdminKey, Request: object{"snapshot": anything, "signature": str, "overwrite": boolean},
+			Response: object{"message": str, "user_id": integer, "created": boolean, "applied": list{str}}},
+	}
+}
+
+// Spec is the document for Routes
+func Spec() *Document {
+	return Build(Info{
+		Title:       "User Service API",
+		Description: "Accounts, authentication and their administration. Errors share one envelope, described by the Error response.",
+		Version:     "1",
+	}, Routes())
+}
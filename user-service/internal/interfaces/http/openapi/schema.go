@@ -0,0 +1,174 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"user-service/internal/interfaces/http/respond"
+)
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	respondTimeType = reflect.TypeOf(respond.Time{})
+	marshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+	// packagePath matches the import paths in a generic type's name
+	packagePath = regexp.MustCompile(`[\w\-./]+\.`)
+)
+
+// schemas derives schemas from Go types the way encoding/json serializes
+// them. Named structs become components, referenced wherever they appear.
+type schemas struct {
+	components map[string]*Schema
+	types      map[string]reflect.Type
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: make(map[string]*Schema),
+		types:      make(map[string]reflect.Type),
+	}
+}
+
+// object describes a JSON object written from a map, each property's
+// schema taken from its value as by schemas.of
+type object map[string]interface{}
+
+// list describes an array of schemas.of(item)
+type list struct{ item interface{} }
+
+// of is the schema of v's type, or v itself when it is already a *Schema
+func (s *schemas) of(v interface{}) *Schema {
+	switch v := v.(type) {
+	case *Schema:
+		return v
+	case object:
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema, len(v))}
+		for name, prop := range v {
+			schema.Properties[name] = s.of(prop)
+		}
+		return schema
+	case list:
+		return &Schema{Type: "array", Items: s.of(v.item)}
+	}
+	return s.forType(reflect.TypeOf(v))
+}
+
+func (s *schemas) forType(t reflect.Type) *Schema {
+	switch t {
+	case timeType, respondTimeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t.Kind() == reflect.Pointer {
+		schema := s.forType(t.Elem())
+		if schema.Ref != "" {
+			// 3.0 ignores siblings of $ref
+			return schema
+		}
+		nullable := *schema
+		nullable.Nullable = true
+		return &nullable
+	}
+	// Whatever a custom marshaller writes is beyond reflection
+	if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.forType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.forType(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		return s.component(t)
+	}
+	return &Schema{}
+}
+
+// component registers the named struct t once, returning a reference to it
+func (s *schemas) component(t reflect.Type) *Schema {
+	name := componentName(t)
+	if known, ok := s.types[name]; ok && known != t {
+		// Same name from another package
+		pkg := packageName(t)
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+	if _, ok := s.types[name]; ok {
+		return ref
+	}
+	// Registered before its fields, so a type can refer to itself
+	s.types[name] = t
+	s.components[name] = &Schema{}
+	*s.components[name] = *s.structSchema(t)
+	return ref
+}
+
+// structSchema lists t's fields under their JSON names. A field is required
+// when its validate tag says so; embedded structs are flattened.
+func (s *schemas) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() && !field.Anonymous {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				inner := s.structSchema(embedded)
+				for prop, propSchema := range inner.Properties {
+					schema.Properties[prop] = propSchema
+				}
+				schema.Required = append(schema.Required, inner.Required...)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = s.forType(field.Type)
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			if rule == "required" {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+	return schema
+}
+
+// componentName is t's name without its package, generic arguments
+// included: Paginated[handlers.AccountResponse] is PaginatedAccountResponse
+func componentName(t reflect.Type) string {
+	name := packagePath.ReplaceAllString(t.Name(), "")
+	return strings.NewReplacer("[", "", "]", "", ",", "").Replace(name)
+}
+
+func packageName(t reflect.Type) string {
+	path := t.PkgPath()
+	return path[strings.LastIndex(path, "/")+1:]
+}
@@ -0,0 +1,159 @@
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"user-service/internal/interfaces/http/respond"
+	"user-service/internal/interfaces/http/router"
+)
+
+// Auth is how a route authenticates its caller
+type Auth int
+
+const (
+	AuthNone Auth = iota
+	// AuthBearer takes a session's access token
+	AuthBearer
+	// AuthBearerOrToken also takes a personal access token
+	AuthBearerOrToken
+	// AuthAdminKey and AuthInternalKey take an X-API-Key from their list
+	AuthAdminKey
+	AuthInternalKey
+	// AuthSignedURL takes the signature in a link the service issued
+	AuthSignedURL
+)
+
+// Route documents one method of one registered pattern
+type Route struct {
+	Method  string
+	Path    string
+	Summary string
+	Tag     string
+	Auth    Auth
+	// Versioned routes are documented under router.VersionPrefix, and at
+	// their legacy path as deprecated
+	Versioned bool
+	Query     []Parameter
+	// Request and Response are values whose type is the JSON body, or a
+	// *Schema. A nil Request means no body.
+	Request interface{}
+	// Status is the success status, 200 if zero
+	Status   int
+	Response interface{}
+	// ContentType overrides application/json for the response
+	ContentType string
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)(\.\.\.)?\}`)
+
+// Build assembles the document for routes
+func Build(info Info, routes []Route) *Document {
+	s := newSchemas()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Responses: map[string]*Response{
+				"Error": {
+					Description: "The request failed. error.code says why, and is stable for clients to switch on.",
+					Content:     jsonContent(s.of(respond.ErrorBody{})),
+				},
+			},
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearer": {
+					Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+					Description: "An access token from login, or a personal access token where the route allows one",
+				},
+				"adminKey":    {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "An admin API key"},
+				"internalKey": {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "An internal service's API key"},
+				"signedURL":   {Type: "apiKey", In: "query", Name: "signature", Description: "The signature of a link the service issued"},
+			},
+		},
+	}
+
+	for _, route := range routes {
+		op := s.operation(route)
+		if !route.Versioned {
+			doc.add(Path(route.Path), route.Method, op)
+			continue
+		}
+		doc.add(Path(router.VersionPrefix+route.Path), route.Method, op)
+		legacy := *op
+		legacy.Deprecated = true
+		doc.add(Path(route.Path), route.Method, &legacy)
+	}
+	doc.Components.Schemas = s.components
+	return doc
+}
+
+// Path is a ServeMux pattern's path as OpenAPI writes it: {key...} is
+// {key}
+func Path(pattern string) string {
+	return pathParam.ReplaceAllString(pattern, "{$1}")
+}
+
+func (d *Document) add(path, method string, op *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+func (s *schemas) operation(route Route) *Operation {
+	op := &Operation{
+		Summary:    route.Summary,
+		Tags:       []string{route.Tag},
+		Security:   security(route.Auth),
+		Parameters: append([]Parameter(nil), route.Query...),
+		Responses: map[string]*Response{
+			"default": {Ref: "#/components/responses/Error"},
+		},
+	}
+	for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"},
+		})
+	}
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{Required: true, Content: jsonContent(s.of(route.Request))}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := &Response{Description: http.StatusText(status)}
+	switch {
+	case route.Response == nil:
+	case route.ContentType != "":
+		resp.Content = map[string]MediaType{route.ContentType: {Schema: s.of(route.Response)}}
+	default:
+		resp.Content = jsonContent(s.of(route.Response))
+	}
+	op.Responses[strconv.Itoa(status)] = resp
+	return op
+}
+
+func security(auth Auth) []map[string][]string {
+	scheme := map[Auth]string{
+		AuthBearer:        "bearer",
+		AuthBearerOrToken: "bearer",
+		AuthAdminKey:      "adminKey",
+		AuthInternalKey:   "internalKey",
+		AuthSignedURL:     "signedURL",
+	}[auth]
+	if scheme == "" {
+		return nil
+	}
+	return []map[string][]string{{scheme: {}}}
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
@@ -0,0 +1,140 @@
+// internal/interfaces/http/openapi/spec_test.go
+package openapi
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"user-service/internal/interfaces/http/router"
+)
+
+func TestSpec_VersionedRoutesKeepADeprecatedAlias(t *testing.T) {
+	doc := Spec()
+
+	current := doc.Paths["/v1/users/login"]["post"]
+	legacy := doc.Paths["/users/login"]["post"]
+	if current == nil || legacy == nil {
+		t.Fatalf("expected login at both paths, got %v and %v", current, legacy)
+	}
+	if current.Deprecated || !legacy.Deprecated {
+		t.Errorf("expected only the unversioned login deprecated, got %v and %v", current.Deprecated, legacy.Deprecated)
+	}
+	if _, ok := doc.Paths["/v1/health"]; ok {
+		t.Error("expected /health unversioned")
+	}
+}
+
+func TestSpec_Schemas(t *testing.T) {
+	doc := Spec()
+
+	register := doc.Components.Schemas["RegisterRequest"]
+	if register == nil {
+		t.Fatalf("expected RegisterRequest as a component, got %v", doc.Components.Schemas)
+	}
+	required := append([]string(nil), register.Required...)
+	sort.Strings(required)
+	if want := []string{"email", "password", "username"}; !reflect.DeepEqual(required, want) {
+		t.Errorf("expected %v required, got %v", want, required)
+	}
+	if _, ok := register.Properties["invite_code"]; !ok {
+		t.Errorf("expected properties under their JSON names, got %v", register.Properties)
+	}
+
+	body := doc.Paths["/v1/users/register"]["post"].RequestBody.Content["application/json"].Schema
+	if body.Ref != "#/components/schemas/RegisterRequest" {
+		t.Errorf("expected the request to refer to the component, got %+v", body)
+	}
+	if _, ok := doc.Components.Schemas["PaginatedAccountResponse"]; !ok {
+		t.Error("expected the generic listing named without its package paths")
+	}
+}
+
+func TestSpec_ErrorsAndAuth(t *testing.T) {
+	doc := Spec()
+
+	detail := doc.Components.Schemas["ErrorDetail"]
+	if detail == nil || detail.Properties["code"] == nil || detail.Properties["message"] == nil {
+		t.Fatalf("expected the error envelope described, got %+v", detail)
+	}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			if op.Responses["default"] == nil || op.Responses["default"].Ref != "#/components/responses/Error" {
+				t.Errorf("%s %s: expected errors to use the envelope", method, path)
+			}
+		}
+	}
+
+	tests := []struct {
+		path, method string
+		want         []map[string][]string
+	}{
+		{"/v1/users/me", "get", []map[string][]string{{"bearer": {}}}},
+		{"/admin/users", "get", []map[string][]string{{"adminKey": {}}}},
+		{"/v1/users/register", "post", nil},
+	}
+	for _, tt := range tests {
+		if got := doc.Paths[tt.path][tt.method].Security; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %s: expected security %v, got %v", tt.method, tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	for pattern, want := range map[string]string{
+		"/users/{id}":     "/users/{id}",
+		"/files/{key...}": "/files/{key}",
+		"/admin/users":    "/admin/users",
+	} {
+		if got := Path(pattern); got != want {
+			t.Errorf("%s: expected %s, got %s", pattern, want, got)
+		}
+	}
+}
+
+func TestSpecHandler(t *testing.T) {
+	handler := SpecHandler(Spec())
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, SpecPath, nil))
+	var doc Document
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil || doc.OpenAPI != Version {
+		t.Errorf("expected an OpenAPI %s document, got %v: %s", Version, err, rec.Body.String())
+	}
+
+	rt := router.New()
+	rt.Group().HandleFunc("GET "+SpecPath, handler)
+	rec = httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, SpecPath, nil))
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("expected POST refused, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestDocsContentSecurityPolicy_AllowsThePageScript(t *testing.T) {
+	rec := httptest.NewRecorder()
+	DocsHandler(SpecPath)(rec, httptest.NewRequest(http.MethodGet, DocsPath, nil))
+
+	// The hash in the policy is of exactly what sits between the tags
+	_, rest, _ := strings.Cut(rec.Body.String(), "<script>")
+	script, _, _ := strings.Cut(rest, "</script>")
+	sum := sha256.Sum256([]byte(script))
+	hash := "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+
+	policy := DocsContentSecurityPolicy(SpecPath)
+	if !strings.Contains(policy, hash) {
+		t.Errorf("expected the policy to allow the page's script %s, got %q", hash, policy)
+	}
+	if !strings.Contains(script, SpecPath) {
+		t.Errorf("expected the script to load %s, got %q", SpecPath, script)
+	}
+	if DocsContentSecurityPolicy("/other.json") == policy {
+		t.Error("expected a page for another document to need another hash")
+	}
+}
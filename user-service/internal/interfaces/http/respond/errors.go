@@ -0,0 +1,150 @@
+package respond
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Error codes clients can switch on. Handlers name the failure with one
+// of these, or a more specific code of their own; Error falls back to the
+// code for the status.
+const (
+	CodeBadRequest         = "bad_request"
+	CodeInvalidJSON        = "invalid_json"
+	CodeUnknownField       = "unknown_field"
+	CodeValidationFailed   = "validation_failed"
+	CodeAlreadyInUse       = "already_in_use"
+	CodeEmailTaken         = "email_taken"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeConflict           = "conflict"
+	CodeRateLimitExceeded  = "rate_limit_exceeded"
+	CodeInternal           = "internal_error"
+	CodeUnavailable        = "unavailable"
+)
+
+// statusCodes names the failure when the handler didn't
+var statusCodes = map[int]string{
+	http.StatusBadRequest:          CodeBadRequest,
+	http.StatusUnauthorized:        CodeUnauthorized,
+	http.StatusForbidden:           CodeForbidden,
+	http.StatusNotFound:            CodeNotFound,
+	http.StatusMethodNotAllowed:    CodeMethodNotAllowed,
+	http.StatusConflict:            CodeConflict,
+	http.StatusTooManyRequests:     CodeRateLimitExceeded,
+	http.StatusInternalServerError: CodeInternal,
+	http.StatusServiceUnavailable:  CodeUnavailable,
+}
+
+// ErrorBody is the JSON envelope of an error response
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail says what went wrong. Code is stable; Message is for people
+// and may change. Fields maps request fields to what is wrong with each,
+// and Details carries anything else the client needs to recover, such as
+// when to retry.
+type ErrorDetail struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Fields  map[string]string      `json:"fields,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// LegacyErrorObserver is told about each error answered in the legacy
+// text/plain format, so we know when clients have stopped needing it
+type LegacyErrorObserver interface {
+	ObserveLegacyError(status int)
+}
+
+type errorCompatKey struct{}
+
+type errorCompat struct {
+	observer LegacyErrorObserver
+}
+
+// ErrorCompat keeps the plain-text error bodies that predate ErrorBody for
+// clients that don't send Accept: application/json. Without it every
+// client gets the envelope. observer may be nil.
+func ErrorCompat(observer LegacyErrorObserver) func(http.Handler) http.Handler {
+	compat := &errorCompat{observer: observer}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), errorCompatKey{}, compat)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Error writes message as an ErrorBody with the given status, coded by
+// the status alone. The arguments follow http.Error, which it replaces.
+func Error(w http.ResponseWriter, r *http.Request, message string, status int) {
+	WriteError(w, r, status, StatusCode(status), message)
+}
+
+// WriteError writes an ErrorBody with the given status and code, and the
+// per-field errors in fields if any. When ErrorCompat applies to r, the
+// client gets the legacy text/plain message instead.
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code, message string, fields ...map[string]string) {
+	detail := ErrorDetail{Code: code, Message: message}
+	for _, f := range fields {
+		for field, msg := range f {
+			if detail.Fields == nil {
+				detail.Fields = make(map[string]string)
+			}
+			detail.Fields[field] = msg
+		}
+	}
+	writeErrorDetail(w, r, status, detail)
+}
+
+// WriteErrorDetails is WriteError for failures that come with data the
+// client acts on, sent as the envelope's details
+func WriteErrorDetails(w http.ResponseWriter, r *http.Request, status int, code, message string, details map[string]interface{}) {
+	writeErrorDetail(w, r, status, ErrorDetail{Code: code, Message: message, Details: details})
+}
+
+func writeErrorDetail(w http.ResponseWriter, r *http.Request, status int, detail ErrorDetail) {
+	if compat, ok := r.Context().Value(errorCompatKey{}).(*errorCompat); ok && !acceptsJSON(r) {
+		if compat.observer != nil {
+			compat.observer.ObserveLegacyError(status)
+		}
+		http.Error(w, detail.Message, status)
+		return
+	}
+	JSON(w, status, ErrorBody{Error: detail})
+}
+
+// StatusCode is the error code for status when nothing more specific
+// applies: the status text in snake case for statuses without one of
+// their own
+func StatusCode(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		return CodeInternal
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+// acceptsJSON reports whether r's Accept header names application/json.
+// Wildcards don't count: older clients send */* and expect plain text.
+func acceptsJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(part)
+			if err == nil && mediaType == "application/json" {
+				return true
+			}
+		}
+	}
+	return false
+}
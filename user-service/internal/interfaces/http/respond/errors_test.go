@@ -0,0 +1,74 @@
+// internal/interfaces/http/respond/errors_test.go
+package respond
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type legacyCounter struct {
+	statuses []int
+}
+
+func (c *legacyCounter) ObserveLegacyError(status int) {
+	c.statuses = append(c.statuses, status)
+}
+
+func TestError_NegotiatesFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		compat     bool
+		accept     string
+		wantLegacy bool
+	}{
+		{"envelope for JSON clients", false, "application/json", false},
+		{"envelope for everyone without compat", false, "", false},
+		{"compat keeps JSON for JSON clients", true, "application/json", false},
+		{"compat finds JSON among other types", true, "text/html, application/json;q=0.9", false},
+		{"compat serves text to clients without Accept", true, "", true},
+		{"compat treats wildcards as text", true, "*/*", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter := &legacyCounter{}
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Error(w, r, "User not found", http.StatusNotFound)
+			})
+			if tt.compat {
+				handler = ErrorCompat(counter)(handler)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("expected 404, got %d", rec.Code)
+			}
+			contentType := rec.Header().Get("Content-Type")
+			if tt.wantLegacy {
+				if !strings.HasPrefix(contentType, "text/plain") || rec.Body.String() != "User not found\n" {
+					t.Errorf("expected the legacy body, got %q (%s)", rec.Body, contentType)
+				}
+				if len(counter.statuses) != 1 || counter.statuses[0] != http.StatusNotFound {
+					t.Errorf("expected the legacy response counted, got %v", counter.statuses)
+				}
+				return
+			}
+
+			var body ErrorBody
+			if contentType != "application/json" || json.Unmarshal(rec.Body.Bytes(), &body) != nil || body.Error.Message != "User not found" || body.Error.Code != CodeNotFound {
+				t.Errorf("expected the JSON envelope, got %q (%s)", rec.Body, contentType)
+			}
+			if len(counter.statuses) != 0 {
+				t.Errorf("expected no legacy responses counted, got %v", counter.statuses)
+			}
+		})
+	}
+}
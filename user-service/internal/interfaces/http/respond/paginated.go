@@ -0,0 +1,52 @@
+package respond
+
+// Paginated is the envelope for page-numbered listings. Build it with
+// NewPaginated so the derived fields always agree with total.
+type Paginated[T any] struct {
+	Items      []T   `json:"items"`
+	Total      int64 `json:"total"`
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	TotalPages int64 `json:"total_pages"`
+	HasNext    bool  `json:"has_next"`
+	HasPrev    bool  `json:"has_prev"`
+}
+
+// NewPaginated wraps one page of items. page is 1-based and pageSize must
+// be positive; total counts items across every page.
+func NewPaginated[T any](items []T, page, pageSize int, total int64) Paginated[T] {
+	if items == nil {
+		items = []T{}
+	}
+	totalPages := (total + int64(pageSize) - 1) / int64(pageSize)
+	return Paginated[T]{
+		Items:      items,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+		HasNext:    int64(page) < totalPages,
+		HasPrev:    page > 1,
+	}
+}
+
+// CursorPaginated is the envelope for keyset listings, which can't know
+// their total. NextCursor is opaque to clients and empty on the last page.
+type CursorPaginated[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// NewCursorPaginated wraps one page of items; pass an empty nextCursor on
+// the last page
+func NewCursorPaginated[T any](items []T, nextCursor string) CursorPaginated[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return CursorPaginated[T]{
+		Items:      items,
+		NextCursor: nextCursor,
+		HasMore:    nextCursor != "",
+	}
+}
@@ -0,0 +1,67 @@
+// internal/interfaces/http/respond/paginated_test.go
+package respond
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	ID int `json:"id"`
+}
+
+// The wire format of every listing is pinned here, once
+func TestPaginated_WireFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload interface{}
+		want    string
+	}{
+		{
+			name:    "first of several pages",
+			payload: NewPaginated([]item{{1}, {2}}, 1, 2, 5),
+			want:    `{"items":[{"id":1},{"id":2}],"total":5,"page":1,"page_size":2,"total_pages":3,"has_next":true,"has_prev":false}`,
+		},
+		{
+			name:    "middle page",
+			payload: NewPaginated([]item{{3}, {4}}, 2, 2, 5),
+			want:    `{"items":[{"id":3},{"id":4}],"total":5,"page":2,"page_size":2,"total_pages":3,"has_next":true,"has_prev":true}`,
+		},
+		{
+			name:    "last page",
+			payload: NewPaginated([]item{{5}}, 3, 2, 5),
+			want:    `{"items":[{"id":5}],"total":5,"page":3,"page_size":2,"total_pages":3,"has_next":false,"has_prev":true}`,
+		},
+		{
+			name:    "empty listing",
+			payload: NewPaginated[item](nil, 1, 10, 0),
+			want:    `{"items":[],"total":0,"page":1,"page_size":10,"total_pages":0,"has_next":false,"has_prev":false}`,
+		},
+		{
+			name:    "page past the end",
+			payload: NewPaginated([]item{}, 4, 2, 5),
+			want:    `{"items":[],"total":5,"page":4,"page_size":2,"total_pages":3,"has_next":false,"has_prev":true}`,
+		},
+		{
+			name:    "cursor page with more",
+			payload: NewCursorPaginated([]item{{1}, {2}}, "Mg"),
+			want:    `{"items":[{"id":1},{"id":2}],"next_cursor":"Mg","has_more":true}`,
+		},
+		{
+			name:    "last cursor page",
+			payload: NewCursorPaginated[item](nil, ""),
+			want:    `{"items":[],"next_cursor":"","has_more":false}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			JSON(rec, http.StatusOK, tt.payload)
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+				t.Errorf("wire format changed\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Package respond writes JSON responses the same way for every handler and
+// middleware.
+package respond
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// RequestIDHeader is set on every response by middleware.RequestID; JSON
+// reads it back to tag its log lines
+const RequestIDHeader = "X-Request-ID"
+
+// internalErrorBody is sent when the real payload can't be encoded
+var internalErrorBody = []byte(`{"error":{"code":"internal_error","message":"Internal server error"}}` + "\n")
+
+// JSON writes payload with the given status. The payload is encoded into a
+// buffer first, so a value that can't be marshalled becomes a clean 500
+// rather than a truncated body behind the intended status. Content-Type
+// is always set before the status is written.
+func JSON(w http.ResponseWriter, status int, payload interface{}) {
+	body, ok := encode(w, status, payload)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// A write error means the client went away; there's no one to tell
+	w.Write(body)
+}
+
+// JSONWithETag is JSON for a representation clients poll, such as the
+// caller's own profile. It adds an ETag of the body and an explicit
+// Content-Length, so a HEAD gets exactly the headers the GET would, and
+// answers 304 Not Modified to an If-None-Match that still matches.
+func JSONWithETag(w http.ResponseWriter, r *http.Request, status int, payload interface{}) {
+	body, ok := encode(w, status, payload)
+	if !ok {
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if status == http.StatusOK && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	// net/http drops the body of a HEAD response
+	w.Write(body)
+}
+
+// encode marshals payload, writing a 500 and reporting false when it can't
+func encode(w http.ResponseWriter, status int, payload interface{}) ([]byte, bool) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(payload); err != nil {
+		log.Printf("Failed to encode %d response (request_id=%s): %v",
+			status, w.Header().Get(RequestIDHeader), err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(internalErrorBody)
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
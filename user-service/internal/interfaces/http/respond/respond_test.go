@@ -0,0 +1,90 @@
+// internal/interfaces/http/respond/respond_test.go
+package respond
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSON_WritesStatusHeaderAndBody(t *testing.T) {
+	rec := httptest.NewRecorder()
+	JSON(rec, http.StatusCreated, map[string]string{"message": "ok"})
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected 201, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got %q", ct)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body["message"] != "ok" {
+		t.Errorf("unexpected body %q (%v)", rec.Body, err)
+	}
+}
+
+func TestJSON_UnencodablePayloadBecomesA500(t *testing.T) {
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set(RequestIDHeader, "req-123")
+	payload := struct {
+		Name    string   `json:"name"`
+		Updates chan int `json:"updates"`
+	}{Name: "alice", Updates: make(chan int)}
+
+	JSON(rec, http.StatusOK, payload)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got %q", ct)
+	}
+	var body ErrorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Code != CodeInternal || body.Error.Message == "" {
+		t.Errorf("expected a complete JSON error body, got %q (%v)", rec.Body, err)
+	}
+	if strings.Contains(rec.Body.String(), "alice") {
+		t.Errorf("the partially encoded payload leaked into the body: %q", rec.Body)
+	}
+	if !strings.Contains(logs.String(), "request_id=req-123") {
+		t.Errorf("expected the failure to be logged with the request ID, got %q", logs.String())
+	}
+}
+
+func TestJSONWithETag_HeadMatchesGetAndRevalidates(t *testing.T) {
+	payload := map[string]string{"message": "ok"}
+	serve := func(method, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/users/me", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		JSONWithETag(rec, req, http.StatusOK, payload)
+		return rec
+	}
+
+	get, head := serve(http.MethodGet, ""), serve(http.MethodHead, "")
+	etag := get.Header().Get("ETag")
+	if etag == "" || head.Header().Get("ETag") != etag {
+		t.Errorf("expected matching ETags, got %q and %q", etag, head.Header().Get("ETag"))
+	}
+	if length := get.Header().Get("Content-Length"); length != fmt.Sprint(get.Body.Len()) || head.Header().Get("Content-Length") != length {
+		t.Errorf("expected matching Content-Length %q, got %q", length, head.Header().Get("Content-Length"))
+	}
+
+	if rec := serve(http.MethodGet, etag); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("expected 304 with no body, got %d: %q", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodGet, `"stale"`); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for a stale ETag, got %d", rec.Code)
+	}
+}
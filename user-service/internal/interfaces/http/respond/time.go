@@ -0,0 +1,70 @@
+package respond
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeFormat is how every timestamp goes out: RFC 3339 in UTC with
+// millisecond precision, e.g. 2024-01-01T12:00:00.000Z
+const TimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// Time is a timestamp in a response DTO. It marshals as TimeFormat in UTC
+// whatever zone it was loaded or computed in, so the server's local zone
+// never reaches clients.
+type Time time.Time
+
+// NewTime wraps t
+func NewTime(t time.Time) Time {
+	return Time(t)
+}
+
+// NewTimePtr wraps t, keeping nil as nil so optional timestamps stay null
+func NewTimePtr(t *time.Time) *Time {
+	if t == nil {
+		return nil
+	}
+	wrapped := Time(*t)
+	return &wrapped
+}
+
+// Time returns the wrapped value in UTC
+func (t Time) Time() time.Time {
+	return time.Time(t).UTC()
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Time().Format(TimeFormat))
+}
+
+// UnmarshalJSON accepts RFC 3339 with either a Z or a numeric offset and
+// any fractional precision, normalizing to UTC
+func (t *Time) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("timestamp must be a string: %w", err)
+	}
+	parsed, err := ParseTime(value)
+	if err != nil {
+		return err
+	}
+	*t = Time(parsed)
+	return nil
+}
+
+// ParseTime parses an RFC 3339 timestamp with a Z or a numeric offset into
+// UTC. In a query string an unescaped "+07:00" offset arrives as " 07:00",
+// so a space before the offset is read as the plus it stood for.
+func ParseTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if i := strings.LastIndexByte(value, ' '); i > 0 {
+		value = value[:i] + "+" + value[i+1:]
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp must be RFC 3339, e.g. 2024-01-01T12:00:00Z: %w", err)
+	}
+	return parsed.UTC(), nil
+}
@@ -0,0 +1,73 @@
+// internal/interfaces/http/respond/time_test.go
+package respond
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"user-service/internal/testsupport"
+)
+
+func TestTime_MarshalsUTCWithMilliseconds(t *testing.T) {
+	if !testsupport.InLocalZone(t) {
+		return
+	}
+
+	// 19:30:05.123456 in Ho Chi Minh City is 12:30:05.123 UTC
+	local := time.Date(2024, time.March, 1, 19, 30, 5, 123456000, time.Local)
+	lastLogin := local.Add(time.Minute)
+	raw, err := json.Marshal(map[string]interface{}{
+		"at":      NewTime(local),
+		"last":    NewTimePtr(&lastLogin),
+		"missing": NewTimePtr(nil),
+	})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `{"at":"2024-03-01T12:30:05.123Z","last":"2024-03-01T12:31:05.123Z","missing":null}`
+	if string(raw) != want {
+		t.Errorf("got %s, want %s", raw, want)
+	}
+}
+
+func TestTime_UnmarshalsZAndOffsetForms(t *testing.T) {
+	if !testsupport.InLocalZone(t) {
+		return
+	}
+	want := time.Date(2024, time.March, 1, 12, 30, 0, 0, time.UTC)
+
+	for _, input := range []string{
+		`"2024-03-01T12:30:00Z"`,
+		`"2024-03-01T12:30:00.000Z"`,
+		`"2024-03-01T19:30:00+07:00"`,
+		`"2024-03-01T07:30:00-05:00"`,
+	} {
+		var got Time
+		if err := json.Unmarshal([]byte(input), &got); err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if !got.Time().Equal(want) || got.Time().Location() != time.UTC {
+			t.Errorf("%s: got %v, want %v in UTC", input, got.Time(), want)
+		}
+	}
+
+	var got Time
+	for _, input := range []string{`"2024-03-01 12:30:00"`, `"yesterday"`, `1709296200`} {
+		if err := json.Unmarshal([]byte(input), &got); err == nil {
+			t.Errorf("%s: expected an error", input)
+		}
+	}
+}
+
+func TestParseTime_RestoresUnescapedPlus(t *testing.T) {
+	// ?from=2024-03-01T19:30:00+07:00 decodes with a space for the plus
+	got, err := ParseTime("2024-03-01T19:30:00 07:00")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if want := time.Date(2024, time.March, 1, 12, 30, 0, 0, time.UTC); !got.Equal(want) || got.Location() != time.UTC {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+// Package router mounts handlers in groups that share a middleware chain,
+// serving the versioned API and its legacy unversioned aliases from the
+// same registration.
+package router
+
+import (
+	"net/http"
+	"strings"
+
+	"user-service/internal/interfaces/http/respond"
+)
+
+// VersionPrefix is where the current API version is mounted
+const VersionPrefix = "/v1"
+
+// Middleware wraps a handler, such as auth or a rate limit
+type Middleware = func(http.Handler) http.Handler
+
+// Router is a ServeMux that remembers each pattern, so tests can walk
+// every route, and knows the endpoint each belongs to: its path, whatever
+// the method, and without the version
+type Router struct {
+	mux      *http.ServeMux
+	patterns []string
+	// paths matches requests to an endpoint regardless of their method
+	paths *http.ServeMux
+	// endpoints maps each path in paths to its endpoint
+	endpoints map[string]string
+}
+
+func New() *Router {
+	return &Router{
+		mux:       http.NewServeMux(),
+		paths:     http.NewServeMux(),
+		endpoints: make(map[string]string),
+	}
+}
+
+// ServeHTTP routes r. Requests no pattern matches get the ServeMux's 404,
+// or its 405 with an Allow header, in the JSON error envelope.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.mux.Handler(r); pattern == "" {
+		w = &unmatchedWriter{ResponseWriter: w, r: r}
+	}
+	rt.mux.ServeHTTP(w, r)
+}
+
+// Handler returns the handler r is routed to and the endpoint it belongs
+// to, such as "/users/{id}" for both GET and HEAD /v1/users/42. Anything
+// keyed by endpoint, such as the kill switches, then covers every method
+// and both spellings alike. The endpoint is "" when no path matches.
+func (rt *Router) Handler(r *http.Request) (http.Handler, string) {
+	h, _ := rt.mux.Handler(r)
+	_, path := rt.paths.Handler(r)
+	return h, rt.endpoints[path]
+}
+
+// Patterns returns the registered patterns in registration order
+func (rt *Router) Patterns() []string {
+	return append([]string(nil), rt.patterns...)
+}
+
+// Group mounts routes as given, behind middleware
+func (rt *Router) Group(middleware ...Middleware) *Group {
+	return &Group{router: rt, middleware: middleware}
+}
+
+// Versioned mounts routes under VersionPrefix, behind middleware. Each is
+// also served at its unversioned path for clients that predate versioning,
+// marked deprecated with a link to its successor.
+func (rt *Router) Versioned(middleware ...Middleware) *Group {
+	return &Group{router: rt, middleware: middleware, versioned: true}
+}
+
+// handle mounts handler at pattern, which belongs to endpoint
+func (rt *Router) handle(pattern, endpoint string, handler http.Handler) {
+	rt.patterns = append(rt.patterns, pattern)
+	rt.mux.Handle(pattern, handler)
+
+	path := pattern
+	if _, p, ok := strings.Cut(pattern, " "); ok {
+		path = p
+	}
+	if _, ok := rt.endpoints[path]; !ok {
+		rt.endpoints[path] = endpoint
+		rt.paths.Handle(path, http.NotFoundHandler())
+	}
+}
+
+// Group is a set of routes sharing a middleware chain
+type Group struct {
+	router     *Router
+	middleware []Middleware
+	versioned  bool
+}
+
+// With returns a group whose routes also go through middleware, after
+// this group's own
+func (g *Group) With(middleware ...Middleware) *Group {
+	chain := make([]Middleware, 0, len(g.middleware)+len(middleware))
+	chain = append(append(chain, g.middleware...), middleware...)
+	return &Group{router: g.router, middleware: chain, versioned: g.versioned}
+}
+
+// Handle mounts handler at pattern behind the group's middleware, the
+// first of which sees the request first. A pattern naming its method, as
+// in "POST /users/login", leaves other methods to a 405; GET also
+// serves HEAD.
+func (g *Group) Handle(pattern string, handler http.Handler) {
+	for i := len(g.middleware) - 1; i >= 0; i-- {
+		handler = g.middleware[i](handler)
+	}
+	endpoint := pattern
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		endpoint = path
+	}
+	if !g.versioned {
+		g.router.handle(pattern, endpoint, handler)
+		return
+	}
+
+	g.router.handle(versionPattern(pattern), endpoint, handler)
+	g.router.handle(pattern, endpoint, deprecated(handler))
+}
+
+func (g *Group) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	g.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// versionPattern puts VersionPrefix in front of pattern's path, after the
+// method if it has one
+func versionPattern(pattern string) string {
+	if method, path, ok := strings.Cut(pattern, " "); ok {
+		return method + " " + VersionPrefix + path
+	}
+	return VersionPrefix + pattern
+}
+
+// deprecated marks responses from an unversioned alias as such, pointing
+// at the same path under VersionPrefix
+func deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+VersionPrefix+r.URL.EscapedPath()+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unmatchedWriter rewrites the plain-text errors ServeMux answers
+// unmatched requests with into the JSON error envelope. Its redirects, to
+// a path with a trailing slash, pass through.
+type unmatchedWriter struct {
+	http.ResponseWriter
+	r         *http.Request
+	rewritten bool
+}
+
+func (u *unmatchedWriter) WriteHeader(status int) {
+	var message string
+	switch status {
+	case http.StatusNotFound:
+		message = "Not found"
+	case http.StatusMethodNotAllowed:
+		// ServeMux has already set Allow
+		message = "Method not allowed"
+	default:
+		u.ResponseWriter.WriteHeader(status)
+		return
+	}
+	u.rewritten = true
+	u.Header().Del("Content-Type")
+	u.Header().Del("X-Content-Type-Options")
+	respond.Error(u.ResponseWriter, u.r, message, status)
+}
+
+func (u *unmatchedWriter) Write(b []byte) (int, error) {
+	if u.rewritten {
+		return len(b), nil
+	}
+	return u.ResponseWriter.Write(b)
+}
@@ -0,0 +1,162 @@
+// internal/interfaces/http/router/router_test.go
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"user-service/internal/interfaces/http/respond"
+)
+
+// tag is middleware that records its name on the response as it runs
+func tag(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Chain", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ok(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func serve(rt *Router, method, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+	return rec
+}
+
+func TestRouter_Groups(t *testing.T) {
+	rt := New()
+	users := rt.Versioned(tag("auth"))
+	users.HandleFunc("/users/me", ok)
+	users.With(tag("limit")).HandleFunc("/users/me/password", ok)
+	rt.Group(tag("admin")).HandleFunc("/admin/users", ok)
+
+	wantPatterns := []string{
+		"/v1/users/me", "/users/me",
+		"/v1/users/me/password", "/users/me/password",
+		"/admin/users",
+	}
+	if got := rt.Patterns(); !reflect.DeepEqual(got, wantPatterns) {
+		t.Errorf("expected patterns %v, got %v", wantPatterns, got)
+	}
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantChain  []string
+	}{
+		{"/v1/users/me", http.StatusOK, []string{"auth"}},
+		{"/users/me", http.StatusOK, []string{"auth"}},
+		// With extends the group without changing it
+		{"/v1/users/me/password", http.StatusOK, []string{"auth", "limit"}},
+		{"/admin/users", http.StatusOK, []string{"admin"}},
+		{"/v1/admin/users", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		rec := serve(rt, http.MethodGet, tt.path)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.wantStatus, rec.Code)
+			continue
+		}
+		if got := rec.Header().Values("X-Chain"); !reflect.DeepEqual(got, tt.wantChain) {
+			t.Errorf("%s: expected middleware %v, got %v", tt.path, tt.wantChain, got)
+		}
+	}
+}
+
+func TestRouter_LegacyAliasesAreDeprecated(t *testing.T) {
+	rt := New()
+	rt.Versioned().HandleFunc("/users/{id}", ok)
+	rt.Group().HandleFunc("/health", ok)
+
+	rec := serve(rt, http.MethodGet, "/users/42")
+	if rec.Header().Get("Deprecation") != "true" {
+		t.Errorf("expected the alias deprecated, got %v", rec.Header())
+	}
+	if link := rec.Header().Get("Link"); link != `</v1/users/42>; rel="successor-version"` {
+		t.Errorf("expected a link to the versioned path, got %q", link)
+	}
+
+	for _, path := range []string{"/v1/users/42", "/health"} {
+		if rec := serve(rt, http.MethodGet, path); rec.Header().Get("Deprecation") != "" {
+			t.Errorf("%s: expected no deprecation, got %v", path, rec.Header())
+		}
+	}
+}
+
+func TestRouter_HandlerReportsTheEndpoint(t *testing.T) {
+	rt := New()
+	rt.Versioned().HandleFunc("POST /users/login", ok)
+	rt.Versioned().HandleFunc("GET /users/{id}", ok)
+	rt.Versioned().HandleFunc("DELETE /users/{id}", ok)
+	rt.Group().HandleFunc("/admin/users", ok)
+
+	tests := []struct {
+		method, path, want string
+	}{
+		{http.MethodPost, "/v1/users/login", "/users/login"},
+		{http.MethodPost, "/users/login", "/users/login"},
+		// Whatever the method, even one the endpoint doesn't take
+		{http.MethodGet, "/users/login", "/users/login"},
+		{http.MethodHead, "/v1/users/42", "/users/{id}"},
+		{http.MethodDelete, "/users/42", "/users/{id}"},
+		{http.MethodPost, "/admin/users", "/admin/users"},
+		{http.MethodPost, "/nowhere", ""},
+	}
+	for _, tt := range tests {
+		_, endpoint := rt.Handler(httptest.NewRequest(tt.method, tt.path, nil))
+		if endpoint != tt.want {
+			t.Errorf("%s %s: expected %q, got %q", tt.method, tt.path, tt.want, endpoint)
+		}
+	}
+	if rec := serve(rt, http.MethodPost, "/v1/users/login"); rec.Code != http.StatusOK {
+		t.Errorf("expected the method kept in front of the version, got %d", rec.Code)
+	}
+	if !strings.HasPrefix(rt.Patterns()[0], "POST "+VersionPrefix) {
+		t.Errorf("expected the versioned pattern first, got %v", rt.Patterns())
+	}
+}
+
+func TestRouter_UnmatchedRequestsGetTheErrorEnvelope(t *testing.T) {
+	rt := New()
+	rt.Versioned().HandleFunc("POST /users/login", ok)
+	rt.Versioned().HandleFunc("GET /users/me", ok)
+	rt.Versioned().HandleFunc("PATCH /users/me", ok)
+
+	tests := []struct {
+		method, path string
+		wantStatus   int
+		wantCode     string
+		wantAllow    string
+	}{
+		{http.MethodGet, "/v1/users/login", http.StatusMethodNotAllowed, respond.CodeMethodNotAllowed, "POST"},
+		{http.MethodDelete, "/users/me", http.StatusMethodNotAllowed, respond.CodeMethodNotAllowed, "GET, HEAD, PATCH"},
+		{http.MethodGet, "/v1/nowhere", http.StatusNotFound, respond.CodeNotFound, ""},
+	}
+	for _, tt := range tests {
+		rec := serve(rt, tt.method, tt.path)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.path, tt.wantStatus, rec.Code)
+			continue
+		}
+		if allow := rec.Header().Get("Allow"); allow != tt.wantAllow {
+			t.Errorf("%s %s: expected Allow %q, got %q", tt.method, tt.path, tt.wantAllow, allow)
+		}
+		var body respond.ErrorBody
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error.Code != tt.wantCode {
+			t.Errorf("%s %s: expected the %q envelope, got %v: %s", tt.method, tt.path, tt.wantCode, err, rec.Body)
+		}
+	}
+
+	if rec := serve(rt, http.MethodHead, "/users/me"); rec.Code != http.StatusOK {
+		t.Errorf("expected GET to serve HEAD, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,405 @@
+// Package jobs runs the service's periodic background work: each job gets
+// a jittered schedule, panic recovery, optional cross-instance locking,
+// metrics and a status the admin API can show. Closing the Scheduler stops
+// every schedule and waits for runs in progress, so shutdown ordering is
+// handled in one place.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobRunning is returned by Run when the job is already running on
+	// this instance
+	ErrJobRunning = errors.New("job already running")
+	// ErrLockHeld is returned by Run when another instance holds a
+	// singleton job's lock
+	ErrLockHeld = errors.New("job is running on another instance")
+	ErrClosed   = errors.New("scheduler closed")
+)
+
+// DefaultJitter spreads each wait by up to ±10% of the interval, so
+// replicas started together don't hit shared resources in lockstep
+const DefaultJitter = 0.1
+
+// lockKeyPrefix namespaces singleton job leases
+const lockKeyPrefix = "locks:jobs:"
+
+// Outcomes of a run, as reported to an Observer
+const (
+	OutcomeOK    = "ok"
+	OutcomeError = "error"
+	OutcomePanic = "panic"
+	// OutcomeSkipped is a singleton run another instance held the lock for
+	OutcomeSkipped = "skipped"
+)
+
+// Func is one run of a job. ctx is cancelled when the job's timeout ends.
+type Func func(ctx context.Context) error
+
+// Locker hands out short-lived exclusive leases shared by every instance
+type Locker interface {
+	// TryLock returns acquired=false without blocking when someone else
+	// holds key. The lease expires after ttl even if release is never called.
+	TryLock(ctx context.Context, key string, ttl time.Duration) (release func(ctx context.Context) error, acquired bool, err error)
+}
+
+// Observer receives the outcome and duration of every run
+type Observer interface {
+	ObserveJob(name, outcome string, duration time.Duration)
+}
+
+// Status is a job's schedule and the result of its last run
+type Status struct {
+	Name     string
+	Interval time.Duration
+	Running  bool
+	// LastRun is when the last completed run started; nil before the first
+	LastRun      *time.Time
+	LastDuration time.Duration
+	// LastError is empty when the last run succeeded
+	LastError string
+	// NextRun is zero until the scheduler has started
+	NextRun  time.Time
+	Runs     int
+	Failures int
+}
+
+// Option configures a Scheduler
+type Option func(*Scheduler)
+
+// WithLocker lets singleton jobs run on one instance at a time. Without
+// it every instance runs its own, which is fine for a single replica.
+func WithLocker(locker Locker) Option {
+	return func(s *Scheduler) {
+		s.locker = locker
+	}
+}
+
+// WithObserver reports every run
+func WithObserver(observer Observer) Option {
+	return func(s *Scheduler) {
+		s.observer = observer
+	}
+}
+
+// WithJitter overrides DefaultJitter; 0 runs jobs exactly on their interval
+func WithJitter(fraction float64) Option {
+	return func(s *Scheduler) {
+		s.jitter = fraction
+	}
+}
+
+// WithClock sets the time source for the timestamps in Status
+func WithClock(now func() time.Time) Option {
+	return func(s *Scheduler) {
+		s.now = now
+	}
+}
+
+// JobOption configures one job
+type JobOption func(*job)
+
+// Singleton holds the Scheduler's lock for the length of each run, so with
+// several replicas only one runs the job at a time
+func Singleton() JobOption {
+	return func(j *job) {
+		j.singleton = true
+	}
+}
+
+// WithTimeout bounds one run, and a singleton's lease. The default is the
+// job's interval.
+func WithTimeout(d time.Duration) JobOption {
+	return func(j *job) {
+		j.timeout = d
+	}
+}
+
+type job struct {
+	name      string
+	interval  time.Duration
+	fn        Func
+	timeout   time.Duration
+	singleton bool
+
+	// Guarded by Scheduler.mu
+	running      bool
+	lastRun      *time.Time
+	lastDuration time.Duration
+	lastErr      string
+	nextRun      time.Time
+	runs         int
+	failures     int
+}
+
+// Scheduler runs registered jobs on their intervals once started
+type Scheduler struct {
+	locker   Locker
+	observer Observer
+	jitter   float64
+	now      func() time.Time
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	started bool
+	closed  bool
+	// stop ends the schedules; wg tracks them and every run in progress
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a Scheduler. Register jobs, then Start it.
+func New(opts ...Option) *Scheduler {
+	s := &Scheduler{
+		jitter: DefaultJitter,
+		now:    time.Now,
+		jobs:   make(map[string]*job),
+		stop:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register adds a job running fn every interval. Registering after Start
+// schedules the job right away. It panics on a duplicate name or a
+// non-positive interval, both programming errors.
+func (s *Scheduler) Register(name string, interval time.Duration, fn Func, opts ...JobOption) {
+	if interval <= 0 {
+		panic(fmt.Sprintf("jobs: non-positive interval for %q", name))
+	}
+	j := &job{name: name, interval: interval, fn: fn, timeout: interval}
+	for _, opt := range opts {
+		opt(j)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.jobs[name]; exists {
+		panic(fmt.Sprintf("jobs: %q registered twice", name))
+	}
+	s.jobs[name] = j
+	if s.started && !s.closed {
+		s.wg.Add(1)
+		go s.schedule(j)
+	}
+}
+
+// Start schedules every registered job. Each first runs one jittered
+// interval after Start. Calling it again does nothing.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.closed {
+		return
+	}
+	s.started = true
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.schedule(j)
+	}
+}
+
+// Run runs the named job now and returns its status afterwards. The run
+// isn't cut off when ctx is cancelled, only by the job's timeout. A failed
+// run returns the job's error.
+func (s *Scheduler) Run(ctx context.Context, name string) (Status, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return Status{}, ErrJobNotFound
+	}
+
+	err := s.run(context.WithoutCancel(ctx), j)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status(j), err
+}
+
+// Statuses lists every job by name
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, s.status(j))
+	}
+	sort.Slice(statuses, func(a, b int) bool { return statuses[a].Name < statuses[b].Name })
+	return statuses
+}
+
+// Close stops the schedules and waits for runs in progress to finish. It
+// returns ctx.Err() if that takes too long. Run fails with ErrClosed after.
+func (s *Scheduler) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// status copies j's state; the caller holds s.mu
+func (s *Scheduler) status(j *job) Status {
+	return Status{
+		Name:         j.name,
+		Interval:     j.interval,
+		Running:      j.running,
+		LastRun:      j.lastRun,
+		LastDuration: j.lastDuration,
+		LastError:    j.lastErr,
+		NextRun:      j.nextRun,
+		Runs:         j.runs,
+		Failures:     j.failures,
+	}
+}
+
+func (s *Scheduler) schedule(j *job) {
+	defer s.wg.Done()
+
+	for {
+		wait := s.delay(j.interval)
+		s.mu.Lock()
+		j.nextRun = s.now().Add(wait)
+		s.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+			// Errors are logged and recorded by run; a run still going from
+			// a manual trigger just means this one is skipped
+			s.run(context.Background(), j)
+		case <-s.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// delay returns interval spread by up to ±jitter of itself
+func (s *Scheduler) delay(interval time.Duration) time.Duration {
+	if s.jitter <= 0 {
+		return interval
+	}
+	spread := float64(interval) * s.jitter
+	return interval + time.Duration((rand.Float64()*2-1)*spread)
+}
+
+// run runs j once unless it is already running here or, for a singleton,
+// on another instance
+func (s *Scheduler) run(ctx context.Context, j *job) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	if j.running {
+		s.mu.Unlock()
+		return ErrJobRunning
+	}
+	j.running = true
+	s.wg.Add(1)
+	s.mu.Unlock()
+	defer s.wg.Done()
+
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	startedAt := s.now()
+	start := time.Now()
+
+	var err error
+	outcome := OutcomeOK
+	if j.singleton && s.locker != nil {
+		var release func(ctx context.Context) error
+		var acquired bool
+		release, acquired, err = s.locker.TryLock(ctx, lockKeyPrefix+j.name, j.timeout)
+		switch {
+		case err != nil:
+			outcome = OutcomeError
+		case !acquired:
+			s.mu.Lock()
+			j.running = false
+			s.mu.Unlock()
+			s.observe(j.name, OutcomeSkipped, time.Since(start))
+			return ErrLockHeld
+		default:
+			defer func() {
+				if err := release(context.WithoutCancel(ctx)); err != nil {
+					log.Printf("Failed to release lock for job %s: %v", j.name, err)
+				}
+			}()
+		}
+	}
+	if err == nil {
+		outcome, err = call(ctx, j)
+	}
+	duration := time.Since(start)
+
+	s.mu.Lock()
+	j.running = false
+	j.lastRun = &startedAt
+	j.lastDuration = duration
+	j.lastErr = ""
+	j.runs++
+	if err != nil {
+		j.lastErr = err.Error()
+		j.failures++
+	}
+	s.mu.Unlock()
+
+	s.observe(j.name, outcome, duration)
+	if err != nil {
+		log.Printf("Job %s failed after %s: %v", j.name, duration.Round(time.Millisecond), err)
+	}
+	return err
+}
+
+// call runs j.fn, turning a panic into an error so one bad run doesn't
+// take down the process
+func call(ctx context.Context, j *job) (outcome string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Job %s panicked: %v\n%s", j.name, r, debug.Stack())
+			outcome, err = OutcomePanic, fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if err := j.fn(ctx); err != nil {
+		return OutcomeError, err
+	}
+	return OutcomeOK, nil
+}
+
+func (s *Scheduler) observe(name, outcome string, duration time.Duration) {
+	if s.observer != nil {
+		s.observer.ObserveJob(name, outcome, duration)
+	}
+}
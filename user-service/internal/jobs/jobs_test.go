@@ -0,0 +1,193 @@
+// internal/jobs/jobs_test.go
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/jobs"
+)
+
+// fakeLocker grants a lease unless held is set
+type fakeLocker struct {
+	mu       sync.Mutex
+	held     bool
+	keys     []string
+	released int
+}
+
+func (l *fakeLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (func(context.Context) error, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.keys = append(l.keys, key)
+	if l.held {
+		return nil, false, nil
+	}
+	l.held = true
+	return func(context.Context) error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.held = false
+		l.released++
+		return nil
+	}, true, nil
+}
+
+// fakeObserver records outcomes per job
+type fakeObserver struct {
+	mu       sync.Mutex
+	outcomes map[string][]string
+}
+
+func (o *fakeObserver) ObserveJob(name, outcome string, duration time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.outcomes == nil {
+		o.outcomes = make(map[string][]string)
+	}
+	o.outcomes[name] = append(o.outcomes[name], outcome)
+}
+
+func (o *fakeObserver) get(name string) []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.outcomes[name]...)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRun_RecordsStatusAndRecoversPanics(t *testing.T) {
+	observer := &fakeObserver{}
+	s := jobs.New(jobs.WithObserver(observer))
+	defer s.Close(context.Background())
+
+	fail := errors.New("database unavailable")
+	calls := 0
+	s.Register("flaky", time.Hour, func(ctx context.Context) error {
+		calls++
+		switch calls {
+		case 1:
+			return fail
+		case 2:
+			panic("nil map")
+		}
+		return nil
+	})
+	ctx := context.Background()
+
+	status, err := s.Run(ctx, "flaky")
+	if !errors.Is(err, fail) || status.LastError != fail.Error() || status.Failures != 1 || status.LastRun == nil {
+		t.Fatalf("expected the failure to be recorded, got %+v (%v)", status, err)
+	}
+	// A panicking job fails its run instead of the process
+	if status, err = s.Run(ctx, "flaky"); err == nil || status.Failures != 2 {
+		t.Fatalf("expected the panic to fail the run, got %+v (%v)", status, err)
+	}
+	if status, err = s.Run(ctx, "flaky"); err != nil || status.LastError != "" || status.Runs != 3 || status.Failures != 2 {
+		t.Fatalf("expected a clean run to clear the error, got %+v (%v)", status, err)
+	}
+
+	want := []string{jobs.OutcomeError, jobs.OutcomePanic, jobs.OutcomeOK}
+	if got := observer.get("flaky"); !equalStrings(got, want) {
+		t.Errorf("observed %v, want %v", got, want)
+	}
+	if _, err := s.Run(ctx, "missing"); !errors.Is(err, jobs.ErrJobNotFound) {
+		t.Errorf("expected ErrJobNotFound, got %v", err)
+	}
+}
+
+func TestRun_SingletonNeedsTheLock(t *testing.T) {
+	observer := &fakeObserver{}
+	locker := &fakeLocker{held: true}
+	s := jobs.New(jobs.WithLocker(locker), jobs.WithObserver(observer))
+	defer s.Close(context.Background())
+
+	runs := 0
+	s.Register("erasure", time.Hour, func(ctx context.Context) error {
+		runs++
+		return nil
+	}, jobs.Singleton())
+	s.Register("local", time.Hour, func(ctx context.Context) error { return nil })
+
+	// Another instance holds the lock
+	status, err := s.Run(context.Background(), "erasure")
+	if !errors.Is(err, jobs.ErrLockHeld) || runs != 0 || status.Runs != 0 {
+		t.Fatalf("expected the run to be skipped, got %+v (%v)", status, err)
+	}
+
+	locker.held = false
+	if _, err := s.Run(context.Background(), "erasure"); err != nil || runs != 1 {
+		t.Fatalf("expected one run, got %d (%v)", runs, err)
+	}
+	if locker.released != 1 || locker.held {
+		t.Error("expected the lock to be released after the run")
+	}
+
+	// Jobs that aren't singletons never touch the lock
+	if _, err := s.Run(context.Background(), "local"); err != nil {
+		t.Fatalf("local run: %v", err)
+	}
+	if want := []string{"locks:jobs:erasure", "locks:jobs:erasure"}; !equalStrings(locker.keys, want) {
+		t.Errorf("locked %v, want %v", locker.keys, want)
+	}
+	if want := []string{jobs.OutcomeSkipped, jobs.OutcomeOK}; !equalStrings(observer.get("erasure"), want) {
+		t.Errorf("observed %v, want %v", observer.get("erasure"), want)
+	}
+}
+
+func TestScheduler_RunsOnScheduleAndDrainsOnClose(t *testing.T) {
+	s := jobs.New(jobs.WithJitter(0))
+
+	ran := make(chan struct{}, 16)
+	release := make(chan struct{})
+	s.Register("tick", 10*time.Millisecond, func(ctx context.Context) error {
+		ran <- struct{}{}
+		<-release
+		return nil
+	})
+	s.Start()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job never ran")
+	}
+	// Manual triggers don't overlap a scheduled run
+	if _, err := s.Run(context.Background(), "tick"); !errors.Is(err, jobs.ErrJobRunning) {
+		t.Errorf("expected ErrJobRunning, got %v", err)
+	}
+	if statuses := s.Statuses(); len(statuses) != 1 || !statuses[0].Running || statuses[0].NextRun.IsZero() {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+
+	// Close waits for the run in progress
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected Close to wait for the run, got %v", err)
+	}
+	close(release)
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if _, err := s.Run(context.Background(), "tick"); !errors.Is(err, jobs.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+	if runs := s.Statuses()[0].Runs; runs != 1 {
+		t.Errorf("expected no runs after Close, got %d", runs)
+	}
+}
@@ -0,0 +1,47 @@
+// Package logging tags log lines with the request they were written for,
+// so one request can be followed from the handler through the service
+// and repositories. The HTTP and gRPC servers put the request ID in the
+// context; everything below them logs with Printf.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the request ID id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID ctx carries, or ""
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random (version 4) UUID
+func NewRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Printf logs like log.Printf, ending the line with the request ID when
+// ctx carries one
+func Printf(ctx context.Context, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if id := RequestID(ctx); id != "" {
+		msg += " request_id=" + id
+	}
+	log.Output(2, msg)
+}
@@ -0,0 +1,44 @@
+// internal/logging/logging_test.go
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"regexp"
+	"testing"
+)
+
+func TestPrintf(t *testing.T) {
+	var buf bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	defer log.SetFlags(log.Flags())
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+
+	Printf(context.Background(), "no request %d", 1)
+	Printf(WithRequestID(context.Background(), "req-42"), "in a request %d", 2)
+
+	want := "no request 1\nin a request 2 request_id=req-42\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := NewRequestID()
+		if !uuid.MatchString(id) {
+			t.Fatalf("expected a version 4 UUID, got %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("generated %q twice", id)
+		}
+		seen[id] = true
+	}
+	if RequestID(context.Background()) != "" || RequestID(WithRequestID(context.Background(), "x")) != "x" {
+		t.Error("expected RequestID to read back what WithRequestID stored")
+	}
+}
@@ -0,0 +1,31 @@
+// Package normalize defines the canonical form of user-supplied account
+// fields. Every entry point (HTTP handlers, the service, gRPC and the admin
+// CLI) goes through it, so an email registered in one spelling is found in
+// any other. It has no dependencies, which keeps it cheap to fuzz.
+package normalize
+
+import "strings"
+
+// Email is the form emails are stored and looked up in: trimmed and lower
+// case
+func Email(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Username trims surrounding whitespace; case is kept for display, and
+// comparisons that must ignore it lower-case explicitly
+func Username(username string) string {
+	return strings.TrimSpace(username)
+}
+
+// Name trims a first or last name, so whitespace alone counts as empty
+func Name(name string) string {
+	return strings.TrimSpace(name)
+}
+
+// Password trims surrounding whitespace, which is easy to paste by
+// accident. Registration, password resets and login must all apply it, or
+// a password set with a trailing space could never be used.
+func Password(password string) string {
+	return strings.TrimSpace(password)
+}
@@ -0,0 +1,105 @@
+// internal/normalize/normalize_test.go
+package normalize
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+func TestEmail(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"alice@example.com", "alice@example.com"},
+		{"  Alice@Example.COM\t", "alice@example.com"},
+		{" bob@example.com\n", "bob@example.com"},
+		{"   ", ""},
+	}
+	for _, tt := range tests {
+		if got := Email(tt.in); got != tt.want {
+			t.Errorf("Email(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUsernameKeepsCase(t *testing.T) {
+	if got := Username("  Alice "); got != "Alice" {
+		t.Errorf("expected the username trimmed with its case kept, got %q", got)
+	}
+}
+
+// checkCanonical asserts the invariants every normalizer shares: applying it
+// twice changes nothing and the result has no surrounding whitespace
+func checkCanonical(t *testing.T, name string, fn func(string) string, in string) string {
+	t.Helper()
+	out := fn(in)
+	if again := fn(out); again != out {
+		t.Fatalf("%s is not idempotent: %q -> %q -> %q", name, in, out, again)
+	}
+	if strings.TrimSpace(out) != out {
+		t.Fatalf("%s(%q) = %q keeps surrounding whitespace", name, in, out)
+	}
+	if strings.TrimSpace(in) == "" && out != "" {
+		t.Fatalf("%s(%q) = %q, want empty for blank input", name, in, out)
+	}
+	return out
+}
+
+func FuzzEmail(f *testing.F) {
+	for _, seed := range []string{"alice@example.com", " MiXeD@Example.Com ", "\t\n", "İ@example.com", "K@x.io", "\xff@x.io"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		out := checkCanonical(t, "Email", Email, in)
+		if !utf8.ValidString(in) {
+			return
+		}
+		// Variants differing only in ASCII case and padding share one form.
+		// Full Unicode case folding doesn't round-trip (ı -> I -> i), so
+		// those are different addresses.
+		if variant := " " + flipASCIICase(in) + "\t"; Email(variant) != out {
+			t.Fatalf("Email(%q) = %q but Email(%q) = %q", in, out, variant, Email(variant))
+		}
+		for _, r := range out {
+			if unicode.IsUpper(r) && unicode.ToLower(r) != r {
+				t.Fatalf("Email(%q) = %q still has upper case", in, out)
+			}
+		}
+	})
+}
+
+func flipASCIICase(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z':
+			return r - 'a' + 'A'
+		case 'A' <= r && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return r
+	}, s)
+}
+
+func FuzzUsername(f *testing.F) {
+	for _, seed := range []string{"alice", "  Alice  ", "   ", "\u3000bob\u3000"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		checkCanonical(t, "Username", Username, in)
+	})
+}
+
+func FuzzPassword(f *testing.F) {
+	for _, seed := range []string{"secret123", " secret123 ", "      ", "\tpass word1\n"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		out := checkCanonical(t, "Password", Password, in)
+		// Inner whitespace is part of the password
+		if strings.Count(out, " ") > strings.Count(in, " ") {
+			t.Fatalf("Password(%q) = %q added spaces", in, out)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package testsupport
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+var _ application.AccessTokenRepository = (*AccessTokenRepository)(nil)
+var _ application.SessionRevoker = (*AccessTokenRepository)(nil)
+var _ Snapshotter = (*AccessTokenRepository)(nil)
+
+type storedAccessToken struct {
+	token domain.AccessToken
+	hash  string
+}
+
+// AccessTokenRepository is an in-memory application.AccessTokenRepository
+type AccessTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[uint]storedAccessToken
+	nextID uint
+}
+
+func NewAccessTokenRepository() *AccessTokenRepository {
+	return &AccessTokenRepository{tokens: make(map[uint]storedAccessToken), nextID: 1}
+}
+
+// Hash returns the hash stored for token id
+func (r *AccessTokenRepository) Hash(id uint) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.tokens[id]
+	return stored.hash, ok
+}
+
+// Snapshot captures the stored tokens; calling restore puts them back
+func (r *AccessTokenRepository) Snapshot() (restore func()) {
+	r.mu.Lock()
+	tokens := make(map[uint]storedAccessToken, len(r.tokens))
+	for id, stored := range r.tokens {
+		tokens[id] = stored
+	}
+	nextID := r.nextID
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.tokens, r.nextID = tokens, nextID
+	}
+}
+
+func (r *AccessTokenRepository) Create(ctx context.Context, token *domain.AccessToken, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = r.nextID
+	r.nextID++
+	stored := *token
+	stored.Scopes = append([]string(nil), token.Scopes...)
+	r.tokens[token.ID] = storedAccessToken{token: stored, hash: hash}
+	return nil
+}
+
+func (r *AccessTokenRepository) ListByUser(ctx context.Context, userID uint) ([]*domain.AccessToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tokens []*domain.AccessToken
+	for _, stored := range r.tokens {
+		if stored.token.UserID == userID {
+			token := stored.token
+			tokens = append(tokens, &token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID > tokens[j].ID })
+	return tokens, nil
+}
+
+func (r *AccessTokenRepository) GetByHash(ctx context.Context, hash string) (*domain.AccessToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, stored := range r.tokens {
+		if stored.hash == hash {
+			token := stored.token
+			return &token, nil
+		}
+	}
+	return nil, domain.ErrAccessTokenNotFound
+}
+
+func (r *AccessTokenRepository) Delete(ctx context.Context, userID, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.tokens[id]
+	if !ok || stored.token.UserID != userID {
+		return domain.ErrAccessTokenNotFound
+	}
+	delete(r.tokens, id)
+	return nil
+}
+
+func (r *AccessTokenRepository) DeleteByUser(ctx context.Context, userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, stored := range r.tokens {
+		if stored.token.UserID == userID {
+			delete(r.tokens, id)
+		}
+	}
+	return nil
+}
+
+func (r *AccessTokenRepository) RevokeUserSessions(ctx context.Context, userID uint) error {
+	return r.DeleteByUser(ctx, userID)
+}
+
+func (r *AccessTokenRepository) UpdateLastUsed(ctx context.Context, used map[uint]time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, at := range used {
+		if stored, ok := r.tokens[id]; ok {
+			at := at
+			stored.token.LastUsedAt = &at
+			r.tokens[id] = stored
+		}
+	}
+	return nil
+}
+
+func (r *AccessTokenRepository) WithTx(tx *gorm.DB) application.AccessTokenRepository {
+	return r
+}
@@ -0,0 +1,115 @@
+package testsupport
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+)
+
+var _ application.BulkJobRepository = (*BulkJobRepository)(nil)
+var _ application.ReportStore = (*ReportStore)(nil)
+
+// BulkJobRepository is an in-memory application.BulkJobRepository
+type BulkJobRepository struct {
+	mu     sync.Mutex
+	jobs   map[uint]domain.BulkJob
+	nextID uint
+	// SaveErr, when set, fails every SaveProgress
+	SaveErr error
+}
+
+func NewBulkJobRepository() *BulkJobRepository {
+	return &BulkJobRepository{jobs: make(map[uint]domain.BulkJob), nextID: 1}
+}
+
+func (r *BulkJobRepository) Create(ctx context.Context, job *domain.BulkJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job.ID = r.nextID
+	r.nextID++
+	r.jobs[job.ID] = copyBulkJob(job)
+	return nil
+}
+
+func (r *BulkJobRepository) Get(ctx context.Context, id uint) (*domain.BulkJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, domain.ErrBulkJobNotFound
+	}
+	job = copyBulkJob(&job)
+	return &job, nil
+}
+
+func (r *BulkJobRepository) NextUnfinished(ctx context.Context) (*domain.BulkJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var next *domain.BulkJob
+	for _, job := range r.jobs {
+		if job.Status != domain.BulkJobDone && (next == nil || job.ID < next.ID) {
+			job = copyBulkJob(&job)
+			next = &job
+		}
+	}
+	return next, nil
+}
+
+func (r *BulkJobRepository) SaveProgress(ctx context.Context, job *domain.BulkJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.SaveErr != nil {
+		return r.SaveErr
+	}
+	if _, ok := r.jobs[job.ID]; !ok {
+		return domain.ErrBulkJobNotFound
+	}
+	r.jobs[job.ID] = copyBulkJob(job)
+	return nil
+}
+
+// copyBulkJob keeps stored jobs from sharing slices with the caller's
+func copyBulkJob(job *domain.BulkJob) domain.BulkJob {
+	c := *job
+	c.UserIDs = append([]uint(nil), job.UserIDs...)
+	c.Failures = append([]domain.BulkFailure(nil), job.Failures...)
+	return c
+}
+
+// ReportStore is an in-memory application.ReportStore. Its links are
+// https://reports.test/ followed by the key.
+type ReportStore struct {
+	mu      sync.Mutex
+	reports map[string]string
+}
+
+func NewReportStore() *ReportStore {
+	return &ReportStore{reports: make(map[string]string)}
+}
+
+// Report returns the stored report
+func (s *ReportStore) Report(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report, ok := s.reports[key]
+	return report, ok
+}
+
+func (s *ReportStore) SaveReport(ctx context.Context, key string, report io.Reader) error {
+	body, err := io.ReadAll(report)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports[key] = string(body)
+	return nil
+}
+
+func (s *ReportStore) ReportURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return "https://reports.test/" + key, nil
+}
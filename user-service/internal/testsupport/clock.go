@@ -0,0 +1,41 @@
+package testsupport
+
+import (
+	"sync"
+	"time"
+
+	"user-service/internal/infrastructure/auth"
+)
+
+// JWTSecret signs tokens issued by NewJWTManager
+const JWTSecret = "test-secret"
+
+// Clock is a manually advanced time source for WithClock options
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a clock stopped at 2024-01-01 12:00 UTC
+func NewClock() *Clock {
+	return &Clock{now: time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// NewJWTManager returns a JWTManager signing with JWTSecret whose tokens
+// are issued and validated against clock, so expiry can be tested by
+// advancing it
+func NewJWTManager(clock *Clock, expire time.Duration) *auth.JWTManager {
+	return auth.NewJWTManager(JWTSecret, expire, auth.WithClock(clock.Now))
+}
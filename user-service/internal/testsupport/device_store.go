@@ -0,0 +1,54 @@
+package testsupport
+
+import (
+	"context"
+	"sync"
+
+	"user-service/internal/application"
+)
+
+var _ application.DeviceStore = (*DeviceStore)(nil)
+
+// DeviceStore is an in-memory application.DeviceStore. Devices never
+// expire.
+type DeviceStore struct {
+	mu      sync.Mutex
+	devices map[uint]map[string]bool
+	// Err, when set, fails every call
+	Err error
+}
+
+func NewDeviceStore() *DeviceStore {
+	return &DeviceStore{devices: make(map[uint]map[string]bool)}
+}
+
+func (d *DeviceStore) RememberDevice(ctx context.Context, userID uint, fingerprint string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.Err != nil {
+		return false, d.Err
+	}
+	if d.devices[userID] == nil {
+		d.devices[userID] = make(map[string]bool)
+	}
+	known := d.devices[userID][fingerprint]
+	d.devices[userID][fingerprint] = true
+	return known, nil
+}
+
+func (d *DeviceStore) ForgetDevices(ctx context.Context, userID uint) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.Err != nil {
+		return d.Err
+	}
+	delete(d.devices, userID)
+	return nil
+}
+
+// Devices returns how many devices the user has
+func (d *DeviceStore) Devices(userID uint) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.devices[userID])
+}
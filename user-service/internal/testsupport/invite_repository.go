@@ -0,0 +1,95 @@
+package testsupport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+var _ application.InviteRepository = (*InviteRepository)(nil)
+var _ Snapshotter = (*InviteRepository)(nil)
+
+// InviteRepository is an in-memory application.InviteRepository. Consume
+// checks and increments under one lock, like the Postgres conditional
+// update.
+type InviteRepository struct {
+	mu      sync.Mutex
+	invites map[string]domain.Invite
+}
+
+func NewInviteRepository() *InviteRepository {
+	return &InviteRepository{invites: make(map[string]domain.Invite)}
+}
+
+// Invite returns a copy of the stored invite
+func (r *InviteRepository) Invite(code string) (domain.Invite, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invite, ok := r.invites[code]
+	return invite, ok
+}
+
+// Snapshot captures the stored invites; calling restore puts them back
+func (r *InviteRepository) Snapshot() (restore func()) {
+	r.mu.Lock()
+	invites := make(map[string]domain.Invite, len(r.invites))
+	for code, invite := range r.invites {
+		invites[code] = invite
+	}
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.invites = invites
+	}
+}
+
+func (r *InviteRepository) Create(ctx context.Context, invite *domain.Invite) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.invites[invite.Code]; ok {
+		return fmt.Errorf("testsupport: duplicate invite code %q", invite.Code)
+	}
+	r.invites[invite.Code] = *invite
+	return nil
+}
+
+func (r *InviteRepository) Revoke(ctx context.Context, code string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invite, ok := r.invites[code]
+	if !ok {
+		return domain.ErrInviteNotFound
+	}
+	if invite.RevokedAt == nil {
+		invite.RevokedAt = &at
+		r.invites[code] = invite
+	}
+	return nil
+}
+
+func (r *InviteRepository) Consume(ctx context.Context, code string, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	invite, ok := r.invites[code]
+	if !ok {
+		return domain.ErrInviteNotFound
+	}
+	if err := invite.Usable(now); err != nil {
+		return err
+	}
+	invite.Uses++
+	r.invites[code] = invite
+	return nil
+}
+
+func (r *InviteRepository) WithTx(tx *gorm.DB) application.InviteRepository {
+	return r
+}
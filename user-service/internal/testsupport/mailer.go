@@ -0,0 +1,39 @@
+package testsupport
+
+import (
+	"context"
+	"sync"
+
+	"user-service/internal/application"
+)
+
+var _ application.Mailer = (*Mailer)(nil)
+
+// Mailer is an in-memory application.Mailer that keeps what it was sent
+type Mailer struct {
+	mu   sync.Mutex
+	sent []application.Message
+	// Err, when set, fails every Send
+	Err error
+}
+
+func NewMailer() *Mailer {
+	return &Mailer{}
+}
+
+func (m *Mailer) Send(ctx context.Context, msg *application.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Err != nil {
+		return m.Err
+	}
+	m.sent = append(m.sent, *msg)
+	return nil
+}
+
+// Sent returns a copy of every delivered message, oldest first
+func (m *Mailer) Sent() []application.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]application.Message(nil), m.sent...)
+}
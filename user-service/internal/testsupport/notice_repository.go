@@ -0,0 +1,87 @@
+package testsupport
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+)
+
+var _ application.NoticeRepository = (*NoticeRepository)(nil)
+
+type noticeKey struct {
+	userID uint
+	code   string
+}
+
+// NoticeRepository is an in-memory application.NoticeRepository
+type NoticeRepository struct {
+	mu        sync.Mutex
+	notices   map[noticeKey]domain.UserNotice
+	dismissed map[noticeKey]string
+	nextID    uint
+}
+
+func NewNoticeRepository() *NoticeRepository {
+	return &NoticeRepository{
+		notices:   make(map[noticeKey]domain.UserNotice),
+		dismissed: make(map[noticeKey]string),
+		nextID:    1,
+	}
+}
+
+func (r *NoticeRepository) ListByUser(ctx context.Context, userID uint) ([]*domain.UserNotice, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var notices []*domain.UserNotice
+	for key, notice := range r.notices {
+		if key.userID == userID {
+			cp := notice
+			notices = append(notices, &cp)
+		}
+	}
+	sort.Slice(notices, func(i, j int) bool { return notices[i].ID < notices[j].ID })
+	return notices, nil
+}
+
+func (r *NoticeRepository) Put(ctx context.Context, notice *domain.UserNotice) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notice.ID = r.nextID
+	r.nextID++
+	r.notices[noticeKey{notice.UserID, notice.Code}] = *notice
+	return nil
+}
+
+func (r *NoticeRepository) Delete(ctx context.Context, userID uint, code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := noticeKey{userID, code}
+	if _, ok := r.notices[key]; !ok {
+		return domain.ErrNoticeNotFound
+	}
+	delete(r.notices, key)
+	return nil
+}
+
+func (r *NoticeRepository) Dismissals(ctx context.Context, userID uint) (map[string]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	dismissed := make(map[string]string)
+	for key, version := range r.dismissed {
+		if key.userID == userID {
+			dismissed[key.code] = version
+		}
+	}
+	return dismissed, nil
+}
+
+func (r *NoticeRepository) Dismiss(ctx context.Context, userID uint, code, version string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dismissed[noticeKey{userID, code}] = version
+	return nil
+}
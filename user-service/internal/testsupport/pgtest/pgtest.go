@@ -0,0 +1,142 @@
+// Package pgtest starts a throwaway Postgres for repository integration
+// tests. One container is shared by every test in the process and all
+// tables are truncated before each test hands out its connection.
+//
+// Set TEST_DATABASE_URL to run against an existing database instead of a
+// container. Tests are skipped under -short or when Docker is unavailable.
+package pgtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const image = "postgres:16-alpine"
+
+var (
+	once    sync.Once
+	db      *gorm.DB
+	initErr error
+)
+
+// DB returns a connection to an empty, migrated database. migrate runs once
+// per process; pass the repository package's Migrate function.
+func DB(t *testing.T, migrate func(*gorm.DB) error) *gorm.DB {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping Postgres integration test in short mode")
+	}
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		skipWithoutDocker(t)
+	}
+
+	once.Do(func() {
+		if dsn == "" {
+			dsn, initErr = startContainer()
+			if initErr != nil {
+				return
+			}
+		}
+		db, initErr = open(dsn)
+		if initErr == nil {
+			initErr = migrate(db)
+		}
+	})
+	if initErr != nil {
+		t.Fatalf("pgtest: %v", initErr)
+	}
+
+	if err := truncate(db); err != nil {
+		t.Fatalf("pgtest: %v", err)
+	}
+	return db
+}
+
+// skipWithoutDocker skips the test when no Docker daemon is reachable.
+// testcontainers panics rather than failing when it can't find a host.
+func skipWithoutDocker(t *testing.T) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Skipf("Docker not available: %v", r)
+		}
+	}()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+}
+
+// startContainer launches Postgres and returns its DSN. The container is
+// left for the testcontainers reaper to remove when the process exits.
+func startContainer() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	container, err := tcpostgres.Run(ctx, image,
+		tcpostgres.WithDatabase("user_service_test"),
+		tcpostgres.WithUsername("test"),
+		tcpostgres.WithPassword("test"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(time.Minute),
+		),
+	)
+	if err != nil {
+		return "", fmt.Errorf("start container: %w", err)
+	}
+
+	dsn, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		return "", fmt.Errorf("container dsn: %w", err)
+	}
+	return dsn, nil
+}
+
+func open(dsn string) (*gorm.DB, error) {
+	conn, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	return conn, nil
+}
+
+// truncate empties every table in the public schema and resets sequences
+func truncate(conn *gorm.DB) error {
+	var tables []string
+	err := conn.Raw(`SELECT tablename FROM pg_tables WHERE schemaname = 'public'`).
+		Scan(&tables).Error
+	if err != nil {
+		return fmt.Errorf("list tables: %w", err)
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, len(tables))
+	for i, table := range tables {
+		quoted[i] = fmt.Sprintf("%q", table)
+	}
+	stmt := "TRUNCATE " + strings.Join(quoted, ", ") + " RESTART IDENTITY CASCADE"
+	if err := conn.Exec(stmt).Error; err != nil {
+		return fmt.Errorf("truncate: %w", err)
+	}
+	return nil
+}
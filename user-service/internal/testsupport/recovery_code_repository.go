@@ -0,0 +1,102 @@
+package testsupport
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"user-service/internal/application"
+
+	"gorm.io/gorm"
+)
+
+var _ application.RecoveryCodeRepository = (*RecoveryCodeRepository)(nil)
+var _ Snapshotter = (*RecoveryCodeRepository)(nil)
+
+type recoveryCode struct {
+	hash string
+	used bool
+}
+
+// RecoveryCodeRepository is an in-memory application.RecoveryCodeRepository
+type RecoveryCodeRepository struct {
+	mu    sync.Mutex
+	codes map[uint][]recoveryCode
+}
+
+func NewRecoveryCodeRepository() *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{codes: make(map[uint][]recoveryCode)}
+}
+
+// Hashes returns the hashes stored for userID, used or not
+func (r *RecoveryCodeRepository) Hashes(userID uint) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hashes := make([]string, len(r.codes[userID]))
+	for i, code := range r.codes[userID] {
+		hashes[i] = code.hash
+	}
+	return hashes
+}
+
+// Snapshot captures the stored codes; calling restore puts them back
+func (r *RecoveryCodeRepository) Snapshot() (restore func()) {
+	r.mu.Lock()
+	codes := make(map[uint][]recoveryCode, len(r.codes))
+	for userID, userCodes := range r.codes {
+		codes[userID] = append([]recoveryCode(nil), userCodes...)
+	}
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.codes = codes
+	}
+}
+
+func (r *RecoveryCodeRepository) Replace(ctx context.Context, userID uint, hashes []string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	codes := make([]recoveryCode, len(hashes))
+	for i, hash := range hashes {
+		codes[i] = recoveryCode{hash: hash}
+	}
+	r.codes[userID] = codes
+	return nil
+}
+
+func (r *RecoveryCodeRepository) Consume(ctx context.Context, userID uint, hash string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, code := range r.codes[userID] {
+		if code.hash == hash && !code.used {
+			r.codes[userID][i].used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *RecoveryCodeRepository) CountRemaining(ctx context.Context, userID uint) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	remaining := 0
+	for _, code := range r.codes[userID] {
+		if !code.used {
+			remaining++
+		}
+	}
+	return remaining, nil
+}
+
+func (r *RecoveryCodeRepository) DeleteByUser(ctx context.Context, userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.codes, userID)
+	return nil
+}
+
+func (r *RecoveryCodeRepository) WithTx(tx *gorm.DB) application.RecoveryCodeRepository {
+	return r
+}
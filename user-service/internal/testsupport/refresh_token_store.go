@@ -0,0 +1,70 @@
+package testsupport
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"user-service/internal/infrastructure/auth"
+)
+
+var _ auth.RefreshTokenStore = (*RefreshTokenStore)(nil)
+
+// RefreshTokenStore is an in-memory auth.RefreshTokenStore. Rotate checks
+// and marks under one lock, like the Postgres conditional update.
+type RefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]auth.RefreshToken
+}
+
+func NewRefreshTokenStore() *RefreshTokenStore {
+	return &RefreshTokenStore{tokens: make(map[string]auth.RefreshToken)}
+}
+
+// Len returns how many tokens are stored
+func (s *RefreshTokenStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tokens)
+}
+
+func (s *RefreshTokenStore) Create(ctx context.Context, token *auth.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token.Hash] = *token
+	return nil
+}
+
+func (s *RefreshTokenStore) Get(ctx context.Context, hash string) (*auth.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[hash]
+	if !ok {
+		return nil, auth.ErrInvalidRefreshToken
+	}
+	return &token, nil
+}
+
+func (s *RefreshTokenStore) Rotate(ctx context.Context, hash string, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[hash]
+	if !ok || token.RotatedAt != nil || token.RevokedAt != nil || !at.Before(token.ExpiresAt) {
+		return false, nil
+	}
+	token.RotatedAt = &at
+	s.tokens[hash] = token
+	return true, nil
+}
+
+func (s *RefreshTokenStore) RevokeFamily(ctx context.Context, family string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, token := range s.tokens {
+		if token.Family == family && token.RevokedAt == nil {
+			token.RevokedAt = &at
+			s.tokens[hash] = token
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package testsupport
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	// The zone must load on machines without zoneinfo too
+	_ "time/tzdata"
+)
+
+// LocalZone is UTC+7, where local-time leaks were first noticed: a
+// timestamp in the server's zone reads seven hours off once it reaches a
+// client treating it as UTC
+const LocalZone = "Asia/Ho_Chi_Minh"
+
+// inLocalZoneEnv marks the child process InLocalZone starts
+const inLocalZoneEnv = "USER_SERVICE_TEST_IN_LOCAL_ZONE"
+
+// InLocalZone makes the calling test run in a process started with
+// TZ=Asia/Ho_Chi_Minh. time.Local is fixed at startup and read by every
+// goroutine, so rather than swapping it the test is re-run in a child
+// process. It returns true in the child, where the test should go on, and
+// false in the parent once the child has passed; a failing child fails
+// the test with its output.
+//
+//	if !testsupport.InLocalZone(t) {
+//		return
+//	}
+func InLocalZone(t *testing.T) bool {
+	t.Helper()
+	if os.Getenv(inLocalZoneEnv) != "" {
+		if name := time.Local.String(); name != LocalZone {
+			t.Fatalf("expected time.Local to be %s, got %s", LocalZone, name)
+		}
+		return true
+	}
+
+	var pattern []string
+	for _, part := range strings.Split(t.Name(), "/") {
+		pattern = append(pattern, "^"+regexp.QuoteMeta(part)+"$")
+	}
+	cmd := exec.Command(os.Args[0], "-test.run="+strings.Join(pattern, "/"), "-test.count=1")
+	cmd.Env = append(os.Environ(), "TZ="+LocalZone, inLocalZoneEnv+"=1")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("test failed with TZ=%s: %v\n%s", LocalZone, err, output)
+	}
+	return false
+}
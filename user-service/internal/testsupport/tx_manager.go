@@ -0,0 +1,86 @@
+package testsupport
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"user-service/internal/application"
+
+	"gorm.io/gorm"
+)
+
+var _ application.TransactionManager = (*TxManager)(nil)
+
+// Snapshotter is implemented by fakes whose writes TxManager can roll back
+type Snapshotter interface {
+	Snapshot() (restore func())
+}
+
+// TxManager is an application.TransactionManager for the in-memory fakes.
+// fn receives a nil *gorm.DB; when it returns an error or panics, every
+// participant is restored to its state from before the transaction.
+// Transactions are not isolated from each other, so tests that exercise
+// concurrent transactions need a real database.
+type TxManager struct {
+	participants []Snapshotter
+
+	mu        sync.Mutex
+	commits   int
+	rollbacks int
+}
+
+// NewTxManager creates a TxManager that rolls back the given fakes
+func NewTxManager(participants ...Snapshotter) *TxManager {
+	return &TxManager{participants: participants}
+}
+
+func (m *TxManager) ExecuteInTx(ctx context.Context, fn func(tx *gorm.DB) error) (err error) {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("failed to begin the transaction: %w", err)
+	}
+
+	restores := make([]func(), len(m.participants))
+	for i, p := range m.participants {
+		restores[i] = p.Snapshot()
+	}
+	rollback := func() {
+		for _, restore := range restores {
+			restore()
+		}
+		m.mu.Lock()
+		m.rollbacks++
+		m.mu.Unlock()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			rollback()
+			panic(r)
+		}
+	}()
+
+	if err := fn(nil); err != nil {
+		rollback()
+		return err
+	}
+
+	m.mu.Lock()
+	m.commits++
+	m.mu.Unlock()
+	return nil
+}
+
+// Commits reports how many transactions completed successfully
+func (m *TxManager) Commits() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.commits
+}
+
+// Rollbacks reports how many transactions were rolled back
+func (m *TxManager) Rollbacks() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rollbacks
+}
@@ -0,0 +1,73 @@
+// internal/testsupport/tx_manager_test.go
+package testsupport
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"user-service/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+func TestTxManager_RollsBackRepositoryWrites(t *testing.T) {
+	repo := NewUserRepository()
+	tm := NewTxManager(repo)
+	ctx := context.Background()
+	existing := repo.AddUser("alice@example.com", "secret123")
+
+	boom := errors.New("boom")
+	err := tm.ExecuteInTx(ctx, func(tx *gorm.DB) error {
+		txRepo := repo.WithTx(tx)
+		if err := txRepo.Create(ctx, &domain.User{Email: "bob@example.com"}, "hash"); err != nil {
+			return err
+		}
+		if err := txRepo.UpdateFields(ctx, existing.ID, map[string]interface{}{"first_name": "Alice"}); err != nil {
+			return err
+		}
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+
+	if exists, _ := repo.ExistsEmail(ctx, "bob@example.com"); exists {
+		t.Error("insert should have been rolled back")
+	}
+	if got, _ := repo.User(existing.ID); got.FirstName != "" {
+		t.Errorf("update should have been rolled back, got %q", got.FirstName)
+	}
+
+	err = tm.ExecuteInTx(ctx, func(tx *gorm.DB) error {
+		return repo.WithTx(tx).Create(ctx, &domain.User{Email: "bob@example.com"}, "hash")
+	})
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if tm.Commits() != 1 || tm.Rollbacks() != 1 {
+		t.Errorf("expected 1 commit and 1 rollback, got %d and %d", tm.Commits(), tm.Rollbacks())
+	}
+}
+
+func TestTxManager_RollsBackOnPanic(t *testing.T) {
+	repo := NewUserRepository()
+	tm := NewTxManager(repo)
+	ctx := context.Background()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic to propagate")
+			}
+		}()
+		_ = tm.ExecuteInTx(ctx, func(tx *gorm.DB) error {
+			_ = repo.Create(ctx, &domain.User{Email: "bob@example.com"}, "hash")
+			panic("boom")
+		})
+	}()
+
+	if exists, _ := repo.ExistsEmail(ctx, "bob@example.com"); exists {
+		t.Error("insert should have been rolled back after panic")
+	}
+}
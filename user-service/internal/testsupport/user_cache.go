@@ -0,0 +1,232 @@
+package testsupport
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+)
+
+var _ application.UserCache = (*UserCache)(nil)
+var _ application.WriteMarker = (*UserCache)(nil)
+
+var (
+	// ErrCacheMiss is returned by UserCache reads for absent keys
+	ErrCacheMiss = errors.New("testsupport: cache miss")
+	// ErrCacheUnavailable is returned by UserCache.Delete while FailDeletes > 0
+	ErrCacheUnavailable = errors.New("testsupport: cache unavailable")
+)
+
+// UserCache is an in-memory application.UserCache that records every call
+type UserCache struct {
+	// FailDeletes makes the next N Delete calls fail
+	FailDeletes int
+	// GetDelay slows Get down; it gives up when the context is done
+	GetDelay time.Duration
+
+	mu            sync.Mutex
+	users         map[uint]*domain.User
+	byEmail       map[string]*domain.User
+	ttls          map[uint]time.Duration
+	written       map[uint]bool
+	calls         []string
+	deletedIDs    []uint
+	deletedEmails []string
+}
+
+func NewUserCache() *UserCache {
+	return &UserCache{
+		users:   make(map[uint]*domain.User),
+		byEmail: make(map[string]*domain.User),
+		ttls:    make(map[uint]time.Duration),
+		written: make(map[uint]bool),
+	}
+}
+
+func (c *UserCache) record(method string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, method)
+}
+
+// Calls returns the invoked method names in order
+func (c *UserCache) Calls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.calls...)
+}
+
+// DeletedIDs returns the IDs passed to successful Delete calls
+func (c *UserCache) DeletedIDs() []uint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]uint(nil), c.deletedIDs...)
+}
+
+// DeletedEmails returns the emails passed to DeleteByEmail
+func (c *UserCache) DeletedEmails() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.deletedEmails...)
+}
+
+// Cached returns a copy of the user cached under id
+func (c *UserCache) Cached(id uint) (*domain.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u, ok := c.users[id]
+	if !ok {
+		return nil, false
+	}
+	cp := *u
+	return &cp, true
+}
+
+// CachedByEmail returns a copy of the user cached under email
+func (c *UserCache) CachedByEmail(email string) (*domain.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	u, ok := c.byEmail[email]
+	if !ok {
+		return nil, false
+	}
+	cp := *u
+	return &cp, true
+}
+
+func (c *UserCache) Set(ctx context.Context, user *domain.User) error {
+	c.record("Set")
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cp := *user
+	c.users[user.ID] = &cp
+	delete(c.ttls, user.ID)
+	return nil
+}
+
+func (c *UserCache) SetWithTTL(ctx context.Context, user *domain.User, ttl time.Duration) error {
+	c.record("SetWithTTL")
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cp := *user
+	c.users[user.ID] = &cp
+	c.ttls[user.ID] = ttl
+	return nil
+}
+
+// TTL returns the TTL the user was cached with by SetWithTTL, or zero for
+// the default one
+func (c *UserCache) TTL(id uint) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ttls[id]
+}
+
+func (c *UserCache) Get(ctx context.Context, userID uint) (*domain.User, error) {
+	c.record("Get")
+	if c.GetDelay > 0 {
+		select {
+		case <-time.After(c.GetDelay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if u, ok := c.users[userID]; ok {
+		cp := *u
+		return &cp, nil
+	}
+	return nil, ErrCacheMiss
+}
+
+func (c *UserCache) GetMany(ctx context.Context, userIDs []uint) (map[uint]*domain.User, error) {
+	c.record("GetMany")
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	users := make(map[uint]*domain.User)
+	for _, id := range userIDs {
+		if u, ok := c.users[id]; ok {
+			cp := *u
+			users[id] = &cp
+		}
+	}
+	return users, nil
+}
+
+func (c *UserCache) Delete(ctx context.Context, userID uint) error {
+	c.record("Delete")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.FailDeletes > 0 {
+		c.FailDeletes--
+		return ErrCacheUnavailable
+	}
+	delete(c.users, userID)
+	c.deletedIDs = append(c.deletedIDs, userID)
+	return nil
+}
+
+func (c *UserCache) SetByEmail(ctx context.Context, email string, user *domain.User) error {
+	c.record("SetByEmail")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cp := *user
+	c.byEmail[email] = &cp
+	return nil
+}
+
+func (c *UserCache) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	c.record("GetByEmail")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if u, ok := c.byEmail[email]; ok {
+		cp := *u
+		return &cp, nil
+	}
+	return nil, ErrCacheMiss
+}
+
+func (c *UserCache) DeleteByEmail(ctx context.Context, email string) error {
+	c.record("DeleteByEmail")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.byEmail, email)
+	c.deletedEmails = append(c.deletedEmails, email)
+	return nil
+}
+
+// MarkWritten marks the user until ExpireWrites; nothing expires on its own
+func (c *UserCache) MarkWritten(ctx context.Context, userID uint) error {
+	c.record("MarkWritten")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written[userID] = true
+	return nil
+}
+
+func (c *UserCache) WrittenRecently(ctx context.Context, userID uint) (bool, error) {
+	c.record("WrittenRecently")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.written[userID], nil
+}
+
+// ExpireWrites drops every write marker, as if the window had passed
+func (c *UserCache) ExpireWrites() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written = make(map[uint]bool)
+}
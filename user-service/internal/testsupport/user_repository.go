@@ -0,0 +1,732 @@
+package testsupport
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var _ application.UserRepository = (*UserRepository)(nil)
+var _ application.LastLoginStore = (*UserRepository)(nil)
+var _ Snapshotter = (*UserRepository)(nil)
+
+// UserRepository is an in-memory application.UserRepository that follows
+// the Postgres repository's contract: emails are unique across live and
+// soft deleted rows, missing rows yield domain.ErrUserNotFound and List is
+// newest first. Stored users are copied in and out, so callers can't
+// mutate them behind the repository's back.
+type UserRepository struct {
+	// ReadDelay is applied to every call except UpdateFields and gives up
+	// when the context is done, like a query run through gorm's WithContext
+	ReadDelay time.Duration
+	// WriteDelay is applied to UpdateFields and always runs to completion,
+	// like a write the database finishes after the client stops waiting
+	WriteDelay time.Duration
+
+	mu         sync.Mutex
+	users      map[uint]*domain.User
+	deleted    map[uint]*domain.User
+	lastLogins map[uint]time.Time
+	// passwords holds each user's hash, which domain.User doesn't carry
+	passwords map[uint]string
+	nextID    uint
+	calls     map[string]int
+}
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{
+		users:      make(map[uint]*domain.User),
+		deleted:    make(map[uint]*domain.User),
+		lastLogins: make(map[uint]time.Time),
+		passwords:  make(map[uint]string),
+		nextID:     1,
+		calls:      make(map[string]int),
+	}
+}
+
+// AddUser stores an active user with a bcrypt hash of password and returns it
+func (r *UserRepository) AddUser(email, password string) *domain.User {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	u := &domain.User{Username: "user", Email: email, Status: domain.StatusActive}
+	if err := r.Create(context.Background(), u, string(hash)); err != nil {
+		panic("testsupport: AddUser: " + err.Error())
+	}
+	return u
+}
+
+// Put stores user as-is, replacing any row with the same ID. A user with
+// DeletedAt set is stored as a soft deleted row.
+func (r *UserRepository) Put(user *domain.User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *user
+	if cp.DeletedAt.Valid {
+		r.deleted[user.ID] = &cp
+		delete(r.users, user.ID)
+	} else {
+		r.users[user.ID] = &cp
+		delete(r.deleted, user.ID)
+	}
+	if user.ID >= r.nextID {
+		r.nextID = user.ID + 1
+	}
+}
+
+// User returns a copy of the live row with the given ID
+func (r *UserRepository) User(id uint) (*domain.User, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return nil, false
+	}
+	cp := *u
+	return &cp, true
+}
+
+// PasswordHash returns the stored hash of user id's password
+func (r *UserRepository) PasswordHash(id uint) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.passwords[id]
+}
+
+// DeletedUser returns a copy of a soft deleted row
+func (r *UserRepository) DeletedUser(id uint) (*domain.User, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.deleted[id]
+	if !ok {
+		return nil, false
+	}
+	cp := *u
+	return &cp, true
+}
+
+// LastLogin returns the timestamp most recently written by UpdateLastLogins
+func (r *UserRepository) LastLogin(id uint) (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	at, ok := r.lastLogins[id]
+	return at, ok
+}
+
+// Calls reports how many times method was invoked
+func (r *UserRepository) Calls(method string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[method]
+}
+
+// Snapshot captures the stored rows; calling restore puts them back
+func (r *UserRepository) Snapshot() (restore func()) {
+	r.mu.Lock()
+	users, deleted := copyUsers(r.users), copyUsers(r.deleted)
+	lastLogins := make(map[uint]time.Time, len(r.lastLogins))
+	for id, at := range r.lastLogins {
+		lastLogins[id] = at
+	}
+	passwords := make(map[uint]string, len(r.passwords))
+	for id, hash := range r.passwords {
+		passwords[id] = hash
+	}
+	nextID := r.nextID
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.users, r.deleted, r.lastLogins, r.passwords, r.nextID = users, deleted, lastLogins, passwords, nextID
+	}
+}
+
+func copyUsers(src map[uint]*domain.User) map[uint]*domain.User {
+	dst := make(map[uint]*domain.User, len(src))
+	for id, u := range src {
+		cp := *u
+		dst[id] = &cp
+	}
+	return dst
+}
+
+// begin records the call and applies ReadDelay
+func (r *UserRepository) begin(ctx context.Context, method string) error {
+	r.mu.Lock()
+	r.calls[method]++
+	r.mu.Unlock()
+
+	if r.ReadDelay > 0 {
+		select {
+		case <-time.After(r.ReadDelay):
+		case <-ctx.Done():
+		}
+	}
+	return ctx.Err()
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *domain.User, passwordHash string) error {
+	if err := r.begin(ctx, "Create"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.emailTaken(user.Email) {
+		return domain.ErrDuplicateUser
+	}
+
+	now := time.Now().UTC()
+	user.ID = r.nextID
+	r.nextID++
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now
+	}
+	user.UpdatedAt = now
+	if user.Status == "" {
+		user.Status = domain.StatusActive
+	}
+	if user.Role == "" {
+		user.Role = domain.RoleUser
+	}
+
+	cp := *user
+	r.users[user.ID] = &cp
+	r.passwords[user.ID] = passwordHash
+	return nil
+}
+
+// emailTaken mirrors the unique index, which soft deletes don't release
+func (r *UserRepository) emailTaken(email string) bool {
+	return r.emailTakenByOther(0, email)
+}
+
+func (r *UserRepository) emailTakenByOther(id uint, email string) bool {
+	for _, rows := range []map[uint]*domain.User{r.users, r.deleted} {
+		for _, u := range rows {
+			if u.ID != id && u.Email == email {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LockConflicts only reports conflicts; like TxManager, the fake doesn't
+// isolate concurrent transactions
+func (r *UserRepository) LockConflicts(ctx context.Context, id uint, username, email string) ([]string, error) {
+	if err := r.begin(ctx, "LockConflicts"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var taken []string
+	if username != "" {
+		for _, u := range r.users {
+			if u.ID != id && strings.EqualFold(u.Username, username) {
+				taken = append(taken, "username")
+				break
+			}
+		}
+	}
+	if email != "" && r.emailTakenByOther(id, email) {
+		taken = append(taken, "email")
+	}
+	return taken, nil
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
+	if err := r.begin(ctx, "GetByEmail"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Email == email {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	return nil, domain.ErrUserNotFound
+}
+
+func (r *UserRepository) GetByEmailUnscoped(ctx context.Context, email string) (*domain.User, error) {
+	if err := r.begin(ctx, "GetByEmailUnscoped"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Email == email {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	for _, u := range r.deleted {
+		if u.Email == email {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	return nil, domain.ErrUserNotFound
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id uint) (*domain.User, error) {
+	if err := r.begin(ctx, "GetByID"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[id]; ok {
+		cp := *u
+		return &cp, nil
+	}
+	return nil, domain.ErrUserNotFound
+}
+
+func (r *UserRepository) GetByIDUnscoped(ctx context.Context, id uint) (*domain.User, error) {
+	if err := r.begin(ctx, "GetByIDUnscoped"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		u, ok = r.deleted[id]
+	}
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	cp := *u
+	return &cp, nil
+}
+
+func (r *UserRepository) GetCredentials(ctx context.Context, id uint) (*domain.Credentials, error) {
+	if err := r.begin(ctx, "GetCredentials"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[id]; !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return &domain.Credentials{UserID: id, PasswordHash: r.passwords[id]}, nil
+}
+
+func (r *UserRepository) GetByIDs(ctx context.Context, ids []uint) ([]*domain.User, error) {
+	if err := r.begin(ctx, "GetByIDs"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []*domain.User
+	for _, id := range ids {
+		if u, ok := r.users[id]; ok {
+			cp := *u
+			users = append(users, &cp)
+		}
+	}
+	return users, nil
+}
+
+func (r *UserRepository) Update(ctx context.Context, user *domain.User) error {
+	if err := r.begin(ctx, "Update"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.emailTakenByOther(user.ID, user.Email) {
+		return domain.ErrDuplicateUser
+	}
+	user.UpdatedAt = time.Now().UTC()
+	cp := *user
+	// Like the postgres repository, Update leaves the token version alone
+	if stored, ok := r.users[user.ID]; ok {
+		cp.TokenVersion = stored.TokenVersion
+	}
+	r.users[user.ID] = &cp
+	return nil
+}
+
+func (r *UserRepository) UpdateFields(ctx context.Context, id uint, fields map[string]interface{}) error {
+	r.mu.Lock()
+	r.calls["UpdateFields"]++
+	r.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if r.WriteDelay > 0 {
+		time.Sleep(r.WriteDelay)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	if email, ok := fields["email"].(string); ok && r.emailTakenByOther(id, email) {
+		return domain.ErrDuplicateUser
+	}
+	r.applyFields(u, fields)
+	return nil
+}
+
+func (r *UserRepository) UpdateFieldsIfStatus(ctx context.Context, id uint, status domain.UserStatus, fields map[string]interface{}) (bool, error) {
+	if err := r.begin(ctx, "UpdateFieldsIfStatus"); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok || u.Status != status {
+		return false, nil
+	}
+	r.applyFields(u, fields)
+	return true, nil
+}
+
+// applyFields mirrors the columns the service writes through UpdateFields.
+// A nil value clears a timestamp column.
+func (r *UserRepository) applyFields(u *domain.User, fields map[string]interface{}) {
+	for column, value := range fields {
+		switch column {
+		case "status":
+			u.Status = domain.UserStatus(value.(string))
+		case "role":
+			u.Role = domain.Role(value.(string))
+		case "username":
+			u.Username = value.(string)
+		case "email":
+			u.Email = value.(string)
+		case "password":
+			r.passwords[u.ID] = value.(string)
+		case "first_name":
+			u.FirstName = value.(string)
+		case "last_name":
+			u.LastName = value.(string)
+		case "mute_security_alerts":
+			u.Notifications.SecurityAlertsMuted = value.(bool)
+		case "notify_marketing":
+			u.Notifications.Marketing = value.(bool)
+		case "notify_product_updates":
+			u.Notifications.ProductUpdates = value.(bool)
+		case "preferences":
+			u.Preferences = value.(domain.Preferences)
+		case "must_reset_password":
+			u.MustResetPassword = value.(bool)
+		case "token_version":
+			u.TokenVersion = value.(uint)
+		case "last_login", "email_verified_at", "deletion_requested_at":
+			var at *time.Time
+			if v, ok := value.(time.Time); ok {
+				at = &v
+			}
+			switch column {
+			case "last_login":
+				u.LastLogin = at
+			case "email_verified_at":
+				u.EmailVerifiedAt = at
+			default:
+				u.DeletionRequestedAt = at
+			}
+		default:
+			panic("testsupport: UpdateFields: unknown column " + column)
+		}
+	}
+	u.UpdatedAt = time.Now().UTC()
+}
+
+func (r *UserRepository) UpdateLastLogins(ctx context.Context, logins map[uint]time.Time) error {
+	if err := r.begin(ctx, "UpdateLastLogins"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, at := range logins {
+		r.lastLogins[id] = at
+		if u, ok := r.users[id]; ok {
+			v := at
+			u.LastLogin = &v
+		}
+	}
+	return nil
+}
+
+func (r *UserRepository) SoftDelete(ctx context.Context, id uint) error {
+	if err := r.begin(ctx, "SoftDelete"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	u.DeletedAt = gorm.DeletedAt{Time: time.Now().UTC(), Valid: true}
+	r.deleted[id] = u
+	delete(r.users, id)
+	return nil
+}
+
+func (r *UserRepository) HardDelete(ctx context.Context, id uint) error {
+	if err := r.begin(ctx, "HardDelete"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, live := r.users[id]
+	_, deleted := r.deleted[id]
+	if !live && !deleted {
+		return domain.ErrUserNotFound
+	}
+	delete(r.users, id)
+	delete(r.deleted, id)
+	delete(r.passwords, id)
+	delete(r.lastLogins, id)
+	return nil
+}
+
+func (r *UserRepository) Restore(ctx context.Context, id uint) error {
+	if err := r.begin(ctx, "Restore"); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.deleted[id]
+	if !ok {
+		return domain.ErrUserNotFound
+	}
+	u.DeletedAt = gorm.DeletedAt{}
+	r.users[id] = u
+	delete(r.deleted, id)
+	return nil
+}
+
+func (r *UserRepository) Exists(ctx context.Context, id uint) (bool, error) {
+	if err := r.begin(ctx, "Exists"); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	return ok && u.Status != domain.StatusErased, nil
+}
+
+func (r *UserRepository) ExistsEmail(ctx context.Context, email string) (bool, error) {
+	if err := r.begin(ctx, "ExistsEmail"); err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Email == email {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *UserRepository) List(ctx context.Context, filter domain.UserFilter, offset, limit int, by domain.UserSort) ([]*domain.User, int64, error) {
+	if err := r.begin(ctx, "List"); err != nil {
+		return nil, 0, err
+	}
+	return r.page(filter, offset, limit, by, false)
+}
+
+func (r *UserRepository) ListWithDeleted(ctx context.Context, filter domain.UserFilter, offset, limit int, by domain.UserSort) ([]*domain.User, int64, error) {
+	if err := r.begin(ctx, "ListWithDeleted"); err != nil {
+		return nil, 0, err
+	}
+	return r.page(filter, offset, limit, by, true)
+}
+
+// page returns the users filter keeps, ordered by by, from offset, and how
+// many it keeps in all
+func (r *UserRepository) page(filter domain.UserFilter, offset, limit int, by domain.UserSort, withDeleted bool) ([]*domain.User, int64, error) {
+	if !by.Field.Valid() || !by.Order.Valid() {
+		return nil, 0, fmt.Errorf("testsupport: invalid sort %+v", by)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	candidates := make([]*domain.User, 0, len(r.users)+len(r.deleted))
+	for _, u := range r.users {
+		candidates = append(candidates, u)
+	}
+	if withDeleted {
+		for _, u := range r.deleted {
+			candidates = append(candidates, u)
+		}
+	}
+	users := make([]*domain.User, 0, len(candidates))
+	for _, u := range candidates {
+		if !keeps(filter, u) {
+			continue
+		}
+		cp := *u
+		users = append(users, &cp)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return sortsBefore(users[i], users[j], by)
+	})
+
+	total := int64(len(users))
+	if offset >= len(users) {
+		return []*domain.User{}, total, nil
+	}
+	users = users[offset:]
+	if limit >= 0 && len(users) > limit {
+		users = users[:limit]
+	}
+	return users, total, nil
+}
+
+// keeps reports whether filter keeps u
+func keeps(filter domain.UserFilter, u *domain.User) bool {
+	if !hasStatus(u, filter.Statuses) {
+		return false
+	}
+	if query := strings.ToLower(filter.Query); query != "" &&
+		!strings.Contains(strings.ToLower(u.Username), query) && !strings.Contains(strings.ToLower(u.Email), query) {
+		return false
+	}
+	if !filter.CreatedAfter.IsZero() && u.CreatedAt.Before(filter.CreatedAfter) {
+		return false
+	}
+	if !filter.CreatedBefore.IsZero() && !u.CreatedAt.Before(filter.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// sortsBefore orders users as the Postgres repository does: ties broken
+// by ID in the same direction, and users who never logged in last
+func sortsBefore(a, b *domain.User, by domain.UserSort) bool {
+	var c int
+	switch by.Field {
+	case domain.SortByCreatedAt:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	case domain.SortByUsername:
+		c = strings.Compare(a.Username, b.Username)
+	case domain.SortByEmail:
+		c = strings.Compare(a.Email, b.Email)
+	case domain.SortByLastLogin:
+		switch {
+		case a.LastLogin == nil && b.LastLogin == nil:
+		case a.LastLogin == nil:
+			return false
+		case b.LastLogin == nil:
+			return true
+		default:
+			c = a.LastLogin.Compare(*b.LastLogin)
+		}
+	}
+	if c == 0 {
+		c = cmp.Compare(a.ID, b.ID)
+	}
+	if by.Order == domain.SortDescending {
+		return c > 0
+	}
+	return c < 0
+}
+
+func (r *UserRepository) ListPendingDeletion(ctx context.Context, requestedBefore time.Time, limit int) ([]*domain.User, error) {
+	if err := r.begin(ctx, "ListPendingDeletion"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []*domain.User
+	for _, u := range r.users {
+		if u.IsPendingDeletion() && u.DeletionRequestedAt != nil && !u.DeletionRequestedAt.After(requestedBefore) {
+			cp := *u
+			users = append(users, &cp)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].DeletionRequestedAt.Before(*users[j].DeletionRequestedAt)
+	})
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r *UserRepository) PagePendingDeletion(ctx context.Context, offset, limit int) ([]*domain.User, int64, error) {
+	if err := r.begin(ctx, "PagePendingDeletion"); err != nil {
+		return nil, 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []*domain.User
+	for _, u := range r.users {
+		if u.IsPendingDeletion() && u.DeletionRequestedAt != nil {
+			cp := *u
+			users = append(users, &cp)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].DeletionRequestedAt.Before(*users[j].DeletionRequestedAt)
+	})
+	total := int64(len(users))
+	if offset >= len(users) {
+		return nil, total, nil
+	}
+	users = users[offset:]
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, total, nil
+}
+
+func (r *UserRepository) SearchByUsername(ctx context.Context, prefix string, afterID uint, limit int, statuses []domain.UserStatus) ([]*domain.User, error) {
+	if err := r.begin(ctx, "SearchByUsername"); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prefix = strings.ToLower(prefix)
+	var users []*domain.User
+	for _, u := range r.users {
+		if u.ID > afterID && u.Status != domain.StatusErased && hasStatus(u, statuses) &&
+			strings.HasPrefix(strings.ToLower(u.Username), prefix) {
+			cp := *u
+			users = append(users, &cp)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+// hasStatus mirrors the repository status filter: no statuses matches all
+func hasStatus(u *domain.User, statuses []domain.UserStatus) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, s := range statuses {
+		if u.Status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// WithTx returns the repository itself; TxManager provides rollback by
+// restoring a snapshot when the transaction fails
+func (r *UserRepository) WithTx(tx *gorm.DB) application.UserRepository {
+	return r
+}
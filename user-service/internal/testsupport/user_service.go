@@ -0,0 +1,500 @@
+package testsupport
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"user-service/internal/application"
+	"user-service/internal/domain"
+)
+
+var _ application.UserServiceInterface = (*MockUserService)(nil)
+
+// ErrNotConfigured is returned by mock methods that have no stub set.
+var ErrNotConfigured = errors.New("testsupport: method not configured")
+
+// MockUserService is a hand-written mock of application.UserServiceInterface.
+// Set the *Fn fields the test cares about; Calls records invoked method names.
+type MockUserService struct {
+	RegisterFn   func(ctx context.Context, user *domain.User, password, inviteCode string) (bool, error)
+	LoginFn      func(ctx context.Context, email, password string) (*domain.User, error)
+	GetUserFn    func(ctx context.Context, id uint) (*domain.User, error)
+	UserExistsFn func(ctx context.Context, id uint) (bool, error)
+	UpdateUserFn func(ctx context.Context, user *domain.User) ([]string, error)
+	DeleteUserFn func(ctx context.Context, id uint) error
+	// DeleteUserWithPasswordFn backs DELETE /users/delete
+	DeleteUserWithPasswordFn func(ctx context.Context, id uint, password string) error
+	UpdateProfileFn          func(ctx context.Context, id uint, fields map[string]interface{}) (*domain.User, []string, error)
+	ListUsersFn              func(ctx context.Context, page, pageSize int, opts application.ListUsersOptions) ([]*domain.User, int64, error)
+
+	ValidateRegistrationFn func(ctx context.Context, user *domain.User, password string) error
+	LookupByEmailFn        func(ctx context.Context, email, caller string) (*application.EmailLookup, error)
+
+	ListPendingDeletionsFn func(ctx context.Context, page, pageSize int) ([]*application.PendingDeletion, int64, error)
+	CancelDeletionFn       func(ctx context.Context, id uint, actorID uint, reason string) error
+	ExpediteDeletionFn     func(ctx context.Context, id uint, actorID uint, reason string) error
+	GetUserUnscopedFn      func(ctx context.Context, id uint) (*domain.User, error)
+	PurgeUserFn            func(ctx context.Context, id uint, confirmEmail, reason string, actorID uint) error
+	RestoreUserFn          func(ctx context.Context, id uint, reason string, actorID uint) (*domain.User, error)
+
+	UpdateNotificationPreferencesFn func(ctx context.Context, id uint, update application.NotificationPreferencesUpdate) (*domain.NotificationPreferences, error)
+	UpdatePreferencesFn             func(ctx context.Context, id uint, patch map[string]interface{}) (domain.Preferences, error)
+
+	CreateInviteFn func(ctx context.Context, invite *domain.Invite) error
+	RevokeInviteFn func(ctx context.Context, code, reason string) error
+
+	GenerateRecoveryCodesFn  func(ctx context.Context, id uint, password string) ([]string, error)
+	RecoverFn                func(ctx context.Context, email, code string) (*domain.User, error)
+	RecoveryCodesRemainingFn func(ctx context.Context, id uint) (int, error)
+	ForgotPasswordFn         func(ctx context.Context, email string) error
+	ResetForgottenPasswordFn func(ctx context.Context, token, password string) error
+	RequestEmailChangeFn     func(ctx context.Context, id uint, change application.EmailChange) error
+	ConfirmEmailChangeFn     func(ctx context.Context, token string) (*domain.User, error)
+	ChangePasswordFn         func(ctx context.Context, id uint, change application.PasswordChange) error
+
+	CreateAccessTokenFn func(ctx context.Context, token *domain.AccessToken) (string, error)
+	ListAccessTokensFn  func(ctx context.Context, userID uint) ([]*domain.AccessToken, error)
+	RevokeAccessTokenFn func(ctx context.Context, userID, id uint) error
+
+	ForcePasswordResetFn func(ctx context.Context, ids []uint, reason string, actorID uint) (*application.ForcedResets, error)
+
+	StartBulkJobFn     func(ctx context.Context, job *domain.BulkJob) error
+	GetBulkJobFn       func(ctx context.Context, id uint) (*domain.BulkJob, error)
+	BulkJobReportURLFn func(ctx context.Context, job *domain.BulkJob) (string, error)
+
+	NoticesFn       func(ctx context.Context, user *domain.User) ([]domain.Notice, error)
+	DismissNoticeFn func(ctx context.Context, userID uint, code string) error
+	AddNoticeFn     func(ctx context.Context, notice *domain.UserNotice) error
+	RemoveNoticeFn  func(ctx context.Context, userID uint, code, removedBy string) error
+
+	ExportSnapshotFn func(ctx context.Context, id uint, reason string) (*application.SignedSnapshot, error)
+	ImportSnapshotFn func(ctx context.Context, bundle *application.SignedSnapshot, overwrite bool, reason string) (*application.SnapshotImport, error)
+
+	// TokenVersionFn defaults to every user being at version 0, since
+	// issuing any token asks for it
+	TokenVersionFn func(ctx context.Context, userID uint) (uint, error)
+	LogoutAllFn    func(ctx context.Context, id uint) error
+
+	// StartSessionFn defaults to application.ErrSessionsNotConfigured,
+	// which logins carry on without; UseSessionFn and SessionActiveFn
+	// default to every session being live
+	StartSessionFn  func(ctx context.Context, userID uint) (*domain.Session, error)
+	ListSessionsFn  func(ctx context.Context, userID uint) ([]*domain.Session, error)
+	RevokeSessionFn func(ctx context.Context, userID uint, id string) error
+	UseSessionFn    func(ctx context.Context, userID uint, id string) error
+	SessionActiveFn func(ctx context.Context, id string) (bool, error)
+
+	mu    sync.Mutex
+	Calls []string
+}
+
+func (m *MockUserService) record(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Calls = append(m.Calls, name)
+}
+
+// Called reports whether the named method was invoked at least once
+func (m *MockUserService) Called(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.Calls {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MockUserService) Register(ctx context.Context, user *domain.User, password, inviteCode string) (bool, error) {
+	m.record("Register")
+	if m.RegisterFn == nil {
+		return false, ErrNotConfigured
+	}
+	return m.RegisterFn(ctx, user, password, inviteCode)
+}
+
+func (m *MockUserService) Login(ctx context.Context, email, password string) (*domain.User, error) {
+	m.record("Login")
+	if m.LoginFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.LoginFn(ctx, email, password)
+}
+
+func (m *MockUserService) GetUser(ctx context.Context, id uint) (*domain.User, error) {
+	m.record("GetUser")
+	if m.GetUserFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetUserFn(ctx, id)
+}
+
+func (m *MockUserService) UserExists(ctx context.Context, id uint) (bool, error) {
+	m.record("UserExists")
+	if m.UserExistsFn == nil {
+		return false, ErrNotConfigured
+	}
+	return m.UserExistsFn(ctx, id)
+}
+
+func (m *MockUserService) UpdateUser(ctx context.Context, user *domain.User) ([]string, error) {
+	m.record("UpdateUser")
+	if m.UpdateUserFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.UpdateUserFn(ctx, user)
+}
+
+func (m *MockUserService) DeleteUserWithPassword(ctx context.Context, id uint, password string) error {
+	m.record("DeleteUserWithPassword")
+	if m.DeleteUserWithPasswordFn == nil {
+		return ErrNotConfigured
+	}
+	return m.DeleteUserWithPasswordFn(ctx, id, password)
+}
+
+func (m *MockUserService) DeleteUser(ctx context.Context, id uint) error {
+	m.record("DeleteUser")
+	if m.DeleteUserFn == nil {
+		return ErrNotConfigured
+	}
+	return m.DeleteUserFn(ctx, id)
+}
+
+func (m *MockUserService) UpdateProfile(ctx context.Context, id uint, fields map[string]interface{}) (*domain.User, []string, error) {
+	m.record("UpdateProfile")
+	if m.UpdateProfileFn == nil {
+		return nil, nil, ErrNotConfigured
+	}
+	return m.UpdateProfileFn(ctx, id, fields)
+}
+
+func (m *MockUserService) ListUsers(ctx context.Context, page, pageSize int, opts application.ListUsersOptions) ([]*domain.User, int64, error) {
+	m.record("ListUsers")
+	if m.ListUsersFn == nil {
+		return nil, 0, ErrNotConfigured
+	}
+	return m.ListUsersFn(ctx, page, pageSize, opts)
+}
+
+func (m *MockUserService) ValidateRegistration(ctx context.Context, user *domain.User, password string) error {
+	m.record("ValidateRegistration")
+	if m.ValidateRegistrationFn == nil {
+		return ErrNotConfigured
+	}
+	return m.ValidateRegistrationFn(ctx, user, password)
+}
+
+func (m *MockUserService) LookupByEmail(ctx context.Context, email, caller string) (*application.EmailLookup, error) {
+	m.record("LookupByEmail")
+	if m.LookupByEmailFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.LookupByEmailFn(ctx, email, caller)
+}
+
+func (m *MockUserService) ListPendingDeletions(ctx context.Context, page, pageSize int) ([]*application.PendingDeletion, int64, error) {
+	m.record("ListPendingDeletions")
+	if m.ListPendingDeletionsFn == nil {
+		return nil, 0, ErrNotConfigured
+	}
+	return m.ListPendingDeletionsFn(ctx, page, pageSize)
+}
+
+func (m *MockUserService) CancelDeletion(ctx context.Context, id uint, actorID uint, reason string) error {
+	m.record("CancelDeletion")
+	if m.CancelDeletionFn == nil {
+		return ErrNotConfigured
+	}
+	return m.CancelDeletionFn(ctx, id, actorID, reason)
+}
+
+func (m *MockUserService) ExpediteDeletion(ctx context.Context, id uint, actorID uint, reason string) error {
+	m.record("ExpediteDeletion")
+	if m.ExpediteDeletionFn == nil {
+		return ErrNotConfigured
+	}
+	return m.ExpediteDeletionFn(ctx, id, actorID, reason)
+}
+
+func (m *MockUserService) GetUserUnscoped(ctx context.Context, id uint) (*domain.User, error) {
+	m.record("GetUserUnscoped")
+	if m.GetUserUnscopedFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetUserUnscopedFn(ctx, id)
+}
+
+func (m *MockUserService) RestoreUser(ctx context.Context, id uint, reason string, actorID uint) (*domain.User, error) {
+	m.record("RestoreUser")
+	if m.RestoreUserFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.RestoreUserFn(ctx, id, reason, actorID)
+}
+
+func (m *MockUserService) PurgeUser(ctx context.Context, id uint, confirmEmail, reason string, actorID uint) error {
+	m.record("PurgeUser")
+	if m.PurgeUserFn == nil {
+		return ErrNotConfigured
+	}
+	return m.PurgeUserFn(ctx, id, confirmEmail, reason, actorID)
+}
+
+func (m *MockUserService) UpdateNotificationPreferences(ctx context.Context, id uint, update application.NotificationPreferencesUpdate) (*domain.NotificationPreferences, error) {
+	m.record("UpdateNotificationPreferences")
+	if m.UpdateNotificationPreferencesFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.UpdateNotificationPreferencesFn(ctx, id, update)
+}
+
+func (m *MockUserService) UpdatePreferences(ctx context.Context, id uint, patch map[string]interface{}) (domain.Preferences, error) {
+	m.record("UpdatePreferences")
+	if m.UpdatePreferencesFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.UpdatePreferencesFn(ctx, id, patch)
+}
+
+func (m *MockUserService) CreateInvite(ctx context.Context, invite *domain.Invite) error {
+	m.record("CreateInvite")
+	if m.CreateInviteFn == nil {
+		return ErrNotConfigured
+	}
+	return m.CreateInviteFn(ctx, invite)
+}
+
+func (m *MockUserService) RevokeInvite(ctx context.Context, code, reason string) error {
+	m.record("RevokeInvite")
+	if m.RevokeInviteFn == nil {
+		return ErrNotConfigured
+	}
+	return m.RevokeInviteFn(ctx, code, reason)
+}
+
+func (m *MockUserService) GenerateRecoveryCodes(ctx context.Context, id uint, password string) ([]string, error) {
+	m.record("GenerateRecoveryCodes")
+	if m.GenerateRecoveryCodesFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GenerateRecoveryCodesFn(ctx, id, password)
+}
+
+func (m *MockUserService) Recover(ctx context.Context, email, code string) (*domain.User, error) {
+	m.record("Recover")
+	if m.RecoverFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.RecoverFn(ctx, email, code)
+}
+
+func (m *MockUserService) RecoveryCodesRemaining(ctx context.Context, id uint) (int, error) {
+	m.record("RecoveryCodesRemaining")
+	if m.RecoveryCodesRemainingFn == nil {
+		return 0, ErrNotConfigured
+	}
+	return m.RecoveryCodesRemainingFn(ctx, id)
+}
+
+func (m *MockUserService) ForgotPassword(ctx context.Context, email string) error {
+	m.record("ForgotPassword")
+	if m.ForgotPasswordFn == nil {
+		return ErrNotConfigured
+	}
+	return m.ForgotPasswordFn(ctx, email)
+}
+
+func (m *MockUserService) ResetForgottenPassword(ctx context.Context, token, password string) error {
+	m.record("ResetForgottenPassword")
+	if m.ResetForgottenPasswordFn == nil {
+		return ErrNotConfigured
+	}
+	return m.ResetForgottenPasswordFn(ctx, token, password)
+}
+
+func (m *MockUserService) RequestEmailChange(ctx context.Context, id uint, change application.EmailChange) error {
+	m.record("RequestEmailChange")
+	if m.RequestEmailChangeFn == nil {
+		return ErrNotConfigured
+	}
+	return m.RequestEmailChangeFn(ctx, id, change)
+}
+
+func (m *MockUserService) ConfirmEmailChange(ctx context.Context, token string) (*domain.User, error) {
+	m.record("ConfirmEmailChange")
+	if m.ConfirmEmailChangeFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.ConfirmEmailChangeFn(ctx, token)
+}
+
+func (m *MockUserService) CreateAccessToken(ctx context.Context, token *domain.AccessToken) (string, error) {
+	m.record("CreateAccessToken")
+	if m.CreateAccessTokenFn == nil {
+		return "", ErrNotConfigured
+	}
+	return m.CreateAccessTokenFn(ctx, token)
+}
+
+func (m *MockUserService) ListAccessTokens(ctx context.Context, userID uint) ([]*domain.AccessToken, error) {
+	m.record("ListAccessTokens")
+	if m.ListAccessTokensFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.ListAccessTokensFn(ctx, userID)
+}
+
+func (m *MockUserService) RevokeAccessToken(ctx context.Context, userID, id uint) error {
+	m.record("RevokeAccessToken")
+	if m.RevokeAccessTokenFn == nil {
+		return ErrNotConfigured
+	}
+	return m.RevokeAccessTokenFn(ctx, userID, id)
+}
+
+func (m *MockUserService) ChangePassword(ctx context.Context, id uint, change application.PasswordChange) error {
+	m.record("ChangePassword")
+	if m.ChangePasswordFn == nil {
+		return ErrNotConfigured
+	}
+	return m.ChangePasswordFn(ctx, id, change)
+}
+
+func (m *MockUserService) ForcePasswordReset(ctx context.Context, ids []uint, reason string, actorID uint) (*application.ForcedResets, error) {
+	m.record("ForcePasswordReset")
+	if m.ForcePasswordResetFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.ForcePasswordResetFn(ctx, ids, reason, actorID)
+}
+
+func (m *MockUserService) StartBulkJob(ctx context.Context, job *domain.BulkJob) error {
+	m.record("StartBulkJob")
+	if m.StartBulkJobFn == nil {
+		return ErrNotConfigured
+	}
+	return m.StartBulkJobFn(ctx, job)
+}
+
+func (m *MockUserService) GetBulkJob(ctx context.Context, id uint) (*domain.BulkJob, error) {
+	m.record("GetBulkJob")
+	if m.GetBulkJobFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.GetBulkJobFn(ctx, id)
+}
+
+func (m *MockUserService) BulkJobReportURL(ctx context.Context, job *domain.BulkJob) (string, error) {
+	m.record("BulkJobReportURL")
+	if m.BulkJobReportURLFn == nil {
+		return "", ErrNotConfigured
+	}
+	return m.BulkJobReportURLFn(ctx, job)
+}
+
+func (m *MockUserService) Notices(ctx context.Context, user *domain.User) ([]domain.Notice, error) {
+	m.record("Notices")
+	if m.NoticesFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.NoticesFn(ctx, user)
+}
+
+func (m *MockUserService) DismissNotice(ctx context.Context, userID uint, code string) error {
+	m.record("DismissNotice")
+	if m.DismissNoticeFn == nil {
+		return ErrNotConfigured
+	}
+	return m.DismissNoticeFn(ctx, userID, code)
+}
+
+func (m *MockUserService) AddNotice(ctx context.Context, notice *domain.UserNotice) error {
+	m.record("AddNotice")
+	if m.AddNoticeFn == nil {
+		return ErrNotConfigured
+	}
+	return m.AddNoticeFn(ctx, notice)
+}
+
+func (m *MockUserService) RemoveNotice(ctx context.Context, userID uint, code, removedBy string) error {
+	m.record("RemoveNotice")
+	if m.RemoveNoticeFn == nil {
+		return ErrNotConfigured
+	}
+	return m.RemoveNoticeFn(ctx, userID, code, removedBy)
+}
+
+func (m *MockUserService) ExportSnapshot(ctx context.Context, id uint, reason string) (*application.SignedSnapshot, error) {
+	m.record("ExportSnapshot")
+	if m.ExportSnapshotFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.ExportSnapshotFn(ctx, id, reason)
+}
+
+func (m *MockUserService) ImportSnapshot(ctx context.Context, bundle *application.SignedSnapshot, overwrite bool, reason string) (*application.SnapshotImport, error) {
+	m.record("ImportSnapshot")
+	if m.ImportSnapshotFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.ImportSnapshotFn(ctx, bundle, overwrite, reason)
+}
+
+func (m *MockUserService) TokenVersion(ctx context.Context, userID uint) (uint, error) {
+	m.record("TokenVersion")
+	if m.TokenVersionFn == nil {
+		return 0, nil
+	}
+	return m.TokenVersionFn(ctx, userID)
+}
+
+func (m *MockUserService) LogoutAll(ctx context.Context, id uint) error {
+	m.record("LogoutAll")
+	if m.LogoutAllFn == nil {
+		return ErrNotConfigured
+	}
+	return m.LogoutAllFn(ctx, id)
+}
+
+func (m *MockUserService) StartSession(ctx context.Context, userID uint) (*domain.Session, error) {
+	m.record("StartSession")
+	if m.StartSessionFn == nil {
+		return nil, application.ErrSessionsNotConfigured
+	}
+	return m.StartSessionFn(ctx, userID)
+}
+
+func (m *MockUserService) ListSessions(ctx context.Context, userID uint) ([]*domain.Session, error) {
+	m.record("ListSessions")
+	if m.ListSessionsFn == nil {
+		return nil, ErrNotConfigured
+	}
+	return m.ListSessionsFn(ctx, userID)
+}
+
+func (m *MockUserService) RevokeSession(ctx context.Context, userID uint, id string) error {
+	m.record("RevokeSession")
+	if m.RevokeSessionFn == nil {
+		return ErrNotConfigured
+	}
+	return m.RevokeSessionFn(ctx, userID, id)
+}
+
+func (m *MockUserService) UseSession(ctx context.Context, userID uint, id string) error {
+	m.record("UseSession")
+	if m.UseSessionFn == nil {
+		return nil
+	}
+	return m.UseSessionFn(ctx, userID, id)
+}
+
+func (m *MockUserService) SessionActive(ctx context.Context, id string) (bool, error) {
+	m.record("SessionActive")
+	if m.SessionActiveFn == nil {
+		return true, nil
+	}
+	return m.SessionActiveFn(ctx, id)
+}